@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"pinkbike-scraper/pkg/brief"
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/priceindex"
+)
+
+func runAnalytics(args []string) error {
+	fs := flag.NewFlagSet("analytics", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database price indexes are stored in")
+	index := fs.String("index", "", "Show the weekly points of this index, \"all\" or a model such as \"Santa Cruz Megatower\" (default lists every index)")
+	weeks := fs.Int("weeks", 26, "Number of recent weeks of -index to show (0 shows all)")
+	refresh := fs.Bool("refresh", false, "Recompute the indexes from the stored listings first")
+	models := fs.Int("models", priceindex.DefaultOptions().Models, "Number of most listed models indexed when refreshing")
+	includeSuspected := fs.Bool("includeSuspected", false, "Keep listings scored as likely scams in the indexes when refreshing")
+	minListings := fs.Int("minListings", priceindex.DefaultOptions().MinListings, "Listings a week needs to get an index point when refreshing")
+	regions := fs.Bool("regions", false, "Compare each model's median price across provinces and states instead, to spot where it sells for less")
+	byCountry := fs.Bool("byCountry", false, "Compare -regions by country, placing listings without a location by their currency")
+	regionList := fs.String("region", "", "Comma-separated regions -regions compares, such as \"British Columbia,Alberta,Washington\" (default all)")
+	regionListings := fs.Int("regionListings", 3, "Listings of a model a region needs for -regions to compare its median")
+	priceDrops := fs.Bool("priceDrops", false, "Check the price drops advertised in titles against the price history instead")
+	category := fs.String("category", "", "Only compare -regions or -priceDrops for listings scraped under this bike type (e.g. enduro)")
+	top := fs.Int("top", 20, "Number of models -regions shows, largest price difference first (0 shows all)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	opts := exporter.DefaultDBOptions()
+	opts.IncludeSuspected = *includeSuspected
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, opts)
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	if *regions {
+		return regionalAnalytics(dbExp, *category, brief.RegionOptions{
+			ByCountry:   *byCountry,
+			MinListings: *regionListings,
+			Regions:     splitList(*regionList),
+		}, *top)
+	}
+
+	if *priceDrops {
+		return priceDropAnalytics(dbExp, *category)
+	}
+
+	if *refresh {
+		n, err := dbExp.RefreshIndexes(priceindex.Options{Models: *models, MinListings: *minListings})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Computed %d index points\n", n)
+	}
+
+	var points []priceindex.Point
+	if *index == "" {
+		points, err = dbExp.LatestIndexes()
+	} else {
+		points, err = dbExp.IndexSeries(*index)
+		if *weeks > 0 && len(points) > *weeks {
+			points = points[len(points)-*weeks:]
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(points) == 0 {
+		if *index != "" {
+			return fmt.Errorf("no index named %q, see analytics without -index", *index)
+		}
+		fmt.Println("No price indexes yet, run with -refresh to compute them")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INDEX\tWEEK\tMEDIAN\tLISTINGS\tVALUE")
+	for _, p := range points {
+		fmt.Fprintf(w, "%s\t%s\t$%.0f\t%d\t%.1f\n", p.Name, p.Week.Format("2006-01-02"), p.Median, p.Listings, p.Value)
+	}
+	return w.Flush()
+}
+
+// regionalAnalytics prints the models whose median price differs the most
+// between regions
+func regionalAnalytics(dbExp *exporter.DBExporter, category string, opts brief.RegionOptions, top int) error {
+	listings, err := dbExp.StoredListings(category)
+	if err != nil {
+		return err
+	}
+	models := brief.CompareRegions(listings, opts)
+	if len(models) == 0 {
+		fmt.Printf("No model has %d or more active listings in two regions\n", opts.MinListings)
+		return nil
+	}
+	if top > 0 && len(models) > top {
+		models = models[:top]
+	}
+	return brief.WriteRegions(os.Stdout, models)
+}
+
+// priceDropAnalytics prints how often the price drops advertised in titles
+// show up in the price history, next to how often other listings drop
+func priceDropAnalytics(dbExp *exporter.DBExporter, category string) error {
+	stats, err := dbExp.PriceDrops(category)
+	if err != nil {
+		return err
+	}
+	if stats.Advertised == 0 {
+		fmt.Println("No listing title advertises a price drop")
+		return nil
+	}
+	fmt.Printf("%d listings advertise a price drop in their title\n", stats.Advertised)
+	fmt.Printf("  %d dropped in price while tracked (%.0f%% of the %d seen on more than one day)\n",
+		stats.Confirmed, stats.ConfirmedShare()*100, stats.Advertised-stats.Unverified)
+	fmt.Printf("  %d were only seen on one day\n", stats.Unverified)
+	fmt.Printf("Of the %d other listings, %d (%.0f%%) dropped in price\n", stats.Unadvertised, stats.Dropped, stats.DroppedShare()*100)
+	return nil
+}
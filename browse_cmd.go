@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/tui"
+)
+
+func runBrowse(args []string) error {
+	fs := flag.NewFlagSet("browse", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database to browse")
+	category := fs.String("category", "", "Only listings scraped under this bike type (e.g. enduro)")
+	manufacturer := fs.String("manufacturer", "", "Only listings from this manufacturer")
+	activeOnly := fs.Bool("active", false, "Start with only active listings shown (toggle with a)")
+	skipBadRows := fs.Bool("skipBadRows", false, "Leave out stored listings that cannot be read instead of failing")
+	nearFlags := addNearFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	dbOptions := exporter.DefaultDBOptions()
+	dbOptions.SkipBadRows = *skipBadRows
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, dbOptions)
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	listings, err := dbExp.QueryListings(exporter.ListingQuery{Manufacturer: *manufacturer, Category: *category})
+	if err != nil {
+		return err
+	}
+	reportSkippedRows(dbExp)
+	keep, err := nearFlags.filter(dbExp)
+	if err != nil {
+		return err
+	}
+	if keep != nil {
+		listings = keepListings(listings, keep)
+	}
+	if len(listings) == 0 {
+		fmt.Println("No listings stored")
+		return nil
+	}
+	favorites, err := dbExp.Favorites()
+	if err != nil {
+		return err
+	}
+
+	browser := tui.NewBrowser(dbExp, listings, favorites, tui.OpenURL)
+	browser.SetActiveOnly(*activeOnly)
+	return tui.Run(browser, os.Stdin, os.Stdout)
+}
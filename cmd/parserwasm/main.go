@@ -0,0 +1,68 @@
+//go:build js && wasm
+
+// Command parserwasm exposes the crawler's title and price parsing rules to
+// JavaScript so browser tools apply exactly the same extraction.
+//
+// Build with:
+//
+//	GOOS=js GOARCH=wasm go build -o parser.wasm ./cmd/parserwasm
+//
+// and load it with the wasm_exec.js shipped in $(go env GOROOT)/misc/wasm.
+package main
+
+import (
+	"syscall/js"
+
+	"pinkbike-scraper/pkg/parser"
+)
+
+func main() {
+	js.Global().Set("pinkbikeParseTitle", js.FuncOf(parseTitle))
+	js.Global().Set("pinkbikeParsePrice", js.FuncOf(parsePrice))
+
+	// keep the exported functions alive
+	select {}
+}
+
+// parseTitle(title) returns {year, manufacturer, model, generation}
+func parseTitle(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.Null()
+	}
+	title := args[0].String()
+
+	manufacturer := parser.ExtractManufacturer(title)
+	model := parser.ExtractModel(title)
+	return map[string]interface{}{
+		"year":         parser.ExtractYear(title),
+		"manufacturer": manufacturer,
+		"model":        model,
+		"generation":   parser.ExtractGeneration(manufacturer, model, title),
+	}
+}
+
+// parsePrice(price, cadToUsdRate) returns {price, currency, max, negotiable, tradeOnly}
+func parsePrice(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return js.Null()
+	}
+	price := args[0].String()
+
+	rate := 1.0
+	if len(args) > 1 && args[1].Type() == js.TypeNumber {
+		rate = args[1].Float()
+	}
+
+	p := parser.ParsePrice(price)
+	max := ""
+	if p.Max > 0 {
+		max = parser.Price{Amount: p.Max, Currency: p.Currency}.USD(rate)
+	}
+	return map[string]interface{}{
+		"price":      p.USD(rate),
+		"currency":   p.Currency,
+		"max":        max,
+		"negotiable": p.Negotiable,
+		"tradeOnly":  p.TradeOnly,
+	}
+}
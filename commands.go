@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"pinkbike-scraper/pkg/exporter"
+)
+
+// command is a subcommand run as "pinkbike-scraper <name> [flags]" instead of a scrape
+type command struct {
+	description string
+	run         func(args []string) error
+}
+
+var commands = map[string]command{
+	"review": {
+		description: "Step through listings flagged for review and correct their year, manufacturer and model",
+		run:         runReview,
+	},
+	"suggest-models": {
+		description: "Suggest model database entries from recent titles without a known model",
+		run:         runSuggestModels,
+	},
+	"telegram-bot": {
+		description: "Answer Telegram commands to manage saved searches and look up median prices",
+		run:         runTelegramBot,
+	},
+	"wayback": {
+		description: "Backfill historical listings from Wayback Machine captures of the buysell pages",
+		run:         runWayback,
+	},
+	"runs": {
+		description: "List recent scrape runs with their counts and errors",
+		run:         runRuns,
+	},
+	"search": {
+		description: "Full-text search stored listings by title and description",
+		run:         runSearch,
+	},
+	"reparse": {
+		description: "Re-derive stored listings' fields with the current parsers and record what changed",
+		run:         runReparse,
+	},
+	"db": {
+		description: "Maintain the listings database: export tables, back it up, move it between machines or vacuum it",
+		run:         runDB,
+	},
+	"import": {
+		description: "Backfill the database from other sources, such as old run CSV files",
+		run:         runImport,
+	},
+	"analytics": {
+		description: "Show the weekly median price indexes of the market and its most listed models, or compare model prices across regions",
+		run:         runAnalytics,
+	},
+	"publish": {
+		description: "Generate a static price guide site with a page per manufacturer and model",
+		run:         runPublish,
+	},
+	"report": {
+		description: "Write an HTML report charting the price indexes' median prices and listing volume",
+		run:         runReport,
+	},
+	"serve": {
+		description: "Serve the price indexes as a JSON API with a stream of listing events, and the listings over gRPC with -grpcListen",
+		run:         runServe,
+	},
+	"deals": {
+		description: "Rank active listings by how far they are priced under their model and year median",
+		run:         runDeals,
+	},
+	"sellers": {
+		description: "List sellers by active listings, flagging private sellers with enough listings to likely be businesses",
+		run:         runSellers,
+	},
+	"browse": {
+		description: "Browse, filter and sort stored listings in the terminal, marking favorites and opening them in a browser",
+		run:         runBrowse,
+	},
+	"favorites": {
+		description: "List, add or remove favorite listings, whose details are refreshed every run, and show how they changed",
+		run:         runFavorites,
+	},
+	"compare": {
+		description: "Compare two or more stored listings side by side: specs, price against the market median, condition and seller",
+		run:         runCompare,
+	},
+	"edits": {
+		description: "Show how sellers edited the titles, descriptions and restrictions of listings scraped again",
+		run:         runEdits,
+	},
+	"doctor": {
+		description: "Check the browser, Pinkbike, the selectors, the database schema, Sheets credentials and exchange rates, explaining how to fix failures",
+		run:         runDoctor,
+	},
+	"flush": {
+		description: "Send exports queued after Sheets or webhook failures now, or list them with -list",
+		run:         runFlush,
+	},
+}
+
+func printCommands(w io.Writer) {
+	printCommandTable(w, commands)
+}
+
+// printCommandTable lists cmds by name with their descriptions lined up after
+// the longest name
+func printCommandTable(w io.Writer, cmds map[string]command) {
+	names := make([]string, 0, len(cmds))
+	for name := range cmds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "  %s\t%s\n", name, cmds[name].description)
+	}
+	tw.Flush()
+}
+
+// reportSkippedRows tells the user about stored rows that were left out of
+// the results because they could not be read
+func reportSkippedRows(dbExp *exporter.DBExporter) {
+	skipped := dbExp.SkippedRows()
+	if len(skipped) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Skipped %d unreadable rows:\n", len(skipped))
+	for _, err := range skipped {
+		fmt.Fprintf(os.Stderr, "  %v\n", &err)
+	}
+}
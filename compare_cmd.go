@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"pinkbike-scraper/pkg/brief"
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/listing"
+)
+
+func runCompare(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database to compare listings from")
+	fixedTime := fs.String("fixedTime", "", "Compare as if it were this time (YYYY-MM-DD or RFC 3339) so the output is reproducible")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), `Usage: pinkbike-scraper compare [flags] <listing> <listing>...
+
+A listing is named by its hash, its URL or its Pinkbike listing ID. Fields the
+listings differ in are marked with an asterisk.`)
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return fmt.Errorf("compare needs at least two listings")
+	}
+	clk, err := parseClock(*fixedTime)
+	if err != nil {
+		return err
+	}
+
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, exporter.DefaultDBOptions())
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	var candidates []listing.Listing
+	for _, ref := range fs.Args() {
+		l, err := dbExp.FindListing(ref)
+		if err != nil {
+			return err
+		}
+		candidates = append(candidates, l)
+	}
+
+	listings, err := dbExp.StoredListings("")
+	if err != nil {
+		return err
+	}
+	reportSkippedRows(dbExp)
+	return brief.WriteComparison(os.Stdout, brief.Compare(candidates, listings, clk.Now()))
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/parser"
+	"pinkbike-scraper/pkg/scraper"
+	"pinkbike-scraper/pkg/storage"
+)
+
+// dbCommands maintain the listings database, run as "pinkbike-scraper db <name> [flags]"
+var dbCommands = map[string]command{
+	"export": {
+		description: "Dump the listings or price_history table to CSV, JSON or Parquet, with filters and a date range",
+		run:         runDBExport,
+	},
+	"backup": {
+		description: "Copy the database to a timestamped file while it stays in use",
+		run:         runDBBackup,
+	},
+	"forget": {
+		description: "Delete a listing, or every listing of a seller, with all data referencing it, for takedown requests",
+		run:         runDBForget,
+	},
+	"dump": {
+		description: "Write the database, or the listings matching filters, to a portable archive another machine can load",
+		run:         runDBDump,
+	},
+	"load": {
+		description: "Create a database from an archive written by db dump, whichever version wrote it",
+		run:         runDBLoad,
+	},
+	"vacuum": {
+		description: "Rebuild the database file to reclaim the space of deleted rows",
+		run:         runDBVacuum,
+	},
+	"clear-cache": {
+		description: "Remove cached exchange rates and geocoded places so they are fetched again",
+		run:         runDBClearCache,
+	},
+}
+
+func runDB(args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := dbCommands[args[0]]; ok {
+			return cmd.run(args[1:])
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, "Usage: pinkbike-scraper db <command> [flags]\n\nCommands:")
+	printCommandTable(os.Stderr, dbCommands)
+	if len(args) == 0 {
+		return fmt.Errorf("missing db command")
+	}
+	return fmt.Errorf("unknown db command %q", args[0])
+}
+
+func runDBExport(args []string) error {
+	fs := flag.NewFlagSet("db export", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database to export from")
+	table := fs.String("table", "listings", "The table to export ("+strings.Join(exporter.DumpTables, ", ")+")")
+	format := fs.String("format", "", "Output format: csv, json, jsonl or parquet (default from the -out extension, else csv)")
+	out := fs.String("out", "", "File to write, standard output when empty")
+	since := fs.String("since", "", "Only listings seen, or prices recorded, on or after this date (YYYY-MM-DD or RFC 3339)")
+	until := fs.String("until", "", "Only listings first seen, or prices recorded, before this date (YYYY-MM-DD or RFC 3339)")
+	manufacturer := fs.String("manufacturer", "", "Only listings from this manufacturer")
+	category := fs.String("category", "", "Only listings scraped under this bike type (e.g. enduro)")
+	active := fs.Bool("active", false, "Only listings still on Pinkbike")
+	uploadTo := fs.String("upload", "", "Upload the -out file to s3://bucket/prefix or gs://bucket/prefix, with credentials from the environment")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *uploadTo != "" && *out == "" {
+		return fmt.Errorf("-upload needs an -out file to upload")
+	}
+
+	opts := exporter.DumpOptions{
+		Table:        *table,
+		Format:       *format,
+		Manufacturer: *manufacturer,
+		Category:     *category,
+		ActiveOnly:   *active,
+	}
+	if opts.Format == "" {
+		opts.Format = strings.TrimPrefix(filepath.Ext(*out), ".")
+		if opts.Format == "" {
+			opts.Format = exporter.DumpCSV
+		}
+	}
+	var err error
+	if *since != "" {
+		if opts.Since, err = parseDate("since", *since); err != nil {
+			return err
+		}
+	}
+	if *until != "" {
+		if opts.Until, err = parseDate("until", *until); err != nil {
+			return err
+		}
+	}
+
+	var uploader *storage.Uploader
+	if *uploadTo != "" {
+		if uploader, err = storage.Open(context.Background(), *uploadTo); err != nil {
+			return err
+		}
+	}
+
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, exporter.DefaultDBOptions())
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	if *out == "" {
+		_, err := dbExp.Dump(os.Stdout, opts)
+		return err
+	}
+
+	// write next to the destination and rename, so a failed export never
+	// leaves a truncated file behind
+	tmp, err := os.CreateTemp(filepath.Dir(*out), filepath.Base(*out)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("could not create %s: %v", *out, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	start := time.Now()
+	n, err := dbExp.Dump(tmp, opts)
+	if chmodErr := tmp.Chmod(0644); err == nil {
+		err = chmodErr
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), *out); err != nil {
+		return fmt.Errorf("could not write %s: %v", *out, err)
+	}
+	fmt.Printf("Exported %d %s rows to %s in %s\n", n, opts.Table, *out, time.Since(start).Round(time.Millisecond))
+
+	if uploader != nil {
+		dest, err := uploader.UploadFile(context.Background(), *out)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Uploaded %s to %s\n", *out, dest)
+	}
+	return nil
+}
+
+func runDBBackup(args []string) error {
+	fs := flag.NewFlagSet("db backup", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database to back up")
+	dir := fs.String("dir", "backups", "Directory timestamped backups are written to")
+	out := fs.String("out", "", "Write the backup to this file instead of a timestamped one in -dir")
+	compress := fs.Bool("gzip", false, "Compress the backup with gzip")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, exporter.DefaultDBOptions())
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	path := *out
+	if path == "" {
+		path = exporter.BackupPath(*dir, *dbPath, "", time.Now(), *compress)
+	}
+	if err := dbExp.Backup(path, *compress); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("could not read backup: %v", err)
+	}
+	fmt.Printf("Backed up %s to %s (%s)\n", *dbPath, path, formatBytes(info.Size()))
+	return nil
+}
+
+func runDBDump(args []string) error {
+	fs := flag.NewFlagSet("db dump", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database to archive")
+	out := fs.String("out", "", "Archive file to write (default listings-<time>.jsonl.gz)")
+	since := fs.String("since", "", "Only listings seen on or after this date (YYYY-MM-DD or RFC 3339)")
+	until := fs.String("until", "", "Only listings first seen before this date (YYYY-MM-DD or RFC 3339)")
+	manufacturer := fs.String("manufacturer", "", "Only listings from this manufacturer")
+	category := fs.String("category", "", "Only listings scraped under this bike type (e.g. enduro)")
+	active := fs.Bool("active", false, "Only listings still on Pinkbike")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	opts := exporter.ArchiveOptions{Manufacturer: *manufacturer, Category: *category, ActiveOnly: *active}
+	var err error
+	if *since != "" {
+		if opts.Since, err = parseDate("since", *since); err != nil {
+			return err
+		}
+	}
+	if *until != "" {
+		if opts.Until, err = parseDate("until", *until); err != nil {
+			return err
+		}
+	}
+	path := *out
+	if path == "" {
+		path = fmt.Sprintf("listings-%s.jsonl.gz", time.Now().UTC().Format("20060102-150405"))
+	}
+
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, exporter.DefaultDBOptions())
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	// write next to the destination and rename, so a failed dump never
+	// leaves a truncated archive behind
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("could not create %s: %v", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	result, err := dbExp.WriteArchive(tmp, opts)
+	if chmodErr := tmp.Chmod(0644); err == nil {
+		err = chmodErr
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("could not write %s: %v", path, err)
+	}
+	fmt.Printf("Archived %d listings and %d rows in all to %s\n", result["listings"], result.Total(), path)
+	return nil
+}
+
+func runDBLoad(args []string) error {
+	fs := flag.NewFlagSet("db load", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The database to create; it must not exist yet")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: pinkbike-scraper db load [-db listings.db] <archive>")
+	}
+
+	if _, err := os.Stat(*dbPath); err == nil {
+		return fmt.Errorf("%s already exists; load into a new database, or back it up and remove it first", *dbPath)
+	}
+	in, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	// build the database next to the destination and rename it once loaded,
+	// so a failed load never leaves a partial database behind
+	tmp := *dbPath + ".loading"
+	defer os.Remove(tmp)
+	// rows are loaded as they were archived, whether or not the listings they
+	// reference were archived with them
+	opts := exporter.DefaultDBOptions()
+	opts.ForeignKeys = false
+	opts.WAL = false
+	dbExp, err := exporter.NewDBExporter(tmp, nil, opts)
+	if err != nil {
+		return fmt.Errorf("could not create database: %v", err)
+	}
+	result, err := dbExp.LoadArchive(in)
+	if closeErr := dbExp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, *dbPath); err != nil {
+		return fmt.Errorf("could not write %s: %v", *dbPath, err)
+	}
+
+	tables := make([]string, 0, len(result))
+	for table, n := range result {
+		if n > 0 {
+			tables = append(tables, table)
+		}
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		fmt.Printf("Loaded %d rows into %s\n", result[table], table)
+	}
+	fmt.Printf("Created %s from %s\n", *dbPath, fs.Arg(0))
+	return nil
+}
+
+func runDBVacuum(args []string) error {
+	fs := flag.NewFlagSet("db vacuum", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database to compact")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, exporter.DefaultDBOptions())
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	before, after, err := dbExp.Vacuum()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Compacted %s from %s to %s\n", *dbPath, formatBytes(before), formatBytes(after))
+	return nil
+}
+
+func runDBClearCache(args []string) error {
+	fs := flag.NewFlagSet("db clear-cache", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database to clear the cache of")
+	prefix := fs.String("prefix", "", "Only remove cached values whose keys start with this, such as rate/ or geocode/ (default removes all)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, exporter.DefaultDBOptions())
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	cleared, err := dbExp.ClearCache(*prefix)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Removed %d cached values\n", cleared)
+	return nil
+}
+
+func runDBForget(args []string) error {
+	fs := flag.NewFlagSet("db forget", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database to delete from")
+	url := fs.String("url", "", "Pinkbike URL of the listing to forget")
+	seller := fs.String("seller", "", "Pinkbike username of the seller whose listings and seller records to forget")
+	dryRun := fs.Bool("dryRun", false, "Count the rows that would be deleted without deleting them")
+	vacuum := fs.Bool("vacuum", true, "Vacuum the database afterwards so deleted rows are not left in its free pages")
+	snapshotDir := fs.String("snapshotDir", "", "Directory scrapes saved page snapshots to; the listings' detail pages are deleted from it")
+	debugDir := fs.String("debugDir", "debug", "Directory -debugScrape saved captures to; the listings' captures are deleted from it")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, exporter.DefaultDBOptions())
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	// the seller's listings are looked up before their rows are deleted
+	urls, err := forgottenURLs(dbExp, *url, *seller)
+	if err != nil {
+		return err
+	}
+
+	result, err := dbExp.Forget(exporter.ForgetRequest{URL: *url, Seller: *seller, DryRun: *dryRun})
+	if err != nil {
+		return err
+	}
+
+	verb := "Deleted"
+	if *dryRun {
+		verb = "Would delete"
+	}
+	files, err := forgetFiles(urls, *snapshotDir, *debugDir, *dryRun)
+	for _, dir := range []string{*snapshotDir, *debugDir} {
+		if n := files[dir]; n > 0 {
+			fmt.Printf("%s %d files from %s\n", verb, n, dir)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if result.Total() == 0 {
+		fmt.Println("Nothing matched, no rows deleted")
+		return nil
+	}
+	tables := make([]string, 0, len(result))
+	for table, n := range result {
+		if n > 0 {
+			tables = append(tables, table)
+		}
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		fmt.Printf("%s %d rows from %s\n", verb, result[table], table)
+	}
+	if *dryRun {
+		return nil
+	}
+
+	if *vacuum {
+		if _, _, err := dbExp.Vacuum(); err != nil {
+			return err
+		}
+	}
+	fmt.Println("Backups, CSV files and spreadsheets exported earlier still hold the data and must be cleaned up separately")
+	if *snapshotDir == "" {
+		fmt.Println("Page snapshots are only deleted when -snapshotDir names the directory scrapes saved them to")
+	} else {
+		fmt.Printf("Snapshots of listings pages in %s may still show the listings among others\n", *snapshotDir)
+	}
+	fmt.Printf("Playwright traces in %s record whole scrapes and may still show them too\n", *debugDir)
+	return nil
+}
+
+// forgottenURLs returns the URL to forget, or the URLs of the seller's stored
+// listings, with their canonical forms
+func forgottenURLs(dbExp *exporter.DBExporter, url, seller string) ([]string, error) {
+	var urls []string
+	if url = strings.TrimSpace(url); url != "" {
+		urls = append(urls, url)
+	}
+	if seller = strings.TrimSpace(seller); seller != "" {
+		listings, err := dbExp.StoredListings("")
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range listings {
+			if l.URL != "" && strings.EqualFold(l.Details.Seller, seller) {
+				urls = append(urls, l.URL)
+			}
+		}
+	}
+	for _, u := range urls {
+		if canonical := parser.CanonicalURL(u); canonical != u {
+			urls = append(urls, canonical)
+		}
+	}
+	return urls, nil
+}
+
+// forgetFiles deletes the page snapshots and debug captures of the listings
+// at urls and returns how many files were deleted from each directory
+func forgetFiles(urls []string, snapshotDir, debugDir string, dryRun bool) (map[string]int, error) {
+	deleted := map[string]bool{}
+	counts := map[string]int{}
+	count := func(dir string, files []string) {
+		for _, f := range files {
+			if !deleted[f] {
+				deleted[f] = true
+				counts[dir]++
+			}
+		}
+	}
+	for _, u := range urls {
+		if snapshotDir != "" {
+			files, err := (&scraper.Snapshots{Dir: snapshotDir}).Forget(u, dryRun)
+			count(snapshotDir, files)
+			if err != nil {
+				return counts, err
+			}
+		}
+		files, err := scraper.ForgetDebugCaptures(debugDir, u, dryRun)
+		count(debugDir, files)
+		if err != nil {
+			return counts, err
+		}
+	}
+	return counts, nil
+}
+
+// formatBytes prints a size in the largest unit it fills, such as "12.3 MB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"pinkbike-scraper/pkg/brief"
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/notify"
+)
+
+func runDeals(args []string) error {
+	fs := flag.NewFlagSet("deals", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database to rank active listings from")
+	top := fs.Int("top", 10, "Number of deals to show")
+	category := fs.String("category", "", "Only listings scraped under this bike type (e.g. enduro)")
+	manufacturer := fs.String("manufacturer", "", "Only listings from this manufacturer")
+	size := fs.String("size", "", "Only listings of this frame size (e.g. L)")
+	wheels := fs.String("wheels", "", "Only listings with these wheels (29, 27.5, 26 or mullet)")
+	currency := fs.String("currency", "", "Only listings priced in this currency, CAD for Canadian sellers or USD for US ones")
+	minScore := fs.Float64("minScore", 0, "Only listings at least this fraction under their median (0.1 for 10%)")
+	conditionPhotos := fs.Bool("conditionPhotos", false, "Only listings with enough photos to judge their condition")
+	nearFlags := addNearFlags(fs)
+	webhookURL := fs.String("webhook", "", "Also post the deals to this webhook URL")
+	webhookFormat := fs.String("webhookFormat", "json", "Webhook body format: json, discord or slack")
+	sheetTab := fs.String("sheet", "", "Also write the deals to this tab of -spreadsheetID, replacing what it held")
+	sheetID := fs.String("spreadsheetID", spreadsheetID, "The Google Sheets spreadsheet -sheet is written to")
+	sheetsCredentials := fs.String("sheetsCredentials", "pinkbike-exporter-8bc8e681ffa1.json", "Google service account key or OAuth client secret used for Sheets exports")
+	sheetsTokenFile := fs.String("sheetsTokenFile", "token.json", "Where the OAuth token is cached when -sheetsCredentials is an OAuth client secret")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	format, err := notify.ParseFormat(*webhookFormat)
+	if err != nil {
+		return err
+	}
+
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, exporter.DefaultDBOptions())
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	near, err := nearFlags.locate(dbExp)
+	if err != nil {
+		return err
+	}
+	listings, err := dbExp.StoredListings("")
+	if err != nil {
+		return err
+	}
+	deals := brief.RankDeals(listings, brief.DealFilter{
+		Category:        *category,
+		Manufacturer:    *manufacturer,
+		Size:            *size,
+		Wheels:          *wheels,
+		Currency:        *currency,
+		MinScore:        *minScore,
+		Near:            near,
+		MaxKM:           *nearFlags.maxKM,
+		ConditionPhotos: *conditionPhotos,
+	}, *top)
+
+	if len(deals) == 0 {
+		fmt.Println("No deals found")
+		return nil
+	}
+	fmt.Println("Top deals:")
+	brief.WriteDeals(os.Stdout, deals)
+
+	if *webhookURL != "" {
+		if err := notify.NewWebhook(*webhookURL, notify.WebhookOptions{Format: format}).PostDeals(deals); err != nil {
+			return fmt.Errorf("could not post deals: %v", err)
+		}
+	}
+	if *sheetTab != "" {
+		sheets, err := exporter.NewSheetsExporter(*sheetsCredentials, *sheetID, *sheetTab, exporter.SheetsOptions{TokenFile: *sheetsTokenFile})
+		if err != nil {
+			return fmt.Errorf("could not create sheets exporter: %v", err)
+		}
+		defer sheets.Close()
+		if err := sheets.WriteTab(*sheetTab, dealRows(deals)); err != nil {
+			return fmt.Errorf("could not write deals to sheets: %v", err)
+		}
+	}
+	return nil
+}
+
+// dealRows lays deals out as a sheet with a header row
+func dealRows(deals []brief.Deal) [][]interface{} {
+	rows := [][]interface{}{{"Rank", "Title", "Price", "Currency", "Median", "Median Of", "Comps", "Under Median", "Size", "URL"}}
+	for i, d := range deals {
+		basis := "all years"
+		switch {
+		case d.Predicted:
+			basis = "price model"
+		case d.Year != "":
+			basis = d.Year
+		}
+		rows = append(rows, []interface{}{
+			i + 1, d.Listing.Title, d.Listing.Price, d.Listing.Currency, d.Median, basis, d.Comps,
+			fmt.Sprintf("%.0f%%", d.Score*100), d.Listing.NormalizedSize, d.Listing.URL,
+		})
+	}
+	return rows
+}
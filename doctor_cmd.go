@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"pinkbike-scraper/pkg/currency"
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/scraper"
+)
+
+// errSkipped marks a check that could not run because one it depends on failed
+var errSkipped = errors.New("skipped")
+
+// doctorCheck verifies one thing a scrape depends on. run returns what it
+// found, and on failure how to fix it.
+type doctorCheck struct {
+	name string
+	run  func() (detail, fix string, err error)
+}
+
+func runDoctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database whose schema is checked")
+	browser := fs.String("browser", scraper.Chromium, "Browser engine to check ("+scraper.Chromium+", "+scraper.Firefox+", "+scraper.WebKit+")")
+	browserPath := fs.String("browserPath", "", "Check the browser installed at this path instead of one Playwright downloads")
+	headless := fs.Bool("headless", true, "Run the browser in headless mode")
+	proxy := fs.String("proxy", "", "Proxy server URL the browser and the reachability check connect through")
+	selectorsPath := fs.String("selectors", "", "JSON file of Pinkbike page selectors to check instead of the built-in ones")
+	bikeType := fs.String("bikeType", "enduro", "The type of bike whose listings page is checked ("+strings.Join(scraper.BikeTypeNames(), ", ")+")")
+	pageTimeout := fs.Duration("pageTimeout", 30*time.Second, "How long each page load may take")
+	sheetID := fs.String("spreadsheetID", spreadsheetID, "The Google Sheets spreadsheet the credentials must be able to read (empty only checks they authenticate)")
+	sheetsCredentials := fs.String("sheetsCredentials", "pinkbike-exporter-8bc8e681ffa1.json", "Google service account key or OAuth client secret used for Sheets exports")
+	sheetsTokenFile := fs.String("sheetsTokenFile", "token.json", "Where the OAuth token is cached when -sheetsCredentials is an OAuth client secret")
+	skip := fs.String("skip", "", "Comma-separated checks to skip (browser, pinkbike, selectors, database, sheets, rates), such as sheets on a machine that only scrapes")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), `Usage: pinkbike-scraper doctor [flags]
+
+Checks everything a scrape depends on: that the browser installs and starts,
+that Pinkbike is reachable, that the selectors still match a live listings
+page, that the database schema is current, that the Sheets credentials work
+and that exchange rates can be fetched. Each failure says how to fix it, and
+the command exits non-zero when any check fails.`)
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	bikeTypeInfo, err := scraper.LookupBikeType(*bikeType)
+	if err != nil {
+		return err
+	}
+	opts := scraper.DefaultScrapeOptions()
+	opts.BikeType = bikeTypeInfo
+	opts.Headless = *headless
+	opts.Proxy = *proxy
+	opts.Engine = strings.ToLower(strings.TrimSpace(*browser))
+	opts.BrowserPath = *browserPath
+	opts.Timeout = *pageTimeout
+	if *selectorsPath != "" {
+		if opts.Selectors, err = scraper.LoadSelectors(*selectorsPath); err != nil {
+			return fmt.Errorf("could not load selectors: %v", err)
+		}
+	}
+	scr, err := scraper.NewScraper(opts)
+	if err != nil {
+		return fmt.Errorf("could not create scraper: %v", err)
+	}
+	defer scr.Close()
+
+	browserOK := false
+	checks := []doctorCheck{
+		{"browser", func() (string, string, error) {
+			if err := scr.CheckBrowser(); err != nil {
+				fix := "install the browser and its system libraries with: go run github.com/playwright-community/playwright-go/cmd/playwright install --with-deps " + opts.Engine
+				if opts.BrowserPath != "" {
+					fix = "check that -browserPath points at a " + opts.Engine + " executable and that the Playwright driver is installed"
+				}
+				return "", fix, err
+			}
+			browserOK = true
+			return opts.Engine + " starts", "", nil
+		}},
+		{"pinkbike", func() (string, string, error) {
+			err := scraper.CheckReachable(opts)
+			if errors.Is(err, scraper.ErrBlocked) {
+				return "", "plain requests are challenged, which the browser may still pass; if scrapes are blocked too, lower -workers, raise -pageDelay or set -proxy", err
+			}
+			if err != nil {
+				return "", "check the network connection and DNS, or route through -proxy", err
+			}
+			return opts.BikeType.ListingsURL(opts.BaseURL) + " answers", "", nil
+		}},
+		{"selectors", func() (string, string, error) {
+			if !browserOK {
+				return "", "needs the browser check to pass", errSkipped
+			}
+			if err := scr.SelfCheck(); err != nil {
+				var layoutErr *scraper.LayoutError
+				if errors.As(err, &layoutErr) {
+					return "", "write the broken selectors to a JSON file and pass it with -selectors", err
+				}
+				return "", "check that Pinkbike loads in the browser, with -headless=false to watch it", err
+			}
+			return fmt.Sprintf("version %d matches the listings and a detail page", opts.Selectors.Version), "", nil
+		}},
+		{"database", func() (string, string, error) {
+			status, err := exporter.InspectSchema(*dbPath)
+			switch {
+			case err != nil:
+				return "", "check that " + *dbPath + " is a readable SQLite database, or restore it from a backup", err
+			case !status.Exists:
+				return *dbPath + " does not exist yet and is created by the first scrape", "", nil
+			case status.Newer():
+				return "", "upgrade pinkbike-scraper, or restore a backup made before the newer version migrated it",
+					fmt.Errorf("schema version %d is newer than this version's %d", status.Version, status.Current)
+			case status.Outdated():
+				return fmt.Sprintf("schema version %d is migrated to %d, after a backup, when next opened", status.Version, status.Current), "", nil
+			}
+			return fmt.Sprintf("schema version %d is current", status.Version), "", nil
+		}},
+		{"sheets", func() (string, string, error) {
+			if err := exporter.CheckSheetsAccess(*sheetsCredentials, *sheetsTokenFile, *sheetID); err != nil {
+				fix := "point -sheetsCredentials at a service account key or OAuth client secret, share the spreadsheet with the service account, " +
+					"or run a scrape with -export=sheets once to authorize an OAuth client"
+				return "", fix, err
+			}
+			if *sheetID == "" {
+				return "credentials load", "", nil
+			}
+			return "spreadsheet " + *sheetID + " is readable", "", nil
+		}},
+		{"rates", func() (string, string, error) {
+			rate, err := currency.ExchangeRateAPI{}.CADtoUSD()
+			if err != nil {
+				return "", "check the network connection; scrapes need the CAD to USD rate", err
+			}
+			return fmt.Sprintf("CAD to USD is %.4f from %s", rate.Value, rate.Source), "", nil
+		}},
+	}
+
+	skipped := map[string]bool{}
+	for _, name := range splitList(*skip) {
+		skipped[strings.ToLower(name)] = true
+	}
+	names := map[string]bool{}
+	for _, c := range checks {
+		names[c.name] = true
+	}
+	for name := range skipped {
+		if !names[name] {
+			return fmt.Errorf("unknown check %q in -skip", name)
+		}
+	}
+	failed := 0
+	for _, c := range checks {
+		if skipped[c.name] {
+			fmt.Printf("%-5s %-10s skipped with -skip\n", "skip", c.name)
+			continue
+		}
+		detail, fix, err := c.run()
+		switch {
+		case errors.Is(err, errSkipped):
+			fmt.Printf("%-5s %-10s %s\n", "skip", c.name, fix)
+		case err != nil:
+			failed++
+			fmt.Printf("%-5s %-10s %s\n", "FAIL", c.name, indent(err.Error()))
+			fmt.Printf("%-16s fix: %s\n", "", fix)
+		default:
+			fmt.Printf("%-5s %-10s %s\n", "ok", c.name, detail)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks)-len(skipped))
+	}
+	return nil
+}
+
+// indent lines up the continuation lines of a multi-line error, such as a
+// LayoutError's table of selectors, under its first line
+func indent(s string) string {
+	return strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n"+strings.Repeat(" ", 17))
+}
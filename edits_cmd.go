@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"pinkbike-scraper/pkg/exporter"
+)
+
+func runEdits(args []string) error {
+	fs := flag.NewFlagSet("edits", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database edits are recorded in")
+	days := fs.Int("days", 7, "How many days of edits to show (0 shows all)")
+	limit := fs.Int("limit", 50, "Show at most this many of the most recent edits (0 shows all)")
+	full := fs.Bool("full", false, "Print the whole text before and after each edit instead of the changed words")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), `Usage: pinkbike-scraper edits [flags] [listing]
+
+Shows how sellers edited the titles of listings, and the descriptions and
+restrictions of listings whose detail pages were scraped again, such as
+favorites. A listing is named by its hash, its URL or its Pinkbike listing ID.`)
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("edits takes at most one listing")
+	}
+
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, exporter.DefaultDBOptions())
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	var hash string
+	if fs.NArg() == 1 {
+		l, err := dbExp.FindListing(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		hash = l.Hash
+	}
+	var since time.Time
+	if *days > 0 {
+		since = time.Now().AddDate(0, 0, -*days)
+	}
+
+	edits, err := dbExp.ListingEdits(hash, since, *limit)
+	if err != nil {
+		return err
+	}
+	if len(edits) == 0 {
+		fmt.Println("No edits recorded")
+		return nil
+	}
+	for _, e := range edits {
+		fmt.Printf("%s  %s edited the %s\n", e.EditedAt.Local().Format("2006-01-02 15:04"), e.Title, e.Field)
+		if *full {
+			fmt.Printf("  before: %s\n  after:  %s\n", e.Old, e.New)
+		} else {
+			fmt.Printf("  %s\n", e.Diff)
+		}
+		fmt.Printf("  %s\n", e.URL)
+	}
+	return nil
+}
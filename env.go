@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// envPrefix starts the environment variable every flag can also be set with,
+// such as PINKBIKE_BIKE_TYPE for -bikeType, so containers can be configured
+// through their environment
+const envPrefix = "PINKBIKE_"
+
+// envName returns the environment variable of a flag, splitting its camel
+// case into words: webhookURL becomes PINKBIKE_WEBHOOK_URL
+func envName(flagName string) string {
+	runes := []rune(flagName)
+	var b strings.Builder
+	b.WriteString(envPrefix)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// applyEnv sets each flag of fs not given on the command line from its
+// environment variable, so command line flags override the environment
+func applyEnv(fs *flag.FlagSet) error {
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if given[f.Name] || err != nil {
+			return
+		}
+		name := envName(f.Name)
+		if value, ok := os.LookupEnv(name); ok {
+			if setErr := fs.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid %s %q: %v", name, value, setErr)
+			}
+		}
+	})
+	return err
+}
+
+// parseFlags parses a command's flags, then fills in the ones not given from
+// the environment
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return applyEnv(fs)
+}
+
+// useDataDir makes dir, such as a mounted volume, the directory the database,
+// runs and every other relative path are read from and written to. Relative
+// paths given in flags are resolved against it too.
+func useDataDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("could not create data directory: %v", err)
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("could not resolve data directory: %v", err)
+	}
+	if err := os.Chdir(abs); err != nil {
+		return fmt.Errorf("could not use data directory: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/currency"
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/privacy"
+	"pinkbike-scraper/pkg/scraper"
+)
+
+// exportConfig carries everything the exporter factories need to build an exporter
+type exportConfig struct {
+	bikeType          scraper.BikeTypeInfo
+	csvOptions        exporter.CSVOptions
+	spreadsheetID     string
+	sheetsCredentials string
+	sheetsOptions     exporter.SheetsOptions
+	tableOptions      exporter.TableOptions
+	natsURL           string
+	natsOptions       exporter.NATSOptions
+	// anonymize holds the anonymizer of each export mode whose output has
+	// seller-identifying data removed
+	anonymize map[string]privacy.Anonymizer
+	// reportCurrency is the currency exports other than the database show
+	// prices in, converted with converter
+	reportCurrency string
+	converter      *currency.Converter
+	dbExporter     *exporter.DBExporter
+	clock          clock.Clock
+}
+
+type exporterFactory struct {
+	description string
+	create      func(cfg exportConfig) (exporter.Exporter, error)
+	// network marks modes that send listings over the network, which
+	// -offline refuses
+	network bool
+}
+
+// exporterRegistry lists every export mode accepted by -export
+var exporterRegistry = map[string]exporterFactory{
+	"csv": {
+		description: "write good and suspect listings to CSV files under runs/",
+		create: func(cfg exportConfig) (exporter.Exporter, error) {
+			fileName := getFileName(cfg.bikeType.Type, clock.Or(cfg.clock).Now())
+			// a fresh data directory has no runs yet
+			if err := os.MkdirAll("runs", 0755); err != nil {
+				return nil, err
+			}
+			return exporter.NewCSVExporter(
+				"runs/"+fileName,
+				"runs/suspect_"+fileName,
+				cfg.csvOptions,
+			), nil
+		},
+	},
+	"sheets": {
+		description: "append listings to the Google Sheets spreadsheet",
+		create: func(cfg exportConfig) (exporter.Exporter, error) {
+			sheets, err := exporter.NewSheetsExporter(
+				cfg.sheetsCredentials,
+				cfg.spreadsheetID,
+				cfg.bikeType.SheetName,
+				cfg.sheetsOptions,
+			)
+			if err != nil {
+				return nil, err
+			}
+			// batches the sheet rejects are queued in the database and retried on later runs
+			return exporter.NewRetryingExporter("sheets:"+cfg.bikeType.SheetName, sheets, cfg.dbExporter), nil
+		},
+		network: true,
+	},
+	"table": {
+		description: "print listings as an aligned table, see -tableFields and -tableSort",
+		create: func(cfg exportConfig) (exporter.Exporter, error) {
+			return exporter.NewTableExporter(os.Stdout, cfg.tableOptions)
+		},
+	},
+	"nats": {
+		description: "publish each listing as JSON to the NATS server at -natsURL",
+		create: func(cfg exportConfig) (exporter.Exporter, error) {
+			return exporter.NewNATSExporter(cfg.natsURL, cfg.natsOptions)
+		},
+		network: true,
+	},
+	"db": {
+		description: "store listings and price history in the SQLite database",
+		create: func(cfg exportConfig) (exporter.Exporter, error) {
+			return cfg.dbExporter, nil
+		},
+	},
+}
+
+func exporterNames() []string {
+	names := make([]string, 0, len(exporterRegistry))
+	for name := range exporterRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func printExporters() {
+	for _, name := range exporterNames() {
+		fmt.Printf("%-8s %s\n", name, exporterRegistry[name].description)
+	}
+}
+
+// parseExportModes splits a comma-separated list of export modes, rejecting
+// unknown modes and dropping duplicates
+func parseExportModes(modes string) ([]string, error) {
+	var parsed []string
+	seen := map[string]bool{}
+	for _, mode := range strings.Split(modes, ",") {
+		mode = strings.ToLower(strings.TrimSpace(mode))
+		if mode == "" || seen[mode] {
+			continue
+		}
+		if _, ok := exporterRegistry[mode]; !ok {
+			return nil, fmt.Errorf("unknown export mode %q (available: %s)", mode, strings.Join(exporterNames(), ", "))
+		}
+		seen[mode] = true
+		parsed = append(parsed, mode)
+	}
+	return parsed, nil
+}
+
+// setupExporters builds an exporter for each mode, closing any already created if one fails
+func setupExporters(modes []string, cfg exportConfig) ([]exporter.Exporter, error) {
+	var exporters []exporter.Exporter
+	for _, mode := range modes {
+		factory, ok := exporterRegistry[mode]
+		if !ok {
+			closeExporters(exporters)
+			return nil, fmt.Errorf("unknown export mode %q (available: %s)", mode, strings.Join(exporterNames(), ", "))
+		}
+
+		exp, err := factory.create(cfg)
+		if err != nil {
+			closeExporters(exporters)
+			return nil, fmt.Errorf("could not create %s exporter: %w", mode, err)
+		}
+		if anonymizer, ok := cfg.anonymize[mode]; ok {
+			exp = exporter.NewAnonymizingExporter(exp, anonymizer)
+		}
+		// the database keeps every price in USD
+		if mode != "db" && cfg.reportCurrency != "" && cfg.reportCurrency != currency.USD {
+			exp = exporter.NewConvertingExporter(exp, cfg.reportCurrency, cfg.converter)
+		}
+		exporters = append(exporters, exp)
+	}
+	return exporters, nil
+}
+
+// parseAnonymize parses a comma-separated list of export modes to anonymize,
+// each optionally followed by =hash or =drop, such as "csv=drop,nats". Modes
+// without one hash. An empty salt is replaced with a random one, so hashes
+// only group sellers within the run.
+func parseAnonymize(value, salt string) (map[string]privacy.Anonymizer, error) {
+	items := splitList(value)
+	if len(items) == 0 {
+		return nil, nil
+	}
+	if salt == "" {
+		var random [16]byte
+		if _, err := rand.Read(random[:]); err != nil {
+			return nil, fmt.Errorf("could not generate anonymization salt: %v", err)
+		}
+		salt = hex.EncodeToString(random[:])
+	}
+
+	anonymize := map[string]privacy.Anonymizer{}
+	for _, item := range items {
+		name, modeName, hasMode := strings.Cut(item, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := exporterRegistry[name]; !ok {
+			return nil, fmt.Errorf("unknown export mode %q to anonymize (available: %s)", name, strings.Join(exporterNames(), ", "))
+		}
+		mode := privacy.Hash
+		if hasMode {
+			var err error
+			if mode, err = privacy.ParseMode(modeName); err != nil {
+				return nil, err
+			}
+		}
+		anonymize[name] = privacy.Anonymizer{Mode: mode, Salt: salt}
+	}
+	return anonymize, nil
+}
+
+func closeExporters(exporters []exporter.Exporter) {
+	for _, e := range exporters {
+		e.Close()
+	}
+}
+
+// colorEnabled reports whether f is a terminal that should get colored
+// output, honoring the NO_COLOR convention
+func colorEnabled(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return isTerminal(f)
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"pinkbike-scraper/pkg/brief"
+	"pinkbike-scraper/pkg/exporter"
+)
+
+func runFavorites(args []string) error {
+	fs := flag.NewFlagSet("favorites", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database favorites are kept in")
+	days := fs.Int("days", 7, "How many days of changes \"changes\" shows")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), `Usage: pinkbike-scraper favorites [flags] [list | add <listing>... | remove <listing>... | changes]
+
+A listing is named by its hash, its URL or its Pinkbike listing ID.`)
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, exporter.DefaultDBOptions())
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	action, refs := "list", fs.Args()
+	if len(refs) > 0 {
+		action, refs = refs[0], refs[1:]
+	}
+	switch action {
+	case "list":
+		return listFavorites(dbExp)
+	case "add", "remove":
+		if len(refs) == 0 {
+			fs.Usage()
+			return fmt.Errorf("favorites %s needs a listing", action)
+		}
+		for _, ref := range refs {
+			l, err := dbExp.FindListing(ref)
+			if err != nil {
+				return err
+			}
+			if err := dbExp.SetFavorite(l.Hash, action == "add"); err != nil {
+				return err
+			}
+			if action == "add" {
+				fmt.Printf("Added %s to favorites\n", l.Title)
+			} else {
+				fmt.Printf("Removed %s from favorites\n", l.Title)
+			}
+		}
+		return nil
+	case "changes":
+		updates, err := favoriteUpdates(dbExp, time.Now().AddDate(0, 0, -*days))
+		if err != nil {
+			return err
+		}
+		if len(updates) == 0 {
+			fmt.Printf("No favorites changed in the last %d days\n", *days)
+			return nil
+		}
+		for _, u := range updates {
+			fmt.Printf("%s\n", u.Title)
+			for _, c := range u.Changes {
+				fmt.Printf("  %s\n", c)
+			}
+			fmt.Printf("  %s\n", u.URL)
+		}
+		return nil
+	}
+	fs.Usage()
+	return fmt.Errorf("unknown favorites action %q", action)
+}
+
+func listFavorites(dbExp *exporter.DBExporter) error {
+	favorites, err := dbExp.FavoriteListings()
+	if err != nil {
+		return err
+	}
+	if len(favorites) == 0 {
+		fmt.Fprintln(os.Stderr, "No favorites yet, add one with: pinkbike-scraper favorites add <listing URL>")
+		return nil
+	}
+	for _, l := range favorites {
+		status := "active"
+		if !l.Active {
+			status = "sold"
+		}
+		fmt.Printf("%s - $%s [%s]\n\t%s\n\t%s\n", l.Title, l.Price, status, l.URL, l.Hash)
+	}
+	return nil
+}
+
+// favoriteUpdates groups the changes recorded on favorites since since by
+// listing, for the brief
+func favoriteUpdates(dbExp *exporter.DBExporter, since time.Time) ([]brief.FavoriteUpdate, error) {
+	changes, err := dbExp.FavoriteChanges(since)
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []brief.FavoriteUpdate
+	index := map[string]int{}
+	for _, c := range changes {
+		i, ok := index[c.Hash]
+		if !ok {
+			i = len(updates)
+			index[c.Hash] = i
+			updates = append(updates, brief.FavoriteUpdate{Title: c.Title, URL: c.URL})
+		}
+		updates[i].Changes = append(updates[i].Changes, fmt.Sprintf("%s %s -> %s", c.Field, c.Old, c.New))
+	}
+	return updates, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/notify"
+)
+
+func runFlush(args []string) error {
+	fs := flag.NewFlagSet("flush", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database exports are queued in")
+	only := fs.String("sink", "", "Only flush queues whose name starts with this, such as sheets or webhook")
+	list := fs.Bool("list", false, "List the queued exports without sending them")
+	sheetID := fs.String("spreadsheetID", spreadsheetID, "The Google Sheets spreadsheet queued sheet batches are sent to")
+	sheetsCredentials := fs.String("sheetsCredentials", "pinkbike-exporter-8bc8e681ffa1.json", "Google service account key or OAuth client secret used for Sheets exports")
+	sheetsTokenFile := fs.String("sheetsTokenFile", "token.json", "Where the OAuth token is cached when -sheetsCredentials is an OAuth client secret")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, exporter.DefaultDBOptions())
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	sinks, err := dbExp.PendingSinks()
+	if err != nil {
+		return err
+	}
+	var selected []exporter.PendingSink
+	for _, s := range sinks {
+		if strings.HasPrefix(s.Sink, *only) {
+			selected = append(selected, s)
+		}
+	}
+	if len(selected) == 0 {
+		fmt.Println("No queued exports")
+		return nil
+	}
+
+	if *list {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SINK\tBATCHES\tNEXT ATTEMPT\tLAST ERROR")
+		for _, s := range selected {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", s.Sink, s.Batches, s.NextAttempt.Local().Format("2006-01-02 15:04"), s.LastError)
+		}
+		return w.Flush()
+	}
+
+	failed := 0
+	for _, s := range selected {
+		send, done, err := flushSender(s.Sink, *sheetsCredentials, *sheetID, *sheetsTokenFile)
+		if err != nil {
+			fmt.Printf("%s: %v\n", s.Sink, err)
+			failed++
+			continue
+		}
+		sent, err := dbExp.FlushPending(s.Sink, send)
+		done()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s: sent %d of %d batches\n", s.Sink, sent, s.Batches)
+		if sent < s.Batches {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d queues still hold exports, see flush -list", failed, len(selected))
+	}
+	return nil
+}
+
+// flushSender returns the send function for a queue, and a function
+// releasing what it opened
+func flushSender(sink, sheetsCredentials, sheetID, sheetsTokenFile string) (func([]byte) error, func(), error) {
+	kind, target, _ := strings.Cut(sink, ":")
+	switch kind {
+	case "sheets":
+		sheets, err := exporter.NewSheetsExporter(sheetsCredentials, sheetID, target, exporter.SheetsOptions{TokenFile: sheetsTokenFile})
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not create sheets exporter: %v", err)
+		}
+		return exporter.BatchSender(sheets), func() { sheets.Close() }, nil
+	case "webhook":
+		// queued webhook posts are already encoded in the webhook's format
+		return notify.NewWebhook(target, notify.WebhookOptions{}).Post, func() {}, nil
+	}
+	return nil, nil, fmt.Errorf("unknown export queue")
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"pinkbike-scraper/pkg/cache"
+	"pinkbike-scraper/pkg/geo"
+	"pinkbike-scraper/pkg/listing"
+)
+
+// geocoderUserAgent identifies the scraper to Nominatim, whose usage policy
+// asks for one
+const geocoderUserAgent = "pinkbike-scraper"
+
+const geocoderUsage = `Geocoder looking up seller locations and -near places, cached in the database: "nominatim" for OpenStreetMap, a CSV file of place,latitude,longitude rows, or "none"`
+
+// openGeocoder returns the geocoder name selects, answering from lookups
+// before asking it. It returns nil for "none".
+func openGeocoder(name string, lookups *cache.Cache) (geo.Geocoder, error) {
+	var geocoder geo.Geocoder
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "none", "":
+		return nil, nil
+	case "nominatim":
+		geocoder = geo.NewNominatim(geocoderUserAgent)
+	default:
+		places, err := geo.LoadPlaces(name)
+		if err != nil {
+			return nil, err
+		}
+		geocoder = places
+	}
+	return geo.Cached(geocoder, lookups), nil
+}
+
+// geocodeListings sets the coordinates of the listings with a location.
+// Locations the geocoder does not know, or that are not cached when it may
+// not look them up, are left without them.
+func geocodeListings(listings []listing.Listing, geocoder geo.Geocoder) error {
+	for i, l := range listings {
+		if l.Location == "" || !l.Coordinates.IsZero() {
+			continue
+		}
+		p, err := geocoder.Geocode(l.Location)
+		if errors.Is(err, geo.ErrNotFound) || errors.Is(err, cache.ErrOffline) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		listings[i].Coordinates = p
+	}
+	return nil
+}
+
+// nearFlags are the -near, -maxKm and -geocoder flags of commands that can
+// limit listings to those close to a place
+type nearFlags struct {
+	near     *string
+	maxKM    *float64
+	geocoder *string
+}
+
+func addNearFlags(fs *flag.FlagSet) nearFlags {
+	return nearFlags{
+		near:     fs.String("near", "", "Only listings within -maxKm of this place, such as \"Calgary, AB\" or 51.05,-114.07"),
+		maxKM:    fs.Float64("maxKm", 300, "How far from -near listings may be, in kilometres"),
+		geocoder: fs.String("geocoder", "nominatim", geocoderUsage),
+	}
+}
+
+// locate returns the position of -near, or the zero Point when it is not set
+func (f nearFlags) locate(store cache.Store) (geo.Point, error) {
+	if *f.near == "" {
+		return geo.Point{}, nil
+	}
+	if *f.maxKM <= 0 {
+		return geo.Point{}, fmt.Errorf("-maxKm must be positive")
+	}
+	geocoder, err := openGeocoder(*f.geocoder, &cache.Cache{Store: store})
+	if err != nil {
+		return geo.Point{}, err
+	}
+	near, err := geo.ParsePlace(*f.near, geocoder)
+	if err != nil {
+		return geo.Point{}, fmt.Errorf("could not locate -near: %v", err)
+	}
+	return near, nil
+}
+
+// filter returns whether a listing is close enough to -near, or nil when
+// -near is not set. Listings without a geocoded location are never close.
+func (f nearFlags) filter(store cache.Store) (func(l listing.Listing) bool, error) {
+	near, err := f.locate(store)
+	if err != nil || near.IsZero() {
+		return nil, err
+	}
+	return func(l listing.Listing) bool {
+		km, ok := l.DistanceKM(near)
+		return ok && km <= *f.maxKM
+	}, nil
+}
+
+// keepListings returns the listings keep returns true for
+func keepListings(listings []listing.Listing, keep func(l listing.Listing) bool) []listing.Listing {
+	var kept []listing.Listing
+	for _, l := range listings {
+		if keep(l) {
+			kept = append(kept, l)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/scraper"
+)
+
+// importCommands load listings from other sources, run as "pinkbike-scraper import <name> [flags]"
+var importCommands = map[string]command{
+	"csv": {
+		description: "Backfill the database from a directory of old run CSV files, dated by their file names",
+		run:         runImportCSV,
+	},
+	"sheets": {
+		description: "Sync year, manufacturer and model corrections made in the Google Sheets spreadsheet into the database",
+		run:         runImportSheets,
+	},
+}
+
+func runImport(args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := importCommands[args[0]]; ok {
+			return cmd.run(args[1:])
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, "Usage: pinkbike-scraper import <command> [flags]\n\nCommands:")
+	printCommandTable(os.Stderr, importCommands)
+	if len(args) == 0 {
+		return fmt.Errorf("missing import command")
+	}
+	return fmt.Errorf("unknown import command %q", args[0])
+}
+
+// runFile is a run CSV file with the day and bike type its name gives
+type runFile struct {
+	path     string
+	date     time.Time
+	category string
+}
+
+func runImportCSV(args []string) error {
+	fs := flag.NewFlagSet("import csv", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database to backfill")
+	pattern := fs.String("pattern", "*.csv", "Files of the directory to import")
+	category := fs.String("category", "", "Bike type of every imported listing (default taken from each file name, such as xcListings2024-09-18.csv)")
+	useModTime := fs.Bool("useModTime", false, "Date files whose names hold no date by their modification time instead of skipping them")
+	dryRun := fs.Bool("dryRun", false, "Only list the files and the dates and bike types they would be imported with")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: pinkbike-scraper import csv [flags] <directory>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("import csv needs a directory")
+	}
+
+	paths, err := filepath.Glob(filepath.Join(fs.Arg(0), *pattern))
+	if err != nil {
+		return fmt.Errorf("invalid -pattern: %v", err)
+	}
+	var files []runFile
+	for _, path := range paths {
+		date, ok := runFileDate(filepath.Base(path))
+		if !ok {
+			info, err := os.Stat(path)
+			if !*useModTime || err != nil {
+				fmt.Fprintf(os.Stderr, "Skipping %s: no date in its name (see -useModTime)\n", path)
+				continue
+			}
+			date = info.ModTime().UTC()
+		}
+		f := runFile{path: path, date: date, category: *category}
+		if f.category == "" {
+			f.category = runFileCategory(filepath.Base(path))
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no run files found in %s", fs.Arg(0))
+	}
+	// oldest first, so each listing's price history builds up in order
+	sort.SliceStable(files, func(i, j int) bool { return files[i].date.Before(files[j].date) })
+
+	if *dryRun {
+		for _, f := range files {
+			fmt.Printf("%s  %-8s %s\n", f.date.Format("2006-01-02"), f.category, f.path)
+		}
+		return nil
+	}
+
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, exporter.DefaultDBOptions())
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	aliases, err := dbExp.Aliases()
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	for _, f := range files {
+		listings, rowErrors, err := scraper.ReadListingsFile(f.path, clock.System)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping %s: %v\n", f.path, err)
+			continue
+		}
+		for _, rowErr := range rowErrors {
+			fmt.Fprintf(os.Stderr, "%s: skipping %v\n", f.path, rowErr)
+		}
+		for i, l := range listings {
+			l = l.ApplyAliases(aliases)
+			if l.Category == "" {
+				l.Category = f.category
+			}
+			listings[i] = l
+		}
+
+		imported, err := dbExp.ImportHistorical(listing.Dedupe(listings), f.date)
+		if err != nil {
+			return err
+		}
+		total += imported
+		fmt.Printf("%s: imported %d listings seen %s\n", f.path, imported, f.date.Format("2006-01-02"))
+	}
+
+	fmt.Printf("Imported %d listings from %d files\n", total, len(files))
+	return nil
+}
+
+func runImportSheets(args []string) error {
+	fs := flag.NewFlagSet("import sheets", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database to sync corrections into")
+	sheetID := fs.String("spreadsheetID", spreadsheetID, "The Google Sheets spreadsheet to read listings from")
+	credentials := fs.String("sheetsCredentials", "pinkbike-exporter-8bc8e681ffa1.json", "Google service account key or OAuth client secret used to read the spreadsheet")
+	tokenFile := fs.String("sheetsTokenFile", "token.json", "Where the OAuth token is cached when -sheetsCredentials is an OAuth client secret")
+	readRange := fs.String("range", "Enduro", "Tab or A1 range to read, starting at the header row, such as Enduro or 'Enduro Review'!A1:R200")
+	columns := fs.String("columns", "", "Headers of renamed columns, such as year=Model Year,model=Bike (default the layout the sheets export writes)")
+	yes := fs.Bool("yes", false, "Save every correction without asking")
+	dryRun := fs.Bool("dryRun", false, "Only list the corrections that would be saved")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	sheetsColumns, err := exporter.ParseSheetsColumns(*columns)
+	if err != nil {
+		return fmt.Errorf("invalid -columns: %v", err)
+	}
+	reader, err := exporter.NewSheetsReader(*credentials, *tokenFile, *sheetID)
+	if err != nil {
+		return err
+	}
+	listings, err := reader.ReadListings(*readRange, sheetsColumns)
+	if err != nil {
+		return err
+	}
+
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, exporter.DefaultDBOptions())
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	corrections, err := dbExp.SheetCorrections(listings)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Read %d listings from %s, %d with corrections\n", len(listings), *readRange, len(corrections))
+	if *dryRun {
+		for _, c := range corrections {
+			printSheetCorrection(os.Stdout, c)
+		}
+		return nil
+	}
+
+	run := exporter.Run{StartedAt: time.Now(), InputMode: "sheets", Listings: len(listings)}
+	if run.ID, err = dbExp.StartRun(run); err != nil {
+		fmt.Fprintf(os.Stderr, "could not record run: %v\n", err)
+	}
+	saved, err := syncSheetCorrections(dbExp, corrections, *yes, os.Stdin, os.Stdout)
+	if err != nil {
+		run.Errors = append(run.Errors, err.Error())
+	}
+	if run.ID != 0 {
+		run.FinishedAt = time.Now()
+		if finishErr := dbExp.FinishRun(run); finishErr != nil {
+			fmt.Fprintf(os.Stderr, "could not record run: %v\n", finishErr)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Saved %d corrections\n", saved)
+	return nil
+}
+
+// syncSheetCorrections saves the corrections reviewers made in the
+// spreadsheet as review corrections, asking before each one unless yes is set
+func syncSheetCorrections(dbExp *exporter.DBExporter, corrections []exporter.SheetCorrection, yes bool, in io.Reader, out io.Writer) (int, error) {
+	reader := bufio.NewReader(in)
+	saved := 0
+	for _, c := range corrections {
+		printSheetCorrection(out, c)
+		if !yes {
+			fmt.Fprint(out, "  Save correction? [Y/n/q]: ")
+			answer, err := reader.ReadString('\n')
+			if err != nil && (err != io.EOF || answer == "") {
+				return saved, err
+			}
+			switch strings.ToLower(strings.TrimSpace(answer)) {
+			case "n":
+				continue
+			case "q":
+				return saved, nil
+			}
+		}
+		corrected, err := dbExp.SaveCorrection(c.Stored, c.Correction)
+		if err != nil {
+			return saved, err
+		}
+		saved++
+		if len(corrected.NeedsReview) > 0 {
+			fmt.Fprintf(out, "  Saved, still needs review: %s\n", corrected.NeedsReview)
+		}
+	}
+	return saved, nil
+}
+
+func printSheetCorrection(out io.Writer, c exporter.SheetCorrection) {
+	fmt.Fprintf(out, "\n%s\n", c.Stored.URL)
+	fmt.Fprintf(out, "  Raw title:     %s\n", c.Stored.Title)
+	fmt.Fprintf(out, "  Stored:        year %q, manufacturer %q, model %q\n", c.Stored.Year, c.Stored.Manufacturer, c.Stored.Model)
+	fmt.Fprintf(out, "  In the sheet:  year %q, manufacturer %q, model %q\n", c.Correction.Year, c.Correction.Manufacturer, c.Correction.Model)
+}
+
+var runFileDates = []struct {
+	pattern *regexp.Regexp
+	layout  string
+}{
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}`), "2006-01-02"},
+	{regexp.MustCompile(`\d{4}_\d{2}_\d{2}`), "2006_01_02"},
+	{regexp.MustCompile(`\d{8}`), "20060102"},
+}
+
+// runFileDate returns the day a run file's name gives, as the csv export
+// names them, such as enduroListings2024-09-18.csv. Runs are dated noon UTC
+// as the time of day is unknown.
+func runFileDate(name string) (time.Time, bool) {
+	for _, d := range runFileDates {
+		for _, match := range d.pattern.FindAllString(name, -1) {
+			if date, err := time.Parse(d.layout, match); err == nil {
+				return date.Add(12 * time.Hour), true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// runFileCategory returns the bike type a run file's name starts or ends
+// with, such as xc for suspect_xcListings2024-09-18.csv, or "" when it names
+// none
+func runFileCategory(name string) string {
+	name = strings.TrimPrefix(strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name))), "suspect_")
+	names := scraper.BikeTypeNames()
+	// longest first, so dirtjump is not taken for dh or the like
+	sort.SliceStable(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	for _, bikeType := range names {
+		if strings.HasPrefix(name, bikeType) {
+			return bikeType
+		}
+	}
+	for _, bikeType := range names {
+		if strings.HasSuffix(name, bikeType) {
+			return bikeType
+		}
+	}
+	return ""
+}
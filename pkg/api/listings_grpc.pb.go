@@ -0,0 +1,219 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: listings.proto
+
+package api
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Listings_ListListings_FullMethodName      = "/pinkbike.v1.Listings/ListListings"
+	Listings_GetPriceHistory_FullMethodName   = "/pinkbike.v1.Listings/GetPriceHistory"
+	Listings_StreamNewListings_FullMethodName = "/pinkbike.v1.Listings/StreamNewListings"
+)
+
+// ListingsClient is the client API for Listings service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ListingsClient interface {
+	// ListListings returns stored listings matching the request, newest first
+	ListListings(ctx context.Context, in *ListListingsRequest, opts ...grpc.CallOption) (*ListListingsResponse, error)
+	// GetPriceHistory returns the prices a listing was asked at, oldest first
+	GetPriceHistory(ctx context.Context, in *GetPriceHistoryRequest, opts ...grpc.CallOption) (*GetPriceHistoryResponse, error)
+	// StreamNewListings sends listings matching the request as they are first
+	// stored, until the client cancels
+	StreamNewListings(ctx context.Context, in *StreamNewListingsRequest, opts ...grpc.CallOption) (Listings_StreamNewListingsClient, error)
+}
+
+type listingsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewListingsClient(cc grpc.ClientConnInterface) ListingsClient {
+	return &listingsClient{cc}
+}
+
+func (c *listingsClient) ListListings(ctx context.Context, in *ListListingsRequest, opts ...grpc.CallOption) (*ListListingsResponse, error) {
+	out := new(ListListingsResponse)
+	err := c.cc.Invoke(ctx, Listings_ListListings_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *listingsClient) GetPriceHistory(ctx context.Context, in *GetPriceHistoryRequest, opts ...grpc.CallOption) (*GetPriceHistoryResponse, error) {
+	out := new(GetPriceHistoryResponse)
+	err := c.cc.Invoke(ctx, Listings_GetPriceHistory_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *listingsClient) StreamNewListings(ctx context.Context, in *StreamNewListingsRequest, opts ...grpc.CallOption) (Listings_StreamNewListingsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Listings_ServiceDesc.Streams[0], Listings_StreamNewListings_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &listingsStreamNewListingsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Listings_StreamNewListingsClient interface {
+	Recv() (*Listing, error)
+	grpc.ClientStream
+}
+
+type listingsStreamNewListingsClient struct {
+	grpc.ClientStream
+}
+
+func (x *listingsStreamNewListingsClient) Recv() (*Listing, error) {
+	m := new(Listing)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ListingsServer is the server API for Listings service.
+// All implementations must embed UnimplementedListingsServer
+// for forward compatibility
+type ListingsServer interface {
+	// ListListings returns stored listings matching the request, newest first
+	ListListings(context.Context, *ListListingsRequest) (*ListListingsResponse, error)
+	// GetPriceHistory returns the prices a listing was asked at, oldest first
+	GetPriceHistory(context.Context, *GetPriceHistoryRequest) (*GetPriceHistoryResponse, error)
+	// StreamNewListings sends listings matching the request as they are first
+	// stored, until the client cancels
+	StreamNewListings(*StreamNewListingsRequest, Listings_StreamNewListingsServer) error
+	mustEmbedUnimplementedListingsServer()
+}
+
+// UnimplementedListingsServer must be embedded to have forward compatible implementations.
+type UnimplementedListingsServer struct {
+}
+
+func (UnimplementedListingsServer) ListListings(context.Context, *ListListingsRequest) (*ListListingsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListListings not implemented")
+}
+func (UnimplementedListingsServer) GetPriceHistory(context.Context, *GetPriceHistoryRequest) (*GetPriceHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPriceHistory not implemented")
+}
+func (UnimplementedListingsServer) StreamNewListings(*StreamNewListingsRequest, Listings_StreamNewListingsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamNewListings not implemented")
+}
+func (UnimplementedListingsServer) mustEmbedUnimplementedListingsServer() {}
+
+// UnsafeListingsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ListingsServer will
+// result in compilation errors.
+type UnsafeListingsServer interface {
+	mustEmbedUnimplementedListingsServer()
+}
+
+func RegisterListingsServer(s grpc.ServiceRegistrar, srv ListingsServer) {
+	s.RegisterService(&Listings_ServiceDesc, srv)
+}
+
+func _Listings_ListListings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListListingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ListingsServer).ListListings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Listings_ListListings_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ListingsServer).ListListings(ctx, req.(*ListListingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Listings_GetPriceHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPriceHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ListingsServer).GetPriceHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Listings_GetPriceHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ListingsServer).GetPriceHistory(ctx, req.(*GetPriceHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Listings_StreamNewListings_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamNewListingsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ListingsServer).StreamNewListings(m, &listingsStreamNewListingsServer{stream})
+}
+
+type Listings_StreamNewListingsServer interface {
+	Send(*Listing) error
+	grpc.ServerStream
+}
+
+type listingsStreamNewListingsServer struct {
+	grpc.ServerStream
+}
+
+func (x *listingsStreamNewListingsServer) Send(m *Listing) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Listings_ServiceDesc is the grpc.ServiceDesc for Listings service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Listings_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pinkbike.v1.Listings",
+	HandlerType: (*ListingsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListListings",
+			Handler:    _Listings_ListListings_Handler,
+		},
+		{
+			MethodName: "GetPriceHistory",
+			Handler:    _Listings_GetPriceHistory_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamNewListings",
+			Handler:       _Listings_StreamNewListings_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "listings.proto",
+}
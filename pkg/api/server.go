@@ -0,0 +1,181 @@
+// Package api serves the listing store over gRPC, for services that would
+// rather not poll the JSON API. The service is defined in listings.proto.
+package api
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative listings.proto
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/listing"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// Store is the listing store the API serves, implemented by
+// *exporter.DBExporter
+type Store interface {
+	QueryListings(q exporter.ListingQuery) ([]listing.Listing, error)
+	PriceHistory(hash string) ([]exporter.PriceRange, error)
+	LastListingID() (int64, error)
+	ListingsAfter(id int64, limit int) ([]listing.Listing, int64, error)
+}
+
+// Server implements the Listings service over a Store
+type Server struct {
+	UnimplementedListingsServer
+	store Store
+	// PollInterval is how often StreamNewListings checks the store for new
+	// listings. Scrapes run in another process, so they cannot notify it.
+	PollInterval time.Duration
+}
+
+// NewServer returns a Server polling store for new listings every 10 seconds
+func NewServer(store Store) *Server {
+	return &Server{store: store, PollInterval: 10 * time.Second}
+}
+
+func (s *Server) ListListings(_ context.Context, req *ListListingsRequest) (*ListListingsResponse, error) {
+	limit := int(req.GetLimit())
+	switch {
+	case limit < 0:
+		return nil, status.Error(codes.InvalidArgument, "limit must not be negative")
+	case limit == 0:
+		limit = defaultLimit
+	case limit > maxLimit:
+		limit = maxLimit
+	}
+
+	listings, err := s.store.QueryListings(exporter.ListingQuery{
+		Manufacturer: req.GetManufacturer(),
+		Model:        req.GetModel(),
+		Category:     req.GetCategory(),
+		ActiveOnly:   req.GetActiveOnly(),
+		Limit:        limit,
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "could not load listings: %v", err)
+	}
+	resp := &ListListingsResponse{Listings: make([]*Listing, len(listings))}
+	for i, l := range listings {
+		resp.Listings[i] = toProto(l)
+	}
+	return resp, nil
+}
+
+func (s *Server) GetPriceHistory(_ context.Context, req *GetPriceHistoryRequest) (*GetPriceHistoryResponse, error) {
+	if req.GetHash() == "" {
+		return nil, status.Error(codes.InvalidArgument, "hash is required")
+	}
+	history, err := s.store.PriceHistory(req.GetHash())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "could not load price history: %v", err)
+	}
+	if len(history) == 0 {
+		return nil, status.Errorf(codes.NotFound, "no price history for listing %s", req.GetHash())
+	}
+
+	resp := &GetPriceHistoryResponse{Prices: make([]*PriceRange, len(history))}
+	for i, r := range history {
+		resp.Prices[i] = &PriceRange{
+			Price:    parsePrice(r.Price),
+			Currency: r.Currency,
+			From:     timestamppb.New(r.From),
+			To:       timestamppb.New(r.To),
+		}
+	}
+	return resp, nil
+}
+
+// StreamNewListings sends the matching listings stored after the stream was
+// opened, oldest first
+func (s *Server) StreamNewListings(req *StreamNewListingsRequest, stream Listings_StreamNewListingsServer) error {
+	cursor, err := s.store.LastListingID()
+	if err != nil {
+		return status.Errorf(codes.Internal, "could not start stream: %v", err)
+	}
+
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		for {
+			listings, next, err := s.store.ListingsAfter(cursor, defaultLimit)
+			if err != nil {
+				return status.Errorf(codes.Internal, "could not load new listings: %v", err)
+			}
+			cursor = next
+			for _, l := range listings {
+				if !matches(req, l) {
+					continue
+				}
+				if err := stream.Send(toProto(l)); err != nil {
+					return err
+				}
+			}
+			if len(listings) < defaultLimit {
+				break
+			}
+		}
+	}
+}
+
+func matches(req *StreamNewListingsRequest, l listing.Listing) bool {
+	for _, f := range []struct{ want, got string }{
+		{req.GetManufacturer(), l.Manufacturer},
+		{req.GetModel(), l.Model},
+		{req.GetCategory(), l.Category},
+	} {
+		if f.want != "" && !strings.EqualFold(f.want, f.got) {
+			return false
+		}
+	}
+	return true
+}
+
+func toProto(l listing.Listing) *Listing {
+	return &Listing{
+		Hash:           l.Hash,
+		Title:          l.Title,
+		Year:           l.Year,
+		Manufacturer:   l.Manufacturer,
+		Model:          l.Model,
+		Price:          parsePrice(l.Price),
+		Currency:       l.Currency,
+		Condition:      l.Condition,
+		FrameSize:      l.FrameSize,
+		NormalizedSize: l.NormalizedSize,
+		WheelSize:      l.WheelSize,
+		FrontTravel:    l.FrontTravel,
+		RearTravel:     l.RearTravel,
+		FrameMaterial:  l.FrameMaterial,
+		Category:       l.Category,
+		Url:            l.URL,
+		Active:         l.Active,
+		Electric:       l.IsElectric,
+		FirstSeen:      timestamppb.New(l.FirstSeen),
+		LastSeen:       timestamppb.New(l.LastSeen),
+	}
+}
+
+// parsePrice reads a stored USD price, which is zero when it is missing
+func parsePrice(s string) float64 {
+	price, _ := strconv.ParseFloat(s, 64)
+	return price
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/listing"
+)
+
+type fakeStore struct {
+	mu       sync.Mutex
+	listings []listing.Listing
+	history  map[string][]exporter.PriceRange
+	query    exporter.ListingQuery
+	// started is closed when a stream takes its starting cursor
+	started chan struct{}
+}
+
+func (s *fakeStore) QueryListings(q exporter.ListingQuery) ([]listing.Listing, error) {
+	s.query = q
+	return s.listings, nil
+}
+
+func (s *fakeStore) PriceHistory(hash string) ([]exporter.PriceRange, error) {
+	return s.history[hash], nil
+}
+
+func (s *fakeStore) LastListingID() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started != nil {
+		close(s.started)
+	}
+	return int64(len(s.listings)), nil
+}
+
+func (s *fakeStore) ListingsAfter(id int64, limit int) ([]listing.Listing, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if int(id) >= len(s.listings) {
+		return nil, id, nil
+	}
+	return s.listings[id:], int64(len(s.listings)), nil
+}
+
+func (s *fakeStore) add(l listing.Listing) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listings = append(s.listings, l)
+}
+
+func newTestClient(t *testing.T, store Store) ListingsClient {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	api := NewServer(store)
+	api.PollInterval = 10 * time.Millisecond
+	RegisterListingsServer(srv, api)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return NewListingsClient(conn)
+}
+
+func TestListListings(t *testing.T) {
+	seen := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	store := &fakeStore{listings: []listing.Listing{{
+		Hash: "abc", Title: "2022 Santa Cruz Megatower", Manufacturer: "Santa Cruz", Model: "Megatower",
+		Price: "4500", Currency: "CAD", Active: true, FirstSeen: seen,
+	}}}
+	client := newTestClient(t, store)
+
+	resp, err := client.ListListings(context.Background(), &ListListingsRequest{Manufacturer: "santa cruz", ActiveOnly: true})
+	require.NoError(t, err)
+	require.Len(t, resp.Listings, 1)
+	assert.Equal(t, "abc", resp.Listings[0].Hash)
+	assert.Equal(t, 4500.0, resp.Listings[0].Price)
+	assert.Equal(t, "CAD", resp.Listings[0].Currency)
+	assert.True(t, resp.Listings[0].FirstSeen.AsTime().Equal(seen))
+	assert.Equal(t, exporter.ListingQuery{Manufacturer: "santa cruz", ActiveOnly: true, Limit: defaultLimit}, store.query)
+
+	_, err = client.ListListings(context.Background(), &ListListingsRequest{Limit: 5000})
+	require.NoError(t, err)
+	assert.Equal(t, maxLimit, store.query.Limit)
+
+	_, err = client.ListListings(context.Background(), &ListListingsRequest{Limit: -1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGetPriceHistory(t *testing.T) {
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	store := &fakeStore{history: map[string][]exporter.PriceRange{
+		"abc": {{Price: "4500", Currency: "USD", From: from, To: from.AddDate(0, 0, 7)}},
+	}}
+	client := newTestClient(t, store)
+
+	resp, err := client.GetPriceHistory(context.Background(), &GetPriceHistoryRequest{Hash: "abc"})
+	require.NoError(t, err)
+	require.Len(t, resp.Prices, 1)
+	assert.Equal(t, 4500.0, resp.Prices[0].Price)
+	assert.True(t, resp.Prices[0].To.AsTime().Equal(from.AddDate(0, 0, 7)))
+
+	_, err = client.GetPriceHistory(context.Background(), &GetPriceHistoryRequest{Hash: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.GetPriceHistory(context.Background(), &GetPriceHistoryRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestStreamNewListings(t *testing.T) {
+	store := &fakeStore{listings: []listing.Listing{{Hash: "old", Manufacturer: "Santa Cruz"}}, started: make(chan struct{})}
+	client := newTestClient(t, store)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.StreamNewListings(ctx, &StreamNewListingsRequest{Manufacturer: "santa cruz"})
+	require.NoError(t, err)
+
+	// the stream starts after the listings stored when it was opened
+	<-store.started
+	store.add(listing.Listing{Hash: "other", Manufacturer: "Yeti"})
+	store.add(listing.Listing{Hash: "new", Manufacturer: "Santa Cruz"})
+
+	l, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "new", l.Hash)
+}
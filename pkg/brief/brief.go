@@ -0,0 +1,240 @@
+package brief
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"pinkbike-scraper/pkg/events"
+	"pinkbike-scraper/pkg/fraud"
+	"pinkbike-scraper/pkg/listing"
+)
+
+const (
+	// TopN is how many deals and price drops the brief lists
+	TopN = 5
+	// minComparables is how many priced listings of a model a median needs
+	// before listings are scored against it
+	minComparables = 3
+)
+
+// MedianFunc returns the median price of a manufacturer's model and how many
+// listings it was computed from, of only the given generation of the model
+// unless generation is empty
+type MedianFunc func(manufacturer, model, generation string) (float64, int, error)
+
+// RetailFunc returns a listing's retail price in USD, ok false when unknown
+type RetailFunc func(l listing.Listing) (price float64, ok bool)
+
+// Deal is a listing priced below the median of its model
+type Deal struct {
+	Listing listing.Listing
+	Median  float64
+	// Score is the fraction below the median, 0.2 is 20% under
+	Score float64
+	// Comps is how many listings the median was computed from, and Year the
+	// model year they share, empty when they span every year
+	Comps int
+	Year  string
+	// Retail is the listing's retail price, zero when unknown, and OffRetail
+	// the fraction below it the listing is priced
+	Retail    float64
+	OffRetail float64
+	// Predicted marks deals scored against the price model's prediction for
+	// the listing, which Median then holds, instead of its model's median
+	Predicted bool
+}
+
+// Drop is a price decrease seen during the run
+type Drop struct {
+	Listing  listing.Listing
+	OldPrice float64
+	NewPrice float64
+}
+
+func (d Drop) fraction() float64 {
+	return (d.OldPrice - d.NewPrice) / d.OldPrice
+}
+
+// FavoriteUpdate is how a favorite listing changed during the run
+type FavoriteUpdate struct {
+	Title, URL string
+	// Changes describe each changed field, such as "price 4200 -> 3900"
+	Changes []string
+}
+
+// Brief summarizes a run for the terminal
+type Brief struct {
+	Categories []string
+	Listings   int
+	New        int
+	Deals      []Deal
+	Drops      []Drop
+	// Favorites are the favorites that changed during the run, set by the
+	// caller since favorites are kept in the database
+	Favorites []FavoriteUpdate
+}
+
+// Collector builds a brief from the events published during a run
+type Collector struct {
+	categories []string
+	new        int
+	drops      []Drop
+	retail     RetailFunc
+}
+
+func NewCollector(categories ...string) *Collector {
+	return &Collector{categories: categories}
+}
+
+// SetRetail makes the brief compare deals against their retail price
+func (c *Collector) SetRetail(retail RetailFunc) {
+	c.retail = retail
+}
+
+// Subscribe counts new listings and records price drops from the bus
+func (c *Collector) Subscribe(bus *events.Bus) {
+	bus.Subscribe("brief", c.Handle, events.ListingDiscovered, events.PriceChanged)
+}
+
+func (c *Collector) Handle(e events.Event) error {
+	switch e.Kind {
+	case events.ListingDiscovered:
+		c.new++
+	case events.PriceChanged:
+		oldPrice, err1 := strconv.ParseFloat(e.OldPrice, 64)
+		newPrice, err2 := strconv.ParseFloat(e.Listing.Price, 64)
+		if err1 == nil && err2 == nil && oldPrice > 0 && newPrice < oldPrice {
+			c.drops = append(c.drops, Drop{Listing: e.Listing, OldPrice: oldPrice, NewPrice: newPrice})
+		}
+	}
+	return nil
+}
+
+// Brief scores the run's listings against their model medians and returns the
+// best deals and biggest drops
+func (c *Collector) Brief(listings []listing.Listing, medians MedianFunc) Brief {
+	drops := append([]Drop(nil), c.drops...)
+	sort.SliceStable(drops, func(i, j int) bool { return drops[i].fraction() > drops[j].fraction() })
+	if len(drops) > TopN {
+		drops = drops[:TopN]
+	}
+
+	deals := Deals(listings, medians, TopN)
+	if c.retail != nil {
+		for i, d := range deals {
+			deals[i].Retail, deals[i].OffRetail = offRetail(d.Listing, c.retail)
+		}
+	}
+
+	return Brief{
+		Categories: c.categories,
+		Listings:   len(listings),
+		New:        c.new,
+		Deals:      deals,
+		Drops:      drops,
+	}
+}
+
+// offRetail returns a listing's retail price and the fraction below it the
+// listing is priced, both zero when either price is unknown
+func offRetail(l listing.Listing, retail RetailFunc) (float64, float64) {
+	r, ok := retail(l)
+	price, err := strconv.ParseFloat(l.Price, 64)
+	if !ok || r <= 0 || err != nil {
+		return 0, 0
+	}
+	return r, (r - price) / r
+}
+
+// Deals returns up to n listings priced furthest below their model's median.
+// Listings that need review or whose model has too few prices are skipped.
+func Deals(listings []listing.Listing, medians MedianFunc, n int) []Deal {
+	medians = cached(medians)
+
+	var deals []Deal
+	for _, l := range listings {
+		if d, ok := Rate(l, medians); ok && d.Score > 0 {
+			deals = append(deals, d)
+		}
+	}
+
+	sort.SliceStable(deals, func(i, j int) bool { return deals[i].Score > deals[j].Score })
+	if len(deals) > n {
+		deals = deals[:n]
+	}
+	return deals
+}
+
+// Rate scores a listing against the median of its model and generation, or against the price
+// model's prediction when one was made. The score is negative for listings
+// priced above it. ok is false when the listing needs review, is a suspected
+// scam, is a frame or parts rather than a complete bike, has no price or its
+// model has too few prices to compare against.
+func Rate(l listing.Listing, medians MedianFunc) (Deal, bool) {
+	if len(l.NeedsReview) > 0 || l.Manufacturer == "" || l.Model == "" || !l.Kind.IsBike() || l.FraudScore >= fraud.Threshold {
+		return Deal{}, false
+	}
+	price, err := strconv.ParseFloat(l.Price, 64)
+	if err != nil || price <= 0 {
+		return Deal{}, false
+	}
+	if predicted := l.PredictedPrice; predicted > 0 {
+		return Deal{Listing: l, Median: predicted, Score: (predicted - price) / predicted, Predicted: true}, true
+	}
+
+	med, count, err := medians(l.Manufacturer, l.Model, l.Generation)
+	if err != nil || count < minComparables || med <= 0 {
+		return Deal{}, false
+	}
+	return Deal{Listing: l, Median: med, Score: (med - price) / med, Comps: count}, true
+}
+
+// cached remembers the median of each model so it is looked up once per brief
+func cached(medians MedianFunc) MedianFunc {
+	type key struct{ manufacturer, model, generation string }
+	type result struct {
+		median float64
+		count  int
+		err    error
+	}
+	cache := map[key]result{}
+
+	return func(manufacturer, model, generation string) (float64, int, error) {
+		k := key{manufacturer, model, generation}
+		r, ok := cache[k]
+		if !ok {
+			r.median, r.count, r.err = medians(manufacturer, model, generation)
+			cache[k] = r
+		}
+		return r.median, r.count, r.err
+	}
+}
+
+// Write prints the brief in a compact, human-readable form
+func (b Brief) Write(w io.Writer) {
+	fmt.Fprintf(w, "\n=== Market brief: %s ===\n", strings.Join(b.Categories, ", "))
+	fmt.Fprintf(w, "%d listings scraped, %d new\n", b.Listings, b.New)
+
+	if len(b.Favorites) > 0 {
+		fmt.Fprintln(w, "\nFavorites:")
+		for _, f := range b.Favorites {
+			fmt.Fprintf(w, "  %s - %s\n     %s\n", f.Title, strings.Join(f.Changes, ", "), f.URL)
+		}
+	}
+
+	if len(b.Deals) > 0 {
+		fmt.Fprintln(w, "\nBest deals:")
+		WriteDeals(w, b.Deals)
+	}
+
+	if len(b.Drops) > 0 {
+		fmt.Fprintln(w, "\nBiggest price drops:")
+		for i, d := range b.Drops {
+			fmt.Fprintf(w, "  %d. %s - $%.0f -> $%.0f (-%.0f%%)\n     %s\n",
+				i+1, d.Listing.Title, d.OldPrice, d.NewPrice, d.fraction()*100, d.Listing.URL)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package brief
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pinkbike-scraper/pkg/events"
+	"pinkbike-scraper/pkg/listing"
+)
+
+func TestBrief(t *testing.T) {
+	medians := func(manufacturer, model, generation string) (float64, int, error) {
+		switch model {
+		case "Megatower":
+			return 4000, 10, nil
+		case "Bronson":
+			return 3000, 2, nil
+		}
+		return 0, 0, nil
+	}
+
+	listings := []listing.Listing{
+		{Title: "2021 Santa Cruz Megatower", Manufacturer: "Santa Cruz", Model: "Megatower", Price: "3000"},
+		{Title: "2022 Santa Cruz Megatower", Manufacturer: "Santa Cruz", Model: "Megatower", Price: "3800"},
+		{Title: "2020 Santa Cruz Megatower", Manufacturer: "Santa Cruz", Model: "Megatower", Price: "4500"},
+		{Title: "Santa Cruz Megatower", Manufacturer: "Santa Cruz", Model: "Megatower", Price: "100", NeedsReview: listing.Reasons{"missing year"}},
+		{Title: "2021 Santa Cruz Bronson", Manufacturer: "Santa Cruz", Model: "Bronson", Price: "1000"},
+	}
+
+	c := NewCollector("enduro")
+	bus := events.NewBus()
+	c.Subscribe(bus)
+	bus.Publish(events.Event{Kind: events.ListingDiscovered, Listing: listings[0]})
+	bus.Publish(events.Event{Kind: events.PriceChanged, Listing: listings[1], OldPrice: "4000"})
+	bus.Publish(events.Event{Kind: events.PriceChanged, Listing: listings[2], OldPrice: "4000"})
+
+	b := c.Brief(listings, medians)
+	assert.Equal(t, 5, b.Listings)
+	assert.Equal(t, 1, b.New)
+
+	// the flagged listing and the model with too few prices are not scored
+	if assert.Len(t, b.Deals, 2) {
+		assert.Equal(t, listings[0].Title, b.Deals[0].Listing.Title)
+		assert.InDelta(t, 0.25, b.Deals[0].Score, 0.001)
+		assert.Equal(t, listings[1].Title, b.Deals[1].Listing.Title)
+	}
+
+	// price increases are not drops
+	if assert.Len(t, b.Drops, 1) {
+		assert.Equal(t, 3800.0, b.Drops[0].NewPrice)
+	}
+
+	b.Favorites = []FavoriteUpdate{{Title: "2019 Transition Sentinel", URL: "https://www.pinkbike.com/buysell/3861316/",
+		Changes: []string{"price 3500 -> 3200", "status active -> sold"}}}
+
+	var out bytes.Buffer
+	b.Write(&out)
+	assert.Contains(t, out.String(), "Market brief: enduro")
+	assert.Contains(t, out.String(), "Favorites:\n  2019 Transition Sentinel - price 3500 -> 3200, status active -> sold\n")
+	assert.Contains(t, out.String(), "25% under $4000 median")
+	assert.Contains(t, out.String(), "$4000 -> $3800 (-5%)")
+}
+
+func TestBriefRetail(t *testing.T) {
+	medians := func(manufacturer, model, generation string) (float64, int, error) { return 4000, 10, nil }
+	listings := []listing.Listing{
+		{Title: "2021 Santa Cruz Megatower", Manufacturer: "Santa Cruz", Model: "Megatower", Price: "3000"},
+		{Title: "2022 Santa Cruz Megatower", Manufacturer: "Santa Cruz", Model: "Megatower", Price: "3800"},
+	}
+
+	c := NewCollector("enduro")
+	c.SetRetail(func(l listing.Listing) (float64, bool) {
+		return 7500, l.Price == "3000"
+	})
+	b := c.Brief(listings, medians)
+	require.Len(t, b.Deals, 2)
+	assert.Equal(t, 7500.0, b.Deals[0].Retail)
+	assert.InDelta(t, 0.6, b.Deals[0].OffRetail, 0.001)
+	assert.Zero(t, b.Deals[1].Retail)
+
+	var out bytes.Buffer
+	b.Write(&out)
+	assert.Contains(t, out.String(), "25% under $4000 median, 60% off $7500 retail)")
+	assert.Contains(t, out.String(), "5% under $4000 median)")
+}
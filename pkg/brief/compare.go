@@ -0,0 +1,220 @@
+package brief
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/parser"
+)
+
+// ComparisonRow is one field of the compared listings, with a value for each
+type ComparisonRow struct {
+	Field  string
+	Values []string
+	// Differs marks fields the listings do not all share
+	Differs bool
+}
+
+// Compare lays candidates out field by field, for deciding between them. Each
+// is priced against the median of its model and year among listings, as
+// RankDeals does, and how long it has been listed is counted up to now.
+func Compare(candidates, listings []listing.Listing, now time.Time) []ComparisonRow {
+	market := newMarket(listings)
+
+	fields := []struct {
+		name  string
+		value func(l listing.Listing) string
+	}{
+		{"Title", func(l listing.Listing) string { return l.Title }},
+		{"Price", comparePrice},
+		{"Vs market", func(l listing.Listing) string {
+			d, ok := market.rate(l)
+			if !ok {
+				return "no comparables"
+			}
+			return marketPosition(d)
+		}},
+		{"Year", func(l listing.Listing) string { return l.Year }},
+		{"Manufacturer", func(l listing.Listing) string { return l.Manufacturer }},
+		{"Model", func(l listing.Listing) string { return l.Model }},
+		{"Generation", func(l listing.Listing) string { return l.Generation }},
+		{"Build", func(l listing.Listing) string { return describeBuild(l.BuildTier) }},
+		{"Category", func(l listing.Listing) string { return l.Category }},
+		{"Frame size", func(l listing.Listing) string {
+			if l.NormalizedSize != "" && !strings.EqualFold(l.NormalizedSize, l.FrameSize) {
+				return fmt.Sprintf("%s (%s)", l.FrameSize, l.NormalizedSize)
+			}
+			return l.FrameSize
+		}},
+		{"Wheel size", func(l listing.Listing) string { return l.WheelSize }},
+		{"Material", func(l listing.Listing) string { return l.FrameMaterial }},
+		{"Front travel", func(l listing.Listing) string { return l.FrontTravel }},
+		{"Rear travel", func(l listing.Listing) string { return l.RearTravel }},
+		{"Electric", func(l listing.Listing) string { return yesNo(l.IsElectric) }},
+		{"Condition", func(l listing.Listing) string { return l.Condition }},
+		{"Usage", func(l listing.Listing) string { return describeUsage(l) }},
+		{"Retail", func(l listing.Listing) string {
+			p := l.Details.OriginalPrice
+			if p.Amount <= 0 {
+				return ""
+			}
+			return strings.TrimSpace(fmt.Sprintf("$%s %s", p.Listed(), p.Currency))
+		}},
+		{"Status", func(l listing.Listing) string {
+			if l.Active {
+				return "active"
+			}
+			return "sold or removed"
+		}},
+		{"Listed", func(l listing.Listing) string { return listedFor(l, now) }},
+		{"Seller", func(l listing.Listing) string { return l.Details.Seller }},
+		{"Seller type", func(l listing.Listing) string { return string(l.Details.SellerType) }},
+		{"Views", func(l listing.Listing) string { return count(l.Details.ViewCount) }},
+		{"Photos", func(l listing.Listing) string { return count(l.Details.PhotoCount) }},
+		{"URL", func(l listing.Listing) string { return l.URL }},
+	}
+
+	rows := make([]ComparisonRow, 0, len(fields))
+	for _, f := range fields {
+		row := ComparisonRow{Field: f.name}
+		for _, l := range candidates {
+			v := f.value(l)
+			if v == "" {
+				v = "-"
+			}
+			if len(row.Values) > 0 && v != row.Values[0] {
+				row.Differs = true
+			}
+			row.Values = append(row.Values, v)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// WriteComparison prints rows as columns, one per listing, marking the fields
+// the listings differ in with an asterisk
+func WriteComparison(w io.Writer, rows []ComparisonRow) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	for _, r := range rows {
+		marker := " "
+		if r.Differs {
+			marker = "*"
+		}
+		fmt.Fprintf(tw, "%s %s\t%s\n", marker, r.Field, strings.Join(r.Values, "\t"))
+	}
+	return tw.Flush()
+}
+
+// comparePrice is the USD price, with the price it was listed at when the
+// seller listed it in another currency
+func comparePrice(l listing.Listing) string {
+	if l.Price == "" {
+		return ""
+	}
+	p := "$" + l.Price
+	if l.ListedPrice != "" && l.Currency != "" && l.Currency != "USD" {
+		p += fmt.Sprintf(" (%s %s)", l.ListedPrice, l.Currency)
+	}
+	if l.Negotiable {
+		p += " OBO"
+	}
+	return p
+}
+
+// marketPosition describes how far over or under its median a listing is
+func marketPosition(d Deal) string {
+	basis := "median"
+	switch {
+	case d.Predicted:
+		basis = "predicted"
+	case d.Year != "":
+		basis = d.Year + " median"
+	}
+	if d.Comps > 0 {
+		basis += fmt.Sprintf(" of %d", d.Comps)
+	}
+
+	percent := math.Round(math.Abs(d.Score) * 100)
+	switch {
+	case percent == 0:
+		return fmt.Sprintf("at $%.0f %s", d.Median, basis)
+	case d.Score > 0:
+		return fmt.Sprintf("%.0f%% under $%.0f %s", percent, d.Median, basis)
+	default:
+		return fmt.Sprintf("%.0f%% over $%.0f %s", percent, d.Median, basis)
+	}
+}
+
+// describeBuild is the build kit with its rank, such as "GX (mid)"
+func describeBuild(t parser.BuildTier) string {
+	if t.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%s (%s)", t.Kit, t.Rank)
+}
+
+// describeUsage is how far and how long the bike was ridden, as its
+// description tells it
+func describeUsage(l listing.Listing) string {
+	u := l.Details.Usage
+	if u.Confidence <= 0 {
+		return ""
+	}
+	var parts []string
+	switch {
+	case u.EstimatedKM > 0:
+		parts = append(parts, fmt.Sprintf("~%d km", u.EstimatedKM))
+	case u.SeasonsUsed == 0:
+		parts = append(parts, "unridden")
+	}
+	if u.SeasonsUsed > 0 {
+		seasons := strconv.FormatFloat(u.SeasonsUsed, 'f', -1, 64) + " seasons"
+		if u.SeasonsUsed == 1 {
+			seasons = "1 season"
+		}
+		parts = append(parts, seasons)
+	}
+	if u.NeverRaced {
+		parts = append(parts, "never raced")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// listedFor is when the listing was posted, or first seen when its post date
+// is unknown, and how many days ago that was
+func listedFor(l listing.Listing, now time.Time) string {
+	since := l.Details.OriginalPostDate
+	if since.IsZero() {
+		since = l.FirstSeen
+	}
+	if since.IsZero() {
+		return ""
+	}
+	end := now
+	if !l.Active && !l.LastSeen.IsZero() {
+		end = l.LastSeen
+	}
+	days := int(end.Sub(since).Hours() / 24)
+	return fmt.Sprintf("%s (%d days)", since.Format("2006-01-02"), days)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func count(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
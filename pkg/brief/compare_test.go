@@ -0,0 +1,58 @@
+package brief
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/parser"
+)
+
+func TestCompare(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	bike := func(title, year, price string) listing.Listing {
+		return listing.Listing{Title: title, Manufacturer: "Santa Cruz", Model: "Megatower", Year: year, Price: price,
+			FrameSize: "L", Condition: "Good - Used, Mechanically Sound", Active: true, FirstSeen: now.AddDate(0, 0, -10)}
+	}
+	listings := []listing.Listing{
+		bike("2022 Megatower A", "2022", "5000"),
+		bike("2022 Megatower B", "2022", "5200"),
+		bike("2022 Megatower C", "2022", "4000"),
+		bike("2019 Megatower", "2019", "3000"),
+	}
+
+	a, b := listings[2], listings[3]
+	a.ListedPrice, a.Currency, a.Negotiable = "5500", "CAD", true
+	a.Details = listing.ListingDetails{Seller: "shredder", SellerType: listing.Private, ViewCount: 120,
+		Usage: parser.Usage{EstimatedKM: 300, SeasonsUsed: 1, Confidence: 0.8}}
+	b.Active, b.LastSeen = false, now.AddDate(0, 0, -4)
+	b.Details.OriginalPostDate = now.AddDate(0, 0, -30)
+
+	rows := Compare([]listing.Listing{a, b}, listings, now)
+	byField := map[string]ComparisonRow{}
+	for _, r := range rows {
+		require.Len(t, r.Values, 2, r.Field)
+		byField[r.Field] = r
+	}
+
+	assert.Equal(t, []string{"$4000 (5500 CAD) OBO", "$3000"}, byField["Price"].Values)
+	assert.Equal(t, "20% under $5000 2022 median of 3", byField["Vs market"].Values[0])
+	assert.Equal(t, "33% under $4500 median of 4", byField["Vs market"].Values[1], "a year with too few prices is compared across every year")
+	assert.Equal(t, []string{"~300 km, 1 season", "-"}, byField["Usage"].Values)
+	assert.Equal(t, []string{"2024-05-22 (10 days)", "2024-05-02 (26 days)"}, byField["Listed"].Values, "sold listings count up to when they were last seen")
+	assert.Equal(t, []string{"shredder", "-"}, byField["Seller"].Values)
+	assert.True(t, byField["Year"].Differs)
+	assert.False(t, byField["Model"].Differs)
+	assert.False(t, byField["Condition"].Differs)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteComparison(&buf, rows))
+	lines := strings.Split(buf.String(), "\n")
+	assert.Regexp(t, `^\* Title +2022 Megatower C +2019 Megatower$`, lines[0])
+	assert.Regexp(t, `^  Model +Megatower +Megatower$`, lines[5])
+}
@@ -0,0 +1,193 @@
+package brief
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"pinkbike-scraper/pkg/fraud"
+	"pinkbike-scraper/pkg/geo"
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/parser"
+)
+
+// DealFilter narrows the listings RankDeals considers. Empty fields match
+// anything.
+type DealFilter struct {
+	Category     string
+	Manufacturer string
+	// Size is a frame size such as "L" or "19.5", compared on the canonical
+	// scale when it can be normalized
+	Size string
+	// Wheels is a wheel size such as "29" or "27.5", or "mullet"
+	Wheels string
+	// Currency is the currency listings were priced in, which tells Canadian
+	// (CAD) and US (USD) sellers apart
+	Currency string
+	// MinScore is the fraction under the median a deal needs, 0.1 for 10%
+	MinScore float64
+	// Near limits deals to sellers at most MaxKM from it, when not zero.
+	// Listings without a geocoded location are left out.
+	Near  geo.Point
+	MaxKM float64
+	// ConditionPhotos leaves out listings with fewer photos than
+	// listing.MinConditionPhotos, too few to judge their condition
+	ConditionPhotos bool
+}
+
+func (f DealFilter) matches(l listing.Listing) bool {
+	if f.Category != "" && !strings.EqualFold(f.Category, l.Category) {
+		return false
+	}
+	if f.Manufacturer != "" && !strings.EqualFold(f.Manufacturer, l.Manufacturer) {
+		return false
+	}
+	if f.Currency != "" && !strings.EqualFold(f.Currency, l.Currency) {
+		return false
+	}
+	if f.ConditionPhotos && l.Details.PhotoCount < listing.MinConditionPhotos {
+		return false
+	}
+	if !f.Near.IsZero() {
+		if km, ok := l.DistanceKM(f.Near); !ok || km > f.MaxKM {
+			return false
+		}
+	}
+	if f.Size != "" && !strings.EqualFold(f.Size, l.FrameSize) {
+		size := (*parser.Sizes)(nil).Normalize(l.Manufacturer, f.Size, "")
+		if size == "" || size != l.NormalizedSize {
+			return false
+		}
+	}
+	if f.Wheels != "" && !l.Wheels.Matches(f.Wheels) {
+		return false
+	}
+	return true
+}
+
+// RankDeals returns up to n active listings matching filter priced furthest
+// below the median of their model and year. Listings of a model year with too
+// few prices are compared against the model's median across every year
+// instead. Listings of a known generation are only compared against the same
+// generation. Medians are taken from all of listings, not only those matching
+// filter, so a size or region filter does not thin out the comparison.
+// Deals are ranked by their score weighted by the listing's description
+// quality, so a bargain that is hard to evaluate ranks under a documented one.
+func RankDeals(listings []listing.Listing, filter DealFilter, n int) []Deal {
+	market := newMarket(listings)
+
+	var deals []Deal
+	for _, l := range listings {
+		if !l.Active || !filter.matches(l) {
+			continue
+		}
+		d, ok := market.rate(l)
+		if !ok || d.Score <= 0 || d.Score < filter.MinScore {
+			continue
+		}
+		deals = append(deals, d)
+	}
+
+	sort.SliceStable(deals, func(i, j int) bool { return deals[i].rank() > deals[j].rank() })
+	if n > 0 && len(deals) > n {
+		deals = deals[:n]
+	}
+	return deals
+}
+
+type marketKey struct{ manufacturer, model, generation, year string }
+
+// market is the prices of active listings by model, and by model and year,
+// across every generation and of each known generation
+type market map[marketKey][]float64
+
+func newMarket(listings []listing.Listing) market {
+	m := market{}
+	for _, l := range listings {
+		if !l.Active || len(l.NeedsReview) > 0 || l.FraudScore >= fraud.Threshold {
+			continue
+		}
+		price, err := strconv.ParseFloat(l.Price, 64)
+		if err != nil || price <= 0 {
+			continue
+		}
+		generations := []string{""}
+		if l.Generation != "" {
+			generations = append(generations, l.Generation)
+		}
+		for _, generation := range generations {
+			model := marketKey{strings.ToLower(l.Manufacturer), strings.ToLower(l.Model), generation, ""}
+			m[model] = append(m[model], price)
+			if l.Year != "" {
+				year := model
+				year.year = l.Year
+				m[year] = append(m[year], price)
+			}
+		}
+	}
+	return m
+}
+
+// rate scores l against the median of its model, generation and year, or of
+// its model and generation across every year when its year has too few prices
+func (m market) rate(l listing.Listing) (Deal, bool) {
+	k := marketKey{strings.ToLower(l.Manufacturer), strings.ToLower(l.Model), l.Generation, l.Year}
+	if len(m[k]) < minComparables {
+		k.year = ""
+	}
+	comps := m[k]
+	medians := func(string, string, string) (float64, int, error) {
+		if len(comps) == 0 {
+			return 0, 0, nil
+		}
+		return median(comps), len(comps), nil
+	}
+
+	d, ok := Rate(l, medians)
+	if ok && !d.Predicted {
+		d.Year = k.year
+	}
+	return d, ok
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// rank is the deal's score weighted by its listing's description quality,
+// halved for a listing with nothing to go on. Listings whose page was not
+// scraped are not weighted.
+func (d Deal) rank() float64 {
+	q, ok := d.Listing.Quality()
+	if !ok {
+		return d.Score
+	}
+	return d.Score * (0.5 + 0.5*q.Score)
+}
+
+// WriteDeals prints a numbered list of deals
+func WriteDeals(w io.Writer, deals []Deal) {
+	for i, d := range deals {
+		basis := "median"
+		switch {
+		case d.Predicted:
+			basis = "predicted"
+		case d.Year != "":
+			basis = d.Year + " median"
+		}
+		retail := ""
+		if d.Retail > 0 {
+			retail = fmt.Sprintf(", %.0f%% off $%.0f retail", d.OffRetail*100, d.Retail)
+		}
+		fmt.Fprintf(w, "  %d. %s - $%s (%.0f%% under $%.0f %s%s)\n     %s\n",
+			i+1, d.Listing.Title, d.Listing.Price, d.Score*100, d.Median, basis, retail, d.Listing.URL)
+	}
+}
@@ -0,0 +1,151 @@
+package brief
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pinkbike-scraper/pkg/geo"
+	"pinkbike-scraper/pkg/listing"
+)
+
+func TestRankDeals(t *testing.T) {
+	bike := func(title, year, price, size, currency string) listing.Listing {
+		return listing.Listing{Title: title, Manufacturer: "Santa Cruz", Model: "Megatower", Year: year, Price: price,
+			NormalizedSize: size, Currency: currency, Category: "enduro", Active: true}
+	}
+	listings := []listing.Listing{
+		bike("2022 Megatower A", "2022", "5000", "L", "USD"),
+		bike("2022 Megatower B", "2022", "5200", "M", "USD"),
+		bike("2022 Megatower C", "2022", "4000", "L", "CAD"),
+		bike("2019 Megatower", "2019", "3000", "L", "USD"),
+		bike("2020 Megatower", "2020", "2500", "M", "USD"),
+	}
+	sold := bike("2022 Megatower sold", "2022", "1000", "L", "USD")
+	sold.Active = false
+	listings = append(listings, sold)
+
+	deals := RankDeals(listings, DealFilter{}, 10)
+	require.Len(t, deals, 3)
+
+	// the 2022s are compared within their year, the older bikes across every year
+	assert.Equal(t, "2020 Megatower", deals[0].Listing.Title)
+	assert.Equal(t, "", deals[0].Year)
+	assert.Equal(t, 4000.0, deals[0].Median)
+	assert.Equal(t, 5, deals[0].Comps)
+	assert.Equal(t, "2019 Megatower", deals[1].Listing.Title)
+	assert.Equal(t, "2022 Megatower C", deals[2].Listing.Title)
+	assert.Equal(t, "2022", deals[2].Year)
+	assert.Equal(t, 5000.0, deals[2].Median)
+
+	deals = RankDeals(listings, DealFilter{Size: "l", Currency: "usd"}, 10)
+	require.Len(t, deals, 1)
+	assert.Equal(t, "2019 Megatower", deals[0].Listing.Title)
+	assert.Equal(t, 4000.0, deals[0].Median, "filters do not change the comps")
+
+	assert.Len(t, RankDeals(listings, DealFilter{MinScore: 0.3}, 10), 1)
+	assert.Len(t, RankDeals(listings, DealFilter{}, 2), 2)
+	assert.Empty(t, RankDeals(listings, DealFilter{Category: "downhill"}, 10))
+
+	calgary := geo.Point{Lat: 51.0447, Lon: -114.0719}
+	listings[2].Coordinates = geo.Point{Lat: 51.1784, Lon: -115.5708}
+	deals = RankDeals(listings, DealFilter{Near: calgary, MaxKM: 300}, 10)
+	require.Len(t, deals, 1, "listings without a location are not near")
+	assert.Equal(t, "2022 Megatower C", deals[0].Listing.Title)
+	assert.Empty(t, RankDeals(listings, DealFilter{Near: calgary, MaxKM: 50}, 10))
+	listings[2].Coordinates = geo.Point{}
+
+	var out bytes.Buffer
+	WriteDeals(&out, RankDeals(listings, DealFilter{Currency: "CAD"}, 10))
+	assert.Equal(t, "  1. 2022 Megatower C - $4000 (20% under $5000 2022 median)\n     \n", out.String())
+
+	// a price model's prediction is scored against instead of the median, so
+	// a listing needs no comps
+	predicted := bike("2021 Megatower", "2021", "4800", "L", "USD")
+	predicted.PredictedPrice = 6000
+	deals = RankDeals([]listing.Listing{predicted}, DealFilter{}, 10)
+	require.Len(t, deals, 1)
+	assert.True(t, deals[0].Predicted)
+	assert.InDelta(t, 0.2, deals[0].Score, 0.0001)
+	out.Reset()
+	WriteDeals(&out, deals)
+	assert.Equal(t, "  1. 2021 Megatower - $4800 (20% under $6000 predicted)\n     \n", out.String())
+}
+
+func TestRankDealsKeepsGenerationsApart(t *testing.T) {
+	bike := func(title, generation, price string) listing.Listing {
+		return listing.Listing{Title: title, Manufacturer: "Santa Cruz", Model: "Nomad", Generation: generation, Price: price, Active: true}
+	}
+	listings := []listing.Listing{
+		bike("Nomad 4 A", "V4", "2600"),
+		bike("Nomad 4 B", "V4", "2800"),
+		bike("Nomad 4 C", "V4", "3000"),
+		bike("Nomad 6 A", "V6", "5600"),
+		bike("Nomad 6 B", "V6", "5800"),
+		bike("Nomad 6 C", "V6", "4800"),
+	}
+
+	deals := RankDeals(listings, DealFilter{}, 10)
+	require.Len(t, deals, 2)
+	assert.Equal(t, "Nomad 6 C", deals[0].Listing.Title)
+	assert.Equal(t, 5600.0, deals[0].Median, "a V6 is not compared against the cheaper V4s")
+	assert.Equal(t, "Nomad 4 A", deals[1].Listing.Title)
+	assert.Equal(t, 2800.0, deals[1].Median)
+}
+
+func TestRankDealsWeighsDescriptionQuality(t *testing.T) {
+	bike := func(title, year, price string, details listing.ListingDetails) listing.Listing {
+		return listing.Listing{Title: title, Manufacturer: "Yeti", Model: "SB150", Year: year, Price: price, Active: true, Details: details}
+	}
+	documented := listing.ListingDetails{
+		Description: strings.Repeat("Ridden two seasons and well looked after. ", 12) +
+			"Fox 38 fork, Float X2 shock, XT brakes and drivetrain, DT Swiss wheels. Call me.",
+		PhotoCount: 12,
+	}
+	sparse := listing.ListingDetails{Description: "Good bike.", PhotoCount: 1}
+	listings := []listing.Listing{
+		bike("2021 SB150", "2021", "5000", listing.ListingDetails{}),
+		bike("2021 SB150 cheap", "2021", "3000", sparse),
+		bike("2021 SB150 fair", "2021", "3500", documented),
+		bike("2021 SB150 pricey", "2021", "6000", listing.ListingDetails{}),
+	}
+
+	deals := RankDeals(listings, DealFilter{}, 10)
+	require.Len(t, deals, 2)
+	assert.Equal(t, "2021 SB150 fair", deals[0].Listing.Title, "a documented bargain ranks over a cheaper one that is hard to judge")
+	assert.Equal(t, "2021 SB150 cheap", deals[1].Listing.Title)
+	assert.Greater(t, deals[1].Score, deals[0].Score)
+
+	deals = RankDeals(listings, DealFilter{ConditionPhotos: true}, 10)
+	require.Len(t, deals, 1)
+	assert.Equal(t, "2021 SB150 fair", deals[0].Listing.Title)
+}
+
+func TestRankDealsLeavesOutSuspectedScams(t *testing.T) {
+	bike := func(title, price string) listing.Listing {
+		return listing.Listing{Title: title, Manufacturer: "Santa Cruz", Model: "Nomad", Price: price, Active: true}
+	}
+	scam := bike("Nomad, shipping only", "1500")
+	scam.FraudScore = 0.8
+	listings := []listing.Listing{
+		bike("Nomad A", "5600"),
+		bike("Nomad B", "5800"),
+		bike("Nomad C", "4800"),
+		bike("Nomad D", "5000"),
+		scam,
+	}
+
+	deals := RankDeals(listings, DealFilter{}, 10)
+	require.Len(t, deals, 2)
+	assert.Equal(t, "Nomad C", deals[0].Listing.Title)
+	assert.Equal(t, 5300.0, deals[0].Median, "a scam's price is not one of the comps")
+	assert.Equal(t, "Nomad D", deals[1].Listing.Title)
+
+	medians := func(string, string, string) (float64, int, error) { return 5300, 4, nil }
+	deals = Deals(listings, medians, 10)
+	require.Len(t, deals, 2)
+	assert.Equal(t, "Nomad C", deals[0].Listing.Title)
+}
@@ -0,0 +1,184 @@
+package brief
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"pinkbike-scraper/pkg/listing"
+)
+
+// RegionOptions configures CompareRegions
+type RegionOptions struct {
+	// ByCountry groups listings by country instead of province or state
+	ByCountry bool
+	// MinListings is the number of prices a region needs for its median to
+	// be compared
+	MinListings int
+	// Regions limits the comparison to these regions, such as "British
+	// Columbia" or "Washington", when not empty
+	Regions []string
+}
+
+// RegionalMedian is the median USD price of a model in one region
+type RegionalMedian struct {
+	Region   string
+	Median   float64
+	Listings int
+}
+
+// RegionalPrices is a model's median price in each region it sells in, from
+// cheapest to dearest
+type RegionalPrices struct {
+	Manufacturer, Model, Generation string
+	Regions                         []RegionalMedian
+}
+
+// Spread is how much dearer the dearest region is than the cheapest, as a
+// fraction of the cheapest
+func (p RegionalPrices) Spread() float64 {
+	cheapest, dearest := p.Regions[0].Median, p.Regions[len(p.Regions)-1].Median
+	if cheapest <= 0 {
+		return 0
+	}
+	return (dearest - cheapest) / cheapest
+}
+
+// Region returns the province or state and country of a location such as
+// "Squamish, British Columbia, Canada", or "" when it has neither
+func Region(location string) string {
+	parts := locationParts(location)
+	if len(parts) < 2 {
+		return ""
+	}
+	return strings.Join(parts[len(parts)-2:], ", ")
+}
+
+// Country returns the country of a location such as "Squamish, British
+// Columbia, Canada". Listings without a location are placed by the currency
+// they were priced in, as Canadian sellers list in CAD and US ones in USD.
+func Country(location, currency string) string {
+	if parts := locationParts(location); len(parts) > 0 {
+		return parts[len(parts)-1]
+	}
+	switch strings.ToUpper(currency) {
+	case "CAD":
+		return "Canada"
+	case "USD":
+		return "United States"
+	}
+	return ""
+}
+
+// locationParts splits a location at its commas, collapsing the line breaks
+// Pinkbike wraps long country names with
+func locationParts(location string) []string {
+	var parts []string
+	for _, part := range strings.Split(location, ",") {
+		if part = strings.Join(strings.Fields(part), " "); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// CompareRegions returns the models listed in at least two regions with
+// enough prices, with the model whose regional medians differ the most first.
+// Each known generation of a model is compared apart from the rest. Only
+// active listings not awaiting review are counted.
+func CompareRegions(listings []listing.Listing, opts RegionOptions) []RegionalPrices {
+	type modelKey struct{ manufacturer, model, generation string }
+	prices := map[modelKey]map[string][]float64{}
+	names := map[modelKey]listing.Listing{}
+	for _, l := range listings {
+		if !l.Active || len(l.NeedsReview) > 0 || l.Model == "" {
+			continue
+		}
+		price, err := strconv.ParseFloat(l.Price, 64)
+		if err != nil || price <= 0 {
+			continue
+		}
+		region := Region(l.Location)
+		if opts.ByCountry {
+			region = Country(l.Location, l.Currency)
+		}
+		if region == "" || !includesRegion(opts.Regions, region) {
+			continue
+		}
+
+		k := modelKey{strings.ToLower(l.Manufacturer), strings.ToLower(l.Model), l.Generation}
+		if prices[k] == nil {
+			prices[k] = map[string][]float64{}
+			names[k] = l
+		}
+		prices[k][region] = append(prices[k][region], price)
+	}
+
+	var models []RegionalPrices
+	for k, regions := range prices {
+		p := RegionalPrices{Manufacturer: names[k].Manufacturer, Model: names[k].Model, Generation: k.generation}
+		for region, values := range regions {
+			if len(values) < opts.MinListings {
+				continue
+			}
+			p.Regions = append(p.Regions, RegionalMedian{Region: region, Median: median(values), Listings: len(values)})
+		}
+		if len(p.Regions) < 2 {
+			continue
+		}
+		sort.Slice(p.Regions, func(i, j int) bool {
+			if p.Regions[i].Median != p.Regions[j].Median {
+				return p.Regions[i].Median < p.Regions[j].Median
+			}
+			return p.Regions[i].Region < p.Regions[j].Region
+		})
+		models = append(models, p)
+	}
+
+	sort.Slice(models, func(i, j int) bool {
+		if si, sj := models[i].Spread(), models[j].Spread(); si != sj {
+			return si > sj
+		}
+		return models[i].Manufacturer+" "+models[i].Model+" "+models[i].Generation < models[j].Manufacturer+" "+models[j].Model+" "+models[j].Generation
+	})
+	return models
+}
+
+// includesRegion reports whether region is one of regions, matching either
+// the whole region or its province or state alone. An empty list includes
+// every region.
+func includesRegion(regions []string, region string) bool {
+	if len(regions) == 0 {
+		return true
+	}
+	for _, r := range regions {
+		r = strings.TrimSpace(r)
+		if strings.EqualFold(r, region) || strings.EqualFold(r, strings.SplitN(region, ",", 2)[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteRegions writes each model's regional medians, with how much dearer
+// each region is than the cheapest
+func WriteRegions(w io.Writer, models []RegionalPrices) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODEL\tREGION\tMEDIAN\tLISTINGS\tVS CHEAPEST")
+	for _, m := range models {
+		name := strings.TrimSpace(m.Manufacturer + " " + m.Model + " " + m.Generation)
+		cheapest := m.Regions[0].Median
+		for i, r := range m.Regions {
+			vs := "-"
+			if i > 0 && cheapest > 0 {
+				vs = fmt.Sprintf("+%.0f%%", (r.Median-cheapest)/cheapest*100)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t$%.0f\t%d\t%s\n", name, r.Region, r.Median, r.Listings, vs)
+			name = ""
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,83 @@
+package brief
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pinkbike-scraper/pkg/listing"
+)
+
+func TestRegion(t *testing.T) {
+	assert.Equal(t, "British Columbia, Canada", Region("Squamish, British Columbia, Canada"))
+	assert.Equal(t, "Nevada, United States", Region("Las Vegas, Nevada, United\n    States"))
+	assert.Empty(t, Region("Canada"))
+
+	assert.Equal(t, "United States", Country("Las Vegas, Nevada, United States", "CAD"))
+	assert.Equal(t, "Canada", Country("", "cad"))
+	assert.Empty(t, Country("", ""))
+}
+
+func TestCompareRegions(t *testing.T) {
+	bike := func(model, price, location, currency string) listing.Listing {
+		return listing.Listing{Manufacturer: "Santa Cruz", Model: model, Price: price, Location: location,
+			Currency: currency, Active: true}
+	}
+	const (
+		bc = "Squamish, British Columbia, Canada"
+		ab = "Calgary, Alberta, Canada"
+		wa = "Bellingham, Washington, United States"
+	)
+	listings := []listing.Listing{
+		bike("Megatower", "4000", bc, "CAD"),
+		bike("Megatower", "4400", bc, "CAD"),
+		bike("Megatower", "3000", ab, "CAD"),
+		bike("Megatower", "3200", ab, "CAD"),
+		bike("Megatower", "5000", wa, "USD"),
+		bike("Megatower", "5000", wa, "USD"),
+		bike("Hightower", "3000", bc, "CAD"),
+		bike("Hightower", "4000", ab, "CAD"),
+		bike("Hightower", "3300", wa, "USD"),
+		bike("Hightower", "3100", "", "USD"),
+		bike("Nomad", "3000", bc, "CAD"),
+	}
+	sold := bike("Nomad", "1000", wa, "USD")
+	sold.Active = false
+	listings = append(listings, sold)
+
+	models := CompareRegions(listings, RegionOptions{MinListings: 1})
+	require.Len(t, models, 2, "the Nomad only sells in one region")
+	assert.Equal(t, "Megatower", models[0].Model)
+	assert.Equal(t, []RegionalMedian{
+		{Region: "Alberta, Canada", Median: 3100, Listings: 2},
+		{Region: "British Columbia, Canada", Median: 4200, Listings: 2},
+		{Region: "Washington, United States", Median: 5000, Listings: 2},
+	}, models[0].Regions)
+	assert.InDelta(t, 0.6129, models[0].Spread(), 0.0001)
+	assert.Equal(t, "Hightower", models[1].Model)
+
+	assert.Len(t, CompareRegions(listings, RegionOptions{MinListings: 2}), 1)
+
+	models = CompareRegions(listings, RegionOptions{MinListings: 1, Regions: []string{"alberta", "Washington"}})
+	require.Len(t, models, 2)
+	for _, m := range models {
+		require.Len(t, m.Regions, 2, m.Model)
+		assert.ElementsMatch(t, []string{"Alberta, Canada", "Washington, United States"},
+			[]string{m.Regions[0].Region, m.Regions[1].Region}, m.Model)
+	}
+
+	// by country, listings without a location count where their currency is used
+	models = CompareRegions(listings, RegionOptions{ByCountry: true, MinListings: 2})
+	require.Len(t, models, 2)
+	assert.Equal(t, "Hightower", models[1].Model)
+	assert.Equal(t, RegionalMedian{Region: "United States", Median: 3200, Listings: 2}, models[1].Regions[0])
+
+	var out bytes.Buffer
+	require.NoError(t, WriteRegions(&out, models[1:]))
+	assert.Equal(t, `MODEL                 REGION         MEDIAN  LISTINGS  VS CHEAPEST
+Santa Cruz Hightower  United States  $3200   2         -
+                      Canada         $3500   2         +9%
+`, out.String())
+}
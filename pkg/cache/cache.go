@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"pinkbike-scraper/pkg/clock"
+)
+
+// ErrOffline is returned for values that are not cached when the cache may
+// not fetch them
+var ErrOffline = errors.New("offline and not cached")
+
+// Entry is a cached value with when it was stored and when it goes stale
+type Entry struct {
+	Value    []byte
+	StoredAt time.Time
+	// ExpiresAt is zero for values that never go stale
+	ExpiresAt time.Time
+}
+
+// Fresh reports whether the entry has not gone stale by now
+func (e Entry) Fresh(now time.Time) bool {
+	return e.ExpiresAt.IsZero() || now.Before(e.ExpiresAt)
+}
+
+// Store keeps cached values between runs, keyed by names such as
+// "rate/CAD/USD" that start with what kind of value they hold
+type Store interface {
+	CachedValue(key string) (Entry, bool, error)
+	CacheValue(key string, e Entry) error
+}
+
+// Fetch looks a value up at its source and returns it with how long it stays
+// fresh, zero for values that never go stale
+type Fetch func() (value []byte, ttl time.Duration, err error)
+
+// Cache reads values through a Store, fetching the ones it does not hold or
+// that went stale. Offline it never fetches and answers from the store alone,
+// stale values included.
+type Cache struct {
+	Store   Store
+	Offline bool
+	// Clock dates entries and decides which are stale, the system clock when nil
+	Clock clock.Clock
+}
+
+// Get returns the value cached under key, fetching and storing it when the
+// cache holds no fresh one. Offline, a missing value is ErrOffline.
+func (c *Cache) Get(key string, fetch Fetch) ([]byte, error) {
+	now := clock.Or(c.Clock).Now()
+	e, cached, err := c.Store.CachedValue(key)
+	if err != nil {
+		return nil, err
+	}
+	if cached && (c.Offline || e.Fresh(now)) {
+		return e.Value, nil
+	}
+	if c.Offline {
+		return nil, fmt.Errorf("%s: %w", key, ErrOffline)
+	}
+
+	value, ttl, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	e = Entry{Value: value, StoredAt: now.UTC()}
+	if ttl > 0 {
+		e.ExpiresAt = e.StoredAt.Add(ttl)
+	}
+	if err := c.Store.CacheValue(key, e); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Memory keeps cached values for as long as the process runs, for commands
+// without a database and for tests
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+func NewMemory() *Memory {
+	return &Memory{entries: map[string]Entry{}}
+}
+
+func (m *Memory) CachedValue(key string) (Entry, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[key]
+	return e, ok, nil
+}
+
+func (m *Memory) CacheValue(key string, e Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = e
+	return nil
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"pinkbike-scraper/pkg/clock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheGet(t *testing.T) {
+	start := time.Date(2024, 9, 19, 12, 0, 0, 0, time.UTC)
+	c := &Cache{Store: NewMemory(), Clock: clock.Fixed(start)}
+	calls := 0
+	fetch := func() ([]byte, time.Duration, error) {
+		calls++
+		return []byte{byte(calls)}, time.Hour, nil
+	}
+
+	value, err := c.Get("rate/CAD/USD", fetch)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1}, value)
+	value, err = c.Get("rate/CAD/USD", fetch)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1}, value, "fresh values are not fetched again")
+
+	c.Clock = clock.Fixed(start.Add(2 * time.Hour))
+	value, err = c.Get("rate/CAD/USD", fetch)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{2}, value, "stale values are fetched again")
+
+	_, err = c.Get("rate/EUR/USD", func() ([]byte, time.Duration, error) {
+		return nil, 0, errors.New("connection refused")
+	})
+	assert.Error(t, err)
+	_, cached, _ := c.Store.CachedValue("rate/EUR/USD")
+	assert.False(t, cached, "failed lookups are not cached")
+}
+
+func TestCacheOffline(t *testing.T) {
+	start := time.Date(2024, 9, 19, 12, 0, 0, 0, time.UTC)
+	store := NewMemory()
+	require.NoError(t, store.CacheValue("rate/CAD/USD", Entry{Value: []byte("0.73"), StoredAt: start, ExpiresAt: start.Add(time.Hour)}))
+	c := &Cache{Store: store, Offline: true, Clock: clock.Fixed(start.Add(48 * time.Hour))}
+	fetch := func() ([]byte, time.Duration, error) {
+		t.Fatal("offline caches do not fetch")
+		return nil, 0, nil
+	}
+
+	value, err := c.Get("rate/CAD/USD", fetch)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("0.73"), value, "stale values are used offline")
+
+	_, err = c.Get("rate/EUR/USD", fetch)
+	assert.ErrorIs(t, err, ErrOffline)
+}
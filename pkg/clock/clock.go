@@ -0,0 +1,34 @@
+package clock
+
+import "time"
+
+// Clock tells the current time. Code that dates listings or decides what is
+// stale takes one so tests can run at a fixed time instead of waiting for days
+// to pass.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// System is the real wall clock
+var System Clock = systemClock{}
+
+// Fixed is a clock stopped at a single instant
+type Fixed time.Time
+
+func (f Fixed) Now() time.Time {
+	return time.Time(f)
+}
+
+// Or returns c, or System when c is nil
+func Or(c Clock) Clock {
+	if c == nil {
+		return System
+	}
+	return c
+}
@@ -0,0 +1,119 @@
+package currency
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// USD is the currency prices are stored in
+const USD = "USD"
+
+var codePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ParseCode parses an ISO 4217 currency code such as "eur"
+func ParseCode(s string) (string, error) {
+	code := strings.ToUpper(strings.TrimSpace(s))
+	if !codePattern.MatchString(code) {
+		return "", fmt.Errorf("invalid currency code %q, expected three letters such as USD or EUR", s)
+	}
+	return code, nil
+}
+
+// Converter converts amounts between currencies. Each rate is fetched from
+// its provider the first time it is needed and reused after that, so a run
+// only asks for the rates of the currencies it actually converts.
+type Converter struct {
+	provider RateProvider
+
+	mu     sync.Mutex
+	rates  map[string]Rate
+	failed map[string]error
+}
+
+func NewConverter(provider RateProvider) *Converter {
+	return &Converter{provider: provider, rates: map[string]Rate{}, failed: map[string]error{}}
+}
+
+// Rate returns the current rate from base to quote, fetching it on first
+// use. A rate that could not be fetched is not asked for again.
+func (c *Converter) Rate(base, quote string) (Rate, error) {
+	return c.cached(base+"/"+quote, func() (Rate, error) {
+		rate, err := c.provider.Rate(base, quote)
+		if err != nil {
+			return Rate{}, fmt.Errorf("could not get %s to %s exchange rate: %w", base, quote, err)
+		}
+		return rate, nil
+	})
+}
+
+// RateOn returns the rate from base to quote on day, fetching it on first
+// use like Rate
+func (c *Converter) RateOn(base, quote string, day time.Time) (Rate, error) {
+	date := day.UTC().Format("2006-01-02")
+	return c.cached(base+"/"+quote+"/"+date, func() (Rate, error) {
+		rate, err := c.provider.RateOn(base, quote, day)
+		if err != nil {
+			return Rate{}, fmt.Errorf("could not get %s to %s exchange rate on %s: %w", base, quote, date, err)
+		}
+		return rate, nil
+	})
+}
+
+func (c *Converter) cached(key string, fetch func() (Rate, error)) (Rate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if rate, ok := c.rates[key]; ok {
+		return rate, nil
+	}
+	if err, ok := c.failed[key]; ok {
+		return Rate{}, err
+	}
+
+	rate, err := fetch()
+	if err != nil {
+		c.failed[key] = err
+		return Rate{}, err
+	}
+	c.rates[key] = rate
+	return rate, nil
+}
+
+// Convert converts amount from one currency to another. An empty currency is
+// taken to be USD, as prices with only a bare "$" are.
+func (c *Converter) Convert(amount float64, from, to string) (float64, error) {
+	if from == "" {
+		from = USD
+	}
+	if to == "" {
+		to = USD
+	}
+	if from == to {
+		return amount, nil
+	}
+	rate, err := c.Rate(from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate.Value, nil
+}
+
+// Rates returns the rates fetched so far, current and historical, so they can
+// be recorded with the prices converted with them
+func (c *Converter) Rates() []Rate {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]string, 0, len(c.rates))
+	for key := range c.rates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	rates := make([]Rate, len(keys))
+	for i, key := range keys {
+		rates[i] = c.rates[key]
+	}
+	return rates
+}
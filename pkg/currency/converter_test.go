@@ -0,0 +1,116 @@
+package currency
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pinkbike-scraper/pkg/cache"
+	"pinkbike-scraper/pkg/clock"
+)
+
+// countingProvider counts the rates asked of the provider it wraps
+type countingProvider struct {
+	RateProvider
+	calls int
+}
+
+func (p *countingProvider) Rate(base, quote string) (Rate, error) {
+	p.calls++
+	return p.RateProvider.Rate(base, quote)
+}
+
+func (p *countingProvider) RateOn(base, quote string, day time.Time) (Rate, error) {
+	p.calls++
+	return p.RateProvider.RateOn(base, quote, day)
+}
+
+func TestConverter(t *testing.T) {
+	provider := &countingProvider{RateProvider: FixedRate{Value: 0.73, Clock: clock.Fixed(time.Date(2024, 9, 19, 0, 0, 0, 0, time.UTC))}}
+	c := NewConverter(provider)
+
+	usd, err := c.Convert(1000, "CAD", "USD")
+	require.NoError(t, err)
+	assert.InDelta(t, 730, usd, 0.001)
+	_, err = c.Convert(2000, "CAD", "USD")
+	require.NoError(t, err)
+	assert.Equal(t, 1, provider.calls, "a rate is fetched once")
+
+	cad, err := c.Convert(730, "", "CAD")
+	require.NoError(t, err)
+	assert.InDelta(t, 1000, cad, 0.001, "a bare dollar is USD")
+
+	same, err := c.Convert(3500, "EUR", "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, 3500.0, same)
+	assert.Equal(t, 2, provider.calls, "converting to the same currency needs no rate")
+
+	_, err = c.Convert(3500, "EUR", "USD")
+	assert.Error(t, err)
+	_, err = c.Convert(3500, "EUR", "USD")
+	assert.Error(t, err)
+	assert.Equal(t, 3, provider.calls, "a failed rate is not asked for again")
+
+	rates := c.Rates()
+	require.Len(t, rates, 2)
+	assert.Equal(t, "CAD", rates[0].Base)
+	assert.Equal(t, "USD", rates[1].Base)
+	assert.Equal(t, "fixed", rates[1].Source)
+}
+
+func TestConverterRateOn(t *testing.T) {
+	provider := &countingProvider{RateProvider: FixedRate{Value: 0.73}}
+	c := NewConverter(provider)
+	posted := time.Date(2023, 3, 14, 18, 30, 0, 0, time.UTC)
+
+	rate, err := c.RateOn("CAD", "USD", posted)
+	require.NoError(t, err)
+	assert.Equal(t, 0.73, rate.Value)
+	assert.Equal(t, "2023-03-14", rate.Date.Format("2006-01-02"))
+
+	_, err = c.RateOn("CAD", "USD", posted.Add(time.Hour))
+	require.NoError(t, err)
+	_, err = c.Rate("CAD", "USD")
+	require.NoError(t, err)
+	assert.Len(t, c.Rates(), 2, "a day's rate is fetched once and kept apart from today's")
+}
+
+func TestParseCode(t *testing.T) {
+	code, err := ParseCode(" eur ")
+	require.NoError(t, err)
+	assert.Equal(t, "EUR", code)
+
+	_, err = ParseCode("euro")
+	assert.Error(t, err)
+}
+
+func TestCachedRates(t *testing.T) {
+	fetchedAt := time.Date(2024, 9, 19, 0, 0, 0, 0, time.UTC)
+	provider := &countingProvider{RateProvider: FixedRate{Value: 0.73, Clock: clock.Fixed(fetchedAt)}}
+	store := cache.NewMemory()
+	rates := Cached(provider, &cache.Cache{Store: store, Clock: clock.Fixed(fetchedAt)}, 12*time.Hour)
+
+	for i := 0; i < 2; i++ {
+		rate, err := rates.CADtoUSD()
+		require.NoError(t, err)
+		assert.Equal(t, 0.73, rate.Value)
+		assert.Equal(t, fetchedAt, rate.FetchedAt)
+	}
+	day := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+	_, err := rates.RateOn("CAD", "USD", day)
+	require.NoError(t, err)
+	_, err = rates.RateOn("CAD", "USD", day)
+	require.NoError(t, err)
+	assert.Equal(t, 2, provider.calls, "cached rates are fetched once")
+
+	// a later run offline answers from the cache alone, stale or not
+	offline := Cached(provider, &cache.Cache{Store: store, Offline: true, Clock: clock.Fixed(fetchedAt.AddDate(0, 1, 0))}, 12*time.Hour)
+	rate, err := offline.Rate("CAD", "USD")
+	require.NoError(t, err)
+	assert.Equal(t, 0.73, rate.Value)
+	_, err = offline.Rate("EUR", "USD")
+	assert.ErrorIs(t, err, cache.ErrOffline)
+	assert.Equal(t, 2, provider.calls)
+}
@@ -0,0 +1,250 @@
+package currency
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"pinkbike-scraper/pkg/cache"
+	"pinkbike-scraper/pkg/clock"
+)
+
+// exchangeRateAPIURL is followed by the base currency, such as CAD
+const exchangeRateAPIURL = "https://api.exchangerate-api.com/v4/latest/"
+
+// frankfurterURL serves the European Central Bank's daily reference rates,
+// followed by a date such as 2024-09-19
+const frankfurterURL = "https://api.frankfurter.app/"
+
+// Rate is an exchange rate along with where and when it was fetched, so prices
+// converted with it can be traced back to it
+type Rate struct {
+	Base      string    `json:"base"`
+	Quote     string    `json:"quote"`
+	Value     float64   `json:"value"`
+	Source    string    `json:"source"`
+	FetchedAt time.Time `json:"fetched_at"`
+	// Date is the day a historical rate applied on, zero for current rates
+	Date time.Time `json:"date,omitempty"`
+}
+
+type exchangeRateResponse struct {
+	Date  string
+	Rates map[string]float64
+}
+
+// RateProvider supplies the CAD to USD rate prices are converted with when
+// they are scraped, the rates between other currencies prices are reported
+// in, and the rates on past days older listings are converted at
+type RateProvider interface {
+	CADtoUSD() (Rate, error)
+	Rate(base, quote string) (Rate, error)
+	RateOn(base, quote string, day time.Time) (Rate, error)
+}
+
+// ExchangeRateAPI provides live rates from exchangerate-api.com
+type ExchangeRateAPI struct{}
+
+func (ExchangeRateAPI) CADtoUSD() (Rate, error) {
+	return FetchCADtoUSD()
+}
+
+func (ExchangeRateAPI) Rate(base, quote string) (Rate, error) {
+	return FetchRate(base, quote)
+}
+
+// RateOn fetches the rate on day from frankfurter.app, as the free
+// exchangerate-api.com API only has current rates
+func (ExchangeRateAPI) RateOn(base, quote string, day time.Time) (Rate, error) {
+	return FetchRateOn(base, quote, day)
+}
+
+// FixedRate provides the same rate every time without touching the network,
+// for tests and offline runs
+type FixedRate struct {
+	Value float64
+	// Clock dates the rate, the system clock when nil
+	Clock clock.Clock
+}
+
+func (f FixedRate) CADtoUSD() (Rate, error) {
+	return Rate{
+		Base:      "CAD",
+		Quote:     "USD",
+		Value:     f.Value,
+		Source:    "fixed",
+		FetchedAt: clock.Or(f.Clock).Now().UTC(),
+	}, nil
+}
+
+// Rate returns the fixed rate between CAD and USD either way round. It knows
+// no other currencies, beyond every currency being worth itself.
+func (f FixedRate) Rate(base, quote string) (Rate, error) {
+	rate, err := f.CADtoUSD()
+	if err != nil {
+		return Rate{}, err
+	}
+	rate.Base, rate.Quote = base, quote
+	switch {
+	case base == quote:
+		rate.Value = 1
+	case base == "CAD" && quote == "USD":
+	case base == "USD" && quote == "CAD" && f.Value != 0:
+		rate.Value = 1 / f.Value
+	default:
+		return Rate{}, fmt.Errorf("no fixed rate from %s to %s, only between CAD and USD", base, quote)
+	}
+	return rate, nil
+}
+
+// RateOn returns the same rate as Rate, whatever the day
+func (f FixedRate) RateOn(base, quote string, day time.Time) (Rate, error) {
+	rate, err := f.Rate(base, quote)
+	if err != nil {
+		return Rate{}, err
+	}
+	rate.Date = startOfDay(day)
+	return rate, nil
+}
+
+// FetchCADtoUSD fetches the current CAD to USD rate from exchangerate-api.com
+func FetchCADtoUSD() (Rate, error) {
+	return FetchRate("CAD", "USD")
+}
+
+// FetchRate fetches the current rate from base to quote from
+// exchangerate-api.com
+func FetchRate(base, quote string) (Rate, error) {
+	url := exchangeRateAPIURL + base
+	resp, err := http.Get(url)
+	if err != nil {
+		return Rate{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Rate{}, err
+	}
+
+	var data exchangeRateResponse
+	err = json.Unmarshal(body, &data)
+	if err != nil {
+		return Rate{}, err
+	}
+
+	value, ok := data.Rates[quote]
+	if !ok {
+		return Rate{}, fmt.Errorf("no %s rate in response from %s", quote, url)
+	}
+
+	return Rate{
+		Base:      base,
+		Quote:     quote,
+		Value:     value,
+		Source:    url,
+		FetchedAt: time.Now().UTC(),
+	}, nil
+}
+
+// FetchRateOn fetches the European Central Bank reference rate from base to
+// quote on day from frankfurter.app. Rates are only published on working
+// days, so a weekend or holiday gets the rate of the last working day before
+// it, which the returned rate is dated with.
+func FetchRateOn(base, quote string, day time.Time) (Rate, error) {
+	url := fmt.Sprintf("%s%s?from=%s&to=%s", frankfurterURL, day.Format("2006-01-02"), base, quote)
+	resp, err := http.Get(url)
+	if err != nil {
+		return Rate{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Rate{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Rate{}, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+
+	var data exchangeRateResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		return Rate{}, err
+	}
+
+	value, ok := data.Rates[quote]
+	if !ok {
+		return Rate{}, fmt.Errorf("no %s rate in response from %s", quote, url)
+	}
+	date, err := time.Parse("2006-01-02", data.Date)
+	if err != nil {
+		return Rate{}, fmt.Errorf("invalid date %q in response from %s", data.Date, url)
+	}
+
+	return Rate{
+		Base:      base,
+		Quote:     quote,
+		Value:     value,
+		Source:    url,
+		FetchedAt: time.Now().UTC(),
+		Date:      date,
+	}, nil
+}
+
+// startOfDay truncates t to midnight UTC of its day
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// cachedRates answers from a cache before asking its provider
+type cachedRates struct {
+	provider RateProvider
+	cache    *cache.Cache
+	ttl      time.Duration
+}
+
+// Cached keeps the rates provider fetches in c between runs. Current rates go
+// stale after ttl; rates on past days never change and never go stale. A
+// cached rate keeps the source and time it was first fetched from.
+func Cached(provider RateProvider, c *cache.Cache, ttl time.Duration) RateProvider {
+	return cachedRates{provider: provider, cache: c, ttl: ttl}
+}
+
+func (c cachedRates) CADtoUSD() (Rate, error) {
+	return c.Rate("CAD", USD)
+}
+
+func (c cachedRates) Rate(base, quote string) (Rate, error) {
+	return c.get("rate/"+base+"/"+quote, c.ttl, func() (Rate, error) {
+		return c.provider.Rate(base, quote)
+	})
+}
+
+func (c cachedRates) RateOn(base, quote string, day time.Time) (Rate, error) {
+	return c.get("rate/"+base+"/"+quote+"/"+day.UTC().Format("2006-01-02"), 0, func() (Rate, error) {
+		return c.provider.RateOn(base, quote, day)
+	})
+}
+
+func (c cachedRates) get(key string, ttl time.Duration, fetch func() (Rate, error)) (Rate, error) {
+	value, err := c.cache.Get(key, func() ([]byte, time.Duration, error) {
+		rate, err := fetch()
+		if err != nil {
+			return nil, 0, err
+		}
+		value, err := json.Marshal(rate)
+		return value, ttl, err
+	})
+	if err != nil {
+		return Rate{}, err
+	}
+
+	var rate Rate
+	if err := json.Unmarshal(value, &rate); err != nil {
+		return Rate{}, fmt.Errorf("invalid cached rate %s: %w", key, err)
+	}
+	return rate, nil
+}
@@ -0,0 +1,121 @@
+package events
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"pinkbike-scraper/pkg/listing"
+)
+
+// Kind identifies a listing lifecycle event
+type Kind string
+
+const (
+	ListingDiscovered Kind = "listing_discovered"
+	PriceChanged      Kind = "price_changed"
+	// ListingSold is published when a listing drops out of the search
+	// results, which on Pinkbike almost always means it sold
+	ListingSold Kind = "listing_sold"
+	// ListingsScraped carries every listing of a run once it is scraped and
+	// refined, for the exporters to store
+	ListingsScraped Kind = "listings_scraped"
+)
+
+// listingKinds are the kinds describing a change to a single listing
+var listingKinds = []Kind{ListingDiscovered, PriceChanged, ListingSold}
+
+// Event describes a change to a listing observed during a run
+type Event struct {
+	Kind    Kind
+	Listing listing.Listing
+	// OldPrice is set for PriceChanged events
+	OldPrice string
+	// Listings is set for ListingsScraped events
+	Listings []listing.Listing
+	Time     time.Time
+}
+
+// Handler reacts to an event. Returned errors are reported to the bus's
+// OnError and do not stop other handlers.
+type Handler func(Event) error
+
+// Bus delivers events to in-process subscribers so custom behaviour can be added
+// without touching the scrape and export pipeline
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[Kind][]namedHandler
+	// OnError is called when a handler fails or panics. Failures are logged
+	// when it is nil.
+	OnError func(handler string, e Event, err error)
+}
+
+type namedHandler struct {
+	name    string
+	handler Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{handlers: map[Kind][]namedHandler{}}
+}
+
+// Subscribe registers a handler for the given kinds, or for every change to a
+// single listing when none are given
+func (b *Bus) Subscribe(name string, h Handler, kinds ...Kind) {
+	if len(kinds) == 0 {
+		kinds = listingKinds
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, kind := range kinds {
+		b.handlers[kind] = append(b.handlers[kind], namedHandler{name: name, handler: h})
+	}
+}
+
+// Publish synchronously delivers the event to every subscriber of its kind.
+// Publishing on a nil bus is a no-op.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	handlers := b.handlers[e.Kind]
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		err := call(h.handler, e)
+		switch {
+		case err == nil:
+		case b.OnError != nil:
+			b.OnError(h.name, e, err)
+		default:
+			log.Printf("event handler %s failed on %s: %v", h.name, e.Kind, err)
+		}
+	}
+}
+
+func call(h Handler, e Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return h(e)
+}
+
+// LogHandler prints a one-line summary of each event
+func LogHandler(e Event) error {
+	switch e.Kind {
+	case PriceChanged:
+		fmt.Printf("[%s] %s: %s -> %s %s\n", e.Kind, e.Listing.Title, e.OldPrice, e.Listing.Price, e.Listing.URL)
+	default:
+		fmt.Printf("[%s] %s %s\n", e.Kind, e.Listing.Title, e.Listing.URL)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package events
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBusDeliversToSubscribedKinds(t *testing.T) {
+	bus := NewBus()
+
+	var all, priceOnly []Kind
+	bus.Subscribe("all", func(e Event) error {
+		all = append(all, e.Kind)
+		return nil
+	})
+	bus.Subscribe("price", func(e Event) error {
+		priceOnly = append(priceOnly, e.Kind)
+		return nil
+	}, PriceChanged)
+
+	bus.Publish(Event{Kind: ListingDiscovered})
+	bus.Publish(Event{Kind: PriceChanged})
+	bus.Publish(Event{Kind: ListingSold})
+	bus.Publish(Event{Kind: ListingsScraped})
+
+	assert.Equal(t, []Kind{ListingDiscovered, PriceChanged, ListingSold}, all, "batches need an explicit subscription")
+	assert.Equal(t, []Kind{PriceChanged}, priceOnly)
+}
+
+func TestBusIsolatesFailingHandlers(t *testing.T) {
+	bus := NewBus()
+
+	delivered := 0
+	bus.Subscribe("error", func(Event) error { return errors.New("boom") })
+	bus.Subscribe("panic", func(Event) error { panic("boom") })
+	bus.Subscribe("ok", func(Event) error {
+		delivered++
+		return nil
+	})
+
+	var failed []string
+	bus.OnError = func(handler string, e Event, err error) {
+		failed = append(failed, handler+": "+err.Error())
+	}
+
+	bus.Publish(Event{Kind: ListingDiscovered})
+	assert.Equal(t, 1, delivered)
+	assert.Equal(t, []string{"error: boom", "panic: panic: boom"}, failed)
+}
+
+func TestNilBusPublish(t *testing.T) {
+	var bus *Bus
+	assert.NotPanics(t, func() { bus.Publish(Event{Kind: ListingDiscovered}) })
+}
@@ -0,0 +1,29 @@
+package exporter
+
+import (
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/privacy"
+)
+
+// AnonymizingExporter wraps an exporter whose output is shared, removing
+// seller-identifying data from listings before they reach it
+type AnonymizingExporter struct {
+	Exporter
+	anonymizer privacy.Anonymizer
+}
+
+func NewAnonymizingExporter(exp Exporter, anonymizer privacy.Anonymizer) *AnonymizingExporter {
+	return &AnonymizingExporter{Exporter: exp, anonymizer: anonymizer}
+}
+
+func (a *AnonymizingExporter) Export(listings []listing.Listing) error {
+	return a.Exporter.Export(a.anonymizer.Listings(listings))
+}
+
+// Files returns the files the wrapped exporter wrote, if it writes any
+func (a *AnonymizingExporter) Files() []string {
+	if f, ok := a.Exporter.(FileExporter); ok {
+		return f.Files()
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package exporter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/privacy"
+)
+
+func TestAnonymizingExporter(t *testing.T) {
+	sink := &flakyExporter{}
+	exp := NewAnonymizingExporter(sink, privacy.Anonymizer{Mode: privacy.Drop})
+
+	l := listing.Listing{Title: "2021 Evil Wreckoning", Price: "3900"}.
+		WithDetails(listing.ListingDetails{Description: "Call 604-555-0123", Seller: "rider"})
+	require.NoError(t, exp.Export([]listing.Listing{l}))
+
+	require.Len(t, sink.batches, 1)
+	assert.Equal(t, "Call [phone removed]", sink.batches[0][0].Details.Description)
+	assert.Empty(t, sink.batches[0][0].Details.Seller)
+	assert.Nil(t, exp.Files())
+
+	csv := NewAnonymizingExporter(NewCSVExporter("good.csv", "suspect.csv", CSVOptions{}), privacy.Anonymizer{Mode: privacy.Hash})
+	assert.Equal(t, []string{"good.csv", "suspect.csv"}, csv.Files(), "files are still uploaded")
+}
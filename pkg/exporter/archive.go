@@ -0,0 +1,536 @@
+package exporter
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// archiveFormat names the files WriteArchive writes, so LoadArchive can tell
+// them from other gzipped JSON
+const archiveFormat = "pinkbike-scraper archive"
+
+// archiveVersion is bumped when the archive layout, not the database schema,
+// changes
+const archiveVersion = 1
+
+// archiveListingTables are the tables whose rows belong to a listing, through
+// the hash column given, and are filtered along with the listings
+var archiveListingTables = map[string]string{
+	"price_history":           "listing_hash",
+	"price_history_compacted": "listing_hash",
+	"listing_events":          "listing_hash",
+	"corrections":             "hash",
+	"reparse_changes":         "hash",
+	"favorites":               "listing_hash",
+	"favorite_changes":        "listing_hash",
+	"listing_edits":           "listing_hash",
+}
+
+// archiveSkipped reports whether a table is left out of archives: SQLite's
+// own tables, the search index, which is rebuilt on load, and exports queued
+// on this machine, which another machine should not send again
+func archiveSkipped(table string) bool {
+	return strings.HasPrefix(table, "sqlite_") || strings.HasPrefix(table, "listings_fts") || table == "pending_exports"
+}
+
+// archiveIdentifier matches the table and column names LoadArchive accepts
+var archiveIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ArchiveOptions selects the listings WriteArchive includes. Rows of other
+// tables that belong to a listing follow it; the rest, such as runs,
+// exchange rates and aliases, are always included whole.
+type ArchiveOptions struct {
+	// Since and Until limit listings to those seen between them; Until is
+	// exclusive and either may be zero to leave that end open
+	Since, Until time.Time
+	// Manufacturer and Category match exactly, ignoring case; empty matches all
+	Manufacturer string
+	Category     string
+	// ActiveOnly leaves out listings no longer on Pinkbike
+	ActiveOnly bool
+}
+
+func (o ArchiveOptions) listings() DumpOptions {
+	return DumpOptions{Since: o.Since, Until: o.Until, Manufacturer: o.Manufacturer, Category: o.Category, ActiveOnly: o.ActiveOnly}
+}
+
+func (o ArchiveOptions) filtered() bool {
+	return o != ArchiveOptions{}
+}
+
+// ArchiveResult counts the rows of each table an archive was written with or
+// loaded from
+type ArchiveResult map[string]int64
+
+// Total is how many rows were archived altogether
+func (r ArchiveResult) Total() int64 {
+	var total int64
+	for _, n := range r {
+		total += n
+	}
+	return total
+}
+
+// archiveHeader is the first line of an archive
+type archiveHeader struct {
+	Format        string    `json:"format"`
+	Version       int       `json:"version"`
+	SchemaVersion int       `json:"schema_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	Filtered      bool      `json:"filtered"`
+}
+
+// archiveTable starts the rows of a table. Its schema and column types let a
+// version without the table or some of its columns still load it.
+type archiveTable struct {
+	Table   string          `json:"table"`
+	Schema  string          `json:"schema"`
+	Columns []archiveColumn `json:"columns"`
+}
+
+type archiveColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"`
+}
+
+// archiveBlob holds a BLOB value, which JSON has no type for
+type archiveBlob struct {
+	Blob string `json:"blob"`
+}
+
+// WriteArchive writes a gzipped archive of the database to w: a header line,
+// then for each table a line with its schema followed by one JSON array per
+// row. Unlike a copy of the SQLite file, LoadArchive reads it into the
+// schema of whichever version loads it, matching columns by name.
+func (e *DBExporter) WriteArchive(w io.Writer, opts ArchiveOptions) (ArchiveResult, error) {
+	if !opts.Since.IsZero() && !opts.Until.IsZero() && !opts.Until.After(opts.Since) {
+		return nil, fmt.Errorf("archive range ends before it starts")
+	}
+
+	tables, err := e.archiveTables()
+	if err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(w)
+	enc := json.NewEncoder(gz)
+	header := archiveHeader{Format: archiveFormat, Version: archiveVersion, SchemaVersion: schemaVersion, CreatedAt: e.clock.Now().UTC(), Filtered: opts.filtered()}
+	if err := enc.Encode(header); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	result := ArchiveResult{}
+	for _, t := range tables {
+		n, err := e.archiveTable(enc, t, opts)
+		if err != nil {
+			return nil, err
+		}
+		result[t.Table] = n
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	return result, nil
+}
+
+// archiveTables returns the tables to archive with their columns. Exchange
+// rates and listings go first so rows referencing them load after them.
+func (e *DBExporter) archiveTables() ([]archiveTable, error) {
+	rows, err := e.db.Query("SELECT name, sql FROM sqlite_master WHERE type = 'table' AND sql LIKE 'CREATE TABLE%'")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []archiveTable
+	for rows.Next() {
+		var t archiveTable
+		if err := rows.Scan(&t.Table, &t.Schema); err != nil {
+			return nil, fmt.Errorf("failed to list tables: %w", err)
+		}
+		if !archiveSkipped(t.Table) {
+			tables = append(tables, t)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	rows.Close()
+
+	rank := map[string]int{"exchange_rates": 1, "listings": 2}
+	sort.Slice(tables, func(i, j int) bool {
+		ri, rj := rank[tables[i].Table], rank[tables[j].Table]
+		if ri == 0 {
+			ri = len(rank) + 1
+		}
+		if rj == 0 {
+			rj = len(rank) + 1
+		}
+		if ri != rj {
+			return ri < rj
+		}
+		return tables[i].Table < tables[j].Table
+	})
+
+	for i := range tables {
+		if tables[i].Columns, err = tableColumnTypes(e.db, tables[i].Table); err != nil {
+			return nil, err
+		}
+	}
+	return tables, nil
+}
+
+// tableColumnTypes returns the columns of a table with their declared types
+func tableColumnTypes(db *sql.DB, table string) ([]archiveColumn, error) {
+	rows, err := db.Query("SELECT name, type FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s columns: %w", table, err)
+	}
+	defer rows.Close()
+
+	var columns []archiveColumn
+	for rows.Next() {
+		var c archiveColumn
+		if err := rows.Scan(&c.Name, &c.Type); err != nil {
+			return nil, fmt.Errorf("failed to read %s columns: %w", table, err)
+		}
+		columns = append(columns, c)
+	}
+	return columns, rows.Err()
+}
+
+// archiveTable writes a table's schema line and rows, returning the number of
+// rows written
+func (e *DBExporter) archiveTable(enc *json.Encoder, t archiveTable, opts ArchiveOptions) (int64, error) {
+	if err := enc.Encode(t); err != nil {
+		return 0, fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	selected := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		// the unary plus keeps the stored value as it is, where the driver
+		// would parse the text of date columns into times
+		selected[i] = "+t." + quoteIdentifier(c.Name)
+		// descriptions are archived as text, leaving their storage to the loader
+		if t.Table == "listings" && c.Name == "description" {
+			selected[i] = "decompress(t.description)"
+		}
+	}
+	query := "SELECT " + strings.Join(selected, ", ") + " FROM " + quoteIdentifier(t.Table) + " t"
+	var args []interface{}
+	if opts.filtered() {
+		where, whereArgs := opts.listings().listingConditions(true)
+		listings := "SELECT l.hash FROM listings l"
+		if len(where) > 0 {
+			listings += " WHERE " + strings.Join(where, " AND ")
+		}
+		if t.Table == "listings" {
+			query += " WHERE t.hash IN (" + listings + ")"
+			args = whereArgs
+		} else if column, ok := archiveListingTables[t.Table]; ok {
+			query += " WHERE t." + column + " IN (" + listings + ")"
+			args = whereArgs
+		}
+	}
+	query += " ORDER BY t.rowid"
+
+	rows, err := e.db.Query(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query %s: %w", t.Table, err)
+	}
+	defer rows.Close()
+
+	raw := make([]interface{}, len(t.Columns))
+	ptrs := make([]interface{}, len(t.Columns))
+	for i := range raw {
+		ptrs[i] = &raw[i]
+	}
+	var n int64
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return n, fmt.Errorf("failed to scan %s row: %w", t.Table, err)
+		}
+		for i, v := range raw {
+			raw[i] = archiveValue(v)
+		}
+		if err := enc.Encode(raw); err != nil {
+			return n, fmt.Errorf("failed to write archive: %w", err)
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, fmt.Errorf("failed to read %s: %w", t.Table, err)
+	}
+	return n, nil
+}
+
+// archiveValue converts a scanned value to one that loads back the same,
+// base64 encoding BLOBs
+func archiveValue(v interface{}) interface{} {
+	if b, ok := v.([]byte); ok {
+		return archiveBlob{Blob: base64.StdEncoding.EncodeToString(b)}
+	}
+	return v
+}
+
+// LoadArchive reads an archive written by WriteArchive into the database,
+// which must not hold any listings yet. Rows keep their IDs. Tables and
+// columns the archive has but this version does not know are created from
+// the archived schema, so nothing is lost; the search index is rebuilt and
+// rows from older versions are migrated afterwards.
+func (e *DBExporter) LoadArchive(r io.Reader) (ArchiveResult, error) {
+	var stored int
+	if err := e.db.QueryRow("SELECT COUNT(*) FROM listings").Scan(&stored); err != nil {
+		return nil, fmt.Errorf("failed to count listings: %w", err)
+	}
+	if stored > 0 {
+		return nil, fmt.Errorf("database already holds %d listings, load the archive into a new one", stored)
+	}
+	// archives from versions that stored listings by their hash may hold
+	// several rows per fingerprint, made unique when they are migrated below
+	if _, err := e.db.Exec("DROP INDEX IF EXISTS idx_listings_fingerprint_unique"); err != nil {
+		return nil, fmt.Errorf("failed to prepare database: %w", err)
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not an archive: %w", err)
+	}
+	defer gz.Close()
+	lines := bufio.NewReader(gz)
+
+	var header archiveHeader
+	line, err := readArchiveLine(lines)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(line, &header); err != nil || header.Format != archiveFormat {
+		return nil, fmt.Errorf("not an archive")
+	}
+	if header.Version > archiveVersion {
+		return nil, fmt.Errorf("archive version %d is newer than this version reads (%d)", header.Version, archiveVersion)
+	}
+
+	result := ArchiveResult{}
+	var load *archiveLoad
+	finish := func() error {
+		if load == nil {
+			return nil
+		}
+		result[load.table] = load.rows
+		return load.commit()
+	}
+	defer func() {
+		if load != nil {
+			load.rollback()
+		}
+	}()
+
+	for {
+		line, err := readArchiveLine(lines)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if line[0] == '{' {
+			var t archiveTable
+			if err := json.Unmarshal(line, &t); err != nil {
+				return nil, fmt.Errorf("failed to read archive: %w", err)
+			}
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			load = nil
+			if load, err = e.startArchiveLoad(t); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if load == nil {
+			return nil, fmt.Errorf("failed to read archive: row before any table")
+		}
+		values, err := decodeArchiveRow(line)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s row: %w", load.table, err)
+		}
+		if err := load.insert(values); err != nil {
+			return nil, err
+		}
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	load = nil
+
+	if e.fts {
+		if _, err := e.db.Exec("DELETE FROM listings_fts"); err != nil {
+			return nil, fmt.Errorf("failed to rebuild search index: %w", err)
+		}
+		if _, err := e.db.Exec(`
+            INSERT INTO listings_fts (hash, title, description)
+            SELECT hash, title, COALESCE(decompress(description), '') FROM listings
+        `); err != nil {
+			return nil, fmt.Errorf("failed to rebuild search index: %w", err)
+		}
+	}
+	if err := migrate(e.db); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// readArchiveLine returns the next non-empty line of an archive
+func readArchiveLine(r *bufio.Reader) ([]byte, error) {
+	for {
+		line, err := r.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			return line, nil
+		}
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+	}
+}
+
+// decodeArchiveRow reverses archiveValue for a row
+func decodeArchiveRow(line []byte) ([]interface{}, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(raw))
+	for i, r := range raw {
+		switch {
+		case len(r) > 0 && r[0] == '{':
+			var b archiveBlob
+			if err := json.Unmarshal(r, &b); err != nil {
+				return nil, err
+			}
+			data, err := base64.StdEncoding.DecodeString(b.Blob)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = data
+		default:
+			dec := json.NewDecoder(bytes.NewReader(r))
+			dec.UseNumber()
+			var v interface{}
+			if err := dec.Decode(&v); err != nil {
+				return nil, err
+			}
+			if n, ok := v.(json.Number); ok {
+				if i, err := n.Int64(); err == nil {
+					v = i
+				} else if v, err = n.Float64(); err != nil {
+					return nil, err
+				}
+			}
+			values[i] = v
+		}
+	}
+	return values, nil
+}
+
+// archiveLoad inserts the rows of one table in a transaction
+type archiveLoad struct {
+	table   string
+	columns int
+	tx      *sql.Tx
+	stmt    *sql.Stmt
+	rows    int64
+}
+
+// startArchiveLoad prepares the database for a table's rows, creating the
+// table or adding columns missing from it
+func (e *DBExporter) startArchiveLoad(t archiveTable) (*archiveLoad, error) {
+	if !archiveIdentifier.MatchString(t.Table) {
+		return nil, fmt.Errorf("invalid table name %q in archive", t.Table)
+	}
+	for _, c := range t.Columns {
+		if !archiveIdentifier.MatchString(c.Name) {
+			return nil, fmt.Errorf("invalid column name %q in archive table %s", c.Name, t.Table)
+		}
+	}
+
+	var exists bool
+	if err := e.db.QueryRow("SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)", t.Table).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to inspect database: %w", err)
+	}
+	if !exists {
+		if !strings.HasPrefix(strings.ToUpper(t.Schema), "CREATE TABLE") {
+			return nil, fmt.Errorf("invalid schema for archive table %s", t.Table)
+		}
+		if _, err := e.db.Exec(t.Schema); err != nil {
+			return nil, fmt.Errorf("failed to create table %s: %w", t.Table, err)
+		}
+	}
+
+	columns := make([]string, len(t.Columns))
+	values := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		if err := addColumnIfMissing(e.db, t.Table, c.Name, c.Type); err != nil {
+			return nil, err
+		}
+		columns[i] = quoteIdentifier(c.Name)
+		values[i] = "?"
+	}
+
+	tx, err := e.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	stmt, err := tx.Prepare("INSERT INTO " + quoteIdentifier(t.Table) + " (" + strings.Join(columns, ", ") + ") VALUES (" + strings.Join(values, ", ") + ")")
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to prepare %s insert: %w", t.Table, err)
+	}
+	return &archiveLoad{table: t.Table, columns: len(t.Columns), tx: tx, stmt: stmt}, nil
+}
+
+func (l *archiveLoad) insert(values []interface{}) error {
+	if len(values) != l.columns {
+		return fmt.Errorf("failed to read %s row: %d values for %d columns", l.table, len(values), l.columns)
+	}
+	if _, err := l.stmt.Exec(values...); err != nil {
+		return fmt.Errorf("failed to load %s row: %w", l.table, err)
+	}
+	l.rows++
+	return nil
+}
+
+func (l *archiveLoad) commit() error {
+	l.stmt.Close()
+	if err := l.tx.Commit(); err != nil {
+		return fmt.Errorf("failed to load %s: %w", l.table, err)
+	}
+	return nil
+}
+
+func (l *archiveLoad) rollback() {
+	l.stmt.Close()
+	l.tx.Rollback()
+}
+
+// quoteIdentifier quotes a table or column name for SQL
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,111 @@
+package exporter
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pinkbike-scraper/pkg/currency"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	exp := newDumpTestDB(t)
+	_, err := exp.RecordExchangeRate(currency.Rate{Base: "CAD", Quote: "USD", Value: 0.73, Source: "test", FetchedAt: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)})
+	require.NoError(t, err)
+	_, err = exp.db.Exec("INSERT INTO seller_listing_counts (username, recorded_on, active_listings) VALUES ('rider', '2024-06-01', 2)")
+	require.NoError(t, err)
+
+	var archive bytes.Buffer
+	written, err := exp.WriteArchive(&archive, ArchiveOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), written["listings"])
+	assert.Equal(t, int64(2), written["price_history"])
+	assert.NotContains(t, written, "pending_exports")
+
+	loaded := newTestDBExporter(t, nil)
+	result, err := loaded.LoadArchive(&archive)
+	require.NoError(t, err)
+	assert.Equal(t, written, result)
+
+	l, err := loaded.FindListing("https://pinkbike.com/1")
+	require.NoError(t, err)
+	assert.Equal(t, "2021 Evil Wreckoning", l.Title)
+	assert.Equal(t, "Coil shock, fresh bearings", l.Details.Description)
+
+	var recordedOn, firstSeen string
+	require.NoError(t, loaded.db.QueryRow("SELECT CAST(recorded_on AS TEXT) FROM seller_listing_counts").Scan(&recordedOn))
+	assert.Equal(t, "2024-06-01", recordedOn, "dates are stored as they were")
+	require.NoError(t, loaded.db.QueryRow("SELECT CAST(first_seen AS TEXT) FROM listings WHERE manufacturer = 'Evil'").Scan(&firstSeen))
+	assert.Equal(t, "2024-05-01 08:00:00", firstSeen)
+	var compressed string
+	require.NoError(t, loaded.db.QueryRow("SELECT typeof(description) FROM listings WHERE manufacturer = 'Evil'").Scan(&compressed))
+	assert.Equal(t, "blob", compressed, "descriptions are compressed again")
+
+	_, err = loaded.LoadArchive(bytes.NewReader(archive.Bytes()))
+	assert.ErrorContains(t, err, "already holds 2 listings")
+}
+
+func TestArchiveFilters(t *testing.T) {
+	exp := newDumpTestDB(t)
+
+	var archive bytes.Buffer
+	written, err := exp.WriteArchive(&archive, ArchiveOptions{Manufacturer: "yeti"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), written["listings"])
+	assert.Equal(t, int64(1), written["price_history"], "price history follows its listing")
+
+	_, err = exp.WriteArchive(&bytes.Buffer{}, ArchiveOptions{Since: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), Until: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)})
+	assert.ErrorContains(t, err, "ends before it starts")
+}
+
+func TestLoadArchiveFromNewerVersion(t *testing.T) {
+	exp := newDumpTestDB(t)
+	_, err := exp.db.Exec("ALTER TABLE listings ADD COLUMN paint_colour TEXT")
+	require.NoError(t, err)
+	_, err = exp.db.Exec("UPDATE listings SET paint_colour = 'raw'")
+	require.NoError(t, err)
+	_, err = exp.db.Exec("CREATE TABLE listing_notes (listing_hash TEXT, note TEXT)")
+	require.NoError(t, err)
+	_, err = exp.db.Exec("INSERT INTO listing_notes VALUES ('h', 'call after 5')")
+	require.NoError(t, err)
+
+	var archive bytes.Buffer
+	_, err = exp.WriteArchive(&archive, ArchiveOptions{})
+	require.NoError(t, err)
+
+	loaded, err := NewDBExporter(filepath.Join(t.TempDir(), "loaded.db"), nil, DefaultDBOptions())
+	require.NoError(t, err)
+	defer loaded.Close()
+	result, err := loaded.LoadArchive(&archive)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result["listing_notes"])
+
+	var colour, note string
+	require.NoError(t, loaded.db.QueryRow("SELECT paint_colour FROM listings LIMIT 1").Scan(&colour))
+	assert.Equal(t, "raw", colour, "unknown columns are added")
+	require.NoError(t, loaded.db.QueryRow("SELECT note FROM listing_notes").Scan(&note))
+	assert.Equal(t, "call after 5", note, "unknown tables are created")
+}
+
+func TestLoadArchiveRejectsOtherFiles(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+
+	_, err := exp.LoadArchive(strings.NewReader("title,price\n"))
+	assert.ErrorContains(t, err, "not an archive")
+
+	var other bytes.Buffer
+	gz := gzip.NewWriter(&other)
+	w := bufio.NewWriter(gz)
+	w.WriteString(`{"format":"something else"}` + "\n")
+	require.NoError(t, w.Flush())
+	require.NoError(t, gz.Close())
+	_, err = exp.LoadArchive(&other)
+	assert.ErrorContains(t, err, "not an archive")
+}
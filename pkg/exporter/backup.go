@@ -0,0 +1,176 @@
+package exporter
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"pinkbike-scraper/pkg/clock"
+)
+
+// BackupPath names a backup of the database at dbPath taken at the given
+// time, such as backups/listings-20240501-080000.db. The label, when set,
+// says why the backup was taken; compressed backups end in .gz.
+func BackupPath(dir, dbPath, label string, at time.Time, compress bool) string {
+	base := strings.TrimSuffix(filepath.Base(dbPath), filepath.Ext(dbPath))
+	if label != "" {
+		base += "-" + label
+	}
+	name := base + "-" + at.UTC().Format("20060102-150405") + ".db"
+	if compress {
+		name += ".gz"
+	}
+	if dir == "" {
+		dir = filepath.Dir(dbPath)
+	}
+	return filepath.Join(dir, name)
+}
+
+// backupBeforeMigrating backs the database up when it needs migrating, so a
+// migration that goes wrong can be undone by restoring the backup
+func backupBeforeMigrating(db *sql.DB, dbPath string, clk clock.Clock) error {
+	needed, err := needsMigration(db)
+	if err != nil || !needed {
+		return err
+	}
+
+	path := BackupPath("", dbPath, "pre-migration", clk.Now(), true)
+	if err := backupDB(db, path, true); err != nil {
+		return fmt.Errorf("failed to back up database before migrating it: %w", err)
+	}
+	return nil
+}
+
+// Backup copies the database to path with SQLite's online backup API, so it
+// is consistent even while a scrape is writing. The copy is gzipped when
+// compress is set. A failed backup leaves nothing at path.
+func (e *DBExporter) Backup(path string, compress bool) error {
+	return backupDB(e.db, path, compress)
+}
+
+func backupDB(db *sql.DB, path string, compress bool) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	defer os.Remove(tmp)
+	if err := copyDB(db, tmp); err != nil {
+		return err
+	}
+
+	if !compress {
+		if err := os.Rename(tmp, path); err != nil {
+			return fmt.Errorf("failed to write backup: %w", err)
+		}
+		return nil
+	}
+
+	gzTmp := path + ".gz.tmp"
+	defer os.Remove(gzTmp)
+	if err := gzipFile(tmp, gzTmp); err != nil {
+		return fmt.Errorf("failed to compress backup: %w", err)
+	}
+	if err := os.Rename(gzTmp, path); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	return nil
+}
+
+// copyDB copies every page of db's main database into a new database at path
+func copyDB(db *sql.DB, path string) error {
+	ctx := context.Background()
+	dest, err := sql.Open(sqliteDriver, path)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer dest.Close()
+
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer destConn.Close()
+	srcConn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer srcConn.Close()
+
+	return destConn.Raw(func(destDriver interface{}) error {
+		return srcConn.Raw(func(srcDriver interface{}) error {
+			backup, err := destDriver.(*sqlite3.SQLiteConn).Backup("main", srcDriver.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return fmt.Errorf("failed to start backup: %w", err)
+			}
+			if _, err := backup.Step(-1); err != nil {
+				backup.Finish()
+				return fmt.Errorf("failed to back up database: %w", err)
+			}
+			if err := backup.Finish(); err != nil {
+				return fmt.Errorf("failed to finish backup: %w", err)
+			}
+			return nil
+		})
+	})
+}
+
+func gzipFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	w := gzip.NewWriter(out)
+	if _, err := io.Copy(w, in); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// Vacuum rebuilds the database file to reclaim the space left by deleted
+// rows, such as compacted price history, returning its size in bytes before
+// and after. It needs free disk space about the size of the database.
+func (e *DBExporter) Vacuum() (before, after int64, err error) {
+	if before, err = dbSize(e.db); err != nil {
+		return 0, 0, err
+	}
+	if _, err := e.db.Exec("VACUUM"); err != nil {
+		return 0, 0, fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	// fold the write-ahead log the vacuum went through back into the database
+	if _, err := e.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return 0, 0, fmt.Errorf("failed to checkpoint database: %w", err)
+	}
+	if after, err = dbSize(e.db); err != nil {
+		return 0, 0, err
+	}
+	return before, after, nil
+}
+
+func dbSize(db *sql.DB) (int64, error) {
+	var pages, pageSize int64
+	if err := db.QueryRow("PRAGMA page_count").Scan(&pages); err != nil {
+		return 0, fmt.Errorf("failed to read database size: %w", err)
+	}
+	if err := db.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		return 0, fmt.Errorf("failed to read database size: %w", err)
+	}
+	return pages * pageSize, nil
+}
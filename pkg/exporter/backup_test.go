@@ -0,0 +1,140 @@
+package exporter
+
+import (
+	"compress/gzip"
+	"database/sql"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/listing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countBackupListings opens a backup and counts its listings
+func countBackupListings(t *testing.T, path string) int {
+	t.Helper()
+
+	db, err := sql.Open(sqliteDriver, path)
+	require.NoError(t, err)
+	defer db.Close()
+	var n int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM listings").Scan(&n))
+	return n
+}
+
+func TestBackupPath(t *testing.T) {
+	at := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	assert.Equal(t, filepath.Join("backups", "listings-20240501-080000.db"), BackupPath("backups", "listings.db", "", at, false))
+	assert.Equal(t, filepath.Join("data", "listings-pre-migration-20240501-080000.db.gz"), BackupPath("", "data/listings.db", "pre-migration", at, true))
+}
+
+func TestBackup(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	require.NoError(t, exp.Export([]listing.Listing{{Title: "2021 Evil Wreckoning", Price: "3900", URL: "https://pinkbike.com/1"}}))
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "plain", "listings.db")
+	require.NoError(t, exp.Backup(path, false))
+	assert.Equal(t, 1, countBackupListings(t, path))
+
+	gzPath := filepath.Join(dir, "listings.db.gz")
+	require.NoError(t, exp.Backup(gzPath, true))
+	file, err := os.Open(gzPath)
+	require.NoError(t, err)
+	defer file.Close()
+	r, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	unzipped := filepath.Join(dir, "unzipped.db")
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(unzipped, data, 0644))
+	assert.Equal(t, 1, countBackupListings(t, unzipped))
+
+	leftovers, err := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	require.NoError(t, err)
+	assert.Empty(t, leftovers)
+}
+
+func TestVacuumReclaimsSpace(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	_, err := exp.db.Exec(`
+        WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 200)
+        INSERT INTO listings (title, description, hash) SELECT 'bike', randomblob(4000), i FROM n
+    `)
+	require.NoError(t, err)
+	_, err = exp.db.Exec("DELETE FROM listings")
+	require.NoError(t, err)
+
+	before, after, err := exp.Vacuum()
+	require.NoError(t, err)
+	assert.Less(t, after, before)
+}
+
+func TestMigrationBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "listings.db")
+	opts := DefaultDBOptions()
+	opts.Clock = clock.Fixed(time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC))
+	backups := func() []string {
+		t.Helper()
+		matches, err := filepath.Glob(filepath.Join(dir, "listings-pre-migration-*.db.gz"))
+		require.NoError(t, err)
+		return matches
+	}
+
+	exp, err := NewDBExporter(path, nil, opts)
+	require.NoError(t, err)
+	require.NoError(t, exp.Close())
+	assert.Empty(t, backups(), "a new database has nothing to back up")
+
+	// a database from before schema versions were recorded
+	db, err := sql.Open(sqliteDriver, path)
+	require.NoError(t, err)
+	_, err = db.Exec("PRAGMA user_version = 0")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	exp, err = NewDBExporter(path, nil, opts)
+	require.NoError(t, err)
+	require.NoError(t, exp.Close())
+	assert.Equal(t, []string{filepath.Join(dir, "listings-pre-migration-20240501-080000.db.gz")}, backups())
+
+	opts.Clock = clock.Fixed(time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC))
+	exp, err = NewDBExporter(path, nil, opts)
+	require.NoError(t, err)
+	require.NoError(t, exp.Close())
+	assert.Len(t, backups(), 1, "a migrated database is not backed up again")
+}
+
+func TestInspectSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "listings.db")
+	status, err := InspectSchema(path)
+	require.NoError(t, err)
+	assert.False(t, status.Exists)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "inspecting does not create the database")
+
+	exp, err := NewDBExporter(path, nil, DefaultDBOptions())
+	require.NoError(t, err)
+	require.NoError(t, exp.Close())
+	status, err = InspectSchema(path)
+	require.NoError(t, err)
+	assert.Equal(t, SchemaStatus{Exists: true, Version: schemaVersion, Current: schemaVersion}, status)
+	assert.False(t, status.Outdated())
+
+	db, err := sql.Open(sqliteDriver, path)
+	require.NoError(t, err)
+	_, err = db.Exec("PRAGMA user_version = 3")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+	status, err = InspectSchema(path)
+	require.NoError(t, err)
+	assert.True(t, status.Outdated())
+	assert.False(t, status.Newer())
+}
@@ -0,0 +1,98 @@
+package exporter
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pinkbike-scraper/pkg/cache"
+)
+
+// CachedValue returns the value stored under key by CacheValue, stale or not
+func (e *DBExporter) CachedValue(key string) (cache.Entry, bool, error) {
+	var value []byte
+	var storedAt, expiresAt sql.NullString
+	err := e.db.QueryRow("SELECT value, stored_at, expires_at FROM cache WHERE key = ?", key).Scan(&value, &storedAt, &expiresAt)
+	if err == sql.ErrNoRows {
+		return cache.Entry{}, false, nil
+	}
+	if err != nil {
+		return cache.Entry{}, false, fmt.Errorf("failed to read cache: %w", err)
+	}
+
+	entry := cache.Entry{Value: value}
+	if storedAt.Valid {
+		if entry.StoredAt, err = parseSQLiteTime(storedAt.String); err != nil {
+			return cache.Entry{}, false, fmt.Errorf("failed to read cache: %w", err)
+		}
+	}
+	if expiresAt.Valid {
+		if entry.ExpiresAt, err = parseSQLiteTime(expiresAt.String); err != nil {
+			return cache.Entry{}, false, fmt.Errorf("failed to read cache: %w", err)
+		}
+	}
+	return entry, true, nil
+}
+
+// CacheValue stores entry under key, replacing what was cached before
+func (e *DBExporter) CacheValue(key string, entry cache.Entry) error {
+	_, err := e.db.Exec(`
+        INSERT INTO cache (key, value, stored_at, expires_at)
+        VALUES (?, ?, ?, ?)
+        ON CONFLICT(key) DO UPDATE SET
+            value = excluded.value,
+            stored_at = excluded.stored_at,
+            expires_at = excluded.expires_at
+    `, key, entry.Value, cacheTime(entry.StoredAt), cacheTime(entry.ExpiresAt))
+	if err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	return nil
+}
+
+// ClearCache removes the cached values whose keys start with prefix, every
+// value when it is empty, and returns how many were removed
+func (e *DBExporter) ClearCache(prefix string) (int64, error) {
+	res, err := e.db.Exec("DELETE FROM cache WHERE substr(key, 1, length(?)) = ?", prefix, prefix)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear cache: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// cacheTime stores zero times as NULL
+func cacheTime(t time.Time) sql.NullString {
+	if t.IsZero() {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.UTC().Format(sqliteTimeFormat), Valid: true}
+}
+
+// migrateGeocodes moves places geocoded into the geocodes table, which the
+// cache table replaced, into the cache. Places the geocoder did not find go
+// stale after 30 days, as the geocoder caches them.
+func migrateGeocodes(db *sql.DB) error {
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'geocodes')").Scan(&exists); err != nil {
+		return fmt.Errorf("failed to inspect database: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+
+	if _, err := db.Exec(`
+        INSERT OR IGNORE INTO cache (key, value, stored_at, expires_at)
+        SELECT 'geocode/' || place,
+               json_object('lat', COALESCE(latitude, 0), 'lon', COALESCE(longitude, 0),
+                           'found', CASE WHEN found THEN json('true') ELSE json('false') END),
+               geocoded_at,
+               CASE WHEN found THEN NULL ELSE datetime(geocoded_at, '+30 days') END
+        FROM geocodes
+    `); err != nil {
+		return fmt.Errorf("failed to migrate geocoded places: %w", err)
+	}
+	if _, err := db.Exec("DROP TABLE geocodes"); err != nil {
+		return fmt.Errorf("failed to migrate geocoded places: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"pinkbike-scraper/pkg/cache"
+	"pinkbike-scraper/pkg/geo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheRoundTrip(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+
+	_, cached, err := exp.CachedValue("rate/CAD/USD")
+	require.NoError(t, err)
+	assert.False(t, cached)
+
+	stored := time.Date(2024, 9, 19, 12, 0, 0, 0, time.UTC)
+	entry := cache.Entry{Value: []byte(`{"value":0.73}`), StoredAt: stored, ExpiresAt: stored.Add(12 * time.Hour)}
+	require.NoError(t, exp.CacheValue("rate/CAD/USD", entry))
+	require.NoError(t, exp.CacheValue("rate/CAD/USD/2023-05-01", cache.Entry{Value: []byte(`{"value":0.74}`), StoredAt: stored}))
+	require.NoError(t, exp.CacheValue("geocode/calgary, ab", cache.Entry{Value: []byte(`{}`), StoredAt: stored}))
+
+	got, cached, err := exp.CachedValue("rate/CAD/USD")
+	require.NoError(t, err)
+	assert.True(t, cached)
+	assert.Equal(t, entry, got)
+	got, _, err = exp.CachedValue("rate/CAD/USD/2023-05-01")
+	require.NoError(t, err)
+	assert.True(t, got.ExpiresAt.IsZero())
+
+	cleared, err := exp.ClearCache("rate/")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), cleared)
+	_, cached, err = exp.CachedValue("geocode/calgary, ab")
+	require.NoError(t, err)
+	assert.True(t, cached)
+}
+
+func TestMigrateGeocodes(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	_, err := exp.db.Exec(`
+        CREATE TABLE geocodes (place TEXT PRIMARY KEY, latitude REAL, longitude REAL, found INTEGER, geocoded_at DATETIME);
+        INSERT INTO geocodes VALUES
+            ('calgary, ab', 51.0447, -114.0719, 1, '2024-09-19 12:00:00'),
+            ('atlantis', NULL, NULL, 0, '2024-09-19 12:00:00');
+    `)
+	require.NoError(t, err)
+
+	require.NoError(t, migrate(exp.db))
+
+	geocoder := geo.Cached(geo.Places{}, &cache.Cache{Store: exp, Offline: true})
+	p, err := geocoder.Geocode("Calgary, AB")
+	require.NoError(t, err)
+	assert.Equal(t, calgary, p)
+	_, err = geocoder.Geocode("Atlantis")
+	assert.ErrorIs(t, err, geo.ErrNotFound)
+
+	entry, _, err := exp.CachedValue("geocode/atlantis")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 10, 19, 12, 0, 0, 0, time.UTC), entry.ExpiresAt)
+
+	var exists bool
+	require.NoError(t, exp.db.QueryRow("SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE name = 'geocodes')").Scan(&exists))
+	assert.False(t, exists)
+}
@@ -0,0 +1,81 @@
+package exporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"io"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriver is go-sqlite3 with the compress and decompress SQL functions
+// registered, so queries can read compressed descriptions transparently
+const sqliteDriver = "sqlite3_listings"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc("compress", compressSQL, true); err != nil {
+				return err
+			}
+			return conn.RegisterFunc("decompress", decompressSQL, true)
+		},
+	})
+}
+
+// compressText gzips long text such as listing descriptions. Empty text is
+// stored as-is so listings scraped without a description stay distinguishable
+// from ones never scraped.
+func compressText(s string) interface{} {
+	if s == "" {
+		return s
+	}
+
+	var buf bytes.Buffer
+	w, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	w.Write([]byte(s))
+	w.Close()
+	return buf.Bytes()
+}
+
+// decompressText reverses compressText. Values that are not gzip data, such as
+// rows written before compression was introduced, are returned unchanged.
+func decompressText(b []byte) (string, error) {
+	if len(b) < 2 || b[0] != 0x1f || b[1] != 0x8b {
+		return string(b), nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func compressSQL(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return compressText(v)
+	case []byte:
+		if len(v) >= 2 && v[0] == 0x1f && v[1] == 0x8b {
+			return v
+		}
+		return compressText(string(v))
+	}
+	return v
+}
+
+func decompressSQL(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case []byte:
+		return decompressText(v)
+	}
+	return v, nil
+}
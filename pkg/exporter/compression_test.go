@@ -0,0 +1,49 @@
+package exporter
+
+import (
+	"testing"
+
+	"pinkbike-scraper/pkg/listing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressTextRoundTrip(t *testing.T) {
+	description := "Coil shock, new bearings. Always garage kept. " + string(make([]byte, 500))
+
+	compressed, ok := compressText(description).([]byte)
+	require.True(t, ok)
+	assert.Less(t, len(compressed), len(description))
+
+	out, err := decompressText(compressed)
+	require.NoError(t, err)
+	assert.Equal(t, description, out)
+
+	assert.Equal(t, "", compressText(""))
+
+	plain, err := decompressText([]byte("written before compression"))
+	require.NoError(t, err)
+	assert.Equal(t, "written before compression", plain)
+}
+
+func TestDescriptionsStoredCompressed(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+
+	l := listing.Listing{Title: "2021 Evil Wreckoning", Price: "3900", Details: listing.ListingDetails{Description: "Coil shock, new bearings"}}
+	require.NoError(t, exp.Export([]listing.Listing{l}))
+
+	var storedType, description string
+	require.NoError(t, exp.db.QueryRow("SELECT typeof(description), decompress(description) FROM listings").Scan(&storedType, &description))
+	assert.Equal(t, "blob", storedType)
+	assert.Equal(t, l.Details.Description, description)
+
+	// rows written by older versions are compressed by the migration
+	_, err := exp.db.Exec("UPDATE listings SET description = 'plain text description'")
+	require.NoError(t, err)
+	require.NoError(t, migrate(exp.db))
+
+	require.NoError(t, exp.db.QueryRow("SELECT typeof(description), decompress(description) FROM listings").Scan(&storedType, &description))
+	assert.Equal(t, "blob", storedType)
+	assert.Equal(t, "plain text description", description)
+}
@@ -0,0 +1,79 @@
+package exporter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"pinkbike-scraper/pkg/currency"
+	"pinkbike-scraper/pkg/listing"
+)
+
+// ConvertingExporter wraps an exporter to report prices in another currency
+// than the USD they are stored in. Each price is converted from the currency
+// it was listed in as it is exported, so stored prices stay tied to the rate
+// they were scraped with.
+type ConvertingExporter struct {
+	Exporter
+	reportCurrency string
+	converter      *currency.Converter
+}
+
+func NewConvertingExporter(exp Exporter, reportCurrency string, converter *currency.Converter) *ConvertingExporter {
+	return &ConvertingExporter{Exporter: exp, reportCurrency: reportCurrency, converter: converter}
+}
+
+func (c *ConvertingExporter) Export(listings []listing.Listing) error {
+	converted := make([]listing.Listing, len(listings))
+	for i, l := range listings {
+		l, err := c.convert(l)
+		if err != nil {
+			return fmt.Errorf("failed to convert prices to %s: %w", c.reportCurrency, err)
+		}
+		converted[i] = l
+	}
+	return c.Exporter.Export(converted)
+}
+
+// convert sets the listing's price to its listed price in the report
+// currency. Listings stored before listed prices were kept are converted
+// from their price, which is in USD only for prices listed in CAD, since no
+// other currency was converted. A price that cannot be read is cleared
+// rather than shown in the wrong currency.
+func (c *ConvertingExporter) convert(l listing.Listing) (listing.Listing, error) {
+	if l.Hash == "" {
+		l.Hash = l.ComputeHash()
+	}
+
+	amount, from := l.ListedPrice, l.Currency
+	if amount == "" {
+		amount = l.Price
+		if from == "CAD" {
+			from = currency.USD
+		}
+	}
+	l.PriceCurrency = c.reportCurrency
+	if amount == "" {
+		return l, nil
+	}
+
+	value, err := strconv.ParseFloat(amount, 64)
+	if err != nil {
+		l.Price = ""
+		return l, nil
+	}
+	value, err = c.converter.Convert(value, from, c.reportCurrency)
+	if err != nil {
+		return l, err
+	}
+	l.Price = strconv.FormatFloat(math.Round(value), 'f', 0, 64)
+	return l, nil
+}
+
+// Files returns the files the wrapped exporter wrote, if it writes any
+func (c *ConvertingExporter) Files() []string {
+	if f, ok := c.Exporter.(FileExporter); ok {
+		return f.Files()
+	}
+	return nil
+}
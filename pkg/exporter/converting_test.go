@@ -0,0 +1,35 @@
+package exporter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pinkbike-scraper/pkg/currency"
+	"pinkbike-scraper/pkg/listing"
+)
+
+func TestConvertingExporter(t *testing.T) {
+	sink := &flakyExporter{}
+	exp := NewConvertingExporter(sink, "CAD", currency.NewConverter(currency.FixedRate{Value: 0.8}))
+
+	listed := listing.Listing{Title: "2018 Commencal Meta AM", Price: "2000", Currency: "CAD", ListedPrice: "2500"}
+	usd := listing.Listing{Title: "2021 Evil Wreckoning", Price: "4000", Currency: "USD", ListedPrice: "4000"}
+	legacy := listing.Listing{Title: "2019 Norco Sight", Price: "1600", Currency: "CAD"}
+	trade := listing.Listing{Title: "2020 Kona Process", Currency: "CAD"}
+	require.NoError(t, exp.Export([]listing.Listing{listed, usd, legacy, trade}))
+
+	require.Len(t, sink.batches, 1)
+	got := sink.batches[0]
+	assert.Equal(t, "2500", got[0].Price, "the listed price is reported as it is")
+	assert.Equal(t, "CAD", got[0].PriceCurrency)
+	assert.Equal(t, listed.ComputeHash(), got[0].Hash)
+	assert.Equal(t, "5000", got[1].Price)
+	assert.Equal(t, "2000", got[2].Price, "listings without a listed price are converted from USD")
+	assert.Empty(t, got[3].Price)
+	assert.Equal(t, "2000", listed.Price, "the original is left untouched")
+
+	euro := listing.Listing{Title: "2022 Canyon Spectral", Price: "3500", Currency: "EUR", ListedPrice: "3500"}
+	assert.Error(t, exp.Export([]listing.Listing{euro}), "the fixed rate only knows CAD and USD")
+}
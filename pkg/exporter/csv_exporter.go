@@ -2,20 +2,40 @@ package exporter
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"pinkbike-scraper/pkg/currency"
+	"pinkbike-scraper/pkg/geo"
 	"pinkbike-scraper/pkg/listing"
+	"strconv"
 )
 
+var csvHeaders = []string{"Title", "Year", "Manufacturer", "Model", "Price", "Currency", "Condition", "Frame Size", "Wheel Size", "Frame Material", "Front Travel", "Rear Travel", "Needs Review", "URL", "Hash", "Seller Type", "Original Post Date", "Restrictions", "Description", "Electric", "Motor", "Battery (Wh)", "Category", "Listed Price", "Price Currency", "Location", "Latitude", "Longitude", "Distance (km)"}
+
+// CSVOptions controls how the CSV exporter writes its files
+type CSVOptions struct {
+	// Append merges new listings into existing files keyed on hash instead of truncating them
+	Append bool
+	// Combined writes every listing to the good listings path, relying on the
+	// Needs Review column to tell suspect listings apart
+	Combined bool
+	// Near is where the Distance column measures from, left empty when zero
+	Near geo.Point
+}
+
 type CSVExporter struct {
 	goodListingsPath    string
 	suspectListingsPath string
+	opts                CSVOptions
 }
 
-func NewCSVExporter(goodPath, suspectPath string) *CSVExporter {
+func NewCSVExporter(goodPath, suspectPath string, opts CSVOptions) *CSVExporter {
 	return &CSVExporter{
 		goodListingsPath:    goodPath,
 		suspectListingsPath: suspectPath,
+		opts:                opts,
 	}
 }
 
@@ -23,59 +43,172 @@ func (e *CSVExporter) Close() error {
 	return nil
 }
 
+// Files returns the paths the exporter writes
+func (e *CSVExporter) Files() []string {
+	if e.opts.Combined {
+		return []string{e.goodListingsPath}
+	}
+	return []string{e.goodListingsPath, e.suspectListingsPath}
+}
+
 func (e *CSVExporter) Export(listings []listing.Listing) error {
-	if err := e.writeToFile(listings); err != nil {
+	if e.opts.Combined {
+		if err := e.writeToFile(e.goodListingsPath, listings); err != nil {
+			return fmt.Errorf("failed to write to CSV: %w", err)
+		}
+		return nil
+	}
+
+	var good, suspect []listing.Listing
+	for _, l := range listings {
+		if len(l.NeedsReview) > 0 {
+			suspect = append(suspect, l)
+			continue
+		}
+		good = append(good, l)
+	}
+
+	if err := e.writeToFile(e.goodListingsPath, good); err != nil {
 		return fmt.Errorf("failed to write to CSV: %w", err)
 	}
+	if err := e.writeToFile(e.suspectListingsPath, suspect); err != nil {
+		return fmt.Errorf("failed to write suspect listings to CSV: %w", err)
+	}
 	return nil
 }
 
-func (e *CSVExporter) writeToFile(listings []listing.Listing) error {
-	goodFile, err := os.Create(e.goodListingsPath)
+func (e *CSVExporter) writeToFile(path string, listings []listing.Listing) error {
+	var rows [][]string
+	if e.opts.Append {
+		existing, err := readCSVRows(path)
+		if err != nil {
+			return err
+		}
+		rows = existing
+	}
+
+	rows = mergeRows(rows, listings, e.opts.Near)
+
+	file, err := os.Create(path)
 	if err != nil {
 		return err
 	}
-	defer goodFile.Close()
+	defer file.Close()
 
-	suspectFile, err := os.Create(e.goodListingsPath)
-	if err != nil {
+	writer := csv.NewWriter(file)
+	if err := writer.Write(csvHeaders); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(rows); err != nil {
 		return err
 	}
-	defer suspectFile.Close()
 
-	goodWriter := csv.NewWriter(goodFile)
-	defer goodWriter.Flush()
+	return file.Close()
+}
+
+// mergeRows replaces rows whose hash matches a listing and appends the rest
+func mergeRows(rows [][]string, listings []listing.Listing, near geo.Point) [][]string {
+	hashColumn := columnIndex(csvHeaders, "Hash")
+
+	byHash := make(map[string]int, len(rows))
+	for i, row := range rows {
+		if hash := row[hashColumn]; hash != "" {
+			byHash[hash] = i
+		}
+	}
+
+	for _, l := range listings {
+		row := csvRow(l, near)
+		if i, ok := byHash[row[hashColumn]]; ok {
+			rows[i] = row
+			continue
+		}
+		byHash[row[hashColumn]] = len(rows)
+		rows = append(rows, row)
+	}
+
+	return rows
+}
 
-	suspectWriter := csv.NewWriter(suspectFile)
-	defer suspectWriter.Flush()
+// readCSVRows loads an existing export and lays its rows out in the current
+// column order, matching columns by header name
+func readCSVRows(path string) ([][]string, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
 
-	csvHeaders := []string{"Title", "Year", "Manufacturer", "Model", "Price", "Currency", "Condition", "Frame Size", "Wheel Size", "Frame Material", "Front Travel", "Rear Travel", "Needs Review"}
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
 
-	err = goodWriter.Write(csvHeaders)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("could not read existing CSV %s: %w", path, err)
 	}
 
-	err = suspectWriter.Write(csvHeaders)
+	records, err := reader.ReadAll()
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("could not read existing CSV %s: %w", path, err)
 	}
 
-	for _, l := range listings {
-		row := []string{l.Title, l.Year, l.Manufacturer, l.Model, l.Price, l.Currency, l.Condition, l.FrameSize, l.WheelSize, l.FrameMaterial, l.FrontTravel, l.RearTravel, l.NeedsReview}
-		if l.NeedsReview != "" {
-			err = suspectWriter.Write(row)
-			if err != nil {
-				return err
+	rows := make([][]string, 0, len(records))
+	for _, record := range records {
+		row := make([]string, len(csvHeaders))
+		for i, name := range csvHeaders {
+			if j := columnIndex(header, name); j >= 0 && j < len(record) {
+				row[i] = record[j]
 			}
-			continue
 		}
+		rows = append(rows, row)
+	}
 
-		err = goodWriter.Write(row)
-		if err != nil {
-			return err
+	return rows, nil
+}
+
+func columnIndex(header []string, name string) int {
+	for i, h := range header {
+		if h == name {
+			return i
 		}
 	}
+	return -1
+}
 
-	return nil
+func csvRow(l listing.Listing, near geo.Point) []string {
+	hash := l.Hash
+	if hash == "" {
+		hash = l.ComputeHash()
+	}
+
+	postDate := ""
+	if !l.Details.OriginalPostDate.IsZero() {
+		postDate = l.Details.OriginalPostDate.Format("2006-01-02")
+	}
+
+	electric, battery := "", ""
+	if l.IsElectric {
+		electric = "yes"
+	}
+	if l.Details.BatteryWh > 0 {
+		battery = strconv.Itoa(l.Details.BatteryWh)
+	}
+
+	priceCurrency := l.PriceCurrency
+	if priceCurrency == "" {
+		priceCurrency = currency.USD
+	}
+
+	lat, lon := "", ""
+	if !l.Coordinates.IsZero() {
+		lat, lon = strconv.FormatFloat(l.Coordinates.Lat, 'f', -1, 64), strconv.FormatFloat(l.Coordinates.Lon, 'f', -1, 64)
+	}
+
+	return []string{l.Title, l.Year, l.Manufacturer, l.Model, l.Price, l.Currency, l.Condition, l.FrameSize, l.WheelSize, l.FrameMaterial, l.FrontTravel, l.RearTravel, l.NeedsReview.String(), l.URL, hash, string(l.Details.SellerType), postDate, l.Details.Restrictions, l.Details.Description, electric, l.Details.Motor, battery, l.Category, l.ListedPrice, priceCurrency, l.Location, lat, lon, distance(l, near)}
 }
@@ -0,0 +1,68 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"pinkbike-scraper/pkg/listing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readTestCSV(t *testing.T, path string) [][]string {
+	t.Helper()
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	require.NoError(t, err)
+	return records
+}
+
+func TestCSVExporterSplitsSuspectListings(t *testing.T) {
+	dir := t.TempDir()
+	good, suspect := filepath.Join(dir, "good.csv"), filepath.Join(dir, "suspect.csv")
+
+	exp := NewCSVExporter(good, suspect, CSVOptions{})
+	require.NoError(t, exp.Export([]listing.Listing{
+		{Title: "2021 Evil Wreckoning", Price: "3900"},
+		{Title: "Mystery bike", Price: "100", NeedsReview: listing.Reasons{"year"}},
+	}))
+
+	goodRows := readTestCSV(t, good)
+	require.Len(t, goodRows, 2)
+	assert.Equal(t, csvHeaders, goodRows[0])
+	assert.Equal(t, "2021 Evil Wreckoning", goodRows[1][0])
+
+	suspectRows := readTestCSV(t, suspect)
+	require.Len(t, suspectRows, 2)
+	assert.Equal(t, "Mystery bike", suspectRows[1][0])
+	assert.Equal(t, "year", suspectRows[1][columnIndex(csvHeaders, "Needs Review")])
+}
+
+func TestCSVExporterAppendMergesByHash(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "listings.csv")
+
+	exp := NewCSVExporter(path, "", CSVOptions{Append: true, Combined: true})
+	require.NoError(t, exp.Export([]listing.Listing{
+		{Title: "2021 Evil Wreckoning", Price: "3900"},
+		{Title: "2020 Kona Process 153", Price: "2200"},
+	}))
+	require.NoError(t, exp.Export([]listing.Listing{
+		{Title: "2021 Evil Wreckoning", Price: "3500"},
+		{Title: "Mystery bike", Price: "100", NeedsReview: listing.Reasons{"year"}},
+	}))
+
+	rows := readTestCSV(t, path)
+	require.Len(t, rows, 4)
+	priceColumn := columnIndex(csvHeaders, "Price")
+	assert.Equal(t, "3500", rows[1][priceColumn])
+	assert.Equal(t, "2200", rows[2][priceColumn])
+	assert.Equal(t, "Mystery bike", rows[3][0])
+}
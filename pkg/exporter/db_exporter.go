@@ -1,47 +1,199 @@
 package exporter
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/currency"
+	"pinkbike-scraper/pkg/events"
 	"pinkbike-scraper/pkg/listing"
-
-	_ "github.com/mattn/go-sqlite3"
+	"pinkbike-scraper/pkg/parser"
+	"pinkbike-scraper/pkg/progress"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 type DBExporter struct {
-	db *sql.DB
+	db  *sql.DB
+	bus *events.Bus
+	// rateID references the current exchange rate fetched for this run. Price
+	// history also records the rate each CAD price was converted at, which
+	// differs from it for prices converted at a historical rate.
+	rateID sql.NullInt64
+	// fts is set when the SQLite build supports the full-text search index
+	fts bool
+	// skipBadRows leaves unreadable rows out of query results, recording them
+	// in skipped, instead of failing the query
+	skipBadRows bool
+	mu          sync.Mutex
+	skipped     []ScanError
+	// clock dates listings and decides which ones have gone stale
+	clock clock.Clock
+	// includeSuspected keeps suspected scams in medians and price indexes
+	includeSuspected bool
+}
+
+// DBOptions tunes the SQLite connection so concurrent scraping and exporting
+// do not fail with "database is locked"
+type DBOptions struct {
+	// WAL enables write-ahead logging so readers do not block the writer
+	WAL bool
+	// BusyTimeout is how long a connection waits for a lock before failing
+	BusyTimeout time.Duration
+	// ForeignKeys enforces the REFERENCES constraints in the schema
+	ForeignKeys bool
+	// MaxOpenConns and MaxIdleConns size the connection pool; zero keeps the
+	// database/sql defaults
+	MaxOpenConns, MaxIdleConns int
+	ConnMaxLifetime            time.Duration
+	// SkipBadRows leaves rows that cannot be read out of query results
+	// instead of failing the whole query; see DBExporter.SkippedRows
+	SkipBadRows bool
+	// Clock stands in for the current time, the system clock when nil
+	Clock clock.Clock
+	// MigrationBackups backs up a database from an older version, gzipped
+	// next to it, before migrating it to the current schema
+	MigrationBackups bool
+	// IncludeSuspected keeps listings scored as likely scams in medians and
+	// price indexes, which leave them out by default
+	IncludeSuspected bool
+}
+
+// DefaultDBOptions returns the settings used unless overridden
+func DefaultDBOptions() DBOptions {
+	return DBOptions{
+		WAL:              true,
+		BusyTimeout:      5 * time.Second,
+		ForeignKeys:      true,
+		MigrationBackups: true,
+	}
+}
+
+// dsn builds a go-sqlite3 connection string applying the pragmas to every connection
+func (o DBOptions) dsn(dbPath string) string {
+	params := url.Values{}
+	if o.WAL {
+		params.Set("_journal_mode", "WAL")
+	}
+	if o.BusyTimeout > 0 {
+		params.Set("_busy_timeout", strconv.FormatInt(o.BusyTimeout.Milliseconds(), 10))
+	}
+	if o.ForeignKeys {
+		params.Set("_foreign_keys", "on")
+	}
+
+	if len(params) == 0 {
+		return dbPath
+	}
+	return "file:" + dbPath + "?" + params.Encode()
 }
 
-func NewDBExporter(dbPath string) (*DBExporter, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+// NewDBExporter opens the listings database. Lifecycle events are published to
+// bus once each export commits; bus may be nil.
+func NewDBExporter(dbPath string, bus *events.Bus, opts DBOptions) (*DBExporter, error) {
+	db, err := sql.Open(sqliteDriver, opts.dsn(dbPath))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
+	if opts.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(opts.MaxOpenConns)
+	}
+	if opts.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(opts.MaxIdleConns)
+	}
+	if opts.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	}
+
+	if opts.MigrationBackups {
+		if err := backupBeforeMigrating(db, dbPath, clock.Or(opts.Clock)); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
 	if err := initializeDB(db); err != nil {
 		db.Close()
 		return nil, err
 	}
 
-	return &DBExporter{db: db}, nil
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	fts, err := initializeSearch(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &DBExporter{
+		db: db, bus: bus, fts: fts, skipBadRows: opts.SkipBadRows, clock: clock.Or(opts.Clock),
+		includeSuspected: opts.IncludeSuspected,
+	}, nil
 }
 
 func (e *DBExporter) Export(listings []listing.Listing) error {
+	return e.ExportWithProgress(listings, nil)
+}
+
+// ExportWithProgress exports listings like Export, advancing bar as each one
+// is stored
+func (e *DBExporter) ExportWithProgress(listings []listing.Listing, bar *progress.Bar) error {
 	tx, err := e.db.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	if err := e.exportListings(tx, listings); err != nil {
+	favorites, err := snapshotFavorites(tx)
+	if err != nil {
+		return err
+	}
+
+	changes, stored, err := e.exportListings(tx, listings, bar)
+	if err != nil {
+		return err
+	}
+
+	inactive, err := e.markInactiveListings(tx)
+	if err != nil {
+		return err
+	}
+
+	if err := e.recordSellers(tx, stored); err != nil {
 		return err
 	}
 
-	if err := e.markInactiveListings(tx); err != nil {
+	if err := e.scoreListings(tx, stored); err != nil {
 		return err
 	}
 
-	return tx.Commit()
+	if err := e.recordFavoriteChanges(tx, favorites); err != nil {
+		return err
+	}
+
+	changes = append(changes, inactive...)
+	if err := e.recordEvents(tx, changes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, ev := range changes {
+		e.bus.Publish(ev)
+	}
+
+	return nil
 }
 
 func (e *DBExporter) Close() error {
@@ -59,6 +211,7 @@ func initializeDB(db *sql.DB) error {
         model TEXT,
         price TEXT,
         currency TEXT,
+        listed_price TEXT,
         condition TEXT,
         frame_size TEXT,
         wheel_size TEXT,
@@ -69,6 +222,51 @@ func initializeDB(db *sql.DB) error {
 		restrictions TEXT,
 		seller_type TEXT,
 		original_post_date DATETIME,
+		field_metadata TEXT,
+		confidence REAL,
+		is_electric INTEGER DEFAULT 0,
+		motor TEXT,
+		battery_wh INTEGER,
+		normalized_size TEXT,
+		year_min INTEGER,
+		year_max INTEGER,
+		generation TEXT,
+		wheel_front REAL,
+		wheel_rear REAL,
+		mullet INTEGER DEFAULT 0,
+		rider_height_min INTEGER,
+		rider_height_max INTEGER,
+		condition_grade INTEGER,
+		build_kit TEXT,
+		build_tier INTEGER,
+		category TEXT,
+		kind TEXT,
+		shock_size TEXT,
+		steerer_length INTEGER,
+		listing_id INTEGER,
+		fingerprint TEXT,
+		negotiable INTEGER DEFAULT 0,
+		price_drop_advertised INTEGER DEFAULT 0,
+		original_price REAL,
+		original_currency TEXT,
+		estimated_km INTEGER,
+		seasons_used REAL,
+		never_raced INTEGER DEFAULT 0,
+		usage_confidence REAL,
+		phone TEXT,
+		photo_count INTEGER,
+		view_count INTEGER,
+		seller TEXT,
+		predicted_price REAL,
+		residual REAL,
+		description_hash TEXT,
+		fraud_score REAL,
+		fraud_reasons TEXT,
+		quality_score REAL,
+		quality_issues TEXT,
+		location TEXT,
+		latitude REAL,
+		longitude REAL,
         needs_review TEXT,
         url TEXT,
         hash TEXT UNIQUE,
@@ -82,12 +280,170 @@ func initializeDB(db *sql.DB) error {
         listing_hash TEXT,
         price TEXT,
         currency TEXT,
+        listed_price TEXT,
+        exchange_rate REAL,
         recorded_at DATETIME DEFAULT CURRENT_TIMESTAMP,
         FOREIGN KEY(listing_hash) REFERENCES listings(hash)
     );
 
+    CREATE TABLE IF NOT EXISTS listing_events (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        kind TEXT NOT NULL,
+        listing_hash TEXT NOT NULL,
+        price TEXT,
+        old_price TEXT,
+        recorded_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE IF NOT EXISTS exchange_rates (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        base TEXT,
+        quote TEXT,
+        rate REAL,
+        source TEXT,
+        fetched_at DATETIME
+    );
+
+    CREATE TABLE IF NOT EXISTS price_history_compacted (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        listing_hash TEXT,
+        price TEXT,
+        currency TEXT,
+        valid_from DATETIME,
+        valid_to DATETIME,
+        FOREIGN KEY(listing_hash) REFERENCES listings(hash)
+    );
+
+    CREATE TABLE IF NOT EXISTS corrections (
+        hash TEXT PRIMARY KEY,
+        year TEXT,
+        manufacturer TEXT,
+        model TEXT,
+        corrected_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE IF NOT EXISTS aliases (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        kind TEXT,
+        manufacturer TEXT,
+        alias TEXT,
+        canonical TEXT,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        UNIQUE(kind, manufacturer, alias)
+    );
+
+    CREATE TABLE IF NOT EXISTS saved_searches (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        owner TEXT,
+        name TEXT,
+        criteria TEXT,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        UNIQUE(owner, name)
+    );
+
+    CREATE TABLE IF NOT EXISTS runs (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        started_at DATETIME,
+        finished_at DATETIME,
+        bike_type TEXT,
+        input_mode TEXT,
+        pages INTEGER DEFAULT 0,
+        listings INTEGER DEFAULT 0,
+        new_listings INTEGER DEFAULT 0,
+        updated_listings INTEGER DEFAULT 0,
+        error_count INTEGER DEFAULT 0,
+        errors TEXT,
+        blocked INTEGER DEFAULT 0,
+        price_drops INTEGER DEFAULT 0,
+        sold_listings INTEGER DEFAULT 0,
+        unparsed_listings INTEGER DEFAULT 0,
+        scrape_failures TEXT,
+        parse_failures TEXT,
+        exports TEXT,
+        exceeded TEXT
+    );
+
+    CREATE TABLE IF NOT EXISTS sellers (
+        username TEXT PRIMARY KEY,
+        profile_url TEXT,
+        seller_type TEXT,
+        first_seen DATETIME,
+        last_seen DATETIME
+    );
+
+    CREATE TABLE IF NOT EXISTS seller_listing_counts (
+        username TEXT NOT NULL,
+        recorded_on DATE NOT NULL,
+        active_listings INTEGER,
+        total_listings INTEGER,
+        PRIMARY KEY(username, recorded_on)
+    );
+
+    CREATE TABLE IF NOT EXISTS reparse_changes (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        hash TEXT,
+        field TEXT,
+        old_value TEXT,
+        new_value TEXT,
+        changed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE IF NOT EXISTS pending_exports (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        sink TEXT,
+        payload BLOB,
+        attempts INTEGER DEFAULT 0,
+        next_attempt DATETIME,
+        last_error TEXT,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE IF NOT EXISTS cache (
+        key TEXT PRIMARY KEY,
+        value BLOB,
+        stored_at DATETIME,
+        expires_at DATETIME
+    );
+
+    CREATE TABLE IF NOT EXISTS favorites (
+        listing_hash TEXT PRIMARY KEY,
+        added_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE IF NOT EXISTS favorite_changes (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        listing_hash TEXT,
+        field TEXT,
+        old_value TEXT,
+        new_value TEXT,
+        changed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE IF NOT EXISTS listing_edits (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        listing_hash TEXT,
+        field TEXT,
+        old_value TEXT,
+        new_value TEXT,
+        diff TEXT,
+        edited_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE IF NOT EXISTS indexes (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        name TEXT,
+        week DATE,
+        median REAL,
+        listings INTEGER,
+        computed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        UNIQUE(name, week)
+    );
+
     CREATE INDEX IF NOT EXISTS idx_listings_hash ON listings(hash);
     CREATE INDEX IF NOT EXISTS idx_price_history_listing_hash ON price_history(listing_hash);
+    CREATE INDEX IF NOT EXISTS idx_price_history_compacted_listing_hash ON price_history_compacted(listing_hash);
+    CREATE INDEX IF NOT EXISTS idx_listing_events_listing_hash ON listing_events(listing_hash);
+    CREATE INDEX IF NOT EXISTS idx_listing_edits_listing_hash ON listing_edits(listing_hash);
     `
 	_, err := db.Exec(createTableSQL)
 	if err != nil {
@@ -97,73 +453,386 @@ func initializeDB(db *sql.DB) error {
 	return nil
 }
 
-func (e *DBExporter) ListingExistsWithDetails(hash string) (bool, error) {
+// RecordExchangeRate stores the rate used to convert prices in this run and
+// links listings and price history exported afterwards to it
+func (e *DBExporter) RecordExchangeRate(rate currency.Rate) (int64, error) {
+	res, err := e.db.Exec(`
+        INSERT INTO exchange_rates (base, quote, rate, source, fetched_at)
+        VALUES (?, ?, ?, ?, ?)
+    `, rate.Base, rate.Quote, rate.Value, rate.Source, rate.FetchedAt.UTC().Format(sqliteTimeFormat))
+	if err != nil {
+		return 0, fmt.Errorf("failed to record exchange rate: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to record exchange rate: %w", err)
+	}
+
+	e.rateID = sql.NullInt64{Int64: id, Valid: true}
+	return id, nil
+}
+
+// ListingExistsWithDetails reports whether the listing with fingerprint is
+// stored with the details of its listing page
+func (e *DBExporter) ListingExistsWithDetails(fingerprint string) (bool, error) {
 	var exists bool
-	err := e.db.QueryRow("SELECT EXISTS(SELECT 1 FROM listings WHERE hash = ? AND description IS NOT NULL)", hash).Scan(&exists)
+	err := e.db.QueryRow("SELECT EXISTS(SELECT 1 FROM listings WHERE fingerprint = ? AND description IS NOT NULL)", fingerprint).Scan(&exists)
 	if err != nil {
 		return false, fmt.Errorf("failed to check if listing exists: %w", err)
 	}
 	return exists, nil
 }
 
-func (e *DBExporter) exportListings(tx *sql.Tx, listings []listing.Listing) error {
+func (e *DBExporter) ListingExists(hash string) (bool, error) {
+	var exists bool
+	err := e.db.QueryRow("SELECT EXISTS(SELECT 1 FROM listings WHERE hash = ?)", hash).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if listing exists: %w", err)
+	}
+	return exists, nil
+}
+
+// FingerprintExists reports whether a listing with fingerprint is stored,
+// whatever its title was when it was stored
+func (e *DBExporter) FingerprintExists(fingerprint string) (bool, error) {
+	var exists bool
+	err := e.db.QueryRow("SELECT EXISTS(SELECT 1 FROM listings WHERE fingerprint = ?)", fingerprint).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if listing exists: %w", err)
+	}
+	return exists, nil
+}
+
+// exportListings upserts listings, returning the lifecycle events they caused
+// and the listings as stored, corrected and with their hash
+func (e *DBExporter) exportListings(tx *sql.Tx, listings []listing.Listing, bar *progress.Bar) ([]events.Event, []listing.Listing, error) {
 	stmt, err := tx.Prepare(`
         INSERT INTO listings (
             title, year, manufacturer, model, price, currency, 
             condition, frame_size, wheel_size, frame_material,
             front_travel, rear_travel, needs_review, url, hash,
             description, restrictions, seller_type, original_post_date,
-            first_seen, last_seen, active
+            field_metadata, confidence, is_electric, motor, battery_wh,
+            normalized_size, rider_height_min, rider_height_max, condition_grade, category,
+            kind, shock_size, steerer_length, wheel_front, wheel_rear, mullet, year_min, year_max, generation,
+            build_kit, build_tier, listing_id, fingerprint, negotiable, price_drop_advertised, original_price, original_currency,
+            estimated_km, seasons_used, never_raced, usage_confidence, phone,
+            photo_count, view_count, seller, listed_price, predicted_price, residual,
+            location, latitude, longitude, quality_score, quality_issues,
+            exchange_rate_id, first_seen, last_seen, active
         ) 
         VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
                 ?, ?, ?, ?,
-                CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 1)
-        ON CONFLICT(hash) DO UPDATE SET 
-            last_seen = CURRENT_TIMESTAMP,
+                ?, ?, ?, ?, ?,
+                ?, ?, ?, ?, ?,
+                ?, ?, ?, ?, ?, ?, ?, ?, ?,
+                ?, ?, ?, ?, ?, ?, ?, ?,
+                ?, ?, ?, ?, ?,
+                ?, ?, ?, ?, ?, ?,
+                ?, ?, ?, ?, ?,
+                ?, ?, ?, 1)
+        ON CONFLICT(fingerprint) DO UPDATE SET 
+            last_seen = excluded.last_seen,
             active = 1,
             url = excluded.url,
             price = excluded.price,
+            negotiable = excluded.negotiable,
+            price_drop_advertised = excluded.price_drop_advertised,
+            description = COALESCE(NULLIF(excluded.description, ''), description),
+            restrictions = COALESCE(NULLIF(excluded.restrictions, ''), restrictions),
+            field_metadata = COALESCE(excluded.field_metadata, field_metadata),
+            confidence = COALESCE(excluded.confidence, confidence),
+            is_electric = MAX(is_electric, excluded.is_electric),
+            normalized_size = COALESCE(excluded.normalized_size, normalized_size),
+            rider_height_min = COALESCE(excluded.rider_height_min, rider_height_min),
+            rider_height_max = COALESCE(excluded.rider_height_max, rider_height_max),
+            condition_grade = COALESCE(excluded.condition_grade, condition_grade),
+            build_kit = COALESCE(excluded.build_kit, build_kit),
+            build_tier = COALESCE(excluded.build_tier, build_tier),
+            category = COALESCE(excluded.category, category),
+            kind = COALESCE(excluded.kind, kind),
+            shock_size = COALESCE(excluded.shock_size, shock_size),
+            steerer_length = COALESCE(excluded.steerer_length, steerer_length),
+            mullet = CASE WHEN excluded.wheel_front IS NULL THEN mullet ELSE excluded.mullet END,
+            wheel_front = COALESCE(excluded.wheel_front, wheel_front),
+            wheel_rear = COALESCE(excluded.wheel_rear, wheel_rear),
+            year_max = CASE WHEN excluded.year_min IS NULL OR (NULLIF(excluded.description, '') IS NULL AND year_min IS NOT NULL)
+                THEN year_max ELSE excluded.year_max END,
+            year_min = CASE WHEN excluded.year_min IS NULL OR (NULLIF(excluded.description, '') IS NULL AND year_min IS NOT NULL)
+                THEN year_min ELSE excluded.year_min END,
+            generation = excluded.generation,
+            listing_id = COALESCE(excluded.listing_id, listing_id),
+            phone = COALESCE(excluded.phone, phone),
+            photo_count = COALESCE(excluded.photo_count, photo_count),
+            view_count = COALESCE(excluded.view_count, view_count),
+            seller = COALESCE(excluded.seller, seller),
+            listed_price = COALESCE(excluded.listed_price, listed_price),
+            predicted_price = COALESCE(excluded.predicted_price, predicted_price),
+            residual = COALESCE(excluded.residual, CAST(NULLIF(excluded.price, '') AS REAL) - predicted_price),
+            location = COALESCE(excluded.location, location),
+            latitude = COALESCE(excluded.latitude, latitude),
+            longitude = COALESCE(excluded.longitude, longitude),
+            quality_score = COALESCE(excluded.quality_score, quality_score),
+            quality_issues = COALESCE(excluded.quality_issues, quality_issues),
+            exchange_rate_id = excluded.exchange_rate_id
     `)
 	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %w", err)
+		return nil, nil, fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
+	corrections, err := loadCorrections(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var changes []events.Event
+	var stored []listing.Listing
 	for _, l := range listings {
-		if err := e.exportListing(stmt, tx, l); err != nil {
-			return err
+		if c, ok := corrections[l.ComputeHash()]; ok {
+			l = c.apply(l, e.clock)
+		}
+		key, ev, err := e.exportListing(stmt, tx, l)
+		if err != nil {
+			return nil, nil, err
 		}
+		l.Hash = key
+		stored = append(stored, l)
+		if ev != nil {
+			changes = append(changes, *ev)
+		}
+		bar.Add(1)
 	}
 
-	return nil
+	return changes, stored, nil
 }
 
-func (e *DBExporter) exportListing(stmt *sql.Stmt, tx *sql.Tx, l listing.Listing) error {
-	hash := l.ComputeHash()
+// exportListing upserts a listing and returns the hash it is stored under and
+// the lifecycle event it caused, if any
+func (e *DBExporter) exportListing(stmt *sql.Stmt, tx *sql.Tx, l listing.Listing) (string, *events.Event, error) {
+	l.Hash = l.ComputeHash()
+	fingerprint := l.Fingerprint()
+
+	hash, oldTitle, err := e.storedKey(tx, fingerprint, l)
+	if err != nil {
+		return "", nil, err
+	}
+	l.Hash = hash
+
+	var oldPrice string
+	stored := storedDetails{title: oldTitle}
+	err = tx.QueryRow(`
+        SELECT price, COALESCE(decompress(description), ''), COALESCE(restrictions, '')
+        FROM listings WHERE fingerprint = ?
+    `, fingerprint).Scan(&oldPrice, &stored.description, &stored.restrictions)
+	isNew := err == sql.ErrNoRows
+	if err != nil && !isNew {
+		return "", nil, fmt.Errorf("failed to look up listing: %w", err)
+	}
+	if !isNew {
+		if err := e.recordEdits(tx, hash, stored, l); err != nil {
+			return "", nil, err
+		}
+	}
+
+	metadata, confidence, err := encodeMetadata(l)
+	if err != nil {
+		return "", nil, err
+	}
+	minHeight, maxHeight := l.RiderHeight()
+	qualityScore, qualityIssues := storedQuality(l)
+	var latitude, longitude sql.NullFloat64
+	if !l.Coordinates.IsZero() {
+		latitude = sql.NullFloat64{Float64: l.Coordinates.Lat, Valid: true}
+		longitude = sql.NullFloat64{Float64: l.Coordinates.Lon, Valid: true}
+	}
+
 	if _, err := stmt.Exec(
 		l.Title, l.Year, l.Manufacturer, l.Model, l.Price,
 		l.Currency, l.Condition, l.FrameSize, l.WheelSize,
 		l.FrameMaterial, l.FrontTravel, l.RearTravel,
-		l.NeedsReview, l.URL, hash,
-		l.Details.Description, l.Details.Restrictions, l.Details.SellerType, l.Details.OriginalPostDate,
+		encodeReasons(l.NeedsReview), l.URL, hash,
+		compressText(l.Details.Description), l.Details.Restrictions, l.Details.SellerType, l.Details.OriginalPostDate,
+		metadata, confidence, l.IsElectric, nullString(l.Details.Motor), nullInt(l.Details.BatteryWh),
+		nullString(l.NormalizedSize), nullInt(minHeight), nullInt(maxHeight), nullInt(int(l.ConditionGrade)), nullString(l.Category),
+		nullString(string(l.Kind)), nullString(l.ShockSize), nullInt(l.SteererLength),
+		nullFloat(l.Wheels.Front), nullFloat(l.Wheels.Rear), l.Wheels.Mullet, nullInt(l.InferredYears.Min), nullInt(l.InferredYears.Max),
+		nullString(l.Generation), nullString(l.BuildTier.Kit), nullInt(int(l.BuildTier.Rank)), nullInt(l.ListingID), fingerprint, l.Negotiable, l.PriceDropAdvertised, nullFloat(l.Details.OriginalPrice.Amount), nullString(l.Details.OriginalPrice.Currency),
+		usageKM(l.Details.Usage), nullFloat(l.Details.Usage.SeasonsUsed), l.Details.Usage.NeverRaced, nullFloat(l.Details.Usage.Confidence), nullString(l.Details.Phone),
+		nullInt(l.Details.PhotoCount), nullInt(l.Details.ViewCount), nullString(l.Details.Seller), nullString(l.ListedPrice),
+		nullFloat(l.PredictedPrice), residual(l),
+		nullString(l.Location), latitude, longitude, qualityScore, qualityIssues,
+		e.rateID, e.now(), e.now(),
 	); err != nil {
-		return fmt.Errorf("failed to insert listing: %w", err)
+		return "", nil, fmt.Errorf("failed to insert listing: %w", err)
+	}
+
+	if err := e.recordPriceHistory(tx, l, hash); err != nil {
+		return "", nil, err
+	}
+
+	if err := e.indexListing(tx, hash); err != nil {
+		return "", nil, err
+	}
+
+	switch {
+	case isNew:
+		return hash, &events.Event{Kind: events.ListingDiscovered, Listing: l}, nil
+	case oldPrice != l.Price:
+		return hash, &events.Event{Kind: events.PriceChanged, Listing: l, OldPrice: oldPrice}, nil
+	}
+	return hash, nil, nil
+}
+
+// storedKey returns the hash the listing with fingerprint is stored under,
+// which the rows referencing it are keyed by, and the stored title when the
+// listing was stored before. A stored listing is re-keyed to the hash of l
+// and gets the scraped fields the hash is computed from, so a listing whose
+// seller edited the title or specs keeps its history instead of being stored
+// again as a new listing. It keeps its hash when another listing is stored
+// under the new one, and a new listing whose hash another listing has is
+// stored under a key of its own, so listings whose titles and specs match
+// are never stored as one.
+func (e *DBExporter) storedKey(tx *sql.Tx, fingerprint string, l listing.Listing) (string, string, error) {
+	var stored, title string
+	err := tx.QueryRow("SELECT hash, title FROM listings WHERE fingerprint = ?", fingerprint).Scan(&stored, &title)
+	if err == sql.ErrNoRows {
+		key, err := freeKey(tx, l.Hash, fingerprint)
+		return key, "", err
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to look up listing fingerprint: %w", err)
+	}
+	if stored == l.Hash {
+		return stored, title, nil
+	}
+
+	key := l.Hash
+	var taken bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM listings WHERE hash = ?)", l.Hash).Scan(&taken); err != nil {
+		return "", "", fmt.Errorf("failed to look up listing: %w", err)
+	}
+	if taken {
+		key = stored
+	}
+
+	// price history references the old hash until it is re-keyed below
+	if _, err := tx.Exec("PRAGMA defer_foreign_keys = ON"); err != nil {
+		return "", "", fmt.Errorf("failed to re-key listing %s: %w", fingerprint, err)
 	}
+	if _, err := tx.Exec(`
+        UPDATE listings SET hash = ?, title = ?, year = ?, year_min = ?, year_max = ?, manufacturer = ?, model = ?, generation = ?, condition = ?,
+            frame_size = ?, wheel_size = ?, wheel_front = ?, wheel_rear = ?, mullet = ?,
+            frame_material = ?, front_travel = ?, rear_travel = ?
+        WHERE hash = ?
+    `, key, l.Title, l.Year, nullInt(l.InferredYears.Min), nullInt(l.InferredYears.Max), l.Manufacturer, l.Model, nullString(l.Generation), l.Condition,
+		l.FrameSize, l.WheelSize, nullFloat(l.Wheels.Front), nullFloat(l.Wheels.Rear), l.Wheels.Mullet,
+		l.FrameMaterial, l.FrontTravel, l.RearTravel, stored); err != nil {
+		return "", "", fmt.Errorf("failed to re-key listing %s: %w", fingerprint, err)
+	}
+	if key != stored {
+		if err := e.rekey(tx, stored, key); err != nil {
+			return "", "", fmt.Errorf("failed to re-key listing %s: %w", fingerprint, err)
+		}
+	}
+	return key, title, nil
+}
 
-	return e.recordPriceHistory(tx, l, hash)
+// freeKey returns hash when no listing is stored under it. Otherwise it
+// returns a key derived from hash and the fingerprint of the listing to store.
+func freeKey(tx *sql.Tx, hash, fingerprint string) (string, error) {
+	var taken bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM listings WHERE hash = ?)", hash).Scan(&taken); err != nil {
+		return "", fmt.Errorf("failed to look up listing: %w", err)
+	}
+	if !taken {
+		return hash, nil
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(hash+"|"+fingerprint))), nil
+}
+
+// encodeMetadata returns the listing's field provenance as JSON and its
+// confidence, both NULL for listings without provenance
+func encodeMetadata(l listing.Listing) (sql.NullString, sql.NullFloat64, error) {
+	if len(l.Metadata) == 0 {
+		return sql.NullString{}, sql.NullFloat64{}, nil
+	}
+
+	data, err := json.Marshal(l.Metadata)
+	if err != nil {
+		return sql.NullString{}, sql.NullFloat64{}, fmt.Errorf("failed to encode field metadata: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, sql.NullFloat64{Float64: l.Confidence(), Valid: true}, nil
+}
+
+// now is the exporter clock's time in the format CURRENT_TIMESTAMP writes
+func (e *DBExporter) now() string {
+	return e.clock.Now().UTC().Format(sqliteTimeFormat)
+}
+
+// encodeReasons stores review reasons as a JSON array. No reasons are stored
+// as an empty string, which queries for listings not needing review match.
+func encodeReasons(r listing.Reasons) string {
+	if len(r) == 0 {
+		return ""
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return r.String()
+	}
+	return string(b)
+}
+
+// nullString stores an empty string as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullInt stores zero as NULL
+func nullInt(n int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(n), Valid: n != 0}
+}
+
+// usageKM stores the distance ridden, NULL when the description gives no
+// usage and zero when it says the bike is unridden
+func usageKM(u parser.Usage) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(u.EstimatedKM), Valid: u.EstimatedKM > 0 || u.Confidence > 0 && u.SeasonsUsed == 0}
+}
+
+// nullFloat stores zero as NULL
+func nullFloat(f float64) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: f, Valid: f != 0}
+}
+
+// residual stores the listing's price less its predicted price, NULL when no
+// price model was run
+func residual(l listing.Listing) sql.NullFloat64 {
+	r, ok := l.Residual()
+	return sql.NullFloat64{Float64: r, Valid: ok}
+}
+
+// storedQuality stores the listing's description quality score and issues,
+// both NULL when its page was not scraped so the stored ones are kept
+func storedQuality(l listing.Listing) (sql.NullFloat64, sql.NullString) {
+	q, ok := l.Quality()
+	if !ok {
+		return sql.NullFloat64{}, sql.NullString{}
+	}
+	return sql.NullFloat64{Float64: q.Score, Valid: true}, sql.NullString{String: strings.Join(q.Issues, "; "), Valid: true}
 }
 
 func (e *DBExporter) recordPriceHistory(tx *sql.Tx, l listing.Listing, hash string) error {
 	_, err := tx.Exec(`
-        INSERT INTO price_history (listing_hash, price, currency)
-        SELECT ?, ?, ?
+        INSERT INTO price_history (listing_hash, price, currency, listed_price, exchange_rate, exchange_rate_id, recorded_at)
+        SELECT ?, ?, ?, ?, ?, ?, ?
         WHERE NOT EXISTS (
             SELECT 1 FROM price_history 
             WHERE listing_hash = ? 
             AND price = ? 
-            AND recorded_at > datetime('now', '-1 day')
+            AND recorded_at > datetime(?, '-1 day')
         )
-    `, hash, l.Price, l.Currency, hash, l.Price)
+    `, hash, l.Price, l.Currency, nullString(l.ListedPrice), nullFloat(l.ExchangeRate), e.rateID, e.now(), hash, l.Price, e.now())
 
 	if err != nil {
 		return fmt.Errorf("failed to record price history: %w", err)
@@ -171,14 +840,46 @@ func (e *DBExporter) recordPriceHistory(tx *sql.Tx, l listing.Listing, hash stri
 	return nil
 }
 
-func (e *DBExporter) markInactiveListings(tx *sql.Tx) error {
-	_, err := tx.Exec(`
+func (e *DBExporter) markInactiveListings(tx *sql.Tx) ([]events.Event, error) {
+	rows, err := tx.Query(`
+        SELECT hash, title, price, currency, url FROM listings
+        WHERE active = 1 AND datetime(last_seen) < datetime(?, '-7 days')
+    `, e.now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to find inactive listings: %w", err)
+	}
+
+	scanner, err := newRowScanner(rows)
+	if err != nil {
+		rows.Close()
+		return nil, err
+	}
+
+	var inactive []events.Event
+	for rows.Next() {
+		var hash, title, price, currency, url sql.NullString
+		if err := scanner.scan(rows, &hash, &title, &price, &currency, &url); err != nil {
+			if e.skipRow(err) {
+				continue
+			}
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan inactive listing: %w", err)
+		}
+		l := listing.Listing{Hash: hash.String, Title: title.String, Price: price.String, Currency: currency.String, URL: url.String}
+		inactive = append(inactive, events.Event{Kind: events.ListingSold, Listing: l})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to find inactive listings: %w", err)
+	}
+
+	_, err = tx.Exec(`
         UPDATE listings 
         SET active = 0 
-        WHERE datetime(last_seen) < datetime('now', '-7 days')
-    `)
+        WHERE datetime(last_seen) < datetime(?, '-7 days')
+    `, e.now())
 	if err != nil {
-		return fmt.Errorf("failed to mark inactive listings: %w", err)
+		return nil, fmt.Errorf("failed to mark inactive listings: %w", err)
 	}
-	return nil
+	return inactive, nil
 }
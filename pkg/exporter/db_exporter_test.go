@@ -0,0 +1,501 @@
+package exporter
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/currency"
+	"pinkbike-scraper/pkg/events"
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/parser"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestDBExporter opens a fresh database in a temporary directory
+func newTestDBExporter(t *testing.T, bus *events.Bus) *DBExporter {
+	t.Helper()
+
+	exp, err := NewDBExporter(filepath.Join(t.TempDir(), "listings.db"), bus, DefaultDBOptions())
+	require.NoError(t, err)
+	t.Cleanup(func() { exp.Close() })
+	return exp
+}
+
+func TestDBExporterPublishesLifecycleEvents(t *testing.T) {
+	bus := events.NewBus()
+	var received []events.Event
+	bus.Subscribe("test", func(e events.Event) error {
+		received = append(received, e)
+		return nil
+	})
+
+	exp := newTestDBExporter(t, bus)
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	exp.clock = clock.Fixed(start)
+	l := listing.Listing{Title: "2021 Evil Wreckoning", Price: "3900", Currency: "USD"}
+
+	require.NoError(t, exp.Export([]listing.Listing{l}))
+	require.Len(t, received, 1)
+	assert.Equal(t, events.ListingDiscovered, received[0].Kind)
+	assert.Equal(t, l.ComputeHash(), received[0].Listing.Hash)
+
+	require.NoError(t, exp.Export([]listing.Listing{l}))
+	assert.Len(t, received, 1, "unchanged listing should not publish")
+
+	l.Price = "3500"
+	require.NoError(t, exp.Export([]listing.Listing{l}))
+	require.Len(t, received, 2)
+	assert.Equal(t, events.PriceChanged, received[1].Kind)
+	assert.Equal(t, "3900", received[1].OldPrice)
+
+	var firstSeen time.Time
+	require.NoError(t, exp.db.QueryRow("SELECT first_seen FROM listings").Scan(&firstSeen))
+	assert.Equal(t, start, firstSeen.UTC())
+
+	exp.clock = clock.Fixed(start.AddDate(0, 0, 8))
+	require.NoError(t, exp.Export(nil))
+	require.Len(t, received, 3)
+	assert.Equal(t, events.ListingSold, received[2].Kind)
+	assert.Equal(t, l.Title, received[2].Listing.Title)
+
+	require.NoError(t, exp.Export(nil))
+	assert.Len(t, received, 3, "already inactive listings should not publish again")
+}
+
+func TestDBExporterLinksPricesToExchangeRate(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+
+	id, err := exp.RecordExchangeRate(currency.Rate{Base: "CAD", Quote: "USD", Value: 0.73, Source: "test", FetchedAt: time.Now()})
+	require.NoError(t, err)
+
+	require.NoError(t, exp.Export([]listing.Listing{{Title: "2021 Evil Wreckoning", Price: "3900", Currency: "CAD"}}))
+
+	var listingRateID, historyRateID int64
+	require.NoError(t, exp.db.QueryRow("SELECT exchange_rate_id FROM listings").Scan(&listingRateID))
+	require.NoError(t, exp.db.QueryRow("SELECT exchange_rate_id FROM price_history").Scan(&historyRateID))
+	assert.Equal(t, id, listingRateID)
+	assert.Equal(t, id, historyRateID)
+}
+
+func TestDBOptionsPragmas(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+
+	var journalMode string
+	var busyTimeout, foreignKeys int
+	require.NoError(t, exp.db.QueryRow("PRAGMA journal_mode").Scan(&journalMode))
+	require.NoError(t, exp.db.QueryRow("PRAGMA busy_timeout").Scan(&busyTimeout))
+	require.NoError(t, exp.db.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys))
+
+	assert.Equal(t, "wal", journalMode)
+	assert.Equal(t, 5000, busyTimeout)
+	assert.Equal(t, 1, foreignKeys)
+}
+
+func TestDBExporterFollowsListingID(t *testing.T) {
+	bus := events.NewBus()
+	var received []events.Event
+	bus.Subscribe("test", func(e events.Event) error {
+		received = append(received, e)
+		return nil
+	})
+
+	exp := newTestDBExporter(t, bus)
+	l := listing.Listing{Title: "2021 Evil Wreckoning", Price: "3900", Currency: "USD",
+		URL: "https://www.pinkbike.com/buysell/3861316/", ListingID: 3861316}
+	require.NoError(t, exp.Export([]listing.Listing{l}))
+
+	// the seller edits the title and drops the price
+	edited := l
+	edited.Title, edited.Price = "2021 Evil Wreckoning V2, new shock", "3500"
+	require.NoError(t, exp.Export([]listing.Listing{edited}))
+
+	var count int
+	require.NoError(t, exp.db.QueryRow("SELECT COUNT(*) FROM listings").Scan(&count))
+	assert.Equal(t, 1, count)
+
+	var hash string
+	require.NoError(t, exp.db.QueryRow("SELECT hash FROM listings WHERE listing_id = 3861316").Scan(&hash))
+	assert.Equal(t, edited.ComputeHash(), hash)
+	require.NoError(t, exp.db.QueryRow("SELECT COUNT(*) FROM price_history WHERE listing_hash = ?", hash).Scan(&count))
+	assert.Equal(t, 2, count)
+
+	require.Len(t, received, 2)
+	assert.Equal(t, events.PriceChanged, received[1].Kind)
+
+	stored, err := exp.FindListing(l.URL)
+	require.NoError(t, err)
+	assert.Equal(t, edited.Title, stored.Title, "the scraped fields follow the edit")
+	edits, err := exp.ListingEdits(hash, time.Time{}, 0)
+	require.NoError(t, err)
+	require.Len(t, edits, 1)
+	assert.Equal(t, "title", edits[0].Field)
+	assert.Equal(t, "2021 Evil Wreckoning {+V2, new shock+}", edits[0].Diff)
+}
+
+func TestDBExporterFollowsFingerprint(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	l := listing.Listing{Title: "2021 Evil Wreckoning", Year: "2021", Manufacturer: "Evil", Model: "Wreckoning",
+		Price: "3900", Currency: "USD", URL: "https://example.com/bikes/42"}
+	require.NoError(t, exp.Export([]listing.Listing{l}))
+
+	// a typo fixed in the title of a listing without a Pinkbike ID
+	edited := l
+	edited.Title = "2021 Evil Wreckoning LB"
+	known, err := exp.FingerprintExists(edited.Fingerprint())
+	require.NoError(t, err)
+	assert.True(t, known)
+	require.NoError(t, exp.Export([]listing.Listing{edited}))
+
+	var count int
+	require.NoError(t, exp.db.QueryRow("SELECT COUNT(*) FROM listings").Scan(&count))
+	assert.Equal(t, 1, count)
+	var hash string
+	require.NoError(t, exp.db.QueryRow("SELECT hash FROM listings WHERE fingerprint = ?", l.Fingerprint()).Scan(&hash))
+	assert.Equal(t, edited.ComputeHash(), hash, "the strict hash still changes with the title")
+
+	// without a URL two listings of the same bike are told apart by their titles
+	other := listing.Listing{Title: "2021 Evil Wreckoning, fresh service", Year: "2021", Manufacturer: "Evil", Model: "Wreckoning",
+		Price: "3700", Currency: "USD"}
+	another := other
+	another.Title = "Evil Wreckoning 2021"
+	require.NoError(t, exp.Export([]listing.Listing{other, another}))
+	require.NoError(t, exp.db.QueryRow("SELECT COUNT(*) FROM listings").Scan(&count))
+	assert.Equal(t, 3, count)
+}
+
+func TestDBExporterKeepsListingsSharingAHash(t *testing.T) {
+	bus := events.NewBus()
+	var received []events.Event
+	bus.Subscribe("test", func(e events.Event) error {
+		received = append(received, e)
+		return nil
+	})
+
+	exp := newTestDBExporter(t, bus)
+	// two sellers posting the same bike with the same title
+	first := listing.Listing{Title: "2022 Santa Cruz Megatower", Year: "2022", Manufacturer: "Santa Cruz", Model: "Megatower",
+		Price: "4200", Currency: "USD", URL: "https://www.pinkbike.com/buysell/3905066/", ListingID: 3905066}
+	second := first
+	second.Price, second.URL, second.ListingID = "3800", "https://www.pinkbike.com/buysell/3907881/", 3907881
+	require.Equal(t, first.ComputeHash(), second.ComputeHash())
+
+	require.NoError(t, exp.Export([]listing.Listing{first, second}))
+	require.NoError(t, exp.Export([]listing.Listing{first, second}))
+
+	var count int
+	require.NoError(t, exp.db.QueryRow("SELECT COUNT(*) FROM listings").Scan(&count))
+	assert.Equal(t, 2, count)
+	require.Len(t, received, 2, "re-exporting reports no price changes")
+	assert.Equal(t, events.ListingDiscovered, received[0].Kind)
+	assert.Equal(t, events.ListingDiscovered, received[1].Kind)
+	assert.NotEqual(t, received[0].Listing.Hash, received[1].Listing.Hash)
+
+	for _, l := range []listing.Listing{first, second} {
+		stored, err := exp.FindListing(l.URL)
+		require.NoError(t, err)
+		assert.Equal(t, l.Price, stored.Price)
+		require.NoError(t, exp.db.QueryRow("SELECT COUNT(*) FROM price_history WHERE listing_hash = ?", stored.Hash).Scan(&count))
+		assert.Equal(t, 1, count)
+	}
+}
+
+func TestMigrateMakesFingerprintsUnique(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	_, err := exp.db.Exec("DROP INDEX idx_listings_fingerprint_unique")
+	require.NoError(t, err)
+	_, err = exp.db.Exec(`
+        INSERT INTO listings (title, url, listing_id, fingerprint, hash, last_seen) VALUES
+            ('2021 Evil Wreckoning', 'https://www.pinkbike.com/buysell/3861316/', 3861316, 'pb:3861316', 'a', '2024-05-01 00:00:00'),
+            ('2021 Evil Wreckoning V2', 'https://www.pinkbike.com/buysell/3861316/', 3861316, 'pb:3861316', 'b', '2024-06-01 00:00:00')
+    `)
+	require.NoError(t, err)
+
+	require.NoError(t, migrate(exp.db))
+
+	var hash string
+	require.NoError(t, exp.db.QueryRow("SELECT hash FROM listings WHERE fingerprint = 'pb:3861316'").Scan(&hash))
+	assert.Equal(t, "b", hash, "the newest row keeps the fingerprint")
+	var fingerprint string
+	require.NoError(t, exp.db.QueryRow("SELECT fingerprint FROM listings WHERE hash = 'a'").Scan(&fingerprint))
+	assert.Equal(t, "a", fingerprint)
+
+	_, err = exp.db.Exec("UPDATE listings SET fingerprint = 'pb:3861316' WHERE hash = 'a'")
+	assert.Error(t, err, "fingerprints are unique")
+}
+
+func TestMigrateBackfillsListingIDs(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	_, err := exp.db.Exec(`
+        INSERT INTO listings (title, url, hash) VALUES
+            ('2021 Evil Wreckoning', '/buysell/3861316/?ref=list', 'a'),
+            ('2019 Trek Slash', 'https://example.com/bikes/1', 'b')
+    `)
+	require.NoError(t, err)
+
+	require.NoError(t, migrate(exp.db))
+
+	var url string
+	var id *int
+	require.NoError(t, exp.db.QueryRow("SELECT url, listing_id FROM listings WHERE hash = 'a'").Scan(&url, &id))
+	assert.Equal(t, "https://www.pinkbike.com/buysell/3861316/", url)
+	require.NotNil(t, id)
+	assert.Equal(t, 3861316, *id)
+
+	require.NoError(t, exp.db.QueryRow("SELECT url, listing_id FROM listings WHERE hash = 'b'").Scan(&url, &id))
+	assert.Equal(t, "https://example.com/bikes/1", url)
+	assert.Nil(t, id)
+}
+
+func TestMigrateBackfillsFingerprints(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	_, err := exp.db.Exec(`
+        INSERT INTO listings (title, manufacturer, model, url, listing_id, hash) VALUES
+            ('2021 Evil Wreckoning', 'Evil', 'Wreckoning', 'https://www.pinkbike.com/buysell/3861316/', 3861316, 'a'),
+            ('2019 Trek Slash', 'Trek', 'Slash', 'https://example.com/bikes/1', NULL, 'b'),
+            ('2020 Kona Process', 'Kona', 'Process', NULL, NULL, 'c')
+    `)
+	require.NoError(t, err)
+
+	require.NoError(t, migrate(exp.db))
+
+	fingerprint := func(hash string) string {
+		t.Helper()
+		var f string
+		require.NoError(t, exp.db.QueryRow("SELECT fingerprint FROM listings WHERE hash = ?", hash).Scan(&f))
+		return f
+	}
+	assert.Equal(t, "pb:3861316", fingerprint("a"))
+	assert.Equal(t, listing.Listing{Manufacturer: "Trek", Model: "Slash", URL: "https://example.com/bikes/1"}.Fingerprint(), fingerprint("b"))
+	assert.Equal(t, "c", fingerprint("c"), "listings without a URL are fingerprinted by their hash")
+}
+
+func TestMigrateReviewReasons(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	_, err := exp.db.Exec(`
+        INSERT INTO listings (title, hash, needs_review) VALUES
+            ('2021 Evil Wreckoning', 'a', 'year, rear travel'),
+            ('2019 Trek Slash', 'b', '')
+    `)
+	require.NoError(t, err)
+
+	require.NoError(t, migrate(exp.db))
+
+	var reasons string
+	require.NoError(t, exp.db.QueryRow("SELECT needs_review FROM listings WHERE hash = 'a'").Scan(&reasons))
+	assert.Equal(t, `["year","rear travel"]`, reasons)
+	assert.Equal(t, listing.Reasons{"year", "rear travel"}, listing.ParseReasons(reasons))
+	require.NoError(t, exp.db.QueryRow("SELECT needs_review FROM listings WHERE hash = 'b'").Scan(&reasons))
+	assert.Empty(t, reasons)
+}
+
+func TestDBExporterStoresUsage(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	ridden := listing.Listing{Title: "2021 Evil Wreckoning", Price: "3900", Currency: "USD"}.
+		WithDetails(listing.ListingDetails{Description: "Less than 200km on it, never raced."})
+	unridden := listing.Listing{Title: "2022 Evil Offering", Price: "4900", Currency: "USD"}.
+		WithDetails(listing.ListingDetails{Description: "Brand new, never ridden"})
+	unknown := listing.Listing{Title: "2020 Evil Following", Price: "2900", Currency: "USD"}.
+		WithDetails(listing.ListingDetails{Description: "Great bike"})
+	require.NoError(t, exp.Export([]listing.Listing{ridden, unridden, unknown}))
+
+	var km *int
+	var raced bool
+	require.NoError(t, exp.db.QueryRow("SELECT estimated_km, never_raced FROM listings WHERE title = ?", ridden.Title).Scan(&km, &raced))
+	require.NotNil(t, km)
+	assert.Equal(t, 200, *km)
+	assert.True(t, raced)
+
+	require.NoError(t, exp.db.QueryRow("SELECT estimated_km FROM listings WHERE title = ?", unridden.Title).Scan(&km))
+	require.NotNil(t, km)
+	assert.Equal(t, 0, *km)
+
+	require.NoError(t, exp.db.QueryRow("SELECT estimated_km FROM listings WHERE title = ?", unknown.Title).Scan(&km))
+	assert.Nil(t, km)
+}
+
+func TestDBExporterStoresPhotoAndViewCounts(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	l := listing.Listing{Title: "2022 Evil Offering", Price: "4200", Currency: "USD"}.
+		WithDetails(listing.ListingDetails{Description: "Demo bike", PhotoCount: 12, ViewCount: 1248})
+	require.NoError(t, exp.Export([]listing.Listing{l}))
+
+	// listings already stored are exported again without details
+	l.Details = listing.ListingDetails{}
+	require.NoError(t, exp.Export([]listing.Listing{l}))
+
+	var photos, views int
+	require.NoError(t, exp.db.QueryRow("SELECT photo_count, view_count FROM listings WHERE title = ?", l.Title).Scan(&photos, &views))
+	assert.Equal(t, 12, photos)
+	assert.Equal(t, 1248, views)
+}
+
+func TestDBExporterStoresListedPrice(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	l := listing.Listing{Title: "2018 Commencal Meta AM", Price: "1825", Currency: "CAD", ListedPrice: "2500", ExchangeRate: 0.73}
+	require.NoError(t, exp.Export([]listing.Listing{l}))
+
+	// listings read back from older CSV files have no listed price
+	l.ListedPrice = ""
+	require.NoError(t, exp.Export([]listing.Listing{l}))
+
+	var price, listed string
+	require.NoError(t, exp.db.QueryRow("SELECT price, listed_price FROM listings WHERE title = ?", l.Title).Scan(&price, &listed))
+	assert.Equal(t, "1825", price)
+	assert.Equal(t, "2500", listed)
+	var rate float64
+	require.NoError(t, exp.db.QueryRow("SELECT listed_price, exchange_rate FROM price_history WHERE listing_hash = ?", l.ComputeHash()).Scan(&listed, &rate))
+	assert.Equal(t, "2500", listed)
+	assert.Equal(t, 0.73, rate)
+}
+
+func TestDBExporterStoresPrediction(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	l := listing.Listing{Title: "2021 Evil Wreckoning", Price: "3900", Currency: "USD", PredictedPrice: 4500}
+	require.NoError(t, exp.Export([]listing.Listing{l}))
+
+	// a run without the model keeps the prediction and updates the residual
+	l.Price, l.PredictedPrice = "3500", 0
+	require.NoError(t, exp.Export([]listing.Listing{l}))
+
+	var predicted, residual float64
+	require.NoError(t, exp.db.QueryRow("SELECT predicted_price, residual FROM listings WHERE title = ?", l.Title).Scan(&predicted, &residual))
+	assert.Equal(t, 4500.0, predicted)
+	assert.Equal(t, -1000.0, residual)
+
+	stored, err := exp.StoredListings("")
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	assert.Equal(t, 4500.0, stored[0].PredictedPrice)
+}
+
+func TestDBExporterKeepsPhone(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	signedIn := listing.Listing{Title: "2021 Evil Wreckoning", Price: "3900", Currency: "USD"}.
+		WithDetails(listing.ListingDetails{Description: "Great bike", Phone: "604-555-0123"})
+	require.NoError(t, exp.Export([]listing.Listing{signedIn}))
+
+	// a later run as a visitor sees no phone number
+	visitor := signedIn
+	visitor.Details.Phone = ""
+	require.NoError(t, exp.Export([]listing.Listing{visitor}))
+
+	var phone string
+	require.NoError(t, exp.db.QueryRow("SELECT phone FROM listings WHERE title = ?", signedIn.Title).Scan(&phone))
+	assert.Equal(t, "604-555-0123", phone)
+}
+
+func TestDBExporterStoresDescriptionQuality(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	l := listing.Listing{Title: "2022 Evil Offering", Price: "4200", Currency: "USD"}.
+		WithDetails(listing.ListingDetails{Description: "Demo bike", PhotoCount: 2})
+	require.NoError(t, exp.Export([]listing.Listing{l}))
+
+	// listings already stored are exported again without details
+	l.Details = listing.ListingDetails{}
+	require.NoError(t, exp.Export([]listing.Listing{l}))
+
+	var score float64
+	var issues string
+	require.NoError(t, exp.db.QueryRow("SELECT quality_score, quality_issues FROM listings WHERE title = ?", l.Title).Scan(&score, &issues))
+	assert.Equal(t, 0.08, score)
+	assert.Equal(t, "short description; few specs; fewer than 4 photos; no contact info", issues)
+}
+
+func TestDBExporterStoresKinds(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	bikes := []listing.Listing{
+		listing.RawListing{Title: "2021 Evil Wreckoning", Price: "4000 USD", URL: "https://www.pinkbike.com/buysell/1/"}.PostProcess(1, nil),
+		listing.RawListing{Title: "2021 Evil Wreckoning LB", Price: "3800 USD", URL: "https://www.pinkbike.com/buysell/2/"}.PostProcess(1, nil),
+	}
+	frame := listing.RawListing{Title: "2021 Evil Wreckoning frame only", Price: "1500 USD", URL: "https://www.pinkbike.com/buysell/3/"}.PostProcess(1, nil).
+		WithDetails(listing.ListingDetails{Description: "With a DHX2, 230x65."})
+	frame.NeedsReview = nil
+	for i := range bikes {
+		bikes[i].NeedsReview = nil
+	}
+	require.NoError(t, exp.Export(append(bikes, frame)))
+
+	stored, err := exp.FindListing(frame.URL)
+	require.NoError(t, err)
+	assert.Equal(t, parser.KindFrame, stored.Kind)
+	assert.Equal(t, "230x65", stored.ShockSize)
+
+	median, count, err := exp.MedianPrice("Evil", "Wreckoning", "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count, "frames are left out of bike medians")
+	assert.Equal(t, 3900.0, median)
+}
+
+func TestDBExporterStoresWheels(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	mullet := listing.RawListing{Title: "2022 Santa Cruz Bronson", Price: "4000 USD", WheelSize: "29/27.5 mullet",
+		URL: "https://www.pinkbike.com/buysell/1/"}.PostProcess(1, nil)
+	require.NoError(t, exp.Export([]listing.Listing{mullet}))
+
+	stored, err := exp.FindListing(mullet.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "29/27.5 mullet", stored.WheelSize)
+	assert.Equal(t, parser.Wheels{Front: 29, Rear: 27.5, Mullet: true}, stored.Wheels)
+}
+
+func TestDBExporterKeepsBuildTier(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	l := listing.RawListing{Title: "2021 Ibis Ripmo V2", Price: "3900 USD", URL: "https://www.pinkbike.com/buysell/2/"}.PostProcess(1, nil)
+	require.NoError(t, exp.Export([]listing.Listing{l.WithDetails(listing.ListingDetails{Description: "XT drivetrain, Fox 36"})}))
+
+	// a later scrape that skips the details page knows no kit
+	require.NoError(t, exp.Export([]listing.Listing{l}))
+
+	stored, err := exp.FindListing(l.URL)
+	require.NoError(t, err)
+	assert.Equal(t, parser.BuildTier{Kit: "XT", Rank: parser.TierUpper}, stored.BuildTier)
+	assert.Equal(t, "V2", stored.Generation)
+}
+
+func TestMigrateBackfillsWheels(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	_, err := exp.db.Exec(`
+        INSERT INTO listings (title, wheel_size, hash) VALUES
+            ('2021 Evil Wreckoning', '29', 'a'),
+            ('2022 Santa Cruz Bronson MX', '27.5 / 650B', 'b'),
+            ('2020 Kona Process', 'Other', 'c')
+    `)
+	require.NoError(t, err)
+	_, err = exp.db.Exec("PRAGMA user_version = 11")
+	require.NoError(t, err)
+
+	require.NoError(t, migrate(exp.db))
+
+	wheels := func(hash string) parser.Wheels {
+		t.Helper()
+		var front, rear sql.NullFloat64
+		var w parser.Wheels
+		require.NoError(t, exp.db.QueryRow("SELECT wheel_front, wheel_rear, mullet FROM listings WHERE hash = ?", hash).
+			Scan(&front, &rear, &w.Mullet))
+		w.Front, w.Rear = front.Float64, rear.Float64
+		return w
+	}
+	assert.Equal(t, parser.Wheels{Front: 29, Rear: 29}, wheels("a"))
+	assert.Equal(t, parser.Wheels{Front: 29, Rear: 27.5, Mullet: true}, wheels("b"))
+	assert.Equal(t, parser.Wheels{}, wheels("c"))
+}
+
+func TestDBExporterKeepsInferredYears(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	raw := listing.RawListing{Title: "Santa Cruz Megatower CC", Price: "4200 USD", URL: "https://www.pinkbike.com/buysell/1/"}
+	detailed := raw.PostProcess(1, nil).WithDetails(listing.ListingDetails{Description: "Fox 38 Grip2"})
+	require.NoError(t, exp.Export([]listing.Listing{detailed}))
+
+	// a later scrape skips the detail page of a known listing
+	require.NoError(t, exp.Export([]listing.Listing{raw.PostProcess(1, nil)}))
+
+	stored, err := exp.FindListing(raw.URL)
+	require.NoError(t, err)
+	assert.Empty(t, stored.Year)
+	assert.Equal(t, parser.YearRange{Min: 2021}, stored.InferredYears)
+}
@@ -0,0 +1,324 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Formats Dump can write
+const (
+	DumpCSV = "csv"
+	// DumpJSON writes one JSON array of row objects
+	DumpJSON = "json"
+	// DumpJSONLines writes one JSON object per line, which stays readable
+	// line by line however large the table
+	DumpJSONLines = "jsonl"
+	// DumpParquet writes a Parquet file, for analysis tools that load it far
+	// faster than text
+	DumpParquet = "parquet"
+)
+
+// DumpTables are the tables Dump can write
+var DumpTables = []string{"listings", "price_history"}
+
+// DumpOptions selects the rows Dump writes. Filters on listing fields also
+// apply to price history, through the listing each entry belongs to.
+type DumpOptions struct {
+	// Table is one of DumpTables
+	Table  string
+	Format string
+	// Since and Until limit listings to those seen between them, and price
+	// history to entries recorded between them. Until is exclusive and either
+	// may be zero to leave that end open.
+	Since, Until time.Time
+	// Manufacturer and Category match exactly, ignoring case; empty matches all
+	Manufacturer string
+	Category     string
+	// ActiveOnly leaves out listings no longer on Pinkbike
+	ActiveOnly bool
+}
+
+func (o DumpOptions) validate() error {
+	switch o.Table {
+	case "listings", "price_history":
+	default:
+		return fmt.Errorf("unknown table %q (available: %s)", o.Table, strings.Join(DumpTables, ", "))
+	}
+	switch o.Format {
+	case DumpCSV, DumpJSON, DumpJSONLines, DumpParquet:
+	default:
+		return fmt.Errorf("unknown dump format %q (available: %s, %s, %s, %s)", o.Format, DumpCSV, DumpJSON, DumpJSONLines, DumpParquet)
+	}
+	if !o.Since.IsZero() && !o.Until.IsZero() && !o.Until.After(o.Since) {
+		return fmt.Errorf("dump range ends before it starts")
+	}
+	return nil
+}
+
+// query builds the SELECT for the options. Listings are written with every
+// one of columns, descriptions decompressed; price history gains the
+// listing's URL so entries can be matched up without the hash.
+func (o DumpOptions) query(columns []string) (string, []interface{}) {
+	where, args := o.listingConditions(o.Table == "listings")
+
+	var query string
+	if o.Table == "listings" {
+		selected := make([]string, len(columns))
+		for i, c := range columns {
+			selected[i] = "l." + c
+			if c == "description" {
+				selected[i] = "decompress(l.description) AS description"
+			}
+		}
+		query = "SELECT " + strings.Join(selected, ", ") + " FROM listings l"
+	} else {
+		if !o.Since.IsZero() {
+			where = append(where, "datetime(p.recorded_at) >= datetime(?)")
+			args = append(args, o.Since.UTC().Format(sqliteTimeFormat))
+		}
+		if !o.Until.IsZero() {
+			where = append(where, "datetime(p.recorded_at) < datetime(?)")
+			args = append(args, o.Until.UTC().Format(sqliteTimeFormat))
+		}
+		query = `SELECT p.id, p.listing_hash, l.url, p.price, p.currency, p.listed_price, p.exchange_rate, p.exchange_rate_id, p.recorded_at
+            FROM price_history p LEFT JOIN listings l ON l.hash = p.listing_hash`
+	}
+
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	if o.Table == "listings" {
+		query += " ORDER BY l.id"
+	} else {
+		query += " ORDER BY p.id"
+	}
+	return query, args
+}
+
+// listingConditions returns the WHERE conditions on the listings table,
+// aliased l, the options select. The date range applies to when listings were
+// seen only when seen is set.
+func (o DumpOptions) listingConditions(seen bool) ([]string, []interface{}) {
+	var where []string
+	var args []interface{}
+	if o.Manufacturer != "" {
+		where = append(where, "l.manufacturer = ? COLLATE NOCASE")
+		args = append(args, o.Manufacturer)
+	}
+	if o.Category != "" {
+		where = append(where, "l.category = ? COLLATE NOCASE")
+		args = append(args, o.Category)
+	}
+	if o.ActiveOnly {
+		where = append(where, "l.active = 1")
+	}
+	if !seen {
+		return where, args
+	}
+	if !o.Since.IsZero() {
+		where = append(where, "datetime(l.last_seen) >= datetime(?)")
+		args = append(args, o.Since.UTC().Format(sqliteTimeFormat))
+	}
+	if !o.Until.IsZero() {
+		where = append(where, "datetime(l.first_seen) < datetime(?)")
+		args = append(args, o.Until.UTC().Format(sqliteTimeFormat))
+	}
+	return where, args
+}
+
+// Dump writes the rows of a table matching opts to w, streaming them so
+// databases of any size can be dumped. It returns the number of rows written.
+func (e *DBExporter) Dump(w io.Writer, opts DumpOptions) (int, error) {
+	if err := opts.validate(); err != nil {
+		return 0, err
+	}
+
+	var columns []string
+	var err error
+	if opts.Table == "listings" {
+		if columns, err = e.tableColumns(opts.Table); err != nil {
+			return 0, err
+		}
+	}
+	query, args := opts.query(columns)
+	rows, err := e.db.Query(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query %s: %w", opts.Table, err)
+	}
+	defer rows.Close()
+
+	if columns, err = rows.Columns(); err != nil {
+		return 0, fmt.Errorf("failed to read columns: %w", err)
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read columns: %w", err)
+	}
+	types := make([]string, len(columnTypes))
+	for i, ct := range columnTypes {
+		types[i] = ct.DatabaseTypeName()
+	}
+	out := newDumpWriter(w, opts.Format, columns, types)
+	if err := out.begin(); err != nil {
+		return 0, fmt.Errorf("failed to write dump: %w", err)
+	}
+
+	raw := make([]interface{}, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for i := range raw {
+		ptrs[i] = &raw[i]
+	}
+	n := 0
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return n, fmt.Errorf("failed to scan row: %w", err)
+		}
+		for i, v := range raw {
+			raw[i] = dumpValue(v)
+		}
+		if err := out.row(raw); err != nil {
+			return n, fmt.Errorf("failed to write dump: %w", err)
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, fmt.Errorf("failed to read %s: %w", opts.Table, err)
+	}
+	if err := out.end(); err != nil {
+		return n, fmt.Errorf("failed to write dump: %w", err)
+	}
+	return n, nil
+}
+
+// tableColumns returns the columns of a table in schema order
+func (e *DBExporter) tableColumns(table string) ([]string, error) {
+	rows, err := e.db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s columns: %w", table, err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to read %s columns: %w", table, err)
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
+
+// dumpValue converts a scanned value to one that reads the same in CSV and
+// JSON: text instead of bytes and times in RFC 3339
+func dumpValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	}
+	return v
+}
+
+// dumpWriter writes rows in one of the dump formats
+type dumpWriter struct {
+	w       io.Writer
+	format  string
+	columns []string
+	csv     *csv.Writer
+	parquet *parquetWriter
+	rows    int
+}
+
+// newDumpWriter writes columns, of the given declared SQL types, to w
+func newDumpWriter(w io.Writer, format string, columns, types []string) *dumpWriter {
+	d := &dumpWriter{w: w, format: format, columns: columns}
+	switch format {
+	case DumpCSV:
+		d.csv = csv.NewWriter(w)
+	case DumpParquet:
+		d.parquet = newParquetWriter(w, columns, types)
+	}
+	return d
+}
+
+func (d *dumpWriter) begin() error {
+	switch d.format {
+	case DumpCSV:
+		return d.csv.Write(d.columns)
+	case DumpJSON:
+		_, err := io.WriteString(d.w, "[")
+		return err
+	case DumpParquet:
+		return d.parquet.begin()
+	}
+	return nil
+}
+
+func (d *dumpWriter) row(values []interface{}) error {
+	d.rows++
+	if d.format == DumpParquet {
+		return d.parquet.row(values)
+	}
+	if d.format == DumpCSV {
+		record := make([]string, len(values))
+		for i, v := range values {
+			if v != nil {
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		return d.csv.Write(record)
+	}
+
+	// marshal by hand to keep the columns in schema order
+	var b strings.Builder
+	if d.format == DumpJSON && d.rows > 1 {
+		b.WriteString(",")
+	}
+	if d.format == DumpJSON {
+		b.WriteString("\n  ")
+	}
+	b.WriteString("{")
+	for i, v := range values {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		key, _ := json.Marshal(d.columns[i])
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		b.Write(key)
+		b.WriteString(":")
+		b.Write(value)
+	}
+	b.WriteString("}")
+	if d.format == DumpJSONLines {
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(d.w, b.String())
+	return err
+}
+
+func (d *dumpWriter) end() error {
+	switch d.format {
+	case DumpCSV:
+		d.csv.Flush()
+		return d.csv.Error()
+	case DumpJSON:
+		closing := "\n]\n"
+		if d.rows == 0 {
+			closing = "]\n"
+		}
+		_, err := io.WriteString(d.w, closing)
+		return err
+	case DumpParquet:
+		return d.parquet.end()
+	}
+	return nil
+}
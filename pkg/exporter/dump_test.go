@@ -0,0 +1,113 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/listing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDumpTestDB stores an Evil seen in May and a Yeti seen in June
+func newDumpTestDB(t *testing.T) *DBExporter {
+	t.Helper()
+
+	exp := newTestDBExporter(t, nil)
+	exp.clock = clock.Fixed(time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC))
+	require.NoError(t, exp.Export([]listing.Listing{
+		{Title: "2021 Evil Wreckoning", Manufacturer: "Evil", Price: "3900", Currency: "USD", Details: listing.ListingDetails{Description: "Coil shock, fresh bearings"}, URL: "https://pinkbike.com/1", Category: "enduro"},
+	}))
+	exp.clock = clock.Fixed(time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC))
+	require.NoError(t, exp.Export([]listing.Listing{
+		{Title: "2022 Yeti SB150", Manufacturer: "Yeti", Price: "4500", Currency: "USD", URL: "https://pinkbike.com/2", Category: "enduro"},
+	}))
+	return exp
+}
+
+func TestDumpListingsCSV(t *testing.T) {
+	exp := newDumpTestDB(t)
+
+	var out bytes.Buffer
+	n, err := exp.Dump(&out, DumpOptions{Table: "listings", Format: DumpCSV})
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	records, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	columns := map[string]int{}
+	for i, c := range records[0] {
+		columns[c] = i
+	}
+	assert.Equal(t, "2021 Evil Wreckoning", records[1][columns["title"]])
+	assert.Equal(t, "Coil shock, fresh bearings", records[1][columns["description"]], "descriptions are decompressed")
+	assert.Equal(t, "2024-05-01T08:00:00Z", records[1][columns["first_seen"]])
+	assert.Equal(t, "2022 Yeti SB150", records[2][columns["title"]])
+}
+
+func TestDumpFilters(t *testing.T) {
+	exp := newDumpTestDB(t)
+	titles := func(opts DumpOptions) []string {
+		t.Helper()
+
+		var out bytes.Buffer
+		opts.Table, opts.Format = "listings", DumpJSONLines
+		_, err := exp.Dump(&out, opts)
+		require.NoError(t, err)
+
+		var titles []string
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var row map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(line), &row))
+			titles = append(titles, row["title"].(string))
+		}
+		return titles
+	}
+
+	assert.Equal(t, []string{"2022 Yeti SB150"}, titles(DumpOptions{Manufacturer: "yeti"}))
+	assert.Equal(t, []string{"2022 Yeti SB150"}, titles(DumpOptions{Since: time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)}))
+	assert.Equal(t, []string{"2021 Evil Wreckoning"}, titles(DumpOptions{Until: time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)}))
+	assert.Empty(t, titles(DumpOptions{Category: "downhill"}))
+}
+
+func TestDumpPriceHistoryJSON(t *testing.T) {
+	exp := newDumpTestDB(t)
+
+	var out bytes.Buffer
+	n, err := exp.Dump(&out, DumpOptions{Table: "price_history", Format: DumpJSON, Manufacturer: "Evil"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	var rows []map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &rows))
+	require.Len(t, rows, 1)
+	assert.Equal(t, "https://pinkbike.com/1", rows[0]["url"])
+	assert.Equal(t, "3900", rows[0]["price"])
+
+	out.Reset()
+	_, err = exp.Dump(&out, DumpOptions{Table: "price_history", Format: DumpJSON, Manufacturer: "Trek"})
+	require.NoError(t, err)
+	assert.Equal(t, "[]\n", out.String())
+}
+
+func TestDumpRejectsBadOptions(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+
+	_, err := exp.Dump(&bytes.Buffer{}, DumpOptions{Table: "runs", Format: DumpCSV})
+	assert.ErrorContains(t, err, `unknown table "runs"`)
+	_, err = exp.Dump(&bytes.Buffer{}, DumpOptions{Table: "listings", Format: "xlsx"})
+	assert.ErrorContains(t, err, `unknown dump format "xlsx"`)
+	_, err = exp.Dump(&bytes.Buffer{}, DumpOptions{Table: "listings", Format: DumpCSV,
+		Since: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), Until: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)})
+	assert.ErrorContains(t, err, "ends before it starts")
+}
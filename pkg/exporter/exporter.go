@@ -1,7 +1,9 @@
 package exporter
 
 import (
+	"pinkbike-scraper/pkg/events"
 	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/progress"
 )
 
 // Exporter interface defines methods for exporting listings
@@ -9,3 +11,35 @@ type Exporter interface {
 	Export(listings []listing.Listing) error
 	Close() error
 }
+
+// FileExporter is an Exporter that writes local files, which can be uploaded
+// once the run is done
+type FileExporter interface {
+	Exporter
+	Files() []string
+}
+
+// ProgressExporter is an Exporter that advances a progress bar as it stores
+// each listing, for exports slow enough to watch
+type ProgressExporter interface {
+	Exporter
+	ExportWithProgress(listings []listing.Listing, bar *progress.Bar) error
+}
+
+// Subscribe has exp export the listings of every ListingsScraped event on bus,
+// drawing how fast it goes on out. A failing export is reported to the bus
+// and does not stop the others.
+func Subscribe(bus *events.Bus, name string, exp Exporter, out *progress.Output) {
+	bus.Subscribe(name, func(e events.Event) error {
+		bar := out.Start(name, "listings", len(e.Listings))
+		defer bar.Done()
+		if p, ok := exp.(ProgressExporter); ok {
+			return p.ExportWithProgress(e.Listings, bar)
+		}
+		if err := exp.Export(e.Listings); err != nil {
+			return err
+		}
+		bar.Add(len(e.Listings))
+		return nil
+	}, events.ListingsScraped)
+}
@@ -0,0 +1,207 @@
+package exporter
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"pinkbike-scraper/pkg/listing"
+)
+
+// ErrListingNotFound is returned for a listing that is not stored
+var ErrListingNotFound = errors.New("listing not found")
+
+// FavoriteChange is a change to a tracked field of a favorite listing
+type FavoriteChange struct {
+	Hash, Title, URL string
+	// Field is one of "price", "listed price", "currency", "condition",
+	// "negotiable" or "status", which is "active" or "sold"
+	Field     string
+	Old, New  string
+	ChangedAt time.Time
+}
+
+// favoriteFields are the fields changes are recorded for on favorites, with
+// the expression reading each from the listings table
+var favoriteFields = []struct{ name, column string }{
+	{"price", "COALESCE(l.price, '')"},
+	{"listed price", "COALESCE(l.listed_price, '')"},
+	{"currency", "COALESCE(l.currency, '')"},
+	{"condition", "COALESCE(l.condition, '')"},
+	{"negotiable", "CASE WHEN l.negotiable THEN 'yes' ELSE 'no' END"},
+	{"status", "CASE WHEN l.active = 1 THEN 'active' ELSE 'sold' END"},
+}
+
+// SetFavorite marks the listing with hash as a favorite, or unmarks it.
+// Marking a listing that is not stored returns ErrListingNotFound.
+func (e *DBExporter) SetFavorite(hash string, favorite bool) error {
+	if !favorite {
+		if _, err := e.db.Exec("DELETE FROM favorites WHERE listing_hash = ?", hash); err != nil {
+			return fmt.Errorf("failed to update favorite: %w", err)
+		}
+		return nil
+	}
+
+	exists, err := e.ListingExists(hash)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrListingNotFound
+	}
+	if _, err := e.db.Exec("INSERT INTO favorites (listing_hash, added_at) VALUES (?, ?) ON CONFLICT(listing_hash) DO NOTHING", hash, e.now()); err != nil {
+		return fmt.Errorf("failed to update favorite: %w", err)
+	}
+	return nil
+}
+
+// IsFavorite reports whether the listing with fingerprint is marked as a
+// favorite
+func (e *DBExporter) IsFavorite(fingerprint string) (bool, error) {
+	var favorite bool
+	if err := e.db.QueryRow(`
+        SELECT EXISTS(SELECT 1 FROM favorites f JOIN listings l ON l.hash = f.listing_hash WHERE l.fingerprint = ?)
+    `, fingerprint).Scan(&favorite); err != nil {
+		return false, fmt.Errorf("failed to look up favorite: %w", err)
+	}
+	return favorite, nil
+}
+
+// Favorites returns the hashes of the listings marked as favorites
+func (e *DBExporter) Favorites() (map[string]bool, error) {
+	rows, err := e.db.Query("SELECT listing_hash FROM favorites")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load favorites: %w", err)
+	}
+	defer rows.Close()
+
+	favorites := make(map[string]bool)
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("failed to load favorites: %w", err)
+		}
+		favorites[hash] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load favorites: %w", err)
+	}
+	return favorites, nil
+}
+
+// FavoriteListings returns the listings marked as favorites, most recently
+// marked first
+func (e *DBExporter) FavoriteListings() ([]listing.Listing, error) {
+	return e.loadListings(`
+        JOIN favorites f ON f.listing_hash = listings.hash
+        ORDER BY datetime(f.added_at) DESC, listings.id DESC`)
+}
+
+// FavoriteChanges returns the changes recorded on favorites since since,
+// oldest first
+func (e *DBExporter) FavoriteChanges(since time.Time) ([]FavoriteChange, error) {
+	rows, err := e.db.Query(`
+        SELECT c.listing_hash, COALESCE(l.title, ''), COALESCE(l.url, ''), c.field, c.old_value, c.new_value, c.changed_at
+        FROM favorite_changes c LEFT JOIN listings l ON l.hash = c.listing_hash
+        WHERE datetime(c.changed_at) >= datetime(?)
+        ORDER BY datetime(c.changed_at), c.id
+    `, since.UTC().Format(sqliteTimeFormat))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load favorite changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []FavoriteChange
+	for rows.Next() {
+		var c FavoriteChange
+		var changedAt interface{}
+		if err := rows.Scan(&c.Hash, &c.Title, &c.URL, &c.Field, &c.Old, &c.New, &changedAt); err != nil {
+			return nil, fmt.Errorf("failed to load favorite changes: %w", err)
+		}
+		if c.ChangedAt, err = parseSQLiteTime(changedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load favorite changes: %w", err)
+	}
+	return changes, nil
+}
+
+// favoriteSnapshot is the tracked fields of each favorite, by listing row ID
+// since an export can re-key a listing
+type favoriteSnapshot map[int64][]string
+
+// snapshotFavorites reads the tracked fields of every favorite
+func snapshotFavorites(tx *sql.Tx) (favoriteSnapshot, error) {
+	query := "SELECT l.id, l.hash"
+	for _, f := range favoriteFields {
+		query += ", " + f.column
+	}
+	rows, err := tx.Query(query + " FROM favorites f JOIN listings l ON l.hash = f.listing_hash")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read favorites: %w", err)
+	}
+	defer rows.Close()
+
+	snapshot := favoriteSnapshot{}
+	for rows.Next() {
+		var id int64
+		// the hash comes first so the values line up with favoriteFields
+		values := make([]string, len(favoriteFields)+1)
+		dest := []interface{}{&id}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to read favorites: %w", err)
+		}
+		snapshot[id] = values
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read favorites: %w", err)
+	}
+	return snapshot, nil
+}
+
+// recordFavoriteChanges records how the favorites changed since before was
+// taken
+func (e *DBExporter) recordFavoriteChanges(tx *sql.Tx, before favoriteSnapshot) error {
+	if len(before) == 0 {
+		return nil
+	}
+	after, err := snapshotFavorites(tx)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int64, 0, len(before))
+	for id := range before {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		old := before[id]
+		current, ok := after[id]
+		if !ok {
+			continue
+		}
+		hash := current[0]
+		for i, f := range favoriteFields {
+			if old[i+1] == current[i+1] {
+				continue
+			}
+			if _, err := tx.Exec(`
+                INSERT INTO favorite_changes (listing_hash, field, old_value, new_value, changed_at)
+                VALUES (?, ?, ?, ?, ?)
+            `, hash, f.name, old[i+1], current[i+1], e.now()); err != nil {
+				return fmt.Errorf("failed to record favorite change: %w", err)
+			}
+		}
+	}
+	return nil
+}
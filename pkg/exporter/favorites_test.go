@@ -0,0 +1,92 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/listing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFavorites(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	l := listing.Listing{Title: "2019 SC Nomad 27.5", Year: "2019", Manufacturer: "NoManufacturer", Model: "NoModelFound",
+		Price: "3000", Currency: "USD", NeedsReview: listing.Reasons{"manufacturer"}}
+	require.NoError(t, exp.Export([]listing.Listing{l}))
+
+	require.NoError(t, exp.SetFavorite(l.ComputeHash(), true))
+	require.NoError(t, exp.SetFavorite(l.ComputeHash(), true), "marking a favorite twice is harmless")
+
+	// correcting the listing changes its hash, the favorite follows it
+	stored, err := exp.ListingsNeedingReview(0)
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	corrected, err := exp.SaveCorrection(stored[0], Correction{Year: "2019", Manufacturer: "Santa Cruz", Model: "Nomad"})
+	require.NoError(t, err)
+
+	favorites, err := exp.Favorites()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{corrected.Hash: true}, favorites)
+
+	require.NoError(t, exp.SetFavorite(corrected.Hash, false))
+	favorites, err = exp.Favorites()
+	require.NoError(t, err)
+	assert.Empty(t, favorites)
+}
+
+func TestFavoriteChanges(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	exp.clock = clock.Fixed(start)
+	favorite := listing.Listing{Title: "2021 Evil Wreckoning", Price: "3900", Currency: "USD", Condition: "Good"}
+	other := listing.Listing{Title: "2019 Trek Slash", Price: "2500", Currency: "USD"}
+	require.NoError(t, exp.Export([]listing.Listing{favorite, other}))
+
+	assert.ErrorIs(t, exp.SetFavorite("missing", true), ErrListingNotFound)
+	require.NoError(t, exp.SetFavorite(favorite.ComputeHash(), true))
+
+	exp.clock = clock.Fixed(start.AddDate(0, 0, 1))
+	favorite.Price, favorite.Negotiable = "3500", true
+	other.Price = "2200"
+	require.NoError(t, exp.Export([]listing.Listing{favorite, other}))
+
+	exp.clock = clock.Fixed(start.AddDate(0, 0, 10))
+	require.NoError(t, exp.Export(nil))
+
+	changes, err := exp.FavoriteChanges(start)
+	require.NoError(t, err)
+	require.Len(t, changes, 3, "only the favorite's changes are recorded")
+	assert.Equal(t, FavoriteChange{Hash: favorite.ComputeHash(), Title: favorite.Title, Field: "price", Old: "3900", New: "3500",
+		ChangedAt: start.AddDate(0, 0, 1)}, changes[0])
+	assert.Equal(t, "negotiable", changes[1].Field)
+	assert.Equal(t, []string{"status", "active", "sold"}, []string{changes[2].Field, changes[2].Old, changes[2].New})
+
+	changes, err = exp.FavoriteChanges(start.AddDate(0, 0, 5))
+	require.NoError(t, err)
+	assert.Len(t, changes, 1)
+
+	favorites, err := exp.FavoriteListings()
+	require.NoError(t, err)
+	require.Len(t, favorites, 1)
+	assert.Equal(t, "3500", favorites[0].Price)
+}
+
+func TestFindListing(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	l := listing.Listing{Title: "2021 Evil Wreckoning", Price: "3900", Currency: "USD",
+		URL: "https://www.pinkbike.com/buysell/3861316/", ListingID: 3861316}
+	require.NoError(t, exp.Export([]listing.Listing{l}))
+
+	for _, ref := range []string{l.ComputeHash(), l.URL, "/buysell/3861316/?ref=list", "3861316"} {
+		found, err := exp.FindListing(ref)
+		require.NoError(t, err, ref)
+		assert.Equal(t, l.Title, found.Title, ref)
+	}
+	_, err := exp.FindListing("https://www.pinkbike.com/buysell/1/")
+	assert.ErrorIs(t, err, ErrListingNotFound)
+	_, err = exp.FindListing("")
+	assert.ErrorIs(t, err, ErrListingNotFound)
+}
@@ -0,0 +1,224 @@
+package exporter
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/parser"
+)
+
+// ForgetRequest selects the data a takedown request covers: one listing by
+// its URL, every listing of a seller, or both
+type ForgetRequest struct {
+	URL    string
+	Seller string
+	// DryRun counts the rows that would be deleted without deleting them
+	DryRun bool
+}
+
+// ForgetResult counts the rows Forget deleted from each table
+type ForgetResult map[string]int64
+
+// Total is how many rows were deleted altogether
+func (r ForgetResult) Total() int64 {
+	var total int64
+	for _, n := range r {
+		total += n
+	}
+	return total
+}
+
+// Forget deletes the listings matching req with every row that references
+// them: price history, events, edits, corrections, reparse records, the search
+// index and batches queued for export. Forgetting a seller also deletes
+// their seller record and listing counts. Deleted rows stay in the file's
+// free pages until it is vacuumed.
+func (e *DBExporter) Forget(req ForgetRequest) (ForgetResult, error) {
+	req.URL = strings.TrimSpace(req.URL)
+	req.Seller = strings.TrimSpace(req.Seller)
+	if req.URL == "" && req.Seller == "" {
+		return nil, fmt.Errorf("nothing to forget: give a listing URL or a seller")
+	}
+
+	tx, err := e.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	hashes, fingerprints, err := forgottenListings(tx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	result := ForgetResult{}
+	del := func(table, query string, args ...interface{}) error {
+		res, err := tx.Exec(query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to delete from %s: %w", table, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to delete from %s: %w", table, err)
+		}
+		result[table] += n
+		return nil
+	}
+
+	for _, hash := range hashes {
+		queries := []struct{ table, query string }{
+			{"price_history", "DELETE FROM price_history WHERE listing_hash = ?"},
+			{"price_history_compacted", "DELETE FROM price_history_compacted WHERE listing_hash = ?"},
+			{"listing_events", "DELETE FROM listing_events WHERE listing_hash = ?"},
+			{"corrections", "DELETE FROM corrections WHERE hash = ?"},
+			{"reparse_changes", "DELETE FROM reparse_changes WHERE hash = ?"},
+			{"favorites", "DELETE FROM favorites WHERE listing_hash = ?"},
+			{"favorite_changes", "DELETE FROM favorite_changes WHERE listing_hash = ?"},
+			{"listing_edits", "DELETE FROM listing_edits WHERE listing_hash = ?"},
+		}
+		if e.fts {
+			queries = append(queries, struct{ table, query string }{"listings_fts", "DELETE FROM listings_fts WHERE hash = ?"})
+		}
+		// the listing goes last, once nothing references it
+		queries = append(queries, struct{ table, query string }{"listings", "DELETE FROM listings WHERE hash = ?"})
+		for _, q := range queries {
+			if err := del(q.table, q.query, hash); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if req.Seller != "" {
+		if err := del("sellers", "DELETE FROM sellers WHERE username = ? COLLATE NOCASE", req.Seller); err != nil {
+			return nil, err
+		}
+		if err := del("seller_listing_counts", "DELETE FROM seller_listing_counts WHERE username = ? COLLATE NOCASE", req.Seller); err != nil {
+			return nil, err
+		}
+	}
+
+	pending, err := forgetPending(tx, fingerprints)
+	if err != nil {
+		return nil, err
+	}
+	result["pending_exports"] = pending
+
+	if req.DryRun {
+		return result, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to forget listings: %w", err)
+	}
+	return result, nil
+}
+
+// forgottenListings returns the hashes and fingerprints of the listings req
+// covers. A URL matches the listing stored under it and, for Pinkbike URLs,
+// any listing with the same listing ID.
+func forgottenListings(tx *sql.Tx, req ForgetRequest) ([]string, []string, error) {
+	var conditions []string
+	var args []interface{}
+	if req.URL != "" {
+		conditions = append(conditions, "url = ?", "url = ?")
+		args = append(args, req.URL, parser.CanonicalURL(req.URL))
+		if id := parser.ExtractListingID(req.URL); id != 0 {
+			conditions = append(conditions, "listing_id = ?")
+			args = append(args, id)
+		}
+	}
+	if req.Seller != "" {
+		conditions = append(conditions, "seller = ? COLLATE NOCASE")
+		args = append(args, req.Seller)
+	}
+
+	rows, err := tx.Query("SELECT hash, COALESCE(fingerprint, hash) FROM listings WHERE "+strings.Join(conditions, " OR "), args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find listings to forget: %w", err)
+	}
+	defer rows.Close()
+
+	var hashes, fingerprints []string
+	for rows.Next() {
+		var hash, fingerprint string
+		if err := rows.Scan(&hash, &fingerprint); err != nil {
+			return nil, nil, fmt.Errorf("failed to find listings to forget: %w", err)
+		}
+		hashes = append(hashes, hash)
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to find listings to forget: %w", err)
+	}
+	return hashes, fingerprints, nil
+}
+
+// forgetPending removes the forgotten listings from batches queued for
+// export, deleting batches left empty, and returns how many listings were
+// removed
+func forgetPending(tx *sql.Tx, fingerprints []string) (int64, error) {
+	if len(fingerprints) == 0 {
+		return 0, nil
+	}
+	forgotten := map[string]bool{}
+	for _, fingerprint := range fingerprints {
+		forgotten[fingerprint] = true
+	}
+
+	rows, err := tx.Query("SELECT id, payload FROM pending_exports")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read queued exports: %w", err)
+	}
+	type batch struct {
+		id       int64
+		listings []listing.Listing
+	}
+	var batches []batch
+	for rows.Next() {
+		var b batch
+		var payload []byte
+		if err := rows.Scan(&b.id, &payload); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to read queued exports: %w", err)
+		}
+		if err := json.Unmarshal(payload, &b.listings); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to decode queued export %d: %w", b.id, err)
+		}
+		batches = append(batches, b)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, fmt.Errorf("failed to read queued exports: %w", err)
+	}
+
+	var removed int64
+	for _, b := range batches {
+		var kept []listing.Listing
+		for _, l := range b.listings {
+			if !forgotten[l.Fingerprint()] {
+				kept = append(kept, l)
+			}
+		}
+		if len(kept) == len(b.listings) {
+			continue
+		}
+		removed += int64(len(b.listings) - len(kept))
+
+		if len(kept) == 0 {
+			if _, err := tx.Exec("DELETE FROM pending_exports WHERE id = ?", b.id); err != nil {
+				return 0, fmt.Errorf("failed to update queued export %d: %w", b.id, err)
+			}
+			continue
+		}
+		payload, err := json.Marshal(kept)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode queued export %d: %w", b.id, err)
+		}
+		if _, err := tx.Exec("UPDATE pending_exports SET payload = ? WHERE id = ?", payload, b.id); err != nil {
+			return 0, fmt.Errorf("failed to update queued export %d: %w", b.id, err)
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,83 @@
+package exporter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pinkbike-scraper/pkg/listing"
+)
+
+func TestForget(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+
+	withSeller := func(title string, id int, seller string) listing.Listing {
+		url := fmt.Sprintf("https://www.pinkbike.com/buysell/%d/", id)
+		return listing.Listing{Title: title, Price: "3000", Currency: "USD", URL: url, ListingID: id}.
+			WithDetails(listing.ListingDetails{Description: "Call 604-555-0123", Seller: seller, SellerType: listing.Private})
+	}
+	takedown := withSeller("2021 Evil Wreckoning", 3000001, "rider")
+	other := withSeller("2022 Transition Spire", 3000002, "rider")
+	kept := withSeller("2023 Norco Range", 3000003, "shop")
+	require.NoError(t, exp.Export([]listing.Listing{takedown, other, kept}))
+
+	// a price change adds history and an event
+	takedown.Price = "2800"
+	require.NoError(t, exp.Export([]listing.Listing{takedown, other, kept}))
+
+	payload, err := json.Marshal([]listing.Listing{takedown, kept})
+	require.NoError(t, err)
+	require.NoError(t, exp.QueueExport("sheets:Enduro", payload, errors.New("503")))
+
+	dry, err := exp.Forget(ForgetRequest{URL: "https://pinkbike.com/buysell/3000001", DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), dry["listings"])
+	exists, err := exp.ListingExists(takedown.ComputeHash())
+	require.NoError(t, err)
+	assert.True(t, exists, "a dry run deletes nothing")
+
+	result, err := exp.Forget(ForgetRequest{URL: "https://pinkbike.com/buysell/3000001"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result["listings"])
+	assert.Equal(t, int64(2), result["price_history"])
+	assert.Equal(t, int64(1), result["pending_exports"])
+
+	exists, err = exp.ListingExists(takedown.ComputeHash())
+	require.NoError(t, err)
+	assert.False(t, exists)
+	var history int
+	require.NoError(t, exp.db.QueryRow("SELECT COUNT(*) FROM price_history WHERE listing_hash = ?", takedown.ComputeHash()).Scan(&history))
+	assert.Zero(t, history)
+	require.NoError(t, exp.db.QueryRow("SELECT COUNT(*) FROM listing_events WHERE listing_hash = ?", takedown.ComputeHash()).Scan(&history))
+	assert.Zero(t, history)
+
+	pending, err := exp.PendingExports("sheets:Enduro")
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	var queued []listing.Listing
+	require.NoError(t, json.Unmarshal(pending[0].Payload, &queued))
+	require.Len(t, queued, 1)
+	assert.Equal(t, kept.Title, queued[0].Title, "other listings stay queued")
+
+	result, err = exp.Forget(ForgetRequest{Seller: "RIDER"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result["listings"])
+	assert.Equal(t, int64(1), result["sellers"])
+	assert.NotZero(t, result["seller_listing_counts"])
+
+	sellers, err := exp.Sellers(0)
+	require.NoError(t, err)
+	require.Len(t, sellers, 1)
+	assert.Equal(t, "shop", sellers[0].Username)
+
+	result, err = exp.Forget(ForgetRequest{Seller: "nobody"})
+	require.NoError(t, err)
+	assert.Zero(t, result.Total())
+
+	_, err = exp.Forget(ForgetRequest{})
+	assert.Error(t, err)
+}
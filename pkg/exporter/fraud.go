@@ -0,0 +1,127 @@
+package exporter
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"pinkbike-scraper/pkg/fraud"
+	"pinkbike-scraper/pkg/listing"
+)
+
+// notSuspected is the condition leaving suspected scams out of a listings
+// query, unless the exporter was opened with IncludeSuspected
+func (e *DBExporter) notSuspected() string {
+	if e.includeSuspected {
+		return ""
+	}
+	return fmt.Sprintf(" AND COALESCE(fraud_score, 0) < %s", strconv.FormatFloat(fraud.Threshold, 'f', -1, 64))
+}
+
+// scoreListings assesses the stored listings for signs of a scam, storing
+// each one's fraud_score and fraud_reasons. Descriptions are fingerprinted
+// so later listings reposting them are caught; a listing is rescored against
+// those each time it is seen again.
+func (e *DBExporter) scoreListings(tx *sql.Tx, stored []listing.Listing) error {
+	type model struct{ manufacturer, model, generation string }
+	prices := map[model]map[string]float64{}
+
+	for _, l := range stored {
+		var description, seller sql.NullString
+		err := tx.QueryRow("SELECT COALESCE(decompress(description), ''), seller FROM listings WHERE hash = ?", l.Hash).
+			Scan(&description, &seller)
+		if err != nil {
+			return fmt.Errorf("failed to load listing description: %w", err)
+		}
+		l.Details.Description = description.String
+		fingerprint := fraud.Fingerprint(description.String)
+
+		var market fraud.Market
+		if l.Manufacturer != "" && l.Model != "" {
+			k := model{l.Manufacturer, l.Model, l.Generation}
+			if _, ok := prices[k]; !ok {
+				if prices[k], err = e.modelPrices(tx, l.Manufacturer, l.Model, l.Generation); err != nil {
+					return err
+				}
+			}
+			// a listing is not one of its own comps
+			var comps []float64
+			for hash, price := range prices[k] {
+				if hash != l.Hash {
+					comps = append(comps, price)
+				}
+			}
+			if len(comps) > 0 {
+				market.Median, market.Comps = median(comps), len(comps)
+			}
+		}
+		if fingerprint != "" {
+			err := tx.QueryRow(`
+                SELECT COUNT(*) FROM listings
+                WHERE description_hash = ?1 AND hash != ?2
+                    AND (?3 = '' OR COALESCE(seller, '') != ?3)
+            `, fingerprint, l.Hash, seller.String).Scan(&market.Duplicates)
+			if err != nil {
+				return fmt.Errorf("failed to look up duplicate descriptions: %w", err)
+			}
+		}
+
+		a := fraud.Assess(l, market)
+		if _, err := tx.Exec(`
+            UPDATE listings SET description_hash = ?, fraud_score = ?, fraud_reasons = ?
+            WHERE hash = ?
+        `, nullString(fingerprint), a.Score, nullString(strings.Join(a.Reasons, "; ")), l.Hash); err != nil {
+			return fmt.Errorf("failed to store fraud score: %w", err)
+		}
+	}
+	return nil
+}
+
+// LoadFraudScores sets the fraud score each of listings was stored with, so
+// listings scraped this run can be told apart from suspected scams. Listings
+// that are not stored keep theirs.
+func (e *DBExporter) LoadFraudScores(listings []listing.Listing) error {
+	for i, l := range listings {
+		var score sql.NullFloat64
+		err := e.db.QueryRow("SELECT fraud_score FROM listings WHERE fingerprint = ?", l.Fingerprint()).Scan(&score)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load fraud score: %w", err)
+		}
+		listings[i].FraudScore = score.Float64
+	}
+	return nil
+}
+
+// modelPrices returns the USD prices of the active listings of a model, and
+// generation when known, that MedianPrice would compute its median from, by
+// listing hash
+func (e *DBExporter) modelPrices(tx *sql.Tx, manufacturer, model, generation string) (map[string]float64, error) {
+	rows, err := tx.Query(`
+        SELECT hash, price FROM listings
+        WHERE active = 1 AND needs_review = '' AND manufacturer = ?1 AND model = ?2
+            AND (?3 = '' OR generation = ?3)`+completeBikes+e.notSuspected(),
+		manufacturer, model, generation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prices: %w", err)
+	}
+	defer rows.Close()
+
+	prices := map[string]float64{}
+	for rows.Next() {
+		var hash, price sql.NullString
+		if err := rows.Scan(&hash, &price); err != nil {
+			return nil, fmt.Errorf("failed to scan price: %w", err)
+		}
+		if p, err := strconv.ParseFloat(price.String, 64); err == nil && p > 0 {
+			prices[hash.String] = p
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query prices: %w", err)
+	}
+	return prices, nil
+}
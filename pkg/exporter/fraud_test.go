@@ -0,0 +1,96 @@
+package exporter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pinkbike-scraper/pkg/fraud"
+	"pinkbike-scraper/pkg/listing"
+)
+
+func TestScoreListings(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	bike := func(title, price, seller, description string) listing.Listing {
+		return listing.Listing{
+			Title: title, Manufacturer: "Santa Cruz", Model: "Megatower", Price: price, Currency: "USD",
+		}.WithDetails(listing.ListingDetails{Description: description, Seller: seller})
+	}
+	copied := "Selling my 2021 Santa Cruz Megatower, ridden two seasons, fresh fork service and new tires this spring."
+
+	var listings []listing.Listing
+	for i, price := range []string{"4000", "4200", "4400"} {
+		seller := fmt.Sprintf("rider%d", i)
+		listings = append(listings, bike("2021 Santa Cruz Megatower "+seller, price, seller, fmt.Sprintf("My bike %d", i)))
+	}
+	listings = append(listings, bike("2021 Santa Cruz Megatower original", "4100", "owner", copied))
+	require.NoError(t, exp.Export(listings))
+
+	scam := bike("2021 Santa Cruz Megatower cheap", "1200", "stranger", copied)
+	require.NoError(t, exp.Export([]listing.Listing{scam}))
+
+	var score float64
+	var reasons string
+	require.NoError(t, exp.db.QueryRow("SELECT fraud_score, fraud_reasons FROM listings WHERE title = ?", scam.Title).Scan(&score, &reasons))
+	assert.Equal(t, 1.0, score)
+	assert.Equal(t, "priced 71% under the $4150 median; description matches 1 listings by other sellers", reasons)
+
+	require.NoError(t, exp.db.QueryRow("SELECT fraud_score FROM listings WHERE title = ?", listings[0].Title).Scan(&score))
+	assert.Zero(t, score)
+
+	scraped := []listing.Listing{scam, listings[0]}
+	require.NoError(t, exp.LoadFraudScores(scraped))
+	assert.Equal(t, 1.0, scraped[0].FraudScore)
+	assert.Zero(t, scraped[1].FraudScore)
+	stored, err := exp.FindListing(scam.ComputeHash())
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, stored.FraudScore)
+
+	median, count, err := exp.MedianPrice("Santa Cruz", "Megatower", "")
+	require.NoError(t, err)
+	assert.Equal(t, 4, count, "the suspected scam is left out")
+	assert.Equal(t, 4150.0, median)
+
+	exp.includeSuspected = true
+	_, count, err = exp.MedianPrice("Santa Cruz", "Megatower", "")
+	require.NoError(t, err)
+	assert.Equal(t, 5, count)
+}
+
+func TestMigrateBackfillsFraudScores(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	_, err := exp.db.Exec(`
+        INSERT INTO listings (title, manufacturer, model, price, active, needs_review, hash, fingerprint) VALUES
+            ('2021 Santa Cruz Megatower', 'Santa Cruz', 'Megatower', '4000', 1, '', 'a', 'a'),
+            ('2021 Santa Cruz Megatower', 'Santa Cruz', 'Megatower', '4200', 1, '', 'b', 'b'),
+            ('2021 Santa Cruz Megatower', 'Santa Cruz', 'Megatower', '4400', 1, '', 'c', 'c'),
+            ('2021 Santa Cruz Megatower', 'Santa Cruz', 'Megatower', '900', 1, '', 'd', 'd')
+    `)
+	require.NoError(t, err)
+	_, err = exp.db.Exec("PRAGMA user_version = 17")
+	require.NoError(t, err)
+
+	require.NoError(t, migrate(exp.db))
+
+	score := func(hash string) *float64 {
+		t.Helper()
+		var s *float64
+		require.NoError(t, exp.db.QueryRow("SELECT fraud_score FROM listings WHERE hash = ?", hash).Scan(&s))
+		return s
+	}
+	for _, hash := range []string{"a", "b", "c"} {
+		if assert.NotNil(t, score(hash), hash) {
+			assert.Zero(t, *score(hash), hash)
+		}
+	}
+	if assert.NotNil(t, score("d")) {
+		assert.GreaterOrEqual(t, *score("d"), fraud.Threshold)
+	}
+
+	median, count, err := exp.MedianPrice("Santa Cruz", "Megatower", "")
+	require.NoError(t, err)
+	assert.Equal(t, 3, count, "the backfilled suspected scam is left out")
+	assert.Equal(t, 4200.0, median)
+}
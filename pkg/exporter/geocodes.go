@@ -0,0 +1,16 @@
+package exporter
+
+import (
+	"pinkbike-scraper/pkg/geo"
+	"pinkbike-scraper/pkg/listing"
+)
+
+// distance is how far l's seller is from near in whole kilometres, empty when
+// either position is unknown
+func distance(l listing.Listing, near geo.Point) string {
+	km, ok := l.DistanceKM(near)
+	if !ok {
+		return ""
+	}
+	return geo.FormatKM(km)
+}
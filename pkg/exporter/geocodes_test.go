@@ -0,0 +1,55 @@
+package exporter
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"pinkbike-scraper/pkg/geo"
+	"pinkbike-scraper/pkg/listing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	calgary = geo.Point{Lat: 51.0447, Lon: -114.0719}
+	banff   = geo.Point{Lat: 51.1784, Lon: -115.5708}
+)
+
+func TestListingLocationIsStored(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	l := listing.Listing{Title: "2021 Evil Wreckoning", Price: "3900", Location: "Banff, Alberta, Canada", Coordinates: banff}
+	require.NoError(t, exp.Export([]listing.Listing{l}))
+
+	// a later scrape without the location keeps the stored one
+	l.Location, l.Coordinates = "", geo.Point{}
+	require.NoError(t, exp.Export([]listing.Listing{l}))
+
+	stored, err := exp.FindListing(l.ComputeHash())
+	require.NoError(t, err)
+	assert.Equal(t, "Banff, Alberta, Canada", stored.Location)
+	assert.Equal(t, banff, stored.Coordinates)
+}
+
+func TestExportsIncludeDistance(t *testing.T) {
+	listings := []listing.Listing{
+		{Title: "2021 Evil Wreckoning", Price: "3900", Location: "Banff, Alberta, Canada", Coordinates: banff},
+		{Title: "2020 Kona Process 153", Price: "2200"},
+	}
+
+	path := filepath.Join(t.TempDir(), "listings.csv")
+	require.NoError(t, NewCSVExporter(path, "", CSVOptions{Combined: true, Near: calgary}).Export(listings))
+	rows := readTestCSV(t, path)
+	require.Len(t, rows, 3)
+	km := columnIndex(csvHeaders, "Distance (km)")
+	assert.Equal(t, "106", rows[1][km])
+	assert.Equal(t, "51.1784", rows[1][columnIndex(csvHeaders, "Latitude")])
+	assert.Empty(t, rows[2][km], "listings without a location have no distance")
+
+	var buf bytes.Buffer
+	table, err := NewTableExporter(&buf, TableOptions{Fields: []string{"title", "distance"}, SortBy: "distance", Near: calgary})
+	require.NoError(t, err)
+	require.NoError(t, table.Export(listings))
+	assert.Regexp(t, `2020 Kona Process 153\n2021 Evil Wreckoning +106`, buf.String())
+}
@@ -0,0 +1,93 @@
+package exporter
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pinkbike-scraper/pkg/listing"
+)
+
+// ImportHistorical backfills listings observed at seenAt, such as archived
+// pages. Unlike Export it widens first_seen and last_seen instead of resetting
+// them, leaves listings that are not already active inactive, and publishes no events.
+// The price of an inactive listing is taken from its latest observation, so
+// imports may come in any order.
+func (e *DBExporter) ImportHistorical(listings []listing.Listing, seenAt time.Time) (int, error) {
+	seen := seenAt.UTC().Format(sqliteTimeFormat)
+
+	tx, err := e.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+        INSERT INTO listings (
+            title, year, manufacturer, model, price, currency,
+            condition, frame_size, wheel_size, frame_material,
+            front_travel, rear_travel, needs_review, url, hash,
+            category, listing_id, fingerprint, listed_price, first_seen, last_seen, active
+        )
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0)
+        ON CONFLICT(fingerprint) DO UPDATE SET
+            price = CASE WHEN NOT active AND excluded.last_seen > last_seen THEN excluded.price ELSE price END,
+            currency = CASE WHEN NOT active AND excluded.last_seen > last_seen THEN excluded.currency ELSE currency END,
+            first_seen = MIN(first_seen, excluded.first_seen),
+            last_seen = MAX(last_seen, excluded.last_seen),
+            category = COALESCE(category, excluded.category),
+            listing_id = COALESCE(listing_id, excluded.listing_id),
+            listed_price = CASE WHEN NOT active AND excluded.last_seen > last_seen
+                THEN COALESCE(excluded.listed_price, listed_price) ELSE COALESCE(listed_price, excluded.listed_price) END
+    `)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	imported := 0
+	for _, l := range listings {
+		// an archived listing stored before keeps the hash and fields it is
+		// stored with, which are newer than the archived ones
+		fingerprint := l.Fingerprint()
+		var hash string
+		err := tx.QueryRow("SELECT hash FROM listings WHERE fingerprint = ?", fingerprint).Scan(&hash)
+		if err == sql.ErrNoRows {
+			if hash, err = freeKey(tx, l.ComputeHash(), fingerprint); err != nil {
+				return 0, err
+			}
+		} else if err != nil {
+			return 0, fmt.Errorf("failed to look up listing: %w", err)
+		}
+
+		if _, err := stmt.Exec(
+			l.Title, l.Year, l.Manufacturer, l.Model, l.Price,
+			l.Currency, l.Condition, l.FrameSize, l.WheelSize,
+			l.FrameMaterial, l.FrontTravel, l.RearTravel,
+			encodeReasons(l.NeedsReview), l.URL, hash, nullString(l.Category), nullInt(l.ListingID), fingerprint, nullString(l.ListedPrice), seen, seen,
+		); err != nil {
+			return 0, fmt.Errorf("failed to import listing: %w", err)
+		}
+
+		if _, err := tx.Exec(`
+            INSERT INTO price_history (listing_hash, price, currency, listed_price, exchange_rate, recorded_at)
+            SELECT ?, ?, ?, ?, ?, ?
+            WHERE NOT EXISTS (
+                SELECT 1 FROM price_history
+                WHERE listing_hash = ? AND price = ? AND date(recorded_at) = date(?)
+            )
+        `, hash, l.Price, l.Currency, nullString(l.ListedPrice), nullFloat(l.ExchangeRate), seen, hash, l.Price, seen); err != nil {
+			return 0, fmt.Errorf("failed to import price history: %w", err)
+		}
+
+		if err := e.indexListing(tx, hash); err != nil {
+			return 0, err
+		}
+		imported++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit import: %w", err)
+	}
+	return imported, nil
+}
@@ -0,0 +1,47 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"pinkbike-scraper/pkg/listing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportHistorical(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	l := listing.Listing{Title: "2016 Santa Cruz Nomad", Price: "3200", Currency: "USD"}
+
+	march := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	june := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	_, err := exp.ImportHistorical([]listing.Listing{l}, june)
+	require.NoError(t, err)
+	l.Price = "3400"
+	_, err = exp.ImportHistorical([]listing.Listing{l}, march)
+	require.NoError(t, err)
+
+	var firstSeen, lastSeen time.Time
+	var active bool
+	var price string
+	require.NoError(t, exp.db.QueryRow("SELECT first_seen, last_seen, active, price FROM listings").Scan(&firstSeen, &lastSeen, &active, &price))
+	assert.Equal(t, march, firstSeen)
+	assert.Equal(t, june, lastSeen)
+	assert.False(t, active)
+	assert.Equal(t, "3200", price, "an earlier observation does not replace the latest price")
+
+	l.Price = "2900"
+	_, err = exp.ImportHistorical([]listing.Listing{l}, june.AddDate(0, 1, 0))
+	require.NoError(t, err)
+	require.NoError(t, exp.db.QueryRow("SELECT price FROM listings").Scan(&price))
+	assert.Equal(t, "2900", price)
+
+	history, err := exp.PriceHistory(l.ComputeHash())
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, "3400", history[0].Price)
+	assert.Equal(t, march, history[0].From)
+	assert.Equal(t, "3200", history[1].Price)
+}
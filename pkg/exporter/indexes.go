@@ -0,0 +1,190 @@
+package exporter
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"pinkbike-scraper/pkg/priceindex"
+)
+
+// completeBikes is the condition keeping frames, forks, wheels and parts out
+// of a listings query, so their prices do not drag down the medians of the
+// bikes they came off. Listings stored before kinds were detected are bikes.
+const completeBikes = " AND COALESCE(kind, 'bike') = 'bike'"
+
+// IndexListings loads the listings a price index is computed from: every
+// complete bike not flagged for review or suspected of being a scam, with its
+// raw and compacted price history
+func (e *DBExporter) IndexListings() ([]priceindex.Listing, error) {
+	rows, err := e.db.Query(`
+        SELECT hash, manufacturer, model, generation, price, first_seen, last_seen FROM listings
+        WHERE needs_review = '' AND manufacturer != '' AND model != ''` + completeBikes + e.notSuspected() + `
+        ORDER BY id
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load listings: %w", err)
+	}
+	defer rows.Close()
+
+	var listings []priceindex.Listing
+	byHash := map[string]int{}
+	current := map[string]string{}
+	for rows.Next() {
+		var (
+			hash, manufacturer, model, generation, price sql.NullString
+			firstSeen, lastSeen                          interface{}
+		)
+		if err := rows.Scan(&hash, &manufacturer, &model, &generation, &price, &firstSeen, &lastSeen); err != nil {
+			return nil, fmt.Errorf("failed to scan listing: %w", err)
+		}
+		l := priceindex.Listing{Manufacturer: manufacturer.String, Model: model.String, Generation: generation.String}
+		if l.FirstSeen, err = parseSQLiteTime(firstSeen); err != nil {
+			return nil, err
+		}
+		if l.LastSeen, err = parseSQLiteTime(lastSeen); err != nil {
+			return nil, err
+		}
+		byHash[hash.String] = len(listings)
+		current[hash.String] = price.String
+		listings = append(listings, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load listings: %w", err)
+	}
+	rows.Close()
+
+	rows, err = e.db.Query(`
+        SELECT listing_hash, price, datetime(recorded_at) AS at FROM price_history
+        UNION ALL
+        SELECT listing_hash, price, datetime(valid_from) AS at FROM price_history_compacted
+        ORDER BY at
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load price history: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			hash, price sql.NullString
+			at          interface{}
+		)
+		if err := rows.Scan(&hash, &price, &at); err != nil {
+			return nil, fmt.Errorf("failed to scan price history: %w", err)
+		}
+		i, ok := byHash[hash.String]
+		amount, err := strconv.ParseFloat(price.String, 64)
+		if !ok || err != nil {
+			continue
+		}
+		recorded, err := parseSQLiteTime(at)
+		if err != nil {
+			return nil, err
+		}
+		listings[i].Prices = append(listings[i].Prices, priceindex.Price{At: recorded, Amount: amount})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load price history: %w", err)
+	}
+
+	// listings stored before price history was kept only have their price
+	for hash, i := range byHash {
+		if len(listings[i].Prices) > 0 {
+			continue
+		}
+		if amount, err := strconv.ParseFloat(current[hash], 64); err == nil {
+			listings[i].Prices = []priceindex.Price{{At: listings[i].FirstSeen, Amount: amount}}
+		}
+	}
+	return listings, nil
+}
+
+// RefreshIndexes recomputes every price index from the stored listings,
+// replacing the previous values, and returns how many points were stored
+func (e *DBExporter) RefreshIndexes(opts priceindex.Options) (int, error) {
+	listings, err := e.IndexListings()
+	if err != nil {
+		return 0, err
+	}
+	points := priceindex.Compute(listings, opts)
+
+	tx, err := e.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM indexes"); err != nil {
+		return 0, fmt.Errorf("failed to clear indexes: %w", err)
+	}
+	stmt, err := tx.Prepare("INSERT INTO indexes (name, week, median, listings, computed_at) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare index insert: %w", err)
+	}
+	defer stmt.Close()
+	now := e.clock.Now().UTC().Format(sqliteTimeFormat)
+	for _, p := range points {
+		if _, err := stmt.Exec(p.Name, p.Week.Format("2006-01-02"), p.Median, p.Listings, now); err != nil {
+			return 0, fmt.Errorf("failed to store index %s: %w", p.Name, err)
+		}
+	}
+	return len(points), tx.Commit()
+}
+
+// LatestIndexes returns the most recent point of every price index, the
+// overall index first and then the models by name
+func (e *DBExporter) LatestIndexes() ([]priceindex.Point, error) {
+	return e.queryIndexes(`
+        SELECT i.name, i.week, i.median, i.listings,
+               (SELECT f.median FROM indexes f WHERE f.name = i.name ORDER BY f.week LIMIT 1)
+        FROM indexes i
+        WHERE i.week = (SELECT MAX(l.week) FROM indexes l WHERE l.name = i.name)
+        ORDER BY i.name != ?, i.name
+    `, priceindex.Overall)
+}
+
+// IndexSeries returns the weekly points of a price index, oldest first. The
+// name is matched ignoring case; an unknown index has no points.
+func (e *DBExporter) IndexSeries(name string) ([]priceindex.Point, error) {
+	return e.queryIndexes(`
+        SELECT i.name, i.week, i.median, i.listings,
+               (SELECT f.median FROM indexes f WHERE f.name = i.name ORDER BY f.week LIMIT 1)
+        FROM indexes i
+        WHERE i.name = ? COLLATE NOCASE
+        ORDER BY i.week
+    `, name)
+}
+
+// queryIndexes reads points selected as name, week, median, listings and the
+// median of the series' first week
+func (e *DBExporter) queryIndexes(query string, args ...interface{}) ([]priceindex.Point, error) {
+	rows, err := e.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query indexes: %w", err)
+	}
+	defer rows.Close()
+
+	var points []priceindex.Point
+	for rows.Next() {
+		var (
+			p    priceindex.Point
+			week interface{}
+			base float64
+		)
+		if err := rows.Scan(&p.Name, &week, &p.Median, &p.Listings, &base); err != nil {
+			return nil, fmt.Errorf("failed to scan index: %w", err)
+		}
+		if p.Week, err = parseSQLiteTime(week); err != nil {
+			return nil, err
+		}
+		if base > 0 {
+			p.Value = p.Median / base * 100
+		}
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query indexes: %w", err)
+	}
+	return points, nil
+}
@@ -0,0 +1,55 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/priceindex"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshIndexes(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	first := time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC)
+	bikes := []listing.Listing{
+		{Title: "2021 Santa Cruz Megatower", Manufacturer: "Santa Cruz", Model: "Megatower", Price: "4000", URL: "https://pinkbike.com/1"},
+		{Title: "2022 Santa Cruz Megatower", Manufacturer: "Santa Cruz", Model: "Megatower", Price: "5000", URL: "https://pinkbike.com/2"},
+		{Title: "2020 Yeti SB150", Manufacturer: "Yeti", Model: "SB150", Price: "3000", URL: "https://pinkbike.com/3"},
+	}
+	exp.clock = clock.Fixed(first)
+	require.NoError(t, exp.Export(bikes))
+	bikes[1].Price = "4400"
+	exp.clock = clock.Fixed(first.AddDate(0, 0, 7))
+	require.NoError(t, exp.Export(bikes))
+
+	n, err := exp.RefreshIndexes(priceindex.Options{Models: 1, MinListings: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+
+	latest, err := exp.LatestIndexes()
+	require.NoError(t, err)
+	require.Len(t, latest, 2)
+	assert.Equal(t, priceindex.Overall, latest[0].Name)
+	assert.Equal(t, "Santa Cruz Megatower", latest[1].Name)
+	assert.Equal(t, priceindex.Week(first.AddDate(0, 0, 7)), latest[1].Week)
+	assert.Equal(t, 4200.0, latest[1].Median)
+	assert.InDelta(t, 93.3, latest[1].Value, 0.1)
+
+	series, err := exp.IndexSeries("santa cruz megatower")
+	require.NoError(t, err)
+	require.Len(t, series, 2)
+	assert.Equal(t, 4500.0, series[0].Median)
+	assert.Equal(t, 100.0, series[0].Value)
+
+	// refreshing replaces the stored points
+	n, err = exp.RefreshIndexes(priceindex.Options{Models: 0, MinListings: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	series, err = exp.IndexSeries("Santa Cruz Megatower")
+	require.NoError(t, err)
+	assert.Empty(t, series)
+}
@@ -0,0 +1,98 @@
+package exporter
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/textdiff"
+)
+
+// editContext is how many unchanged words are kept around each change in the
+// diff of an edit
+const editContext = 8
+
+// ListingEdit is a change a seller made to the title, description or
+// restrictions of a listing after it was first scraped
+type ListingEdit struct {
+	Hash, Title, URL string
+	// Field is "title", "description" or "restrictions"
+	Field    string
+	Old, New string
+	// Diff marks the words removed [-like this-] and added {+like this+}
+	Diff     string
+	EditedAt time.Time
+}
+
+// storedDetails are the fields of a stored listing edits are detected in
+type storedDetails struct {
+	title, description, restrictions string
+}
+
+// recordEdits records how the title, description and restrictions scraped for
+// a stored listing differ from the stored ones. Fields not scraped this time,
+// or never stored before, are not edits.
+func (e *DBExporter) recordEdits(tx *sql.Tx, hash string, stored storedDetails, scraped listing.Listing) error {
+	fields := []struct{ name, old, new string }{
+		{"title", stored.title, scraped.Title},
+		{"description", stored.description, scraped.Details.Description},
+		{"restrictions", stored.restrictions, scraped.Details.Restrictions},
+	}
+	for _, f := range fields {
+		if strings.TrimSpace(f.old) == "" || strings.TrimSpace(f.new) == "" {
+			continue
+		}
+		diff := textdiff.Words(f.old, f.new, editContext)
+		if diff == "" {
+			continue
+		}
+		if _, err := tx.Exec(`
+            INSERT INTO listing_edits (listing_hash, field, old_value, new_value, diff, edited_at)
+            VALUES (?, ?, ?, ?, ?, ?)
+        `, hash, f.name, f.old, f.new, diff, e.now()); err != nil {
+			return fmt.Errorf("failed to record listing edit: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListingEdits returns the edits recorded since since, oldest first, of the
+// listing with hash or of every listing when hash is empty. A limit above
+// zero keeps only the most recent ones.
+func (e *DBExporter) ListingEdits(hash string, since time.Time, limit int) ([]ListingEdit, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := e.db.Query(`
+        SELECT * FROM (
+            SELECT d.id, d.listing_hash, COALESCE(l.title, ''), COALESCE(l.url, ''), d.field, d.old_value, d.new_value, d.diff, d.edited_at
+            FROM listing_edits d LEFT JOIN listings l ON l.hash = d.listing_hash
+            WHERE (? = '' OR d.listing_hash = ?) AND datetime(d.edited_at) >= datetime(?)
+            ORDER BY datetime(d.edited_at) DESC, d.id DESC LIMIT ?
+        ) ORDER BY datetime(edited_at), id
+    `, hash, hash, since.UTC().Format(sqliteTimeFormat), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load listing edits: %w", err)
+	}
+	defer rows.Close()
+
+	var edits []ListingEdit
+	for rows.Next() {
+		var id int64
+		var d ListingEdit
+		var editedAt interface{}
+		if err := rows.Scan(&id, &d.Hash, &d.Title, &d.URL, &d.Field, &d.Old, &d.New, &d.Diff, &editedAt); err != nil {
+			return nil, fmt.Errorf("failed to load listing edits: %w", err)
+		}
+		if d.EditedAt, err = parseSQLiteTime(editedAt); err != nil {
+			return nil, err
+		}
+		edits = append(edits, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load listing edits: %w", err)
+	}
+	return edits, nil
+}
@@ -0,0 +1,52 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/listing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListingEdits(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	exp.clock = clock.Fixed(start)
+	l := listing.Listing{Title: "2021 Evil Wreckoning", Price: "3900", Currency: "USD", URL: "https://pinkbike.com/1"}.
+		WithDetails(listing.ListingDetails{Description: "Coil shock, fresh bearings. Price is firm.", Restrictions: "Local pickup only"})
+	require.NoError(t, exp.Export([]listing.Listing{l}))
+
+	// a run that skips the detail page leaves the stored description alone
+	exp.clock = clock.Fixed(start.AddDate(0, 0, 1))
+	require.NoError(t, exp.Export([]listing.Listing{{Title: l.Title, Price: l.Price, Currency: l.Currency, URL: l.URL}}))
+
+	exp.clock = clock.Fixed(start.AddDate(0, 0, 2))
+	l.Details.Description = "Coil shock, fresh bearings. Price is negotiable, new rear tire."
+	require.NoError(t, exp.Export([]listing.Listing{l}))
+
+	edits, err := exp.ListingEdits("", start, 0)
+	require.NoError(t, err)
+	require.Len(t, edits, 1)
+	assert.Equal(t, "description", edits[0].Field)
+	assert.Equal(t, "2021 Evil Wreckoning", edits[0].Title)
+	assert.Equal(t, "Coil shock, fresh bearings. Price is firm.", edits[0].Old)
+	assert.Equal(t, "Coil shock, fresh bearings. Price is [-firm.-] {+negotiable, new rear tire.+}", edits[0].Diff)
+	assert.Equal(t, start.AddDate(0, 0, 2), edits[0].EditedAt)
+
+	stored, err := exp.FindListing(l.URL)
+	require.NoError(t, err)
+	assert.Equal(t, l.Details.Description, stored.Details.Description, "the stored description is the latest one")
+
+	// the same description again is not another edit
+	require.NoError(t, exp.Export([]listing.Listing{l}))
+	edits, err = exp.ListingEdits(l.ComputeHash(), start, 0)
+	require.NoError(t, err)
+	assert.Len(t, edits, 1)
+
+	edits, err = exp.ListingEdits("", start.AddDate(0, 0, 3), 0)
+	require.NoError(t, err)
+	assert.Empty(t, edits)
+}
@@ -0,0 +1,145 @@
+package exporter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"pinkbike-scraper/pkg/events"
+	"pinkbike-scraper/pkg/listing"
+)
+
+// recordEvents stores the lifecycle events of an export, so processes other
+// than the one exporting can follow them with EventsAfter
+func (e *DBExporter) recordEvents(tx *sql.Tx, evs []events.Event) error {
+	if len(evs) == 0 {
+		return nil
+	}
+	stmt, err := tx.Prepare(`
+        INSERT INTO listing_events (kind, listing_hash, price, old_price, recorded_at)
+        VALUES (?, ?, ?, ?, ?)
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, ev := range evs {
+		if _, err := stmt.Exec(ev.Kind, ev.Listing.Hash, ev.Listing.Price, nullString(ev.OldPrice), e.now()); err != nil {
+			return fmt.Errorf("failed to record listing event: %w", err)
+		}
+	}
+	return nil
+}
+
+// LastEventID returns the ID of the most recently recorded listing event, to
+// pass to EventsAfter for the events recorded from now on
+func (e *DBExporter) LastEventID() (int64, error) {
+	var id int64
+	if err := e.db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM listing_events").Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to look up last listing event: %w", err)
+	}
+	return id, nil
+}
+
+// EventsAfter returns up to limit listing events recorded after the event with
+// ID id, oldest first, and the ID to pass to the next call. Each event carries
+// its listing as stored now, at the price of the event. Events of listings no
+// longer stored are left out.
+func (e *DBExporter) EventsAfter(id int64, limit int) ([]events.Event, int64, error) {
+	rows, err := e.db.Query(`
+        SELECT id, kind, listing_hash, COALESCE(price, ''), COALESCE(old_price, ''), recorded_at
+        FROM listing_events WHERE id > ? ORDER BY id LIMIT ?
+    `, id, limit)
+	if err != nil {
+		return nil, id, fmt.Errorf("failed to query listing events: %w", err)
+	}
+
+	var recorded []events.Event
+	var hashes []interface{}
+	last := id
+	for rows.Next() {
+		var ev events.Event
+		var at interface{}
+		if err := rows.Scan(&last, &ev.Kind, &ev.Listing.Hash, &ev.Listing.Price, &ev.OldPrice, &at); err != nil {
+			rows.Close()
+			return nil, id, fmt.Errorf("failed to scan listing event: %w", err)
+		}
+		if ev.Time, err = parseSQLiteTime(at); err != nil {
+			rows.Close()
+			return nil, id, err
+		}
+		recorded = append(recorded, ev)
+		hashes = append(hashes, ev.Listing.Hash)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, id, fmt.Errorf("failed to query listing events: %w", err)
+	}
+	if len(recorded) == 0 {
+		return nil, id, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(hashes)), ", ")
+	listings, err := e.loadListings("WHERE hash IN ("+placeholders+")", hashes...)
+	if err != nil {
+		return nil, id, err
+	}
+	byHash := make(map[string]listing.Listing, len(listings))
+	for _, l := range listings {
+		byHash[l.Hash] = l
+	}
+
+	evs := make([]events.Event, 0, len(recorded))
+	for _, ev := range recorded {
+		l, ok := byHash[ev.Listing.Hash]
+		if !ok {
+			continue
+		}
+		l.Price = ev.Listing.Price
+		ev.Listing = l
+		evs = append(evs, ev)
+	}
+	return evs, last, nil
+}
+
+// RelayEvents publishes the listing events other processes record, such as a
+// scrape exporting to the same database, on bus until ctx is done. It checks
+// for new events every interval and only relays those recorded after it
+// started. A process relaying its own exports would publish them twice.
+func (e *DBExporter) RelayEvents(ctx context.Context, bus *events.Bus, interval time.Duration) error {
+	cursor, err := e.LastEventID()
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		for {
+			const batch = 100
+			evs, next, err := e.EventsAfter(cursor, batch)
+			if err != nil {
+				// the scrape may hold the database for a while; try again
+				log.Printf("could not relay listing events: %v", err)
+				break
+			}
+			cursor = next
+			for _, ev := range evs {
+				bus.Publish(ev)
+			}
+			if len(evs) < batch {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/events"
+	"pinkbike-scraper/pkg/listing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventsAfter(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	first := time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC)
+	exp.clock = clock.Fixed(first)
+	megatower := listing.Listing{Title: "2021 Santa Cruz Megatower", Price: "4000", URL: "https://pinkbike.com/1"}
+	require.NoError(t, exp.Export([]listing.Listing{megatower}))
+
+	cursor, err := exp.LastEventID()
+	require.NoError(t, err)
+	assert.NotZero(t, cursor, "discovering the listing is recorded")
+
+	exp.clock = clock.Fixed(first.AddDate(0, 0, 1))
+	megatower.Price = "3800"
+	require.NoError(t, exp.Export([]listing.Listing{megatower}))
+
+	evs, next, err := exp.EventsAfter(cursor, 10)
+	require.NoError(t, err)
+	require.Len(t, evs, 1)
+	assert.Equal(t, events.PriceChanged, evs[0].Kind)
+	assert.Equal(t, megatower.Title, evs[0].Listing.Title)
+	assert.Equal(t, "3800", evs[0].Listing.Price)
+	assert.Equal(t, "4000", evs[0].OldPrice)
+	assert.True(t, evs[0].Time.Equal(first.AddDate(0, 0, 1)))
+
+	all, _, err := exp.EventsAfter(0, 10)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, events.ListingDiscovered, all[0].Kind)
+	assert.Equal(t, "4000", all[0].Listing.Price, "events carry the price they recorded")
+
+	evs, _, err = exp.EventsAfter(next, 10)
+	require.NoError(t, err)
+	assert.Empty(t, evs)
+}
@@ -0,0 +1,121 @@
+package exporter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/parser"
+)
+
+// ListingQuery filters the listings QueryListings returns. Empty fields match
+// anything; text fields match ignoring case.
+type ListingQuery struct {
+	Manufacturer, Model, Category string
+	ActiveOnly                    bool
+	// Limit caps how many listings are returned, 0 returns all of them
+	Limit int
+}
+
+// QueryListings returns the stored listings matching q, newest first
+func (e *DBExporter) QueryListings(q ListingQuery) ([]listing.Listing, error) {
+	var where []string
+	var args []interface{}
+	for _, f := range []struct{ column, value string }{
+		{"manufacturer", q.Manufacturer},
+		{"model", q.Model},
+		{"category", q.Category},
+	} {
+		if f.value != "" {
+			where = append(where, f.column+" = ? COLLATE NOCASE")
+			args = append(args, f.value)
+		}
+	}
+	if q.ActiveOnly {
+		where = append(where, "active = 1")
+	}
+
+	clauses := ""
+	if len(where) > 0 {
+		clauses = "WHERE " + strings.Join(where, " AND ")
+	}
+	clauses += " ORDER BY datetime(first_seen) DESC, id DESC"
+	if q.Limit > 0 {
+		clauses += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+	return e.loadListings(clauses, args...)
+}
+
+// FindListing returns the stored listing ref names by its hash, its URL or its
+// Pinkbike listing ID, or ErrListingNotFound when none matches
+func (e *DBExporter) FindListing(ref string) (listing.Listing, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return listing.Listing{}, ErrListingNotFound
+	}
+	clauses := "WHERE hash = ? OR url = ? OR url = ?"
+	args := []interface{}{ref, ref, parser.CanonicalURL(ref)}
+	id := parser.ExtractListingID(ref)
+	if id == 0 {
+		id, _ = strconv.Atoi(ref)
+	}
+	if id > 0 {
+		clauses += " OR listing_id = ?"
+		args = append(args, id)
+	}
+
+	listings, err := e.loadListings(clauses+" ORDER BY datetime(last_seen) DESC LIMIT 1", args...)
+	if err != nil {
+		return listing.Listing{}, err
+	}
+	if len(listings) == 0 {
+		return listing.Listing{}, fmt.Errorf("%w: %s", ErrListingNotFound, ref)
+	}
+	return listings[0], nil
+}
+
+// LastListingID returns the row ID of the most recently stored listing, to
+// pass to ListingsAfter for the listings stored from now on
+func (e *DBExporter) LastListingID() (int64, error) {
+	var id int64
+	if err := e.db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM listings").Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to look up last listing: %w", err)
+	}
+	return id, nil
+}
+
+// ListingsAfter returns up to limit listings stored after the listing with
+// row ID id, oldest first, and the row ID to pass to the next call. Updating a
+// stored listing keeps its row ID, so only listings new to the database are
+// returned.
+func (e *DBExporter) ListingsAfter(id int64, limit int) ([]listing.Listing, int64, error) {
+	rows, err := e.db.Query("SELECT id FROM listings WHERE id > ? ORDER BY id LIMIT ?", id, limit)
+	if err != nil {
+		return nil, id, fmt.Errorf("failed to query new listings: %w", err)
+	}
+	var ids []interface{}
+	last := id
+	for rows.Next() {
+		if err := rows.Scan(&last); err != nil {
+			rows.Close()
+			return nil, id, fmt.Errorf("failed to scan listing id: %w", err)
+		}
+		ids = append(ids, last)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, id, fmt.Errorf("failed to query new listings: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, id, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	listings, err := e.loadListings("WHERE id IN ("+placeholders+") ORDER BY id", ids...)
+	if err != nil {
+		return nil, id, err
+	}
+	return listings, last, nil
+}
@@ -0,0 +1,79 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/listing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryListings(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	first := time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC)
+	exp.clock = clock.Fixed(first)
+	require.NoError(t, exp.Export([]listing.Listing{
+		{Title: "2021 Santa Cruz Megatower", Manufacturer: "Santa Cruz", Model: "Megatower", Price: "4000", Category: "enduro", URL: "https://pinkbike.com/1"},
+		{Title: "2020 Yeti SB150", Manufacturer: "Yeti", Model: "SB150", Price: "3000", Category: "enduro", URL: "https://pinkbike.com/2"},
+	}))
+	exp.clock = clock.Fixed(first.AddDate(0, 0, 1))
+	require.NoError(t, exp.Export([]listing.Listing{
+		{Title: "2022 Santa Cruz Megatower", Manufacturer: "Santa Cruz", Model: "Megatower", Price: "5000", Category: "enduro", URL: "https://pinkbike.com/3"},
+	}))
+
+	all, err := exp.QueryListings(ListingQuery{})
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, "2022 Santa Cruz Megatower", all[0].Title, "newest first")
+
+	megatowers, err := exp.QueryListings(ListingQuery{Manufacturer: "santa cruz", Model: "MEGATOWER", Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, megatowers, 1)
+	assert.Equal(t, "2022 Santa Cruz Megatower", megatowers[0].Title)
+
+	_, err = exp.db.Exec("UPDATE listings SET active = 0 WHERE url = ?", "https://pinkbike.com/3")
+	require.NoError(t, err)
+	active, err := exp.QueryListings(ListingQuery{ActiveOnly: true})
+	require.NoError(t, err)
+	assert.Len(t, active, 2)
+
+	trail, err := exp.QueryListings(ListingQuery{Category: "trail"})
+	require.NoError(t, err)
+	assert.Empty(t, trail)
+}
+
+func TestListingsAfter(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	megatower := listing.Listing{Title: "2021 Santa Cruz Megatower", Price: "4000", URL: "https://pinkbike.com/1"}
+	require.NoError(t, exp.Export([]listing.Listing{megatower}))
+
+	cursor, err := exp.LastListingID()
+	require.NoError(t, err)
+	listings, next, err := exp.ListingsAfter(cursor, 10)
+	require.NoError(t, err)
+	assert.Empty(t, listings)
+	assert.Equal(t, cursor, next)
+
+	// a price change updates the stored listing rather than adding one
+	megatower.Price = "3800"
+	yeti := listing.Listing{Title: "2020 Yeti SB150", Price: "3000", URL: "https://pinkbike.com/2"}
+	nomad := listing.Listing{Title: "2019 Santa Cruz Nomad", Price: "2500", URL: "https://pinkbike.com/3"}
+	require.NoError(t, exp.Export([]listing.Listing{megatower, yeti, nomad}))
+
+	listings, next, err = exp.ListingsAfter(cursor, 1)
+	require.NoError(t, err)
+	require.Len(t, listings, 1)
+	assert.Equal(t, yeti.Title, listings[0].Title)
+
+	listings, next, err = exp.ListingsAfter(next, 10)
+	require.NoError(t, err)
+	require.Len(t, listings, 1)
+	assert.Equal(t, nomad.Title, listings[0].Title)
+
+	listings, _, err = exp.ListingsAfter(next, 10)
+	require.NoError(t, err)
+	assert.Empty(t, listings)
+}
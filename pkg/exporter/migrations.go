@@ -0,0 +1,589 @@
+package exporter
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/parser"
+)
+
+// addColumnIfMissing adds a column to an existing table, letting databases
+// created by older versions pick up new columns
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid                 int
+			name, colType       string
+			notNull, primaryKey int
+			defaultValue        sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &primaryKey); err != nil {
+			return fmt.Errorf("failed to inspect table %s: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	rows.Close()
+
+	if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+// schemaVersion is recorded in the database's user_version once migrate has
+// run. Bump it whenever migrate changes, so databases from older versions are
+// backed up before they are migrated.
+const schemaVersion = 18
+
+// needsMigration reports whether db holds tables from a version older than
+// schemaVersion. A new, empty database needs none.
+func needsMigration(db *sql.DB) (bool, error) {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return false, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version >= schemaVersion {
+		return false, nil
+	}
+
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'listings')").Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect database: %w", err)
+	}
+	return exists, nil
+}
+
+// SchemaStatus is the schema version of a database next to the one this
+// version migrates databases to
+type SchemaStatus struct {
+	// Exists is false when there is no database with listings at the path yet
+	Exists  bool
+	Version int
+	Current int
+}
+
+// Outdated reports whether the database will be migrated when it is next opened
+func (s SchemaStatus) Outdated() bool {
+	return s.Exists && s.Version < s.Current
+}
+
+// Newer reports whether the database was migrated by a newer version, which
+// may have changed tables in ways this one does not expect
+func (s SchemaStatus) Newer() bool {
+	return s.Exists && s.Version > s.Current
+}
+
+// InspectSchema reads the schema version of the database at dbPath without
+// creating or migrating it
+func InspectSchema(dbPath string) (SchemaStatus, error) {
+	status := SchemaStatus{Current: schemaVersion}
+	if _, err := os.Stat(dbPath); errors.Is(err, os.ErrNotExist) {
+		return status, nil
+	} else if err != nil {
+		return status, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	db, err := sql.Open(sqliteDriver, "file:"+dbPath+"?mode=ro")
+	if err != nil {
+		return status, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'listings')").Scan(&status.Exists); err != nil {
+		return status, fmt.Errorf("failed to inspect database: %w", err)
+	}
+	if err := db.QueryRow("PRAGMA user_version").Scan(&status.Version); err != nil {
+		return status, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return status, nil
+}
+
+// migrate brings tables created by older versions up to the current schema
+func migrate(db *sql.DB) error {
+	columns := []struct{ table, column, definition string }{
+		{"listings", "description", "TEXT"},
+		{"listings", "restrictions", "TEXT"},
+		{"listings", "seller_type", "TEXT"},
+		{"listings", "original_post_date", "DATETIME"},
+		{"listings", "field_metadata", "TEXT"},
+		{"listings", "confidence", "REAL"},
+		{"listings", "is_electric", "INTEGER DEFAULT 0"},
+		{"listings", "motor", "TEXT"},
+		{"listings", "battery_wh", "INTEGER"},
+		{"listings", "normalized_size", "TEXT"},
+		{"listings", "year_min", "INTEGER"},
+		{"listings", "year_max", "INTEGER"},
+		{"listings", "generation", "TEXT"},
+		{"listings", "wheel_front", "REAL"},
+		{"listings", "wheel_rear", "REAL"},
+		{"listings", "mullet", "INTEGER DEFAULT 0"},
+		{"listings", "rider_height_min", "INTEGER"},
+		{"listings", "rider_height_max", "INTEGER"},
+		{"listings", "condition_grade", "INTEGER"},
+		{"listings", "build_kit", "TEXT"},
+		{"listings", "build_tier", "INTEGER"},
+		{"listings", "category", "TEXT"},
+		{"listings", "kind", "TEXT"},
+		{"listings", "shock_size", "TEXT"},
+		{"listings", "steerer_length", "INTEGER"},
+		{"listings", "listing_id", "INTEGER"},
+		{"listings", "fingerprint", "TEXT"},
+		{"listings", "negotiable", "INTEGER DEFAULT 0"},
+		{"listings", "original_price", "REAL"},
+		{"listings", "original_currency", "TEXT"},
+		{"listings", "estimated_km", "INTEGER"},
+		{"listings", "seasons_used", "REAL"},
+		{"listings", "never_raced", "INTEGER DEFAULT 0"},
+		{"listings", "usage_confidence", "REAL"},
+		{"listings", "phone", "TEXT"},
+		{"listings", "photo_count", "INTEGER"},
+		{"listings", "view_count", "INTEGER"},
+		{"listings", "seller", "TEXT"},
+		{"listings", "listed_price", "TEXT"},
+		{"listings", "exchange_rate_id", "INTEGER REFERENCES exchange_rates(id)"},
+		{"listings", "predicted_price", "REAL"},
+		{"listings", "residual", "REAL"},
+		{"listings", "description_hash", "TEXT"},
+		{"listings", "fraud_score", "REAL"},
+		{"listings", "fraud_reasons", "TEXT"},
+		{"listings", "location", "TEXT"},
+		{"listings", "latitude", "REAL"},
+		{"listings", "longitude", "REAL"},
+		{"listings", "price_drop_advertised", "INTEGER DEFAULT 0"},
+		{"listings", "quality_score", "REAL"},
+		{"listings", "quality_issues", "TEXT"},
+		{"price_history", "exchange_rate_id", "INTEGER REFERENCES exchange_rates(id)"},
+		{"price_history", "listed_price", "TEXT"},
+		{"price_history", "exchange_rate", "REAL"},
+		{"runs", "blocked", "INTEGER DEFAULT 0"},
+		{"runs", "price_drops", "INTEGER DEFAULT 0"},
+		{"runs", "sold_listings", "INTEGER DEFAULT 0"},
+		{"runs", "unparsed_listings", "INTEGER DEFAULT 0"},
+		{"runs", "scrape_failures", "TEXT"},
+		{"runs", "parse_failures", "TEXT"},
+		{"runs", "exports", "TEXT"},
+		{"runs", "exceeded", "TEXT"},
+	}
+
+	for _, c := range columns {
+		if err := addColumnIfMissing(db, c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	// descriptions used to be stored as plain text
+	if _, err := db.Exec(`
+        UPDATE listings SET description = compress(description)
+        WHERE typeof(description) = 'text' AND description != ''
+    `); err != nil {
+		return fmt.Errorf("failed to compress descriptions: %w", err)
+	}
+
+	// indexes on added columns can only be created once the columns exist
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_listings_category ON listings(category)`); err != nil {
+		return fmt.Errorf("failed to create category index: %w", err)
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_listings_listing_id ON listings(listing_id)`); err != nil {
+		return fmt.Errorf("failed to create listing id index: %w", err)
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_listings_wheels ON listings(wheel_front, wheel_rear)`); err != nil {
+		return fmt.Errorf("failed to create wheels index: %w", err)
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_listings_seller ON listings(seller)`); err != nil {
+		return fmt.Errorf("failed to create seller index: %w", err)
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_listings_description_hash ON listings(description_hash)`); err != nil {
+		return fmt.Errorf("failed to create description hash index: %w", err)
+	}
+
+	if err := backfillListingIDs(db); err != nil {
+		return err
+	}
+	if err := backfillFingerprints(db); err != nil {
+		return err
+	}
+	if err := uniqueFingerprints(db); err != nil {
+		return err
+	}
+	if err := backfillWheels(db); err != nil {
+		return err
+	}
+	if err := backfillGenerations(db); err != nil {
+		return err
+	}
+	if err := backfillFraudScores(db); err != nil {
+		return err
+	}
+	if err := migrateReviewReasons(db); err != nil {
+		return err
+	}
+	if err := migrateGeocodes(db); err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", schemaVersion)); err != nil {
+		return fmt.Errorf("failed to record schema version: %w", err)
+	}
+	return nil
+}
+
+// backfillListingIDs fills in the listing ID of listings stored without one
+// from their URL, canonicalizing the URL as it goes
+func backfillListingIDs(db *sql.DB) error {
+	rows, err := db.Query("SELECT id, url FROM listings WHERE listing_id IS NULL AND url GLOB '*/buysell/[0-9]*'")
+	if err != nil {
+		return fmt.Errorf("failed to find listings without an id: %w", err)
+	}
+	defer rows.Close()
+
+	type pending struct {
+		rowID int64
+		url   string
+	}
+	var backfill []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.rowID, &p.url); err != nil {
+			return fmt.Errorf("failed to find listings without an id: %w", err)
+		}
+		backfill = append(backfill, p)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to find listings without an id: %w", err)
+	}
+	rows.Close()
+	if len(backfill) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, p := range backfill {
+		id := parser.ExtractListingID(p.url)
+		if id == 0 {
+			continue
+		}
+		if _, err := tx.Exec("UPDATE listings SET listing_id = ?, url = ? WHERE id = ?", id, parser.CanonicalURL(p.url), p.rowID); err != nil {
+			return fmt.Errorf("failed to backfill listing id: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// backfillFingerprints fingerprints listings stored before listings were
+// recognized by their fingerprint. It runs after backfillListingIDs, so
+// listings with a Pinkbike URL are fingerprinted by their ID. Listings without
+// a URL are fingerprinted by their stored hash, as Fingerprint would.
+func backfillFingerprints(db *sql.DB) error {
+	rows, err := db.Query(`
+        SELECT id, title, COALESCE(year, ''), COALESCE(manufacturer, ''), COALESCE(model, ''), COALESCE(condition, ''),
+            COALESCE(frame_size, ''), COALESCE(wheel_size, ''), COALESCE(frame_material, ''),
+            COALESCE(front_travel, ''), COALESCE(rear_travel, ''), COALESCE(url, ''), COALESCE(listing_id, 0), COALESCE(hash, '')
+        FROM listings WHERE fingerprint IS NULL
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to find listings without a fingerprint: %w", err)
+	}
+	defer rows.Close()
+
+	type pending struct {
+		rowID       int64
+		fingerprint string
+	}
+	var backfill []pending
+	for rows.Next() {
+		var p pending
+		var l listing.Listing
+		if err := rows.Scan(&p.rowID, &l.Title, &l.Year, &l.Manufacturer, &l.Model, &l.Condition,
+			&l.FrameSize, &l.WheelSize, &l.FrameMaterial, &l.FrontTravel, &l.RearTravel, &l.URL, &l.ListingID, &l.Hash); err != nil {
+			return fmt.Errorf("failed to find listings without a fingerprint: %w", err)
+		}
+		p.fingerprint = l.Fingerprint()
+		if strings.TrimSpace(l.URL) == "" {
+			p.fingerprint = l.Hash
+		}
+		backfill = append(backfill, p)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to find listings without a fingerprint: %w", err)
+	}
+	rows.Close()
+	if len(backfill) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, p := range backfill {
+		if _, err := tx.Exec("UPDATE listings SET fingerprint = ? WHERE id = ?", p.fingerprint, p.rowID); err != nil {
+			return fmt.Errorf("failed to backfill fingerprint: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// uniqueFingerprints makes listings unique by their fingerprint, which they
+// are stored by. Listings used to be stored by their hash, so a listing whose
+// seller edited it could be stored twice and listings sharing a hash were
+// stored as one. The older rows sharing a fingerprint are fingerprinted by
+// their hash instead, which keeps them and their history apart.
+func uniqueFingerprints(db *sql.DB) error {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'index' AND name = 'idx_listings_fingerprint_unique')").Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to inspect database: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	if _, err := db.Exec(`
+        UPDATE listings SET fingerprint = hash
+        WHERE EXISTS (
+            SELECT 1 FROM listings newer
+            WHERE newer.fingerprint = listings.fingerprint
+              AND (newer.last_seen > listings.last_seen OR (newer.last_seen = listings.last_seen AND newer.id > listings.id))
+        )
+    `); err != nil {
+		return fmt.Errorf("failed to deduplicate fingerprints: %w", err)
+	}
+	if _, err := db.Exec("DROP INDEX IF EXISTS idx_listings_fingerprint"); err != nil {
+		return fmt.Errorf("failed to create fingerprint index: %w", err)
+	}
+	if _, err := db.Exec("CREATE UNIQUE INDEX idx_listings_fingerprint_unique ON listings(fingerprint)"); err != nil {
+		return fmt.Errorf("failed to create fingerprint index: %w", err)
+	}
+	return nil
+}
+
+// backfillWheels parses the wheel configuration of listings stored before it
+// was, once: listings whose wheel size cannot be parsed keep none
+func backfillWheels(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version >= 12 {
+		return nil
+	}
+
+	rows, err := db.Query(`
+        SELECT id, COALESCE(wheel_size, ''), title FROM listings WHERE wheel_front IS NULL
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to find listings without wheels: %w", err)
+	}
+	defer rows.Close()
+
+	type pending struct {
+		rowID  int64
+		wheels parser.Wheels
+	}
+	var backfill []pending
+	for rows.Next() {
+		var p pending
+		var wheelSize, title string
+		if err := rows.Scan(&p.rowID, &wheelSize, &title); err != nil {
+			return fmt.Errorf("failed to find listings without wheels: %w", err)
+		}
+		if p.wheels = parser.ParseWheels(wheelSize, title); !p.wheels.IsZero() {
+			backfill = append(backfill, p)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to find listings without wheels: %w", err)
+	}
+	rows.Close()
+	if len(backfill) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, p := range backfill {
+		if _, err := tx.Exec("UPDATE listings SET wheel_front = ?, wheel_rear = ?, mullet = ? WHERE id = ?",
+			p.wheels.Front, p.wheels.Rear, p.wheels.Mullet, p.rowID); err != nil {
+			return fmt.Errorf("failed to backfill wheels: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// backfillGenerations reads the generation of listings stored before
+// generations were, once, when migrating from a version without them. The
+// model is read from the title again, since versions such as "Ripmo V2" used
+// to be stored as models of their own; reparse splits those stored models.
+func backfillGenerations(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version >= 14 {
+		return nil
+	}
+
+	rows, err := db.Query(`
+        SELECT id, COALESCE(manufacturer, ''), title FROM listings WHERE generation IS NULL
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to find listings without a generation: %w", err)
+	}
+	defer rows.Close()
+
+	type pending struct {
+		rowID      int64
+		generation string
+	}
+	var backfill []pending
+	for rows.Next() {
+		var p pending
+		var manufacturer, title string
+		if err := rows.Scan(&p.rowID, &manufacturer, &title); err != nil {
+			return fmt.Errorf("failed to find listings without a generation: %w", err)
+		}
+		if p.generation = parser.ExtractGeneration(manufacturer, parser.ExtractModel(title), title); p.generation != "" {
+			backfill = append(backfill, p)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to find listings without a generation: %w", err)
+	}
+	rows.Close()
+	if len(backfill) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, p := range backfill {
+		if _, err := tx.Exec("UPDATE listings SET generation = ? WHERE id = ?", p.generation, p.rowID); err != nil {
+			return fmt.Errorf("failed to backfill generation: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// backfillFraudScores scores the listings stored before listings were
+// assessed for scams, once, when migrating from a version without fraud
+// scores. Until then only listings exported again were scored, so suspected
+// scams among the rest went into deals, medians and comps.
+func backfillFraudScores(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version >= 18 {
+		return nil
+	}
+
+	rows, err := db.Query(`
+        SELECT hash, COALESCE(manufacturer, ''), COALESCE(model, ''), COALESCE(generation, ''), COALESCE(price, '')
+        FROM listings WHERE fraud_score IS NULL AND hash IS NOT NULL
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to find listings without a fraud score: %w", err)
+	}
+	defer rows.Close()
+
+	var backfill []listing.Listing
+	for rows.Next() {
+		var l listing.Listing
+		if err := rows.Scan(&l.Hash, &l.Manufacturer, &l.Model, &l.Generation, &l.Price); err != nil {
+			return fmt.Errorf("failed to find listings without a fraud score: %w", err)
+		}
+		backfill = append(backfill, l)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to find listings without a fraud score: %w", err)
+	}
+	rows.Close()
+	if len(backfill) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := (&DBExporter{db: db}).scoreListings(tx, backfill); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// migrateReviewReasons rewrites review reasons stored joined by commas, as
+// older versions did, as a JSON array
+func migrateReviewReasons(db *sql.DB) error {
+	rows, err := db.Query("SELECT id, needs_review FROM listings WHERE needs_review != '' AND needs_review NOT LIKE '[%'")
+	if err != nil {
+		return fmt.Errorf("failed to find review reasons to migrate: %w", err)
+	}
+	defer rows.Close()
+
+	type pending struct {
+		rowID   int64
+		reasons string
+	}
+	var migrated []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.rowID, &p.reasons); err != nil {
+			return fmt.Errorf("failed to find review reasons to migrate: %w", err)
+		}
+		migrated = append(migrated, p)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to find review reasons to migrate: %w", err)
+	}
+	rows.Close()
+	if len(migrated) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, p := range migrated {
+		if _, err := tx.Exec("UPDATE listings SET needs_review = ? WHERE id = ?", encodeReasons(listing.ParseReasons(p.reasons)), p.rowID); err != nil {
+			return fmt.Errorf("failed to migrate review reasons: %w", err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,56 @@
+package exporter
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/listing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type natsMsg struct {
+	subject string
+	data    []byte
+}
+
+type fakeNATSConn struct {
+	published []natsMsg
+	flushed   bool
+}
+
+func (c *fakeNATSConn) Publish(subject string, data []byte) error {
+	c.published = append(c.published, natsMsg{subject, data})
+	return nil
+}
+
+func (c *fakeNATSConn) FlushTimeout(time.Duration) error {
+	c.flushed = true
+	return nil
+}
+
+func (c *fakeNATSConn) Close() {}
+
+func TestNATSExporterPublishesListings(t *testing.T) {
+	conn := &fakeNATSConn{}
+	scraped := time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC)
+	exp := &NATSExporter{conn: conn, opts: NATSOptions{Subject: "pinkbike.listings", Clock: clock.Fixed(scraped)}}
+
+	megatower := listing.Listing{Title: "2021 Santa Cruz Megatower", Manufacturer: "Santa Cruz", Model: "Megatower", Price: "4000", Currency: "USD", Category: "enduro", FrameSize: "L", URL: "https://pinkbike.com/1"}
+	require.NoError(t, exp.Export([]listing.Listing{megatower, {Title: "Unsorted", URL: "https://pinkbike.com/2"}}))
+	require.Len(t, conn.published, 2)
+	assert.True(t, conn.flushed)
+	assert.Equal(t, "pinkbike.listings.enduro", conn.published[0].subject)
+	assert.Equal(t, "pinkbike.listings.unknown", conn.published[1].subject)
+
+	var msg NATSMessage
+	require.NoError(t, json.Unmarshal(conn.published[0].data, &msg))
+	assert.Equal(t, megatower.ComputeHash(), msg.Hash)
+	assert.Equal(t, "Megatower", msg.Model)
+	assert.Equal(t, "L", msg.FrameSize)
+	assert.True(t, msg.ScrapedAt.Equal(scraped))
+	assert.Contains(t, string(conn.published[0].data), `"frame_size":"L"`)
+}
@@ -0,0 +1,385 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// parquetRowGroupRows is how many rows a Parquet dump buffers before writing
+// them out as a row group, which bounds its memory however large the table
+var parquetRowGroupRows = 10000
+
+// Parquet enums, as numbered in the format's Thrift definitions
+const (
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetOptional = 1 // FieldRepetitionType
+	parquetUTF8     = 0 // ConvertedType
+	parquetPlain    = 0 // Encoding
+	parquetRLE      = 3
+	parquetDataPage = 0 // PageType
+)
+
+var parquetMagic = []byte("PAR1")
+
+// parquetColumn buffers the values of one column of a row group
+type parquetColumn struct {
+	name string
+	kind int32
+	// values are the PLAIN encoded values of the rows that have one, and
+	// defined records which rows do
+	values  bytes.Buffer
+	defined []bool
+}
+
+// parquetChunk is where a written column chunk is in the file
+type parquetChunk struct {
+	offset, size int64
+}
+
+// parquetRowGroup is a written row group
+type parquetRowGroup struct {
+	rows   int64
+	chunks []parquetChunk
+}
+
+// parquetWriter writes rows as a Parquet file. Every column is optional,
+// uncompressed and PLAIN encoded, with one data page per column chunk, which
+// any Parquet reader can read. Integer and real columns keep their types; the
+// rest, times included, are written as UTF-8 strings as the other dump
+// formats write them.
+type parquetWriter struct {
+	w       io.Writer
+	offset  int64
+	columns []*parquetColumn
+	groups  []parquetRowGroup
+	// buffered is the number of rows buffered for the next row group
+	buffered int
+}
+
+// newParquetWriter writes columns of the given declared SQL types to w
+func newParquetWriter(w io.Writer, columns, types []string) *parquetWriter {
+	p := &parquetWriter{w: w}
+	for i, name := range columns {
+		c := &parquetColumn{name: name, kind: parquetByteArray}
+		declared := strings.ToUpper(types[i])
+		switch {
+		case strings.Contains(declared, "INT"):
+			c.kind = parquetInt64
+		case strings.Contains(declared, "REAL"), strings.Contains(declared, "FLOA"), strings.Contains(declared, "DOUB"):
+			c.kind = parquetDouble
+		}
+		p.columns = append(p.columns, c)
+	}
+	return p
+}
+
+func (p *parquetWriter) write(b []byte) error {
+	n, err := p.w.Write(b)
+	p.offset += int64(n)
+	return err
+}
+
+func (p *parquetWriter) begin() error {
+	return p.write(parquetMagic)
+}
+
+func (p *parquetWriter) row(values []interface{}) error {
+	for i, v := range values {
+		c := p.columns[i]
+		c.defined = append(c.defined, v != nil)
+		if v == nil {
+			continue
+		}
+		if err := c.add(v); err != nil {
+			return fmt.Errorf("column %s: %w", c.name, err)
+		}
+	}
+	p.buffered++
+	if p.buffered >= parquetRowGroupRows {
+		return p.flush()
+	}
+	return nil
+}
+
+// add PLAIN encodes a value of the column
+func (c *parquetColumn) add(v interface{}) error {
+	var b [8]byte
+	switch c.kind {
+	case parquetInt64:
+		var n int64
+		switch v := v.(type) {
+		case int64:
+			n = v
+		case bool:
+			if v {
+				n = 1
+			}
+		case float64:
+			if v != math.Trunc(v) {
+				return fmt.Errorf("%v is not an integer", v)
+			}
+			n = int64(v)
+		case string:
+			var err error
+			if n, err = strconv.ParseInt(v, 10, 64); err != nil {
+				return fmt.Errorf("%q is not an integer", v)
+			}
+		default:
+			return fmt.Errorf("%v is not an integer", v)
+		}
+		binary.LittleEndian.PutUint64(b[:], uint64(n))
+		c.values.Write(b[:])
+	case parquetDouble:
+		var f float64
+		switch v := v.(type) {
+		case float64:
+			f = v
+		case int64:
+			f = float64(v)
+		case string:
+			var err error
+			if f, err = strconv.ParseFloat(v, 64); err != nil {
+				return fmt.Errorf("%q is not a number", v)
+			}
+		default:
+			return fmt.Errorf("%v is not a number", v)
+		}
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(f))
+		c.values.Write(b[:])
+	default:
+		s := fmt.Sprint(v)
+		binary.LittleEndian.PutUint32(b[:4], uint32(len(s)))
+		c.values.Write(b[:4])
+		c.values.WriteString(s)
+	}
+	return nil
+}
+
+// flush writes the buffered rows as a row group, one data page per column
+func (p *parquetWriter) flush() error {
+	group := parquetRowGroup{rows: int64(p.buffered)}
+	for _, c := range p.columns {
+		levels := encodeParquetLevels(c.defined)
+		page := make([]byte, 4, 4+len(levels)+c.values.Len())
+		binary.LittleEndian.PutUint32(page, uint32(len(levels)))
+		page = append(append(page, levels...), c.values.Bytes()...)
+
+		var header thriftWriter
+		header.begin()
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(len(page)))
+		header.i32(3, int32(len(page)))
+		header.structBegin(5)
+		header.i32(1, int32(p.buffered))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.end()
+		header.end()
+
+		chunk := parquetChunk{offset: p.offset, size: int64(header.b.Len() + len(page))}
+		if err := p.write(header.b.Bytes()); err != nil {
+			return err
+		}
+		if err := p.write(page); err != nil {
+			return err
+		}
+		group.chunks = append(group.chunks, chunk)
+		c.values.Reset()
+		c.defined = c.defined[:0]
+	}
+	p.groups = append(p.groups, group)
+	p.buffered = 0
+	return nil
+}
+
+// encodeParquetLevels encodes definition levels, 1 for a value and 0 for a
+// null, as runs of the RLE/bit-packing hybrid encoding with a bit width of 1
+func encodeParquetLevels(defined []bool) []byte {
+	var b []byte
+	for i := 0; i < len(defined); {
+		j := i
+		for j < len(defined) && defined[j] == defined[i] {
+			j++
+		}
+		b = binary.AppendUvarint(b, uint64(j-i)<<1)
+		if defined[i] {
+			b = append(b, 1)
+		} else {
+			b = append(b, 0)
+		}
+		i = j
+	}
+	return b
+}
+
+// end writes the last row group and the footer holding the file's metadata
+func (p *parquetWriter) end() error {
+	if p.buffered > 0 {
+		if err := p.flush(); err != nil {
+			return err
+		}
+	}
+
+	var rows int64
+	for _, g := range p.groups {
+		rows += g.rows
+	}
+
+	var meta thriftWriter
+	meta.begin()
+	meta.i32(1, 1)
+	meta.list(2, thriftStruct, len(p.columns)+1)
+	meta.begin()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(p.columns)))
+	meta.end()
+	for _, c := range p.columns {
+		meta.begin()
+		meta.i32(1, c.kind)
+		meta.i32(3, parquetOptional)
+		meta.binary(4, c.name)
+		if c.kind == parquetByteArray {
+			meta.i32(6, parquetUTF8)
+		}
+		meta.end()
+	}
+	meta.i64(3, rows)
+	meta.list(4, thriftStruct, len(p.groups))
+	for _, g := range p.groups {
+		var size int64
+		meta.begin()
+		meta.list(1, thriftStruct, len(g.chunks))
+		for i, chunk := range g.chunks {
+			c := p.columns[i]
+			meta.begin()
+			meta.i64(2, chunk.offset)
+			meta.structBegin(3)
+			meta.i32(1, c.kind)
+			meta.list(2, thriftI32, 2)
+			meta.elemI32(parquetPlain)
+			meta.elemI32(parquetRLE)
+			meta.list(3, thriftBinary, 1)
+			meta.elemBinary(c.name)
+			meta.i32(4, 0) // uncompressed
+			meta.i64(5, g.rows)
+			meta.i64(6, chunk.size)
+			meta.i64(7, chunk.size)
+			meta.i64(9, chunk.offset)
+			meta.end()
+			meta.end()
+			size += chunk.size
+		}
+		meta.i64(2, size)
+		meta.i64(3, g.rows)
+		meta.end()
+	}
+	meta.binary(6, "pinkbike-scraper")
+	meta.end()
+
+	if err := p.write(meta.b.Bytes()); err != nil {
+		return err
+	}
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(meta.b.Len()))
+	if err := p.write(length[:]); err != nil {
+		return err
+	}
+	return p.write(parquetMagic)
+}
+
+// Thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs in the Thrift compact protocol Parquet
+// metadata is written in. Each struct is opened with begin, or structBegin
+// for a struct field, and closed with end; its fields must be written in
+// increasing id order.
+type thriftWriter struct {
+	b bytes.Buffer
+	// last is the id of the last field written to each open struct
+	last []int16
+}
+
+func (t *thriftWriter) begin() {
+	t.last = append(t.last, 0)
+}
+
+func (t *thriftWriter) end() {
+	t.b.WriteByte(0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+func (t *thriftWriter) field(id int16, kind byte) {
+	last := &t.last[len(t.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.b.WriteByte(byte(delta)<<4 | kind)
+	} else {
+		t.b.WriteByte(kind)
+		t.varint(int64(id))
+	}
+	*last = id
+}
+
+// varint writes a zigzag encoded varint
+func (t *thriftWriter) varint(v int64) {
+	var b [binary.MaxVarintLen64]byte
+	t.b.Write(b[:binary.PutUvarint(b[:], uint64(v<<1^v>>63))])
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(v)
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.elemBinary(s)
+}
+
+func (t *thriftWriter) structBegin(id int16) {
+	t.field(id, thriftStruct)
+	t.begin()
+}
+
+// list starts a list field of n elements, which follow as elemI32,
+// elemBinary or begin and end
+func (t *thriftWriter) list(id int16, kind byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.b.WriteByte(byte(n)<<4 | kind)
+		return
+	}
+	t.b.WriteByte(0xf0 | kind)
+	var b [binary.MaxVarintLen64]byte
+	t.b.Write(b[:binary.PutUvarint(b[:], uint64(n))])
+}
+
+func (t *thriftWriter) elemI32(v int32) {
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) elemBinary(s string) {
+	var b [binary.MaxVarintLen64]byte
+	t.b.Write(b[:binary.PutUvarint(b[:], uint64(len(s)))])
+	t.b.WriteString(s)
+}
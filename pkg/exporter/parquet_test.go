@@ -0,0 +1,213 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// thriftReader decodes Thrift compact protocol structs into maps of field
+// id to value, enough to read back the metadata parquetWriter writes
+type thriftReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) varint() int64 {
+	u := r.uvarint()
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+func (r *thriftReader) value(kind byte) interface{} {
+	switch kind {
+	case thriftI32, thriftI64:
+		return r.varint()
+	case thriftBinary:
+		n := int(r.uvarint())
+		r.pos += n
+		return string(r.b[r.pos-n : r.pos])
+	case thriftList:
+		header := r.b[r.pos]
+		r.pos++
+		n := int(header >> 4)
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			list[i] = r.value(header & 0x0f)
+		}
+		return list
+	case thriftStruct:
+		return r.structure()
+	}
+	panic(fmt.Sprintf("unexpected thrift type %d", kind))
+}
+
+func (r *thriftReader) structure() map[int16]interface{} {
+	fields := map[int16]interface{}{}
+	var id int16
+	for {
+		header := r.b[r.pos]
+		r.pos++
+		if header == 0 {
+			return fields
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.varint())
+		}
+		fields[id] = r.value(header & 0x0f)
+	}
+}
+
+// readParquet reads back a file parquetWriter wrote, returning its rows as
+// maps of column name to value
+func readParquet(t *testing.T, data []byte) (int, []map[string]interface{}) {
+	t.Helper()
+
+	require.True(t, bytes.HasPrefix(data, parquetMagic))
+	require.True(t, bytes.HasSuffix(data, parquetMagic))
+	length := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := &thriftReader{b: data[len(data)-8-length : len(data)-8]}
+	meta := footer.structure()
+	require.Equal(t, length, footer.pos, "the footer is one struct")
+
+	schema := meta[2].([]interface{})
+	root := schema[0].(map[int16]interface{})
+	require.Equal(t, int64(len(schema)-1), root[5])
+
+	var rows []map[string]interface{}
+	for _, g := range meta[4].([]interface{}) {
+		group := g.(map[int16]interface{})
+		n := int(group[3].(int64))
+		start := len(rows)
+		for i := 0; i < n; i++ {
+			rows = append(rows, map[string]interface{}{})
+		}
+		for i, c := range group[1].([]interface{}) {
+			column := c.(map[int16]interface{})[3].(map[int16]interface{})
+			element := schema[i+1].(map[int16]interface{})
+			name := element[4].(string)
+			require.Equal(t, []interface{}{name}, column[3])
+			require.Equal(t, int64(n), column[5])
+
+			page := &thriftReader{b: data, pos: int(column[9].(int64))}
+			header := page.structure()
+			require.Equal(t, column[6], int64(page.pos)-column[9].(int64)+header[3].(int64), "the chunk is one page")
+			require.Equal(t, int64(n), header[5].(map[int16]interface{})[1])
+			body := data[page.pos : page.pos+int(header[3].(int64))]
+
+			levelsEnd := 4 + int(binary.LittleEndian.Uint32(body))
+			levels := &thriftReader{b: body[:levelsEnd], pos: 4}
+			var defined []bool
+			for levels.pos < levelsEnd {
+				run := levels.uvarint()
+				require.Zero(t, run&1, "levels are written as RLE runs")
+				level := body[levels.pos]
+				levels.pos++
+				for j := uint64(0); j < run>>1; j++ {
+					defined = append(defined, level == 1)
+				}
+			}
+			require.Len(t, defined, n)
+
+			values := body[levelsEnd:]
+			for j, ok := range defined {
+				if !ok {
+					rows[start+j][name] = nil
+					continue
+				}
+				switch element[1].(int64) {
+				case parquetInt64:
+					rows[start+j][name] = int64(binary.LittleEndian.Uint64(values))
+					values = values[8:]
+				case parquetDouble:
+					rows[start+j][name] = math.Float64frombits(binary.LittleEndian.Uint64(values))
+					values = values[8:]
+				case parquetByteArray:
+					size := int(binary.LittleEndian.Uint32(values))
+					rows[start+j][name] = string(values[4 : 4+size])
+					values = values[4+size:]
+				}
+			}
+			require.Empty(t, values)
+		}
+	}
+	require.Equal(t, int64(len(rows)), meta[3])
+	return len(schema) - 1, rows
+}
+
+func TestDumpParquet(t *testing.T) {
+	exp := newDumpTestDB(t)
+	_, err := exp.db.Exec("UPDATE listings SET latitude = 49.28, longitude = -123.12, view_count = 40 WHERE title = '2022 Yeti SB150'")
+	require.NoError(t, err)
+
+	for _, table := range DumpTables {
+		var parquet, jsonOut bytes.Buffer
+		n, err := exp.Dump(&parquet, DumpOptions{Table: table, Format: DumpParquet})
+		require.NoError(t, err)
+		_, err = exp.Dump(&jsonOut, DumpOptions{Table: table, Format: DumpJSON})
+		require.NoError(t, err)
+
+		var want []map[string]interface{}
+		require.NoError(t, json.Unmarshal(jsonOut.Bytes(), &want))
+		columns, rows := readParquet(t, parquet.Bytes())
+		require.Len(t, rows, n)
+		require.Len(t, want, n)
+		assert.Len(t, want[0], columns)
+		for i := range rows {
+			for name, v := range rows[i] {
+				if n, ok := v.(int64); ok {
+					v = float64(n)
+				}
+				assert.Equal(t, want[i][name], v, "%s row %d column %s", table, i, name)
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	_, err = exp.Dump(&out, DumpOptions{Table: "listings", Format: DumpParquet})
+	require.NoError(t, err)
+	_, rows := readParquet(t, out.Bytes())
+	assert.Equal(t, "2022 Yeti SB150", rows[1]["title"])
+	assert.Equal(t, 49.28, rows[1]["latitude"], "real columns stay numbers")
+	assert.Equal(t, int64(40), rows[1]["view_count"], "integer columns stay integers")
+	assert.Nil(t, rows[0]["latitude"])
+	assert.Equal(t, "Coil shock, fresh bearings", rows[0]["description"])
+	assert.Equal(t, "2024-05-01T08:00:00Z", rows[0]["first_seen"])
+}
+
+func TestDumpParquetRowGroups(t *testing.T) {
+	exp := newDumpTestDB(t)
+	defer func(rows int) { parquetRowGroupRows = rows }(parquetRowGroupRows)
+	parquetRowGroupRows = 1
+
+	var out bytes.Buffer
+	_, err := exp.Dump(&out, DumpOptions{Table: "listings", Format: DumpParquet})
+	require.NoError(t, err)
+	_, rows := readParquet(t, out.Bytes())
+	require.Len(t, rows, 2)
+	assert.Equal(t, "2021 Evil Wreckoning", rows[0]["title"])
+	assert.Equal(t, "2022 Yeti SB150", rows[1]["title"])
+
+	out.Reset()
+	n, err := exp.Dump(&out, DumpOptions{Table: "listings", Format: DumpParquet, Manufacturer: "Trek"})
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	_, rows = readParquet(t, out.Bytes())
+	assert.Empty(t, rows)
+}
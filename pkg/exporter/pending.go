@@ -0,0 +1,223 @@
+package exporter
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"pinkbike-scraper/pkg/listing"
+)
+
+const (
+	pendingInitialBackoff = 15 * time.Minute
+	pendingMaxBackoff     = 24 * time.Hour
+)
+
+// PendingExport is a batch an external sink could not take, kept in the
+// database so a later run can send it again
+type PendingExport struct {
+	ID          int64
+	Sink        string
+	Payload     []byte
+	Attempts    int
+	NextAttempt time.Time
+	LastError   string
+}
+
+// pendingBackoff is how long to wait before the next attempt after attempts failures
+func pendingBackoff(attempts int) time.Duration {
+	backoff := pendingInitialBackoff
+	for i := 1; i < attempts && backoff < pendingMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > pendingMaxBackoff {
+		backoff = pendingMaxBackoff
+	}
+	return backoff
+}
+
+// QueueExport stores a payload that sink failed to take, to be retried once
+// the first backoff has passed
+func (e *DBExporter) QueueExport(sink string, payload []byte, cause error) error {
+	_, err := e.db.Exec(`
+        INSERT INTO pending_exports (sink, payload, attempts, next_attempt, last_error)
+        VALUES (?, ?, 1, ?, ?)
+    `, sink, payload, e.clock.Now().Add(pendingBackoff(1)).UTC().Format(sqliteTimeFormat), cause.Error())
+	if err != nil {
+		return fmt.Errorf("failed to queue %s export: %w", sink, err)
+	}
+	return nil
+}
+
+// PendingExports returns the payloads queued for sink, oldest first
+func (e *DBExporter) PendingExports(sink string) ([]PendingExport, error) {
+	rows, err := e.db.Query(`
+        SELECT id, sink, payload, attempts, next_attempt, last_error
+        FROM pending_exports
+        WHERE sink = ?
+        ORDER BY id
+    `, sink)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending exports: %w", err)
+	}
+	defer rows.Close()
+
+	var pending []PendingExport
+	for rows.Next() {
+		var p PendingExport
+		var next interface{}
+		var lastError sql.NullString
+		if err := rows.Scan(&p.ID, &p.Sink, &p.Payload, &p.Attempts, &next, &lastError); err != nil {
+			return nil, fmt.Errorf("failed to scan pending export: %w", err)
+		}
+		if p.NextAttempt, err = parseSQLiteTime(next); err != nil {
+			return nil, err
+		}
+		p.LastError = lastError.String
+		pending = append(pending, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query pending exports: %w", err)
+	}
+	return pending, nil
+}
+
+// PendingSink summarizes the queue of one sink
+type PendingSink struct {
+	Sink    string
+	Batches int
+	// NextAttempt is the earliest time a queued batch is due
+	NextAttempt time.Time
+	// LastError is why the most recently tried batch failed
+	LastError string
+}
+
+// PendingSinks returns every sink with queued payloads, by name
+func (e *DBExporter) PendingSinks() ([]PendingSink, error) {
+	rows, err := e.db.Query(`
+        SELECT p.sink, COUNT(*), MIN(p.next_attempt),
+               (SELECT last_error FROM pending_exports l WHERE l.sink = p.sink ORDER BY l.next_attempt DESC, l.id DESC LIMIT 1)
+        FROM pending_exports p
+        GROUP BY p.sink
+        ORDER BY p.sink
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending exports: %w", err)
+	}
+	defer rows.Close()
+
+	var sinks []PendingSink
+	for rows.Next() {
+		var s PendingSink
+		var next interface{}
+		var lastError sql.NullString
+		if err := rows.Scan(&s.Sink, &s.Batches, &next, &lastError); err != nil {
+			return nil, fmt.Errorf("failed to scan pending exports: %w", err)
+		}
+		if s.NextAttempt, err = parseSQLiteTime(next); err != nil {
+			return nil, err
+		}
+		s.LastError = lastError.String
+		sinks = append(sinks, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query pending exports: %w", err)
+	}
+	return sinks, nil
+}
+
+// RetryPending sends sink's queued payloads whose backoff has passed, oldest
+// first. Sent payloads are removed. The first failure is rescheduled with a
+// longer backoff and the rest wait for a later run, so a sink that is still
+// down is only tried once. Returns how many payloads were sent.
+func (e *DBExporter) RetryPending(sink string, send func(payload []byte) error) (int, error) {
+	return e.retryPending(sink, send, false)
+}
+
+// FlushPending sends all of sink's queued payloads now, whether or not their
+// backoff has passed, stopping at the first failure like RetryPending
+func (e *DBExporter) FlushPending(sink string, send func(payload []byte) error) (int, error) {
+	return e.retryPending(sink, send, true)
+}
+
+func (e *DBExporter) retryPending(sink string, send func(payload []byte) error, force bool) (int, error) {
+	pending, err := e.PendingExports(sink)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, p := range pending {
+		if !force && p.NextAttempt.After(e.clock.Now()) {
+			continue
+		}
+
+		if sendErr := send(p.Payload); sendErr != nil {
+			p.Attempts++
+			_, err := e.db.Exec(`
+                UPDATE pending_exports SET attempts = ?, next_attempt = ?, last_error = ?
+                WHERE id = ?
+            `, p.Attempts, e.clock.Now().Add(pendingBackoff(p.Attempts)).UTC().Format(sqliteTimeFormat), sendErr.Error(), p.ID)
+			if err != nil {
+				return sent, fmt.Errorf("failed to reschedule %s export: %w", sink, err)
+			}
+			return sent, nil
+		}
+
+		if _, err := e.db.Exec("DELETE FROM pending_exports WHERE id = ?", p.ID); err != nil {
+			return sent, fmt.Errorf("failed to remove sent %s export: %w", sink, err)
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// RetryingExporter wraps an exporter for an external sink. Batches it fails
+// to take are queued in the database and sent again, ahead of the new batch,
+// on later runs.
+type RetryingExporter struct {
+	Exporter
+	sink  string
+	queue *DBExporter
+}
+
+func NewRetryingExporter(sink string, exp Exporter, queue *DBExporter) *RetryingExporter {
+	return &RetryingExporter{Exporter: exp, sink: sink, queue: queue}
+}
+
+func (r *RetryingExporter) Export(listings []listing.Listing) error {
+	sent, err := r.queue.RetryPending(r.sink, BatchSender(r.Exporter))
+	if err != nil {
+		return err
+	}
+	if sent > 0 {
+		fmt.Printf("%s: sent %d queued batches\n", r.sink, sent)
+	}
+
+	exportErr := r.Exporter.Export(listings)
+	if exportErr == nil {
+		return nil
+	}
+
+	payload, err := json.Marshal(listings)
+	if err != nil {
+		return fmt.Errorf("failed to encode batch: %w", err)
+	}
+	if err := r.queue.QueueExport(r.sink, payload, exportErr); err != nil {
+		return fmt.Errorf("%v, and %w", exportErr, err)
+	}
+	return fmt.Errorf("%w (queued for retry)", exportErr)
+}
+
+// BatchSender returns a send function for RetryPending and FlushPending that
+// exports batches queued by a RetryingExporter to exp
+func BatchSender(exp Exporter) func(payload []byte) error {
+	return func(payload []byte) error {
+		var batch []listing.Listing
+		if err := json.Unmarshal(payload, &batch); err != nil {
+			return fmt.Errorf("failed to decode queued batch: %w", err)
+		}
+		return exp.Export(batch)
+	}
+}
@@ -0,0 +1,116 @@
+package exporter
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/listing"
+)
+
+// flakyExporter fails while down and records the batches it receives
+type flakyExporter struct {
+	down    bool
+	batches [][]listing.Listing
+}
+
+func (f *flakyExporter) Export(listings []listing.Listing) error {
+	if f.down {
+		return errors.New("503 service unavailable")
+	}
+	f.batches = append(f.batches, listings)
+	return nil
+}
+
+func (f *flakyExporter) Close() error { return nil }
+
+func TestRetryingExporter(t *testing.T) {
+	at := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	db := newTestDBExporter(t, nil)
+	db.clock = clock.Fixed(at)
+	sink := &flakyExporter{down: true}
+	exp := NewRetryingExporter("sheets:Enduro", sink, db)
+
+	first := []listing.Listing{{Title: "2021 Evil Wreckoning", Price: "3900"}}
+	err := exp.Export(first)
+	assert.ErrorContains(t, err, "queued for retry")
+
+	pending, err := db.PendingExports("sheets:Enduro")
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Equal(t, at.Add(15*time.Minute), pending[0].NextAttempt)
+	assert.Equal(t, "503 service unavailable", pending[0].LastError)
+
+	// the sink is back but the backoff has not passed, only the new batch is sent
+	sink.down = false
+	second := []listing.Listing{{Title: "2020 Kona Process 153", Price: "2200"}}
+	require.NoError(t, exp.Export(second))
+	assert.Equal(t, [][]listing.Listing{second}, sink.batches)
+
+	// still down when the backoff passes, the wait doubles
+	sink.down = true
+	at = at.Add(time.Hour)
+	db.clock = clock.Fixed(at)
+	sent, err := db.RetryPending("sheets:Enduro", func(payload []byte) error { return errors.New("timeout") })
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+	pending, err = db.PendingExports("sheets:Enduro")
+	require.NoError(t, err)
+	assert.Equal(t, 2, pending[0].Attempts)
+	assert.Equal(t, at.Add(30*time.Minute), pending[0].NextAttempt)
+
+	// on a later run the queued batch goes out ahead of the new one
+	sink.down = false
+	sink.batches = nil
+	at = at.Add(time.Hour)
+	db.clock = clock.Fixed(at)
+	require.NoError(t, exp.Export(second))
+	require.Len(t, sink.batches, 2)
+	assert.Equal(t, first[0].Title, sink.batches[0][0].Title)
+	assert.Equal(t, second, sink.batches[1])
+
+	pending, err = db.PendingExports("sheets:Enduro")
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestPendingBackoff(t *testing.T) {
+	assert.Equal(t, 15*time.Minute, pendingBackoff(1))
+	assert.Equal(t, time.Hour, pendingBackoff(3))
+	assert.Equal(t, 24*time.Hour, pendingBackoff(50))
+}
+
+func TestFlushPending(t *testing.T) {
+	at := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	db := newTestDBExporter(t, nil)
+	db.clock = clock.Fixed(at)
+	sink := &flakyExporter{down: true}
+	exp := NewRetryingExporter("sheets:Enduro", sink, db)
+
+	batch := []listing.Listing{{Title: "2021 Evil Wreckoning", Price: "3900"}}
+	assert.Error(t, exp.Export(batch))
+	require.NoError(t, db.QueueExport("webhook:https://example.com/hook", []byte(`{"title":"x"}`), errors.New("timeout")))
+
+	sinks, err := db.PendingSinks()
+	require.NoError(t, err)
+	require.Len(t, sinks, 2)
+	assert.Equal(t, PendingSink{Sink: "sheets:Enduro", Batches: 1, NextAttempt: at.Add(15 * time.Minute), LastError: "503 service unavailable"}, sinks[0])
+	assert.Equal(t, "webhook:https://example.com/hook", sinks[1].Sink)
+
+	// flushing sends queued batches before their backoff has passed
+	sink.down = false
+	sent, err := db.FlushPending("sheets:Enduro", BatchSender(sink))
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, [][]listing.Listing{batch}, sink.batches)
+
+	sinks, err = db.PendingSinks()
+	require.NoError(t, err)
+	require.Len(t, sinks, 1)
+	assert.Equal(t, "webhook:https://example.com/hook", sinks[0].Sink)
+}
@@ -0,0 +1,90 @@
+package exporter
+
+import "fmt"
+
+// PriceDropStats compares the listings whose title advertises a price drop
+// with the drops their price history shows
+type PriceDropStats struct {
+	// Advertised is how many listings advertise a drop, of which Confirmed
+	// went down in price while they were tracked and Unverified were only
+	// seen on one day, too briefly to tell. The others were seen at the same
+	// or a higher price throughout, so their drop happened before they were
+	// first scraped or not at all.
+	Advertised, Confirmed, Unverified int
+	// Unadvertised is how many listings advertise no drop, of which Dropped
+	// went down in price anyway, the baseline drop rate
+	Unadvertised, Dropped int
+}
+
+// ConfirmedShare is the share of advertised drops that could be verified and
+// showed up in the price history, zero when none could be verified
+func (s PriceDropStats) ConfirmedShare() float64 {
+	verifiable := s.Advertised - s.Unverified
+	if verifiable <= 0 {
+		return 0
+	}
+	return float64(s.Confirmed) / float64(verifiable)
+}
+
+// DroppedShare is the share of listings not advertising a drop that dropped
+// in price anyway
+func (s PriceDropStats) DroppedShare() float64 {
+	if s.Unadvertised == 0 {
+		return 0
+	}
+	return float64(s.Dropped) / float64(s.Unadvertised)
+}
+
+// PriceDrops checks the price drops advertised in titles against the price
+// history of every stored listing, or those of one category. Prices are
+// compared as listed, so exchange rate moves of CAD prices are not drops.
+func (e *DBExporter) PriceDrops(category string) (PriceDropStats, error) {
+	rows, err := e.db.Query(`
+        WITH prices AS (
+            SELECT listing_hash, CAST(price AS REAL) AS amount, valid_from AS at FROM price_history_compacted
+            UNION ALL
+            SELECT listing_hash, CAST(COALESCE(NULLIF(listed_price, ''), price) AS REAL), recorded_at FROM price_history
+        )
+        SELECT
+            COALESCE(l.price_drop_advertised, 0),
+            EXISTS(
+                SELECT 1 FROM prices a JOIN prices b ON b.listing_hash = a.listing_hash
+                WHERE a.listing_hash = l.hash AND datetime(b.at) > datetime(a.at) AND b.amount < a.amount AND b.amount > 0
+            ),
+            (SELECT COUNT(DISTINCT date(at)) FROM prices WHERE listing_hash = l.hash)
+        FROM listings l
+        WHERE ? = '' OR l.category = ?
+    `, category, category)
+	if err != nil {
+		return PriceDropStats{}, fmt.Errorf("failed to check price drops: %w", err)
+	}
+	defer rows.Close()
+
+	var stats PriceDropStats
+	for rows.Next() {
+		var advertised, dropped bool
+		var days int
+		if err := rows.Scan(&advertised, &dropped, &days); err != nil {
+			return PriceDropStats{}, fmt.Errorf("failed to check price drops: %w", err)
+		}
+		switch {
+		case !advertised:
+			stats.Unadvertised++
+			if dropped {
+				stats.Dropped++
+			}
+		case dropped:
+			stats.Advertised++
+			stats.Confirmed++
+		case days <= 1:
+			stats.Advertised++
+			stats.Unverified++
+		default:
+			stats.Advertised++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return PriceDropStats{}, fmt.Errorf("failed to check price drops: %w", err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,47 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/listing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriceDrops(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	start := time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)
+	scrape := func(title, price, url string) listing.Listing {
+		return listing.RawListing{Title: title, Price: price, URL: url}.PostProcess(1.0, nil)
+	}
+
+	exp.clock = clock.Fixed(start)
+	require.NoError(t, exp.Export([]listing.Listing{
+		scrape("PRICE DROP 2021 Yeti SB130", "$4000 USD", "https://pinkbike.com/1"),
+		scrape("2020 Trek Slash - reduced", "$3000 USD", "https://pinkbike.com/2"),
+		scrape("2022 Ibis Ripmo price drop", "$3800 USD", "https://pinkbike.com/3"),
+		scrape("2019 Norco Range", "$2500 USD", "https://pinkbike.com/4"),
+		scrape("2021 Kona Process", "$2800 USD", "https://pinkbike.com/5"),
+	}))
+
+	exp.clock = clock.Fixed(start.AddDate(0, 0, 2))
+	require.NoError(t, exp.Export([]listing.Listing{
+		scrape("PRICE DROP 2021 Yeti SB130", "$3500 USD", "https://pinkbike.com/1"),
+		scrape("2022 Ibis Ripmo price drop", "$3800 USD", "https://pinkbike.com/3"),
+		scrape("2019 Norco Range", "$2300 USD", "https://pinkbike.com/4"),
+		scrape("2021 Kona Process", "$2900 USD", "https://pinkbike.com/5"),
+	}))
+
+	stats, err := exp.PriceDrops("")
+	require.NoError(t, err)
+	assert.Equal(t, PriceDropStats{Advertised: 3, Confirmed: 1, Unverified: 1, Unadvertised: 2, Dropped: 1}, stats)
+	assert.Equal(t, 0.5, stats.ConfirmedShare())
+	assert.Equal(t, 0.5, stats.DroppedShare())
+
+	stored, err := exp.FindListing("https://pinkbike.com/2")
+	require.NoError(t, err)
+	assert.True(t, stored.PriceDropAdvertised)
+}
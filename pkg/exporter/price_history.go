@@ -0,0 +1,202 @@
+package exporter
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// sqliteTimeFormat matches the format CURRENT_TIMESTAMP writes
+const sqliteTimeFormat = "2006-01-02 15:04:05"
+
+// PriceRange is a price that held for a listing between From and To. Raw price
+// history entries are returned as ranges where From equals To.
+type PriceRange struct {
+	Price, Currency string
+	From, To        time.Time
+}
+
+// PriceHistory returns the price history of a listing in chronological order,
+// combining compacted ranges with raw entries that have not been compacted yet
+func (e *DBExporter) PriceHistory(hash string) ([]PriceRange, error) {
+	rows, err := e.db.Query(`
+        SELECT price, COALESCE(currency, ''), valid_from, valid_to FROM price_history_compacted
+        WHERE listing_hash = ?
+        UNION ALL
+        SELECT price, COALESCE(currency, ''), recorded_at, recorded_at FROM price_history
+        WHERE listing_hash = ?
+        ORDER BY 3
+    `, hash, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query price history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []PriceRange
+	for rows.Next() {
+		var r PriceRange
+		var from, to interface{}
+		if err := rows.Scan(&r.Price, &r.Currency, &from, &to); err != nil {
+			return nil, fmt.Errorf("failed to scan price history: %w", err)
+		}
+		if r.From, err = parseSQLiteTime(from); err != nil {
+			return nil, err
+		}
+		if r.To, err = parseSQLiteTime(to); err != nil {
+			return nil, err
+		}
+		history = append(history, r)
+	}
+
+	return history, rows.Err()
+}
+
+// CompactPriceHistory collapses raw price history recorded before cutoff into
+// ranges of consecutive identical prices and removes the raw rows. It returns
+// the number of raw rows compacted.
+func (e *DBExporter) CompactPriceHistory(cutoff time.Time) (int, error) {
+	tx, err := e.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	cutoffStr := cutoff.UTC().Format(sqliteTimeFormat)
+	rows, err := tx.Query(`
+        SELECT listing_hash, price, COALESCE(currency, ''), recorded_at FROM price_history
+        WHERE datetime(recorded_at) < datetime(?)
+        ORDER BY listing_hash, datetime(recorded_at), id
+    `, cutoffStr)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query price history: %w", err)
+	}
+
+	type rawPrice struct {
+		hash, price, currency string
+		recordedAt            time.Time
+	}
+	var raw []rawPrice
+	for rows.Next() {
+		var p rawPrice
+		var recordedAt interface{}
+		if err := rows.Scan(&p.hash, &p.price, &p.currency, &recordedAt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan price history: %w", err)
+		}
+		if p.recordedAt, err = parseSQLiteTime(recordedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		raw = append(raw, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query price history: %w", err)
+	}
+
+	var current *compactedRange
+	for _, p := range raw {
+		if current == nil || current.hash != p.hash {
+			if err := current.save(tx); err != nil {
+				return 0, err
+			}
+			if current, err = lastCompactedRange(tx, p.hash); err != nil {
+				return 0, err
+			}
+		}
+
+		if current != nil && current.price == p.price && current.currency == p.currency {
+			current.to = p.recordedAt
+			continue
+		}
+		if err := current.save(tx); err != nil {
+			return 0, err
+		}
+
+		current = &compactedRange{hash: p.hash, price: p.price, currency: p.currency, from: p.recordedAt, to: p.recordedAt}
+	}
+	if err := current.save(tx); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM price_history WHERE datetime(recorded_at) < datetime(?)`, cutoffStr); err != nil {
+		return 0, fmt.Errorf("failed to delete compacted price history: %w", err)
+	}
+
+	return len(raw), tx.Commit()
+}
+
+type compactedRange struct {
+	id                    int64
+	hash, price, currency string
+	from, to              time.Time
+}
+
+// lastCompactedRange returns the most recent compacted range of a listing so new
+// entries with the same price extend it rather than starting a new range. It
+// returns nil when the listing has no compacted history.
+func lastCompactedRange(tx *sql.Tx, hash string) (*compactedRange, error) {
+	r := &compactedRange{hash: hash}
+	var from, to interface{}
+	err := tx.QueryRow(`
+        SELECT id, price, COALESCE(currency, ''), valid_from, valid_to FROM price_history_compacted
+        WHERE listing_hash = ?
+        ORDER BY datetime(valid_to) DESC LIMIT 1
+    `, hash).Scan(&r.id, &r.price, &r.currency, &from, &to)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query compacted price history: %w", err)
+	}
+	if r.from, err = parseSQLiteTime(from); err != nil {
+		return nil, err
+	}
+	if r.to, err = parseSQLiteTime(to); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *compactedRange) save(tx *sql.Tx) error {
+	if r == nil {
+		return nil
+	}
+
+	from, to := r.from.UTC().Format(sqliteTimeFormat), r.to.UTC().Format(sqliteTimeFormat)
+	var err error
+	if r.id != 0 {
+		_, err = tx.Exec(`UPDATE price_history_compacted SET valid_to = ? WHERE id = ?`, to, r.id)
+	} else {
+		_, err = tx.Exec(`
+            INSERT INTO price_history_compacted (listing_hash, price, currency, valid_from, valid_to)
+            VALUES (?, ?, ?, ?, ?)
+        `, r.hash, r.price, r.currency, from, to)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save compacted price history: %w", err)
+	}
+	return nil
+}
+
+// parseSQLiteTime converts a DATETIME column value, which the driver returns as
+// either a time or a string depending on how it was written, to a time
+func parseSQLiteTime(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case nil:
+		return time.Time{}, nil
+	case []byte:
+		return parseSQLiteTime(string(t))
+	case string:
+		for _, layout := range []string{sqliteTimeFormat, time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00", "2006-01-02"} {
+			if parsed, err := time.Parse(layout, t); err == nil {
+				return parsed, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("unrecognised time %q", t)
+	default:
+		return time.Time{}, fmt.Errorf("unexpected time value %T", v)
+	}
+}
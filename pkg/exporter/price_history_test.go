@@ -0,0 +1,85 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompactPriceHistory(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+
+	day := func(d int) time.Time {
+		return time.Date(2024, 9, d, 12, 0, 0, 0, time.UTC)
+	}
+	for _, hash := range []string{"a", "b"} {
+		_, err := exp.db.Exec(`INSERT INTO listings (hash) VALUES (?)`, hash)
+		require.NoError(t, err)
+	}
+
+	record := func(hash, price string, at time.Time) {
+		_, err := exp.db.Exec(`INSERT INTO price_history (listing_hash, price, currency, recorded_at) VALUES (?, ?, 'USD', ?)`,
+			hash, price, at.Format(sqliteTimeFormat))
+		require.NoError(t, err)
+	}
+
+	record("a", "4000", day(1))
+	record("a", "4000", day(2))
+	record("a", "3500", day(3))
+	record("a", "3500", day(4))
+	record("b", "2000", day(2))
+
+	compacted, err := exp.CompactPriceHistory(day(4))
+	require.NoError(t, err)
+	assert.Equal(t, 4, compacted)
+
+	record("a", "3500", day(5))
+	record("a", "3000", day(6))
+
+	history, err := exp.PriceHistory("a")
+	require.NoError(t, err)
+	assert.Equal(t, []PriceRange{
+		{Price: "4000", Currency: "USD", From: day(1), To: day(2)},
+		{Price: "3500", Currency: "USD", From: day(3), To: day(3)},
+		{Price: "3500", Currency: "USD", From: day(4), To: day(4)},
+		{Price: "3500", Currency: "USD", From: day(5), To: day(5)},
+		{Price: "3000", Currency: "USD", From: day(6), To: day(6)},
+	}, history)
+
+	// a second compaction extends the existing 3500 range
+	compacted, err = exp.CompactPriceHistory(day(6))
+	require.NoError(t, err)
+	assert.Equal(t, 2, compacted)
+
+	history, err = exp.PriceHistory("a")
+	require.NoError(t, err)
+	assert.Equal(t, []PriceRange{
+		{Price: "4000", Currency: "USD", From: day(1), To: day(2)},
+		{Price: "3500", Currency: "USD", From: day(3), To: day(5)},
+		{Price: "3000", Currency: "USD", From: day(6), To: day(6)},
+	}, history)
+}
+
+func TestPriceHistoryWithoutCurrency(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	at := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+	_, err := exp.db.Exec(`INSERT INTO listings (hash) VALUES ('a')`)
+	require.NoError(t, err)
+	// rows recorded before prices had a currency
+	_, err = exp.db.Exec(`INSERT INTO price_history (listing_hash, price, recorded_at) VALUES ('a', '4000', ?), ('a', '4000', ?)`,
+		at.Format(sqliteTimeFormat), at.AddDate(0, 0, 1).Format(sqliteTimeFormat))
+	require.NoError(t, err)
+
+	history, err := exp.PriceHistory("a")
+	require.NoError(t, err)
+	assert.Len(t, history, 2)
+
+	compacted, err := exp.CompactPriceHistory(at.AddDate(0, 0, 2))
+	require.NoError(t, err)
+	assert.Equal(t, 2, compacted)
+	history, err = exp.PriceHistory("a")
+	require.NoError(t, err)
+	assert.Equal(t, []PriceRange{{Price: "4000", From: at, To: at.AddDate(0, 0, 1)}}, history)
+}
@@ -0,0 +1,253 @@
+package exporter
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"pinkbike-scraper/pkg/geo"
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/parser"
+)
+
+// FieldChange is a stored field a reparse changed
+type FieldChange struct {
+	Field    string
+	Old, New string
+}
+
+// StoredListings loads every stored listing, or those of one category, with
+// the fields a reparse derives the others from and when each was on sale
+func (e *DBExporter) StoredListings(category string) ([]listing.Listing, error) {
+	return e.loadListings("WHERE ? = '' OR category = ? ORDER BY id", category, category)
+}
+
+// storedListingColumns are the listing columns loadListings scans
+const storedListingColumns = `
+        hash, title, year, manufacturer, model, price, currency, condition,
+        frame_size, wheel_size, front_travel, rear_travel, frame_material,
+        needs_review, url, COALESCE(decompress(description), ''), restrictions,
+        seller_type, original_post_date, field_metadata, is_electric, motor,
+        battery_wh, normalized_size, condition_grade, category, active, listing_id,
+        negotiable, original_price, original_currency,
+        estimated_km, seasons_used, never_raced, usage_confidence,
+        listed_price, predicted_price, first_seen, last_seen, seller, photo_count, view_count,
+        location, latitude, longitude, price_drop_advertised, kind, shock_size, steerer_length,
+        wheel_front, wheel_rear, mullet, year_min, year_max, generation, build_kit, build_tier, fraud_score`
+
+// loadListings loads the listings picked by clauses, the WHERE, ORDER BY and
+// LIMIT parts of the query
+func (e *DBExporter) loadListings(clauses string, args ...interface{}) ([]listing.Listing, error) {
+	rows, err := e.db.Query("SELECT "+storedListingColumns+" FROM listings "+clauses, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load listings: %w", err)
+	}
+	defer rows.Close()
+
+	scanner, err := newRowScanner(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var listings []listing.Listing
+	for rows.Next() {
+		var (
+			f                                   [30]sql.NullString
+			postDate, firstSeen, lastSeen       sql.NullTime
+			electric, active, negotiable, raced sql.NullBool
+			dropAdvertised, mullet              sql.NullBool
+			batteryWh, grade, id, km            sql.NullInt64
+			photos, views, steerer              sql.NullInt64
+			yearMin, yearMax, buildTier         sql.NullInt64
+			originalPrice, seasons, confidence  sql.NullFloat64
+			predicted, latitude, longitude      sql.NullFloat64
+			wheelFront, wheelRear, fraudScore   sql.NullFloat64
+		)
+		dest := make([]sql.Scanner, 0, 58)
+		for i := range f[:18] {
+			dest = append(dest, &f[i])
+		}
+		dest = append(dest, &postDate, &f[18], &electric, &f[19], &batteryWh, &f[20], &grade, &f[21], &active, &id,
+			&negotiable, &originalPrice, &f[22], &km, &seasons, &raced, &confidence, &f[23], &predicted, &firstSeen, &lastSeen,
+			&f[24], &photos, &views, &f[25], &latitude, &longitude, &dropAdvertised, &f[26], &f[27], &steerer,
+			&wheelFront, &wheelRear, &mullet, &yearMin, &yearMax, &f[28], &f[29], &buildTier, &fraudScore)
+		if err := scanner.scan(rows, dest...); err != nil {
+			if e.skipRow(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to scan listing: %w", err)
+		}
+		l := listing.Listing{
+			Hash: f[0].String, Title: f[1].String, Year: f[2].String, Manufacturer: f[3].String,
+			Model: f[4].String, Generation: f[28].String, Price: f[5].String, Currency: f[6].String, Condition: f[7].String,
+			FrameSize: f[8].String, WheelSize: f[9].String, FrontTravel: f[10].String,
+			RearTravel: f[11].String, FrameMaterial: f[12].String, NeedsReview: listing.ParseReasons(f[13].String),
+			URL: f[14].String, IsElectric: electric.Bool, NormalizedSize: f[20].String,
+			ConditionGrade: parser.ConditionGrade(grade.Int64), Category: f[21].String, Active: active.Bool,
+			ListingID: int(id.Int64), Negotiable: negotiable.Bool, PriceDropAdvertised: dropAdvertised.Bool, FirstSeen: firstSeen.Time, LastSeen: lastSeen.Time,
+			ListedPrice: f[23].String, PredictedPrice: predicted.Float64, FraudScore: fraudScore.Float64,
+			Kind: parser.Kind(f[26].String), ShockSize: f[27].String, SteererLength: int(steerer.Int64),
+			Wheels:        parser.Wheels{Front: wheelFront.Float64, Rear: wheelRear.Float64, Mullet: mullet.Bool},
+			InferredYears: parser.YearRange{Min: int(yearMin.Int64), Max: int(yearMax.Int64)},
+			BuildTier:     parser.BuildTier{Kit: f[29].String, Rank: parser.TierRank(buildTier.Int64)},
+			Details: listing.ListingDetails{
+				Description: f[15].String, Restrictions: f[16].String, SellerType: listing.SellerType(f[17].String),
+				OriginalPostDate: postDate.Time, Motor: f[19].String, BatteryWh: int(batteryWh.Int64),
+				OriginalPrice: parser.Price{Amount: originalPrice.Float64, Currency: f[22].String},
+				Usage: parser.Usage{
+					EstimatedKM: int(km.Int64), SeasonsUsed: seasons.Float64, NeverRaced: raced.Bool, Confidence: confidence.Float64,
+				},
+				Seller: f[24].String, PhotoCount: int(photos.Int64), ViewCount: int(views.Int64),
+			},
+			Location:    f[25].String,
+			Coordinates: geo.Point{Lat: latitude.Float64, Lon: longitude.Float64},
+		}
+		if f[18].Valid {
+			if err := json.Unmarshal([]byte(f[18].String), &l.Metadata); err != nil {
+				scanErr := scanner.errorAt("field_metadata", err)
+				if e.skipRow(scanErr) {
+					continue
+				}
+				return nil, fmt.Errorf("failed to decode field metadata: %w", scanErr)
+			}
+		}
+		listings = append(listings, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load listings: %w", err)
+	}
+	return listings, nil
+}
+
+// reparsedFields are the stored fields a reparse can change, with how each is
+// stored in the listings table
+func reparsedFields(l listing.Listing) []struct{ column, value string } {
+	min, max := l.RiderHeight()
+	var quality string
+	if q, ok := l.Quality(); ok {
+		quality = strconv.FormatFloat(q.Score, 'f', -1, 64)
+	}
+	return []struct{ column, value string }{
+		{"hash", l.Hash},
+		{"year", l.Year},
+		{"inferred_years", l.InferredYears.String()},
+		{"manufacturer", l.Manufacturer},
+		{"model", l.Model},
+		{"generation", l.Generation},
+		{"url", l.URL},
+		{"listing_id", strconv.Itoa(l.ListingID)},
+		{"needs_review", l.NeedsReview.String()},
+		{"is_electric", strconv.FormatBool(l.IsElectric)},
+		{"price_drop_advertised", strconv.FormatBool(l.PriceDropAdvertised)},
+		{"motor", l.Details.Motor},
+		{"battery_wh", strconv.Itoa(l.Details.BatteryWh)},
+		{"original_price", strconv.FormatFloat(l.Details.OriginalPrice.Amount, 'f', -1, 64)},
+		{"original_currency", l.Details.OriginalPrice.Currency},
+		{"estimated_km", strconv.Itoa(l.Details.Usage.EstimatedKM)},
+		{"seasons_used", strconv.FormatFloat(l.Details.Usage.SeasonsUsed, 'f', -1, 64)},
+		{"never_raced", strconv.FormatBool(l.Details.Usage.NeverRaced)},
+		{"usage_confidence", strconv.FormatFloat(l.Details.Usage.Confidence, 'f', -1, 64)},
+		{"normalized_size", l.NormalizedSize},
+		{"wheels", l.Wheels.String()},
+		{"rider_height_min", strconv.Itoa(min)},
+		{"rider_height_max", strconv.Itoa(max)},
+		{"condition_grade", strconv.Itoa(int(l.ConditionGrade))},
+		{"build_tier", l.BuildTier.Kit},
+		{"kind", string(l.Kind)},
+		{"shock_size", l.ShockSize},
+		{"steerer_length", strconv.Itoa(l.SteererLength)},
+		{"quality_score", quality},
+	}
+}
+
+// ReparseChanges lists the stored fields that differ between a listing as
+// stored and as reparsed
+func ReparseChanges(stored, reparsed listing.Listing) []FieldChange {
+	before, after := reparsedFields(stored), reparsedFields(reparsed)
+	var changes []FieldChange
+	for i := range before {
+		if before[i].value != after[i].value {
+			changes = append(changes, FieldChange{Field: before[i].column, Old: before[i].value, New: after[i].value})
+		}
+	}
+	return changes
+}
+
+// SaveReparse writes a reparsed listing over the stored one, re-keying it when
+// its hash changed, and records each changed field in reparse_changes. It
+// returns the changes, none when the reparse changed nothing.
+func (e *DBExporter) SaveReparse(stored, reparsed listing.Listing) ([]FieldChange, error) {
+	changes := ReparseChanges(stored, reparsed)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	tx, err := e.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if reparsed.Hash != stored.Hash {
+		var taken bool
+		if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM listings WHERE hash = ?)", reparsed.Hash).Scan(&taken); err != nil {
+			return nil, fmt.Errorf("failed to check reparsed hash: %w", err)
+		}
+		if taken {
+			return nil, fmt.Errorf("reparsed listing %s matches stored listing %s", stored.Hash, reparsed.Hash)
+		}
+	}
+
+	// price history references the old hash until it is re-keyed below
+	if _, err := tx.Exec("PRAGMA defer_foreign_keys = ON"); err != nil {
+		return nil, fmt.Errorf("failed to save reparse: %w", err)
+	}
+
+	metadata, confidence, err := encodeMetadata(reparsed)
+	if err != nil {
+		return nil, err
+	}
+	minHeight, maxHeight := reparsed.RiderHeight()
+	qualityScore, qualityIssues := storedQuality(reparsed)
+
+	if _, err := tx.Exec(`
+        UPDATE listings SET hash = ?, fingerprint = ?, year = ?, manufacturer = ?, model = ?, generation = ?, url = ?, listing_id = ?, needs_review = ?,
+            is_electric = ?, price_drop_advertised = ?, motor = ?, battery_wh = ?, original_price = ?, original_currency = ?,
+            estimated_km = ?, seasons_used = ?, never_raced = ?, usage_confidence = ?, normalized_size = ?,
+            wheel_front = ?, wheel_rear = ?, mullet = ?, year_min = ?, year_max = ?,
+            rider_height_min = ?, rider_height_max = ?, condition_grade = ?, build_kit = ?, build_tier = ?, kind = ?, shock_size = ?, steerer_length = ?,
+            quality_score = COALESCE(?, quality_score), quality_issues = COALESCE(?, quality_issues),
+            field_metadata = ?, confidence = ?
+        WHERE hash = ?
+    `, reparsed.Hash, reparsed.Fingerprint(), reparsed.Year, reparsed.Manufacturer, reparsed.Model, nullString(reparsed.Generation), reparsed.URL, nullInt(reparsed.ListingID), encodeReasons(reparsed.NeedsReview),
+		reparsed.IsElectric, reparsed.PriceDropAdvertised, nullString(reparsed.Details.Motor), nullInt(reparsed.Details.BatteryWh),
+		nullFloat(reparsed.Details.OriginalPrice.Amount), nullString(reparsed.Details.OriginalPrice.Currency),
+		usageKM(reparsed.Details.Usage), nullFloat(reparsed.Details.Usage.SeasonsUsed), reparsed.Details.Usage.NeverRaced, nullFloat(reparsed.Details.Usage.Confidence),
+		nullString(reparsed.NormalizedSize), nullFloat(reparsed.Wheels.Front), nullFloat(reparsed.Wheels.Rear), reparsed.Wheels.Mullet,
+		nullInt(reparsed.InferredYears.Min), nullInt(reparsed.InferredYears.Max),
+		nullInt(minHeight), nullInt(maxHeight), nullInt(int(reparsed.ConditionGrade)), nullString(reparsed.BuildTier.Kit), nullInt(int(reparsed.BuildTier.Rank)),
+		nullString(string(reparsed.Kind)), nullString(reparsed.ShockSize), nullInt(reparsed.SteererLength),
+		qualityScore, qualityIssues,
+		metadata, confidence, stored.Hash); err != nil {
+		return nil, fmt.Errorf("failed to save reparse: %w", err)
+	}
+
+	if err := e.rekey(tx, stored.Hash, reparsed.Hash); err != nil {
+		return nil, fmt.Errorf("failed to save reparse: %w", err)
+	}
+
+	for _, c := range changes {
+		if _, err := tx.Exec(`
+            INSERT INTO reparse_changes (hash, field, old_value, new_value, changed_at)
+            VALUES (?, ?, ?, ?, ?)
+        `, reparsed.Hash, c.Field, c.Old, c.New, e.now()); err != nil {
+			return nil, fmt.Errorf("failed to record reparse change: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to save reparse: %w", err)
+	}
+	return changes, nil
+}
@@ -0,0 +1,61 @@
+package exporter
+
+import (
+	"testing"
+
+	"pinkbike-scraper/pkg/listing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveReparse(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+
+	// stored by an older parser that did not know the model
+	stale := listing.Listing{
+		Title: "2019 Santa Cruz Nomad", Year: "2019", Manufacturer: "NoManufacturer", Model: "NoModelFound",
+		Price: "3000", Currency: "USD", Condition: "Good - Used, Mechanically Sound", FrameSize: "L", WheelSize: "27.5",
+		FrontTravel: "170 mm", RearTravel: "170 mm", FrameMaterial: "Carbon Fiber", NeedsReview: listing.Reasons{"manufacturer"},
+		Category: "enduro",
+	}
+	require.NoError(t, exp.Export([]listing.Listing{stale}))
+
+	stored, err := exp.StoredListings("enduro")
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	assert.Equal(t, stale.ComputeHash(), stored[0].Hash)
+	assert.False(t, stored[0].FirstSeen.IsZero())
+	assert.False(t, stored[0].LastSeen.IsZero())
+
+	reparsed := stored[0].Reparse(nil)
+	reparsed.Hash = reparsed.ComputeHash()
+	require.Equal(t, "Santa Cruz", reparsed.Manufacturer)
+
+	changes, err := exp.SaveReparse(stored[0], reparsed)
+	require.NoError(t, err)
+	assert.Contains(t, changes, FieldChange{Field: "manufacturer", Old: "NoManufacturer", New: "Santa Cruz"})
+	assert.Contains(t, changes, FieldChange{Field: "hash", Old: stored[0].Hash, New: reparsed.Hash})
+
+	var logged int
+	require.NoError(t, exp.db.QueryRow("SELECT COUNT(*) FROM reparse_changes WHERE hash = ?", reparsed.Hash).Scan(&logged))
+	assert.Equal(t, len(changes), logged)
+
+	var historyHash string
+	require.NoError(t, exp.db.QueryRow("SELECT listing_hash FROM price_history").Scan(&historyHash))
+	assert.Equal(t, reparsed.Hash, historyHash)
+
+	// a second reparse finds nothing left to change
+	stored, err = exp.StoredListings("")
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	again := stored[0].Reparse(nil)
+	again.Hash = again.ComputeHash()
+	changes, err = exp.SaveReparse(stored[0], again)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	stored, err = exp.StoredListings("trail")
+	require.NoError(t, err)
+	assert.Empty(t, stored)
+}
@@ -0,0 +1,268 @@
+package exporter
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/parser"
+)
+
+const (
+	manufacturerAlias = "manufacturer"
+	modelAlias        = "model"
+)
+
+// Correction is a reviewer's fix for the fields parsed from a listing title
+type Correction struct {
+	Year, Manufacturer, Model string
+}
+
+func (c Correction) apply(l listing.Listing, clk clock.Clock) listing.Listing {
+	l.Year, l.Manufacturer, l.Model = c.Year, c.Manufacturer, c.Model
+	l = l.Revalidate(clk)
+	for _, field := range []string{"year", "manufacturer", "model"} {
+		l.Metadata.Set(field, listing.SourceCorrection)
+	}
+	return l
+}
+
+// loadCorrections returns saved corrections keyed by the hash of the listing as parsed
+func loadCorrections(tx *sql.Tx) (map[string]Correction, error) {
+	rows, err := tx.Query("SELECT hash, year, manufacturer, model FROM corrections")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load corrections: %w", err)
+	}
+	defer rows.Close()
+
+	corrections := map[string]Correction{}
+	for rows.Next() {
+		var hash string
+		var c Correction
+		if err := rows.Scan(&hash, &c.Year, &c.Manufacturer, &c.Model); err != nil {
+			return nil, fmt.Errorf("failed to load corrections: %w", err)
+		}
+		corrections[hash] = c
+	}
+	return corrections, rows.Err()
+}
+
+// ListingsNeedingReview returns active listings flagged for review, oldest first
+func (e *DBExporter) ListingsNeedingReview(limit int) ([]listing.Listing, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := e.db.Query(`
+        SELECT hash, title, year, manufacturer, model, price, currency, condition,
+               frame_size, wheel_size, front_travel, rear_travel, frame_material,
+               needs_review, url, field_metadata, year_min, year_max
+        FROM listings
+        WHERE active = 1 AND needs_review != ''
+        ORDER BY first_seen
+        LIMIT ?
+    `, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find listings needing review: %w", err)
+	}
+	defer rows.Close()
+
+	scanner, err := newRowScanner(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var listings []listing.Listing
+	for rows.Next() {
+		var f [16]sql.NullString
+		var yearMin, yearMax sql.NullInt64
+		dest := make([]sql.Scanner, len(f), len(f)+2)
+		for i := range f {
+			dest[i] = &f[i]
+		}
+		dest = append(dest, &yearMin, &yearMax)
+		if err := scanner.scan(rows, dest...); err != nil {
+			if e.skipRow(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to scan listing: %w", err)
+		}
+		l := listing.Listing{
+			Hash: f[0].String, Title: f[1].String, Year: f[2].String, Manufacturer: f[3].String,
+			Model: f[4].String, Price: f[5].String, Currency: f[6].String, Condition: f[7].String,
+			FrameSize: f[8].String, WheelSize: f[9].String, FrontTravel: f[10].String,
+			RearTravel: f[11].String, FrameMaterial: f[12].String, NeedsReview: listing.ParseReasons(f[13].String),
+			URL: f[14].String, Active: true, InferredYears: parser.YearRange{Min: int(yearMin.Int64), Max: int(yearMax.Int64)},
+		}
+		if f[15].Valid {
+			if err := json.Unmarshal([]byte(f[15].String), &l.Metadata); err != nil {
+				scanErr := scanner.errorAt("field_metadata", err)
+				if e.skipRow(scanErr) {
+					continue
+				}
+				return nil, fmt.Errorf("failed to decode field metadata: %w", scanErr)
+			}
+		}
+		listings = append(listings, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to find listings needing review: %w", err)
+	}
+	return listings, nil
+}
+
+// SaveCorrection stores a reviewer's fix for l, a listing returned by
+// ListingsNeedingReview. The stored listing is re-keyed to the hash of the
+// corrected fields and later scrapes of the same listing get the fix applied.
+func (e *DBExporter) SaveCorrection(l listing.Listing, c Correction) (listing.Listing, error) {
+	corrected := c.apply(l, e.clock)
+
+	tx, err := e.db.Begin()
+	if err != nil {
+		return l, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// price history references the old hash until it is re-keyed below
+	if _, err := tx.Exec("PRAGMA defer_foreign_keys = ON"); err != nil {
+		return l, fmt.Errorf("failed to save correction: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+        INSERT INTO corrections (hash, year, manufacturer, model)
+        VALUES (?, ?, ?, ?)
+        ON CONFLICT(hash) DO UPDATE SET
+            year = excluded.year,
+            manufacturer = excluded.manufacturer,
+            model = excluded.model,
+            corrected_at = CURRENT_TIMESTAMP
+    `, l.Hash, c.Year, c.Manufacturer, c.Model); err != nil {
+		return l, fmt.Errorf("failed to save correction: %w", err)
+	}
+
+	metadata, confidence, err := encodeMetadata(corrected)
+	if err != nil {
+		return l, err
+	}
+
+	if _, err := tx.Exec(`
+        UPDATE listings SET year = ?, manufacturer = ?, model = ?, needs_review = ?, hash = ?, fingerprint = ?,
+            field_metadata = ?, confidence = ?
+        WHERE hash = ?
+    `, corrected.Year, corrected.Manufacturer, corrected.Model, encodeReasons(corrected.NeedsReview), corrected.Hash, corrected.Fingerprint(),
+		metadata, confidence, l.Hash); err != nil {
+		return l, fmt.Errorf("failed to correct listing: %w", err)
+	}
+
+	if err := e.rekey(tx, l.Hash, corrected.Hash); err != nil {
+		return l, fmt.Errorf("failed to correct listing: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return l, fmt.Errorf("failed to save correction: %w", err)
+	}
+	return corrected, nil
+}
+
+// rekey points the rows that reference a listing at its new hash. The
+// listing row itself is updated by the caller, with foreign keys deferred.
+func (e *DBExporter) rekey(tx *sql.Tx, oldHash, newHash string) error {
+	queries := []string{
+		"UPDATE price_history SET listing_hash = ? WHERE listing_hash = ?",
+		"UPDATE price_history_compacted SET listing_hash = ? WHERE listing_hash = ?",
+		"UPDATE listing_events SET listing_hash = ? WHERE listing_hash = ?",
+		"UPDATE favorites SET listing_hash = ? WHERE listing_hash = ?",
+		"UPDATE favorite_changes SET listing_hash = ? WHERE listing_hash = ?",
+		"UPDATE listing_edits SET listing_hash = ? WHERE listing_hash = ?",
+	}
+	if e.fts {
+		queries = append(queries, "UPDATE listings_fts SET hash = ? WHERE hash = ?")
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query, newHash, oldHash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddManufacturerAlias records that name in a title refers to manufacturer
+func (e *DBExporter) AddManufacturerAlias(name, manufacturer string) error {
+	return e.addAlias(manufacturerAlias, "", name, manufacturer)
+}
+
+// AddModelAlias records that name in a title refers to one of manufacturer's models
+func (e *DBExporter) AddModelAlias(manufacturer, name, model string) error {
+	return e.addAlias(modelAlias, manufacturer, name, model)
+}
+
+func (e *DBExporter) addAlias(kind, manufacturer, name, canonical string) error {
+	_, err := e.db.Exec(`
+        INSERT INTO aliases (kind, manufacturer, alias, canonical)
+        VALUES (?, ?, ?, ?)
+        ON CONFLICT(kind, manufacturer, alias) DO UPDATE SET canonical = excluded.canonical
+    `, kind, manufacturer, name, canonical)
+	if err != nil {
+		return fmt.Errorf("failed to add alias: %w", err)
+	}
+	return nil
+}
+
+// Aliases loads the aliases learned from reviewed listings
+func (e *DBExporter) Aliases() (*parser.Aliases, error) {
+	rows, err := e.db.Query("SELECT kind, manufacturer, alias, canonical FROM aliases ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aliases: %w", err)
+	}
+	defer rows.Close()
+
+	aliases := &parser.Aliases{}
+	for rows.Next() {
+		var kind, manufacturer, name, canonical string
+		if err := rows.Scan(&kind, &manufacturer, &name, &canonical); err != nil {
+			return nil, fmt.Errorf("failed to load aliases: %w", err)
+		}
+		switch kind {
+		case manufacturerAlias:
+			aliases.AddManufacturer(name, canonical)
+		case modelAlias:
+			aliases.AddModel(manufacturer, name, canonical)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load aliases: %w", err)
+	}
+	return aliases, nil
+}
+
+// UnmatchedModelTitles returns the titles of listings seen since the given time
+// whose manufacturer was found but whose model was not, grouped by manufacturer
+func (e *DBExporter) UnmatchedModelTitles(since time.Time) (map[string][]string, error) {
+	rows, err := e.db.Query(`
+        SELECT manufacturer, title FROM listings
+        WHERE model = 'NoModelFound'
+        AND manufacturer != '' AND manufacturer != 'NoManufacturer'
+        AND datetime(last_seen) >= datetime(?)
+        ORDER BY last_seen DESC
+    `, since.UTC().Format(sqliteTimeFormat))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find unmatched titles: %w", err)
+	}
+	defer rows.Close()
+
+	titles := map[string][]string{}
+	for rows.Next() {
+		var manufacturer, title string
+		if err := rows.Scan(&manufacturer, &title); err != nil {
+			return nil, fmt.Errorf("failed to scan unmatched title: %w", err)
+		}
+		titles[manufacturer] = append(titles[manufacturer], title)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to find unmatched titles: %w", err)
+	}
+	return titles, nil
+}
@@ -0,0 +1,82 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"pinkbike-scraper/pkg/listing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveCorrection(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+
+	parsed := listing.Listing{
+		Title: "2019 SC Nomad 27.5", Year: "2019", Manufacturer: "NoManufacturer", Model: "NoModelFound",
+		Price: "3000", Currency: "USD", Condition: "Good", FrameSize: "L", WheelSize: "27.5",
+		FrontTravel: "170 mm", RearTravel: "170 mm", FrameMaterial: "Carbon Fiber", NeedsReview: listing.Reasons{"manufacturer"},
+	}
+	require.NoError(t, exp.Export([]listing.Listing{parsed}))
+
+	flagged, err := exp.ListingsNeedingReview(0)
+	require.NoError(t, err)
+	require.Len(t, flagged, 1)
+	assert.Equal(t, parsed.ComputeHash(), flagged[0].Hash)
+
+	corrected, err := exp.SaveCorrection(flagged[0], Correction{Year: "2019", Manufacturer: "Santa Cruz", Model: "Nomad"})
+	require.NoError(t, err)
+	assert.Empty(t, corrected.NeedsReview)
+	assert.NotEqual(t, flagged[0].Hash, corrected.Hash)
+
+	var metadata string
+	var confidence float64
+	require.NoError(t, exp.db.QueryRow("SELECT field_metadata, confidence FROM listings WHERE hash = ?", corrected.Hash).Scan(&metadata, &confidence))
+	assert.Contains(t, metadata, `"manufacturer":{"source":"correction","confidence":1}`)
+	assert.Greater(t, confidence, 0.8)
+
+	flagged, err = exp.ListingsNeedingReview(0)
+	require.NoError(t, err)
+	assert.Empty(t, flagged)
+
+	var historyHash string
+	require.NoError(t, exp.db.QueryRow("SELECT listing_hash FROM price_history").Scan(&historyHash))
+	assert.Equal(t, corrected.Hash, historyHash)
+
+	// the next scrape parses the title the same way but lands on the corrected listing
+	require.NoError(t, exp.Export([]listing.Listing{parsed}))
+	var count int
+	require.NoError(t, exp.db.QueryRow("SELECT COUNT(*) FROM listings").Scan(&count))
+	assert.Equal(t, 1, count)
+}
+
+func TestAliasesRoundTrip(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+
+	require.NoError(t, exp.AddManufacturerAlias("SC", "Santa Cruz"))
+	require.NoError(t, exp.AddModelAlias("Santa Cruz", "Tallboy LT", "Tallboy"))
+
+	aliases, err := exp.Aliases()
+	require.NoError(t, err)
+	assert.Equal(t, "Santa Cruz", aliases.Manufacturer("2018 SC Tallboy LT"))
+	assert.Equal(t, "Tallboy", aliases.Model("Santa Cruz", "2018 SC Tallboy LT"))
+}
+
+func TestUnmatchedModelTitles(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+
+	require.NoError(t, exp.Export([]listing.Listing{
+		{Title: "2024 Giant Trance Elite E", Manufacturer: "Giant", Model: "NoModelFound", Price: "3500"},
+		{Title: "2024 Giant Reign", Manufacturer: "Giant", Model: "Reign", Price: "3000"},
+		{Title: "2024 Unknown Bike", Manufacturer: "NoManufacturer", Model: "NoModelFound", Price: "1000"},
+	}))
+
+	titles, err := exp.UnmatchedModelTitles(time.Now().AddDate(0, 0, -7))
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"Giant": {"2024 Giant Trance Elite E"}}, titles)
+
+	titles, err = exp.UnmatchedModelTitles(time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, titles)
+}
@@ -0,0 +1,163 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"pinkbike-scraper/pkg/events"
+	"pinkbike-scraper/pkg/listing"
+)
+
+// Count adds a listing lifecycle event to the run's counts
+func (r *Run) Count(e events.Event) {
+	switch e.Kind {
+	case events.ListingDiscovered:
+		r.New++
+	case events.PriceChanged:
+		r.Updated++
+		old, errOld := strconv.ParseFloat(e.OldPrice, 64)
+		price, errNew := strconv.ParseFloat(e.Listing.Price, 64)
+		if errOld == nil && errNew == nil && price < old {
+			r.PriceDrops++
+		}
+	case events.ListingSold:
+		r.Sold++
+	}
+}
+
+// CountReviews sets the run's parse failures from the review reasons of the
+// listings it parsed
+func (r *Run) CountReviews(listings []listing.Listing) {
+	r.ParseFailures, r.Unparsed = nil, 0
+	for _, l := range listings {
+		if len(l.NeedsReview) == 0 {
+			continue
+		}
+		r.Unparsed++
+		if r.ParseFailures == nil {
+			r.ParseFailures = map[string]int{}
+		}
+		for _, reason := range l.NeedsReview {
+			r.ParseFailures[reason]++
+		}
+	}
+}
+
+// RunThresholds are the limits past which a run counts as failed, so a
+// scheduler notices a layout change or a broken export. Zero limits are not
+// checked.
+type RunThresholds struct {
+	// MinListings fails runs that parsed fewer listings
+	MinListings int
+	// MaxUnparsedRate fails runs where a larger share of the listings has a
+	// review reason, such as a model that could not be parsed
+	MaxUnparsedRate float64
+	// MaxScrapeFailures fails runs with more fields that could not be read
+	MaxScrapeFailures int
+	// ExportFailures fails runs with an export mode that failed
+	ExportFailures bool
+}
+
+// Exceeded describes each threshold r crossed, none when it passed
+func (t RunThresholds) Exceeded(r Run) []string {
+	var exceeded []string
+	if t.MinListings > 0 && r.Listings < t.MinListings {
+		exceeded = append(exceeded, fmt.Sprintf("parsed %d listings, fewer than %d", r.Listings, t.MinListings))
+	}
+	if t.MaxUnparsedRate > 0 && r.Listings > 0 {
+		if rate := float64(r.Unparsed) / float64(r.Listings); rate > t.MaxUnparsedRate {
+			exceeded = append(exceeded, fmt.Sprintf("%.0f%% of listings need review, more than %.0f%%", rate*100, t.MaxUnparsedRate*100))
+		}
+	}
+	if t.MaxScrapeFailures > 0 {
+		if n := total(r.ScrapeFailures); n > t.MaxScrapeFailures {
+			exceeded = append(exceeded, fmt.Sprintf("%d fields could not be scraped, more than %d", n, t.MaxScrapeFailures))
+		}
+	}
+	if t.ExportFailures {
+		for _, o := range r.Exports {
+			if o.Err != "" {
+				exceeded = append(exceeded, fmt.Sprintf("export %s failed", o.Mode))
+			}
+		}
+	}
+	return exceeded
+}
+
+// WriteSummary prints what the run did, one aspect per line
+func (r Run) WriteSummary(w io.Writer) error {
+	duration := "did not finish"
+	if !r.FinishedAt.IsZero() {
+		duration = "took " + r.FinishedAt.Sub(r.StartedAt).Round(time.Second).String()
+	}
+	lines := [][2]string{
+		{"Pages scraped", fmt.Sprint(r.Pages)},
+		{"Listings parsed", fmt.Sprintf("%d (%d new, %d updated, %d price drops, %d sold)", r.Listings, r.New, r.Updated, r.PriceDrops, r.Sold)},
+		{"Scrape failures", counts(r.ScrapeFailures)},
+		{"Parse failures", counts(r.ParseFailures)},
+	}
+	if len(r.ParseFailures) > 0 {
+		lines[3][1] = fmt.Sprintf("%d listings need review (%s)", r.Unparsed, counts(r.ParseFailures))
+	}
+	if len(r.Exports) > 0 {
+		var exports []string
+		for _, o := range r.Exports {
+			if o.Err != "" {
+				exports = append(exports, fmt.Sprintf("%s failed: %s", o.Mode, o.Err))
+			} else {
+				exports = append(exports, fmt.Sprintf("%s %d", o.Mode, o.Listings))
+			}
+		}
+		lines = append(lines, [2]string{"Exports", strings.Join(exports, ", ")})
+	}
+	if len(r.Errors) > 0 {
+		lines = append(lines, [2]string{"Errors", fmt.Sprint(len(r.Errors))})
+	}
+	for _, e := range r.Exceeded {
+		lines = append(lines, [2]string{"Threshold exceeded", e})
+	}
+
+	if _, err := fmt.Fprintf(w, "Run %d of %s listings from %s %s\n", r.ID, r.BikeType, r.InputMode, duration); err != nil {
+		return err
+	}
+	for _, l := range lines {
+		if _, err := fmt.Fprintf(w, "  %-19s %s\n", l[0]+":", l[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// counts lists counts by name, largest first, or "none"
+func counts(byName map[string]int) string {
+	if len(byName) == 0 {
+		return "none"
+	}
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if byName[names[i]] != byName[names[j]] {
+			return byName[names[i]] > byName[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s %d", name, byName[name])
+	}
+	return strings.Join(parts, ", ")
+}
+
+func total(byName map[string]int) int {
+	n := 0
+	for _, c := range byName {
+		n += c
+	}
+	return n
+}
@@ -0,0 +1,67 @@
+package exporter
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"pinkbike-scraper/pkg/events"
+	"pinkbike-scraper/pkg/listing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCounts(t *testing.T) {
+	var r Run
+	r.Count(events.Event{Kind: events.ListingDiscovered})
+	r.Count(events.Event{Kind: events.PriceChanged, OldPrice: "4000", Listing: listing.Listing{Price: "3500"}})
+	r.Count(events.Event{Kind: events.PriceChanged, OldPrice: "3000", Listing: listing.Listing{Price: "3200"}})
+	r.Count(events.Event{Kind: events.ListingSold})
+	assert.Equal(t, Run{New: 1, Updated: 2, PriceDrops: 1, Sold: 1}, r)
+
+	r.CountReviews([]listing.Listing{
+		{NeedsReview: listing.Reasons{"model", "year"}},
+		{NeedsReview: listing.Reasons{"model"}},
+		{},
+	})
+	assert.Equal(t, 2, r.Unparsed)
+	assert.Equal(t, map[string]int{"model": 2, "year": 1}, r.ParseFailures)
+}
+
+func TestRunThresholds(t *testing.T) {
+	r := Run{
+		Listings: 40, Unparsed: 10,
+		ScrapeFailures: map[string]int{"price": 4, "location": 3},
+		Exports:        []ExportOutcome{{Mode: "db", Listings: 40}, {Mode: "sheets", Err: "quota exceeded"}},
+	}
+	assert.Empty(t, RunThresholds{}.Exceeded(r), "zero thresholds are not checked")
+	assert.Equal(t, []string{
+		"parsed 40 listings, fewer than 50",
+		"25% of listings need review, more than 20%",
+		"7 fields could not be scraped, more than 5",
+		"export sheets failed",
+	}, RunThresholds{MinListings: 50, MaxUnparsedRate: 0.2, MaxScrapeFailures: 5, ExportFailures: true}.Exceeded(r))
+	assert.Empty(t, RunThresholds{MinListings: 40, MaxUnparsedRate: 0.25, MaxScrapeFailures: 7}.Exceeded(r))
+}
+
+func TestRunWriteSummary(t *testing.T) {
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	r := Run{
+		ID: 12, StartedAt: start, FinishedAt: start.Add(192 * time.Second), BikeType: "enduro", InputMode: "web",
+		Pages: 5, Listings: 120, New: 8, Updated: 5, PriceDrops: 3, Sold: 4, Unparsed: 7,
+		ParseFailures: map[string]int{"model": 6, "year": 2},
+		Exports:       []ExportOutcome{{Mode: "db", Listings: 120}, {Mode: "sheets", Err: "quota exceeded"}},
+		Exceeded:      []string{"export sheets failed"},
+	}
+	var out bytes.Buffer
+	require.NoError(t, r.WriteSummary(&out))
+	assert.Equal(t, `Run 12 of enduro listings from web took 3m12s
+  Pages scraped:      5
+  Listings parsed:    120 (8 new, 5 updated, 3 price drops, 4 sold)
+  Scrape failures:    none
+  Parse failures:     7 listings need review (model 6, year 2)
+  Exports:            db 120, sheets failed: quota exceeded
+  Threshold exceeded: export sheets failed
+`, out.String())
+}
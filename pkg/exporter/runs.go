@@ -0,0 +1,180 @@
+package exporter
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Run is the audit record of one scrape
+type Run struct {
+	ID        int64
+	StartedAt time.Time
+	// FinishedAt is zero while the run is in progress, or if it crashed
+	FinishedAt time.Time
+	BikeType   string
+	InputMode  string
+	Pages      int
+	Listings   int
+	// New and Updated count listings first seen and listings whose price changed
+	New, Updated int
+	// PriceDrops counts the price changes that lowered a price, and Sold the
+	// listings that dropped out of the results
+	PriceDrops, Sold int
+	// ScrapeFailures counts the fields that could not be read from a page,
+	// and ParseFailures the review reasons of the listings parsed, such as
+	// "year" for listings without one. Unparsed is how many listings have a
+	// review reason.
+	ScrapeFailures, ParseFailures map[string]int
+	Unparsed                      int
+	// Exports is how each export mode fared
+	Exports []ExportOutcome
+	Errors  []string
+	// Exceeded are the thresholds the run crossed, which failed it
+	Exceeded []string
+	// Blocked marks runs stopped by Pinkbike's bot protection
+	Blocked bool
+}
+
+// ExportOutcome is how an export mode fared in a run
+type ExportOutcome struct {
+	Mode     string `json:"mode"`
+	Listings int    `json:"listings"`
+	// Err is why the export failed, empty when it succeeded
+	Err string `json:"error,omitempty"`
+}
+
+// StartRun records that a run began and returns its ID, so runs that never
+// finish still show up in the history
+func (e *DBExporter) StartRun(r Run) (int64, error) {
+	res, err := e.db.Exec(`
+        INSERT INTO runs (started_at, bike_type, input_mode)
+        VALUES (?, ?, ?)
+    `, r.StartedAt.UTC().Format(sqliteTimeFormat), r.BikeType, r.InputMode)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record run: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to record run: %w", err)
+	}
+	return id, nil
+}
+
+// FinishRun stores the outcome of the run started with StartRun
+func (e *DBExporter) FinishRun(r Run) error {
+	errs, err := json.Marshal(r.Errors)
+	if err != nil {
+		return fmt.Errorf("failed to encode run errors: %w", err)
+	}
+	var summary [4]sql.NullString
+	fields := []struct {
+		value interface{}
+		n     int
+	}{
+		{r.ScrapeFailures, len(r.ScrapeFailures)},
+		{r.ParseFailures, len(r.ParseFailures)},
+		{r.Exports, len(r.Exports)},
+		{r.Exceeded, len(r.Exceeded)},
+	}
+	for i, f := range fields {
+		// empty fields are stored as NULL
+		if f.n == 0 {
+			continue
+		}
+		data, err := json.Marshal(f.value)
+		if err != nil {
+			return fmt.Errorf("failed to encode run summary: %w", err)
+		}
+		summary[i] = sql.NullString{String: string(data), Valid: true}
+	}
+
+	_, err = e.db.Exec(`
+        UPDATE runs SET
+            finished_at = ?, pages = ?, listings = ?, new_listings = ?,
+            updated_listings = ?, error_count = ?, errors = ?, blocked = ?,
+            price_drops = ?, sold_listings = ?, unparsed_listings = ?,
+            scrape_failures = ?, parse_failures = ?, exports = ?, exceeded = ?
+        WHERE id = ?
+    `, r.FinishedAt.UTC().Format(sqliteTimeFormat), r.Pages, r.Listings, r.New,
+		r.Updated, len(r.Errors), string(errs), r.Blocked,
+		r.PriceDrops, r.Sold, r.Unparsed,
+		summary[0], summary[1], summary[2], summary[3], r.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record run: %w", err)
+	}
+	return nil
+}
+
+// RecentRuns returns the latest runs, newest first
+func (e *DBExporter) RecentRuns(limit int) ([]Run, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := e.db.Query(`
+        SELECT id, started_at, finished_at, bike_type, input_mode, pages,
+               listings, new_listings, updated_listings, errors, blocked,
+               COALESCE(price_drops, 0), COALESCE(sold_listings, 0), COALESCE(unparsed_listings, 0),
+               scrape_failures, parse_failures, exports, exceeded
+        FROM runs
+        ORDER BY started_at DESC, id DESC
+        LIMIT ?
+    `, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		var (
+			r                 Run
+			started, finished interface{}
+			errs              sql.NullString
+			summary           [4]sql.NullString
+		)
+		if err := rows.Scan(&r.ID, &started, &finished, &r.BikeType, &r.InputMode, &r.Pages,
+			&r.Listings, &r.New, &r.Updated, &errs, &r.Blocked,
+			&r.PriceDrops, &r.Sold, &r.Unparsed, &summary[0], &summary[1], &summary[2], &summary[3]); err != nil {
+			return nil, fmt.Errorf("failed to scan run: %w", err)
+		}
+		if r.StartedAt, err = parseSQLiteTime(started); err != nil {
+			return nil, err
+		}
+		if r.FinishedAt, err = parseSQLiteTime(finished); err != nil {
+			return nil, err
+		}
+		if errs.Valid && errs.String != "" {
+			if err := json.Unmarshal([]byte(errs.String), &r.Errors); err != nil {
+				return nil, fmt.Errorf("failed to decode run errors: %w", err)
+			}
+		}
+		for i, v := range []interface{}{&r.ScrapeFailures, &r.ParseFailures, &r.Exports, &r.Exceeded} {
+			if !summary[i].Valid {
+				continue
+			}
+			if err := json.Unmarshal([]byte(summary[i].String), v); err != nil {
+				return nil, fmt.Errorf("failed to decode run summary: %w", err)
+			}
+		}
+		runs = append(runs, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query runs: %w", err)
+	}
+	return runs, nil
+}
+
+// LastBlocked returns when the latest run stopped by bot protection ended, or
+// the zero time when no run was blocked
+func (e *DBExporter) LastBlocked() (time.Time, error) {
+	var finished interface{}
+	err := e.db.QueryRow("SELECT MAX(finished_at) FROM runs WHERE blocked = 1").Scan(&finished)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to query blocked runs: %w", err)
+	}
+	return parseSQLiteTime(finished)
+}
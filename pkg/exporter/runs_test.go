@@ -0,0 +1,63 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunHistory(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	crashed := Run{StartedAt: start, BikeType: "enduro", InputMode: "web"}
+	_, err := exp.StartRun(crashed)
+	require.NoError(t, err)
+
+	finished := Run{StartedAt: start.Add(time.Hour), BikeType: "trail", InputMode: "file"}
+	finished.ID, err = exp.StartRun(finished)
+	require.NoError(t, err)
+	finished.FinishedAt = finished.StartedAt.Add(2 * time.Minute)
+	finished.Pages, finished.Listings, finished.New, finished.Updated = 5, 100, 12, 3
+	finished.Errors = []string{"export error: quota"}
+	finished.PriceDrops, finished.Sold, finished.Unparsed = 2, 4, 7
+	finished.ScrapeFailures = map[string]int{"location": 3}
+	finished.ParseFailures = map[string]int{"model": 6, "year": 2}
+	finished.Exports = []ExportOutcome{{Mode: "db", Listings: 100}, {Mode: "sheets", Err: "quota"}}
+	finished.Exceeded = []string{"export sheets failed"}
+	require.NoError(t, exp.FinishRun(finished))
+
+	runs, err := exp.RecentRuns(10)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, finished, runs[0])
+	assert.True(t, runs[1].FinishedAt.IsZero(), "runs that never finished have no end time")
+	assert.Equal(t, "enduro", runs[1].BikeType)
+}
+
+func TestLastBlocked(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	last, err := exp.LastBlocked()
+	require.NoError(t, err)
+	assert.True(t, last.IsZero())
+
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	for i, blocked := range []bool{true, false} {
+		r := Run{StartedAt: start.Add(time.Duration(i) * time.Hour), BikeType: "enduro", InputMode: "web"}
+		r.ID, err = exp.StartRun(r)
+		require.NoError(t, err)
+		r.FinishedAt, r.Blocked = r.StartedAt.Add(time.Minute), blocked
+		require.NoError(t, exp.FinishRun(r))
+	}
+
+	last, err = exp.LastBlocked()
+	require.NoError(t, err)
+	assert.Equal(t, start.Add(time.Minute), last.UTC())
+
+	runs, err := exp.RecentRuns(0)
+	require.NoError(t, err)
+	assert.False(t, runs[0].Blocked)
+	assert.True(t, runs[1].Blocked)
+}
@@ -0,0 +1,118 @@
+package exporter
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"pinkbike-scraper/pkg/notify"
+)
+
+// SaveSearch stores a saved search, replacing the owner's search of the same name
+func (e *DBExporter) SaveSearch(s notify.SavedSearch) error {
+	criteria, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode saved search: %w", err)
+	}
+
+	_, err = e.db.Exec(`
+        INSERT INTO saved_searches (owner, name, criteria)
+        VALUES (?, ?, ?)
+        ON CONFLICT(owner, name) DO UPDATE SET criteria = excluded.criteria
+    `, s.Owner, s.Name, string(criteria))
+	if err != nil {
+		return fmt.Errorf("failed to save search: %w", err)
+	}
+	return nil
+}
+
+// RemoveSearch deletes an owner's saved search, reporting whether it existed
+func (e *DBExporter) RemoveSearch(owner, name string) (bool, error) {
+	res, err := e.db.Exec("DELETE FROM saved_searches WHERE owner = ? AND name = ?", owner, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove saved search: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to remove saved search: %w", err)
+	}
+	return n > 0, nil
+}
+
+// SavedSearches returns the saved searches of owner, or of every owner when owner is empty
+func (e *DBExporter) SavedSearches(owner string) ([]notify.SavedSearch, error) {
+	var (
+		rows *sql.Rows
+		err  error
+	)
+	if owner == "" {
+		rows, err = e.db.Query("SELECT criteria FROM saved_searches ORDER BY id")
+	} else {
+		rows, err = e.db.Query("SELECT criteria FROM saved_searches WHERE owner = ? ORDER BY id", owner)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load saved searches: %w", err)
+	}
+	defer rows.Close()
+
+	var searches []notify.SavedSearch
+	for rows.Next() {
+		var criteria string
+		if err := rows.Scan(&criteria); err != nil {
+			return nil, fmt.Errorf("failed to load saved searches: %w", err)
+		}
+		var s notify.SavedSearch
+		if err := json.Unmarshal([]byte(criteria), &s); err != nil {
+			return nil, fmt.Errorf("failed to decode saved search: %w", err)
+		}
+		searches = append(searches, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load saved searches: %w", err)
+	}
+	return searches, nil
+}
+
+// MedianPrice returns the median USD price of active listings of a
+// manufacturer's model, or of all its models when model is empty, and how many
+// listings it was computed from. A generation leaves out the model's other
+// generations, which sell for very different prices. Suspected scams and listings of frames and
+// parts are left out.
+func (e *DBExporter) MedianPrice(manufacturer, model, generation string) (float64, int, error) {
+	query := "SELECT price FROM listings WHERE active = 1 AND needs_review = '' AND manufacturer = ?" + completeBikes + e.notSuspected()
+	args := []interface{}{manufacturer}
+	if model != "" {
+		query += " AND model = ?"
+		args = append(args, model)
+	}
+	if generation != "" {
+		query += " AND generation = ?"
+		args = append(args, generation)
+	}
+
+	rows, err := e.db.Query(query, args...)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to query prices: %w", err)
+	}
+	defer rows.Close()
+
+	var prices []float64
+	for rows.Next() {
+		var price sql.NullString
+		if err := rows.Scan(&price); err != nil {
+			return 0, 0, fmt.Errorf("failed to scan price: %w", err)
+		}
+		if p, err := strconv.ParseFloat(price.String, 64); err == nil && p > 0 {
+			prices = append(prices, p)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, 0, fmt.Errorf("failed to query prices: %w", err)
+	}
+
+	if len(prices) == 0 {
+		return 0, 0, nil
+	}
+	return median(prices), len(prices), nil
+}
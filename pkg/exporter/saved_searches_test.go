@@ -0,0 +1,81 @@
+package exporter
+
+import (
+	"testing"
+
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/notify"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSavedSearches(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+
+	require.NoError(t, exp.SaveSearch(notify.SavedSearch{Name: "nomad", Owner: "42", Model: "Nomad"}))
+	require.NoError(t, exp.SaveSearch(notify.SavedSearch{Name: "nomad", Owner: "42", Model: "Nomad", MaxPrice: 3000}))
+	require.NoError(t, exp.SaveSearch(notify.SavedSearch{Name: "dh", Owner: "7", Keywords: []string{"dh"}}))
+
+	searches, err := exp.SavedSearches("42")
+	require.NoError(t, err)
+	require.Len(t, searches, 1)
+	assert.Equal(t, 3000.0, searches[0].MaxPrice)
+
+	all, err := exp.SavedSearches("")
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	removed, err := exp.RemoveSearch("42", "nomad")
+	require.NoError(t, err)
+	assert.True(t, removed)
+	removed, err = exp.RemoveSearch("42", "nomad")
+	require.NoError(t, err)
+	assert.False(t, removed)
+}
+
+func TestMedianPrice(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+
+	require.NoError(t, exp.Export([]listing.Listing{
+		{Title: "2019 Santa Cruz Nomad A", Manufacturer: "Santa Cruz", Model: "Nomad", Price: "3000"},
+		{Title: "2019 Santa Cruz Nomad B", Manufacturer: "Santa Cruz", Model: "Nomad", Price: "3500"},
+		{Title: "2020 Santa Cruz Hightower", Manufacturer: "Santa Cruz", Model: "Hightower", Price: "2000"},
+		{Title: "2019 Santa Cruz Nomad C", Manufacturer: "Santa Cruz", Model: "Nomad", Price: "100", NeedsReview: listing.Reasons{"year"}},
+	}))
+
+	price, count, err := exp.MedianPrice("Santa Cruz", "Nomad", "")
+	require.NoError(t, err)
+	assert.Equal(t, 3250.0, price)
+	assert.Equal(t, 2, count)
+
+	price, count, err = exp.MedianPrice("Santa Cruz", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, 3000.0, price)
+	assert.Equal(t, 3, count)
+}
+
+func TestMedianPriceByGeneration(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+
+	require.NoError(t, exp.Export([]listing.Listing{
+		{Title: "2019 Santa Cruz Megatower 1 A", Manufacturer: "Santa Cruz", Model: "Megatower", Generation: "V1", Price: "2500"},
+		{Title: "2020 Santa Cruz Megatower 1 B", Manufacturer: "Santa Cruz", Model: "Megatower", Generation: "V1", Price: "2700"},
+		{Title: "2023 Santa Cruz Megatower 2", Manufacturer: "Santa Cruz", Model: "Megatower", Generation: "V2", Price: "5500"},
+	}))
+
+	price, count, err := exp.MedianPrice("Santa Cruz", "Megatower", "V1")
+	require.NoError(t, err)
+	assert.Equal(t, 2600.0, price)
+	assert.Equal(t, 2, count)
+
+	price, count, err = exp.MedianPrice("Santa Cruz", "Megatower", "")
+	require.NoError(t, err)
+	assert.Equal(t, 2700.0, price, "without a generation every generation counts")
+	assert.Equal(t, 3, count)
+
+	listings, err := exp.IndexListings()
+	require.NoError(t, err)
+	require.Len(t, listings, 3)
+	assert.Equal(t, "V2", listings[2].Generation)
+}
@@ -0,0 +1,105 @@
+package exporter
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ScanError describes a stored row that could not be read back: which listing
+// it was, which column held the bad value and what that value was
+type ScanError struct {
+	Hash   string
+	Column string
+	Raw    interface{}
+	Err    error
+}
+
+func (e *ScanError) Error() string {
+	raw := e.Raw
+	if b, ok := raw.([]byte); ok {
+		raw = string(b)
+	}
+	return fmt.Sprintf("listing %s: column %s has unreadable value %#v: %v", e.Hash, e.Column, raw, e.Err)
+}
+
+func (e *ScanError) Unwrap() error {
+	return e.Err
+}
+
+// rowScanner reads each column of a row separately so a value that does not
+// fit its destination can be traced to its column and listing
+type rowScanner struct {
+	columns []string
+	raw     []interface{}
+	ptrs    []interface{}
+}
+
+func newRowScanner(rows *sql.Rows) (*rowScanner, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns: %w", err)
+	}
+	s := &rowScanner{columns: columns, raw: make([]interface{}, len(columns)), ptrs: make([]interface{}, len(columns))}
+	for i := range s.raw {
+		s.ptrs[i] = &s.raw[i]
+	}
+	return s, nil
+}
+
+// scan reads the current row into dest, one destination per column in order
+func (s *rowScanner) scan(rows *sql.Rows, dest ...sql.Scanner) error {
+	if len(dest) != len(s.columns) {
+		return fmt.Errorf("failed to scan row: %d destinations for %d columns", len(dest), len(s.columns))
+	}
+	if err := rows.Scan(s.ptrs...); err != nil {
+		return fmt.Errorf("failed to scan row: %w", err)
+	}
+	for i, d := range dest {
+		if err := d.Scan(s.raw[i]); err != nil {
+			return s.errorAt(s.columns[i], err)
+		}
+	}
+	return nil
+}
+
+// errorAt reports a bad value in column of the current row, which may also be
+// one that only failed to decode after scanning, such as malformed JSON
+func (s *rowScanner) errorAt(column string, err error) *ScanError {
+	scanErr := &ScanError{Column: column, Err: err}
+	for i, c := range s.columns {
+		if c == column {
+			scanErr.Raw = s.raw[i]
+		}
+		if c == "hash" {
+			switch h := s.raw[i].(type) {
+			case []byte:
+				scanErr.Hash = string(h)
+			case string:
+				scanErr.Hash = h
+			}
+		}
+	}
+	return scanErr
+}
+
+// skipRow reports whether a row that failed with err should be left out of
+// the results rather than failing the query, recording it if so
+func (e *DBExporter) skipRow(err error) bool {
+	var scanErr *ScanError
+	if !e.skipBadRows || !errors.As(err, &scanErr) {
+		return false
+	}
+	e.mu.Lock()
+	e.skipped = append(e.skipped, *scanErr)
+	e.mu.Unlock()
+	return true
+}
+
+// SkippedRows returns the rows left out of query results because they could
+// not be read, when DBOptions.SkipBadRows is set
+func (e *DBExporter) SkippedRows() []ScanError {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]ScanError(nil), e.skipped...)
+}
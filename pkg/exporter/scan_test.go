@@ -0,0 +1,41 @@
+package exporter
+
+import (
+	"errors"
+	"testing"
+
+	"pinkbike-scraper/pkg/listing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanErrorNamesRowAndColumn(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+
+	require.NoError(t, exp.Export([]listing.Listing{
+		{Title: "2021 Evil Wreckoning", Price: "3900", Details: listing.ListingDetails{Description: "Coil shock"}},
+		{Title: "2020 Kona Process 153", Price: "2200", Details: listing.ListingDetails{Description: "Coil shock"}},
+	}))
+	bad := listing.Listing{Title: "2021 Evil Wreckoning"}.ComputeHash()
+	_, err := exp.db.Exec("UPDATE listings SET active = 'sometimes' WHERE hash = ?", bad)
+	require.NoError(t, err)
+
+	_, err = exp.SearchListings("coil", "", 10)
+	var scanErr *ScanError
+	require.True(t, errors.As(err, &scanErr))
+	assert.Equal(t, bad, scanErr.Hash)
+	assert.Equal(t, "active", scanErr.Column)
+	assert.Equal(t, "sometimes", scanErr.Raw)
+	assert.Contains(t, err.Error(), `column active has unreadable value "sometimes"`)
+
+	exp.skipBadRows = true
+	results, err := exp.SearchListings("coil", "", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "2020 Kona Process 153", results[0].Title)
+
+	skipped := exp.SkippedRows()
+	require.Len(t, skipped, 1)
+	assert.Equal(t, bad, skipped[0].Hash)
+}
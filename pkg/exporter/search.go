@@ -0,0 +1,150 @@
+package exporter
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"pinkbike-scraper/pkg/geo"
+	"pinkbike-scraper/pkg/listing"
+)
+
+// initializeSearch creates the full-text index over listing titles and
+// descriptions and fills it from existing listings the first time. FTS5 is
+// only compiled into go-sqlite3 with the sqlite_fts5 build tag; without it
+// search falls back to LIKE matching and false is returned.
+func initializeSearch(db *sql.DB) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE name = 'listings_fts')").Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check search index: %w", err)
+	}
+	if exists {
+		return true, nil
+	}
+
+	_, err = db.Exec(`
+        CREATE VIRTUAL TABLE listings_fts USING fts5(
+            hash UNINDEXED,
+            title,
+            description
+        )
+    `)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such module") {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to create search index: %w", err)
+	}
+
+	_, err = db.Exec(`
+        INSERT INTO listings_fts (hash, title, description)
+        SELECT hash, title, COALESCE(decompress(description), '') FROM listings
+    `)
+	if err != nil {
+		return false, fmt.Errorf("failed to fill search index: %w", err)
+	}
+	return true, nil
+}
+
+// indexListing replaces the search index entry for a listing
+func (e *DBExporter) indexListing(tx *sql.Tx, hash string) error {
+	if !e.fts {
+		return nil
+	}
+
+	if _, err := tx.Exec("DELETE FROM listings_fts WHERE hash = ?", hash); err != nil {
+		return fmt.Errorf("failed to update search index: %w", err)
+	}
+	_, err := tx.Exec(`
+        INSERT INTO listings_fts (hash, title, description)
+        SELECT hash, title, COALESCE(decompress(description), '') FROM listings WHERE hash = ?
+    `, hash)
+	if err != nil {
+		return fmt.Errorf("failed to update search index: %w", err)
+	}
+	return nil
+}
+
+// SearchListings returns listings whose title or description match query,
+// best matches first. With FTS5 the query uses its syntax ("coil shock",
+// "warranty OR receipt", "\"coil shock\""); otherwise every word must appear.
+// A non-empty category limits results to listings scraped under that bike type.
+func (e *DBExporter) SearchListings(query, category string, limit int) ([]listing.Listing, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("empty search query")
+	}
+	if limit <= 0 {
+		limit = -1
+	}
+
+	var (
+		rows *sql.Rows
+		err  error
+	)
+	if e.fts {
+		rows, err = e.db.Query(`
+            SELECT l.hash, l.title, l.year, l.manufacturer, l.model, l.price, l.currency, l.url, l.active, l.category,
+                l.location, l.latitude, l.longitude
+            FROM listings_fts f
+            JOIN listings l ON l.hash = f.hash
+            WHERE listings_fts MATCH ? AND (? = '' OR l.category = ?)
+            ORDER BY f.rank
+            LIMIT ?
+        `, query, category, category, limit)
+	} else {
+		where := []string{}
+		args := []interface{}{}
+		for _, word := range strings.Fields(query) {
+			where = append(where, "(l.title LIKE ? OR decompress(l.description) LIKE ?)")
+			pattern := "%" + word + "%"
+			args = append(args, pattern, pattern)
+		}
+		where = append(where, "(? = '' OR l.category = ?)")
+		args = append(args, category, category, limit)
+		rows, err = e.db.Query(`
+            SELECT l.hash, l.title, l.year, l.manufacturer, l.model, l.price, l.currency, l.url, l.active, l.category,
+                l.location, l.latitude, l.longitude
+            FROM listings l
+            WHERE `+strings.Join(where, " AND ")+`
+            ORDER BY l.last_seen DESC
+            LIMIT ?
+        `, args...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to search listings: %w", err)
+	}
+	defer rows.Close()
+
+	scanner, err := newRowScanner(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []listing.Listing
+	for rows.Next() {
+		var (
+			hash, title, year, manufacturer, model, price, currency, url, category sql.NullString
+			location                                                               sql.NullString
+			active                                                                 sql.NullBool
+			latitude, longitude                                                    sql.NullFloat64
+		)
+		if err := scanner.scan(rows, &hash, &title, &year, &manufacturer, &model, &price, &currency, &url, &active, &category,
+			&location, &latitude, &longitude); err != nil {
+			if e.skipRow(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		l := listing.Listing{Hash: hash.String, Title: title.String, URL: url.String, Active: active.Bool, Category: category.String}
+		l.Year, l.Manufacturer, l.Model = year.String, manufacturer.String, model.String
+		l.Price, l.Currency = price.String, currency.String
+		l.Location, l.Coordinates = location.String, geo.Point{Lat: latitude.Float64, Lon: longitude.Float64}
+		results = append(results, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search listings: %w", err)
+	}
+	return results, nil
+}
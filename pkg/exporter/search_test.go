@@ -0,0 +1,41 @@
+package exporter
+
+import (
+	"testing"
+
+	"pinkbike-scraper/pkg/listing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchListings(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+
+	require.NoError(t, exp.Export([]listing.Listing{
+		{Title: "2021 Evil Wreckoning", Price: "3900", Details: listing.ListingDetails{Description: "Coil shock, new bearings"}},
+		{Title: "2020 Santa Cruz Megatower", Price: "4200", Category: "enduro", Details: listing.ListingDetails{Description: "Air shock, warranty until 2025"}},
+	}))
+
+	results, err := exp.SearchListings("coil shock", "", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "2021 Evil Wreckoning", results[0].Title)
+
+	results, err = exp.SearchListings("warranty", "", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "4200", results[0].Price)
+
+	results, err = exp.SearchListings("megatower", "", 10)
+	require.NoError(t, err)
+	assert.Len(t, results, 1)
+
+	results, err = exp.SearchListings("shock", "enduro", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "enduro", results[0].Category)
+
+	_, err = exp.SearchListings(" ", "", 10)
+	assert.Error(t, err)
+}
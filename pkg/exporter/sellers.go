@@ -0,0 +1,167 @@
+package exporter
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pinkbike-scraper/pkg/listing"
+)
+
+// DefaultBusinessThreshold is how many active listings a private seller needs
+// to be flagged as a likely business
+const DefaultBusinessThreshold = 5
+
+// Seller is a Pinkbike account with listings in the database
+type Seller struct {
+	Username   string
+	ProfileURL string
+	// SellerType is the type given on the seller's latest listing
+	SellerType listing.SellerType
+	// ActiveListings and TotalListings count the seller's listings in the
+	// database that are still for sale and ever seen
+	ActiveListings, TotalListings int
+	FirstSeen, LastSeen           time.Time
+}
+
+// LikelyBusiness reports whether the seller lists as private but has at least
+// threshold listings for sale at once, which usually means an unregistered
+// shop or flipper
+func (s Seller) LikelyBusiness(threshold int) bool {
+	return s.SellerType == listing.Private && threshold > 0 && s.ActiveListings >= threshold
+}
+
+// SellerCount is a seller's listing counts on one day
+type SellerCount struct {
+	Date                          time.Time
+	ActiveListings, TotalListings int
+}
+
+// recordSellers updates the sellers of the stored listings and records their
+// listing counts for the day. Listings exported without details are matched
+// to the seller stored with them.
+func (e *DBExporter) recordSellers(tx *sql.Tx, stored []listing.Listing) error {
+	profiles := map[string]string{}
+	var sellers []string
+	for _, l := range stored {
+		seller := l.Details.Seller
+		if seller == "" {
+			var known sql.NullString
+			err := tx.QueryRow("SELECT seller FROM listings WHERE hash = ?", l.Hash).Scan(&known)
+			if err != nil && err != sql.ErrNoRows {
+				return fmt.Errorf("failed to look up seller: %w", err)
+			}
+			seller = known.String
+		}
+		if seller == "" {
+			continue
+		}
+		if _, seen := profiles[seller]; !seen {
+			sellers = append(sellers, seller)
+			profiles[seller] = ""
+		}
+		if l.Details.SellerURL != "" {
+			profiles[seller] = l.Details.SellerURL
+		}
+	}
+
+	now := e.clock.Now().UTC()
+	for _, seller := range sellers {
+		if _, err := tx.Exec(`
+            INSERT INTO sellers (username, profile_url, seller_type, first_seen, last_seen)
+            VALUES (?1, ?2, (SELECT seller_type FROM listings WHERE seller = ?1 ORDER BY last_seen DESC LIMIT 1), ?3, ?3)
+            ON CONFLICT(username) DO UPDATE SET
+                profile_url = COALESCE(excluded.profile_url, profile_url),
+                seller_type = COALESCE(excluded.seller_type, seller_type),
+                last_seen = excluded.last_seen
+        `, seller, nullString(profiles[seller]), now.Format(sqliteTimeFormat)); err != nil {
+			return fmt.Errorf("failed to record seller %s: %w", seller, err)
+		}
+
+		if _, err := tx.Exec(`
+            INSERT INTO seller_listing_counts (username, recorded_on, active_listings, total_listings)
+            SELECT ?1, ?2, COALESCE(SUM(active), 0), COUNT(*) FROM listings WHERE seller = ?1
+            ON CONFLICT(username, recorded_on) DO UPDATE SET
+                active_listings = excluded.active_listings,
+                total_listings = excluded.total_listings
+        `, seller, now.Format("2006-01-02")); err != nil {
+			return fmt.Errorf("failed to record listing counts of seller %s: %w", seller, err)
+		}
+	}
+	return nil
+}
+
+// Sellers returns the sellers with at least minActive active listings, those
+// with the most active listings first
+func (e *DBExporter) Sellers(minActive int) ([]Seller, error) {
+	rows, err := e.db.Query(`
+        SELECT s.username, COALESCE(s.profile_url, ''), COALESCE(s.seller_type, ''),
+               COALESCE(SUM(l.active), 0), COUNT(l.id), s.first_seen, s.last_seen
+        FROM sellers s
+        LEFT JOIN listings l ON l.seller = s.username
+        GROUP BY s.username
+        HAVING COALESCE(SUM(l.active), 0) >= ?
+        ORDER BY COALESCE(SUM(l.active), 0) DESC, COUNT(l.id) DESC, s.username
+    `, minActive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sellers: %w", err)
+	}
+	defer rows.Close()
+
+	var sellers []Seller
+	for rows.Next() {
+		var (
+			s               Seller
+			sellerType      string
+			first, lastSeen interface{}
+		)
+		if err := rows.Scan(&s.Username, &s.ProfileURL, &sellerType, &s.ActiveListings, &s.TotalListings, &first, &lastSeen); err != nil {
+			return nil, fmt.Errorf("failed to scan seller: %w", err)
+		}
+		s.SellerType = listing.SellerType(sellerType)
+		if s.FirstSeen, err = parseSQLiteTime(first); err != nil {
+			return nil, err
+		}
+		if s.LastSeen, err = parseSQLiteTime(lastSeen); err != nil {
+			return nil, err
+		}
+		sellers = append(sellers, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query sellers: %w", err)
+	}
+	return sellers, nil
+}
+
+// SellerHistory returns a seller's daily listing counts, oldest first
+func (e *DBExporter) SellerHistory(username string) ([]SellerCount, error) {
+	rows, err := e.db.Query(`
+        SELECT recorded_on, active_listings, total_listings
+        FROM seller_listing_counts
+        WHERE username = ?
+        ORDER BY recorded_on
+    `, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query seller history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []SellerCount
+	for rows.Next() {
+		var (
+			c    SellerCount
+			date interface{}
+		)
+		if err := rows.Scan(&date, &c.ActiveListings, &c.TotalListings); err != nil {
+			return nil, fmt.Errorf("failed to scan seller history: %w", err)
+		}
+		if c.Date, err = parseSQLiteTime(date); err != nil {
+			return nil, err
+		}
+		history = append(history, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query seller history: %w", err)
+	}
+	return history, nil
+}
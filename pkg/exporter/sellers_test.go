@@ -0,0 +1,71 @@
+package exporter
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/listing"
+)
+
+func TestSellers(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+	day := time.Date(2024, 9, 5, 12, 0, 0, 0, time.UTC)
+	exp.clock = clock.Fixed(day)
+
+	sold := func(seller string, sellerType listing.SellerType, n int) []listing.Listing {
+		var listings []listing.Listing
+		for i := 0; i < n; i++ {
+			listings = append(listings, listing.Listing{
+				Title: fmt.Sprintf("2022 Transition Spire %s %d", seller, i), Price: "3000", Currency: "USD",
+			}.WithDetails(listing.ListingDetails{
+				Description: "Ridden twice", SellerType: sellerType,
+				Seller: seller, SellerURL: "https://www.pinkbike.com/u/" + seller + "/",
+			}))
+		}
+		return listings
+	}
+	flipper := sold("flipper", listing.Private, 5)
+	shop := sold("shop", listing.Business, 6)
+	rider := sold("rider", listing.Private, 1)
+	require.NoError(t, exp.Export(append(append(append([]listing.Listing{}, flipper...), shop...), rider...)))
+
+	sellers, err := exp.Sellers(1)
+	require.NoError(t, err)
+	require.Len(t, sellers, 3)
+	assert.Equal(t, "shop", sellers[0].Username)
+	assert.Equal(t, "flipper", sellers[1].Username)
+	assert.Equal(t, "https://www.pinkbike.com/u/flipper/", sellers[1].ProfileURL)
+	assert.Equal(t, 5, sellers[1].ActiveListings)
+
+	assert.True(t, sellers[1].LikelyBusiness(DefaultBusinessThreshold), "private seller with many listings")
+	assert.False(t, sellers[0].LikelyBusiness(DefaultBusinessThreshold), "registered business")
+	assert.False(t, sellers[2].LikelyBusiness(DefaultBusinessThreshold))
+	assert.False(t, sellers[1].LikelyBusiness(0), "a zero threshold disables the flag")
+
+	sellers, err = exp.Sellers(2)
+	require.NoError(t, err)
+	assert.Len(t, sellers, 2)
+
+	// the next day the flipper's listings are seen again without details
+	exp.clock = clock.Fixed(day.AddDate(0, 0, 1))
+	for i := range flipper {
+		flipper[i].Details = listing.ListingDetails{}
+	}
+	require.NoError(t, exp.Export(append(flipper[:3], sold("flipper", listing.Private, 7)[5:]...)))
+
+	history, err := exp.SellerHistory("flipper")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, "2024-09-05", history[0].Date.Format("2006-01-02"))
+	assert.Equal(t, 5, history[0].TotalListings)
+	assert.Equal(t, 7, history[1].TotalListings)
+
+	history, err = exp.SellerHistory("nobody")
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
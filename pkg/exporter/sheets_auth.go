@@ -0,0 +1,157 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+	"google.golang.org/api/sheets/v4"
+)
+
+var sheetsScopes = []string{sheets.SpreadsheetsScope, drive.DriveFileScope}
+
+// sheetsClientOption authenticates with either a service account key or an
+// OAuth client secret. OAuth tokens are cached in tokenFile so the browser
+// consent flow only runs once. Without authorize a missing token is an error
+// instead of starting the consent flow.
+func sheetsClientOption(ctx context.Context, credentialsFile, tokenFile string, authorize bool) (option.ClientOption, error) {
+	data, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read credentials file: %w", err)
+	}
+
+	var kind struct {
+		Type      string          `json:"type"`
+		Installed json.RawMessage `json:"installed"`
+		Web       json.RawMessage `json:"web"`
+	}
+	if err := json.Unmarshal(data, &kind); err != nil {
+		return nil, fmt.Errorf("could not parse credentials file: %w", err)
+	}
+
+	if kind.Installed == nil && kind.Web == nil {
+		return option.WithCredentialsJSON(data), nil
+	}
+
+	config, err := google.ConfigFromJSON(data, sheetsScopes...)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse OAuth client secret: %w", err)
+	}
+
+	token, err := loadToken(tokenFile)
+	if err != nil && !authorize {
+		return nil, fmt.Errorf("no cached OAuth token in %s: %w", tokenFile, err)
+	}
+	if err != nil {
+		token, err = authorizeUser(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		if err := saveToken(tokenFile, token); err != nil {
+			return nil, err
+		}
+	}
+
+	return option.WithTokenSource(config.TokenSource(ctx, token)), nil
+}
+
+func loadToken(path string) (*oauth2.Token, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &oauth2.Token{}
+	if err := json.Unmarshal(data, token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func saveToken(path string, token *oauth2.Token) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("could not cache OAuth token: %w", err)
+	}
+	return nil
+}
+
+// authorizeUser runs the installed-app consent flow, receiving the
+// authorization code on a loopback redirect
+func authorizeUser(ctx context.Context, config *oauth2.Config) (*oauth2.Token, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("could not listen for OAuth redirect: %w", err)
+	}
+	defer listener.Close()
+
+	config.RedirectURL = "http://" + listener.Addr().String()
+	state := fmt.Sprintf("pinkbike-%d", os.Getpid())
+
+	codes := make(chan string, 1)
+	errs := make(chan error, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("state") != state {
+			http.Error(w, "state mismatch", http.StatusBadRequest)
+			return
+		}
+		code := r.URL.Query().Get("code")
+		if code == "" {
+			errs <- errors.New("authorization was denied")
+			http.Error(w, "authorization was denied", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, "Authorization complete, you can close this window.")
+		codes <- code
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	fmt.Printf("Open the following link in your browser to authorize Google Sheets access:\n%s\n", config.AuthCodeURL(state, oauth2.AccessTypeOffline))
+
+	select {
+	case code := <-codes:
+		token, err := config.Exchange(ctx, code)
+		if err != nil {
+			return nil, fmt.Errorf("could not exchange authorization code: %w", err)
+		}
+		return token, nil
+	case err := <-errs:
+		return nil, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// CheckSheetsAccess verifies the credentials authenticate with Google and can
+// read the spreadsheet, when spreadsheetID is set. Unlike an export it never
+// starts the OAuth consent flow.
+func CheckSheetsAccess(credentialsFile, tokenFile, spreadsheetID string) error {
+	ctx := context.Background()
+	clientOption, err := sheetsClientOption(ctx, credentialsFile, tokenFile, false)
+	if err != nil {
+		return err
+	}
+	srv, err := sheets.NewService(ctx, clientOption)
+	if err != nil {
+		return fmt.Errorf("failed to create sheets service: %w", err)
+	}
+	if spreadsheetID == "" {
+		return nil
+	}
+	if _, err := srv.Spreadsheets.Get(spreadsheetID).Fields("spreadsheetId").Context(ctx).Do(); err != nil {
+		return fmt.Errorf("could not read spreadsheet %s: %w", spreadsheetID, err)
+	}
+	return nil
+}
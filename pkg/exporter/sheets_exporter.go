@@ -3,128 +3,703 @@ package exporter
 import (
 	"context"
 	"fmt"
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/geo"
 	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/parser"
+	"pinkbike-scraper/pkg/privacy"
+	"sort"
+	"strconv"
 
 	"google.golang.org/api/drive/v3"
 	"google.golang.org/api/option"
 	"google.golang.org/api/sheets/v4"
 )
 
+// SheetsOptions controls optional Google Sheets export behaviour
+type SheetsOptions struct {
+	// PerRunTabs additionally writes each run to a dated tab, refreshes a
+	// "Latest" tab and rebuilds a summary tab with per-model counts and medians
+	PerRunTabs bool
+	// ShareWith is the email address a newly created spreadsheet is shared with
+	// when no spreadsheet ID is configured
+	ShareWith string
+	// BatchSize is the maximum number of rows sent per append or update call
+	BatchSize int
+	// MaxRetries is how often a call is retried after a quota or server error
+	MaxRetries int
+	// TokenFile caches the OAuth token when the credentials file is an OAuth
+	// client secret rather than a service account key
+	TokenFile string
+	// Privacy suppresses small cohorts and adds noise to the summary and
+	// sizes tabs. When enabled only those are written, as the listings tabs
+	// publish each listing's exact price.
+	Privacy privacy.Options
+	// DailyRequestLimit is the number of Sheets API requests to budget per
+	// day. Near it the per-run tabs are deferred and rows are written in
+	// larger batches. Zero disables quota tracking.
+	DailyRequestLimit int
+	// QuotaFile stores the day's request count between runs
+	QuotaFile string
+	// Clock dates the per-run tab and the quota day, the system clock when nil
+	Clock clock.Clock
+	// Near is where the Distance column measures from, left empty when zero
+	Near geo.Point
+	// Combined writes every listing to the listings tab, relying on the Needs
+	// Review column to tell suspect listings apart, instead of writing
+	// listings needing review to a separate review tab
+	Combined bool
+}
+
 type SheetsExporter struct {
 	service       *sheets.Service
 	spreadsheetID string
+	sheetName     string
+	sheetID       int64
+	// reviewSheetID is the review tab's ID once it was looked up
+	reviewSheetID int64
+	opts          SheetsOptions
+	quota         *sheetsQuota
 }
 
-func NewSheetsExporter(credentialsFile, spreadsheetID string) (*SheetsExporter, error) {
+// NewSheetsExporter writes listings to the sheetName tab of the spreadsheet. When
+// spreadsheetID is empty a new spreadsheet is created and shared with opts.ShareWith.
+func NewSheetsExporter(credentialsFile, spreadsheetID, sheetName string, opts SheetsOptions) (*SheetsExporter, error) {
+	if opts.TokenFile == "" {
+		opts.TokenFile = "token.json"
+	}
+	if opts.QuotaFile == "" {
+		opts.QuotaFile = "sheets_quota.json"
+	}
+
 	ctx := context.Background()
-	srv, err := sheets.NewService(ctx, option.WithCredentialsFile(credentialsFile))
+	clientOption, err := sheetsClientOption(ctx, credentialsFile, opts.TokenFile, true)
+	if err != nil {
+		return nil, err
+	}
+
+	srv, err := sheets.NewService(ctx, clientOption)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create sheets service: %w", err)
 	}
 
-	return &SheetsExporter{
+	if spreadsheetID == "" {
+		if opts.ShareWith == "" {
+			return nil, fmt.Errorf("no spreadsheet ID configured and no email to share a new spreadsheet with")
+		}
+		spreadsheetID, err = createSheetAndShare(ctx, srv, "Pinkbike Listings", opts.ShareWith, clientOption)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultSheetsBatchSize
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = defaultSheetsMaxRetries
+	}
+
+	e := &SheetsExporter{
 		service:       srv,
 		spreadsheetID: spreadsheetID,
-	}, nil
+		sheetName:     sheetName,
+		opts:          opts,
+		quota:         loadSheetsQuota(opts.QuotaFile, opts.DailyRequestLimit, opts.Clock),
+	}
+
+	if e.sheetID, err = e.ensureSheet(sheetName); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// ensureSheet looks up a tab by title, creating it when missing, and returns its ID
+func (e *SheetsExporter) ensureSheet(title string) (int64, error) {
+	id, _, err := e.findOrAddSheet(title)
+	return id, err
+}
+
+// findOrAddSheet looks up a tab by title, creating it when missing, and
+// returns its ID and whether it was created
+func (e *SheetsExporter) findOrAddSheet(title string) (int64, bool, error) {
+	e.quota.record()
+	spreadsheet, err := e.service.Spreadsheets.Get(e.spreadsheetID).Fields("sheets.properties").Do()
+	if err != nil {
+		return 0, false, fmt.Errorf("Unable to get spreadsheet: %v", err)
+	}
+
+	for _, sheet := range spreadsheet.Sheets {
+		if sheet.Properties.Title == title {
+			return sheet.Properties.SheetId, false, nil
+		}
+	}
+
+	e.quota.record()
+	resp, err := e.service.Spreadsheets.BatchUpdate(e.spreadsheetID, &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{
+			{AddSheet: &sheets.AddSheetRequest{Properties: &sheets.SheetProperties{Title: title}}},
+		},
+	}).Do()
+	if err != nil {
+		return 0, false, fmt.Errorf("Unable to add sheet %s: %v", title, err)
+	}
+
+	return resp.Replies[0].AddSheet.Properties.SheetId, true, nil
 }
 
 func (e *SheetsExporter) Close() error {
 	return nil
 }
 
+// Export writes listings to the listings tab and, unless opts.Combined is
+// set, listings needing review to the review tab, which the per-run tabs
+// leave out too. A listing that moved between the two since it was last
+// exported is removed from the tab it left.
 func (e *SheetsExporter) Export(listings []listing.Listing) error {
-	if err := e.appendToSheet(listings); err != nil {
-		return fmt.Errorf("failed to export to sheets: %w", err)
+	if e.opts.Privacy.Enabled() {
+		if !e.opts.Combined {
+			listings, _ = splitSuspect(listings)
+		}
+		if e.quota.nearLimit() {
+			fmt.Printf("Sheets: deferring summary tabs to a later run, %s\n", e.quota)
+			return nil
+		}
+		if err := e.writeSummaryTabs(listings); err != nil {
+			return fmt.Errorf("failed to export summary tabs to sheets: %w", err)
+		}
+		return nil
+	}
+
+	if e.opts.Combined {
+		if err := e.exportTab(e.sheetName, e.sheetID, listings, nil); err != nil {
+			return fmt.Errorf("failed to export to sheets: %w", err)
+		}
+	} else {
+		good, suspect := splitSuspect(listings)
+		listings = good
+		if err := e.exportTab(e.sheetName, e.sheetID, good, suspect); err != nil {
+			return fmt.Errorf("failed to export to sheets: %w", err)
+		}
+		reviewID, err := e.ensureReviewSheet()
+		if err != nil {
+			return fmt.Errorf("failed to export to sheets: %w", err)
+		}
+		if err := e.exportTab(e.reviewTab(), reviewID, suspect, good); err != nil {
+			return fmt.Errorf("failed to export review listings to sheets: %w", err)
+		}
+	}
+
+	if e.opts.PerRunTabs && e.quota.nearLimit() {
+		fmt.Printf("Sheets: deferring per-run tabs to a later run, %s\n", e.quota)
+	} else if e.opts.PerRunTabs {
+		if err := e.writeRunTabs(listings); err != nil {
+			return fmt.Errorf("failed to export run tabs to sheets: %w", err)
+		}
 	}
-	return e.removeDuplicates()
+	return nil
 }
 
-func (e *SheetsExporter) appendToSheet(listings []listing.Listing) error {
-	// Create a new Google Sheets service client
-	ctx := context.Background()
-	srv, err := sheets.NewService(ctx, option.WithCredentialsFile("pinkbike-exporter-8bc8e681ffa1.json"))
+// exportTab writes listings to a listings tab, updating the rows of those
+// already on it, and removes the rows of moved, the listings written to the
+// other tab
+func (e *SheetsExporter) exportTab(title string, sheetID int64, listings, moved []listing.Listing) error {
+	existing, err := e.readRows(title)
 	if err != nil {
-		return fmt.Errorf("Unable to retrieve Sheets client: %v", err)
+		return err
+	}
+
+	updates, appends := planSheetChanges(existing, listings, e.opts.Near)
+	if len(existing) == 0 {
+		appends = append([][]interface{}{sheetHeaders}, appends...)
 	}
 
-	var values [][]interface{}
+	if err := e.updateRows(title, updates); err != nil {
+		return err
+	}
+	if err := e.appendToSheet(title, appends); err != nil {
+		return err
+	}
+	return e.deleteRows(title, sheetID, movedRows(existing, moved))
+}
+
+// splitSuspect separates the listings needing review from the rest
+func splitSuspect(listings []listing.Listing) (good, suspect []listing.Listing) {
 	for _, l := range listings {
-		values = append(values, []interface{}{l.Title, l.Year, l.Manufacturer, l.Model, l.Price, l.Condition, l.
-			FrameSize, l.WheelSize, l.FrontTravel, l.RearTravel, l.FrameMaterial, l.NeedsReview, l.Currency, l.URL})
+		if len(l.NeedsReview) > 0 {
+			suspect = append(suspect, l)
+			continue
+		}
+		good = append(good, l)
+	}
+	return good, suspect
+}
+
+// reviewTab is the title of the tab listings needing review are written to
+func (e *SheetsExporter) reviewTab() string {
+	return e.sheetName + " Review"
+}
+
+// ensureReviewSheet looks up the review tab, creating it with its Needs
+// Review column highlighted when missing, and returns its ID
+func (e *SheetsExporter) ensureReviewSheet() (int64, error) {
+	if e.reviewSheetID != 0 {
+		return e.reviewSheetID, nil
+	}
+	id, created, err := e.findOrAddSheet(e.reviewTab())
+	if err != nil {
+		return 0, err
+	}
+	if created {
+		if err := e.highlightReasons(id); err != nil {
+			return 0, err
+		}
 	}
+	e.reviewSheetID = id
+	return id, nil
+}
 
-	// Create the value range object
-	valueRange := &sheets.ValueRange{
-		Values: values,
+// highlightReasons colours the Needs Review column of a tab's listings by
+// reason: red for suspected scams, amber for the rest
+func (e *SheetsExporter) highlightReasons(sheetID int64) error {
+	column := []*sheets.GridRange{{
+		SheetId: sheetID, StartRowIndex: 1,
+		StartColumnIndex: sheetReviewColumn, EndColumnIndex: sheetReviewColumn + 1,
+	}}
+	rule := func(index int64, condition *sheets.BooleanCondition, color *sheets.Color) *sheets.Request {
+		return &sheets.Request{AddConditionalFormatRule: &sheets.AddConditionalFormatRuleRequest{
+			Index: index,
+			Rule: &sheets.ConditionalFormatRule{
+				Ranges: column,
+				BooleanRule: &sheets.BooleanRule{
+					Condition: condition,
+					Format:    &sheets.CellFormat{BackgroundColor: color},
+				},
+			},
+		}}
+	}
+	requests := []*sheets.Request{
+		rule(0, &sheets.BooleanCondition{Type: "TEXT_CONTAINS", Values: []*sheets.ConditionValue{{UserEnteredValue: "fraud"}}},
+			&sheets.Color{Red: 0.96, Green: 0.8, Blue: 0.8}),
+		rule(1, &sheets.BooleanCondition{Type: "NOT_BLANK"}, &sheets.Color{Red: 1, Green: 0.9, Blue: 0.7}),
 	}
 
-	// Append the data to the sheet
-	appendRange := "Sheet1"
-	_, err = srv.Spreadsheets.Values.Append(e.spreadsheetID, appendRange, valueRange).ValueInputOption("USER_ENTERED").
-		InsertDataOption("INSERT_ROWS").Do()
+	err := e.withRetry("format "+e.reviewTab(), func() error {
+		_, err := e.service.Spreadsheets.BatchUpdate(e.spreadsheetID, &sheets.BatchUpdateSpreadsheetRequest{Requests: requests}).Do()
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("Unable to append data to sheet: %v", err)
+		return fmt.Errorf("Unable to format sheet %s: %v", e.reviewTab(), err)
 	}
+	return nil
+}
 
+// writeRunTabs writes this run to a dated tab and replaces the latest and summary tabs
+func (e *SheetsExporter) writeRunTabs(listings []listing.Listing) error {
+	rows := [][]interface{}{sheetHeaders}
+	for _, l := range listings {
+		rows = append(rows, sheetRow(l, e.opts.Near))
+	}
+
+	runTab := fmt.Sprintf("%s %s", e.sheetName, clock.Or(e.opts.Clock).Now().Format("2006-01-02"))
+	if err := e.replaceTab(runTab, rows); err != nil {
+		return err
+	}
+	if err := e.replaceTab(e.sheetName+" Latest", rows); err != nil {
+		return err
+	}
+	return e.writeSummaryTabs(listings)
+}
+
+// writeSummaryTabs replaces the summary and sizes tabs. Every listing is
+// counted in both, so each spends half the privacy budget.
+func (e *SheetsExporter) writeSummaryTabs(listings []listing.Listing) error {
+	p := e.opts.Privacy.Split(2)
+	if err := e.replaceTab(e.sheetName+" Summary", summarizeByModel(listings, &p)); err != nil {
+		return err
+	}
+	return e.replaceTab(e.sheetName+" Sizes", summarizeBySize(listings, &p))
+}
+
+// WriteTab replaces the contents of a tab of the spreadsheet with rows, for
+// reports that are not listing exports
+func (e *SheetsExporter) WriteTab(title string, rows [][]interface{}) error {
+	return e.replaceTab(title, rows)
+}
+
+// replaceTab clears a tab, creating it if needed, and writes rows from A1
+func (e *SheetsExporter) replaceTab(title string, rows [][]interface{}) error {
+	if _, err := e.ensureSheet(title); err != nil {
+		return err
+	}
+
+	tabRange := fmt.Sprintf("'%s'", title)
+	err := e.withRetry("clear "+title, func() error {
+		_, err := e.service.Spreadsheets.Values.Clear(e.spreadsheetID, tabRange, &sheets.ClearValuesRequest{}).Do()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Unable to clear sheet %s: %v", title, err)
+	}
+
+	written := 0
+	for _, chunk := range chunkRows(rows, e.batchSize()) {
+		chunkRange := fmt.Sprintf("%s!A%d", tabRange, written+1)
+		err := e.withRetry("write "+title, func() error {
+			_, err := e.service.Spreadsheets.Values.Update(e.spreadsheetID, chunkRange, &sheets.ValueRange{Values: chunk}).
+				ValueInputOption("USER_ENTERED").Do()
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("Unable to write sheet %s after %d of %d rows: %v", title, written, len(rows), err)
+		}
+		written += len(chunk)
+	}
 	return nil
 }
 
-// SendDeDuplicateRequestToGoogleSheets removes duplicate rows from the Google Sheets document
-// NOTE: Only the first match is kept! This means that when a listing's price changes, the old listing and old price will be kept.
-func (e *SheetsExporter) removeDuplicates() error {
-	// Remove duplicates from the sheet, considering only specific columns
-	deleteDuplicatesRequest := &sheets.BatchUpdateSpreadsheetRequest{
-		Requests: []*sheets.Request{
-			{
-				DeleteDuplicates: &sheets.DeleteDuplicatesRequest{
-					Range: &sheets.GridRange{
-						SheetId:          0,
-						StartRowIndex:    0,
-						StartColumnIndex: 0,
-						EndColumnIndex:   12, // Include columns 0 to 11 (Title to FrameMaterial)
-					},
-					ComparisonColumns: []*sheets.DimensionRange{
-						{
-							SheetId:    0,
-							Dimension:  "COLUMNS",
-							StartIndex: 0, // Title
-							EndIndex:   3, // Model
-						},
-						{
-							SheetId:    0,
-							Dimension:  "COLUMNS",
-							StartIndex: 6,  // Condition
-							EndIndex:   11, // FrameMaterial
-						},
-					},
-				},
-			},
-		},
+// summarizeByModel builds summary rows with the listing count and median price
+// per manufacturer, model and generation, most listed models first. Counts and medians are
+// protected by p, and models it suppresses are left out.
+func summarizeByModel(listings []listing.Listing, p *privacy.Options) [][]interface{} {
+	// e-bikes are summarized apart so their prices do not skew other bikes
+	type key struct {
+		manufacturer, model, generation string
+		electric                        bool
+	}
+	prices := map[key][]float64{}
+	counts := map[key]int{}
+	for _, l := range listings {
+		k := key{l.Manufacturer, l.Model, l.Generation, l.IsElectric}
+		counts[k]++
+		if price, err := strconv.ParseFloat(l.Price, 64); err == nil && price > 0 {
+			prices[k] = append(prices[k], price)
+		}
+	}
+
+	published := map[key]int{}
+	keys := make([]key, 0, len(counts))
+	for k, n := range counts {
+		count, ok := p.Count(n)
+		if !ok {
+			continue
+		}
+		published[k] = count
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if published[keys[i]] != published[keys[j]] {
+			return published[keys[i]] > published[keys[j]]
+		}
+		if keys[i].manufacturer != keys[j].manufacturer {
+			return keys[i].manufacturer < keys[j].manufacturer
+		}
+		if keys[i].model != keys[j].model {
+			return keys[i].model < keys[j].model
+		}
+		if keys[i].generation != keys[j].generation {
+			return keys[i].generation < keys[j].generation
+		}
+		return !keys[i].electric
+	})
+
+	rows := [][]interface{}{{"Manufacturer", "Model", "Generation", "E-Bike", "Listings", "Median Price (USD)"}}
+	for _, k := range keys {
+		var medianPrice interface{} = ""
+		if len(prices[k]) > 0 {
+			medianPrice = p.Median(prices[k])
+		}
+		electric := ""
+		if k.electric {
+			electric = "yes"
+		}
+		rows = append(rows, []interface{}{k.manufacturer, k.model, k.generation, electric, published[k], medianPrice})
+	}
+	return rows
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// summarizeBySize builds the size summary tab: listing count and median
+// price per normalized frame size, smallest first, with unrecognised sizes last
+func summarizeBySize(listings []listing.Listing, p *privacy.Options) [][]interface{} {
+	prices := map[string][]float64{}
+	counts := map[string]int{}
+	for _, l := range listings {
+		counts[l.NormalizedSize]++
+		if price, err := strconv.ParseFloat(l.Price, 64); err == nil && price > 0 {
+			prices[l.NormalizedSize] = append(prices[l.NormalizedSize], price)
+		}
+	}
+
+	rows := [][]interface{}{{"Size", "Rider Height (cm)", "Listings", "Median Price (USD)"}}
+	for _, size := range append(parser.SizeNames(), "") {
+		count, ok := p.Count(counts[size])
+		if counts[size] == 0 || !ok {
+			continue
+		}
+
+		var medianPrice interface{} = ""
+		if len(prices[size]) > 0 {
+			medianPrice = p.Median(prices[size])
+		}
+
+		name, height := "Unknown", ""
+		if s, ok := parser.SizeByName(size); ok {
+			name, height = s.Name, fmt.Sprintf("%d-%d", s.MinHeight, s.MaxHeight)
+		}
+		rows = append(rows, []interface{}{name, height, count, medianPrice})
+	}
+	return rows
+}
+
+// sheetHeaders is the column layout of the listings tab; the hash column is
+// used to match listings against rows that were already exported
+var sheetHeaders = []interface{}{"Title", "Year", "Manufacturer", "Model", "Price", "Condition", "Frame Size", "Wheel Size", "Front Travel", "Rear Travel", "Frame Material", "Needs Review", "Currency", "URL", "Hash", "Category", "Location", "Distance (km)"}
+
+const (
+	sheetReviewColumn = 11
+	sheetHashColumn   = 14
+)
+
+func sheetRow(l listing.Listing, near geo.Point) []interface{} {
+	hash := l.Hash
+	if hash == "" {
+		hash = l.ComputeHash()
+	}
+	return []interface{}{l.Title, l.Year, l.Manufacturer, l.Model, l.Price, l.Condition, l.FrameSize, l.WheelSize, l.FrontTravel, l.RearTravel, l.FrameMaterial, l.NeedsReview.String(), l.Currency, l.URL, hash, l.Category, l.Location, distance(l, near)}
+}
+
+// planSheetChanges matches listings to existing rows by hash. Rows whose values
+// changed are returned keyed by their 1-based sheet row number; listings with no
+// matching row are returned to be appended.
+func planSheetChanges(existing [][]interface{}, listings []listing.Listing, near geo.Point) (map[int][]interface{}, [][]interface{}) {
+	rowsByHash := map[string]int{}
+	for i, row := range existing {
+		if len(row) <= sheetHashColumn {
+			continue
+		}
+		hash := fmt.Sprint(row[sheetHashColumn])
+		if _, ok := rowsByHash[hash]; !ok && hash != "" {
+			rowsByHash[hash] = i
+		}
+	}
+
+	updates := map[int][]interface{}{}
+	var appends [][]interface{}
+	for _, l := range listings {
+		row := sheetRow(l, near)
+		hash := row[sheetHashColumn].(string)
+
+		i, ok := rowsByHash[hash]
+		if !ok {
+			rowsByHash[hash] = len(existing) + len(appends)
+			appends = append(appends, row)
+			continue
+		}
+
+		if i >= len(existing) {
+			// duplicate within this export, keep the latest values
+			appends[i-len(existing)] = row
+			continue
+		}
+
+		if !rowsEqual(existing[i], row) {
+			updates[i+1] = row
+		}
+	}
+
+	return updates, appends
+}
+
+// movedRows returns the 1-based row numbers of the existing rows of listings
+// written to another tab, last first so deleting them in order leaves the
+// numbers of the rest valid
+func movedRows(existing [][]interface{}, moved []listing.Listing) []int {
+	hashes := map[string]bool{}
+	for _, l := range moved {
+		hash := l.Hash
+		if hash == "" {
+			hash = l.ComputeHash()
+		}
+		hashes[hash] = true
+	}
+
+	var rows []int
+	for i := len(existing) - 1; i > 0; i-- {
+		if len(existing[i]) > sheetHashColumn && hashes[fmt.Sprint(existing[i][sheetHashColumn])] {
+			rows = append(rows, i+1)
+		}
+	}
+	return rows
+}
+
+func rowsEqual(a, b []interface{}) bool {
+	for i := range b {
+		var av interface{} = ""
+		if i < len(a) {
+			av = a[i]
+		}
+		if fmt.Sprint(av) != fmt.Sprint(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// withRetry retries a Sheets call and counts every attempt against the daily quota
+func (e *SheetsExporter) withRetry(description string, fn func() error) error {
+	return withRetry(e.opts.MaxRetries, description, func() error {
+		e.quota.record()
+		return fn()
+	})
+}
+
+// batchSize is the configured batch size, raised near the daily quota so the
+// remaining rows take fewer requests
+func (e *SheetsExporter) batchSize() int {
+	if e.quota.nearLimit() && e.opts.BatchSize < sheetsMaxBatchSize {
+		return sheetsMaxBatchSize
 	}
+	return e.opts.BatchSize
+}
 
-	_, err := e.service.Spreadsheets.BatchUpdate(e.spreadsheetID, deleteDuplicatesRequest).Do()
+func columnRange(title string) string {
+	return fmt.Sprintf("'%s'!A:%c", title, 'A'+len(sheetHeaders)-1)
+}
+
+func (e *SheetsExporter) readRows(title string) ([][]interface{}, error) {
+	var resp *sheets.ValueRange
+	err := e.withRetry("read rows", func() error {
+		var err error
+		resp, err = e.service.Spreadsheets.Values.Get(e.spreadsheetID, columnRange(title)).Do()
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("Unable to remove duplicates from sheet: %v", err)
+		return nil, fmt.Errorf("Unable to read rows from sheet %s: %v", title, err)
+	}
+	return resp.Values, nil
+}
+
+// updateRows rewrites changed rows of a tab in place, batchSize rows per request
+func (e *SheetsExporter) updateRows(title string, updates map[int][]interface{}) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	rowNumbers := make([]int, 0, len(updates))
+	for rowNumber := range updates {
+		rowNumbers = append(rowNumbers, rowNumber)
+	}
+	sort.Ints(rowNumbers)
+
+	data := make([]*sheets.ValueRange, 0, len(updates))
+	for _, rowNumber := range rowNumbers {
+		data = append(data, &sheets.ValueRange{
+			Range:  fmt.Sprintf("'%s'!A%d", title, rowNumber),
+			Values: [][]interface{}{updates[rowNumber]},
+		})
+	}
+
+	batchSize := e.batchSize()
+	for start := 0; start < len(data); start += batchSize {
+		end := start + batchSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		err := e.withRetry("update rows", func() error {
+			_, err := e.service.Spreadsheets.Values.BatchUpdate(e.spreadsheetID, &sheets.BatchUpdateValuesRequest{
+				ValueInputOption: "USER_ENTERED",
+				Data:             data[start:end],
+			}).Do()
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("Unable to update rows in sheet after %d of %d rows: %v", start, len(data), err)
+		}
+		fmt.Printf("Sheets: updated %d/%d rows\n", end, len(data))
+	}
+
+	return nil
+}
+
+func (e *SheetsExporter) appendToSheet(title string, values [][]interface{}) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	// Append the data to the sheet in batches. Rows are matched by hash on the
+	// next export, so a run that fails part way resumes with the remaining rows.
+	appendRange := fmt.Sprintf("'%s'", title)
+	appended := 0
+	for _, chunk := range chunkRows(values, e.batchSize()) {
+		err := e.withRetry("append rows", func() error {
+			_, err := e.service.Spreadsheets.Values.Append(e.spreadsheetID, appendRange, &sheets.ValueRange{Values: chunk}).ValueInputOption("USER_ENTERED").
+				InsertDataOption("INSERT_ROWS").Do()
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("Unable to append data to sheet after %d of %d rows: %v", appended, len(values), err)
+		}
+		appended += len(chunk)
+		fmt.Printf("Sheets: appended %d/%d rows\n", appended, len(values))
 	}
 
 	return nil
 }
 
-func createSheetAndShare(ctx context.Context, srv *sheets.Service, title, email, credentialFile string) error {
+// deleteRows removes rows of a tab by their 1-based row numbers, which must
+// be ordered last first
+func (e *SheetsExporter) deleteRows(title string, sheetID int64, rowNumbers []int) error {
+	if len(rowNumbers) == 0 {
+		return nil
+	}
+
+	requests := make([]*sheets.Request, 0, len(rowNumbers))
+	for _, n := range rowNumbers {
+		requests = append(requests, &sheets.Request{DeleteDimension: &sheets.DeleteDimensionRequest{
+			Range: &sheets.DimensionRange{SheetId: sheetID, Dimension: "ROWS", StartIndex: int64(n - 1), EndIndex: int64(n)},
+		}})
+	}
+
+	err := e.withRetry("delete rows", func() error {
+		_, err := e.service.Spreadsheets.BatchUpdate(e.spreadsheetID, &sheets.BatchUpdateSpreadsheetRequest{Requests: requests}).Do()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Unable to remove %d moved rows from sheet %s: %v", len(rowNumbers), title, err)
+	}
+	fmt.Printf("Sheets: moved %d rows out of %s\n", len(rowNumbers), title)
+	return nil
+}
+
+// createSheetAndShare creates a spreadsheet, shares it with email and returns its ID
+func createSheetAndShare(ctx context.Context, srv *sheets.Service, title, email string, clientOption option.ClientOption) (string, error) {
 	sheet, err := srv.Spreadsheets.Create(&sheets.Spreadsheet{
 		Properties: &sheets.SpreadsheetProperties{
 			Title: title,
 		},
 	}).Do()
 	if err != nil {
-		return fmt.Errorf("Unable to create spreadsheet: %v", err)
+		return "", fmt.Errorf("Unable to create spreadsheet: %v", err)
 	}
 
 	fmt.Printf("Created new spreadsheet: %s\n", sheet.SpreadsheetUrl)
 
-	driveService, err := drive.NewService(ctx, option.WithCredentialsFile(credentialFile))
+	driveService, err := drive.NewService(ctx, clientOption)
 	if err != nil {
-		return fmt.Errorf("Unable to retrieve Drive client: %v", err)
+		return "", fmt.Errorf("Unable to retrieve Drive client: %v", err)
 	}
 
 	_, err = driveService.Permissions.Create(sheet.SpreadsheetId, &drive.Permission{
@@ -133,8 +708,8 @@ func createSheetAndShare(ctx context.Context, srv *sheets.Service, title, email,
 		EmailAddress: email,
 	}).Do()
 	if err != nil {
-		return fmt.Errorf("Unable to share spreadsheet: %v", err)
+		return "", fmt.Errorf("Unable to share spreadsheet: %v", err)
 	}
 
-	return nil
+	return sheet.SpreadsheetId, nil
 }
@@ -0,0 +1,114 @@
+package exporter
+
+import (
+	"testing"
+
+	"pinkbike-scraper/pkg/geo"
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/privacy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanSheetChanges(t *testing.T) {
+	unchanged := listing.Listing{Title: "2021 Evil Wreckoning", Price: "3900"}
+	repriced := listing.Listing{Title: "2020 Kona Process 153", Price: "2200"}
+	existing := [][]interface{}{
+		sheetHeaders,
+		sheetRow(unchanged, geo.Point{}),
+		sheetRow(repriced, geo.Point{}),
+	}
+
+	repriced.Price = "1900"
+	added := listing.Listing{Title: "2022 Transition Spire", Price: "5300"}
+
+	updates, appends := planSheetChanges(existing, []listing.Listing{unchanged, repriced, added}, geo.Point{})
+
+	require.Len(t, updates, 1)
+	assert.Equal(t, "1900", updates[3][4])
+
+	require.Len(t, appends, 1)
+	assert.Equal(t, "2022 Transition Spire", appends[0][0])
+}
+
+func TestPlanSheetChangesDuplicateInExport(t *testing.T) {
+	first := listing.Listing{Title: "2022 Transition Spire", Price: "5300"}
+	second := first
+	second.Price = "5000"
+
+	updates, appends := planSheetChanges(nil, []listing.Listing{first, second}, geo.Point{})
+
+	assert.Empty(t, updates)
+	require.Len(t, appends, 1)
+	assert.Equal(t, "5000", appends[0][4])
+}
+
+func TestMovedRows(t *testing.T) {
+	flagged := listing.Listing{Title: "2021 Evil Wreckoning", Price: "3900", NeedsReview: listing.Reasons{"price"}}
+	kept := listing.Listing{Title: "2020 Kona Process 153", Price: "2200"}
+	existing := [][]interface{}{
+		sheetHeaders,
+		sheetRow(listing.Listing{Title: "2021 Evil Wreckoning", Price: "3900"}, geo.Point{}),
+		sheetRow(kept, geo.Point{}),
+		sheetRow(listing.Listing{Title: "2021 Evil Wreckoning", Price: "3900"}, geo.Point{}),
+	}
+
+	good, suspect := splitSuspect([]listing.Listing{flagged, kept})
+	assert.Equal(t, []listing.Listing{kept}, good)
+	assert.Equal(t, []listing.Listing{flagged}, suspect)
+
+	assert.Equal(t, []int{4, 2}, movedRows(existing, suspect), "every row of a moved listing goes, last first")
+	assert.Empty(t, movedRows(existing, nil))
+	assert.Equal(t, "price", sheetRow(flagged, geo.Point{})[sheetReviewColumn])
+}
+
+func TestSummarizeByModel(t *testing.T) {
+	rows := summarizeByModel([]listing.Listing{
+		{Manufacturer: "Evil", Model: "Wreckoning", Price: "3900"},
+		{Manufacturer: "Evil", Model: "Wreckoning", Price: "3500"},
+		{Manufacturer: "Evil", Model: "Wreckoning", Price: ""},
+		{Manufacturer: "Kona", Model: "Process 153", Price: "2200"},
+		{Manufacturer: "Kona", Model: "Process 153", Price: "5200", IsElectric: true},
+		{Manufacturer: "Santa Cruz", Model: "Nomad", Generation: "V4", Price: "2800"},
+		{Manufacturer: "Santa Cruz", Model: "Nomad", Generation: "V6", Price: "5600"},
+	}, &privacy.Options{})
+
+	assert.Equal(t, [][]interface{}{
+		{"Manufacturer", "Model", "Generation", "E-Bike", "Listings", "Median Price (USD)"},
+		{"Evil", "Wreckoning", "", "", 3, 3700.0},
+		{"Kona", "Process 153", "", "", 1, 2200.0},
+		{"Kona", "Process 153", "", "yes", 1, 5200.0},
+		{"Santa Cruz", "Nomad", "V4", "", 1, 2800.0},
+		{"Santa Cruz", "Nomad", "V6", "", 1, 5600.0},
+	}, rows)
+}
+
+func TestSummarizeByModelSuppressesSmallCohorts(t *testing.T) {
+	rows := summarizeByModel([]listing.Listing{
+		{Manufacturer: "Evil", Model: "Wreckoning", Price: "3900"},
+		{Manufacturer: "Evil", Model: "Wreckoning", Price: "3500"},
+		{Manufacturer: "Kona", Model: "Process 153", Price: "2200"},
+	}, &privacy.Options{MinCount: 2})
+
+	assert.Equal(t, [][]interface{}{
+		{"Manufacturer", "Model", "Generation", "E-Bike", "Listings", "Median Price (USD)"},
+		{"Evil", "Wreckoning", "", "", 2, 3700.0},
+	}, rows)
+}
+
+func TestSummarizeBySize(t *testing.T) {
+	rows := summarizeBySize([]listing.Listing{
+		{NormalizedSize: "L", Price: "3000"},
+		{NormalizedSize: "S", Price: "2000"},
+		{NormalizedSize: "L", Price: "4000"},
+		{NormalizedSize: "", Price: "1000"},
+	}, &privacy.Options{})
+
+	assert.Equal(t, [][]interface{}{
+		{"Size", "Rider Height (cm)", "Listings", "Median Price (USD)"},
+		{"S", "160-170", 1, 2000.0},
+		{"L", "175-186", 2, 3500.0},
+		{"Unknown", "", 1, 1000.0},
+	}, rows)
+}
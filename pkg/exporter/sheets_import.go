@@ -0,0 +1,179 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"pinkbike-scraper/pkg/listing"
+
+	"google.golang.org/api/sheets/v4"
+)
+
+// SheetsColumns maps the listing fields read back from a spreadsheet, such as
+// "year", to the header of the column holding them
+type SheetsColumns map[string]string
+
+// sheetFields are the listing fields a spreadsheet row can set, named as
+// SheetsColumns names them, with the listings tab header they default to
+var sheetFields = []struct {
+	name, header string
+	set          func(l *listing.Listing, value string)
+}{
+	{"title", "Title", func(l *listing.Listing, v string) { l.Title = v }},
+	{"year", "Year", func(l *listing.Listing, v string) { l.Year = v }},
+	{"manufacturer", "Manufacturer", func(l *listing.Listing, v string) { l.Manufacturer = v }},
+	{"model", "Model", func(l *listing.Listing, v string) { l.Model = v }},
+	{"price", "Price", func(l *listing.Listing, v string) { l.Price = v }},
+	{"condition", "Condition", func(l *listing.Listing, v string) { l.Condition = v }},
+	{"frame_size", "Frame Size", func(l *listing.Listing, v string) { l.FrameSize = v }},
+	{"wheel_size", "Wheel Size", func(l *listing.Listing, v string) { l.WheelSize = v }},
+	{"front_travel", "Front Travel", func(l *listing.Listing, v string) { l.FrontTravel = v }},
+	{"rear_travel", "Rear Travel", func(l *listing.Listing, v string) { l.RearTravel = v }},
+	{"frame_material", "Frame Material", func(l *listing.Listing, v string) { l.FrameMaterial = v }},
+	{"currency", "Currency", func(l *listing.Listing, v string) { l.Currency = v }},
+	{"url", "URL", func(l *listing.Listing, v string) { l.URL = v }},
+	{"hash", "Hash", func(l *listing.Listing, v string) { l.Hash = v }},
+	{"category", "Category", func(l *listing.Listing, v string) { l.Category = v }},
+	{"location", "Location", func(l *listing.Listing, v string) { l.Location = v }},
+}
+
+// DefaultSheetsColumns is the column layout the sheets export writes
+func DefaultSheetsColumns() SheetsColumns {
+	columns := SheetsColumns{}
+	for _, f := range sheetFields {
+		columns[f.name] = f.header
+	}
+	return columns
+}
+
+// ParseSheetsColumns reads a column mapping such as "year=Model Year,model=Bike"
+// on top of the default layout, for spreadsheets whose columns were renamed.
+// Mapping a field to an empty header leaves it unread.
+func ParseSheetsColumns(spec string) (SheetsColumns, error) {
+	columns := DefaultSheetsColumns()
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, header, ok := strings.Cut(pair, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("invalid column mapping %q, want field=header", pair)
+		}
+		if _, known := columns[name]; !known {
+			return nil, fmt.Errorf("unknown listing field %q in column mapping (known: %s)", name, strings.Join(columns.fields(), ", "))
+		}
+		columns[name] = strings.TrimSpace(header)
+	}
+	return columns, nil
+}
+
+func (c SheetsColumns) fields() []string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// listingsFromSheetRows reads listings from rows whose first row holds the
+// headers columns names. Rows without a hash cannot be matched to a stored
+// listing and are left out.
+func listingsFromSheetRows(rows [][]interface{}, columns SheetsColumns) ([]listing.Listing, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	index := map[string]int{}
+	for i, header := range rows[0] {
+		index[strings.ToLower(strings.TrimSpace(fmt.Sprint(header)))] = i
+	}
+	if _, ok := index[strings.ToLower(columns["hash"])]; !ok || columns["hash"] == "" {
+		return nil, fmt.Errorf("no %q column to match rows to stored listings", columns["hash"])
+	}
+
+	var listings []listing.Listing
+	for _, row := range rows[1:] {
+		var l listing.Listing
+		for _, f := range sheetFields {
+			header := columns[f.name]
+			i, ok := index[strings.ToLower(header)]
+			if header == "" || !ok || i >= len(row) {
+				continue
+			}
+			f.set(&l, strings.TrimSpace(fmt.Sprint(row[i])))
+		}
+		if l.Hash != "" {
+			listings = append(listings, l)
+		}
+	}
+	return listings, nil
+}
+
+// SheetsReader reads listings back from a spreadsheet, such as one the sheets
+// export wrote and reviewers then corrected by hand
+type SheetsReader struct {
+	service       *sheets.Service
+	spreadsheetID string
+}
+
+// NewSheetsReader reads from the spreadsheet with the same credentials the
+// sheets export uses. An OAuth token must already be cached in tokenFile.
+func NewSheetsReader(credentialsFile, tokenFile, spreadsheetID string) (*SheetsReader, error) {
+	if spreadsheetID == "" {
+		return nil, fmt.Errorf("no spreadsheet ID to read listings from")
+	}
+	ctx := context.Background()
+	clientOption, err := sheetsClientOption(ctx, credentialsFile, tokenFile, false)
+	if err != nil {
+		return nil, err
+	}
+	srv, err := sheets.NewService(ctx, clientOption)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sheets service: %w", err)
+	}
+	return &SheetsReader{service: srv, spreadsheetID: spreadsheetID}, nil
+}
+
+// ReadListings reads the listings in readRange, a tab name or an A1 range
+// such as "Enduro!A1:R500" starting at the header row
+func (r *SheetsReader) ReadListings(readRange string, columns SheetsColumns) ([]listing.Listing, error) {
+	resp, err := r.service.Spreadsheets.Values.Get(r.spreadsheetID, readRange).Do()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read rows from %s: %v", readRange, err)
+	}
+	return listingsFromSheetRows(resp.Values, columns)
+}
+
+// SheetCorrection is a change a reviewer made in a spreadsheet to the year,
+// manufacturer or model of a stored listing
+type SheetCorrection struct {
+	Stored     listing.Listing
+	Correction Correction
+}
+
+// SheetCorrections compares listings read back from a spreadsheet with the
+// stored listings they were exported from and returns the ones whose year,
+// manufacturer or model was changed, to be saved with SaveCorrection. Rows
+// of listings no longer stored are skipped.
+func (e *DBExporter) SheetCorrections(listings []listing.Listing) ([]SheetCorrection, error) {
+	var corrections []SheetCorrection
+	for _, l := range listings {
+		stored, err := e.FindListing(l.Hash)
+		if errors.Is(err, ErrListingNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		c := Correction{Year: l.Year, Manufacturer: l.Manufacturer, Model: l.Model}
+		if c == (Correction{Year: stored.Year, Manufacturer: stored.Manufacturer, Model: stored.Model}) {
+			continue
+		}
+		corrections = append(corrections, SheetCorrection{Stored: stored, Correction: c})
+	}
+	return corrections, nil
+}
@@ -0,0 +1,74 @@
+package exporter
+
+import (
+	"testing"
+
+	"pinkbike-scraper/pkg/listing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSheetsColumns(t *testing.T) {
+	columns, err := ParseSheetsColumns("year=Model Year, model = Bike")
+	require.NoError(t, err)
+	assert.Equal(t, "Model Year", columns["year"])
+	assert.Equal(t, "Bike", columns["model"])
+	assert.Equal(t, "Hash", columns["hash"])
+
+	_, err = ParseSheetsColumns("colour=Paint")
+	assert.Error(t, err)
+	_, err = ParseSheetsColumns("year")
+	assert.Error(t, err)
+}
+
+func TestListingsFromSheetRows(t *testing.T) {
+	columns, err := ParseSheetsColumns("year=Model Year")
+	require.NoError(t, err)
+	rows := [][]interface{}{
+		{"Title", "Model Year", "Manufacturer", "Model", "Hash"},
+		{"2019 SC Nomad", "2019", "Santa Cruz", "Nomad", "abc"},
+		{"No hash", "2020"},
+		{"Short row", "", "", "", "def"},
+	}
+
+	listings, err := listingsFromSheetRows(rows, columns)
+	require.NoError(t, err)
+	assert.Equal(t, []listing.Listing{
+		{Title: "2019 SC Nomad", Year: "2019", Manufacturer: "Santa Cruz", Model: "Nomad", Hash: "abc"},
+		{Title: "Short row", Hash: "def"},
+	}, listings)
+
+	_, err = listingsFromSheetRows([][]interface{}{{"Title", "Year"}}, columns)
+	assert.Error(t, err)
+}
+
+func TestSheetCorrections(t *testing.T) {
+	exp := newTestDBExporter(t, nil)
+
+	parsed := listing.Listing{
+		Title: "2019 SC Nomad 27.5", Year: "2019", Manufacturer: "NoManufacturer", Model: "NoModelFound",
+		Price: "3000", Currency: "USD", Condition: "Good", FrameSize: "L", WheelSize: "27.5",
+		FrontTravel: "170 mm", RearTravel: "170 mm", FrameMaterial: "Carbon Fiber", NeedsReview: listing.Reasons{"manufacturer"},
+	}
+	untouched := listing.Listing{
+		Title: "2021 Ibis Ripmo", Year: "2021", Manufacturer: "Ibis", Model: "Ripmo",
+		Price: "4000", Currency: "USD", Condition: "Good", FrameSize: "M", WheelSize: "29",
+	}
+	require.NoError(t, exp.Export([]listing.Listing{parsed, untouched}))
+
+	fromSheet := []listing.Listing{
+		{Hash: parsed.ComputeHash(), Year: "2019", Manufacturer: "Santa Cruz", Model: "Nomad"},
+		{Hash: untouched.ComputeHash(), Year: "2021", Manufacturer: "Ibis", Model: "Ripmo"},
+		{Hash: "gone", Year: "2018", Manufacturer: "Trek", Model: "Slash"},
+	}
+	corrections, err := exp.SheetCorrections(fromSheet)
+	require.NoError(t, err)
+	require.Len(t, corrections, 1)
+	assert.Equal(t, parsed.ComputeHash(), corrections[0].Stored.Hash)
+	assert.Equal(t, Correction{Year: "2019", Manufacturer: "Santa Cruz", Model: "Nomad"}, corrections[0].Correction)
+
+	corrected, err := exp.SaveCorrection(corrections[0].Stored, corrections[0].Correction)
+	require.NoError(t, err)
+	assert.Empty(t, corrected.NeedsReview)
+}
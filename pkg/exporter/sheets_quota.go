@@ -0,0 +1,81 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"pinkbike-scraper/pkg/clock"
+)
+
+const (
+	// sheetsQuotaSoftLimit is the share of the daily budget after which
+	// low-priority writes are deferred and rows are sent in larger batches
+	sheetsQuotaSoftLimit = 0.8
+	// sheetsMaxBatchSize bounds batches when batching up near the limit
+	sheetsMaxBatchSize = 5000
+)
+
+// sheetsQuota counts Sheets API requests made today. Usage is saved to a file
+// so it accumulates across runs on the same day.
+type sheetsQuota struct {
+	path  string
+	limit int
+	clock clock.Clock
+
+	Date     string `json:"date"`
+	Requests int    `json:"requests"`
+}
+
+// loadSheetsQuota reads today's usage from path, a missing or stale file
+// starts the day at zero. A nil quota, returned when limit is zero, tracks nothing.
+func loadSheetsQuota(path string, limit int, clk clock.Clock) *sheetsQuota {
+	if limit <= 0 {
+		return nil
+	}
+
+	q := &sheetsQuota{path: path, limit: limit, clock: clock.Or(clk)}
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, q)
+	}
+	q.rollover()
+	return q
+}
+
+func (q *sheetsQuota) rollover() {
+	today := q.clock.Now().Format("2006-01-02")
+	if q.Date != today {
+		q.Date = today
+		q.Requests = 0
+	}
+}
+
+// record counts one request and saves the usage
+func (q *sheetsQuota) record() {
+	if q == nil {
+		return
+	}
+	q.rollover()
+	q.Requests++
+
+	data, err := json.Marshal(q)
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(q.path, data, 0644); err != nil {
+		fmt.Printf("Sheets: could not save quota usage: %v\n", err)
+	}
+}
+
+// nearLimit reports whether low-priority writes should wait for tomorrow's quota
+func (q *sheetsQuota) nearLimit() bool {
+	if q == nil {
+		return false
+	}
+	q.rollover()
+	return float64(q.Requests) >= float64(q.limit)*sheetsQuotaSoftLimit
+}
+
+func (q *sheetsQuota) String() string {
+	return fmt.Sprintf("%d of %d daily Sheets requests used", q.Requests, q.limit)
+}
@@ -0,0 +1,47 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pinkbike-scraper/pkg/clock"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSheetsQuota(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	day := clock.Fixed(time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC))
+
+	assert.Nil(t, loadSheetsQuota(path, 0, nil), "a zero limit disables tracking")
+	var disabled *sheetsQuota
+	disabled.record()
+	assert.False(t, disabled.nearLimit())
+
+	q := loadSheetsQuota(path, 10, day)
+	for i := 0; i < 7; i++ {
+		q.record()
+	}
+	assert.False(t, q.nearLimit())
+
+	// usage carries over to the next run on the same day
+	q = loadSheetsQuota(path, 10, day)
+	assert.Equal(t, 7, q.Requests)
+	q.record()
+	assert.True(t, q.nearLimit())
+
+	e := &SheetsExporter{opts: SheetsOptions{BatchSize: 500}, quota: q}
+	assert.Equal(t, sheetsMaxBatchSize, e.batchSize())
+
+	// a new day starts over, whether the file is stale or the day turns mid run
+	assert.NoError(t, os.WriteFile(path, []byte(`{"date":"2000-01-01","requests":9}`), 0644))
+	q = loadSheetsQuota(path, 10, day)
+	assert.Equal(t, 0, q.Requests)
+	assert.False(t, q.nearLimit())
+
+	q.Requests = 9
+	q.clock = clock.Fixed(time.Date(2024, 5, 2, 1, 0, 0, 0, time.UTC))
+	assert.False(t, q.nearLimit())
+}
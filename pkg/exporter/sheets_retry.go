@@ -0,0 +1,68 @@
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	defaultSheetsBatchSize  = 500
+	defaultSheetsMaxRetries = 5
+	sheetsInitialBackoff    = 2 * time.Second
+	sheetsMaxBackoff        = 64 * time.Second
+)
+
+// sleep is swapped out in tests so backoff does not slow them down
+var sleep = time.Sleep
+
+// isRetryable reports whether a Sheets API error is a quota or server error worth retrying
+func isRetryable(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == http.StatusTooManyRequests || apiErr.Code >= http.StatusInternalServerError
+}
+
+// withRetry calls fn, retrying retryable errors with exponential backoff
+func withRetry(maxRetries int, description string, fn func() error) error {
+	backoff := sheetsInitialBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || !isRetryable(err) {
+			return err
+		}
+		if attempt >= maxRetries {
+			return fmt.Errorf("%s failed after %d retries: %w", description, maxRetries, err)
+		}
+
+		fmt.Printf("Sheets: %s failed (%v), retrying in %s\n", description, err, backoff)
+		sleep(backoff)
+
+		backoff *= 2
+		if backoff > sheetsMaxBackoff {
+			backoff = sheetsMaxBackoff
+		}
+	}
+}
+
+// chunkRows splits rows into batches of at most size rows
+func chunkRows(rows [][]interface{}, size int) [][][]interface{} {
+	if size <= 0 {
+		size = defaultSheetsBatchSize
+	}
+
+	var chunks [][][]interface{}
+	for len(rows) > size {
+		chunks = append(chunks, rows[:size])
+		rows = rows[size:]
+	}
+	if len(rows) > 0 {
+		chunks = append(chunks, rows)
+	}
+	return chunks
+}
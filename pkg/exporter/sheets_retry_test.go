@@ -0,0 +1,52 @@
+package exporter
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/googleapi"
+)
+
+func TestWithRetry(t *testing.T) {
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	t.Cleanup(func() { sleep = time.Sleep })
+
+	calls := 0
+	err := withRetry(3, "test", func() error {
+		calls++
+		if calls < 3 {
+			return &googleapi.Error{Code: 429}
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []time.Duration{2 * time.Second, 4 * time.Second}, slept)
+
+	calls = 0
+	err = withRetry(3, "test", func() error {
+		calls++
+		return &googleapi.Error{Code: 503}
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 4, calls)
+
+	calls = 0
+	err = withRetry(3, "test", func() error {
+		calls++
+		return errors.New("bad request")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls, "non-retryable errors should not be retried")
+}
+
+func TestChunkRows(t *testing.T) {
+	rows := make([][]interface{}, 5)
+	chunks := chunkRows(rows, 2)
+	assert.Len(t, chunks, 3)
+	assert.Len(t, chunks[2], 1)
+	assert.Empty(t, chunkRows(nil, 2))
+}
@@ -0,0 +1,249 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"pinkbike-scraper/pkg/geo"
+	"pinkbike-scraper/pkg/listing"
+)
+
+// ANSI escape codes used by the table exporter
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+)
+
+// tableField is a column the table exporter can show
+type tableField struct {
+	header string
+	value  func(l listing.Listing) string
+	// numeric columns are right aligned and sorted by value
+	numeric bool
+	// maxWidth truncates longer values, zero never truncates
+	maxWidth int
+}
+
+var tableFields = map[string]tableField{
+	"title":        {header: "TITLE", value: func(l listing.Listing) string { return l.Title }, maxWidth: 50},
+	"year":         {header: "YEAR", value: func(l listing.Listing) string { return l.Year }, numeric: true},
+	"manufacturer": {header: "MANUFACTURER", value: func(l listing.Listing) string { return l.Manufacturer }},
+	"model":        {header: "MODEL", value: func(l listing.Listing) string { return l.Model }, maxWidth: 25},
+	"price":        {header: "PRICE", value: func(l listing.Listing) string { return l.Price }, numeric: true},
+	"currency":     {header: "CURRENCY", value: func(l listing.Listing) string { return l.Currency }},
+	"listedPrice":  {header: "LISTED", value: func(l listing.Listing) string { return l.ListedPrice }, numeric: true},
+	"condition":    {header: "CONDITION", value: func(l listing.Listing) string { return l.Condition }, maxWidth: 30},
+	"size": {header: "SIZE", value: func(l listing.Listing) string {
+		if l.NormalizedSize != "" {
+			return l.NormalizedSize
+		}
+		return l.FrameSize
+	}},
+	"wheelSize":   {header: "WHEELS", value: func(l listing.Listing) string { return l.WheelSize }},
+	"frontTravel": {header: "FRONT", value: func(l listing.Listing) string { return l.FrontTravel }, numeric: true},
+	"rearTravel":  {header: "REAR", value: func(l listing.Listing) string { return l.RearTravel }, numeric: true},
+	"material":    {header: "MATERIAL", value: func(l listing.Listing) string { return l.FrameMaterial }},
+	"category":    {header: "CATEGORY", value: func(l listing.Listing) string { return l.Category }},
+	"review":      {header: "REVIEW", value: func(l listing.Listing) string { return l.NeedsReview.String() }, maxWidth: 40},
+	"location":    {header: "LOCATION", value: func(l listing.Listing) string { return l.Location }, maxWidth: 30},
+	// distance is measured from TableOptions.Near, see TableExporter.field
+	"distance": {header: "KM", value: func(listing.Listing) string { return "" }, numeric: true},
+	"url":      {header: "URL", value: func(l listing.Listing) string { return l.URL }},
+}
+
+// DefaultTableFields are the columns shown when none are selected
+var DefaultTableFields = []string{"year", "manufacturer", "model", "price", "size", "condition", "url"}
+
+// TableFieldNames returns the fields the table exporter can show
+func TableFieldNames() []string {
+	names := make([]string, 0, len(tableFields))
+	for name := range tableFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TableOptions controls what the table exporter prints
+type TableOptions struct {
+	// Fields are the columns in order, DefaultTableFields when empty
+	Fields []string
+	// SortBy is the field listings are sorted by, descending with a leading
+	// "-" as in "-price"; empty keeps the scraped order
+	SortBy string
+	// Color highlights the header, prices and listings needing review
+	Color bool
+	// Near is where the distance field measures from
+	Near geo.Point
+}
+
+// TableExporter prints listings as an aligned table, for quick scrapes that
+// do not need a CSV or the database
+type TableExporter struct {
+	w      io.Writer
+	fields []tableField
+	opts   TableOptions
+}
+
+// NewTableExporter returns an exporter writing to w, rejecting unknown fields
+func NewTableExporter(w io.Writer, opts TableOptions) (*TableExporter, error) {
+	if len(opts.Fields) == 0 {
+		opts.Fields = DefaultTableFields
+	}
+
+	t := &TableExporter{w: w, opts: opts}
+	for _, name := range opts.Fields {
+		field, ok := t.field(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown table field %q (available: %s)", name, strings.Join(TableFieldNames(), ", "))
+		}
+		t.fields = append(t.fields, field)
+	}
+	if sortBy := strings.TrimPrefix(opts.SortBy, "-"); sortBy != "" {
+		if _, ok := t.field(sortBy); !ok {
+			return nil, fmt.Errorf("unknown table sort field %q (available: %s)", sortBy, strings.Join(TableFieldNames(), ", "))
+		}
+	}
+	return t, nil
+}
+
+// field returns the named field, with distances measured from opts.Near
+func (t *TableExporter) field(name string) (tableField, bool) {
+	f, ok := tableFields[name]
+	if name == "distance" {
+		f.value = func(l listing.Listing) string { return distance(l, t.opts.Near) }
+	}
+	return f, ok
+}
+
+func (t *TableExporter) Close() error {
+	return nil
+}
+
+func (t *TableExporter) Export(listings []listing.Listing) error {
+	if len(listings) == 0 {
+		_, err := fmt.Fprintln(t.w, "No listings")
+		return err
+	}
+	listings = t.sorted(listings)
+
+	rows := make([][]string, len(listings))
+	widths := make([]int, len(t.fields))
+	for i, f := range t.fields {
+		widths[i] = utf8.RuneCountInString(f.header)
+	}
+	for r, l := range listings {
+		rows[r] = make([]string, len(t.fields))
+		for i, f := range t.fields {
+			value := truncate(strings.TrimSpace(f.value(l)), f.maxWidth)
+			rows[r][i] = value
+			if n := utf8.RuneCountInString(value); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	var b strings.Builder
+	var line strings.Builder
+	// endLine drops the padding left by empty trailing columns
+	endLine := func() {
+		b.WriteString(strings.TrimRight(line.String(), " "))
+		b.WriteString("\n")
+		line.Reset()
+	}
+	for i, f := range t.fields {
+		line.WriteString(t.cell(f.header, widths[i], f.numeric, i == len(t.fields)-1, ansiBold))
+	}
+	endLine()
+	for r, row := range rows {
+		color := ""
+		if len(listings[r].NeedsReview) > 0 {
+			color = ansiYellow
+		}
+		for i, value := range row {
+			cellColor := color
+			if cellColor == "" && t.opts.Fields[i] == "price" {
+				cellColor = ansiGreen
+			}
+			line.WriteString(t.cell(value, widths[i], t.fields[i].numeric, i == len(row)-1, cellColor))
+		}
+		endLine()
+	}
+	fmt.Fprintf(&b, "%d listings\n", len(listings))
+
+	if _, err := io.WriteString(t.w, b.String()); err != nil {
+		return fmt.Errorf("failed to write table: %w", err)
+	}
+	return nil
+}
+
+// cell pads value to width, right aligning numbers. Padding goes outside the
+// color codes, which take no space on the terminal.
+func (t *TableExporter) cell(value string, width int, numeric, last bool, color string) string {
+	pad := strings.Repeat(" ", width-utf8.RuneCountInString(value))
+	if t.opts.Color && color != "" {
+		value = color + value + ansiReset
+	}
+	switch {
+	case numeric:
+		value = pad + value
+	case !last:
+		value += pad
+	}
+	if !last {
+		value += "  "
+	}
+	return value
+}
+
+// sorted returns listings ordered by opts.SortBy, keeping the scraped order
+// of equal values
+func (t *TableExporter) sorted(listings []listing.Listing) []listing.Listing {
+	name := strings.TrimPrefix(t.opts.SortBy, "-")
+	if name == "" {
+		return listings
+	}
+	descending := strings.HasPrefix(t.opts.SortBy, "-")
+	field, _ := t.field(name)
+
+	sorted := append([]listing.Listing(nil), listings...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := field.value(sorted[i]), field.value(sorted[j])
+		if descending {
+			a, b = b, a
+		}
+		if field.numeric {
+			return leadingNumber(a) < leadingNumber(b)
+		}
+		return strings.ToLower(a) < strings.ToLower(b)
+	})
+	return sorted
+}
+
+// leadingNumber reads the number a value starts with, such as 170 from
+// "170 mm", sorting values without one first
+func leadingNumber(s string) float64 {
+	end := 0
+	for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == '.') {
+		end++
+	}
+	n, err := strconv.ParseFloat(s[:end], 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// truncate shortens s to max runes, marking the cut with an ellipsis
+func truncate(s string, max int) string {
+	if max <= 0 || utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max-1]) + "…"
+}
@@ -0,0 +1,74 @@
+package exporter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"pinkbike-scraper/pkg/listing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var tableListings = []listing.Listing{
+	{Year: "2021", Manufacturer: "Evil", Model: "Wreckoning", Price: "3900", NormalizedSize: "L", URL: "https://pinkbike.com/1"},
+	{Year: "2019", Manufacturer: "Santa Cruz", Model: "Megatower", Price: "12500", FrameSize: "XL", URL: "https://pinkbike.com/2"},
+	{Year: "2022", Manufacturer: "Yeti", Model: "SB150", Price: "950", NeedsReview: listing.Reasons{"price"}, URL: "https://pinkbike.com/3"},
+}
+
+func exportTable(t *testing.T, opts TableOptions) string {
+	t.Helper()
+
+	var out bytes.Buffer
+	exp, err := NewTableExporter(&out, opts)
+	require.NoError(t, err)
+	require.NoError(t, exp.Export(tableListings))
+	return out.String()
+}
+
+func TestTableExporterAlignsColumns(t *testing.T) {
+	lines := strings.Split(strings.TrimSuffix(exportTable(t, TableOptions{Fields: []string{"manufacturer", "price", "size"}}), "\n"), "\n")
+	require.Len(t, lines, 5)
+
+	assert.Equal(t, "MANUFACTURER  PRICE  SIZE", lines[0])
+	assert.Equal(t, "Evil           3900  L", lines[1])
+	assert.Equal(t, "Santa Cruz    12500  XL", lines[2])
+	assert.Equal(t, "Yeti            950", lines[3])
+	assert.Equal(t, "3 listings", lines[4])
+}
+
+func TestTableExporterSorts(t *testing.T) {
+	out := exportTable(t, TableOptions{Fields: []string{"model"}, SortBy: "-price"})
+	assert.Equal(t, "MODEL\nMegatower\nWreckoning\nSB150\n3 listings\n", out)
+
+	out = exportTable(t, TableOptions{Fields: []string{"model"}, SortBy: "manufacturer"})
+	assert.Equal(t, "MODEL\nWreckoning\nMegatower\nSB150\n3 listings\n", out)
+}
+
+func TestTableExporterColor(t *testing.T) {
+	plain := exportTable(t, TableOptions{})
+	assert.NotContains(t, plain, "\x1b[")
+
+	colored := exportTable(t, TableOptions{Fields: []string{"model", "price"}, Color: true})
+	assert.Contains(t, colored, ansiBold+"MODEL"+ansiReset)
+	assert.Contains(t, colored, ansiGreen+"3900"+ansiReset)
+	assert.Contains(t, colored, ansiYellow+"SB150"+ansiReset)
+	assert.Contains(t, colored, ansiYellow+"950"+ansiReset)
+}
+
+func TestTableExporterRejectsUnknownFields(t *testing.T) {
+	_, err := NewTableExporter(&bytes.Buffer{}, TableOptions{Fields: []string{"year", "colour"}})
+	assert.ErrorContains(t, err, `unknown table field "colour"`)
+
+	_, err = NewTableExporter(&bytes.Buffer{}, TableOptions{SortBy: "-colour"})
+	assert.ErrorContains(t, err, `unknown table sort field "colour"`)
+}
+
+func TestTableExporterEmpty(t *testing.T) {
+	var out bytes.Buffer
+	exp, err := NewTableExporter(&out, TableOptions{})
+	require.NoError(t, err)
+	require.NoError(t, exp.Export(nil))
+	assert.Equal(t, "No listings\n", out.String())
+}
@@ -0,0 +1,111 @@
+package fraud
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"pinkbike-scraper/pkg/listing"
+)
+
+const (
+	// Threshold is the score from which a listing is a suspected scam, left
+	// out of medians and price indexes
+	Threshold = 0.5
+	// minComparables is how many priced listings of a model its median
+	// needs before a listing is judged against it
+	minComparables = 3
+	// minFingerprintLength is how long a normalized description must be to
+	// be compared with others, so "Great bike, no issues" is not a duplicate
+	minFingerprintLength = 80
+)
+
+// Market is what the rest of the database says about a listing
+type Market struct {
+	// Median is the median price of the listing's model and Comps how many
+	// listings it was computed from
+	Median float64
+	Comps  int
+	// Duplicates is how many listings by other sellers share the listing's
+	// description
+	Duplicates int
+}
+
+// Assessment is how likely a listing is to be a scam and why
+type Assessment struct {
+	// Score is from 0, nothing suspicious, to 1
+	Score   float64
+	Reasons []string
+}
+
+// Suspected reports whether the score reaches Threshold
+func (a Assessment) Suspected() bool {
+	return a.Score >= Threshold
+}
+
+func (a *Assessment) add(score float64, reason string, args ...interface{}) {
+	a.Score = math.Min(1, a.Score+score)
+	a.Reasons = append(a.Reasons, fmt.Sprintf(reason, args...))
+}
+
+var (
+	// scamPhrases are payment methods and excuses common to scams and rare
+	// in honest listings
+	scamPhrases  = regexp.MustCompile(`(?i)\b(western union|money ?gram|wire transfer|gift cards?|friends (?:and|&) family|whats ?app|deployed|out of the country|shipping only|ship only)\b`)
+	emailPattern = regexp.MustCompile(`[\w.+-]+@[\w-]+(?:\.[\w-]+)+`)
+	// firstPerson is how sellers write about their own bike
+	firstPerson = regexp.MustCompile(`(?i)\b(i|i'm|i've|my|me|we|our)\b`)
+	// catalogTerms are the marketing words of manufacturer copy
+	catalogTerms = regexp.MustCompile(`(?i)\b(engineered|featuring|designed to|all-new|key features|specifications|boasts|unmatched|unrivaled|cutting-edge)\b`)
+	nonWord      = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Assess scores a listing on signs of a scam: a price far below its model's
+// median, payment methods scammers ask for, a description copied from a
+// catalog rather than written by the owner, and a description shared with
+// other sellers' listings
+func Assess(l listing.Listing, m Market) Assessment {
+	var a Assessment
+
+	price, err := strconv.ParseFloat(l.Price, 64)
+	if err == nil && price > 0 && m.Median > 0 && m.Comps >= minComparables {
+		under := (m.Median - price) / m.Median
+		switch {
+		case under >= 0.65:
+			a.add(0.6, "priced %.0f%% under the $%.0f median", under*100, m.Median)
+		case under >= 0.5:
+			a.add(0.3, "priced %.0f%% under the $%.0f median", under*100, m.Median)
+		}
+	}
+
+	description := l.Details.Description
+	if phrase := scamPhrases.FindString(description); phrase != "" {
+		a.add(0.4, "description mentions %q", strings.ToLower(phrase))
+	}
+	if emailPattern.MatchString(description) {
+		a.add(0.2, "description gives an email address")
+	}
+	if len(description) >= 200 && !firstPerson.MatchString(description) && len(catalogTerms.FindAllString(description, -1)) >= 2 {
+		a.add(0.3, "description reads like catalog copy")
+	}
+	if m.Duplicates > 0 {
+		a.add(0.5, "description matches %d listings by other sellers", m.Duplicates)
+	}
+	return a
+}
+
+// Fingerprint identifies a description regardless of case, punctuation and
+// spacing, so reposted copies match. It is empty for descriptions too short
+// to tell apart.
+func Fingerprint(description string) string {
+	normalized := strings.TrimSpace(nonWord.ReplaceAllString(strings.ToLower(description), " "))
+	if len(normalized) < minFingerprintLength {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,61 @@
+package fraud
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pinkbike-scraper/pkg/listing"
+)
+
+func withDescription(price, description string) listing.Listing {
+	return listing.Listing{Price: price}.WithDetails(listing.ListingDetails{Description: description})
+}
+
+func TestHonestListing(t *testing.T) {
+	l := withDescription("3900", "Selling my Megatower, I rode it two seasons. New chain and tires, I can send more photos.")
+	a := Assess(l, Market{Median: 4200, Comps: 12})
+	assert.Zero(t, a.Score)
+	assert.Empty(t, a.Reasons)
+	assert.False(t, a.Suspected())
+}
+
+func TestUnderpriced(t *testing.T) {
+	a := Assess(listing.Listing{Price: "1200"}, Market{Median: 4000, Comps: 8})
+	assert.True(t, a.Suspected())
+	assert.Equal(t, []string{"priced 70% under the $4000 median"}, a.Reasons)
+
+	a = Assess(listing.Listing{Price: "1800"}, Market{Median: 4000, Comps: 8})
+	assert.Equal(t, 0.3, a.Score, "a steep discount alone is not enough")
+
+	a = Assess(listing.Listing{Price: "1200"}, Market{Median: 4000, Comps: 2})
+	assert.Zero(t, a.Score, "too few comps to judge the price")
+}
+
+func TestDescriptionSigns(t *testing.T) {
+	a := Assess(withDescription("3900", "Bike is in storage while I am deployed, payment by Western Union, email scam@example.com"), Market{})
+	assert.True(t, a.Suspected())
+	assert.Equal(t, []string{`description mentions "deployed"`, "description gives an email address"}, a.Reasons)
+
+	catalog := "The all-new enduro platform is engineered for the steepest tracks, featuring a 170mm flip-chip " +
+		"suspension layout and boasts progressive geometry that gives unmatched confidence at speed on every descent."
+	a = Assess(withDescription("3900", catalog), Market{})
+	assert.Equal(t, []string{"description reads like catalog copy"}, a.Reasons)
+
+	a = Assess(withDescription("3900", catalog), Market{Duplicates: 2})
+	assert.True(t, a.Suspected())
+	assert.Contains(t, a.Reasons, "description matches 2 listings by other sellers")
+
+	a = Assess(withDescription("900", "Wire transfer only, shipping only. "+catalog), Market{Median: 4000, Comps: 5, Duplicates: 3})
+	assert.Equal(t, 1.0, a.Score, "the score is capped")
+}
+
+func TestFingerprint(t *testing.T) {
+	description := "Selling my 2021 Santa Cruz Megatower, ridden two seasons, fresh fork service and new tires this spring."
+	reposted := strings.ToUpper(strings.ReplaceAll(description, ", ", " - "))
+	assert.NotEmpty(t, Fingerprint(description))
+	assert.Equal(t, Fingerprint(description), Fingerprint(reposted))
+	assert.NotEqual(t, Fingerprint(description), Fingerprint(description+" Size large."))
+	assert.Empty(t, Fingerprint("Great bike, no issues."))
+}
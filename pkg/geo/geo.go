@@ -0,0 +1,61 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const earthRadiusKM = 6371.0
+
+// Point is a position in decimal degrees. The zero Point stands for an
+// unknown position, as no Pinkbike seller is off the coast of West Africa.
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// IsZero reports whether p is the unknown position
+func (p Point) IsZero() bool {
+	return p.Lat == 0 && p.Lon == 0
+}
+
+// DistanceKM is the great-circle distance between p and q in kilometres
+func (p Point) DistanceKM(q Point) float64 {
+	lat1, lat2 := radians(p.Lat), radians(q.Lat)
+	dLat, dLon := lat2-lat1, radians(q.Lon-p.Lon)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKM * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// FormatKM writes a distance in whole kilometres
+func FormatKM(km float64) string {
+	return strconv.FormatFloat(km, 'f', 0, 64)
+}
+
+func (p Point) String() string {
+	return fmt.Sprintf("%.4f,%.4f", p.Lat, p.Lon)
+}
+
+// ParsePoint reads a "latitude,longitude" pair such as "51.05,-114.07". ok
+// is false for anything else, such as a place name to geocode.
+func ParsePoint(s string) (Point, bool) {
+	lat, lon, found := strings.Cut(s, ",")
+	if !found {
+		return Point{}, false
+	}
+	p := Point{}
+	var err error
+	if p.Lat, err = strconv.ParseFloat(strings.TrimSpace(lat), 64); err != nil || math.Abs(p.Lat) > 90 {
+		return Point{}, false
+	}
+	if p.Lon, err = strconv.ParseFloat(strings.TrimSpace(lon), 64); err != nil || math.Abs(p.Lon) > 180 {
+		return Point{}, false
+	}
+	return p, true
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
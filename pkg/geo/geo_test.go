@@ -0,0 +1,135 @@
+package geo
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"pinkbike-scraper/pkg/cache"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	calgary   = Point{Lat: 51.0447, Lon: -114.0719}
+	vancouver = Point{Lat: 49.2827, Lon: -123.1207}
+)
+
+func TestDistanceKM(t *testing.T) {
+	assert.InDelta(t, 675, calgary.DistanceKM(vancouver), 5)
+	assert.InDelta(t, calgary.DistanceKM(vancouver), vancouver.DistanceKM(calgary), 1e-9)
+	assert.Zero(t, calgary.DistanceKM(calgary))
+}
+
+func TestParsePoint(t *testing.T) {
+	p, ok := ParsePoint(" 51.0447, -114.0719 ")
+	require.True(t, ok)
+	assert.Equal(t, calgary, p)
+
+	for _, s := range []string{"Calgary, AB", "51.0447", "91,0", "0,181", ""} {
+		_, ok := ParsePoint(s)
+		assert.False(t, ok, s)
+	}
+}
+
+func TestLoadPlaces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "places.csv")
+	require.NoError(t, os.WriteFile(path, []byte("place,lat,lon\n\"Calgary, AB\",51.0447,-114.0719\n"), 0644))
+
+	places, err := LoadPlaces(path)
+	require.NoError(t, err)
+	p, err := places.Geocode("calgary,  ab")
+	require.NoError(t, err)
+	assert.Equal(t, calgary, p)
+	_, err = places.Geocode("Atlantis")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, os.WriteFile(path, []byte("Calgary,51.0447,-114.0719\nBanff,north,west\n"), 0644))
+	_, err = LoadPlaces(path)
+	assert.ErrorContains(t, err, "line 2")
+}
+
+type countingGeocoder struct {
+	Places
+	calls int
+	err   error
+}
+
+func (g *countingGeocoder) Geocode(place string) (Point, error) {
+	g.calls++
+	if g.err != nil {
+		return Point{}, g.err
+	}
+	return g.Places.Geocode(place)
+}
+
+func TestCached(t *testing.T) {
+	store := cache.NewMemory()
+	inner := &countingGeocoder{Places: Places{"calgary, ab": calgary}}
+	geocoder := Cached(inner, &cache.Cache{Store: store})
+
+	for _, place := range []string{"Calgary, AB", "CALGARY,AB"} {
+		p, err := geocoder.Geocode(place)
+		require.NoError(t, err)
+		assert.Equal(t, calgary, p)
+	}
+	for i := 0; i < 2; i++ {
+		_, err := geocoder.Geocode("Atlantis")
+		assert.ErrorIs(t, err, ErrNotFound)
+	}
+	assert.Equal(t, 2, inner.calls, "places are looked up once, found or not")
+
+	inner.err = errors.New("connection refused")
+	_, err := geocoder.Geocode("Banff")
+	assert.Error(t, err)
+	_, cached, _ := store.CachedValue("geocode/banff")
+	assert.False(t, cached, "failed lookups are not cached")
+
+	entry, _, _ := store.CachedValue("geocode/atlantis")
+	assert.False(t, entry.ExpiresAt.IsZero(), "places not found are looked up again later")
+
+	offline := Cached(inner, &cache.Cache{Store: store, Offline: true})
+	p, err := offline.Geocode("calgary, ab")
+	require.NoError(t, err)
+	assert.Equal(t, calgary, p)
+	_, err = offline.Geocode("Banff")
+	assert.ErrorIs(t, err, cache.ErrOffline)
+}
+
+func TestNominatim(t *testing.T) {
+	var agents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents = append(agents, r.UserAgent())
+		if r.URL.Query().Get("q") == "Calgary, AB" {
+			w.Write([]byte(`[{"lat": "51.0447", "lon": "-114.0719", "display_name": "Calgary"}]`))
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	n := NewNominatim("pinkbike-scraper test")
+	n.URL, n.Interval = server.URL, 0
+
+	p, err := n.Geocode("Calgary, AB")
+	require.NoError(t, err)
+	assert.Equal(t, calgary, p)
+	_, err = n.Geocode("Atlantis")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, []string{"pinkbike-scraper test", "pinkbike-scraper test"}, agents)
+}
+
+func TestParsePlace(t *testing.T) {
+	p, err := ParsePlace("49.2827,-123.1207", nil)
+	require.NoError(t, err)
+	assert.Equal(t, vancouver, p)
+
+	_, err = ParsePlace("Vancouver", nil)
+	assert.ErrorContains(t, err, "no geocoder")
+	_, err = ParsePlace("Atlantis", Places{})
+	assert.ErrorContains(t, err, `could not find "Atlantis"`)
+}
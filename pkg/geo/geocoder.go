@@ -0,0 +1,214 @@
+package geo
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"pinkbike-scraper/pkg/cache"
+)
+
+// nominatimURL is OpenStreetMap's public geocoder, which asks for at most
+// one request a second and a user agent naming the application
+const nominatimURL = "https://nominatim.openstreetmap.org/search"
+
+// ErrNotFound is returned for places a geocoder does not know
+var ErrNotFound = errors.New("place not found")
+
+// Geocoder finds the position of a place name such as "Squamish, British
+// Columbia, Canada"
+type Geocoder interface {
+	Geocode(place string) (Point, error)
+}
+
+// Nominatim geocodes with a Nominatim server, by default OpenStreetMap's.
+// Requests are spaced out by Interval to honour its usage policy.
+type Nominatim struct {
+	URL       string
+	UserAgent string
+	Interval  time.Duration
+	Client    *http.Client
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// NewNominatim returns a geocoder for OpenStreetMap's Nominatim server,
+// identifying itself as userAgent
+func NewNominatim(userAgent string) *Nominatim {
+	return &Nominatim{
+		URL:       nominatimURL,
+		UserAgent: userAgent,
+		Interval:  time.Second,
+		Client:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (n *Nominatim) Geocode(place string) (Point, error) {
+	n.wait()
+
+	query := url.Values{"q": {place}, "format": {"jsonv2"}, "limit": {"1"}}
+	req, err := http.NewRequest(http.MethodGet, n.URL+"?"+query.Encode(), nil)
+	if err != nil {
+		return Point{}, err
+	}
+	req.Header.Set("User-Agent", n.UserAgent)
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return Point{}, fmt.Errorf("could not geocode %q: %w", place, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return Point{}, fmt.Errorf("could not geocode %q: %s: %s", place, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var results []struct{ Lat, Lon string }
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return Point{}, fmt.Errorf("could not decode geocoding response for %q: %w", place, err)
+	}
+	if len(results) == 0 {
+		return Point{}, ErrNotFound
+	}
+	p, ok := ParsePoint(results[0].Lat + "," + results[0].Lon)
+	if !ok {
+		return Point{}, fmt.Errorf("invalid position %s,%s for %q", results[0].Lat, results[0].Lon, place)
+	}
+	return p, nil
+}
+
+// wait blocks until Interval has passed since the last request
+func (n *Nominatim) wait() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if wait := n.Interval - time.Since(n.last); wait > 0 {
+		time.Sleep(wait)
+	}
+	n.last = time.Now()
+}
+
+// Places geocodes from a fixed list of places, for offline runs and tests
+type Places map[string]Point
+
+// LoadPlaces reads a CSV file of place, latitude and longitude rows. A header
+// row is skipped.
+func LoadPlaces(path string) (Places, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read places: %w", err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 3
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("could not parse places %s: %w", path, err)
+	}
+
+	places := Places{}
+	for i, record := range records {
+		p, ok := ParsePoint(record[1] + "," + record[2])
+		if !ok {
+			if i == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("places %s line %d: invalid position %s,%s", path, i+1, record[1], record[2])
+		}
+		places[normalize(record[0])] = p
+	}
+	return places, nil
+}
+
+func (p Places) Geocode(place string) (Point, error) {
+	if point, ok := p[normalize(place)]; ok {
+		return point, nil
+	}
+	return Point{}, ErrNotFound
+}
+
+// notFoundTTL is how long places a geocoder did not find are cached before
+// they are looked up again, in case it learns of them
+const notFoundTTL = 30 * 24 * time.Hour
+
+// cachedPlace is how a geocoded place is cached
+type cachedPlace struct {
+	Lat   float64 `json:"lat"`
+	Lon   float64 `json:"lon"`
+	Found bool    `json:"found"`
+}
+
+type cachedGeocoder struct {
+	geocoder Geocoder
+	cache    *cache.Cache
+}
+
+// Cached looks places up in c before asking geocoder, and caches what
+// geocoder answers. Places it did not find are cached too, for a while, so
+// they are not looked up on every run. Place names are matched ignoring case
+// and spacing.
+func Cached(geocoder Geocoder, c *cache.Cache) Geocoder {
+	return cachedGeocoder{geocoder: geocoder, cache: c}
+}
+
+func (c cachedGeocoder) Geocode(place string) (Point, error) {
+	key := normalize(place)
+	if key == "" {
+		return Point{}, ErrNotFound
+	}
+	value, err := c.cache.Get("geocode/"+key, func() ([]byte, time.Duration, error) {
+		p, err := c.geocoder.Geocode(place)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, 0, err
+		}
+		var ttl time.Duration
+		if err != nil {
+			ttl = notFoundTTL
+		}
+		value, err := json.Marshal(cachedPlace{p.Lat, p.Lon, err == nil})
+		return value, ttl, err
+	})
+	if err != nil {
+		return Point{}, err
+	}
+
+	var cached cachedPlace
+	if err := json.Unmarshal(value, &cached); err != nil {
+		return Point{}, fmt.Errorf("invalid cached position of %q: %w", place, err)
+	}
+	if !cached.Found {
+		return Point{}, ErrNotFound
+	}
+	return Point{Lat: cached.Lat, Lon: cached.Lon}, nil
+}
+
+// ParsePlace returns the position s names, either as "latitude,longitude"
+// or as a place name looked up with geocoder
+func ParsePlace(s string, geocoder Geocoder) (Point, error) {
+	if p, ok := ParsePoint(s); ok {
+		return p, nil
+	}
+	if geocoder == nil {
+		return Point{}, fmt.Errorf("no geocoder to look up %q, give it as latitude,longitude", s)
+	}
+	p, err := geocoder.Geocode(s)
+	if errors.Is(err, ErrNotFound) {
+		return Point{}, fmt.Errorf("could not find %q", s)
+	}
+	return p, err
+}
+
+// normalize lowercases a place name and collapses its spacing, so
+// "Calgary,  AB" and "calgary, ab" are the same place
+func normalize(place string) string {
+	return strings.ToLower(strings.Join(strings.Fields(strings.ReplaceAll(place, ",", ", ")), " "))
+}
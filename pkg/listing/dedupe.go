@@ -0,0 +1,42 @@
+package listing
+
+import "strings"
+
+// duplicateKey identifies the same listing seen twice, such as a listing that
+// shows up on two pages when new listings push it down mid scrape, possibly
+// edited or repriced in between. Listings with a URL are identified by their
+// fingerprint; without one, by the bike they describe with the frame or wheel
+// size spelled either way.
+func (l Listing) duplicateKey() string {
+	if l.ListingID != 0 || strings.TrimSpace(l.URL) != "" {
+		return l.Fingerprint()
+	}
+	size := l.NormalizedSize
+	if size == "" {
+		size = strings.ToLower(l.FrameSize)
+	}
+	return strings.Join([]string{
+		strings.ToLower(strings.TrimSpace(l.Title)),
+		l.Year,
+		l.Manufacturer,
+		l.Model,
+		size,
+		l.Wheels.String(),
+	}, "|")
+}
+
+// Dedupe drops later copies of listings seen more than once, keeping the
+// first one seen
+func Dedupe(listings []Listing) []Listing {
+	seen := make(map[string]bool, len(listings))
+	unique := make([]Listing, 0, len(listings))
+	for _, l := range listings {
+		key := l.duplicateKey()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, l)
+	}
+	return unique
+}
@@ -4,23 +4,95 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
-	"math"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
+
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/geo"
+	"pinkbike-scraper/pkg/parser"
 )
 
 type RawListing struct {
 	Title, Price, Condition, FrameSize, WheelSize, FrameMaterial, FrontTravel, RearTravel, URL, DetailsLink string
+	// Location is where the seller is, such as "Squamish, British Columbia,
+	// Canada"
+	Location string
 }
 
 type Listing struct {
-	Title, Year, Manufacturer, Model, Price, Currency, Condition                         string
-	FrameSize, WheelSize, FrameMaterial, FrontTravel, RearTravel, NeedsReview, URL, Hash string
-	FirstSeen, LastSeen                                                                  time.Time
-	Active                                                                               bool
-	Details                                                                              ListingDetails
+	Title, Year, Manufacturer, Model, Price, Currency, Condition            string
+	FrameSize, WheelSize, FrameMaterial, FrontTravel, RearTravel, URL, Hash string
+	// NeedsReview are the reasons the listing failed validation, such as
+	// "year" when none was found
+	NeedsReview         Reasons
+	FirstSeen, LastSeen time.Time
+	Active              bool
+	// Warnings are the reasons of the warn validation rules the listing
+	// failed; unlike review reasons they are not stored
+	Warnings []string
+	// IsElectric marks e-bikes, which are summarized apart from other bikes
+	IsElectric bool
+	// NormalizedSize is the frame size on the canonical XXS to XXL scale
+	NormalizedSize string
+	// Generation is the version of Model the title names, such as V2 for a
+	// "Ripmo V2", so generations that sell for very different prices are
+	// priced apart. It is empty when the title names none.
+	Generation string
+	// Wheels is the wheel configuration read from WheelSize, which keeps the
+	// size as the seller gave it
+	Wheels parser.Wheels
+	// ConditionGrade ranks Condition so listings can be compared by it
+	ConditionGrade parser.ConditionGrade
+	// BuildTier is the build kit the title names, or the description when the
+	// title names none, such as "GX" or "S-Works", ranked so listings of a
+	// model built up differently can be compared
+	BuildTier parser.BuildTier
+	// Category is the bike type the listing was scraped under, such as enduro
+	Category string
+	// InferredYears are the model years the components and model allow when
+	// the title gives no year, recorded as inferred in Metadata. Year is left
+	// empty, so medians and the hash only use years sellers gave.
+	InferredYears parser.YearRange
+	// Kind is whether the listing sells a complete bike, a frame, a fork,
+	// wheels or other parts
+	Kind parser.Kind
+	// ShockSize is the rear shock size of a frame or shock, such as 230x65,
+	// and SteererLength the steerer length of a fork in millimetres
+	ShockSize     string
+	SteererLength int
+	// Negotiable marks prices the seller is open to offers on, such as "OBO"
+	Negotiable bool
+	// PriceDropAdvertised marks titles announcing a lowered price, such as
+	// "PRICE DROP" or a struck through old price
+	PriceDropAdvertised bool
+	// ListedPrice is the asking price in Currency, as the seller listed it.
+	// Price holds it converted to PriceCurrency.
+	ListedPrice string
+	// PriceCurrency is the currency Price is in, USD when empty. Prices are
+	// stored in USD and only converted to other currencies for reporting.
+	PriceCurrency string
+	// ExchangeRate is the CAD to USD rate a CAD price was converted at, zero
+	// for prices that were not converted
+	ExchangeRate float64
+	// PredictedPrice is the USD price a trained price model predicts for the
+	// listing, zero when no model was run
+	PredictedPrice float64
+	// FraudScore is how likely the listing is a scam, from 0 to 1, as scored
+	// when it was stored
+	FraudScore float64
+	// ListingID is Pinkbike's ID for the listing, taken from its URL. Unlike
+	// the hash it survives the seller editing the title or specs; zero when
+	// the URL is not a Pinkbike listing.
+	ListingID int
+	Details   ListingDetails
+	// Location is where the seller is, as Pinkbike shows it, and Coordinates
+	// its geocoded position, zero until it is geocoded
+	Location    string
+	Coordinates geo.Point
+	// Metadata records how each field was derived, so low-confidence rows can
+	// be weighted or excluded downstream
+	Metadata Metadata
 }
 
 type ListingDetails struct {
@@ -28,6 +100,47 @@ type ListingDetails struct {
 	OriginalPostDate time.Time
 	Description      string
 	Restrictions     string
+	// Phone is the seller's phone number, only shown to signed in accounts
+	Phone string
+	// Motor and BatteryWh are read from the description of e-bikes
+	Motor     string
+	BatteryWh int
+	// OriginalPrice is the retail price quoted in the description, in its
+	// currency or the listing's when it names none
+	OriginalPrice parser.Price
+	// Usage is how far and how long the bike has been ridden, read from the
+	// description
+	Usage parser.Usage
+	// PhotoCount is how many photos the listing has
+	PhotoCount int
+	// ViewCount is how often the detail page was viewed when it was scraped
+	ViewCount int
+	// Seller is the seller's Pinkbike username and SellerURL their profile
+	Seller, SellerURL string
+}
+
+// ViewsPerDay is how many views the listing gathered per day between its
+// original post date and at, a measure of demand. It is zero when the view
+// count or post date is unknown.
+func (d ListingDetails) ViewsPerDay(at time.Time) float64 {
+	if d.ViewCount == 0 || d.OriginalPostDate.IsZero() {
+		return 0
+	}
+	days := at.Sub(d.OriginalPostDate).Hours() / 24
+	// a listing posted today has not had a full day of views yet
+	if days < 1 {
+		days = 1
+	}
+	return float64(d.ViewCount) / days
+}
+
+// DistanceKM is how far the seller is from from, in kilometres. ok is false
+// when the listing's location or from is unknown.
+func (l Listing) DistanceKM(from geo.Point) (km float64, ok bool) {
+	if l.Coordinates.IsZero() || from.IsZero() {
+		return 0, false
+	}
+	return l.Coordinates.DistanceKM(from), true
 }
 
 type SellerType string
@@ -50,125 +163,296 @@ func (l RawListing) Print() string {
 		l.Title, l.Price, l.Condition, l.FrameSize, l.WheelSize, l.FrontTravel, l.RearTravel, l.FrameMaterial, l.URL)
 }
 
-func (l RawListing) PostProcess(exchangeRate float64) Listing {
+// PostProcess parses a scraped listing, converting CAD prices to USD at
+// exchangeRate. Model years after the year clk is in are implausible; a nil
+// clk is the system clock.
+func (l RawListing) PostProcess(exchangeRate float64, clk clock.Clock) Listing {
+	price := parser.ParsePrice(l.Price)
 	newL := Listing{
 		Title:         strings.ReplaceAll(l.Title, "\n", ""),
-		Year:          extractYear(l.Title),
-		Manufacturer:  extractManufacturer(l.Title),
-		Model:         extractModel(l.Title),
-		Currency:      extractCurrency(l.Price),
-		Price:         convertPrice(l.Price, extractCurrency(l.Price), exchangeRate),
+		Year:          parser.ExtractYear(l.Title),
+		Manufacturer:  parser.ExtractManufacturer(l.Title),
+		Model:         parser.ExtractModel(l.Title),
+		Currency:      price.Currency,
+		Price:         price.USD(exchangeRate),
+		ListedPrice:   price.Listed(),
+		ExchangeRate:  cadRate(price, exchangeRate),
+		Negotiable:    price.Negotiable,
 		Condition:     l.Condition,
 		FrameSize:     l.FrameSize,
-		WheelSize:     l.WheelSize,   //todo: convert to float - remove 650B
+		WheelSize:     l.WheelSize,
 		FrontTravel:   l.FrontTravel, //todo: remove mm
 		RearTravel:    l.RearTravel,  //todo: remove mm
 		FrameMaterial: l.FrameMaterial,
-		URL:           l.URL,
+		Location:      l.Location,
+		URL:           parser.CanonicalURL(l.URL),
+		ListingID:     parser.ExtractListingID(l.URL),
+		Metadata:      Metadata{},
 	}
+	newL.Generation = parser.ExtractGeneration(newL.Manufacturer, newL.Model, newL.Title)
+	newL.IsElectric = parser.IsElectric(newL.Title, newL.Model)
+	newL.PriceDropAdvertised = parser.AdvertisesPriceDrop(newL.Title)
+	newL.NormalizedSize = (*parser.Sizes)(nil).Normalize(newL.Manufacturer, newL.FrameSize, newL.Title)
+	newL.Wheels = parser.ParseWheels(newL.WheelSize, newL.Title)
+	newL.ConditionGrade = parser.ParseCondition(newL.Condition)
+	newL.BuildTier = parser.ExtractBuildTier(newL.Manufacturer, newL.Title)
+	newL.Kind = parser.DetectKind(newL.Title)
+	newL = newL.extractKindSpecs(newL.Title)
 
-	if reason := validateListing(newL); reason != "" {
-		newL.NeedsReview = reason
-	}
+	newL.Metadata.derive("year", newL.Year, SourceRegex)
+	newL.Metadata.checkYear(newL.Year, clock.Or(clk).Now())
+	newL.Metadata.derive("manufacturer", newL.Manufacturer, SourceModelDB)
+	newL.Metadata.derive("model", newL.Model, SourceModelDB)
+	newL.Metadata.derive("price", newL.Price, SourceRegex)
+	newL.Metadata.derive("currency", newL.Currency, SourceRegex)
+	newL.Metadata.fill(newL, SourceScraped)
+	newL = newL.inferYears(newL.Title)
+
+	newL.NeedsReview = validateListing(newL, DefaultProfile)
 
 	return newL
 }
 
-func validateListing(l Listing) string {
-	if l.Price == "" || l.Price == "0" {
-		return "price"
+// Reprice converts a price listed in CAD to USD at cadToUSD instead of the
+// rate it was converted at, such as the rate on the day it was posted.
+// Other listings are returned as they are.
+func (l Listing) Reprice(cadToUSD float64) Listing {
+	if l.Currency != "CAD" || l.ListedPrice == "" {
+		return l
+	}
+	amount, err := strconv.ParseFloat(l.ListedPrice, 64)
+	if err != nil {
+		return l
+	}
+	l.Price = parser.Price{Amount: amount, Currency: l.Currency}.USD(cadToUSD)
+	l.ExchangeRate = cadToUSD
+	return l
+}
+
+// Residual is how far the listing's price is above the price model's
+// prediction, negative for listings priced under it. ok is false when either
+// price is unknown.
+func (l Listing) Residual() (float64, bool) {
+	price, err := strconv.ParseFloat(l.Price, 64)
+	if err != nil || l.PredictedPrice <= 0 {
+		return 0, false
+	}
+	return price - l.PredictedPrice, true
+}
+
+// cadRate is the rate price is converted to USD at, zero unless it is in CAD
+func cadRate(price parser.Price, cadToUSD float64) float64 {
+	if price.Currency != "CAD" || price.Amount == 0 {
+		return 0
 	}
+	return cadToUSD
+}
+
+// Revalidate fills fields derivable from the title when they are missing, then
+// recomputes the review reason and hash the same way PostProcess would at clk
+func (l Listing) Revalidate(clk clock.Clock) Listing {
+	l.Metadata = l.Metadata.clone()
 	if l.Year == "" {
-		return "year"
+		l.Year = parser.ExtractYear(l.Title)
+		l.Metadata.derive("year", l.Year, SourceRegex)
+		l.Metadata.checkYear(l.Year, clock.Or(clk).Now())
 	}
-	if l.Manufacturer == "NoManufacturer" || l.Manufacturer == "" {
-		return "manufacturer"
+	if l.Manufacturer == "" {
+		l.Manufacturer = parser.ExtractManufacturer(l.Title)
+		l.Metadata.derive("manufacturer", l.Manufacturer, SourceModelDB)
 	}
-	if l.Model == "NoModelFound" || strings.Contains(l.Model, "Electric") || l.Model == "" {
-		return "model"
+	if l.Model == "" {
+		l.Model = parser.ExtractModel(l.Title)
+		l.Metadata.derive("model", l.Model, SourceModelDB)
 	}
-	if l.Currency == "" {
-		return "currency"
+	if l.Generation == "" {
+		l.Generation = parser.ExtractGeneration(l.Manufacturer, l.Model, l.Title)
 	}
-	if l.Condition == "" {
-		return "condition"
+	l.Metadata.fill(l, SourceImported)
+	l.IsElectric = l.IsElectric || parser.IsElectric(l.Title, l.Model)
+	l.PriceDropAdvertised = l.PriceDropAdvertised || parser.AdvertisesPriceDrop(l.Title)
+	if l.NormalizedSize == "" {
+		l.NormalizedSize = (*parser.Sizes)(nil).Normalize(l.Manufacturer, l.FrameSize, l.Title)
 	}
-	if l.FrameSize == "" {
-		return "frame size"
+	if l.Wheels.IsZero() {
+		l.Wheels = parser.ParseWheels(l.WheelSize, l.Title)
 	}
-	if l.WheelSize == "" {
-		return "wheel size"
+	l.ConditionGrade = parser.ParseCondition(l.Condition)
+	if l.BuildTier.IsZero() {
+		l = l.extractBuildTier(l.Details.Description)
 	}
-	if l.FrontTravel == "" {
-		return "front travel"
+	l.URL = parser.CanonicalURL(l.URL)
+	if l.ListingID == 0 {
+		l.ListingID = parser.ExtractListingID(l.URL)
 	}
-	if l.RearTravel == "" {
-		return "rear travel"
+	if l.Kind == "" {
+		l.Kind = parser.DetectKind(l.Title)
 	}
-	if l.FrameMaterial == "" {
-		return "frame material"
+	l = l.extractKindSpecs(l.Title)
+	if l.InferredYears.IsZero() {
+		l = l.inferYears(l.Title + "\n" + l.Details.Description)
 	}
 
-	return ""
+	l.NeedsReview = validateListing(l, DefaultProfile)
+	l.Hash = l.ComputeHash()
+
+	return l
 }
 
-func extractYear(title string) string {
-	reg := regexp.MustCompile(`\d{4}`)
-	s := reg.FindString(title)
-	return s
+// Reparse derives a stored listing's fields again from the ones kept as
+// scraped (title, condition, sizes, travel, material and description) with
+// the current extraction logic. The price was converted when it was scraped
+// and is kept, as are fields a reviewer corrected. Years are checked as
+// PostProcess checks them at clk.
+func (l Listing) Reparse(clk clock.Clock) Listing {
+	raw := RawListing{
+		Title: l.Title, Price: l.Price, Condition: l.Condition, FrameSize: l.FrameSize, WheelSize: l.WheelSize,
+		FrameMaterial: l.FrameMaterial, FrontTravel: l.FrontTravel, RearTravel: l.RearTravel, URL: l.URL,
+	}
+	r := raw.PostProcess(1.0, clk)
+	r.Price, r.Currency, r.Negotiable = l.Price, l.Currency, l.Negotiable
+	for _, field := range []string{"price", "currency"} {
+		if meta, ok := l.Metadata[field]; ok {
+			r.Metadata[field] = meta
+		}
+	}
+
+	if l.Metadata["model"].Source == SourceCorrection {
+		r.Year, r.Manufacturer, r.Model = l.Year, l.Manufacturer, l.Model
+		r.Generation = parser.ExtractGeneration(r.Manufacturer, r.Model, r.Title)
+		for _, field := range []string{"year", "manufacturer", "model"} {
+			r.Metadata.Set(field, SourceCorrection)
+		}
+	}
+
+	r.Category, r.FirstSeen, r.LastSeen, r.Active = l.Category, l.FirstSeen, l.LastSeen, l.Active
+	details := l.Details
+	details.Motor, details.BatteryWh = "", 0
+	return r.WithDetails(details)
 }
 
-func extractCurrency(price string) string {
-	reg := regexp.MustCompile(`(CAD|USD)`)
-	return reg.FindString(price)
+// ApplyAliases resolves a manufacturer or model the model database could not
+// find using aliases learned from reviewed listings, then updates the review reason
+func (l Listing) ApplyAliases(aliases *parser.Aliases) Listing {
+	if l.Manufacturer == "NoManufacturer" || l.Manufacturer == "" {
+		if manufacturer := aliases.Manufacturer(l.Title); manufacturer != "" {
+			l.Manufacturer = manufacturer
+			l.Metadata = l.Metadata.clone()
+			l.Metadata.Set("manufacturer", SourceAlias)
+		}
+	}
+	if l.Model == "NoModelFound" || l.Model == "" {
+		if model := aliases.Model(l.Manufacturer, l.Title); model != "" {
+			l.Model = model
+			l.Generation = parser.ExtractGeneration(l.Manufacturer, l.Model, l.Title)
+			l.Metadata = l.Metadata.clone()
+			l.Metadata.Set("model", SourceAlias)
+		}
+	}
+
+	l.NeedsReview = validateListing(l, DefaultProfile)
+	return l
+}
+
+// ApplySizes normalizes the frame size with configured brand sizing schemes
+func (l Listing) ApplySizes(sizes *parser.Sizes) Listing {
+	l.NormalizedSize = sizes.Normalize(l.Manufacturer, l.FrameSize, l.Title)
+	return l
 }
 
-func convertPrice(price, currency string, exchangeRate float64) string {
-	p := extractPrice(price)
+// RiderHeight returns the rider heights in cm the normalized size usually
+// fits, both zero when the size is unknown
+func (l Listing) RiderHeight() (int, int) {
+	size, _ := parser.SizeByName(l.NormalizedSize)
+	return size.MinHeight, size.MaxHeight
+}
 
-	floatPrice, err := strconv.ParseFloat(p, 32)
-	if err != nil {
-		return ""
+// WithDetails sets the details scraped from the listing page. The motor and
+// battery of e-bikes are read from the description, and a listing whose
+// description names a drive unit is marked electric. So are the shock size
+// of frames and the steerer length of forks whose titles leave them out, and
+// the model years of bikes whose titles give none.
+func (l Listing) WithDetails(d ListingDetails) Listing {
+	if motor := parser.ExtractMotor(d.Description); motor != "" {
+		l.IsElectric = true
+		d.Motor = motor
 	}
-
-	if currency == "CAD" {
-		floatPrice = math.Round(floatPrice * exchangeRate)
-		p = fmt.Sprintf("%.0f", floatPrice)
+	if l.IsElectric {
+		d.BatteryWh = parser.ExtractBatteryWh(d.Description)
 	}
+	d.OriginalPrice = parser.ExtractOriginalPrice(d.Description)
+	d.Usage = parser.ExtractUsage(d.Description)
+	if d.OriginalPrice.Amount > 0 && d.OriginalPrice.Currency == "" {
+		d.OriginalPrice.Currency = l.Currency
+	}
+	l.Details = d
+	return l.extractKindSpecs(d.Description).extractBuildTier(d.Description).inferYears(l.Title + "\n" + d.Description)
+}
 
-	return p
+// extractBuildTier reads the build kit from the title, or from description
+// when the title names none
+func (l Listing) extractBuildTier(description string) Listing {
+	if tier := parser.ExtractBuildTier(l.Manufacturer, l.Title); !tier.IsZero() {
+		l.BuildTier = tier
+		return l
+	}
+	l.BuildTier = parser.ExtractBuildTier(l.Manufacturer, description)
+	return l
 }
 
-func extractPrice(price string) string {
-	reg := regexp.MustCompile(`[0-9,]+`)
-	res := reg.FindString(price)
-	return strings.ReplaceAll(res, ",", "")
+// inferYears estimates the model years of a listing whose title gives no year
+// from the components and model text names, keeping the years already
+// inferred when text narrows them no further
+func (l Listing) inferYears(text string) Listing {
+	if l.Year != "" {
+		return l
+	}
+	years, _ := parser.InferYears(l.Manufacturer, l.Model, l.Generation, text)
+	if years.IsZero() || years == l.InferredYears {
+		return l
+	}
+	l.InferredYears = years
+	l.Metadata = l.Metadata.clone()
+	l.Metadata.Set("inferred_years", SourceInferred)
+	return l
 }
 
-func extractManufacturer(title string) string {
-	for manufacturer := range bikeModels {
-		if strings.Contains(strings.ToLower(title), strings.ToLower(manufacturer)) {
-			return manufacturer
+// extractKindSpecs reads the specs only some kinds of listing have from text,
+// unless the title already gave them: the shock size of frames and shocks
+// and the steerer length of forks
+func (l Listing) extractKindSpecs(text string) Listing {
+	switch l.Kind {
+	case parser.KindFrame, parser.KindParts:
+		if l.ShockSize == "" {
+			l.ShockSize = parser.ExtractShockSize(text)
+		}
+	case parser.KindFork:
+		if l.SteererLength == 0 {
+			l.SteererLength = parser.ExtractSteererLength(text)
 		}
 	}
-	return "NoManufacturer"
+	return l
 }
 
-func extractModel(title string) string {
-	manufacturer := extractManufacturer(title)
-	bikes := bikeModels[manufacturer]
-
-	for _, model := range bikes {
-		if strings.Contains(strings.ToLower(title), strings.ToLower(model.Name)) {
-			if model.Purpose == Electric {
-				return model.Name + " Electric"
-			}
-			return model.Name
-		}
+// RetailPrice returns the listing's retail price in USD: its model year's MSRP
+// when msrps has one, otherwise the original price quoted in the description,
+// with CAD converted at cadToUSD
+func (l Listing) RetailPrice(msrps *parser.MSRPs, cadToUSD float64) (float64, bool) {
+	if price, ok := msrps.Lookup(l.Manufacturer, l.Model, l.Year); ok {
+		return price, true
 	}
-	return "NoModelFound"
+	price, err := strconv.ParseFloat(l.Details.OriginalPrice.USD(cadToUSD), 64)
+	return price, err == nil && price > 0
 }
 
+// Confidence is the mean confidence of the listing's fields
+func (l Listing) Confidence() float64 {
+	return l.Metadata.Confidence()
+}
+
+// ComputeHash is the strict hash of the listing's scraped title and specs.
+// Any edit changes it, so it tells whether a listing changed since it was
+// stored; Fingerprint tells which stored listing it is.
 func (l Listing) ComputeHash() string {
 	// Combine fields that would uniquely identify a bike listing
 	uniqueString := strings.Join([]string{
@@ -186,3 +470,35 @@ func (l Listing) ComputeHash() string {
 	hasher.Write([]byte(uniqueString))
 	return hex.EncodeToString(hasher.Sum(nil))
 }
+
+// Fingerprint identifies the listing across edits of its title: Pinkbike's
+// listing ID when its URL has one, otherwise a hash of its URL and the fields
+// extracted from the title, which rewording or fixing a typo in the title
+// leaves alone. Without a URL the extracted fields are too often shared by
+// different bikes, so the fingerprint is the strict hash.
+func (l Listing) Fingerprint() string {
+	id := l.ListingID
+	if id == 0 {
+		id = parser.ExtractListingID(l.URL)
+	}
+	if id != 0 {
+		return "pb:" + strconv.Itoa(id)
+	}
+	if strings.TrimSpace(l.URL) == "" {
+		return l.ComputeHash()
+	}
+
+	stableString := strings.Join([]string{
+		parser.CanonicalURL(l.URL),
+		strings.ToLower(l.Manufacturer),
+		strings.ToLower(l.Model),
+		l.Year,
+		strings.ToLower(l.FrameSize),
+		strings.ToLower(l.WheelSize),
+		strings.ToLower(l.FrameMaterial),
+	}, "|")
+
+	hasher := sha256.New()
+	hasher.Write([]byte(stableString))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
@@ -1,111 +1,31 @@
 package listing
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/parser"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func TestExtractManufacturer(t *testing.T) {
-	tests := []struct {
-		name string
-		arg  string
-		want string
-	}{
-		{"Manufacturer at start", "Specialized Bike Model", "Specialized"},
-		{"Manufacturer in middle", "Bike Specialized Model", "Specialized"},
-		{"No manufacturer", "Bike Model", "NoManufacturer"},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := extractManufacturer(tt.arg); got != tt.want {
-				t.Errorf("extractManufacturer() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
+func TestReprice(t *testing.T) {
+	l := RawListing{Title: "2018 Commencal Meta AM 4.2", Price: "$2,500 CAD"}.PostProcess(0.75, nil)
+	assert.Equal(t, "1875", l.Price)
+	assert.Equal(t, 0.75, l.ExchangeRate)
 
-func TestExtractYear(t *testing.T) {
-	tests := []struct {
-		name string
-		arg  string
-		want string
-	}{
-		{"Year at start", "2022 Bike Model", "2022"},
-		{"Year in middle", "Bike 2022 Model", "2022"},
-		{"No year", "Bike Model", ""},
-	}
+	l = l.Reprice(0.8)
+	assert.Equal(t, "2000", l.Price)
+	assert.Equal(t, "2500", l.ListedPrice)
+	assert.Equal(t, 0.8, l.ExchangeRate)
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := extractYear(tt.arg); got != tt.want {
-				t.Errorf("extractYear() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func TestExtractCurrency(t *testing.T) {
-	tests := []struct {
-		name string
-		arg  string
-		want string
-	}{
-		{"CAD", "1000 CAD", "CAD"},
-		{"USD", "1000 USD", "USD"},
-		{"No currency", "1000", ""},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := extractCurrency(tt.arg); got != tt.want {
-				t.Errorf("extractCurrency() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func TestExtractPrice(t *testing.T) {
-	tests := []struct {
-		name string
-		arg  string
-		want string
-	}{
-		{"Price with comma", "1,000 CAD", "1000"},
-		{"Price without comma", "1000 CAD", "1000"},
-		{"No price", "CAD", ""},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := extractPrice(tt.arg); got != tt.want {
-				t.Errorf("extractPrice() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func TestConvertPrice(t *testing.T) {
-	tests := []struct {
-		name         string
-		price        string
-		currency     string
-		exchangeRate float64
-		want         string
-	}{
-		{"Price in CAD to CAD", "1000", "CAD", 1.0, "1000"},
-		{"Price in CAD to USD with exchange rate 0.75", "1000", "CAD", 0.75, "750"},
-		{"Price with comma in CAD to USD", "1,000", "CAD", 0.75, "750"},
-		{"Invalid price format", "one thousand", "CAD", 0.75, ""},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := convertPrice(tt.price, tt.currency, tt.exchangeRate)
-			assert.Equal(t, tt.want, got)
-		})
-	}
+	usd := RawListing{Title: "2021 Evil Wreckoning", Price: "$3,900 USD"}.PostProcess(0.75, nil)
+	assert.Zero(t, usd.ExchangeRate)
+	assert.Equal(t, usd, usd.Reprice(0.8))
 }
 
 func TestPostProcess(t *testing.T) {
@@ -127,18 +47,23 @@ func TestPostProcess(t *testing.T) {
 				FrameMaterial: "Carbon Fiber",
 			},
 			Listing{
-				Title:         "2024 Transition Spire AXS T-Type Fox Factory Reserve Wheels",
-				Price:         "5300",
-				Year:          "2024",
-				Manufacturer:  "Transition",
-				Model:         "Spire",
-				Currency:      "USD",
-				Condition:     "Excellent - Lightly Ridden",
-				FrameSize:     "L",
-				WheelSize:     "29",
-				FrontTravel:   "170 mm",
-				RearTravel:    "170 mm",
-				FrameMaterial: "Carbon Fiber",
+				Title:          "2024 Transition Spire AXS T-Type Fox Factory Reserve Wheels",
+				Price:          "5300",
+				ListedPrice:    "5300",
+				Year:           "2024",
+				Manufacturer:   "Transition",
+				Model:          "Spire",
+				Currency:       "USD",
+				Condition:      "Excellent - Lightly Ridden",
+				FrameSize:      "L",
+				NormalizedSize: "L",
+				ConditionGrade: parser.ConditionExcellent,
+				Kind:           parser.KindBike,
+				WheelSize:      "29",
+				Wheels:         parser.Wheels{Front: 29, Rear: 29},
+				FrontTravel:    "170 mm",
+				RearTravel:     "170 mm",
+				FrameMaterial:  "Carbon Fiber",
 			},
 		},
 		{
@@ -154,26 +79,436 @@ func TestPostProcess(t *testing.T) {
 				FrameMaterial: "Aluminum",
 			},
 			Listing{
-				Title:         "2018 Commencal Meta AM 4.2 World Cup Edition",
-				Price:         "2550",
-				Year:          "2018",
-				Manufacturer:  "Commencal",
-				Model:         "Meta AM",
-				Currency:      "CAD",
+				Title:          "2018 Commencal Meta AM 4.2 World Cup Edition",
+				Price:          "2550",
+				ListedPrice:    "2550",
+				ExchangeRate:   1,
+				Year:           "2018",
+				Manufacturer:   "Commencal",
+				Model:          "Meta AM",
+				Currency:       "CAD",
+				Condition:      "Good - Used, Mechanically Sound",
+				FrameSize:      "M",
+				NormalizedSize: "M",
+				ConditionGrade: parser.ConditionGood,
+				Kind:           parser.KindBike,
+				WheelSize:      "27.5 / 650B",
+				Wheels:         parser.Wheels{Front: 27.5, Rear: 27.5},
+				FrontTravel:    "170 mm",
+				RearTravel:     "160 mm",
+				FrameMaterial:  "Aluminum",
+			},
+		},
+		{
+			"Negotiable euro price",
+			RawListing{
+				Title:         "2022 Canyon Spectral CF 8",
+				Price:         "€3.500 OBO",
 				Condition:     "Good - Used, Mechanically Sound",
 				FrameSize:     "M",
-				WheelSize:     "27.5 / 650B",
-				FrontTravel:   "170 mm",
-				RearTravel:    "160 mm",
-				FrameMaterial: "Aluminum",
+				WheelSize:     `29`,
+				FrontTravel:   "160 mm",
+				RearTravel:    "150 mm",
+				FrameMaterial: "Carbon Fiber",
+			},
+			Listing{
+				Title:          "2022 Canyon Spectral CF 8",
+				Price:          "3500",
+				ListedPrice:    "3500",
+				Year:           "2022",
+				Manufacturer:   "Canyon",
+				Model:          "Spectral",
+				Currency:       "EUR",
+				Negotiable:     true,
+				Condition:      "Good - Used, Mechanically Sound",
+				FrameSize:      "M",
+				NormalizedSize: "M",
+				ConditionGrade: parser.ConditionGood,
+				Kind:           parser.KindBike,
+				WheelSize:      "29",
+				Wheels:         parser.Wheels{Front: 29, Rear: 29},
+				FrontTravel:    "160 mm",
+				RearTravel:     "150 mm",
+				FrameMaterial:  "Carbon Fiber",
 			},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := tt.arg.PostProcess(1.0)
+			got := tt.arg.PostProcess(1.0, nil)
+			got.Metadata = nil // covered by TestProvenance
 			assert.Equal(t, tt.want, got)
 		})
 	}
 }
+
+func TestProvenance(t *testing.T) {
+	raw := RawListing{
+		Title:     "Santa Cruz Nomad 27.5",
+		Price:     "$3000 USD",
+		Condition: "Good - Used, Mechanically Sound",
+		FrameSize: "L",
+	}
+
+	l := raw.PostProcess(1.0, nil)
+	assert.Equal(t, FieldMeta{Source: SourceMissing}, l.Metadata["year"])
+	assert.Equal(t, FieldMeta{Source: SourceModelDB, Confidence: 0.9}, l.Metadata["model"])
+	assert.Equal(t, FieldMeta{Source: SourceRegex, Confidence: 0.8}, l.Metadata["price"])
+	assert.Equal(t, FieldMeta{Source: SourceScraped, Confidence: 1}, l.Metadata["frame_size"])
+	assert.Equal(t, SourceMissing, l.Metadata["rear_travel"].Source)
+	assert.Len(t, l.Metadata, 11)
+
+	// a four digit number that cannot be a model year
+	raw.Title = "Santa Cruz Nomad 2500 km"
+	assert.Equal(t, 0.3, raw.PostProcess(1.0, nil).Metadata["year"].Confidence)
+
+	// next year's models are plausible, judged by the processing clock
+	processed := clock.Fixed(time.Date(2031, 9, 1, 0, 0, 0, 0, time.UTC))
+	raw.Title = "2032 Santa Cruz Nomad"
+	assert.Equal(t, 0.8, raw.PostProcess(1.0, processed).Metadata["year"].Confidence)
+	raw.Title = "2033 Santa Cruz Nomad"
+	assert.Equal(t, 0.3, raw.PostProcess(1.0, processed).Metadata["year"].Confidence)
+	assert.Equal(t, 0.3, Listing{Title: raw.Title}.Revalidate(processed).Metadata["year"].Confidence)
+
+	// imported fields keep their source, those filled from the title are derived
+	imported := Listing{Title: "2019 Santa Cruz Nomad", Price: "3000", FrameSize: "L"}.Revalidate(nil)
+	assert.Equal(t, SourceImported, imported.Metadata["price"].Source)
+	assert.Equal(t, SourceRegex, imported.Metadata["year"].Source)
+	assert.Equal(t, SourceMissing, imported.Metadata["wheel_size"].Source)
+
+	var aliases parser.Aliases
+	aliases.AddModel("Santa Cruz", "Nomad", "Nomad")
+	noModel := l
+	noModel.Model = "NoModelFound"
+	aliased := noModel.ApplyAliases(&aliases)
+	assert.Equal(t, SourceAlias, aliased.Metadata["model"].Source)
+	assert.Equal(t, SourceModelDB, l.Metadata["model"].Source, "the original listing is not modified")
+
+	assert.InDelta(t, 0.6, Metadata{"year": {Confidence: 0.2}, "model": {Confidence: 1}}.Confidence(), 0.001)
+	assert.Equal(t, 0.0, Listing{}.Confidence())
+}
+
+func TestElectric(t *testing.T) {
+	levo := RawListing{Title: "2022 Specialized Turbo Levo Comp", Price: "$6000 USD", Condition: "Good", FrameSize: "L",
+		WheelSize: "29", FrontTravel: "160 mm", RearTravel: "150 mm", FrameMaterial: "Carbon Fiber"}.PostProcess(1.0, nil)
+	assert.True(t, levo.IsElectric)
+	assert.Empty(t, levo.NeedsReview, "e-bikes are no longer flagged for review")
+
+	levo = levo.WithDetails(ListingDetails{Description: "Specialized 2.2 motor, 700wh battery, 300 km"})
+	assert.Equal(t, "Specialized 2.2", levo.Details.Motor)
+	assert.Equal(t, 700, levo.Details.BatteryWh)
+
+	// a drive unit in the description marks a listing the title did not
+	sight := Listing{Title: "2023 Norco Sight VLT", Model: "Sight"}.WithDetails(ListingDetails{Description: "Shimano EP8, 900wh"})
+	assert.True(t, sight.IsElectric)
+	assert.Equal(t, 900, sight.Details.BatteryWh)
+
+	plain := Listing{Title: "2021 Norco Sight C2"}.WithDetails(ListingDetails{Description: "Fox 36, 750wh of fun"})
+	assert.False(t, plain.IsElectric)
+	assert.Equal(t, 0, plain.Details.BatteryWh)
+}
+
+func TestRetailPrice(t *testing.T) {
+	l := Listing{Manufacturer: "Santa Cruz", Model: "Nomad", Year: "2023", Price: "3900", Currency: "CAD"}.
+		WithDetails(ListingDetails{Description: "Barely ridden, retailed for $8,000 last year"})
+	assert.Equal(t, parser.Price{Amount: 8000, Currency: "CAD"}, l.Details.OriginalPrice)
+
+	retail, ok := l.RetailPrice(nil, 0.75)
+	assert.True(t, ok)
+	assert.Equal(t, 6000.0, retail)
+
+	msrps := parser.NewMSRPs()
+	msrps.Add(parser.MSRP{Manufacturer: "Santa Cruz", Model: "Nomad", Year: "2023", Price: 6499})
+	retail, ok = l.RetailPrice(msrps, 0.75)
+	assert.True(t, ok)
+	assert.Equal(t, 6499.0, retail)
+
+	_, ok = Listing{Price: "3900"}.RetailPrice(msrps, 0.75)
+	assert.False(t, ok)
+}
+
+func TestResidual(t *testing.T) {
+	residual, ok := Listing{Price: "3900", PredictedPrice: 4500}.Residual()
+	assert.True(t, ok)
+	assert.Equal(t, -600.0, residual)
+
+	_, ok = Listing{Price: "3900"}.Residual()
+	assert.False(t, ok, "no model was run")
+	_, ok = Listing{PredictedPrice: 4500}.Residual()
+	assert.False(t, ok, "the listing has no price")
+}
+
+func TestViewsPerDay(t *testing.T) {
+	posted := time.Date(2024, 9, 5, 0, 0, 0, 0, time.UTC)
+	d := ListingDetails{OriginalPostDate: posted, ViewCount: 1200}
+	assert.Equal(t, 100.0, d.ViewsPerDay(posted.AddDate(0, 0, 12)))
+	assert.Equal(t, 1200.0, d.ViewsPerDay(posted.Add(2*time.Hour)), "the first day counts as a whole day")
+
+	assert.Zero(t, ListingDetails{ViewCount: 1200}.ViewsPerDay(posted))
+	assert.Zero(t, ListingDetails{OriginalPostDate: posted}.ViewsPerDay(posted.AddDate(0, 0, 1)))
+}
+
+func TestApplyAliases(t *testing.T) {
+	var aliases parser.Aliases
+	aliases.AddManufacturer("SC", "Santa Cruz")
+	aliases.AddModel("Santa Cruz", "Nomad", "Nomad")
+
+	l := Listing{
+		Title: "2019 SC Nomad 27.5", Year: "2019", Manufacturer: "NoManufacturer", Model: "NoModelFound",
+		Price: "3000", Currency: "USD", Condition: "Good", FrameSize: "L", WheelSize: "27.5",
+		FrontTravel: "170 mm", RearTravel: "170 mm", FrameMaterial: "Carbon Fiber", NeedsReview: Reasons{"manufacturer"},
+	}
+
+	got := l.ApplyAliases(&aliases)
+	assert.Equal(t, "Santa Cruz", got.Manufacturer)
+	assert.Equal(t, "Nomad", got.Model)
+	assert.Empty(t, got.NeedsReview)
+
+	assert.Equal(t, l.Manufacturer, l.ApplyAliases(nil).Manufacturer)
+}
+
+func TestReparse(t *testing.T) {
+	stored := Listing{
+		Title: "2019 Santa Cruz Nomad", Year: "2019", Manufacturer: "NoManufacturer", Model: "NoModelFound",
+		Price: "2250", Currency: "USD", Condition: "Excellent - Lightly Ridden", FrameSize: "L", WheelSize: "27.5",
+		FrontTravel: "170 mm", RearTravel: "170 mm", FrameMaterial: "Carbon Fiber", NeedsReview: Reasons{"manufacturer"},
+		Category: "enduro", Active: true,
+		Details: ListingDetails{Description: "Shimano EP8 motor with a 630Wh battery"},
+	}
+
+	got := stored.Reparse(nil)
+	assert.Equal(t, "Santa Cruz", got.Manufacturer)
+	assert.Equal(t, "Nomad", got.Model)
+	assert.Equal(t, parser.ConditionExcellent, got.ConditionGrade)
+	assert.Equal(t, "2250", got.Price, "the converted price is kept")
+	assert.Equal(t, "USD", got.Currency)
+	assert.Equal(t, "enduro", got.Category)
+	assert.True(t, got.Active)
+	assert.Equal(t, 630, got.Details.BatteryWh)
+
+	corrected := stored
+	corrected.Manufacturer, corrected.Model = "Santa Cruz", "Bronson"
+	corrected.Metadata = Metadata{}
+	corrected.Metadata.Set("model", SourceCorrection)
+	got = corrected.Reparse(nil)
+	assert.Equal(t, "Bronson", got.Model, "a reviewer's correction is kept")
+	assert.Equal(t, SourceCorrection, got.Metadata["manufacturer"].Source)
+}
+
+func TestInferredYears(t *testing.T) {
+	l := RawListing{Title: "Santa Cruz Megatower CC", Price: "4200 USD", URL: "https://www.pinkbike.com/buysell/3861316/"}.PostProcess(1, nil)
+	assert.Equal(t, parser.YearRange{Min: 2019}, l.InferredYears, "the model was first made in 2019")
+	assert.Equal(t, SourceInferred, l.Metadata["inferred_years"].Source)
+
+	l = l.WithDetails(ListingDetails{Description: "Fox 38 Grip2 fork, SRAM X01 Eagle"})
+	assert.Equal(t, parser.YearRange{Min: 2021}, l.InferredYears)
+	assert.Empty(t, l.Year, "an inferred year is not taken as the year")
+	assert.Contains(t, l.NeedsReview, "year")
+
+	dated := RawListing{Title: "2020 Santa Cruz Megatower CC", Price: "4200 USD"}.PostProcess(1, nil).
+		WithDetails(ListingDetails{Description: "Fox 38 Grip2 fork"})
+	assert.True(t, dated.InferredYears.IsZero(), "years are only inferred when the title gives none")
+}
+
+func TestBuildTier(t *testing.T) {
+	l := RawListing{Title: "2022 Specialized Stumpjumper EVO Expert", Price: "4200 USD"}.PostProcess(1, nil)
+	assert.Equal(t, parser.BuildTier{Kit: "Expert", Rank: parser.TierUpper}, l.BuildTier)
+
+	l = RawListing{Title: "2021 Ibis Ripmo V2", Price: "3900 USD"}.PostProcess(1, nil)
+	assert.True(t, l.BuildTier.IsZero())
+	l = l.WithDetails(ListingDetails{Description: "Built up with an XT drivetrain and brakes"})
+	assert.Equal(t, parser.BuildTier{Kit: "XT", Rank: parser.TierUpper}, l.BuildTier, "the description names the kit when the title does not")
+}
+
+func TestGeneration(t *testing.T) {
+	l := RawListing{Title: "Santa Cruz Megatower 1 CC X01", Price: "3200 USD"}.PostProcess(1, nil)
+	assert.Equal(t, "Megatower", l.Model)
+	assert.Equal(t, "V1", l.Generation)
+	assert.Equal(t, parser.YearRange{Min: 2019, Max: 2021}, l.InferredYears, "the generation bounds the years")
+
+	imported := Listing{Title: "2023 Ibis Ripmo V2S", Manufacturer: "Ibis", Model: "Ripmo"}.Revalidate(nil)
+	assert.Equal(t, "V2S", imported.Generation)
+}
+
+func TestValidationProfiles(t *testing.T) {
+	gravel := Listing{
+		Title: "2022 Specialized Crux Pro", Year: "2022", Manufacturer: "Specialized", Model: "Crux",
+		Price: "4000", Currency: "USD", Condition: "Good", FrameSize: "56", WheelSize: "700c",
+		FrameMaterial: "Carbon Fiber",
+	}
+	assert.Equal(t, Reasons{"front travel", "rear travel"}, gravel.Validate(DefaultProfile).NeedsReview)
+	assert.Empty(t, gravel.Validate(GravelProfile).NeedsReview)
+
+	dirtJump := Listing{
+		Title: "Specialized P.3", Manufacturer: "Specialized", Model: "P.3",
+		Price: "900", Currency: "USD", Condition: "Good", FrameSize: "One Size", WheelSize: "26",
+		FrontTravel: "100 mm", FrameMaterial: "Aluminium",
+	}
+	assert.Equal(t, Reasons{"year", "rear travel"}, dirtJump.Validate(DefaultProfile).NeedsReview)
+	assert.Empty(t, dirtJump.Validate(DirtJumpProfile).NeedsReview)
+
+	dirtJump.Price = ""
+	assert.Equal(t, Reasons{"price"}, dirtJump.Validate(DirtJumpProfile).NeedsReview)
+}
+
+func TestValidationKinds(t *testing.T) {
+	frame := RawListing{
+		Title: "2022 Santa Cruz Megatower frame only", Price: "2200 USD", Condition: "Good - Used, Mechanically Sound",
+		FrameSize: "L", RearTravel: "160 mm", FrameMaterial: "Carbon Fiber",
+	}.PostProcess(1, nil)
+	assert.Equal(t, parser.KindFrame, frame.Kind)
+	assert.Empty(t, frame.NeedsReview, "frames are sold without a fork or wheels")
+	assert.Equal(t, []string{"shock size"}, DefaultProfile.Check(frame).Warnings)
+	frame = frame.WithDetails(ListingDetails{Description: "Comes with a Float X2, 230x65 trunnion."})
+	assert.Equal(t, "230x65", frame.ShockSize)
+	assert.Empty(t, DefaultProfile.Check(frame).Warnings)
+
+	fork := RawListing{Title: "RockShox ZEB Ultimate fork 170mm", Price: "650 USD", Condition: "Excellent - Lightly Ridden",
+		WheelSize: "29", FrontTravel: "170 mm"}.PostProcess(1, nil)
+	assert.Equal(t, parser.KindFork, fork.Kind)
+	assert.Empty(t, fork.NeedsReview, "forks need no year, frame size or rear travel")
+	fork = fork.WithDetails(ListingDetails{Description: "Steerer cut to 195mm."})
+	assert.Equal(t, 195, fork.SteererLength)
+	assert.Empty(t, DefaultProfile.Check(fork).Warnings)
+
+	bike := Listing{Title: "2021 Evil Wreckoning", Price: "3900", Currency: "USD"}
+	assert.Contains(t, bike.Validate(DefaultProfile).NeedsReview, "front travel", "listings stored without a kind are bikes")
+}
+
+func TestValidationModelTravel(t *testing.T) {
+	xc := Listing{
+		Title: "2021 Specialized Epic", Year: "2021", Manufacturer: "Specialized", Model: "Epic", Price: "3000", Currency: "USD",
+		Condition: "Good", FrameSize: "M", WheelSize: "29", FrontTravel: "100 mm", RearTravel: "100 mm", FrameMaterial: "Carbon Fiber",
+	}
+	assert.Empty(t, xc.Validate(DefaultProfile).NeedsReview)
+
+	xc.FrontTravel = "200 mm"
+	assert.Equal(t, Reasons{"front travel for model"}, xc.Validate(DefaultProfile).NeedsReview, "no XC bike has a 200mm fork")
+
+	hardtail := xc
+	hardtail.Title, hardtail.Manufacturer, hardtail.Model = "2021 Kona Honzo", "Kona", "Honzo"
+	hardtail.FrontTravel, hardtail.RearTravel = "130 mm", "140 mm"
+	assert.Equal(t, Reasons{"rear travel for model"}, hardtail.Validate(DefaultProfile).NeedsReview, "hardtails have no rear travel")
+	hardtail.RearTravel = "0 mm"
+	assert.Empty(t, hardtail.Validate(DefaultProfile).NeedsReview)
+
+	unknown := xc
+	unknown.Model = "NoModelFound"
+	assert.Equal(t, Reasons{"model"}, unknown.Validate(DefaultProfile).NeedsReview, "travel of unknown models is not checked")
+}
+
+func TestValidationRules(t *testing.T) {
+	rules, err := LoadRules("testdata/rules.json")
+	require.NoError(t, err)
+	profile := DefaultProfile.WithRules(rules)
+
+	enduro := Listing{
+		Title: "Santa Cruz Megatower", Manufacturer: "Santa Cruz", Model: "Megatower", Price: "50", Currency: "USD",
+		Condition: "Good", FrameSize: "L", WheelSize: "29", FrontTravel: "170 mm", RearTravel: "120 mm",
+		FrameMaterial: "Carbon Fiber", Category: "enduro",
+	}
+	f := profile.Check(enduro)
+	assert.Empty(t, f.Rejected)
+	assert.Equal(t, Reasons{"rear travel for model", "enduro travel"}, f.Review)
+	assert.Equal(t, []string{"year", "implausible price"}, f.Warnings, "the year rule was made a warning")
+
+	validated := enduro.Validate(profile)
+	assert.Equal(t, Reasons{"rear travel for model", "enduro travel"}, validated.NeedsReview)
+	assert.Equal(t, []string{"year", "implausible price"}, validated.Warnings)
+
+	trail := enduro
+	trail.Category = "trail"
+	assert.Equal(t, Reasons{"rear travel for model"}, profile.Check(trail).Review, "the travel rule only applies to enduro bikes")
+
+	euro := enduro
+	euro.Currency = "EUR"
+	assert.Equal(t, []string{"outside north america"}, profile.Check(euro).Rejected)
+	euro.Currency = ""
+	assert.Empty(t, profile.Check(euro).Rejected, "a missing value only fails required rules")
+	assert.Contains(t, profile.Check(euro).Review, "currency")
+}
+
+func TestLoadRulesRejectsBadRules(t *testing.T) {
+	for name, rules := range map[string]string{
+		"unknown field":    `[{"field": "colour", "required": true}]`,
+		"unknown severity": `[{"field": "year", "required": true, "severity": "fatal"}]`,
+		"bad pattern":      `[{"field": "year", "pattern": "(("}]`,
+		"checks nothing":   `[{"field": "year"}]`,
+		"year model range": `[{"field": "year", "modelRange": true}]`,
+	} {
+		path := filepath.Join(t.TempDir(), "rules.json")
+		require.NoError(t, os.WriteFile(path, []byte(rules), 0o644))
+		_, err := LoadRules(path)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestParseReasons(t *testing.T) {
+	assert.Equal(t, Reasons{"year", "rear travel"}, ParseReasons(`["year","rear travel"]`))
+	assert.Equal(t, Reasons{"year", "rear travel"}, ParseReasons("year, rear travel"), "older databases join reasons with commas")
+	assert.Nil(t, ParseReasons(""))
+	assert.Nil(t, ParseReasons("[]"))
+	assert.Equal(t, "year, rear travel", Reasons{"year", "rear travel"}.String())
+}
+
+func TestDedupe(t *testing.T) {
+	sizes := parser.NewSizes()
+	first := Listing{Title: "2021 Specialized Stumpjumper", Year: "2021", Manufacturer: "Specialized", Model: "Stumpjumper",
+		Price: "3500", FrameSize: "S4", URL: "https://www.pinkbike.com/buysell/1/"}.ApplySizes(sizes)
+	repriced := first
+	repriced.Price = "3200"
+	assert.Equal(t, []Listing{first}, Dedupe([]Listing{first, repriced}), "a listing repriced mid scrape is the same listing")
+
+	// the same listing seen on two pages after the seller edited its title
+	other := first
+	other.ListingID = 3861316
+	edited := other
+	edited.Title = "2021 Specialized Stumpjumper Comp"
+	assert.Equal(t, []Listing{other}, Dedupe([]Listing{other, edited}))
+
+	// two sellers listing the same bike with the same title
+	second := first
+	second.URL = "https://www.pinkbike.com/buysell/2/"
+	require.Equal(t, first.ComputeHash(), second.ComputeHash())
+	assert.Equal(t, []Listing{first, second}, Dedupe([]Listing{first, second}))
+
+	// without a URL the bike is compared, with its size spelled either way
+	unlinked := first
+	unlinked.URL = ""
+	resized := unlinked
+	resized.FrameSize, resized.Price = "L", "3200"
+	resized = resized.ApplySizes(sizes)
+	assert.Equal(t, []Listing{unlinked}, Dedupe([]Listing{unlinked, resized}))
+
+	// wheel sizes compare as configurations, so a mullet is another bike
+	raw := RawListing{Title: "2022 Santa Cruz Bronson", Price: "4000", WheelSize: "27.5 / 650B"}
+	bronson := raw.PostProcess(1, nil)
+	raw.WheelSize = "27.5"
+	respelled := raw.PostProcess(1, nil)
+	raw.WheelSize = "29/27.5 mullet"
+	mullet := raw.PostProcess(1, nil)
+	assert.Equal(t, parser.Wheels{Front: 29, Rear: 27.5, Mullet: true}, mullet.Wheels)
+	assert.Equal(t, []Listing{bronson, mullet}, Dedupe([]Listing{bronson, respelled, mullet}))
+}
+
+func TestFingerprint(t *testing.T) {
+	l := RawListing{Title: "2021 Santa Cruz Megatower", Price: "4200", FrameSize: "L", URL: "https://www.pinkbike.com/buysell/3861316/"}.PostProcess(1, nil)
+	typoFixed := RawListing{Title: "2021 Santa Cruz Megatower CC, fresh service", Price: "4200", FrameSize: "L", URL: "https://www.pinkbike.com/buysell/3861316/"}.PostProcess(1, nil)
+	assert.NotEqual(t, l.ComputeHash(), typoFixed.ComputeHash(), "the strict hash changes with the title")
+	assert.Equal(t, "pb:3861316", l.Fingerprint())
+	assert.Equal(t, l.Fingerprint(), typoFixed.Fingerprint())
+
+	// a URL without a listing ID is fingerprinted with the extracted fields
+	other := Listing{Title: "2021 Santa Cruz Megatower", Year: "2021", Manufacturer: "Santa Cruz", Model: "Megatower", URL: "https://example.com/bikes/42"}
+	retitled := other
+	retitled.Title = "2021 Santa Cruz Megatower - reduced"
+	assert.Equal(t, other.Fingerprint(), retitled.Fingerprint())
+	resized := other
+	resized.FrameSize = "XL"
+	assert.NotEqual(t, other.Fingerprint(), resized.Fingerprint())
+
+	other.URL, retitled.URL = "", ""
+	assert.Equal(t, other.ComputeHash(), other.Fingerprint(), "without a URL the fields are not distinctive enough")
+	assert.NotEqual(t, other.Fingerprint(), retitled.Fingerprint())
+}
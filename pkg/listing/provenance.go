@@ -0,0 +1,129 @@
+package listing
+
+import (
+	"strconv"
+	"time"
+)
+
+// Source records how a field's value was derived
+type Source string
+
+const (
+	// SourceScraped values were read directly from a listing field
+	SourceScraped Source = "scraped"
+	// SourceRegex values were matched in the title or price text
+	SourceRegex Source = "regex"
+	// SourceModelDB values were found in the manufacturer and model database
+	SourceModelDB Source = "model_db"
+	// SourceAlias values were resolved with an alias learned in review
+	SourceAlias Source = "alias"
+	// SourceCorrection values were set by a reviewer
+	SourceCorrection Source = "correction"
+	// SourceImported values were read from an imported file
+	SourceImported Source = "imported"
+	// SourceInferred values were estimated from other fields, such as model
+	// years from the components a bike came with
+	SourceInferred Source = "inferred"
+	// SourceMissing fields could not be derived
+	SourceMissing Source = "missing"
+)
+
+// sourceConfidence is how far a value from each source can be trusted, 0 to 1
+var sourceConfidence = map[Source]float64{
+	SourceScraped:    1,
+	SourceCorrection: 1,
+	SourceModelDB:    0.9,
+	SourceRegex:      0.8,
+	SourceImported:   0.8,
+	SourceAlias:      0.7,
+	SourceInferred:   0.5,
+	SourceMissing:    0,
+}
+
+// FieldMeta is how one field was derived and how much it can be trusted
+type FieldMeta struct {
+	Source     Source  `json:"source"`
+	Confidence float64 `json:"confidence"`
+}
+
+// Metadata maps field names, as named in the listings table, to their provenance
+type Metadata map[string]FieldMeta
+
+// Set records the source of a field with that source's confidence
+func (m Metadata) Set(field string, source Source) {
+	m[field] = FieldMeta{Source: source, Confidence: sourceConfidence[source]}
+}
+
+// derive records source for a field with a value and missing for an empty one
+func (m Metadata) derive(field, value string, source Source) {
+	if isMissing(value) {
+		m.Set(field, SourceMissing)
+		return
+	}
+	m.Set(field, source)
+}
+
+// fill records source for every field of l that has no provenance yet
+func (m Metadata) fill(l Listing, source Source) {
+	for _, f := range provenanceFields(l) {
+		if _, ok := m[f.name]; !ok {
+			m.derive(f.name, f.value, source)
+		}
+	}
+}
+
+// Confidence is the mean confidence of the recorded fields, 0 when none are
+func (m Metadata) Confidence() float64 {
+	if len(m) == 0 {
+		return 0
+	}
+
+	var total float64
+	for _, f := range m {
+		total += f.Confidence
+	}
+	return total / float64(len(m))
+}
+
+// clone copies the metadata so methods on a Listing value do not modify the
+// map shared with the caller's copy
+func (m Metadata) clone() Metadata {
+	c := make(Metadata, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func isMissing(value string) bool {
+	return value == "" || value == "NoManufacturer" || value == "NoModelFound"
+}
+
+func provenanceFields(l Listing) []struct{ name, value string } {
+	return []struct{ name, value string }{
+		{"year", l.Year},
+		{"manufacturer", l.Manufacturer},
+		{"model", l.Model},
+		{"price", l.Price},
+		{"currency", l.Currency},
+		{"condition", l.Condition},
+		{"frame_size", l.FrameSize},
+		{"wheel_size", l.WheelSize},
+		{"front_travel", l.FrontTravel},
+		{"rear_travel", l.RearTravel},
+		{"frame_material", l.FrameMaterial},
+	}
+}
+
+// checkYear lowers the confidence of a title year outside the range of
+// modern mountain bikes up to next year's models, the year regex matches any
+// four digit number
+func (m Metadata) checkYear(year string, now time.Time) {
+	y, err := strconv.Atoi(year)
+	if err != nil || (y >= 1990 && y <= now.Year()+1) {
+		return
+	}
+	meta := m["year"]
+	meta.Confidence = 0.3
+	m["year"] = meta
+}
@@ -0,0 +1,72 @@
+package listing
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"pinkbike-scraper/pkg/parser"
+)
+
+const (
+	// MinConditionPhotos is how many photos a listing needs before its
+	// condition can be judged from them
+	MinConditionPhotos = 4
+	// fullDescriptionWords, fullSpecs and fullPhotos are how many words,
+	// specs mentioned and photos earn the full share of the quality score
+	fullDescriptionWords = 80
+	fullSpecs            = 5
+	fullPhotos           = 8
+)
+
+// Quality is how well a listing's description and photos let a buyer judge
+// the bike without asking the seller
+type Quality struct {
+	// Score is from 0, nothing to go on, to 1
+	Score float64
+	// ConditionPhotos is whether the listing has MinConditionPhotos photos
+	ConditionPhotos bool
+	// Issues are what lowered the score, such as "short description"
+	Issues []string
+}
+
+// Quality scores the description and photos scraped from the listing page,
+// weighing the description's length and the specs it mentions, the photo
+// count and whether it says how to reach the seller. ok is false when the
+// listing page was not scraped.
+func (l Listing) Quality() (q Quality, ok bool) {
+	d := l.Details
+	if strings.TrimSpace(d.Description) == "" && d.PhotoCount == 0 {
+		return Quality{}, false
+	}
+
+	words := len(strings.Fields(d.Description))
+	specs := len(parser.MentionedSpecs(d.Description))
+	contact := d.Phone != "" || parser.HasContactInfo(d.Description)
+	q.ConditionPhotos = d.PhotoCount >= MinConditionPhotos
+
+	q.Score = 0.3*share(words, fullDescriptionWords) + 0.3*share(specs, fullSpecs) + 0.3*share(d.PhotoCount, fullPhotos)
+	if contact {
+		q.Score += 0.1
+	}
+	q.Score = math.Round(q.Score*100) / 100
+
+	if words < fullDescriptionWords/2 {
+		q.Issues = append(q.Issues, "short description")
+	}
+	if specs < 2 {
+		q.Issues = append(q.Issues, "few specs")
+	}
+	if !q.ConditionPhotos {
+		q.Issues = append(q.Issues, fmt.Sprintf("fewer than %d photos", MinConditionPhotos))
+	}
+	if !contact {
+		q.Issues = append(q.Issues, "no contact info")
+	}
+	return q, true
+}
+
+// share is n as a fraction of full, capped at 1
+func share(n, full int) float64 {
+	return math.Min(1, float64(n)/float64(full))
+}
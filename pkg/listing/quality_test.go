@@ -0,0 +1,31 @@
+package listing
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuality(t *testing.T) {
+	_, ok := Listing{Title: "2021 Norco Sight"}.Quality()
+	assert.False(t, ok, "listings without a scraped page are not scored")
+
+	thorough := Listing{Details: ListingDetails{
+		Description: strings.Repeat("Ridden two seasons on local trails and well looked after. ", 8) +
+			"Fox 36 fork and Float X2 shock serviced in May, XT drivetrain and brakes, new Assegai tires. Text me to see it.",
+		PhotoCount: 10,
+	}}
+	q, ok := thorough.Quality()
+	assert.True(t, ok)
+	assert.Equal(t, 1.0, q.Score)
+	assert.True(t, q.ConditionPhotos)
+	assert.Empty(t, q.Issues)
+
+	sparse := Listing{Details: ListingDetails{Description: "Great bike, no issues.", PhotoCount: 2}}
+	q, ok = sparse.Quality()
+	assert.True(t, ok)
+	assert.Equal(t, 0.09, q.Score)
+	assert.False(t, q.ConditionPhotos)
+	assert.Equal(t, []string{"short description", "few specs", "fewer than 4 photos", "no contact info"}, q.Issues)
+}
@@ -0,0 +1,84 @@
+package listing
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+var quoteReplacer = strings.NewReplacer(
+	"“", `"`, "”", `"`, "„", `"`, "″", `"`,
+	"‘", "'", "’", "'", "′", "'",
+)
+
+// Sanitize removes labels, markup leftovers and stray whitespace from a scraped listing
+func (l RawListing) Sanitize() RawListing {
+	return RawListing{
+		Title:         CleanText(l.Title),
+		Price:         CleanText(l.Price),
+		Condition:     ParseItemDetail(l.Condition, "Condition :"),
+		FrameSize:     ParseItemDetail(l.FrameSize, "Frame Size :"),
+		WheelSize:     ParseItemDetail(l.WheelSize, "Wheel Size :"),
+		FrontTravel:   ParseItemDetail(l.FrontTravel, "Front Travel :"),
+		RearTravel:    ParseItemDetail(l.RearTravel, "Rear Travel :"),
+		FrameMaterial: ParseItemDetail(l.FrameMaterial, "Material :"),
+		URL:           strings.TrimSpace(l.URL),
+		DetailsLink:   strings.TrimSpace(l.DetailsLink),
+		Location:      CleanText(l.Location),
+	}
+}
+
+// ParseItemDetail returns the cleaned value following label, with quotes removed
+func ParseItemDetail(detail, label string) string {
+	split := strings.SplitN(CleanText(detail), label, 2)
+	if len(split) < 2 {
+		return ""
+	}
+
+	s := strings.ReplaceAll(split[1], `"`, "")
+
+	return strings.TrimSpace(s)
+}
+
+// CleanText decodes HTML entities, normalizes quotes and unicode spaces, drops
+// emoji and control characters, and collapses whitespace to single spaces
+func CleanText(s string) string {
+	s = html.UnescapeString(s)
+	s = quoteReplacer.Replace(s)
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		switch {
+		case unicode.IsSpace(r):
+			b.WriteRune(' ')
+		case isEmoji(r), unicode.IsControl(r), unicode.Is(unicode.Cf, r):
+			continue
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	s = strings.Join(strings.Fields(b.String()), " ")
+
+	for strings.Contains(s, `""`) {
+		s = strings.ReplaceAll(s, `""`, `"`)
+	}
+
+	if len(s) > 1 && strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) {
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+
+	return s
+}
+
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF, // pictographs, emoticons, transport, flags
+		r >= 0x2600 && r <= 0x27BF,   // misc symbols and dingbats
+		r >= 0xFE00 && r <= 0xFE0F,   // variation selectors
+		r >= 0xE0020 && r <= 0xE007F: // tag sequences
+		return true
+	}
+	return false
+}
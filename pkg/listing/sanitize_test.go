@@ -0,0 +1,61 @@
+package listing
+
+import (
+	_ "embed"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//go:embed testdata/sanitize_corpus.json
+var sanitizeCorpusJSON []byte
+
+func TestSanitizeCorpus(t *testing.T) {
+	var corpus []struct {
+		Name  string `json:"name"`
+		Label string `json:"label"`
+		Input string `json:"input"`
+		Want  string `json:"want"`
+	}
+	require.NoError(t, json.Unmarshal(sanitizeCorpusJSON, &corpus))
+
+	for _, tt := range corpus {
+		t.Run(tt.Name, func(t *testing.T) {
+			if tt.Label == "" {
+				assert.Equal(t, tt.Want, CleanText(tt.Input))
+				return
+			}
+			assert.Equal(t, tt.Want, ParseItemDetail(tt.Input, tt.Label))
+		})
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	raw := RawListing{
+		Title:         "  2024 Transition Spire&nbsp;AXS 🚀 ",
+		Price:         " $5300 USD ",
+		Condition:     "Condition : Excellent - Lightly Ridden",
+		FrameSize:     "Frame Size : L",
+		WheelSize:     `Wheel Size : 29"`,
+		FrontTravel:   "Front Travel : 170 mm",
+		RearTravel:    "Rear Travel : 170 mm",
+		FrameMaterial: "Material : Carbon Fiber",
+		URL:           " https://www.pinkbike.com/buysell/3960926/ ",
+		DetailsLink:   "https://www.pinkbike.com/buysell/3960926/",
+	}
+
+	assert.Equal(t, RawListing{
+		Title:         "2024 Transition Spire AXS",
+		Price:         "$5300 USD",
+		Condition:     "Excellent - Lightly Ridden",
+		FrameSize:     "L",
+		WheelSize:     "29",
+		FrontTravel:   "170 mm",
+		RearTravel:    "170 mm",
+		FrameMaterial: "Carbon Fiber",
+		URL:           "https://www.pinkbike.com/buysell/3960926/",
+		DetailsLink:   "https://www.pinkbike.com/buysell/3960926/",
+	}, raw.Sanitize())
+}
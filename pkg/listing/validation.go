@@ -0,0 +1,393 @@
+package listing
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"pinkbike-scraper/pkg/parser"
+)
+
+// Severity is what failing a validation rule does to a listing
+type Severity string
+
+const (
+	// Reject drops the listing from the run
+	Reject Severity = "reject"
+	// Review flags the listing for review, which keeps it out of medians
+	// and deals until it is corrected
+	Review Severity = "review"
+	// Warn only notes the problem
+	Warn Severity = "warn"
+)
+
+// Rule is a check of one listing field. A rule fails when a required field
+// is missing, or when a present value does not match Pattern, its first
+// number is outside Min and Max, or with ModelRange outside the range the
+// model database expects of the listing's model.
+type Rule struct {
+	// Reason names the rule in review reasons and warnings, the field with
+	// spaces for underscores when empty ("frame size")
+	Reason string `json:"reason"`
+	// Field is the listing field checked, one of RuleFields
+	Field    string   `json:"field"`
+	Required bool     `json:"required"`
+	Pattern  string   `json:"pattern"`
+	Min      *float64 `json:"min"`
+	Max      *float64 `json:"max"`
+	// ModelRange checks travel against what the model database expects of
+	// the listing's model, catching data entry errors such as 200mm on a
+	// cross country bike. Only travel fields can be checked this way, and
+	// models the database does not know pass.
+	ModelRange bool `json:"modelRange"`
+	// Categories limits the rule to listings of these categories, such as
+	// "enduro"; it applies to every category when empty
+	Categories []string `json:"categories"`
+	// Kinds limits the rule to listings of these kinds, such as "frame"; it
+	// applies to every kind when empty
+	Kinds []string `json:"kinds"`
+	// Severity is Review when empty
+	Severity Severity `json:"severity"`
+
+	pattern *regexp.Regexp
+}
+
+// ruleFields reads the fields rules can check. Placeholders the parser fills
+// in for values it could not find read as missing.
+var ruleFields = map[string]func(l Listing) string{
+	"title":          func(l Listing) string { return l.Title },
+	"year":           func(l Listing) string { return l.Year },
+	"manufacturer":   func(l Listing) string { return placeholder(l.Manufacturer, "NoManufacturer") },
+	"model":          func(l Listing) string { return placeholder(l.Model, "NoModelFound") },
+	"price":          func(l Listing) string { return placeholder(l.Price, "0") },
+	"listed_price":   func(l Listing) string { return l.ListedPrice },
+	"currency":       func(l Listing) string { return l.Currency },
+	"condition":      func(l Listing) string { return l.Condition },
+	"frame_size":     func(l Listing) string { return l.FrameSize },
+	"size":           func(l Listing) string { return l.NormalizedSize },
+	"wheel_size":     func(l Listing) string { return l.WheelSize },
+	"wheels":         func(l Listing) string { return l.Wheels.String() },
+	"front_travel":   func(l Listing) string { return l.FrontTravel },
+	"rear_travel":    func(l Listing) string { return l.RearTravel },
+	"frame_material": func(l Listing) string { return l.FrameMaterial },
+	"category":       func(l Listing) string { return l.Category },
+	"kind":           func(l Listing) string { return string(l.kind()) },
+	"shock_size":     func(l Listing) string { return l.ShockSize },
+	"steerer_length": func(l Listing) string { return placeholder(strconv.Itoa(l.SteererLength), "0") },
+	"url":            func(l Listing) string { return l.URL },
+	"description":    func(l Listing) string { return l.Details.Description },
+	"seller_type":    func(l Listing) string { return string(l.Details.SellerType) },
+}
+
+// modelRanges pick the range of a field ModelRange rules check it against
+var modelRanges = map[string]func(parser.ExpectedTravel) parser.TravelRange{
+	"front_travel": func(t parser.ExpectedTravel) parser.TravelRange { return t.Front },
+	"rear_travel":  func(t parser.ExpectedTravel) parser.TravelRange { return t.Rear },
+}
+
+func placeholder(value, missing string) string {
+	if value == missing {
+		return ""
+	}
+	return value
+}
+
+// RuleFields returns the fields validation rules can check
+func RuleFields() []string {
+	fields := make([]string, 0, len(ruleFields))
+	for field := range ruleFields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// DefaultRules send listings missing any of the fields summaries group by to
+// review, and those whose travel the model cannot have
+var DefaultRules = mustCompile([]Rule{
+	{Field: "price", Required: true},
+	{Field: "year", Required: true},
+	{Field: "manufacturer", Required: true},
+	{Field: "model", Required: true},
+	{Field: "currency", Required: true},
+	{Field: "condition", Required: true},
+	{Field: "frame_size", Required: true},
+	{Field: "wheel_size", Required: true},
+	{Field: "front_travel", Required: true},
+	{Field: "rear_travel", Required: true},
+	{Field: "frame_material", Required: true},
+	{Reason: "front travel for model", Field: "front_travel", ModelRange: true},
+	{Reason: "rear travel for model", Field: "rear_travel", ModelRange: true},
+	{Field: "shock_size", Required: true, Kinds: []string{string(parser.KindFrame)}, Severity: Warn},
+	{Field: "steerer_length", Required: true, Kinds: []string{string(parser.KindFork)}, Severity: Warn},
+})
+
+// kindOptional holds the reasons of default rules that do not apply to a
+// kind of listing, as a frame comes without a fork or wheels and a fork has
+// no frame. The model database only knows bike makers, so parts need no
+// manufacturer or model either.
+var kindOptional = map[parser.Kind]map[string]bool{
+	parser.KindFrame: {"front travel": true, "wheel size": true},
+	parser.KindFork: {
+		"year": true, "manufacturer": true, "model": true, "frame size": true, "rear travel": true, "frame material": true,
+	},
+	parser.KindWheels: {
+		"year": true, "manufacturer": true, "model": true, "frame size": true, "front travel": true, "rear travel": true,
+		"frame material": true,
+	},
+	parser.KindParts: {
+		"year": true, "manufacturer": true, "model": true, "frame size": true, "wheel size": true, "front travel": true,
+		"rear travel": true, "frame material": true,
+	},
+}
+
+// kind is the listing's kind, a complete bike when it has none
+func (l Listing) kind() parser.Kind {
+	if l.Kind == "" {
+		return parser.KindBike
+	}
+	return l.Kind
+}
+
+func mustCompile(rules []Rule) []Rule {
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			panic(err)
+		}
+	}
+	return rules
+}
+
+// compile checks the rule and fills in its defaults
+func (r *Rule) compile() error {
+	if _, ok := ruleFields[r.Field]; !ok {
+		return fmt.Errorf("rule checks unknown field %q (available: %s)", r.Field, strings.Join(RuleFields(), ", "))
+	}
+	if r.Reason == "" {
+		r.Reason = strings.ReplaceAll(r.Field, "_", " ")
+	}
+	switch r.Severity {
+	case "":
+		r.Severity = Review
+	case Reject, Review, Warn:
+	default:
+		return fmt.Errorf("rule %q has unknown severity %q (available: %s, %s, %s)", r.Reason, r.Severity, Reject, Review, Warn)
+	}
+	if r.Pattern != "" {
+		pattern, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("rule %q has an invalid pattern: %w", r.Reason, err)
+		}
+		r.pattern = pattern
+	}
+	if _, ok := modelRanges[r.Field]; r.ModelRange && !ok {
+		return fmt.Errorf("rule %q checks %s against the model, which only travel fields can be", r.Reason, r.Field)
+	}
+	if !r.Required && r.Pattern == "" && r.Min == nil && r.Max == nil && !r.ModelRange {
+		return fmt.Errorf("rule %q checks nothing, give it required, pattern, min, max or modelRange", r.Reason)
+	}
+	return nil
+}
+
+var numberPattern = regexp.MustCompile(`-?\d+(?:\.\d+)?`)
+
+// failed reports whether l breaks the rule
+func (r Rule) failed(l Listing) bool {
+	if len(r.Categories) > 0 && !containsFold(r.Categories, l.Category) {
+		return false
+	}
+	if len(r.Kinds) > 0 && !containsFold(r.Kinds, string(l.kind())) {
+		return false
+	}
+
+	value := strings.TrimSpace(ruleFields[r.Field](l))
+	if value == "" {
+		return r.Required
+	}
+	if r.pattern != nil && !r.pattern.MatchString(value) {
+		return true
+	}
+	if r.Min != nil || r.Max != nil {
+		n, err := strconv.ParseFloat(numberPattern.FindString(strings.ReplaceAll(value, ",", "")), 64)
+		if err != nil {
+			return true
+		}
+		if r.Min != nil && n < *r.Min || r.Max != nil && n > *r.Max {
+			return true
+		}
+	}
+	if r.ModelRange {
+		expected, ok := parser.ModelTravel(l.Manufacturer, l.Model)
+		mm, parsed := parser.ParseTravel(value)
+		if ok && parsed && !modelRanges[r.Field](expected).Contains(mm) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadRules reads validation rules from a JSON file of
+// [{"field": "rear_travel", "min": 100, "max": 220, "severity": "warn", "categories": ["enduro"], "kinds": ["bike"]}],
+// or {"field": "front_travel", "modelRange": true} to check it against the model.
+// They are added to DefaultRules, replacing a default rule with the same
+// reason, so {"field": "year", "required": true, "severity": "warn"} stops a
+// missing year sending listings to review.
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read validation rules: %w", err)
+	}
+
+	var loaded []Rule
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return nil, fmt.Errorf("could not parse validation rules %s: %w", path, err)
+	}
+	for i := range loaded {
+		if err := loaded[i].compile(); err != nil {
+			return nil, fmt.Errorf("validation rules %s: %w", path, err)
+		}
+	}
+
+	rules := append([]Rule(nil), DefaultRules...)
+	for _, r := range loaded {
+		replaced := false
+		for i := range rules {
+			if rules[i].Reason == r.Reason {
+				rules[i], replaced = r, true
+				break
+			}
+		}
+		if !replaced {
+			rules = append(rules, r)
+		}
+	}
+	return rules, nil
+}
+
+// ValidationProfile tunes which rules apply to a category of bike, since not
+// every category has every field. Rules that do not apply to a kind of
+// listing, such as front travel to a frame sold on its own, are skipped
+// whatever the profile.
+type ValidationProfile struct {
+	Name string
+	// Optional holds reasons ("year", "rear travel", ...) of rules that
+	// should not apply to this category
+	Optional map[string]bool
+	// Rules are checked in order, DefaultRules when nil
+	Rules []Rule
+}
+
+var (
+	// DefaultProfile suits full suspension mountain bikes and requires every field
+	DefaultProfile = ValidationProfile{Name: "mtb"}
+	// GravelProfile allows missing travel, gravel bikes are rigid or only have a short fork
+	GravelProfile = ValidationProfile{
+		Name:     "gravel",
+		Optional: map[string]bool{"front travel": true, "rear travel": true},
+	}
+	// DirtJumpProfile allows missing rear travel and year, dirt jumpers are
+	// mostly hardtails and are rarely sold by model year
+	DirtJumpProfile = ValidationProfile{
+		Name:     "dirt jump",
+		Optional: map[string]bool{"rear travel": true, "year": true},
+	}
+)
+
+// WithRules returns the profile checking rules instead of DefaultRules
+func (p ValidationProfile) WithRules(rules []Rule) ValidationProfile {
+	p.Rules = rules
+	return p
+}
+
+// Reasons are why a listing needs review, in the order its rules are
+// checked, empty when it does not
+type Reasons []string
+
+// String joins the reasons with commas, as exports show them
+func (r Reasons) String() string {
+	return strings.Join(r, ", ")
+}
+
+// ParseReasons reads reasons stored as a JSON array, or joined by commas as
+// exports and older databases have them
+func ParseReasons(s string) Reasons {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	if strings.HasPrefix(s, "[") {
+		var r Reasons
+		if err := json.Unmarshal([]byte(s), &r); err == nil {
+			if len(r) == 0 {
+				return nil
+			}
+			return r
+		}
+	}
+	var r Reasons
+	for _, reason := range strings.Split(s, ",") {
+		if reason = strings.TrimSpace(reason); reason != "" {
+			r = append(r, reason)
+		}
+	}
+	return r
+}
+
+// Findings are the reasons of the rules a listing failed, by severity, in
+// the order the rules are checked
+type Findings struct {
+	Rejected, Warnings []string
+	Review             Reasons
+}
+
+// Check runs the profile's rules against l
+func (p ValidationProfile) Check(l Listing) Findings {
+	rules := p.Rules
+	if rules == nil {
+		rules = DefaultRules
+	}
+
+	var f Findings
+	for _, r := range rules {
+		if p.Optional[r.Reason] || kindOptional[l.kind()][r.Reason] || !r.failed(l) {
+			continue
+		}
+		switch r.Severity {
+		case Reject:
+			f.Rejected = append(f.Rejected, r.Reason)
+		case Warn:
+			f.Warnings = append(f.Warnings, r.Reason)
+		default:
+			f.Review = append(f.Review, r.Reason)
+		}
+	}
+	return f
+}
+
+// Validate recomputes the review reasons and warnings using the profile of
+// the listing's category
+func (l Listing) Validate(p ValidationProfile) Listing {
+	f := p.Check(l)
+	l.NeedsReview = f.Review
+	l.Warnings = f.Warnings
+	return l
+}
+
+// validateListing returns the reasons the listing needs review, none if it
+// looks complete
+func validateListing(l Listing, p ValidationProfile) Reasons {
+	return p.Check(l).Review
+}
@@ -0,0 +1,42 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"pinkbike-scraper/pkg/currency"
+)
+
+// Manifest records what a run did and which inputs produced its numbers
+type Manifest struct {
+	StartedAt     time.Time       `json:"started_at"`
+	FinishedAt    time.Time       `json:"finished_at"`
+	BikeType      string          `json:"bike_type"`
+	InputMode     string          `json:"input_mode"`
+	ExportModes   []string        `json:"export_modes"`
+	Listings      int             `json:"listings"`
+	ExchangeRates []currency.Rate `json:"exchange_rates"`
+	// Blocked marks runs stopped by Pinkbike's bot protection
+	Blocked bool `json:"blocked,omitempty"`
+}
+
+// Write saves the manifest as JSON in dir, named after the bike type and start time
+func (m Manifest) Write(dir string) (string, error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("could not encode manifest: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("could not create manifest directory: %w", err)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("manifest_%s_%s.json", m.BikeType, m.StartedAt.Format("2006-01-02T150405")))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("could not write manifest: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,248 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"pinkbike-scraper/pkg/geo"
+	"pinkbike-scraper/pkg/parser"
+)
+
+// BotStore is the data the chat bot reads and changes
+type BotStore interface {
+	SaveSearch(s SavedSearch) error
+	RemoveSearch(owner, name string) (bool, error)
+	SavedSearches(owner string) ([]SavedSearch, error)
+	MedianPrice(manufacturer, model, generation string) (float64, int, error)
+}
+
+const botHelp = `Commands:
+/watch <name> [manufacturer=...] [model=...] [size=...] [wheels=...] [condition=...] [maxPrice=...] [keywords=a,b] [near=... maxKm=...]
+    get a message when a new listing matches
+/unwatch <name>   stop watching a search
+/searches         list your saved searches
+/median <manufacturer> [model] [generation]   median price of active listings`
+
+// Bot answers chat commands for managing saved searches and querying prices
+type Bot struct {
+	tg       *Telegram
+	store    BotStore
+	geocoder geo.Geocoder
+}
+
+// NewBot returns a bot answering through tg. geocoder looks up the places
+// searches are limited to; without one they must be given as
+// latitude,longitude.
+func NewBot(tg *Telegram, store BotStore, geocoder geo.Geocoder) *Bot {
+	return &Bot{tg: tg, store: store, geocoder: geocoder}
+}
+
+// Run answers messages until ctx is cancelled
+func (b *Bot) Run(ctx context.Context) error {
+	var offset int64
+	for ctx.Err() == nil {
+		updates, err := b.tg.updates(offset)
+		if err != nil {
+			log.Printf("could not get telegram updates: %v", err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if u.Message == nil || u.Message.Text == "" {
+				continue
+			}
+
+			chat := chatID(u.Message.Chat.ID)
+			if err := b.tg.SendMessage(chat, b.handle(chat, u.Message.Text)); err != nil {
+				log.Printf("could not reply to chat %s: %v", chat, err)
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+// handle runs a command sent from chat and returns the reply
+func (b *Bot) handle(chat, text string) string {
+	command, args := splitCommand(text)
+
+	switch command {
+	case "/watch":
+		s, err := parseSearch(args)
+		if err != nil {
+			return err.Error()
+		}
+		s.Owner = chat
+		if err := s.Locate(b.geocoder); err != nil {
+			return err.Error()
+		}
+		if err := b.store.SaveSearch(s); err != nil {
+			return fmt.Sprintf("Could not save search: %v", err)
+		}
+		return fmt.Sprintf("Watching %q", s.Name)
+
+	case "/unwatch":
+		name := strings.TrimSpace(args)
+		removed, err := b.store.RemoveSearch(chat, name)
+		if err != nil {
+			return fmt.Sprintf("Could not remove search: %v", err)
+		}
+		if !removed {
+			return fmt.Sprintf("No saved search named %q", name)
+		}
+		return fmt.Sprintf("Stopped watching %q", name)
+
+	case "/searches":
+		searches, err := b.store.SavedSearches(chat)
+		if err != nil {
+			return fmt.Sprintf("Could not load searches: %v", err)
+		}
+		if len(searches) == 0 {
+			return "No saved searches, add one with /watch"
+		}
+		lines := make([]string, 0, len(searches))
+		for _, s := range searches {
+			lines = append(lines, describeSearch(s))
+		}
+		return strings.Join(lines, "\n")
+
+	case "/median":
+		manufacturer := parser.ExtractManufacturer(args)
+		if manufacturer == "NoManufacturer" {
+			return fmt.Sprintf("Unknown manufacturer in %q", args)
+		}
+		model := parser.ExtractModel(args)
+		if model == "NoModelFound" {
+			model = ""
+		}
+
+		generation := parser.ExtractGeneration(manufacturer, model, args)
+
+		price, count, err := b.store.MedianPrice(manufacturer, model, generation)
+		if err != nil {
+			return fmt.Sprintf("Could not compute median: %v", err)
+		}
+		name := strings.TrimSpace(manufacturer + " " + model + " " + generation)
+		if count == 0 {
+			return fmt.Sprintf("No active listings for %s", name)
+		}
+		return fmt.Sprintf("%s: median $%.0f USD across %d active listings", name, price, count)
+	}
+	return botHelp
+}
+
+// splitCommand separates "/command@botname args" into the command and its arguments
+func splitCommand(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	command, args, _ := strings.Cut(text, " ")
+	command, _, _ = strings.Cut(command, "@")
+	return strings.ToLower(command), strings.TrimSpace(args)
+}
+
+// parseSearch reads "<name> key=value ..." where values may contain spaces
+func parseSearch(args string) (SavedSearch, error) {
+	var s SavedSearch
+	var name []string
+	fields := map[string][]string{}
+	key := ""
+
+	for _, word := range strings.Fields(args) {
+		if k, v, ok := strings.Cut(word, "="); ok {
+			key = strings.ToLower(k)
+			fields[key] = []string{v}
+			continue
+		}
+		if key == "" {
+			name = append(name, word)
+		} else {
+			fields[key] = append(fields[key], word)
+		}
+	}
+
+	s.Name = strings.Join(name, " ")
+	if s.Name == "" {
+		return s, fmt.Errorf("a search needs a name: /watch <name> model=...")
+	}
+
+	for k, words := range fields {
+		value := strings.Join(words, " ")
+		switch k {
+		case "manufacturer":
+			s.Manufacturer = value
+		case "model":
+			s.Model = value
+		case "size":
+			s.FrameSize = value
+		case "wheels":
+			if parser.ParseWheels(value, "").IsZero() {
+				return s, fmt.Errorf("wheels must be a wheel size such as 29, 27.5 or mullet, got %q", value)
+			}
+			s.WheelSize = value
+		case "condition":
+			if parser.ParseCondition(value) == parser.ConditionUnknown {
+				return s, fmt.Errorf("condition must be one of new, excellent, good, fair or poor, got %q", value)
+			}
+			s.MinCondition = value
+		case "maxprice":
+			price, err := strconv.ParseFloat(strings.TrimPrefix(value, "$"), 64)
+			if err != nil {
+				return s, fmt.Errorf("maxPrice must be a number, got %q", value)
+			}
+			s.MaxPrice = price
+		case "near":
+			s.Near = value
+		case "maxkm":
+			km, err := strconv.ParseFloat(strings.TrimSuffix(value, "km"), 64)
+			if err != nil || km <= 0 {
+				return s, fmt.Errorf("maxKm must be a positive number, got %q", value)
+			}
+			s.MaxKM = km
+		case "keywords":
+			for _, keyword := range strings.Split(value, ",") {
+				if keyword = strings.TrimSpace(keyword); keyword != "" {
+					s.Keywords = append(s.Keywords, keyword)
+				}
+			}
+		default:
+			return s, fmt.Errorf("unknown search field %q", k)
+		}
+	}
+	if (s.Near == "") != (s.MaxKM == 0) {
+		return s, fmt.Errorf("near and maxKm go together: near=Calgary, AB maxKm=300")
+	}
+	return s, nil
+}
+
+func describeSearch(s SavedSearch) string {
+	parts := []string{s.Name + ":"}
+	if s.Manufacturer != "" {
+		parts = append(parts, "manufacturer="+s.Manufacturer)
+	}
+	if s.Model != "" {
+		parts = append(parts, "model="+s.Model)
+	}
+	if s.FrameSize != "" {
+		parts = append(parts, "size="+s.FrameSize)
+	}
+	if s.WheelSize != "" {
+		parts = append(parts, "wheels="+s.WheelSize)
+	}
+	if s.MinCondition != "" {
+		parts = append(parts, "condition="+s.MinCondition)
+	}
+	if s.MaxPrice > 0 {
+		parts = append(parts, fmt.Sprintf("maxPrice=%.0f", s.MaxPrice))
+	}
+	if len(s.Keywords) > 0 {
+		parts = append(parts, "keywords="+strings.Join(s.Keywords, ","))
+	}
+	if s.MaxKM > 0 {
+		parts = append(parts, fmt.Sprintf("within %.0fkm of %s", s.MaxKM, s.Near))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,112 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pinkbike-scraper/pkg/events"
+	"pinkbike-scraper/pkg/geo"
+	"pinkbike-scraper/pkg/listing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStore struct {
+	searches []SavedSearch
+}
+
+func (f *fakeStore) SaveSearch(s SavedSearch) error {
+	f.searches = append(f.searches, s)
+	return nil
+}
+
+func (f *fakeStore) RemoveSearch(owner, name string) (bool, error) {
+	for i, s := range f.searches {
+		if s.Owner == owner && s.Name == name {
+			f.searches = append(f.searches[:i], f.searches[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeStore) SavedSearches(owner string) ([]SavedSearch, error) {
+	return f.searches, nil
+}
+
+func (f *fakeStore) MedianPrice(manufacturer, model, generation string) (float64, int, error) {
+	if manufacturer == "Santa Cruz" && model == "Nomad" {
+		return 3250, 12, nil
+	}
+	return 0, 0, nil
+}
+
+func TestBotCommands(t *testing.T) {
+	store := &fakeStore{}
+	bot := NewBot(nil, store, nil)
+
+	assert.Equal(t, `Watching "cheap nomad"`, bot.handle("42", "/watch cheap nomad manufacturer=Santa Cruz model=Nomad maxPrice=3000 keywords=coil, warranty"))
+	require.Len(t, store.searches, 1)
+	assert.Equal(t, SavedSearch{
+		Name: "cheap nomad", Owner: "42", Manufacturer: "Santa Cruz", Model: "Nomad",
+		MaxPrice: 3000, Keywords: []string{"coil", "warranty"},
+	}, store.searches[0])
+
+	assert.Equal(t, "cheap nomad: manufacturer=Santa Cruz model=Nomad maxPrice=3000 keywords=coil,warranty", bot.handle("42", "/searches"))
+	assert.Equal(t, "Santa Cruz Nomad: median $3250 USD across 12 active listings", bot.handle("42", "/median@pinkbike_bot santa cruz nomad"))
+	assert.Equal(t, `Unknown manufacturer in "unicorn"`, bot.handle("42", "/median unicorn"))
+
+	assert.Contains(t, bot.handle("42", "/watch model=Nomad"), "needs a name")
+	assert.Contains(t, bot.handle("42", "/watch x colour=red"), "unknown search field")
+	assert.Contains(t, bot.handle("42", "/watch x condition=mint"), "condition must be one of")
+
+	assert.Equal(t, `Stopped watching "cheap nomad"`, bot.handle("42", "/unwatch cheap nomad"))
+	assert.Equal(t, botHelp, bot.handle("42", "hello"))
+}
+
+func TestBotNearSearch(t *testing.T) {
+	store := &fakeStore{}
+	calgary := geo.Point{Lat: 51.0447, Lon: -114.0719}
+	bot := NewBot(nil, store, geo.Places{"calgary, ab": calgary})
+
+	assert.Equal(t, `Watching "local"`, bot.handle("42", "/watch local model=Nomad near=Calgary, AB maxKm=300"))
+	require.Len(t, store.searches, 1)
+	assert.Equal(t, calgary, store.searches[0].NearPoint)
+	assert.Equal(t, "local: model=Nomad within 300km of Calgary, AB", bot.handle("42", "/searches"))
+
+	assert.Contains(t, bot.handle("42", "/watch far near=Atlantis maxKm=10"), `could not find "Atlantis"`)
+	assert.Contains(t, bot.handle("42", "/watch far near=Calgary, AB"), "near and maxKm go together")
+	assert.Equal(t, `Watching "coords"`, NewBot(nil, store, nil).handle("42", "/watch coords near=49.70,-123.15 maxKm=50"),
+		"coordinates need no geocoder")
+}
+
+func TestTelegramNotifier(t *testing.T) {
+	var sent []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/bottoken/sendMessage", r.URL.Path)
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		sent = append(sent, body)
+		w.Write([]byte(`{"ok":true,"result":{}}`))
+	}))
+	defer server.Close()
+
+	tg := &Telegram{baseURL: server.URL, token: "token", client: server.Client()}
+	bus := events.NewBus()
+	NewTelegramNotifier(tg, []SavedSearch{{Name: "nomad", Owner: "42", Model: "Nomad"}}, "7", 0.1, Messages{}).Subscribe(bus)
+
+	nomad := listing.Listing{Title: "2019 Santa Cruz Nomad", Model: "Nomad", Price: "2800", Currency: "USD"}
+	bus.Publish(events.Event{Kind: events.ListingDiscovered, Listing: nomad})
+	bus.Publish(events.Event{Kind: events.ListingDiscovered, Listing: listing.Listing{Title: "2020 Yeti SB150", Model: "SB150"}})
+	nomad.Price = "2000"
+	bus.Publish(events.Event{Kind: events.PriceChanged, Listing: nomad, OldPrice: "2800"})
+
+	require.Len(t, sent, 2)
+	assert.Equal(t, "42", sent[0]["chat_id"])
+	assert.Contains(t, sent[0]["text"], "New listing for nomad")
+	assert.Equal(t, "7", sent[1]["chat_id"])
+	assert.Contains(t, sent[1]["text"], "Price drop")
+}
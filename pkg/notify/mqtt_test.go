@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"encoding/json"
+	"testing"
+
+	"pinkbike-scraper/pkg/events"
+	"pinkbike-scraper/pkg/listing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type published struct {
+	topic   string
+	retain  bool
+	payload map[string]interface{}
+}
+
+type fakePublisher []published
+
+func (p *fakePublisher) Publish(topic string, _ byte, retained bool, payload []byte) error {
+	var body map[string]interface{}
+	if err := json.Unmarshal(payload, &body); err != nil {
+		return err
+	}
+	*p = append(*p, published{topic, retained, body})
+	return nil
+}
+
+func (p *fakePublisher) Close() {}
+
+func TestMQTTPublishesMatchingEvents(t *testing.T) {
+	opts := DefaultMQTTOptions()
+	opts.Searches = []SavedSearch{{Name: "medium megatower", Model: "Megatower", FrameSize: "M", MaxPrice: 3000}}
+	opts.Retain = true
+	client := &fakePublisher{}
+	m := &MQTT{client: client, opts: opts}
+	bus := events.NewBus()
+	m.Subscribe(bus)
+
+	megatower := listing.Listing{Title: "2020 Santa Cruz Megatower", Manufacturer: "Santa Cruz", Model: "Megatower", NormalizedSize: "M", FrameSize: "M", Price: "2900", Currency: "USD"}
+	bus.Publish(events.Event{Kind: events.ListingDiscovered, Listing: megatower})
+	large := megatower
+	large.FrameSize, large.NormalizedSize = "L", "L"
+	bus.Publish(events.Event{Kind: events.ListingDiscovered, Listing: large})
+
+	megatower.Price = "2800"
+	bus.Publish(events.Event{Kind: events.PriceChanged, Listing: megatower, OldPrice: "2900"})
+	megatower.Price = "2400"
+	bus.Publish(events.Event{Kind: events.PriceChanged, Listing: megatower, OldPrice: "2800"})
+
+	require.Len(t, *client, 2)
+	assert.Equal(t, "pinkbike/new_listing", (*client)[0].topic)
+	assert.True(t, (*client)[0].retain)
+	assert.Equal(t, "medium megatower", (*client)[0].payload["search"])
+	assert.Equal(t, "Megatower", (*client)[0].payload["model"])
+	assert.Equal(t, "M", (*client)[0].payload["size"])
+	assert.Equal(t, "pinkbike/price_drop", (*client)[1].topic)
+	assert.Equal(t, "2800", (*client)[1].payload["oldPrice"])
+}
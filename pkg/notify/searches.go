@@ -0,0 +1,121 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"pinkbike-scraper/pkg/geo"
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/parser"
+)
+
+// SavedSearch describes listings worth being notified about. Empty fields match anything.
+type SavedSearch struct {
+	Name string `json:"name"`
+	// Owner identifies who is notified of matches, such as a Telegram chat ID
+	Owner string `json:"owner,omitempty"`
+	// Keywords must all appear in the title or description
+	Keywords     []string `json:"keywords"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+	FrameSize    string   `json:"frameSize"`
+	// WheelSize is a wheel size such as "29" or "27.5", matching bikes
+	// running it front and rear, or "mullet"
+	WheelSize string `json:"wheelSize,omitempty"`
+	// MinCondition is a condition grade such as "good"; listings in worse or
+	// unknown condition do not match
+	MinCondition string `json:"minCondition,omitempty"`
+	// MaxPrice is in USD, zero means no limit
+	MaxPrice float64 `json:"maxPrice"`
+	// Near is a place such as "Calgary, AB", or a "latitude,longitude"
+	// pair, matching listings at most MaxKM from it. Locate geocodes it into
+	// NearPoint; listings without a geocoded location do not match.
+	Near      string    `json:"near,omitempty"`
+	MaxKM     float64   `json:"maxKm,omitempty"`
+	NearPoint geo.Point `json:"nearPoint"`
+}
+
+// Matches reports whether l satisfies every criterion of the search
+func (s SavedSearch) Matches(l listing.Listing) bool {
+	if s.Manufacturer != "" && !strings.EqualFold(s.Manufacturer, l.Manufacturer) {
+		return false
+	}
+	if s.Model != "" && !strings.EqualFold(s.Model, l.Model) {
+		return false
+	}
+	if s.FrameSize != "" && !s.matchesSize(l) {
+		return false
+	}
+	if s.WheelSize != "" && !l.Wheels.Matches(s.WheelSize) {
+		return false
+	}
+	if s.MinCondition != "" && l.ConditionGrade < parser.ParseCondition(s.MinCondition) {
+		return false
+	}
+	if s.MaxPrice > 0 {
+		price, err := strconv.ParseFloat(l.Price, 64)
+		if err != nil || price > s.MaxPrice {
+			return false
+		}
+	}
+	if s.MaxKM > 0 {
+		km, ok := l.DistanceKM(s.NearPoint)
+		if !ok || km > s.MaxKM {
+			return false
+		}
+	}
+
+	text := strings.ToLower(l.Title + " " + l.Details.Description)
+	for _, keyword := range s.Keywords {
+		if !strings.Contains(text, strings.ToLower(keyword)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Locate sets NearPoint to the position of Near, looking it up with geocoder
+// unless it is already known
+func (s *SavedSearch) Locate(geocoder geo.Geocoder) error {
+	if s.Near == "" || !s.NearPoint.IsZero() {
+		return nil
+	}
+	p, err := geo.ParsePlace(s.Near, geocoder)
+	if err != nil {
+		return fmt.Errorf("could not locate search %q: %w", s.Name, err)
+	}
+	s.NearPoint = p
+	return nil
+}
+
+// matchesSize compares sizes on the canonical scale when both can be
+// normalized, so a search for "L" matches a 19.5" frame
+func (s SavedSearch) matchesSize(l listing.Listing) bool {
+	if strings.EqualFold(s.FrameSize, l.FrameSize) {
+		return true
+	}
+	size := (*parser.Sizes)(nil).Normalize(l.Manufacturer, s.FrameSize, "")
+	return size != "" && size == l.NormalizedSize
+}
+
+// LoadSavedSearches reads a JSON array of saved searches
+func LoadSavedSearches(path string) ([]SavedSearch, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read saved searches: %w", err)
+	}
+
+	var searches []SavedSearch
+	if err := json.Unmarshal(data, &searches); err != nil {
+		return nil, fmt.Errorf("could not parse saved searches %s: %w", path, err)
+	}
+	for i, s := range searches {
+		if s.Name == "" {
+			searches[i].Name = fmt.Sprintf("search %d", i+1)
+		}
+	}
+	return searches, nil
+}
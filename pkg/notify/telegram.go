@@ -0,0 +1,179 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"pinkbike-scraper/pkg/events"
+	"pinkbike-scraper/pkg/listing"
+)
+
+const telegramAPIURL = "https://api.telegram.org"
+
+// Telegram is a minimal Telegram Bot API client
+type Telegram struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+func NewTelegram(token string) *Telegram {
+	return &Telegram{
+		baseURL: telegramAPIURL,
+		token:   token,
+		// long enough for getUpdates long polling
+		client: &http.Client{Timeout: 90 * time.Second},
+	}
+}
+
+type telegramResponse struct {
+	OK          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+}
+
+// call posts a Bot API method and decodes its result into out, which may be nil
+func (t *Telegram) call(method string, params interface{}, out interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("could not encode %s request: %w", method, err)
+	}
+
+	resp, err := t.client.Post(fmt.Sprintf("%s/bot%s/%s", t.baseURL, t.token, method), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("could not call telegram %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var r telegramResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return fmt.Errorf("could not decode telegram %s response: %w", method, err)
+	}
+	if !r.OK {
+		return fmt.Errorf("telegram %s failed: %s", method, r.Description)
+	}
+	if out != nil {
+		return json.Unmarshal(r.Result, out)
+	}
+	return nil
+}
+
+// SendMessage sends a plain text message to a chat
+func (t *Telegram) SendMessage(chatID string, text string) error {
+	return t.call("sendMessage", map[string]interface{}{
+		"chat_id": chatID,
+		"text":    text,
+	}, nil)
+}
+
+// SendHTML sends a message formatted with Telegram's HTML subset
+func (t *Telegram) SendHTML(chatID string, html string) error {
+	return t.call("sendMessage", map[string]interface{}{
+		"chat_id":    chatID,
+		"text":       html,
+		"parse_mode": "HTML",
+	}, nil)
+}
+
+// TelegramNotifier sends new listings matching saved searches to the chats
+// that saved them, and large price drops to a default chat
+type TelegramNotifier struct {
+	tg       *Telegram
+	searches []SavedSearch
+	// chatID receives price drops of at least minPriceDrop, empty disables them
+	chatID       string
+	minPriceDrop float64
+	messages     Messages
+}
+
+// NewTelegramNotifier creates a notifier for searches whose Owner is a chat ID.
+// Messages are sent as HTML when messages has HTML templates.
+func NewTelegramNotifier(tg *Telegram, searches []SavedSearch, chatID string, minPriceDrop float64, messages Messages) *TelegramNotifier {
+	return &TelegramNotifier{tg: tg, searches: searches, chatID: chatID, minPriceDrop: minPriceDrop, messages: messages}
+}
+
+// Subscribe registers the notifier for the events it can send
+func (n *TelegramNotifier) Subscribe(bus *events.Bus) {
+	bus.Subscribe("telegram", n.Handle, events.ListingDiscovered, events.PriceChanged)
+}
+
+// Handle sends the event to every chat it is relevant to
+func (n *TelegramNotifier) Handle(e events.Event) error {
+	p := Payload{
+		Event:    e.Kind,
+		Title:    e.Listing.Title,
+		Price:    e.Listing.Price,
+		OldPrice: e.OldPrice,
+		Currency: e.Listing.Currency,
+		URL:      e.Listing.URL,
+		Time:     e.Time,
+	}
+
+	payloads := map[string]Payload{}
+	switch e.Kind {
+	case events.ListingDiscovered:
+		for _, s := range n.searches {
+			if _, sent := payloads[s.Owner]; s.Owner == "" || sent || !s.Matches(e.Listing) {
+				continue
+			}
+			p.Search = s.Name
+			payloads[s.Owner] = p
+		}
+	case events.PriceChanged:
+		if n.chatID != "" && n.minPriceDrop > 0 && priceDrop(e.OldPrice, e.Listing.Price) >= n.minPriceDrop {
+			payloads[n.chatID] = p
+		}
+	}
+
+	for chatID, p := range payloads {
+		if err := n.send(chatID, p, e.Listing); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *TelegramNotifier) send(chatID string, p Payload, l listing.Listing) error {
+	html, ok, err := n.messages.HTML(p, l)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return n.tg.SendHTML(chatID, html)
+	}
+
+	text, err := n.messages.Text(p, l)
+	if err != nil {
+		return err
+	}
+	return n.tg.SendMessage(chatID, text)
+}
+
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Text string `json:"text"`
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+// updates long-polls for messages sent to the bot after offset
+func (t *Telegram) updates(offset int64) ([]telegramUpdate, error) {
+	var updates []telegramUpdate
+	err := t.call("getUpdates", map[string]interface{}{
+		"offset":          offset,
+		"timeout":         60,
+		"allowed_updates": []string{"message"},
+	}, &updates)
+	return updates, err
+}
+
+func chatID(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
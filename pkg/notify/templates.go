@@ -0,0 +1,189 @@
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+
+	"pinkbike-scraper/pkg/brief"
+	"pinkbike-scraper/pkg/events"
+	"pinkbike-scraper/pkg/listing"
+)
+
+// Template names. A template directory overrides one with <name>.txt for the
+// text variant and <name>.html for the HTML variant.
+const (
+	NewListingTemplate = "new_listing"
+	PriceDropTemplate  = "price_drop"
+	// DigestTemplate renders the end of run market brief, a brief.Brief
+	DigestTemplate = "digest"
+)
+
+var templateNames = []string{NewListingTemplate, PriceDropTemplate, DigestTemplate}
+
+// defaultTextTemplates are used for messages a template directory does not override
+var defaultTextTemplates = map[string]string{
+	NewListingTemplate: `New listing{{with .Search}} for {{.}}{{end}}: {{.Title}} {{.Price}} {{.Currency}} {{.URL}}`,
+	PriceDropTemplate:  `Price drop: {{.Title}} {{.OldPrice}} → {{.Price}} {{.Currency}} ({{pct .Drop}}% off) {{.URL}}`,
+}
+
+var templateFuncs = map[string]interface{}{
+	// pct formats a fraction as a whole percentage, 0.25 as 25
+	"pct": func(f float64) string { return fmt.Sprintf("%.0f", f*100) },
+	// money formats a price without cents
+	"money": func(f float64) string { return fmt.Sprintf("%.0f", f) },
+}
+
+// Templates renders notification and digest messages. Every message has a
+// text variant, HTML variants are only used when a template directory has one.
+type Templates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+// DefaultTemplates returns the built-in text templates
+func DefaultTemplates() *Templates {
+	t := &Templates{text: map[string]*texttemplate.Template{}, html: map[string]*htmltemplate.Template{}}
+	for name, src := range defaultTextTemplates {
+		t.text[name] = texttemplate.Must(texttemplate.New(name).Funcs(templateFuncs).Parse(src))
+	}
+	return t
+}
+
+// LoadTemplates starts from the built-in templates and overrides them with the
+// <name>.txt and <name>.html files found in dir
+func LoadTemplates(dir string) (*Templates, error) {
+	t := DefaultTemplates()
+	for _, name := range templateNames {
+		src, err := readTemplate(dir, name+".txt")
+		if err != nil {
+			return nil, err
+		}
+		if src != "" {
+			if t.text[name], err = texttemplate.New(name).Funcs(templateFuncs).Parse(src); err != nil {
+				return nil, fmt.Errorf("could not parse %s.txt: %w", name, err)
+			}
+		}
+
+		if src, err = readTemplate(dir, name+".html"); err != nil {
+			return nil, err
+		}
+		if src != "" {
+			if t.html[name], err = htmltemplate.New(name).Funcs(templateFuncs).Parse(src); err != nil {
+				return nil, fmt.Errorf("could not parse %s.html: %w", name, err)
+			}
+		}
+	}
+	return t, nil
+}
+
+func readTemplate(dir, file string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, file))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not read template %s: %w", file, err)
+	}
+	return string(data), nil
+}
+
+// Text renders the text variant of a template, ok is false when there is none
+func (t *Templates) Text(name string, data interface{}) (string, bool, error) {
+	tmpl, ok := t.text[name]
+	if !ok {
+		return "", false, nil
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", true, fmt.Errorf("could not render %s: %w", name, err)
+	}
+	return strings.TrimSpace(buf.String()), true, nil
+}
+
+// HTML renders the HTML variant of a template, ok is false when there is none
+func (t *Templates) HTML(name string, data interface{}) (string, bool, error) {
+	tmpl, ok := t.html[name]
+	if !ok {
+		return "", false, nil
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", true, fmt.Errorf("could not render %s: %w", name, err)
+	}
+	return strings.TrimSpace(buf.String()), true, nil
+}
+
+// MessageData is what listing notification templates are executed with
+type MessageData struct {
+	Payload
+	Listing listing.Listing
+	// Drop is the fractional decrease of a price change, 0.2 is 20% off
+	Drop float64
+	// Median is the median price of the listing's model across Comps active
+	// listings, both zero when comps are not available
+	Median float64
+	Comps  int
+	// DealScore is how far below the median the listing is priced, 0.2 is 20%
+	// under and negative above it. Zero when the listing cannot be scored.
+	DealScore float64
+}
+
+// Messages renders event notifications, adding the listing's comps and deal
+// score when Comps is set
+type Messages struct {
+	Templates *Templates
+	Comps     brief.MedianFunc
+}
+
+func (m Messages) templates() *Templates {
+	if m.Templates == nil {
+		return DefaultTemplates()
+	}
+	return m.Templates
+}
+
+func (m Messages) data(p Payload, l listing.Listing) MessageData {
+	d := MessageData{Payload: p, Listing: l, Drop: priceDrop(p.OldPrice, p.Price)}
+	if m.Comps == nil || l.Manufacturer == "" {
+		return d
+	}
+
+	median, count, err := m.Comps(l.Manufacturer, l.Model, l.Generation)
+	if err != nil {
+		return d
+	}
+	d.Median, d.Comps = median, count
+
+	comps := func(string, string, string) (float64, int, error) { return median, count, nil }
+	if deal, ok := brief.Rate(l, comps); ok {
+		d.DealScore = deal.Score
+	}
+	return d
+}
+
+func templateFor(kind events.Kind) string {
+	if kind == events.PriceChanged {
+		return PriceDropTemplate
+	}
+	return NewListingTemplate
+}
+
+// Text renders the plain text message for a payload
+func (m Messages) Text(p Payload, l listing.Listing) (string, error) {
+	text, _, err := m.templates().Text(templateFor(p.Event), m.data(p, l))
+	return text, err
+}
+
+// HTML renders the HTML message for a payload, ok is false when no HTML
+// template was loaded for it
+func (m Messages) HTML(p Payload, l listing.Listing) (string, bool, error) {
+	return m.templates().HTML(templateFor(p.Event), m.data(p, l))
+}
@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pinkbike-scraper/pkg/brief"
+	"pinkbike-scraper/pkg/events"
+	"pinkbike-scraper/pkg/listing"
+)
+
+func TestLoadTemplates(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new_listing.txt"),
+		[]byte(`{{.Listing.Manufacturer}} {{.Listing.Model}} for ${{.Price}}, {{pct .DealScore}}% under the ${{money .Median}} median of {{.Comps}}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new_listing.html"),
+		[]byte(`<a href="{{.URL}}">{{.Title}}</a>`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "digest.txt"),
+		[]byte(`{{.New}} new of {{.Listings}}{{range .Deals}}; {{.Listing.Title}}{{end}}`), 0644))
+
+	templates, err := LoadTemplates(dir)
+	require.NoError(t, err)
+
+	messages := Messages{
+		Templates: templates,
+		Comps: func(manufacturer, model, generation string) (float64, int, error) {
+			return 4000, 12, nil
+		},
+	}
+	l := listing.Listing{Title: "2021 Santa Cruz <Megatower>", Manufacturer: "Santa Cruz", Model: "Megatower", Price: "3000", URL: "https://www.pinkbike.com/buysell/1/"}
+	p := Payload{Event: events.ListingDiscovered, Title: l.Title, Price: l.Price, URL: l.URL}
+
+	text, err := messages.Text(p, l)
+	require.NoError(t, err)
+	assert.Equal(t, "Santa Cruz Megatower for $3000, 25% under the $4000 median of 12", text)
+
+	html, ok, err := messages.HTML(p, l)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, `<a href="https://www.pinkbike.com/buysell/1/">2021 Santa Cruz &lt;Megatower&gt;</a>`, html)
+
+	// price drops were not overridden and keep the built-in text without HTML
+	p.Event, p.OldPrice = events.PriceChanged, "3500"
+	text, err = messages.Text(p, l)
+	require.NoError(t, err)
+	assert.Contains(t, text, "Price drop: 2021 Santa Cruz <Megatower> 3500 → 3000")
+	_, ok, err = messages.HTML(p, l)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	digest, ok, err := templates.Text(DigestTemplate, brief.Brief{New: 2, Listings: 40, Deals: []brief.Deal{{Listing: l}}})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "2 new of 40; 2021 Santa Cruz <Megatower>", digest)
+
+	_, ok, _ = DefaultTemplates().Text(DigestTemplate, brief.Brief{})
+	assert.False(t, ok, "the digest has no built-in template")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "price_drop.txt"), []byte(`{{.Title`), 0644))
+	_, err = LoadTemplates(dir)
+	assert.Error(t, err)
+}
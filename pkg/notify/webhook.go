@@ -0,0 +1,238 @@
+// Package notify sends listing events to external services
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"pinkbike-scraper/pkg/brief"
+	"pinkbike-scraper/pkg/events"
+	"pinkbike-scraper/pkg/listing"
+)
+
+// Format selects the JSON body posted to a webhook
+type Format string
+
+const (
+	// JSONFormat posts the structured Payload
+	JSONFormat Format = "json"
+	// DiscordFormat posts {"content": "..."} as Discord webhooks expect
+	DiscordFormat Format = "discord"
+	// SlackFormat posts {"text": "..."} as Slack incoming webhooks expect
+	SlackFormat Format = "slack"
+)
+
+// ParseFormat validates a webhook format name
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case "":
+		return JSONFormat, nil
+	case JSONFormat, DiscordFormat, SlackFormat:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown webhook format %q (available: json, discord, slack)", s)
+}
+
+// WebhookOptions selects which events are sent
+type WebhookOptions struct {
+	Format Format
+	// Searches limits new listing notifications to listings matching one of
+	// them. With no searches every new listing is sent.
+	Searches []SavedSearch
+	// MinPriceDrop is the fractional drop (0.1 for 10%) a price change needs to
+	// be sent. Zero disables price drop notifications.
+	MinPriceDrop float64
+	// Messages renders the Discord and Slack message text
+	Messages Messages
+	// Queue keeps posts that failed so a later run can send them, nil drops them
+	Queue Queue
+}
+
+// Queue persists payloads a sink failed to take
+type Queue interface {
+	QueueExport(sink string, payload []byte, cause error) error
+}
+
+// Payload is the body posted in JSONFormat
+type Payload struct {
+	Event    events.Kind `json:"event"`
+	Search   string      `json:"search,omitempty"`
+	Title    string      `json:"title"`
+	Price    string      `json:"price"`
+	OldPrice string      `json:"oldPrice,omitempty"`
+	Currency string      `json:"currency"`
+	URL      string      `json:"url"`
+	Time     time.Time   `json:"time"`
+}
+
+// Webhook posts new listings matching saved searches and large price drops to a URL
+type Webhook struct {
+	url    string
+	opts   WebhookOptions
+	client *http.Client
+}
+
+func NewWebhook(url string, opts WebhookOptions) *Webhook {
+	if opts.Format == "" {
+		opts.Format = JSONFormat
+	}
+	return &Webhook{url: url, opts: opts, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Subscribe registers the webhook for the events it can send
+func (w *Webhook) Subscribe(bus *events.Bus) {
+	bus.Subscribe("webhook", w.Handle, events.ListingDiscovered, events.PriceChanged)
+}
+
+// Handle posts the event if it is a matching new listing or a large enough price drop
+func (w *Webhook) Handle(e events.Event) error {
+	payload, ok := w.payload(e)
+	if !ok {
+		return nil
+	}
+
+	formatted, err := w.format(payload, e.Listing)
+	if err != nil {
+		return err
+	}
+
+	return w.send(formatted)
+}
+
+// DealPayload is a deal in the body PostDeals sends in JSONFormat
+type DealPayload struct {
+	Title    string  `json:"title"`
+	Price    string  `json:"price"`
+	Currency string  `json:"currency"`
+	URL      string  `json:"url"`
+	Median   float64 `json:"median"`
+	Score    float64 `json:"score"`
+	Comps    int     `json:"comps"`
+	Year     string  `json:"year,omitempty"`
+}
+
+// PostDeals sends ranked deals in one post, as {"deals": [...]} in
+// JSONFormat and as a numbered list in the chat formats
+func (w *Webhook) PostDeals(deals []brief.Deal) error {
+	if key := map[Format]string{DiscordFormat: "content", SlackFormat: "text"}[w.opts.Format]; key != "" {
+		var text strings.Builder
+		text.WriteString("Top deals:\n")
+		brief.WriteDeals(&text, deals)
+		return w.send(map[string]string{key: text.String()})
+	}
+
+	payload := struct {
+		Deals []DealPayload `json:"deals"`
+	}{Deals: []DealPayload{}}
+	for _, d := range deals {
+		payload.Deals = append(payload.Deals, DealPayload{
+			Title:    d.Listing.Title,
+			Price:    d.Listing.Price,
+			Currency: d.Listing.Currency,
+			URL:      d.Listing.URL,
+			Median:   d.Median,
+			Score:    d.Score,
+			Comps:    d.Comps,
+			Year:     d.Year,
+		})
+	}
+	return w.send(payload)
+}
+
+// send encodes and posts a body, queueing it for a later run when the post fails
+func (w *Webhook) send(formatted interface{}) error {
+	body, err := json.Marshal(formatted)
+	if err != nil {
+		return fmt.Errorf("could not encode webhook payload: %w", err)
+	}
+
+	postErr := w.Post(body)
+	if postErr == nil || w.opts.Queue == nil {
+		return postErr
+	}
+	if err := w.opts.Queue.QueueExport(w.Sink(), body, postErr); err != nil {
+		return fmt.Errorf("%v, and %w", postErr, err)
+	}
+	return fmt.Errorf("%w (queued for retry)", postErr)
+}
+
+// Sink names the webhook's queue of failed posts
+func (w *Webhook) Sink() string {
+	return "webhook:" + w.url
+}
+
+// Post sends an encoded payload to the webhook
+func (w *Webhook) Post(body []byte) error {
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("could not post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (w *Webhook) payload(e events.Event) (Payload, bool) {
+	p := Payload{
+		Event:    e.Kind,
+		Title:    e.Listing.Title,
+		Price:    e.Listing.Price,
+		OldPrice: e.OldPrice,
+		Currency: e.Listing.Currency,
+		URL:      e.Listing.URL,
+		Time:     e.Time,
+	}
+
+	switch e.Kind {
+	case events.ListingDiscovered:
+		if len(w.opts.Searches) == 0 {
+			return p, true
+		}
+		for _, s := range w.opts.Searches {
+			if s.Matches(e.Listing) {
+				p.Search = s.Name
+				return p, true
+			}
+		}
+	case events.PriceChanged:
+		if w.opts.MinPriceDrop > 0 && priceDrop(e.OldPrice, e.Listing.Price) >= w.opts.MinPriceDrop {
+			return p, true
+		}
+	}
+	return p, false
+}
+
+// priceDrop returns the fractional decrease from old to new, or zero if either
+// price is not a number or the price went up
+func priceDrop(oldPrice, newPrice string) float64 {
+	o, err := strconv.ParseFloat(oldPrice, 64)
+	if err != nil || o <= 0 {
+		return 0
+	}
+	n, err := strconv.ParseFloat(newPrice, 64)
+	if err != nil || n >= o {
+		return 0
+	}
+	return (o - n) / o
+}
+
+func (w *Webhook) format(p Payload, l listing.Listing) (interface{}, error) {
+	key := map[Format]string{DiscordFormat: "content", SlackFormat: "text"}[w.opts.Format]
+	if key == "" {
+		return p, nil
+	}
+
+	text, err := w.opts.Messages.Text(p, l)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{key: text}, nil
+}
@@ -0,0 +1,146 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pinkbike-scraper/pkg/brief"
+	"pinkbike-scraper/pkg/events"
+	"pinkbike-scraper/pkg/geo"
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/parser"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSendsMatchingEvents(t *testing.T) {
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		bodies = append(bodies, body)
+	}))
+	defer server.Close()
+
+	bus := events.NewBus()
+	NewWebhook(server.URL, WebhookOptions{
+		Searches:     []SavedSearch{{Name: "cheap nomad", Model: "Nomad", MaxPrice: 3000}},
+		MinPriceDrop: 0.1,
+	}).Subscribe(bus)
+
+	nomad := listing.Listing{Title: "2019 Santa Cruz Nomad", Model: "Nomad", Price: "2800", Currency: "USD"}
+	bus.Publish(events.Event{Kind: events.ListingDiscovered, Listing: nomad})
+	bus.Publish(events.Event{Kind: events.ListingDiscovered, Listing: listing.Listing{Title: "2020 Yeti SB150", Model: "SB150", Price: "2800"}})
+
+	nomad.Price = "2700"
+	bus.Publish(events.Event{Kind: events.PriceChanged, Listing: nomad, OldPrice: "2800"})
+	nomad.Price = "2400"
+	bus.Publish(events.Event{Kind: events.PriceChanged, Listing: nomad, OldPrice: "2700"})
+
+	require.Len(t, bodies, 2)
+	assert.Equal(t, "listing_discovered", bodies[0]["event"])
+	assert.Equal(t, "cheap nomad", bodies[0]["search"])
+	assert.Equal(t, "price_changed", bodies[1]["event"])
+	assert.Equal(t, "2700", bodies[1]["oldPrice"])
+}
+
+type queuedPost struct {
+	sink, payload, cause string
+}
+
+type memoryQueue []queuedPost
+
+func (q *memoryQueue) QueueExport(sink string, payload []byte, cause error) error {
+	*q = append(*q, queuedPost{sink, string(payload), cause.Error()})
+	return nil
+}
+
+func TestWebhookQueuesFailedPosts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	var queue memoryQueue
+	webhook := NewWebhook(server.URL, WebhookOptions{Format: SlackFormat, Queue: &queue})
+
+	err := webhook.Handle(events.Event{Kind: events.ListingDiscovered, Listing: listing.Listing{Title: "2019 Santa Cruz Nomad", Price: "2800"}})
+	assert.ErrorContains(t, err, "queued for retry")
+	require.Len(t, queue, 1)
+	assert.Equal(t, "webhook:"+server.URL, queue[0].sink)
+	assert.Contains(t, queue[0].payload, `"text":"New listing: 2019 Santa Cruz Nomad 2800`)
+	assert.Equal(t, "webhook returned status 502", queue[0].cause)
+}
+
+func TestWebhookChatFormats(t *testing.T) {
+	p := Payload{Event: events.PriceChanged, Title: "2019 Santa Cruz Nomad", Price: "2400", OldPrice: "3000", Currency: "USD", URL: "https://www.pinkbike.com/buysell/1/"}
+
+	discord, err := NewWebhook("", WebhookOptions{Format: DiscordFormat}).format(p, listing.Listing{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"content": "Price drop: 2019 Santa Cruz Nomad 3000 → 2400 USD (20% off) https://www.pinkbike.com/buysell/1/"}, discord)
+
+	slack, err := NewWebhook("", WebhookOptions{Format: SlackFormat}).format(p, listing.Listing{})
+	require.NoError(t, err)
+	assert.Contains(t, slack.(map[string]string)["text"], "Price drop")
+
+	_, err = ParseFormat("teams")
+	assert.Error(t, err)
+}
+
+func TestSavedSearchMatches(t *testing.T) {
+	l := listing.Listing{Title: "2021 Evil Wreckoning", Manufacturer: "Evil", Price: "3900", FrameSize: "L",
+		Details: listing.ListingDetails{Description: "Coil shock, warranty transferable"}}
+
+	assert.True(t, SavedSearch{Keywords: []string{"coil", "warranty"}}.Matches(l))
+	assert.True(t, SavedSearch{Manufacturer: "evil", FrameSize: "l", MaxPrice: 4000}.Matches(l))
+	assert.False(t, SavedSearch{MaxPrice: 3500}.Matches(l))
+	assert.False(t, SavedSearch{Keywords: []string{"air shock"}}.Matches(l))
+
+	l.FrameSize, l.NormalizedSize = `19.5"`, "L"
+	assert.True(t, SavedSearch{FrameSize: "Large"}.Matches(l))
+	assert.False(t, SavedSearch{FrameSize: "M"}.Matches(l))
+
+	l.Wheels = parser.ParseWheels("29/27.5 mullet", "")
+	assert.True(t, SavedSearch{WheelSize: "mullet"}.Matches(l))
+	assert.False(t, SavedSearch{WheelSize: "29"}.Matches(l))
+
+	l.ConditionGrade = parser.ConditionGood
+	assert.True(t, SavedSearch{MinCondition: "fair"}.Matches(l))
+	assert.False(t, SavedSearch{MinCondition: "excellent"}.Matches(l))
+
+	calgary := geo.Point{Lat: 51.0447, Lon: -114.0719}
+	near := SavedSearch{Near: "Calgary, AB", MaxKM: 150, NearPoint: calgary}
+	assert.False(t, near.Matches(l), "listings without a location are not near anywhere")
+	l.Coordinates = geo.Point{Lat: 51.1784, Lon: -115.5708}
+	assert.True(t, near.Matches(l), "Banff is about 106km from Calgary")
+	near.MaxKM = 100
+	assert.False(t, near.Matches(l))
+}
+
+func TestWebhookPostsDeals(t *testing.T) {
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		bodies = append(bodies, body)
+	}))
+	defer server.Close()
+
+	deals := []brief.Deal{{
+		Listing: listing.Listing{Title: "2019 Santa Cruz Nomad", Price: "2800", Currency: "USD", URL: "https://pinkbike.com/1"},
+		Median:  3500, Score: 0.2, Comps: 6, Year: "2019",
+	}}
+	require.NoError(t, NewWebhook(server.URL, WebhookOptions{}).PostDeals(deals))
+	require.NoError(t, NewWebhook(server.URL, WebhookOptions{Format: DiscordFormat}).PostDeals(deals))
+
+	require.Len(t, bodies, 2)
+	require.Len(t, bodies[0]["deals"], 1)
+	deal := bodies[0]["deals"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "2019 Santa Cruz Nomad", deal["title"])
+	assert.Equal(t, 0.2, deal["score"])
+	assert.Equal(t, "2019", deal["year"])
+	assert.Contains(t, bodies[1]["content"], "20% under $3500 2019 median")
+}
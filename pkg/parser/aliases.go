@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Aliases maps alternative names found in titles ("SC", "Tallboy LT") to
+// canonical manufacturer and model names the model database does not know
+type Aliases struct {
+	manufacturers []alias
+	models        map[string][]alias
+}
+
+type alias struct {
+	pattern   *regexp.Regexp
+	canonical string
+}
+
+func newAlias(name, canonical string) alias {
+	return alias{
+		pattern:   regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(strings.TrimSpace(name)) + `\b`),
+		canonical: canonical,
+	}
+}
+
+// AddManufacturer registers name as another way titles refer to manufacturer
+func (a *Aliases) AddManufacturer(name, manufacturer string) {
+	a.manufacturers = append(a.manufacturers, newAlias(name, manufacturer))
+}
+
+// AddModel registers name as another way titles refer to a manufacturer's model
+func (a *Aliases) AddModel(manufacturer, name, model string) {
+	if a.models == nil {
+		a.models = map[string][]alias{}
+	}
+	a.models[manufacturer] = append(a.models[manufacturer], newAlias(name, model))
+}
+
+// Manufacturer returns the manufacturer an alias in title refers to, or "" if none match
+func (a *Aliases) Manufacturer(title string) string {
+	if a == nil {
+		return ""
+	}
+	for _, al := range a.manufacturers {
+		if al.pattern.MatchString(title) {
+			return al.canonical
+		}
+	}
+	return ""
+}
+
+// Model returns the model of manufacturer an alias in title refers to, or "" if none match
+func (a *Aliases) Model(manufacturer, title string) string {
+	if a == nil {
+		return ""
+	}
+	for _, al := range a.models[manufacturer] {
+		if al.pattern.MatchString(title) {
+			return al.canonical
+		}
+	}
+	return ""
+}
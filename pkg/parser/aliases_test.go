@@ -0,0 +1,21 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAliases(t *testing.T) {
+	var aliases Aliases
+	aliases.AddManufacturer("SC", "Santa Cruz")
+	aliases.AddModel("Santa Cruz", "Tallboy LT", "Tallboy")
+
+	assert.Equal(t, "Santa Cruz", aliases.Manufacturer("2019 SC Nomad 27.5"))
+	assert.Equal(t, "", aliases.Manufacturer("2019 Scott Genius"), "aliases only match whole words")
+	assert.Equal(t, "Tallboy", aliases.Model("Santa Cruz", "2018 sc tallboy lt carbon"))
+	assert.Equal(t, "", aliases.Model("Yeti", "2018 sc tallboy lt carbon"))
+
+	var none *Aliases
+	assert.Equal(t, "", none.Manufacturer("2019 SC Nomad"))
+}
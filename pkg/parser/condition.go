@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"strings"
+)
+
+// ConditionGrade ranks a listing's condition so conditions can be compared
+// numerically; higher is better and zero means unknown
+type ConditionGrade int
+
+const (
+	ConditionUnknown ConditionGrade = iota
+	ConditionPoor
+	ConditionFair
+	ConditionGood
+	ConditionExcellent
+	ConditionNew
+)
+
+var conditionNames = []string{"unknown", "poor", "fair", "good", "excellent", "new"}
+
+// conditionWords are matched in order against the start of the condition
+// text, so "like new" is graded before "new"
+var conditionWords = []struct {
+	word  string
+	grade ConditionGrade
+}{
+	{"like new", ConditionExcellent},
+	{"new", ConditionNew},
+	{"excellent", ConditionExcellent},
+	{"good", ConditionGood},
+	{"fair", ConditionFair},
+	{"average", ConditionFair},
+	{"poor", ConditionPoor},
+	{"for parts", ConditionPoor},
+}
+
+func (g ConditionGrade) String() string {
+	if g < 0 || int(g) >= len(conditionNames) {
+		return conditionNames[ConditionUnknown]
+	}
+	return conditionNames[g]
+}
+
+// ParseCondition grades Pinkbike's condition text, such as "Excellent -
+// Lightly Ridden" or "Good - Used, Mechanically Sound", by its leading word.
+// Grade names like "good" parse too, so filters can be written with them.
+func ParseCondition(text string) ConditionGrade {
+	text = strings.ToLower(strings.TrimSpace(text))
+	for _, c := range conditionWords {
+		if strings.HasPrefix(text, c.word) {
+			return c.grade
+		}
+	}
+	return ConditionUnknown
+}
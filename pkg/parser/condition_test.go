@@ -0,0 +1,28 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCondition(t *testing.T) {
+	tests := map[string]ConditionGrade{
+		"New - Unridden/With Tags":        ConditionNew,
+		"Excellent - Lightly Ridden":      ConditionExcellent,
+		"Good - Used, Mechanically Sound": ConditionGood,
+		"Fair - Used, Needs Some TLC":     ConditionFair,
+		"Poor - For Parts":                ConditionPoor,
+		"like new":                        ConditionExcellent,
+		" GOOD ":                          ConditionGood,
+		"":                                ConditionUnknown,
+		"Ridden twice":                    ConditionUnknown,
+	}
+	for text, want := range tests {
+		assert.Equal(t, want, ParseCondition(text), text)
+	}
+
+	assert.True(t, ConditionExcellent > ConditionGood)
+	assert.Equal(t, "excellent", ConditionExcellent.String())
+	assert.Equal(t, "unknown", ConditionGrade(42).String())
+}
@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// electricPattern matches words sellers use to mark an e-bike
+var electricPattern = regexp.MustCompile(`(?i)\b(e-?bike|e-?mtb|pedelec)s?\b`)
+
+// motors are matched in order, so specific drive units come before the brand
+var motors = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"Shimano EP801", regexp.MustCompile(`(?i)\bep ?801\b`)},
+	{"Shimano EP8", regexp.MustCompile(`(?i)\bep ?8\b`)},
+	{"Shimano E8000", regexp.MustCompile(`(?i)\be ?8000\b`)},
+	{"Shimano E7000", regexp.MustCompile(`(?i)\be ?7000\b`)},
+	{"Bosch Performance Line CX", regexp.MustCompile(`(?i)\bperformance (line )?cx\b`)},
+	{"Bosch Performance Line SX", regexp.MustCompile(`(?i)\bperformance (line )?sx\b`)},
+	{"Bosch", regexp.MustCompile(`(?i)\bbosch\b`)},
+	{"Specialized 2.2", regexp.MustCompile(`(?i)\b(specialized|brose) ?(motor )?2\.2\b`)},
+	{"Specialized 2.1", regexp.MustCompile(`(?i)\b(specialized|brose) ?(motor )?2\.1\b`)},
+	{"Brose", regexp.MustCompile(`(?i)\bbrose\b`)},
+	{"Fazua Ride 60", regexp.MustCompile(`(?i)\bride ?60\b`)},
+	{"Fazua", regexp.MustCompile(`(?i)\bfazua\b`)},
+	{"TQ HPR50", regexp.MustCompile(`(?i)\bhpr ?50\b`)},
+	{"Yamaha PW-X3", regexp.MustCompile(`(?i)\bpw-?x3\b`)},
+	{"DJI Avinox", regexp.MustCompile(`(?i)\bavinox\b`)},
+}
+
+var batteryPattern = regexp.MustCompile(`(?i)\b(\d{3,4}) ?wh\b`)
+
+// IsElectric reports whether a title or the model found in it names an e-bike
+func IsElectric(title, model string) bool {
+	return strings.HasSuffix(model, " Electric") || electricPattern.MatchString(title)
+}
+
+// ExtractMotor returns the e-bike drive unit named in text, or "" if none is
+func ExtractMotor(text string) string {
+	for _, m := range motors {
+		if m.pattern.MatchString(text) {
+			return m.name
+		}
+	}
+	return ""
+}
+
+// ExtractBatteryWh returns the battery capacity in watt hours named in text,
+// or 0 if none is. Numbers outside the range of e-bike batteries are ignored.
+func ExtractBatteryWh(text string) int {
+	for _, m := range batteryPattern.FindAllStringSubmatch(text, -1) {
+		wh, err := strconv.Atoi(m[1])
+		if err == nil && wh >= 150 && wh <= 1500 {
+			return wh
+		}
+	}
+	return 0
+}
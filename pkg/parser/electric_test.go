@@ -0,0 +1,27 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsElectric(t *testing.T) {
+	assert.True(t, IsElectric("2022 Specialized Turbo Levo", "Turbo Levo Electric"))
+	assert.True(t, IsElectric("Orbea Wild E-MTB size L", "NoModelFound"))
+	assert.True(t, IsElectric("Norco Sight VLT ebike", "Sight"))
+	assert.False(t, IsElectric("2021 Norco Sight C2", "Sight"))
+}
+
+func TestExtractMotor(t *testing.T) {
+	assert.Equal(t, "Shimano EP801", ExtractMotor("Shimano EP801 motor, 630wh battery"))
+	assert.Equal(t, "Shimano EP8", ExtractMotor("EP8 with 504 Wh battery"))
+	assert.Equal(t, "Bosch Performance Line CX", ExtractMotor("bosch performance line cx gen 4"))
+	assert.Equal(t, "Bosch", ExtractMotor("Bosch motor"))
+	assert.Equal(t, "Specialized 2.2", ExtractMotor("Specialized 2.2 motor rebuilt"))
+	assert.Equal(t, "", ExtractMotor("Fox 36 fork, 150mm"))
+
+	assert.Equal(t, 630, ExtractBatteryWh("Shimano EP801 motor, 630wh battery"))
+	assert.Equal(t, 504, ExtractBatteryWh("EP8 with 504 Wh battery"))
+	assert.Equal(t, 0, ExtractBatteryWh("20 wh light and a 2400wh charger"))
+}
@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Generation is one version of a model, such as the second Megatower, with
+// the model years it was sold in
+type Generation struct {
+	Name    string
+	Years   YearRange
+	pattern *regexp.Regexp
+}
+
+// newGeneration matches how sellers write a generation right after the model
+// name, as "V2", "2" or "Gen 2" when pattern is the bare number
+func newGeneration(name, pattern string, min, max int) Generation {
+	return Generation{name, YearRange{min, max}, regexp.MustCompile(`(?i)^\s*(v|gen\s*|mk\s*)?` + pattern + `\b`)}
+}
+
+// modelGenerations are the generations of models that changed enough between
+// versions to sell for very different prices. A model is listed the way
+// ExtractModel names it, and a generation that adds letters, such as the
+// V2S, comes before the one it extends.
+var modelGenerations = map[string]map[string][]Generation{
+	"Ibis": {
+		"Ripmo": {
+			newGeneration("V2S", `2\s*s`, 2023, 0),
+			newGeneration("V1", `1`, 2018, 2020),
+			newGeneration("V2", `2`, 2021, 0),
+			newGeneration("V3", `3`, 2025, 0),
+		},
+		"Ripley": {
+			newGeneration("V3", `3`, 2016, 2018),
+			newGeneration("V4", `4`, 2019, 0),
+			newGeneration("V5", `5`, 2025, 0),
+		},
+	},
+	"Santa Cruz": {
+		"Megatower": {
+			newGeneration("V1", `1`, 2019, 2021),
+			newGeneration("V2", `2`, 2022, 0),
+		},
+		"Hightower": {
+			newGeneration("V1", `1`, 2016, 2018),
+			newGeneration("V2", `2`, 2019, 2021),
+			newGeneration("V3", `3`, 2022, 0),
+		},
+		"Nomad": {
+			newGeneration("V3", `3`, 2014, 2017),
+			newGeneration("V4", `4`, 2018, 2020),
+			newGeneration("V5", `5`, 2021, 2022),
+			newGeneration("V6", `6`, 2023, 0),
+		},
+		"Bronson": {
+			newGeneration("V3", `3`, 2019, 2021),
+			newGeneration("V4", `4`, 2022, 0),
+		},
+		"Tallboy": {
+			newGeneration("V4", `4`, 2019, 2021),
+			newGeneration("V5", `5`, 2022, 0),
+		},
+		"5010": {
+			newGeneration("V3", `3`, 2018, 2020),
+			newGeneration("V4", `4`, 2021, 0),
+		},
+	},
+	"Evil": {
+		"Offering": {
+			newGeneration("V1", `1`, 2018, 2021),
+			newGeneration("V2", `2`, 2022, 0),
+		},
+	},
+}
+
+// versionPattern is a generation a seller marks as one, such as "V2",
+// "Gen 4" or "Mk2", for models the table does not know
+var versionPattern = regexp.MustCompile(`(?i)^\s*(?:v|gen(?:eration)?\s*|mk\s*|version\s*)(\d{1,2})\b`)
+
+// ExtractGeneration returns the generation of a manufacturer's model the
+// title names right after the model, such as "V2" for "Ripmo V2" or
+// "Megatower 2", or "" when it names none. Bare numbers count only for models
+// whose generations are known, since "Slash 8" is a build, not a generation.
+func ExtractGeneration(manufacturer, model, title string) string {
+	model = strings.TrimSuffix(model, " Electric")
+	if model == "" || model == "NoModelFound" {
+		return ""
+	}
+	title = strings.ToLower(title)
+	i := strings.Index(title, strings.ToLower(model))
+	if i < 0 {
+		return ""
+	}
+	after := title[i+len(model):]
+	for _, g := range modelGenerations[manufacturer][model] {
+		if g.pattern.MatchString(after) {
+			return g.Name
+		}
+	}
+	if m := versionPattern.FindStringSubmatch(after); m != nil {
+		return "V" + m[1]
+	}
+	return ""
+}
+
+// GenerationYears returns the model years a known generation was sold in
+func GenerationYears(manufacturer, model, generation string) (YearRange, bool) {
+	for _, g := range modelGenerations[manufacturer][strings.TrimSuffix(model, " Electric")] {
+		if g.Name == generation {
+			return g.Years, true
+		}
+	}
+	return YearRange{}, false
+}
@@ -0,0 +1,49 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractGeneration(t *testing.T) {
+	tests := []struct {
+		title, want string
+	}{
+		{"2021 Ibis Ripmo V2 XT", "V2"},
+		{"2023 Ibis Ripmo V2S Large", "V2S"},
+		{"2022 Ibis Ripmo v2 s", "V2S"},
+		{"Ibis Ripmo AF Deore", ""},
+		{"2022 Santa Cruz Megatower 2 CC X01", "V2"},
+		{"Santa Cruz Nomad 4 CC", "V4"},
+		{"Santa Cruz Nomad Gen 5", "V5"},
+		{"Santa Cruz Hightower 29 C", ""},
+		{"2019 Santa Cruz Megatower 1x12", ""},
+		{"Specialized Stumpjumper EVO Expert", ""},
+		{"2017 Kona Process 153 Mk2", "V2"},
+		{"Trek Slash 8 2020", ""},
+		{"Trek Slash Gen 6 9.8", "V6"},
+	}
+	for _, tt := range tests {
+		model := ExtractModel(tt.title)
+		assert.Equal(t, tt.want, ExtractGeneration(ExtractManufacturer(tt.title), model, tt.title), tt.title)
+	}
+}
+
+func TestExtractModelPrefersLongerName(t *testing.T) {
+	assert.Equal(t, "Stumpjumper EVO", ExtractModel("2021 Specialized Stumpjumper EVO Comp"))
+	assert.Equal(t, "Geometron G16", ExtractModel("Nicolai Geometron G16 size 4"))
+	assert.Equal(t, "Ripmo", ExtractModel("Ibis Ripmo V2 XT"))
+	assert.Equal(t, "Spark", ExtractModel("2022 NEW Scott Contessa Spark 920"))
+}
+
+func TestGenerationYears(t *testing.T) {
+	years, ok := GenerationYears("Santa Cruz", "Megatower", "V1")
+	assert.True(t, ok)
+	assert.Equal(t, YearRange{2019, 2021}, years)
+
+	_, ok = GenerationYears("Santa Cruz", "Megatower", "V7")
+	assert.False(t, ok)
+	_, ok = GenerationYears("Trek", "Slash", "V6")
+	assert.False(t, ok)
+}
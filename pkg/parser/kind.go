@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// Kind is what a listing sells: a complete bike, or a frame, fork, wheels or
+// other parts listed in the bike categories
+type Kind string
+
+const (
+	KindBike   Kind = "bike"
+	KindFrame  Kind = "frame"
+	KindFork   Kind = "fork"
+	KindWheels Kind = "wheels"
+	KindParts  Kind = "parts"
+)
+
+// Kinds are the listing kinds, complete bikes first
+var Kinds = []Kind{KindBike, KindFrame, KindFork, KindWheels, KindParts}
+
+// IsBike reports whether k is a complete bike. Listings stored before kinds
+// were detected have none and are taken to be bikes.
+func (k Kind) IsBike() bool {
+	return k == "" || k == KindBike
+}
+
+var (
+	// completePattern marks a title as a complete bike whatever parts it names
+	completePattern = regexp.MustCompile(`(?i)\b(complete|full build|whole bike)\b`)
+	framePattern    = regexp.MustCompile(`(?i)\b(frame ?sets?|frame only|frame\s*(and|&|\+|w/|with)\s*(rear )?shock)\b|\bframe\s*($|[-,(|/])`)
+	forkPattern     = regexp.MustCompile(`(?i)\bforks?\b|^(\d{4}\s+)?(fox|rock ?shox|marzocchi|manitou|[oö]hlins|dvo|formula)\s+(factory\s+|performance\s+)?(32|34|36|38|40|lyrik|pike|zeb|domain|yari|sid|boxxer|z1|bomber|mezzer|mattoc|rxf|onyx|sapphire|diamond|selva|belva)\b`)
+	wheelsPattern   = regexp.MustCompile(`(?i)\b(wheel ?sets?|wheels|(front|rear) wheel)\b`)
+	partsPattern    = regexp.MustCompile(`(?i)\b(shock|derailleur|cranks?(et)?|brakes?|cassette|dropper|seat ?post|handlebars?|stem|saddle|pedals|tires?|tyres?|rotors?|groupset|chain ?ring|hubs?|rims?|headset|grips)\b`)
+)
+
+// DetectKind tells from a title whether a listing sells a complete bike, a
+// frame, a fork, wheels or other parts. A title naming a known bike model is
+// a bike even when it mentions parts, as "Evil Wreckoning w/ new fork" does,
+// unless it says the frame is sold on its own.
+func DetectKind(title string) Kind {
+	switch {
+	case completePattern.MatchString(title):
+		return KindBike
+	case framePattern.MatchString(title):
+		return KindFrame
+	case ExtractModel(title) != "NoModelFound":
+		return KindBike
+	case forkPattern.MatchString(title):
+		return KindFork
+	case wheelsPattern.MatchString(title):
+		return KindWheels
+	case partsPattern.MatchString(title):
+		return KindParts
+	}
+	return KindBike
+}
+
+var (
+	// shockSizePattern matches eye to eye length by stroke, such as 230x65
+	// or 210 x 55mm
+	shockSizePattern = regexp.MustCompile(`(?i)\b(\d{3})(?:\.\d)?\s*(?:mm)?\s*[x×]\s*(\d{2}(?:\.\d)?)\s*(?:mm)?\b`)
+	// steererPattern matches a steerer length given before or after the word
+	steererPattern = regexp.MustCompile(`(?i)\bsteerer\b[^0-9.\n]{0,20}(\d{3})(?:\.\d)?\s*mm|\b(\d{3})(?:\.\d)?\s*mm\s+(?:long\s+)?steerer\b`)
+)
+
+// ExtractShockSize returns the rear shock size text gives, eye to eye length
+// by stroke in millimetres such as "230x65", or "" when it gives none
+func ExtractShockSize(text string) string {
+	for _, m := range shockSizePattern.FindAllStringSubmatch(text, -1) {
+		eye, _ := strconv.Atoi(m[1])
+		stroke, _ := strconv.ParseFloat(m[2], 64)
+		if eye >= 150 && eye <= 270 && stroke >= 35 && stroke <= 80 {
+			return m[1] + "x" + m[2]
+		}
+	}
+	return ""
+}
+
+// ExtractSteererLength returns the fork steerer length text gives in
+// millimetres, or 0 when it gives none
+func ExtractSteererLength(text string) int {
+	for _, m := range steererPattern.FindAllStringSubmatch(text, -1) {
+		value := m[1]
+		if value == "" {
+			value = m[2]
+		}
+		if mm, _ := strconv.Atoi(value); mm >= 120 && mm <= 320 {
+			return mm
+		}
+	}
+	return 0
+}
@@ -0,0 +1,48 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectKind(t *testing.T) {
+	tests := []struct {
+		title string
+		want  Kind
+	}{
+		{"2021 Evil Wreckoning", KindBike},
+		{"2021 Evil Wreckoning w/ new Fox 38 fork and wheels", KindBike},
+		{"2022 Santa Cruz Megatower frame only", KindFrame},
+		{"Transition Sentinel Frameset Large", KindFrame},
+		{"Yeti SB150 frame w/ shock", KindFrame},
+		{"2021 Specialized Enduro S-Works frame", KindFrame},
+		{"Specialized Enduro complete, new frame", KindBike},
+		{"2023 Fox 38 Factory 170mm", KindFork},
+		{"RockShox ZEB Ultimate fork 29", KindFork},
+		{"DT Swiss EX1700 wheelset 29 boost", KindWheels},
+		{"Fox Float X2 230x65 shock", KindParts},
+		{"Shimano XT M8100 brakes", KindParts},
+		{"Unknown brand enduro bike", KindBike},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, DetectKind(tt.title), tt.title)
+	}
+	assert.True(t, Kind("").IsBike())
+	assert.False(t, KindFrame.IsBike())
+}
+
+func TestExtractShockSize(t *testing.T) {
+	assert.Equal(t, "230x65", ExtractShockSize("Comes with Float X2, 230x65 trunnion"))
+	assert.Equal(t, "210x55", ExtractShockSize("Shock is 210 x 55mm"))
+	assert.Equal(t, "205x62.5", ExtractShockSize("205x62.5 metric"))
+	assert.Empty(t, ExtractShockSize("Bars are 800x35"))
+	assert.Empty(t, ExtractShockSize("Great frame, no shock"))
+}
+
+func TestExtractSteererLength(t *testing.T) {
+	assert.Equal(t, 195, ExtractSteererLength("Steerer cut to 195mm, star nut installed"))
+	assert.Equal(t, 210, ExtractSteererLength("210mm steerer, tapered"))
+	assert.Equal(t, 0, ExtractSteererLength("Uncut steerer"))
+	assert.Equal(t, 0, ExtractSteererLength("170mm travel"))
+}
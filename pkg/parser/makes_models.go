@@ -1,4 +1,4 @@
-package listing
+package parser
 
 // MountainBikeType defines an enumeration of mountain bike types.
 type MountainBikeType int
@@ -104,11 +104,11 @@ var bikeModels = map[string][]BikeModel{
 		{"Wideangle", Hardtail},
 		{"Monk", Hardtail},
 		{"Minor Threat", Kids},
-		{"Surface", AllMountain},
-		{"Surface Voyager", AllMountain},
-		{"Primer", AllMountain},
-		{"Doctahawk", Downhill},
-		{"Samaritan", AllMountain},
+		{"Surface", Hardtail},
+		{"Surface Voyager", Hardtail},
+		{"Primer", Hardtail},
+		{"Doctahawk", AllMountain},
+		{"Samaritan", Hardtail},
 	},
 	"Commencal": {
 		{"Meta TR", Trail},
@@ -124,7 +124,7 @@ var bikeModels = map[string][]BikeModel{
 		{"Power Meta", Electric},
 		{"Clash", Enduro},
 		{"Supreme DH", Downhill},
-		{"Frs", Downhill},
+		{"Frs", Enduro},
 		{"Tempo", Trail},
 		{"Absolut", Hardtail},
 	},
@@ -161,7 +161,7 @@ var bikeModels = map[string][]BikeModel{
 	"Diamondback": {
 		{"Release", Trail},
 		{"Catch", Enduro},
-		{"Line", Downhill},
+		{"Line", Hardtail},
 		{"Sync'r", CrossCountry},
 		{"Overdrive", Hardtail},
 		{"Hook", Hardtail},
@@ -172,14 +172,14 @@ var bikeModels = map[string][]BikeModel{
 		{"Offering", Trail},
 		{"Following", Trail},
 		{"Wreckoning", AllMountain},
-		{"The Calling", Downhill},
+		{"The Calling", Trail},
 		{"Epocalypse", Electric},
 		{"Faction", DirtJump},
 	},
 	"Felt": {
 		{"Edict", CrossCountry},
 		{"Edict Advanced", CrossCountry},
-		{"Doctrine", Downhill},
+		{"Doctrine", CrossCountry},
 		{"Compulsion", Enduro},
 		{"Virtue", Trail},
 	},
@@ -201,7 +201,7 @@ var bikeModels = map[string][]BikeModel{
 	"Forbidden": {
 		{"Druid", Trail},
 		{"Dreadnought", Enduro},
-		{"Supernought", Downhill},
+		{"Supernought", Enduro},
 	},
 	"Gary Fisher": {},
 	"Ghost-Bikes": {
@@ -213,7 +213,7 @@ var bikeModels = map[string][]BikeModel{
 		{"Lector", CrossCountry},
 		{"Hybride Kato", Electric},
 		{"Hybride SL AMR", Electric},
-		{"Asket", Downhill},
+		{"Asket", Hardtail},
 	},
 	"Giant": {
 		{"Trance X", Trail},
@@ -240,8 +240,8 @@ var bikeModels = map[string][]BikeModel{
 		{"Smash", Enduro},
 		{"Megatrail", Enduro},
 		{"Trail Pistol", Trail},
-		{"Shred Dogg", Hardtail},
-		{"The Gnarvana", Hardtail},
+		{"Shred Dogg", Trail},
+		{"The Gnarvana", Enduro},
 	},
 	"Haibike": {
 		{"AllMtn", Electric},
@@ -258,7 +258,7 @@ var bikeModels = map[string][]BikeModel{
 		{"Double Peak 29", Trail},
 		{"Shift R7 Plus", Hardtail},
 		{"Shift R5", Hardtail},
-		{"Flightline", Downhill},
+		{"Flightline", Hardtail},
 	},
 	"Huffy": {
 		{"Stone Mountain", Hardtail},
@@ -269,11 +269,7 @@ var bikeModels = map[string][]BikeModel{
 	},
 	"Ibis": {
 		{"Ripmo", Trail},
-		{"Ripmo V2", Trail},
-		{"Ripmo V3", Trail},
 		{"Ripley", Trail},
-		{"Ripley V4", Trail},
-		{"Ripmo V2S", Trail},
 		{"Ripmo AF", Trail},
 		{"Mojo", Trail},
 		{"Mojo HD5", Enduro},
@@ -290,7 +286,7 @@ var bikeModels = map[string][]BikeModel{
 	"Jamis": {
 		{"Hardline", Enduro},
 		{"Portal", Trail},
-		{"Dakar", CrossCountry},
+		{"Dakar", Trail},
 		{"Komodo", Hardtail},
 		{"Dragonfly", CrossCountry},
 	},
@@ -343,7 +339,7 @@ var bikeModels = map[string][]BikeModel{
 		{"Alpine Trail", Trail},
 		{"Hawk Hill", Trail},
 		{"Mount Vision", Trail},
-		{"Rift Zone", CrossCountry},
+		{"Rift Zone", Trail},
 		{"San Quentin", Hardtail},
 		{"Bobcat Trail", Hardtail},
 		{"Pine Mountain", Hardtail},
@@ -377,15 +373,15 @@ var bikeModels = map[string][]BikeModel{
 		{"Women's ATB 29er", Hardtail},
 	},
 	"Nicolai": {
-		{"Geometron G1", Downhill},
+		{"Geometron G1", Enduro},
 		{"Geometron G16", Enduro},
 		{"Geometron G13", Enduro},
 		{"G19", Enduro},
 		{"Nucleon 16", Enduro},
 		{"Nucleon 12", Trail},
 		{"Saturn 14", Trail},
-		{"Argon FR", Enduro},
-		{"Argon AM", AllMountain},
+		{"Argon FR", Hardtail},
+		{"Argon AM", Hardtail},
 		{"Argon TR", Trail},
 		{"Argon CX", CrossCountry},
 	},
@@ -406,8 +402,8 @@ var bikeModels = map[string][]BikeModel{
 		{"Shore", Enduro},
 		{"Storm", Hardtail},
 		{"Fluid", Trail},
-		{"Revolver", Hardtail},
-		{"Optic", CrossCountry},
+		{"Revolver", CrossCountry},
+		{"Optic", Trail},
 		{"Torrent", Hardtail},
 	},
 	"NS Bikes": {
@@ -460,14 +456,14 @@ var bikeModels = map[string][]BikeModel{
 		{"EVOLINK 140", Trail},
 		{"EVOLINK 158", Enduro},
 		{"STAHLWERK EVO", Enduro},
-		{"MACHINE", Downhill},
+		{"MACHINE", Enduro},
 	},
 	"Polygon": {
 		{"Siskiu T", Trail},
 		{"Siskiu T8", Enduro},
-		{"Siskiu D", Downhill},
+		{"Siskiu D", Trail},
 		{"Collosus", Downhill},
-		{"Xquarone", CrossCountry},
+		{"Xquarone", Trail},
 		{"Cascade", Trail},
 		{"Bend R", Hardtail},
 		{"Bend RV", Hardtail},
@@ -489,7 +485,7 @@ var bikeModels = map[string][]BikeModel{
 	},
 	"Reeb": {
 		{"Sqweeb", AllMountain},
-		{"Donkadonk", Enduro},
+		{"Donkadonk", Hardtail},
 	},
 	"Revel": {
 		{"Rail", Enduro},
@@ -518,7 +514,7 @@ var bikeModels = map[string][]BikeModel{
 	},
 	"Salsa": {
 		{"Timberjack", Hardtail},
-		{"Blackthorn", Hardtail},
+		{"Blackthorn", Trail},
 		{"Horsethief", Trail},
 		{"Spearfish", CrossCountry},
 	},
@@ -579,9 +575,9 @@ var bikeModels = map[string][]BikeModel{
 		{"Patrol", Enduro},
 		{"Sentinel", AllMountain},
 		{"Scout", Trail},
-		{"Spire", CrossCountry},
+		{"Spire", Enduro},
 		{"Smuggler", Trail},
-		{"Covert", Hardtail},
+		{"Covert", AllMountain},
 		{"PBJ", DirtJump},
 		{"TR11", Downhill},
 		{"Throttle", Electric},
@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// minRetailPrice is the lowest quoted retail price taken to be the bike's,
+// so "paid $80 for new grips" is not read as the original price
+const minRetailPrice = 300
+
+// originalPricePattern matches a retail price quoted in a description, such
+// as "retail $6,500", "MSRP 7999" or "retailed for $5,200 CAD". The price
+// text is captured for ParsePrice.
+var originalPricePattern = regexp.MustCompile(`(?i)\b(?:msrp|rrp|retail(?:ed|s)?(?: price)?|original(?:ly)?(?: price)?|list price|new price|paid)\b` +
+	`(?:\s*(?:was|is|of|for|at|:|~|-|=|approx\.?|around|about|over))*\s*` +
+	`((?:[a-z]{0,2}\$|[€£])?\s*\d[\d.,']*k?(?:\s*(?:usd|cad|eur|gbp|aud|nzd|€|£))?)`)
+
+// ExtractOriginalPrice returns the retail price a description quotes, with an
+// Amount of zero when it quotes none
+func ExtractOriginalPrice(text string) Price {
+	for _, m := range originalPricePattern.FindAllStringSubmatch(text, -1) {
+		if p := ParsePrice(m[1]); p.Amount >= minRetailPrice {
+			p.Negotiable = false
+			return p
+		}
+	}
+	return Price{}
+}
+
+// MSRP is a model's manufacturer suggested retail price in USD. An empty
+// Year applies to every year without its own entry.
+type MSRP struct {
+	Manufacturer string  `json:"manufacturer"`
+	Model        string  `json:"model"`
+	Year         string  `json:"year"`
+	Price        float64 `json:"price"`
+}
+
+type msrpKey struct{ manufacturer, model, year string }
+
+// MSRPs looks up retail prices by manufacturer, model and year. There are no
+// built-in prices since they change every model year; a nil MSRPs knows none.
+type MSRPs struct {
+	prices map[msrpKey]float64
+}
+
+// NewMSRPs returns an empty retail price table
+func NewMSRPs() *MSRPs {
+	return &MSRPs{prices: map[msrpKey]float64{}}
+}
+
+// LoadMSRPs reads retail prices from a JSON file of
+// [{"manufacturer": "Santa Cruz", "model": "Nomad", "year": "2023", "price": 6499}]
+func LoadMSRPs(path string) (*MSRPs, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read MSRPs: %w", err)
+	}
+
+	var entries []MSRP
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("could not parse MSRPs %s: %w", path, err)
+	}
+
+	m := NewMSRPs()
+	for _, e := range entries {
+		if e.Manufacturer == "" || e.Model == "" || e.Price <= 0 {
+			return nil, fmt.Errorf("MSRP %+v needs a manufacturer, model and price", e)
+		}
+		m.Add(e)
+	}
+	return m, nil
+}
+
+// Add records a retail price, replacing any for the same model and year
+func (m *MSRPs) Add(e MSRP) {
+	m.prices[newMSRPKey(e.Manufacturer, e.Model, e.Year)] = e.Price
+}
+
+// Lookup returns the retail price of a model year, falling back to the
+// model's entry without a year
+func (m *MSRPs) Lookup(manufacturer, model, year string) (float64, bool) {
+	if m == nil {
+		return 0, false
+	}
+	for _, y := range []string{year, ""} {
+		if price, ok := m.prices[newMSRPKey(manufacturer, model, y)]; ok {
+			return price, true
+		}
+	}
+	return 0, false
+}
+
+func newMSRPKey(manufacturer, model, year string) msrpKey {
+	return msrpKey{strings.ToLower(strings.TrimSpace(manufacturer)), strings.ToLower(strings.TrimSpace(model)), strings.TrimSpace(year)}
+}
@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractOriginalPrice(t *testing.T) {
+	tests := []struct {
+		text string
+		want Price
+	}{
+		{"Great bike, retail $6,500. Selling because I moved.", Price{Amount: 6500}},
+		{"MSRP 7999", Price{Amount: 7999}},
+		{"Retailed for $5,200 CAD last spring", Price{Amount: 5200, Currency: "CAD"}},
+		{"New price: 4.500€, ridden twice", Price{Amount: 4500, Currency: "EUR"}},
+		{"Paid $80 for new grips, bike originally $3,200", Price{Amount: 3200}},
+		{"Retail on the fork alone is over $1,000", Price{}},
+		{"No retail price mentioned", Price{}},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ExtractOriginalPrice(tt.text), tt.text)
+	}
+}
+
+func TestLoadMSRPs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "msrp.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"manufacturer": "Santa Cruz", "model": "Nomad", "year": "2023", "price": 6499},
+		{"manufacturer": "Santa Cruz", "model": "Nomad", "price": 5999}
+	]`), 0o644))
+
+	msrps, err := LoadMSRPs(path)
+	require.NoError(t, err)
+
+	price, ok := msrps.Lookup("santa cruz", "Nomad", "2023")
+	assert.True(t, ok)
+	assert.Equal(t, 6499.0, price)
+
+	price, ok = msrps.Lookup("Santa Cruz", "Nomad", "2019")
+	assert.True(t, ok)
+	assert.Equal(t, 5999.0, price)
+
+	_, ok = msrps.Lookup("Santa Cruz", "Bronson", "2023")
+	assert.False(t, ok)
+	_, ok = (*MSRPs)(nil).Lookup("Santa Cruz", "Nomad", "2023")
+	assert.False(t, ok)
+
+	require.NoError(t, os.WriteFile(path, []byte(`[{"manufacturer": "Santa Cruz", "price": 6499}]`), 0o644))
+	_, err = LoadMSRPs(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,83 @@
+// Package parser holds the title and price extraction rules shared by the
+// crawler and embeddable builds. It only depends on the standard library so it
+// can be compiled to WebAssembly.
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ExtractYear returns the first four digit number in a title
+func ExtractYear(title string) string {
+	reg := regexp.MustCompile(`\d{4}`)
+	s := reg.FindString(title)
+	return s
+}
+
+// ExtractCurrency returns the currency code of a price string
+func ExtractCurrency(price string) string {
+	return ParsePrice(price).Currency
+}
+
+// ConvertPrice returns the numeric price in USD, converting CAD prices with exchangeRate
+func ConvertPrice(price, currency string, exchangeRate float64) string {
+	p := ParsePrice(price)
+	p.Currency = currency
+	return p.USD(exchangeRate)
+}
+
+// ExtractPrice returns the whole amount of the first number in a price string
+func ExtractPrice(price string) string {
+	p := ParsePrice(price)
+	if p.Amount == 0 {
+		return ""
+	}
+	return formatAmount(p.Amount)
+}
+
+// ExtractManufacturer returns the known manufacturer named in a title. When
+// several match, such as Marin and Ari in "Marin Alpine Trail", the one named
+// first wins, and the longer name when they start together, so a title always
+// parses the same way.
+func ExtractManufacturer(title string) string {
+	title = strings.ToLower(title)
+	found, at := "NoManufacturer", -1
+	for manufacturer := range bikeModels {
+		i := strings.Index(title, strings.ToLower(manufacturer))
+		if i < 0 {
+			continue
+		}
+		if at < 0 || i < at || (i == at && (len(manufacturer) > len(found) || len(manufacturer) == len(found) && manufacturer < found)) {
+			found, at = manufacturer, i
+		}
+	}
+	return found
+}
+
+// ExtractModel returns the known model of the title's manufacturer named in
+// the title. A longer name that takes in the one found wins, so
+// "Stumpjumper EVO" is not taken for a Stumpjumper; the generation of a model
+// is left to ExtractGeneration.
+func ExtractModel(title string) string {
+	manufacturer := ExtractManufacturer(title)
+	title = strings.ToLower(title)
+
+	var found *BikeModel
+	for i, model := range bikeModels[manufacturer] {
+		name := strings.ToLower(model.Name)
+		if !strings.Contains(title, name) {
+			continue
+		}
+		if found == nil || len(name) > len(found.Name) && strings.Contains(name, strings.ToLower(found.Name)) {
+			found = &bikeModels[manufacturer][i]
+		}
+	}
+	switch {
+	case found == nil:
+		return "NoModelFound"
+	case found.Purpose == Electric:
+		return found.Name + " Electric"
+	}
+	return found.Name
+}
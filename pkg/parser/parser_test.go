@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractManufacturer(t *testing.T) {
+	tests := []struct {
+		name string
+		arg  string
+		want string
+	}{
+		{"Manufacturer at start", "Specialized Bike Model", "Specialized"},
+		{"Manufacturer in middle", "Bike Specialized Model", "Specialized"},
+		{"No manufacturer", "Bike Model", "NoManufacturer"},
+		{"Name inside another", "2022 Marin Alpine Trail", "Marin"},
+		{"Name later in title", "2023 Pivot Switchblade w/ Livevalve", "Pivot"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractManufacturer(tt.arg); got != tt.want {
+				t.Errorf("ExtractManufacturer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractYear(t *testing.T) {
+	tests := []struct {
+		name string
+		arg  string
+		want string
+	}{
+		{"Year at start", "2022 Bike Model", "2022"},
+		{"Year in middle", "Bike 2022 Model", "2022"},
+		{"No year", "Bike Model", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractYear(tt.arg); got != tt.want {
+				t.Errorf("ExtractYear() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractCurrency(t *testing.T) {
+	tests := []struct {
+		name string
+		arg  string
+		want string
+	}{
+		{"CAD", "1000 CAD", "CAD"},
+		{"USD", "1000 USD", "USD"},
+		{"No currency", "1000", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractCurrency(tt.arg); got != tt.want {
+				t.Errorf("ExtractCurrency() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractPrice(t *testing.T) {
+	tests := []struct {
+		name string
+		arg  string
+		want string
+	}{
+		{"Price with comma", "1,000 CAD", "1000"},
+		{"Price without comma", "1000 CAD", "1000"},
+		{"No price", "CAD", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractPrice(tt.arg); got != tt.want {
+				t.Errorf("ExtractPrice() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertPrice(t *testing.T) {
+	tests := []struct {
+		name         string
+		price        string
+		currency     string
+		exchangeRate float64
+		want         string
+	}{
+		{"Price in CAD to CAD", "1000", "CAD", 1.0, "1000"},
+		{"Price in CAD to USD with exchange rate 0.75", "1000", "CAD", 0.75, "750"},
+		{"Price with comma in CAD to USD", "1,000", "CAD", 0.75, "750"},
+		{"Invalid price format", "one thousand", "CAD", 0.75, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ConvertPrice(tt.price, tt.currency, tt.exchangeRate)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package parser
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+)
+
+// Price is an asking price read from listing text such as "$4,200 CAD OBO",
+// "€3.500" or "trade only"
+type Price struct {
+	// Amount is the asking price, or the low end of a range such as
+	// "$3,000-3,500"; zero when no price is given
+	Amount float64
+	// Max is the high end of a price range, zero for a single price
+	Max float64
+	// Currency is an ISO code, or "" when the text only has a bare "$"
+	Currency string
+	// Negotiable marks prices the seller is open to offers on, such as "OBO"
+	Negotiable bool
+	// TradeOnly marks listings offered for trade instead of money
+	TradeOnly bool
+}
+
+// currencyPatterns are tried in order, so a prefixed dollar like "C$" is read
+// before the bare "$" that means nothing on its own
+var currencyPatterns = []struct {
+	pattern *regexp.Regexp
+	code    string
+}{
+	{regexp.MustCompile(`(?i)(?:^|[^a-z])usd(?:[^a-z]|$)|us\$`), "USD"},
+	{regexp.MustCompile(`(?i)(?:^|[^a-z])cad(?:[^a-z]|$)|ca?\$`), "CAD"},
+	{regexp.MustCompile(`(?i)(?:^|[^a-z])(?:eur|euros?)(?:[^a-z]|$)|€`), "EUR"},
+	{regexp.MustCompile(`(?i)(?:^|[^a-z])gbp(?:[^a-z]|$)|£`), "GBP"},
+	{regexp.MustCompile(`(?i)(?:^|[^a-z])aud(?:[^a-z]|$)|au?\$`), "AUD"},
+	{regexp.MustCompile(`(?i)(?:^|[^a-z])nzd(?:[^a-z]|$)|nz\$`), "NZD"},
+	{regexp.MustCompile(`(?i)(?:^|[^a-z])chf(?:[^a-z]|$)`), "CHF"},
+}
+
+var (
+	// numberPattern matches digits with any thousands or decimal separators,
+	// which parseAmount sorts out, and an optional "k" for thousands
+	numberPattern     = regexp.MustCompile(`(?i)\d+(?:[.,' \x{a0}]\d+)*(k\b)?`)
+	rangePattern      = regexp.MustCompile(`^\s*(?:-|–|to)\s*[^\d\s]{0,3}\s*$`)
+	negotiablePattern = regexp.MustCompile(`(?i)\b(?:obo|o\.b\.o\.?|ono|or (?:best|nearest) offer|best offer|offers|negotiable|neg)\b`)
+	tradePattern      = regexp.MustCompile(`(?i)\btrades?\b`)
+)
+
+// ParsePrice reads the amount, currency and terms from listing price text
+func ParsePrice(text string) Price {
+	p := Price{
+		Currency:   parseCurrency(text),
+		Negotiable: negotiablePattern.MatchString(text),
+	}
+
+	numbers := numberPattern.FindAllStringSubmatchIndex(text, 2)
+	if len(numbers) > 0 {
+		p.Amount = parseAmount(text[numbers[0][0]:numbers[0][1]])
+	}
+	if len(numbers) > 1 && rangePattern.MatchString(text[numbers[0][1]:numbers[1][0]]) {
+		if max := parseAmount(text[numbers[1][0]:numbers[1][1]]); max > p.Amount {
+			p.Max = max
+		}
+	}
+	p.TradeOnly = p.Amount == 0 && tradePattern.MatchString(text)
+	return p
+}
+
+func parseCurrency(text string) string {
+	for _, c := range currencyPatterns {
+		if c.pattern.MatchString(text) {
+			return c.code
+		}
+	}
+	return ""
+}
+
+// parseAmount reads a number written with either "," or "." as the thousands
+// separator, as in "4,200", "3.500" or "1 250,50". A single separator followed
+// by three digits groups thousands; followed by one or two digits it marks
+// decimals.
+func parseAmount(s string) float64 {
+	scale := 1.0
+	if last := s[len(s)-1]; last == 'k' || last == 'K' {
+		scale, s = 1000, s[:len(s)-1]
+	}
+
+	var groups []string
+	var seps []byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			continue
+		}
+		if i > start {
+			groups = append(groups, s[start:i])
+			seps = append(seps, s[i])
+		}
+		start = i + 1
+	}
+	groups = append(groups, s[start:])
+
+	decimals := ""
+	if n := len(groups); n > 1 && len(groups[n-1]) <= 2 && (seps[n-2] == '.' || seps[n-2] == ',') {
+		decimals, groups, seps = groups[n-1], groups[:n-1], seps[:n-2]
+	}
+
+	whole := groups[0]
+	if len(whole) <= 3 {
+		for i := 1; i < len(groups) && len(groups[i]) == 3 && seps[i-1] == seps[0]; i++ {
+			whole += groups[i]
+		}
+	}
+	amount, err := strconv.ParseFloat(whole+"."+decimals+"0", 64)
+	if err != nil {
+		return 0
+	}
+	return amount * scale
+}
+
+// USD returns the amount in US dollars, rounded to the dollar, converting CAD
+// with cadToUSD. Other currencies are returned as they are, since only the
+// CAD rate is known.
+func (p Price) USD(cadToUSD float64) string {
+	if p.Amount == 0 {
+		return ""
+	}
+	amount := p.Amount
+	if p.Currency == "CAD" {
+		amount *= cadToUSD
+	}
+	return formatAmount(amount)
+}
+
+// Listed returns the amount in the currency it was listed in, rounded to the
+// unit
+func (p Price) Listed() string {
+	if p.Amount == 0 {
+		return ""
+	}
+	return formatAmount(p.Amount)
+}
+
+// formatAmount writes an amount the way prices are stored, in whole units
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(math.Round(amount), 'f', 0, 64)
+}
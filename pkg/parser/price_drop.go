@@ -0,0 +1,37 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// priceDropPatterns match the words sellers put in a title to announce a
+// lower price, such as "PRICE DROP", "reduced" or "was $4000"
+var priceDropPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\bprice\s*(drop(ped)?|reduc(ed|tion)|cut|lowered|change)\b`),
+	regexp.MustCompile(`(?i)\b(reduced|lowered|dropped)\b`),
+	regexp.MustCompile(`(?i)\bnew\s+(low\s+)?price\b`),
+	regexp.MustCompile(`(?i)\bwas\s*[$€£]?\s*\d`),
+	// an old price pointing at the new one, such as "$4000 -> $3500"
+	regexp.MustCompile(`[$€£]\s*\d[\d,.]*k?\s*(->|=>|→|>>)\s*[$€£]?\s*\d`),
+	// a price struck through markdown style, such as "~~$4000~~"
+	regexp.MustCompile(`~~\s*[$€£]?\s*\d[\d,.]*k?\s*~~`),
+}
+
+// strikethroughMarks are the combining characters sellers overlay on an old
+// price to strike it through, since titles are plain text
+const strikethroughMarks = "\u0335\u0336\u0337\u0338"
+
+// AdvertisesPriceDrop reports whether a title announces that the price was
+// lowered, in words or by striking an old price through
+func AdvertisesPriceDrop(title string) bool {
+	if strings.ContainsAny(title, strikethroughMarks) {
+		return true
+	}
+	for _, p := range priceDropPatterns {
+		if p.MatchString(title) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdvertisesPriceDrop(t *testing.T) {
+	tests := []struct {
+		title string
+		want  bool
+	}{
+		{"PRICE DROP 2021 Santa Cruz Hightower CC", true},
+		{"2020 Norco Sight C2 - price reduced!", true},
+		{"Reduced: Transition Sentinel XL", true},
+		{"Yeti SB150 NEW PRICE", true},
+		{"Trek Slash 9.8 was $5000 now $4200", true},
+		{"Evil Offering $4,500 -> $3,900", true},
+		{"Ibis Ripmo ~~$4000~~ $3600", true},
+		{"Kona Process $̶4̶0̶0̶0̶ $3200", true},
+		{"2022 Specialized Stumpjumper Evo with dropper post", false},
+		{"Rocky Mountain Altitude 2021 size L", false},
+		{"Commencal Meta AM 29 $3500 OBO", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			assert.Equal(t, tt.want, AdvertisesPriceDrop(tt.title))
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		text string
+		want Price
+	}{
+		{"$2,800 CAD", Price{Amount: 2800, Currency: "CAD"}},
+		{"$1,250.50 USD", Price{Amount: 1250.5, Currency: "USD"}},
+		{"€3.500", Price{Amount: 3500, Currency: "EUR"}},
+		{"3500eur", Price{Amount: 3500, Currency: "EUR"}},
+		{"1 250,50 €", Price{Amount: 1250.5, Currency: "EUR"}},
+		{"£900 ono", Price{Amount: 900, Currency: "GBP", Negotiable: true}},
+		{"$4,200 OBO", Price{Amount: 4200, Negotiable: true}},
+		{"C$3,000 - 3,500", Price{Amount: 3000, Max: 3500, Currency: "CAD"}},
+		{"$3000 to $3500 USD", Price{Amount: 3000, Max: 3500, Currency: "USD"}},
+		{"3.5k USD or best offer", Price{Amount: 3500, Currency: "USD", Negotiable: true}},
+		{"trade only", Price{TradeOnly: true}},
+		{"Trades considered", Price{TradeOnly: true}},
+		{"", Price{}},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ParsePrice(tt.text), tt.text)
+	}
+}
+
+func TestPriceUSD(t *testing.T) {
+	assert.Equal(t, "750", ParsePrice("$1,000 CAD").USD(0.75))
+	assert.Equal(t, "1251", ParsePrice("$1,250.50 USD").USD(0.75))
+	assert.Equal(t, "", ParsePrice("trade only").USD(0.75))
+}
+
+func TestPriceListed(t *testing.T) {
+	assert.Equal(t, "1000", ParsePrice("$1,000 CAD").Listed())
+	assert.Equal(t, "3500", ParsePrice("€3.500 OBO").Listed())
+	assert.Equal(t, "", ParsePrice("trade only").Listed())
+}
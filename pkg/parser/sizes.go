@@ -0,0 +1,214 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Size is a canonical frame size and the rider heights it usually fits, in cm
+type Size struct {
+	Name                 string
+	MinHeight, MaxHeight int
+}
+
+// canonicalSizes are ordered smallest first
+var canonicalSizes = []Size{
+	{"XXS", 135, 150},
+	{"XS", 150, 163},
+	{"S", 160, 170},
+	{"M", 168, 178},
+	{"L", 175, 186},
+	{"XL", 183, 193},
+	{"XXL", 190, 203},
+}
+
+// letterSizes maps the ways sellers spell letter sizes to a canonical size.
+// Between sizes like "M/L" resolve to the smaller one.
+var letterSizes = map[string]string{
+	"XXS": "XXS", "2XS": "XXS",
+	"XS": "XS", "XSMALL": "XS", "EXTRASMALL": "XS",
+	"S": "S", "SM": "S", "SMALL": "S", "S/M": "S", "SM/MD": "S",
+	"M": "M", "MD": "M", "MED": "M", "MEDIUM": "M", "M/L": "M", "ML": "M", "MD/LG": "M",
+	"L": "L", "LG": "L", "LARGE": "L", "L/XL": "L",
+	"XL": "XL", "XLARGE": "XL", "EXTRALARGE": "XL",
+	"XXL": "XXL", "2XL": "XXL", "XXLARGE": "XXL",
+}
+
+// defaultSizeSchemes are brand sizing schemes, keyed by manufacturer and label
+var defaultSizeSchemes = map[string]map[string]string{
+	"Specialized": {"S1": "XS", "S2": "S", "S3": "M", "S4": "L", "S5": "XL", "S6": "XXL"},
+}
+
+var (
+	sizeNumberPattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*(CM|IN|INCH|INCHES|")?$`)
+	titleSizePattern  = regexp.MustCompile(`(?i)\bsize:?\s*([a-z0-9./]+)`)
+)
+
+// Sizes normalizes frame sizes. Brand schemes are configurable per
+// manufacturer, a nil Sizes uses the built-in schemes.
+type Sizes struct {
+	schemes map[string]map[string]string
+}
+
+// NewSizes returns a normalizer with the built-in brand schemes
+func NewSizes() *Sizes {
+	s := &Sizes{schemes: map[string]map[string]string{}}
+	for manufacturer, labels := range defaultSizeSchemes {
+		s.AddScheme(manufacturer, labels)
+	}
+	return s
+}
+
+// LoadSizeSchemes adds the brand schemes in a JSON file of
+// {"manufacturer": {"label": "canonical size"}} to the built-in ones
+func LoadSizeSchemes(path string) (*Sizes, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read size schemes: %w", err)
+	}
+
+	var schemes map[string]map[string]string
+	if err := json.Unmarshal(data, &schemes); err != nil {
+		return nil, fmt.Errorf("could not parse size schemes %s: %w", path, err)
+	}
+
+	s := NewSizes()
+	for manufacturer, labels := range schemes {
+		for label, size := range labels {
+			if _, ok := SizeByName(size); !ok {
+				return nil, fmt.Errorf("size scheme for %s maps %s to unknown size %q", manufacturer, label, size)
+			}
+		}
+		s.AddScheme(manufacturer, labels)
+	}
+	return s, nil
+}
+
+// AddScheme maps a manufacturer's size labels to canonical sizes
+func (s *Sizes) AddScheme(manufacturer string, labels map[string]string) {
+	scheme := s.schemes[strings.ToLower(manufacturer)]
+	if scheme == nil {
+		scheme = map[string]string{}
+		s.schemes[strings.ToLower(manufacturer)] = scheme
+	}
+	for label, size := range labels {
+		scheme[sizeLabel(label)] = strings.ToUpper(size)
+	}
+}
+
+// Normalize returns the canonical size of a listing's frame size field, or of
+// a size named in its title when the field is empty or not recognised.
+// Returns "" when neither gives a size.
+func (s *Sizes) Normalize(manufacturer, frameSize, title string) string {
+	if s == nil {
+		s = builtinSizes
+	}
+
+	if size := s.normalizeLabel(manufacturer, frameSize); size != "" {
+		return size
+	}
+	if m := titleSizePattern.FindStringSubmatch(title); m != nil {
+		return s.normalizeLabel(manufacturer, m[1])
+	}
+	return ""
+}
+
+var builtinSizes = NewSizes()
+
+func (s *Sizes) normalizeLabel(manufacturer, raw string) string {
+	label := sizeLabel(raw)
+	if label == "" {
+		return ""
+	}
+
+	if size, ok := s.schemes[strings.ToLower(manufacturer)][label]; ok {
+		return size
+	}
+	if size, ok := letterSizes[label]; ok {
+		return size
+	}
+
+	m := sizeNumberPattern.FindStringSubmatch(label)
+	if m == nil {
+		return ""
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return ""
+	}
+	if m[2] == "CM" || n >= 40 {
+		return centimetreSize(n)
+	}
+	return inchSize(n)
+}
+
+// sizeLabel uppercases a size and drops spaces, dashes and "size" prefixes
+func sizeLabel(raw string) string {
+	label := strings.ToUpper(strings.TrimSpace(raw))
+	label = strings.TrimPrefix(label, "SIZE")
+	return strings.NewReplacer(" ", "", "-", "", "”", `"`, "''", `"`).Replace(label)
+}
+
+// inchSize maps a mountain bike seat tube length in inches to a size
+func inchSize(in float64) string {
+	switch {
+	case in < 13:
+		return "XXS"
+	case in < 15:
+		return "XS"
+	case in < 17:
+		return "S"
+	case in < 19:
+		return "M"
+	case in < 21:
+		return "L"
+	case in < 23:
+		return "XL"
+	}
+	return "XXL"
+}
+
+// centimetreSize maps a size in cm to a canonical size. Sizes under 49cm are
+// mountain bike seat tubes, larger ones follow road and gravel sizing.
+func centimetreSize(cm float64) string {
+	if cm < 49 {
+		return inchSize(cm / 2.54)
+	}
+	switch cm = math.Round(cm); {
+	case cm <= 50:
+		return "XS"
+	case cm <= 52:
+		return "S"
+	case cm <= 54:
+		return "M"
+	case cm <= 56:
+		return "L"
+	case cm <= 58:
+		return "XL"
+	}
+	return "XXL"
+}
+
+// SizeNames returns the canonical size names, smallest first
+func SizeNames() []string {
+	names := make([]string, len(canonicalSizes))
+	for i, s := range canonicalSizes {
+		names[i] = s.Name
+	}
+	return names
+}
+
+// SizeByName returns a canonical size and its rider height range
+func SizeByName(name string) (Size, bool) {
+	for _, s := range canonicalSizes {
+		if s.Name == strings.ToUpper(name) {
+			return s, true
+		}
+	}
+	return Size{}, false
+}
@@ -0,0 +1,51 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeSize(t *testing.T) {
+	var sizes *Sizes
+	tests := []struct {
+		manufacturer, frameSize, title, want string
+	}{
+		{"Santa Cruz", "L", "", "L"},
+		{"Santa Cruz", "Large", "", "L"},
+		{"Trek", "M/L", "", "M"},
+		{"Kona", "17.5", "", "M"},
+		{"Kona", `19.5"`, "", "L"},
+		{"Kona", "21", "", "XL"},
+		{"Kona", "46cm", "", "M"},
+		{"Kona", "56cm", "", "L"},
+		{"Specialized", "S4", "", "L"},
+		{"Specialized", "", "2022 Specialized Stumpjumper Evo size S3", "M"},
+		{"Norco", "S4", "", ""},
+		{"Norco", "", "2021 Norco Sight", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, sizes.Normalize(tt.manufacturer, tt.frameSize, tt.title), "%s %q %q", tt.manufacturer, tt.frameSize, tt.title)
+	}
+
+	l, ok := SizeByName("l")
+	require.True(t, ok)
+	assert.Equal(t, Size{"L", 175, 186}, l)
+}
+
+func TestLoadSizeSchemes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sizes.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"Norco": {"S4": "XL", "S3": "L"}}`), 0644))
+
+	sizes, err := LoadSizeSchemes(path)
+	require.NoError(t, err)
+	assert.Equal(t, "XL", sizes.Normalize("Norco", "S4", ""))
+	assert.Equal(t, "L", sizes.Normalize("Specialized", "S4", ""), "built-in schemes are kept")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"Norco": {"S4": "huge"}}`), 0644))
+	_, err = LoadSizeSchemes(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,40 @@
+package parser
+
+import "regexp"
+
+// specs are the parts of a build buyers look for in a description, each with
+// the words and common component names that mention it
+var specs = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"fork", regexp.MustCompile(`(?i)\b(fork|fox (32|34|36|38|40)|lyrik|pike|zeb|domain|yari|sid|mezzer|dvo)\b`)},
+	{"shock", regexp.MustCompile(`(?i)\b(shock|float x2?|dhx2?|super deluxe|deluxe|vivid|monarch|coil)\b`)},
+	{"drivetrain", regexp.MustCompile(`(?i)\b(drivetrain|derailleur|cassette|shifter|eagle|axs|xtr?|slx|deore|gx|nx|sx|x01|xx1|1x\d{1,2}|\d{1,2} ?speed)\b`)},
+	{"brakes", regexp.MustCompile(`(?i)\b(brakes?|rotors?|calipers?|code rsc|codes?|guide|g2|maven|saint|zee|hope tech|trickstuff)\b`)},
+	{"wheels", regexp.MustCompile(`(?i)\b(wheels?|wheelset|rims?|hubs?|i9|dt swiss|industry nine|reserve|we are one)\b`)},
+	{"tires", regexp.MustCompile(`(?i)\b(tires?|tyres?|assegai|minion|dhf|dhr|magic mary|maxxis|tubeless)\b`)},
+	{"dropper", regexp.MustCompile(`(?i)\b(dropper|reverb|oneup post|transfer post|bikeyoke)\b`)},
+	{"service", regexp.MustCompile(`(?i)\b(service[ds]?|bearings?|rebuilt|tune[ds]?|overhaul(ed)?)\b`)},
+}
+
+// contactPattern matches a phone number or an invitation to get in touch
+var contactPattern = regexp.MustCompile(`(?i)(\(?\b\d{3}\)?[ .-]?\d{3}[ .-]?\d{4}\b|\b(text|call|e-?mail|message|pm|dm)\s+(me|us)\b|\b(contact|reach)\b)`)
+
+// MentionedSpecs returns the parts of a build text mentions, such as "fork",
+// "brakes" or "service" for a service history
+func MentionedSpecs(text string) []string {
+	var found []string
+	for _, s := range specs {
+		if s.pattern.MatchString(text) {
+			found = append(found, s.name)
+		}
+	}
+	return found
+}
+
+// HasContactInfo reports whether text gives a phone number or tells buyers
+// how to get in touch
+func HasContactInfo(text string) bool {
+	return contactPattern.MatchString(text)
+}
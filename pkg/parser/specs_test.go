@@ -0,0 +1,20 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMentionedSpecs(t *testing.T) {
+	text := "Fox 36 Grip2, Float X2 rear. XT 12 speed, Code RSC brakes, DT Swiss wheels with fresh Assegai. Bearings replaced this spring."
+	assert.Equal(t, []string{"fork", "shock", "drivetrain", "brakes", "wheels", "tires", "service"}, MentionedSpecs(text))
+	assert.Empty(t, MentionedSpecs("Great bike, rides well, no issues."))
+}
+
+func TestHasContactInfo(t *testing.T) {
+	assert.True(t, HasContactInfo("Text me at 604-555-1234"))
+	assert.True(t, HasContactInfo("Call me after 5"))
+	assert.True(t, HasContactInfo("Please contact for details"))
+	assert.False(t, HasContactInfo("Rides great, 2 seasons of use"))
+}
@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// TierRank ranks build kits on one scale across manufacturers' kit names and
+// the drivetrain groups kits are named after; higher is better and zero
+// means unknown
+type TierRank int
+
+const (
+	TierUnknown TierRank = iota
+	TierEntry
+	TierMid
+	TierUpper
+	TierPro
+	TierFlagship
+)
+
+var tierNames = []string{"unknown", "entry", "mid", "upper", "pro", "flagship"}
+
+func (r TierRank) String() string {
+	if r < 0 || int(r) >= len(tierNames) {
+		return tierNames[TierUnknown]
+	}
+	return tierNames[r]
+}
+
+// BuildTier is the build kit a listing names, as the manufacturer or
+// drivetrain calls it, such as "S-Works" or "GX", with its rank
+type BuildTier struct {
+	Kit  string
+	Rank TierRank
+}
+
+// IsZero reports whether no build kit is known
+func (t BuildTier) IsZero() bool {
+	return t.Kit == ""
+}
+
+type buildKit struct {
+	tier    BuildTier
+	pattern *regexp.Regexp
+}
+
+func newBuildKit(kit string, rank TierRank, pattern string) buildKit {
+	return buildKit{BuildTier{kit, rank}, regexp.MustCompile(`(?i)` + pattern)}
+}
+
+// manufacturerKits are the kit names manufacturers give their builds, which
+// name the kit better than the parts it comes with
+var manufacturerKits = map[string][]buildKit{
+	"Specialized": {
+		newBuildKit("S-Works", TierFlagship, `\bs-?works\b`),
+		newBuildKit("Pro", TierPro, `\bpro\b`),
+		newBuildKit("Expert", TierUpper, `\bexpert\b`),
+		newBuildKit("Comp", TierMid, `\bcomp\b`),
+	},
+	"Trek": {
+		newBuildKit("9.9", TierFlagship, `\b9\.9\b`),
+		newBuildKit("9.8", TierUpper, `\b9\.8\b`),
+		newBuildKit("9.7", TierMid, `\b9\.7\b`),
+	},
+}
+
+// drivetrainKits are the drivetrain groups builds are named after, the
+// more specific names first so "XX1 AXS" is not taken for "XX1" and the
+// dearer groups before the cheaper ones a listing also names
+var drivetrainKits = []buildKit{
+	newBuildKit("XX SL", TierFlagship, `\bxx\s*sl\b`),
+	newBuildKit("XX Transmission", TierFlagship, `\bxx\s*(t-?type|transmission|axs)\b`),
+	newBuildKit("XX1 AXS", TierFlagship, `\bxx1\s*axs\b`),
+	newBuildKit("XX1", TierFlagship, `\bxx1\b`),
+	newBuildKit("XTR", TierFlagship, `\bxtr\b`),
+	newBuildKit("X0 Transmission", TierPro, `\bx0\s*(t-?type|transmission|axs)\b`),
+	newBuildKit("X01 AXS", TierPro, `\bx01\s*axs\b`),
+	newBuildKit("X01", TierPro, `\bx01\b`),
+	newBuildKit("GX AXS", TierUpper, `\bgx\s*(t-?type|transmission|axs)\b`),
+	newBuildKit("XT", TierUpper, `\bxt\b`),
+	newBuildKit("GX", TierMid, `\bgx\b`),
+	newBuildKit("SLX", TierMid, `\bslx\b`),
+	newBuildKit("NX", TierEntry, `\bnx\b`),
+	newBuildKit("SX", TierEntry, `\bsx\b`),
+	newBuildKit("Deore", TierEntry, `\bdeore\b`),
+}
+
+// ExtractBuildTier returns the build kit text names: the manufacturer's own
+// kit name when it has one, such as "Expert", otherwise the first drivetrain
+// group named. It is zero when text names none.
+func ExtractBuildTier(manufacturer, text string) BuildTier {
+	for _, k := range manufacturerKits[manufacturer] {
+		if k.pattern.MatchString(text) {
+			return k.tier
+		}
+	}
+	for _, k := range drivetrainKits {
+		if k.pattern.MatchString(text) {
+			return k.tier
+		}
+	}
+	return BuildTier{}
+}
+
+// ParseTierRank reads a rank by name, such as "pro", or by a kit of that
+// rank, such as "XT", so filters can be written with either
+func ParseTierRank(s string) TierRank {
+	s = strings.TrimSpace(s)
+	for r, name := range tierNames {
+		if strings.EqualFold(s, name) {
+			return TierRank(r)
+		}
+	}
+	for _, kits := range manufacturerKits {
+		for _, k := range kits {
+			if strings.EqualFold(s, k.tier.Kit) {
+				return k.tier.Rank
+			}
+		}
+	}
+	for _, k := range drivetrainKits {
+		if strings.EqualFold(s, k.tier.Kit) {
+			return k.tier.Rank
+		}
+	}
+	return TierUnknown
+}
@@ -0,0 +1,39 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractBuildTier(t *testing.T) {
+	tests := []struct {
+		manufacturer, text string
+		want               BuildTier
+	}{
+		{"Specialized", "2022 Specialized Stumpjumper EVO Expert", BuildTier{"Expert", TierUpper}},
+		{"Specialized", "2021 Specialized Enduro S-Works XX1 AXS", BuildTier{"S-Works", TierFlagship}},
+		{"Specialized", "Specialized Status 160 Comp", BuildTier{"Comp", TierMid}},
+		{"Trek", "2023 Trek Slash 9.8 XT", BuildTier{"9.8", TierUpper}},
+		{"Santa Cruz", "2022 Santa Cruz Megatower CC XX1 AXS", BuildTier{"XX1 AXS", TierFlagship}},
+		{"Santa Cruz", "2022 Santa Cruz Hightower C GX AXS", BuildTier{"GX AXS", TierUpper}},
+		{"Santa Cruz", "2021 Santa Cruz Bronson S Kit GX", BuildTier{"GX", TierMid}},
+		{"Yeti", "Yeti SB150 T2 X0 Transmission", BuildTier{"X0 Transmission", TierPro}},
+		{"Ibis", "Ibis Ripmo Deore build", BuildTier{"Deore", TierEntry}},
+		{"Ibis", "Ibis Ripmo, XT brakes and a GX drivetrain", BuildTier{"XT", TierUpper}},
+		{"Trek", "Trek Slash 8", BuildTier{}},
+		{"Kona", "Kona Process 153 great shape", BuildTier{}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ExtractBuildTier(tt.manufacturer, tt.text), tt.text)
+	}
+}
+
+func TestParseTierRank(t *testing.T) {
+	assert.Equal(t, TierPro, ParseTierRank("pro"))
+	assert.Equal(t, TierFlagship, ParseTierRank("S-Works"))
+	assert.Equal(t, TierUpper, ParseTierRank("xt"))
+	assert.Equal(t, TierUnknown, ParseTierRank("gold"))
+	assert.Equal(t, "upper", TierUpper.String())
+	assert.Equal(t, "unknown", TierRank(9).String())
+}
@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// TravelRange is a span of suspension travel in millimetres, both ends included
+type TravelRange struct {
+	Min, Max int
+}
+
+// Contains reports whether mm is within the range
+func (r TravelRange) Contains(mm int) bool {
+	return mm >= r.Min && mm <= r.Max
+}
+
+// ExpectedTravel is the front and rear travel a bike model is built with
+type ExpectedTravel struct {
+	Front, Rear TravelRange
+}
+
+// purposeTravel is the travel bikes of each purpose are built with, wide
+// enough to take in longer travel builds and shorter travel versions of a
+// model. Hardtails have no rear travel, and cross country, trail, electric,
+// kids and dirt jump models come as hardtails too.
+var purposeTravel = map[MountainBikeType]ExpectedTravel{
+	CrossCountry: {Front: TravelRange{80, 130}, Rear: TravelRange{0, 130}},
+	Trail:        {Front: TravelRange{110, 170}, Rear: TravelRange{0, 160}},
+	AllMountain:  {Front: TravelRange{130, 180}, Rear: TravelRange{110, 175}},
+	Enduro:       {Front: TravelRange{150, 190}, Rear: TravelRange{130, 190}},
+	Downhill:     {Front: TravelRange{180, 220}, Rear: TravelRange{180, 230}},
+	Electric:     {Front: TravelRange{100, 190}, Rear: TravelRange{0, 180}},
+	Kids:         {Front: TravelRange{0, 160}, Rear: TravelRange{0, 160}},
+	Hardtail:     {Front: TravelRange{80, 160}, Rear: TravelRange{0, 0}},
+	DirtJump:     {Front: TravelRange{80, 140}, Rear: TravelRange{0, 100}},
+}
+
+// ModelTravel returns the travel the model database expects of a
+// manufacturer's model, as ExtractModel names it, from the purpose the model
+// is built for. ok is false for models it does not know.
+func ModelTravel(manufacturer, model string) (ExpectedTravel, bool) {
+	for _, m := range bikeModels[manufacturer] {
+		if m.Name == model || m.Purpose == Electric && m.Name+" Electric" == model {
+			travel, ok := purposeTravel[m.Purpose]
+			return travel, ok
+		}
+	}
+	return ExpectedTravel{}, false
+}
+
+var travelPattern = regexp.MustCompile(`^\d+(?:\.\d+)?`)
+
+// ParseTravel reads travel as Pinkbike shows it, such as "160 mm", in
+// millimetres. ok is false when it gives no number, such as "None".
+func ParseTravel(s string) (mm int, ok bool) {
+	n, err := strconv.ParseFloat(travelPattern.FindString(strings.TrimSpace(s)), 64)
+	if err != nil {
+		return 0, false
+	}
+	return int(n + 0.5), true
+}
@@ -0,0 +1,48 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelTravel(t *testing.T) {
+	epic, ok := ModelTravel("Specialized", "Epic")
+	require.True(t, ok)
+	assert.True(t, epic.Front.Contains(100))
+	assert.False(t, epic.Front.Contains(200), "a 200mm fork is not an XC build")
+
+	session, ok := ModelTravel("Trek", "Session")
+	require.True(t, ok)
+	assert.True(t, session.Rear.Contains(200))
+	assert.False(t, session.Rear.Contains(120))
+
+	_, ok = ModelTravel("Canyon", "Spectral:ON Electric")
+	assert.True(t, ok, "electric models are named with a suffix")
+
+	_, ok = ModelTravel("Trek", "NoModelFound")
+	assert.False(t, ok)
+	_, ok = ModelTravel("NoManufacturer", "Session")
+	assert.False(t, ok)
+}
+
+func TestParseTravel(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		ok   bool
+	}{
+		{"160 mm", 160, true},
+		{"140mm", 140, true},
+		{" 0 mm", 0, true},
+		{"152.5 mm", 153, true},
+		{"None", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		mm, ok := ParseTravel(tt.in)
+		assert.Equal(t, tt.want, mm, tt.in)
+		assert.Equal(t, tt.ok, ok, tt.in)
+	}
+}
@@ -0,0 +1,43 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// pinkbikeOrigin is the scheme and host canonical listing URLs use
+const pinkbikeOrigin = "https://www.pinkbike.com"
+
+var listingIDPattern = regexp.MustCompile(`(?i)^(?:https?:)?(?://(?:www\.|m\.)?pinkbike\.com)?/buysell/(\d+)(?:[/?#]|$)`)
+
+// ExtractListingID returns the numeric ID in a Pinkbike listing URL such as
+// https://www.pinkbike.com/buysell/3861316/, or 0 when url is not a listing
+func ExtractListingID(url string) int {
+	m := listingIDPattern.FindStringSubmatch(strings.TrimSpace(url))
+	if m == nil {
+		return 0
+	}
+	id, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// CanonicalURL returns the one spelling of a listing's URL, whether it was
+// scraped as a relative link, without a scheme, from the mobile site or with
+// a query string. Links that are not listings are only made absolute.
+func CanonicalURL(url string) string {
+	url = strings.TrimSpace(url)
+	if id := ExtractListingID(url); id != 0 {
+		return pinkbikeOrigin + "/buysell/" + strconv.Itoa(id) + "/"
+	}
+	switch {
+	case strings.HasPrefix(url, "//"):
+		return "https:" + url
+	case strings.HasPrefix(url, "/"):
+		return pinkbikeOrigin + url
+	}
+	return url
+}
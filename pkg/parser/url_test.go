@@ -0,0 +1,29 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+		id   int
+	}{
+		{"https://www.pinkbike.com/buysell/3861316/", "https://www.pinkbike.com/buysell/3861316/", 3861316},
+		{"/buysell/3861316/", "https://www.pinkbike.com/buysell/3861316/", 3861316},
+		{"//www.pinkbike.com/buysell/3861316", "https://www.pinkbike.com/buysell/3861316/", 3861316},
+		{"http://pinkbike.com/buysell/3861316/?utm_source=feed", "https://www.pinkbike.com/buysell/3861316/", 3861316},
+		{" https://m.pinkbike.com/buysell/3861316/#photos ", "https://www.pinkbike.com/buysell/3861316/", 3861316},
+		{"/buysell/list/?category=2", "https://www.pinkbike.com/buysell/list/?category=2", 0},
+		{"https://example.com/buysell/3861316/", "https://example.com/buysell/3861316/", 0},
+		{"", "", 0},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, CanonicalURL(tt.url), tt.url)
+		assert.Equal(t, tt.id, ExtractListingID(tt.url), tt.url)
+	}
+}
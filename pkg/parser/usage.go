@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+const kmPerMile = 1.609344
+
+// Usage is how much a bike has been ridden, as its description tells it
+type Usage struct {
+	// EstimatedKM is the distance ridden; zero with a Confidence above zero
+	// means unridden
+	EstimatedKM int
+	// SeasonsUsed is how many riding seasons the bike has seen, 0.5 for
+	// "half a season"
+	SeasonsUsed float64
+	NeverRaced  bool
+	// Confidence is how sure the estimate is, from 0 when the description
+	// says nothing about use to 1
+	Confidence float64
+}
+
+var (
+	// distancePattern captures an optional qualifier, the number and its unit
+	distancePattern = regexp.MustCompile(`(?i)\b(less than|under|about|around|approx\.?|roughly|maybe|only|<|~)?\s*(\d[\d.,]*k?)\s*(km|kms|kilometers|kilometres|miles|mi)\b([^.!\n]{0,20})`)
+	// notUsagePattern marks distances that are about something else, such as
+	// "30km from Whistler"
+	notUsagePattern = regexp.MustCompile(`(?i)^\s*(from|away|radius|drive|north|south|east|west|outside)\b`)
+
+	seasonPattern = regexp.MustCompile(`(?i)\b(half a|one|a|single|1|two|2|three|3|four|4|five|5|couple(?: of)?|few)\s+(seasons?|years?)\b(?:\s+(?:old|of (?:use|riding)))?`)
+	// seasonContextPattern separates "ridden two seasons" from "two year warranty"
+	seasonContextPattern = regexp.MustCompile(`(?i)\b(ridden|rode|used|use|riding|season old|seasons old|owned|had it)\b`)
+
+	unriddenPattern   = regexp.MustCompile(`(?i)\b(never ridden|unridden|brand new|new in box|nib|zero (?:km|miles))\b`)
+	neverRacedPattern = regexp.MustCompile(`(?i)\b(never (?:been )?raced|not raced|no racing)\b`)
+)
+
+var seasonWords = map[string]float64{
+	"half a": 0.5, "one": 1, "a": 1, "single": 1, "1": 1, "two": 2, "2": 2, "three": 3, "3": 3,
+	"four": 4, "4": 4, "five": 5, "5": 5, "couple": 2, "couple of": 2, "few": 3,
+}
+
+// ExtractUsage reads how far and how long a bike has been ridden from its
+// description, such as "less than 200km", "ridden one season" or "never raced"
+func ExtractUsage(text string) Usage {
+	u := Usage{NeverRaced: neverRacedPattern.MatchString(text)}
+
+	if unriddenPattern.MatchString(text) {
+		u.Confidence = 0.9
+		return u
+	}
+
+	for _, m := range distancePattern.FindAllStringSubmatch(text, -1) {
+		qualifier, number, unit, after := strings.ToLower(m[1]), m[2], strings.ToLower(m[3]), m[4]
+		if notUsagePattern.MatchString(after) {
+			continue
+		}
+		km := parseAmount(number)
+		if strings.HasPrefix(unit, "mi") {
+			km *= kmPerMile
+		}
+		if km <= 0 || km > 100000 {
+			continue
+		}
+		u.EstimatedKM = int(math.Round(km))
+		u.Confidence = 0.9
+		if qualifier != "" && qualifier != "only" {
+			u.Confidence = 0.7
+		}
+		break
+	}
+
+	for _, m := range seasonPattern.FindAllStringSubmatchIndex(text, -1) {
+		sentence := sentenceAround(text, m[0], m[1])
+		if !seasonContextPattern.MatchString(sentence) {
+			continue
+		}
+		word := strings.ToLower(text[m[2]:m[3]])
+		u.SeasonsUsed = seasonWords[word]
+		confidence := 0.8
+		if word == "couple" || word == "couple of" || word == "few" {
+			confidence = 0.6
+		}
+		u.Confidence = math.Max(u.Confidence, confidence)
+		break
+	}
+	return u
+}
+
+// sentenceAround returns the sentence of text containing text[start:end]
+func sentenceAround(text string, start, end int) string {
+	from := strings.LastIndexAny(text[:start], ".!?\n") + 1
+	to := strings.IndexAny(text[end:], ".!?\n")
+	if to < 0 {
+		return text[from:]
+	}
+	return text[from : end+to]
+}
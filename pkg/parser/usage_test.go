@@ -0,0 +1,28 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractUsage(t *testing.T) {
+	tests := []struct {
+		text string
+		want Usage
+	}{
+		{"Less than 200km on it, never raced.", Usage{EstimatedKM: 200, NeverRaced: true, Confidence: 0.7}},
+		{"Only 1,250 km on the odometer", Usage{EstimatedKM: 1250, Confidence: 0.9}},
+		{"About 300 miles of riding", Usage{EstimatedKM: 483, Confidence: 0.7}},
+		{"Ridden one season in the bike park.", Usage{SeasonsUsed: 1, Confidence: 0.8}},
+		{"Used for a couple of seasons, about 1.5k km total.", Usage{EstimatedKM: 1500, SeasonsUsed: 2, Confidence: 0.7}},
+		{"Half a season old", Usage{SeasonsUsed: 0.5, Confidence: 0.8}},
+		{"Brand new, never ridden", Usage{Confidence: 0.9}},
+		{"Located 30km from Whistler. Comes with a 2 year warranty.", Usage{}},
+		{"Great bike, well maintained", Usage{}},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ExtractUsage(tt.text), tt.text)
+	}
+}
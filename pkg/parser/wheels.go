@@ -0,0 +1,102 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Wheels is a bike's wheel configuration: the front and rear rim diameters in
+// inches, zero when unknown, and whether it is a mullet with a bigger front
+// wheel than rear
+type Wheels struct {
+	Front, Rear float64
+	Mullet      bool
+}
+
+var (
+	// diameterPattern matches the ways sellers write rim diameters, such as
+	// 29er, 27.5+, 650B or 26"
+	diameterPattern = regexp.MustCompile(`(?i)\b(29(?:er)?|27\.5\+?|650\s*b|700\s*c|26|24|20)(?:\s*(?:"|''|in\b|inch\b))?`)
+	// mulletPattern matches the names mixed wheel setups go by
+	mulletPattern = regexp.MustCompile(`(?i)\b(mullet|mx|mixed( wheels?)?)\b`)
+)
+
+// diameters maps a matched diameter to inches
+var diameters = map[string]float64{
+	"29": 29, "29er": 29, "700c": 29,
+	"27.5": 27.5, "27.5+": 27.5, "650b": 27.5,
+	"26": 26, "24": 24, "20": 20,
+}
+
+// ParseWheels reads the wheel configuration from the wheel size a listing
+// gives, such as "27.5 / 650B", "29/27.5 mullet" or `26"`. Two different
+// diameters are front then rear. The title only tells whether the bike is a
+// mullet: one called a mullet with a single or no diameter given is taken to
+// run the usual 29" front and 27.5" rear.
+func ParseWheels(wheelSize, title string) Wheels {
+	var sizes []float64
+	for _, m := range diameterPattern.FindAllStringSubmatch(wheelSize, -1) {
+		key := strings.ToLower(strings.Join(strings.Fields(m[1]), ""))
+		d := diameters[key]
+		if len(sizes) == 0 || sizes[len(sizes)-1] != d {
+			sizes = append(sizes, d)
+		}
+	}
+
+	var w Wheels
+	switch len(sizes) {
+	case 0:
+	case 1:
+		w.Front, w.Rear = sizes[0], sizes[0]
+	default:
+		w.Front, w.Rear = sizes[0], sizes[1]
+	}
+	if w.Front != w.Rear {
+		// "27.5/29" lists the smaller wheel first but still means a mullet
+		if w.Front < w.Rear {
+			w.Front, w.Rear = w.Rear, w.Front
+		}
+		w.Mullet = true
+		return w
+	}
+	if mulletPattern.MatchString(wheelSize) || mulletPattern.MatchString(title) {
+		return Wheels{Front: 29, Rear: 27.5, Mullet: true}
+	}
+	return w
+}
+
+// IsZero reports whether no wheel size is known
+func (w Wheels) IsZero() bool {
+	return w.Front == 0 && w.Rear == 0
+}
+
+// String is the configuration as "29" when both wheels are the same size, or
+// "29/27.5" front then rear when they differ, and "" when it is unknown
+func (w Wheels) String() string {
+	if w.IsZero() {
+		return ""
+	}
+	front := strconv.FormatFloat(w.Front, 'f', -1, 64)
+	if w.Front == w.Rear {
+		return front
+	}
+	return front + "/" + strconv.FormatFloat(w.Rear, 'f', -1, 64)
+}
+
+// Matches reports whether the configuration fits a wanted wheel size, such as
+// "29", "27.5", "650b" or "mullet". A single diameter only matches bikes
+// running it front and rear.
+func (w Wheels) Matches(want string) bool {
+	if w.IsZero() {
+		return false
+	}
+	wanted := ParseWheels(want, "")
+	if wanted.IsZero() {
+		return false
+	}
+	if mulletPattern.MatchString(want) && diameterPattern.FindString(want) == "" {
+		return w.Mullet
+	}
+	return wanted == w
+}
@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWheels(t *testing.T) {
+	tests := []struct {
+		wheelSize, title string
+		want             Wheels
+	}{
+		{"27.5 / 650B", "", Wheels{27.5, 27.5, false}},
+		{"29", "", Wheels{29, 29, false}},
+		{"29er", "", Wheels{29, 29, false}},
+		{`26"`, "", Wheels{26, 26, false}},
+		{"27.5+", "", Wheels{27.5, 27.5, false}},
+		{"29/27.5 mullet", "", Wheels{29, 27.5, true}},
+		{"27.5/29", "", Wheels{29, 27.5, true}},
+		{"29 front, 27.5 rear", "", Wheels{29, 27.5, true}},
+		{"Mixed", "", Wheels{29, 27.5, true}},
+		{"29", "2022 Santa Cruz Bronson MX", Wheels{29, 27.5, true}},
+		{"", "2023 Specialized Enduro mullet", Wheels{29, 27.5, true}},
+		{"", "2021 Norco Sight", Wheels{}},
+		{"Other", "", Wheels{}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ParseWheels(tt.wheelSize, tt.title), "%q %q", tt.wheelSize, tt.title)
+	}
+}
+
+func TestWheelsString(t *testing.T) {
+	assert.Equal(t, "29", Wheels{29, 29, false}.String())
+	assert.Equal(t, "27.5", Wheels{27.5, 27.5, false}.String())
+	assert.Equal(t, "29/27.5", Wheels{29, 27.5, true}.String())
+	assert.Equal(t, "", Wheels{}.String())
+}
+
+func TestWheelsMatches(t *testing.T) {
+	mullet := Wheels{29, 27.5, true}
+	twentyNine := Wheels{29, 29, false}
+
+	assert.True(t, twentyNine.Matches("29"))
+	assert.True(t, twentyNine.Matches(`29"`))
+	assert.False(t, twentyNine.Matches("27.5"))
+	assert.False(t, twentyNine.Matches("mullet"))
+	assert.True(t, Wheels{27.5, 27.5, false}.Matches("650b"))
+	assert.True(t, mullet.Matches("mullet"))
+	assert.True(t, mullet.Matches("29/27.5"))
+	assert.False(t, mullet.Matches("29"))
+	assert.False(t, Wheels{}.Matches("29"))
+	assert.False(t, twentyNine.Matches("big"))
+}
@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// YearRange is a span of model years, both ends included. Max is zero when
+// the span is open ended, for parts and models still made.
+type YearRange struct {
+	Min, Max int
+}
+
+// IsZero reports whether no year is known
+func (r YearRange) IsZero() bool {
+	return r.Min == 0 && r.Max == 0
+}
+
+// String is the range as "2021-2023", "2021+" when open ended, "2022" when it
+// is a single year and "" when unknown
+func (r YearRange) String() string {
+	switch {
+	case r.IsZero():
+		return ""
+	case r.Max == 0:
+		return strconv.Itoa(r.Min) + "+"
+	case r.Min == r.Max:
+		return strconv.Itoa(r.Min)
+	}
+	return strconv.Itoa(r.Min) + "-" + strconv.Itoa(r.Max)
+}
+
+// intersect narrows r to the years o allows too. ok is false when they share
+// none, so the clues contradict each other.
+func (r YearRange) intersect(o YearRange) (YearRange, bool) {
+	if o.Min > r.Min {
+		r.Min = o.Min
+	}
+	if o.Max != 0 && (r.Max == 0 || o.Max < r.Max) {
+		r.Max = o.Max
+	}
+	return r, r.Max == 0 || r.Min <= r.Max
+}
+
+// yearClue is a component or model that was only sold in some model years
+type yearClue struct {
+	name    string
+	pattern *regexp.Regexp
+	years   YearRange
+}
+
+func newYearClue(name, pattern string, min, max int) yearClue {
+	return yearClue{name, regexp.MustCompile(`(?i)` + pattern), YearRange{min, max}}
+}
+
+// componentYears are the model years bikes came specced with components,
+// from the year a component was introduced to the last one it was specced in
+var componentYears = []yearClue{
+	newYearClue("SRAM Transmission", `\b(t-?type|transmission)\b`, 2024, 0),
+	newYearClue("SRAM Eagle AXS", `\b(eagle\s+)?axs\b`, 2020, 0),
+	newYearClue("SRAM Eagle", `\beagle\b`, 2017, 0),
+	newYearClue("SRAM Maven", `\bmaven\b`, 2024, 0),
+	newYearClue("RockShox ZEB", `\bzeb\b`, 2021, 0),
+	newYearClue("RockShox Charger 3", `\bcharger\s*3\b`, 2023, 0),
+	newYearClue("RockShox Flight Attendant", `\bflight\s*attendant\b`, 2022, 0),
+	newYearClue("RockShox Reverb AXS", `\breverb\s+axs\b`, 2020, 0),
+	newYearClue("RockShox Super Deluxe", `\bsuper\s*deluxe\b`, 2018, 0),
+	newYearClue("Fox 38", `\bfox\s+(factory\s+|performance\s+)?38\b`, 2021, 0),
+	newYearClue("Fox Grip2", `\bgrip\s*2\b`, 2019, 0),
+	newYearClue("Fox Fit4", `\bfit\s*4\b`, 2016, 2020),
+	newYearClue("Fox Live Valve Neo", `\blive\s*valve\s*neo\b`, 2024, 0),
+	newYearClue("Shimano XT M8100", `\bm81[0-2]0\b|\bxt\b[^.\n]{0,20}\b12[- ]?(speed|spd|s)\b`, 2020, 0),
+	newYearClue("Shimano SLX M7100", `\bm71[0-2]0\b`, 2020, 0),
+	newYearClue("Shimano Deore M6100", `\bm61[0-2]0\b`, 2021, 0),
+	newYearClue("Shimano XTR M9100", `\bm91[0-2]0\b`, 2019, 0),
+	newYearClue("Shimano XT M8000", `\bm80[0-2]0\b`, 2016, 2020),
+	newYearClue("Boost spacing", `\bboost\s*(148|110)\b|\b(148|110)\s*boost\b`, 2016, 0),
+	newYearClue("10 speed drivetrain", `\b(1|2|3)\s*x\s*10\b|\b10[- ]?speed\b`, 0, 2017),
+	newYearClue("9 speed drivetrain", `\b(1|2|3)\s*x\s*9\b|\b9[- ]?speed\b`, 0, 2012),
+}
+
+// modelYears are the model years of models that have not always been made,
+// so a listing of one is no older than its first year
+var modelYears = map[string]map[string]YearRange{
+	"Evil":        {"Wreckoning": {2016, 0}, "Offering": {2018, 0}, "Following": {2015, 0}},
+	"Ibis":        {"Ripmo": {2018, 0}, "Ripley": {2011, 0}},
+	"Kona":        {"Process 153": {2015, 0}, "Process X": {2021, 0}},
+	"Santa Cruz":  {"Megatower": {2019, 0}, "Hightower": {2016, 0}},
+	"Specialized": {"Stumpjumper EVO": {2016, 0}},
+	"Transition":  {"Spire": {2022, 0}, "Sentinel": {2018, 0}, "Smuggler": {2014, 0}},
+	"Trek":        {"Supercaliber": {2020, 0}},
+	"YT":          {"Jeffsy": {2016, 0}},
+}
+
+// InferYears estimates the model years of a bike whose title gives none from
+// the components text names, such as "Fox 38" or "SRAM AXS T-Type", and the
+// years the model and its generation were made. It returns the years every clue allows and the
+// clues that narrowed them, and no years when nothing narrows them or the
+// clues contradict each other.
+func InferYears(manufacturer, model, generation, text string) (YearRange, []string) {
+	var years YearRange
+	var clues []string
+	if r, ok := modelYears[manufacturer][model]; ok {
+		years = r
+		clues = append(clues, manufacturer+" "+model)
+	}
+	if r, ok := GenerationYears(manufacturer, model, generation); ok {
+		years, _ = years.intersect(r)
+		clues = append(clues, manufacturer+" "+model+" "+generation)
+	}
+	for _, c := range componentYears {
+		if !c.pattern.MatchString(text) {
+			continue
+		}
+		narrowed, ok := years.intersect(c.years)
+		if !ok {
+			return YearRange{}, nil
+		}
+		if narrowed != years {
+			years = narrowed
+			clues = append(clues, c.name)
+		}
+	}
+	if years.Min == 0 {
+		// an end year alone only says the bike is old, not how old
+		return YearRange{}, nil
+	}
+	return years, clues
+}
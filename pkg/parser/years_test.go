@@ -0,0 +1,36 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInferYears(t *testing.T) {
+	tests := []struct {
+		name, manufacturer, model, generation, text string
+		want                                        YearRange
+		clues                                       []string
+	}{
+		{"transmission", "Trek", "Slash", "", "Trek Slash 9.9 XX SRAM AXS T-Type", YearRange{2024, 0}, []string{"SRAM Transmission"}},
+		{"fork and model", "Santa Cruz", "Megatower", "", "Fox 38 Grip2, Eagle", YearRange{2021, 0}, []string{"Santa Cruz Megatower", "Fox 38"}},
+		{"discontinued part", "Evil", "Wreckoning", "", "Fox 36 Fit4, 1x10 drivetrain", YearRange{2016, 2017}, []string{"Evil Wreckoning", "Fox Fit4", "10 speed drivetrain"}},
+		{"model only", "Ibis", "Ripmo", "", "Ibis Ripmo, great bike", YearRange{2018, 0}, []string{"Ibis Ripmo"}},
+		{"generation", "Santa Cruz", "Megatower", "V1", "Fox 36 Grip2", YearRange{2019, 2021}, []string{"Santa Cruz Megatower", "Santa Cruz Megatower V1"}},
+		{"contradiction", "Trek", "Slash", "", "SRAM T-Type with a 9 speed cassette", YearRange{}, nil},
+		{"end year only", "Trek", "Slash", "", "3x9 drivetrain", YearRange{}, nil},
+		{"nothing", "Trek", "Slash", "", "Great bike, ridden twice", YearRange{}, nil},
+	}
+	for _, tt := range tests {
+		years, clues := InferYears(tt.manufacturer, tt.model, tt.generation, tt.text)
+		assert.Equal(t, tt.want, years, tt.name)
+		assert.Equal(t, tt.clues, clues, tt.name)
+	}
+}
+
+func TestYearRangeString(t *testing.T) {
+	assert.Equal(t, "2021+", YearRange{2021, 0}.String())
+	assert.Equal(t, "2016-2017", YearRange{2016, 2017}.String())
+	assert.Equal(t, "2022", YearRange{2022, 2022}.String())
+	assert.Equal(t, "", YearRange{}.String())
+}
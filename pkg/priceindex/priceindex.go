@@ -0,0 +1,178 @@
+// Package priceindex computes weekly median asking prices per model and
+// across the whole market, to chart how used bike prices move over time
+package priceindex
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Overall names the index over every listing, whatever its model
+const Overall = "all"
+
+// Options choose which indexes Compute builds
+type Options struct {
+	// Models is how many of the most listed models get an index of their own
+	Models int
+	// MinListings is how many listings a week needs for its median to be
+	// recorded, so a quiet week does not swing the index
+	MinListings int
+}
+
+// DefaultOptions indexes the 25 most listed models, from weeks with at least
+// 5 of their listings on sale
+func DefaultOptions() Options {
+	return Options{Models: 25, MinListings: 5}
+}
+
+// Price is an asking price a listing was seen at from At on
+type Price struct {
+	At     time.Time
+	Amount float64
+}
+
+// Listing is what the index needs of a stored listing: its model and
+// generation, when it was on sale and the prices it was asked at, oldest first
+type Listing struct {
+	Manufacturer, Model, Generation string
+	FirstSeen, LastSeen             time.Time
+	Prices                          []Price
+}
+
+// Name is the index name of a manufacturer's model, or of one generation of
+// it when generation is not empty
+func Name(manufacturer, model, generation string) string {
+	if generation == "" {
+		return manufacturer + " " + model
+	}
+	return manufacturer + " " + model + " " + generation
+}
+
+// Point is an index's value for one week
+type Point struct {
+	Name string    `json:"name"`
+	Week time.Time `json:"week"`
+	// Median is the median asking price of the listings on sale that week
+	Median   float64 `json:"median"`
+	Listings int     `json:"listings"`
+	// Value is Median relative to the first week of the series, which is 100
+	Value float64 `json:"value"`
+}
+
+// Week returns the Monday starting the week t falls in, in UTC
+func Week(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
+
+// Compute builds the overall index and one for each of the most listed
+// models, with each known generation of a model indexed apart from the rest. A listing counts towards every week it was on sale, at the last
+// price it was asked that week. Points are ordered by name and week.
+func Compute(listings []Listing, opts Options) []Point {
+	type model struct{ manufacturer, model, generation string }
+	counts := map[model]int{}
+	names := map[model]string{}
+	for _, l := range listings {
+		if l.Manufacturer == "" || l.Model == "" || len(l.Prices) == 0 {
+			continue
+		}
+		m := model{strings.ToLower(l.Manufacturer), strings.ToLower(l.Model), l.Generation}
+		counts[m]++
+		if _, ok := names[m]; !ok {
+			names[m] = Name(l.Manufacturer, l.Model, l.Generation)
+		}
+	}
+	popular := make([]model, 0, len(counts))
+	for m := range counts {
+		popular = append(popular, m)
+	}
+	sort.Slice(popular, func(i, j int) bool {
+		if counts[popular[i]] != counts[popular[j]] {
+			return counts[popular[i]] > counts[popular[j]]
+		}
+		return names[popular[i]] < names[popular[j]]
+	})
+	if len(popular) > opts.Models {
+		popular = popular[:opts.Models]
+	}
+	indexed := map[model]bool{}
+	for _, m := range popular {
+		indexed[m] = true
+	}
+
+	type week struct {
+		name string
+		week time.Time
+	}
+	prices := map[week][]float64{}
+	for _, l := range listings {
+		if len(l.Prices) == 0 {
+			continue
+		}
+		m := model{strings.ToLower(l.Manufacturer), strings.ToLower(l.Model), l.Generation}
+		for w := Week(l.FirstSeen); !w.After(l.LastSeen); w = w.AddDate(0, 0, 7) {
+			price := priceAt(l.Prices, w.AddDate(0, 0, 7))
+			if price <= 0 {
+				continue
+			}
+			prices[week{Overall, w}] = append(prices[week{Overall, w}], price)
+			if indexed[m] {
+				prices[week{names[m], w}] = append(prices[week{names[m], w}], price)
+			}
+		}
+	}
+
+	var points []Point
+	for w, p := range prices {
+		if len(p) < opts.MinListings {
+			continue
+		}
+		points = append(points, Point{Name: w.name, Week: w.week, Median: median(p), Listings: len(p)})
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].Name != points[j].Name {
+			return points[i].Name < points[j].Name
+		}
+		return points[i].Week.Before(points[j].Week)
+	})
+	return rebase(points)
+}
+
+// rebase sets each point's Value relative to the first point of its series,
+// which must be ordered by name and week
+func rebase(points []Point) []Point {
+	var base float64
+	for i, p := range points {
+		if i == 0 || p.Name != points[i-1].Name {
+			base = p.Median
+		}
+		if base > 0 {
+			points[i].Value = p.Median / base * 100
+		}
+	}
+	return points
+}
+
+// priceAt returns the last price asked before end, or the first price when
+// the listing had none yet
+func priceAt(prices []Price, end time.Time) float64 {
+	price := prices[0].Amount
+	for _, p := range prices {
+		if !p.At.Before(end) {
+			break
+		}
+		price = p.Amount
+	}
+	return price
+}
+
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
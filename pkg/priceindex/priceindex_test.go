@@ -0,0 +1,78 @@
+package priceindex
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func day(d int) time.Time {
+	// 2024-05-06 is a Monday
+	return time.Date(2024, 5, 6+d, 12, 0, 0, 0, time.UTC)
+}
+
+func TestWeek(t *testing.T) {
+	monday := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, monday, Week(day(0)))
+	assert.Equal(t, monday, Week(day(6)))
+	assert.Equal(t, monday.AddDate(0, 0, 7), Week(day(7)))
+}
+
+func TestCompute(t *testing.T) {
+	bike := func(model string, first, last int, prices ...Price) Listing {
+		return Listing{Manufacturer: "Santa Cruz", Model: model, FirstSeen: day(first), LastSeen: day(last), Prices: prices}
+	}
+	listings := []Listing{
+		// dropped its price in the second week
+		bike("Megatower", 0, 10, Price{day(0), 5000}, Price{day(9), 4000}),
+		bike("Megatower", 0, 3, Price{day(0), 4500}),
+		bike("Megatower", 8, 12, Price{day(8), 3000}),
+		bike("Nomad", 0, 12, Price{day(0), 4000}),
+		bike("Bronson", 0, 1, Price{day(0), 2000}),
+	}
+
+	points := Compute(listings, Options{Models: 2, MinListings: 2})
+
+	byName := map[string][]Point{}
+	for _, p := range points {
+		byName[p.Name] = append(byName[p.Name], p)
+	}
+	// Bronson wins the tie for the second model by name but never has enough
+	// listings in a week, and Nomad is not among the two models indexed
+	assert.NotContains(t, byName, "Santa Cruz Bronson")
+	assert.NotContains(t, byName, "Santa Cruz Nomad")
+
+	overall := byName[Overall]
+	require.Len(t, overall, 2)
+	assert.Equal(t, Week(day(0)), overall[0].Week)
+	assert.Equal(t, 4, overall[0].Listings)
+	assert.Equal(t, 4250.0, overall[0].Median)
+	assert.Equal(t, 100.0, overall[0].Value)
+	assert.Equal(t, 3, overall[1].Listings)
+	assert.Equal(t, 4000.0, overall[1].Median)
+
+	megatower := byName["Santa Cruz Megatower"]
+	require.Len(t, megatower, 2)
+	assert.Equal(t, 4750.0, megatower[0].Median)
+	assert.Equal(t, 3500.0, megatower[1].Median, "the second week uses the dropped price")
+	assert.InDelta(t, 73.7, megatower[1].Value, 0.1)
+}
+
+func TestComputeGenerations(t *testing.T) {
+	bike := func(generation string, price float64) Listing {
+		return Listing{Manufacturer: "Santa Cruz", Model: "Megatower", Generation: generation, FirstSeen: day(0), LastSeen: day(3), Prices: []Price{{day(0), price}}}
+	}
+	listings := []Listing{bike("V1", 2500), bike("V1", 2700), bike("V2", 5000), bike("V2", 5400)}
+
+	byName := map[string][]Point{}
+	for _, p := range Compute(listings, Options{Models: 5, MinListings: 2}) {
+		byName[p.Name] = append(byName[p.Name], p)
+	}
+	assert.NotContains(t, byName, "Santa Cruz Megatower", "generations are not blended")
+	require.Len(t, byName["Santa Cruz Megatower V1"], 1)
+	assert.Equal(t, 2600.0, byName["Santa Cruz Megatower V1"][0].Median)
+	require.Len(t, byName["Santa Cruz Megatower V2"], 1)
+	assert.Equal(t, 5200.0, byName["Santa Cruz Megatower V2"][0].Median)
+}
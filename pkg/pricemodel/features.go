@@ -0,0 +1,136 @@
+package pricemodel
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/parser"
+)
+
+// feature computes one input of a model from a listing at the time now, NaN
+// when unknown
+type feature func(l listing.Listing, now time.Time) float64
+
+var numberPattern = regexp.MustCompile(`\d+(?:\.\d+)?`)
+
+// firstNumber reads the first number in text, such as 170 from "170 mm"
+func firstNumber(text string) float64 {
+	n, err := strconv.ParseFloat(numberPattern.FindString(text), 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return n
+}
+
+func boolFeature(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func countFeature(n int) float64 {
+	if n == 0 {
+		return math.NaN()
+	}
+	return float64(n)
+}
+
+// numericFeatures are the numeric features a model can be trained on
+var numericFeatures = map[string]feature{
+	"year": func(l listing.Listing, _ time.Time) float64 { return firstNumber(l.Year) },
+	"age": func(l listing.Listing, now time.Time) float64 {
+		return float64(now.Year()) - firstNumber(l.Year)
+	},
+	"front_travel": func(l listing.Listing, _ time.Time) float64 { return firstNumber(l.FrontTravel) },
+	"rear_travel":  func(l listing.Listing, _ time.Time) float64 { return firstNumber(l.RearTravel) },
+	"wheel_size":   func(l listing.Listing, _ time.Time) float64 { return firstNumber(l.WheelSize) },
+	"condition_grade": func(l listing.Listing, _ time.Time) float64 {
+		if l.ConditionGrade == 0 {
+			return math.NaN()
+		}
+		return float64(l.ConditionGrade)
+	},
+	"build_tier": func(l listing.Listing, _ time.Time) float64 {
+		if l.BuildTier.Rank == 0 {
+			return math.NaN()
+		}
+		return float64(l.BuildTier.Rank)
+	},
+	// size is the frame size's position on the XXS to XXL scale, from 1
+	"size": func(l listing.Listing, _ time.Time) float64 {
+		for i, name := range parser.SizeNames() {
+			if name == l.NormalizedSize {
+				return float64(i + 1)
+			}
+		}
+		return math.NaN()
+	},
+	"electric":   func(l listing.Listing, _ time.Time) float64 { return boolFeature(l.IsElectric) },
+	"negotiable": func(l listing.Listing, _ time.Time) float64 { return boolFeature(l.Negotiable) },
+	"battery_wh": func(l listing.Listing, _ time.Time) float64 { return countFeature(l.Details.BatteryWh) },
+	"photos":     func(l listing.Listing, _ time.Time) float64 { return countFeature(l.Details.PhotoCount) },
+	"views":      func(l listing.Listing, _ time.Time) float64 { return countFeature(l.Details.ViewCount) },
+	"estimated_km": func(l listing.Listing, _ time.Time) float64 {
+		return countFeature(l.Details.Usage.EstimatedKM)
+	},
+}
+
+// categoricalFeatures are one-hot encoded: "manufacturer=Santa Cruz" is 1
+// for Santa Cruz listings and 0 for the rest
+var categoricalFeatures = map[string]func(l listing.Listing) string{
+	"manufacturer": func(l listing.Listing) string { return l.Manufacturer },
+	"model":        func(l listing.Listing) string { return l.Model },
+	"generation":   func(l listing.Listing) string { return l.Generation },
+	"build_kit":    func(l listing.Listing) string { return l.BuildTier.Kit },
+	"category":     func(l listing.Listing) string { return l.Category },
+	"material":     func(l listing.Listing) string { return l.FrameMaterial },
+	"currency":     func(l listing.Listing) string { return l.Currency },
+	"size":         func(l listing.Listing) string { return l.NormalizedSize },
+	"seller_type":  func(l listing.Listing) string { return string(l.Details.SellerType) },
+}
+
+// parseFeature looks up a feature by name: one of FeatureNames, or a
+// categorical field and value joined by "=" such as "material=Carbon Fiber"
+func parseFeature(name string) (feature, error) {
+	name = strings.TrimSpace(name)
+	if field, value, ok := strings.Cut(name, "="); ok {
+		get, known := categoricalFeatures[field]
+		if !known {
+			return nil, fmt.Errorf("unknown categorical feature %q (available: %s)", field, strings.Join(categoricalNames(), ", "))
+		}
+		return func(l listing.Listing, _ time.Time) float64 {
+			return boolFeature(strings.EqualFold(get(l), value))
+		}, nil
+	}
+	if f, ok := numericFeatures[name]; ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("unknown feature %q (available: %s, or field=value of %s)",
+		name, strings.Join(FeatureNames(), ", "), strings.Join(categoricalNames(), ", "))
+}
+
+// FeatureNames returns the numeric features a model can use
+func FeatureNames() []string {
+	names := make([]string, 0, len(numericFeatures))
+	for name := range numericFeatures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func categoricalNames() []string {
+	names := make([]string, 0, len(categoricalFeatures))
+	for name := range categoricalFeatures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,154 @@
+package pricemodel
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/listing"
+)
+
+// FeaturesKey is the metadata property of a model listing its features in
+// input order, separated by commas
+const FeaturesKey = "features"
+
+// Model is a price model trained outside the scraper and exported to ONNX.
+// It takes one float input of shape [N, features] and its first output is
+// the predicted price in USD. Inference runs in-process, supporting the
+// operators in ops.
+type Model struct {
+	// Clock is the time listings' ages are taken at, the system clock when
+	// nil
+	Clock clock.Clock
+
+	graph    onnxGraph
+	input    string
+	output   string
+	names    []string
+	features []feature
+}
+
+// Load reads an ONNX model from path. Its features are taken from the
+// model's FeaturesKey metadata unless features are given.
+func Load(path string, features []string) (*Model, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read price model: %v", err)
+	}
+	m, err := Parse(b, features)
+	if err != nil {
+		return nil, fmt.Errorf("could not load price model %s: %v", path, err)
+	}
+	return m, nil
+}
+
+// Parse reads an ONNX model, checking that every operator it uses is
+// supported before any listing is run through it
+func Parse(b []byte, features []string) (*Model, error) {
+	onnx, err := decodeModel(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(features) == 0 {
+		if listed := onnx.metadata[FeaturesKey]; listed != "" {
+			features = strings.Split(listed, ",")
+		}
+	}
+	if len(features) == 0 {
+		return nil, fmt.Errorf("model lists no features in its %q metadata and none were given", FeaturesKey)
+	}
+
+	m := &Model{graph: onnx.graph}
+	for _, name := range features {
+		f, err := parseFeature(name)
+		if err != nil {
+			return nil, err
+		}
+		m.names = append(m.names, strings.TrimSpace(name))
+		m.features = append(m.features, f)
+	}
+
+	var inputs []string
+	for _, name := range onnx.graph.inputs {
+		if _, ok := onnx.graph.initializers[name]; !ok {
+			inputs = append(inputs, name)
+		}
+	}
+	if len(inputs) != 1 {
+		return nil, fmt.Errorf("model has %d inputs, expected one feature matrix", len(inputs))
+	}
+	if len(onnx.graph.outputs) == 0 {
+		return nil, fmt.Errorf("model has no outputs")
+	}
+	m.input, m.output = inputs[0], onnx.graph.outputs[0]
+
+	for _, n := range onnx.graph.nodes {
+		switch n.domain {
+		case "", "ai.onnx", "ai.onnx.ml":
+		default:
+			return nil, fmt.Errorf("node %s uses unsupported operator domain %s", n.name, n.domain)
+		}
+		if _, ok := ops[n.opType]; !ok {
+			return nil, fmt.Errorf("node %s uses unsupported operator %s", n.name, n.opType)
+		}
+	}
+	return m, nil
+}
+
+// Features returns the names of the model's features in input order
+func (m *Model) Features() []string {
+	return m.names
+}
+
+// Predict returns the price the model predicts for l in USD
+func (m *Model) Predict(l listing.Listing) (float64, error) {
+	now := clock.Or(m.Clock).Now()
+	x := tensor{shape: []int{1, len(m.features)}, data: make([]float64, len(m.features))}
+	for i, f := range m.features {
+		x.data[i] = f(l, now)
+	}
+
+	values := make(map[string]tensor, len(m.graph.initializers)+len(m.graph.nodes)+1)
+	for name, t := range m.graph.initializers {
+		values[name] = t
+	}
+	values[m.input] = x
+
+	for _, n := range m.graph.nodes {
+		in := make([]tensor, 0, len(n.inputs))
+		for _, name := range n.inputs {
+			// optional inputs are left out with an empty name
+			if name == "" {
+				continue
+			}
+			t, ok := values[name]
+			if !ok {
+				return 0, fmt.Errorf("node %s reads %s before it is computed", n.name, name)
+			}
+			in = append(in, t)
+		}
+		if len(in) == 0 {
+			return 0, fmt.Errorf("node %s has no inputs", n.name)
+		}
+		out, err := ops[n.opType](n, in)
+		if err != nil {
+			return 0, fmt.Errorf("%s node %s: %v", n.opType, n.name, err)
+		}
+		if len(n.outputs) > 0 {
+			values[n.outputs[0]] = out
+		}
+	}
+
+	out, ok := values[m.output]
+	if !ok || len(out.data) == 0 {
+		return 0, fmt.Errorf("model computed no %s output", m.output)
+	}
+	price := out.data[0]
+	if math.IsNaN(price) || math.IsInf(price, 0) {
+		return 0, fmt.Errorf("model predicted no price, check the listing has the features it needs")
+	}
+	return price, nil
+}
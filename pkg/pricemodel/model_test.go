@@ -0,0 +1,220 @@
+package pricemodel
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"pinkbike-scraper/pkg/listing"
+)
+
+// The helpers below encode the onnx.proto messages a model is made of, so
+// tests can build models the way skl2onnx and friends export them.
+
+func field(num protowire.Number, b []byte) []byte {
+	return protowire.AppendBytes(protowire.AppendTag(nil, num, protowire.BytesType), b)
+}
+
+func varint(num protowire.Number, x int64) []byte {
+	return protowire.AppendVarint(protowire.AppendTag(nil, num, protowire.VarintType), uint64(x))
+}
+
+func join(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func stringsAttr(name string, values ...string) []byte {
+	b := field(1, []byte(name))
+	for _, v := range values {
+		b = append(b, field(9, []byte(v))...)
+	}
+	return b
+}
+
+func intsAttr(name string, values ...int64) []byte {
+	b := field(1, []byte(name))
+	for _, v := range values {
+		b = append(b, varint(8, v)...)
+	}
+	return b
+}
+
+func floatsAttr32(name string, values ...float32) []byte {
+	b := field(1, []byte(name))
+	for _, v := range values {
+		b = protowire.AppendFixed32(protowire.AppendTag(b, 7, protowire.Fixed32Type), math.Float32bits(v))
+	}
+	return b
+}
+
+func floatTensor(name string, dims []int64, values ...float32) []byte {
+	b := field(8, []byte(name))
+	for _, d := range dims {
+		b = append(b, varint(1, d)...)
+	}
+	b = append(b, varint(2, onnxFloat)...)
+	raw := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(raw[i*4:], math.Float32bits(v))
+	}
+	return append(b, field(9, raw)...)
+}
+
+func node(opType, domain string, inputs, outputs []string, attrs ...[]byte) []byte {
+	b := join(field(3, []byte(opType+"_node")), field(4, []byte(opType)), field(7, []byte(domain)))
+	for _, in := range inputs {
+		b = append(b, field(1, []byte(in))...)
+	}
+	for _, out := range outputs {
+		b = append(b, field(2, []byte(out))...)
+	}
+	for _, a := range attrs {
+		b = append(b, field(5, a)...)
+	}
+	return b
+}
+
+func model(features string, nodes [][]byte, initializers ...[]byte) []byte {
+	graph := join(field(11, field(1, []byte("input"))), field(12, field(1, []byte("variable"))))
+	for _, n := range nodes {
+		graph = append(graph, field(1, n)...)
+	}
+	for _, t := range initializers {
+		graph = append(graph, field(5, t)...)
+	}
+	b := field(7, graph)
+	if features != "" {
+		b = append(b, field(14, join(field(1, []byte(FeaturesKey)), field(2, []byte(features))))...)
+	}
+	return b
+}
+
+func TestLinearRegressor(t *testing.T) {
+	m, err := Parse(model("year, electric, material=Carbon Fiber", [][]byte{
+		node("LinearRegressor", "ai.onnx.ml", []string{"input"}, []string{"variable"},
+			floatsAttr32("coefficients", 100, 1000, 500), floatsAttr32("intercepts", -200000)),
+	}), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"year", "electric", "material=Carbon Fiber"}, m.Features())
+
+	price, err := m.Predict(listing.Listing{Year: "2022", FrameMaterial: "carbon fiber"})
+	require.NoError(t, err)
+	assert.InDelta(t, 2700, price, 0.01)
+
+	price, err = m.Predict(listing.Listing{Year: "2022", IsElectric: true, FrameMaterial: "Aluminum"})
+	require.NoError(t, err)
+	assert.InDelta(t, 3200, price, 0.01)
+
+	_, err = m.Predict(listing.Listing{FrameMaterial: "Aluminum"})
+	assert.Error(t, err, "a linear model cannot predict without the year")
+}
+
+func TestTreeEnsembleRegressor(t *testing.T) {
+	m, err := Parse(model("rear_travel", [][]byte{
+		node("TreeEnsembleRegressor", "ai.onnx.ml", []string{"input"}, []string{"variable"},
+			intsAttr("nodes_treeids", 0, 0, 0), intsAttr("nodes_nodeids", 0, 1, 2),
+			intsAttr("nodes_featureids", 0, 0, 0), stringsAttr("nodes_modes", "BRANCH_LEQ", "LEAF", "LEAF"),
+			floatsAttr32("nodes_values", 150, 0, 0),
+			intsAttr("nodes_truenodeids", 1, 0, 0), intsAttr("nodes_falsenodeids", 2, 0, 0),
+			intsAttr("nodes_missing_value_tracks_true", 1, 0, 0),
+			intsAttr("target_treeids", 0, 0), intsAttr("target_nodeids", 1, 2), intsAttr("target_ids", 0, 0),
+			floatsAttr32("target_weights", 2000, 3500), floatsAttr32("base_values", 500)),
+	}), nil)
+	require.NoError(t, err)
+
+	price, err := m.Predict(listing.Listing{RearTravel: "160 mm"})
+	require.NoError(t, err)
+	assert.InDelta(t, 4000, price, 0.01)
+
+	price, err = m.Predict(listing.Listing{RearTravel: "130mm"})
+	require.NoError(t, err)
+	assert.InDelta(t, 2500, price, 0.01)
+
+	price, err = m.Predict(listing.Listing{})
+	require.NoError(t, err)
+	assert.InDelta(t, 2500, price, 0.01, "missing travel follows the true branch")
+}
+
+func TestNeuralNetwork(t *testing.T) {
+	b := model("", [][]byte{
+		node("Gemm", "", []string{"input", "weights", "bias"}, []string{"hidden"}),
+		node("Relu", "", []string{"hidden"}, []string{"variable"}),
+	}, floatTensor("weights", []int64{1, 1}, 2), floatTensor("bias", []int64{1}, -4000))
+
+	_, err := Parse(b, nil)
+	assert.Error(t, err, "the model lists no features")
+
+	m, err := Parse(b, []string{"year"})
+	require.NoError(t, err)
+	price, err := m.Predict(listing.Listing{Year: "2022"})
+	require.NoError(t, err)
+	assert.InDelta(t, 44, price, 0.01)
+	price, err = m.Predict(listing.Listing{Year: "1990"})
+	require.NoError(t, err)
+	assert.Zero(t, price)
+
+	price, err = m.Predict(listing.Listing{Year: "2022"})
+	require.NoError(t, err)
+	assert.InDelta(t, 44, price, 0.01, "predicting leaves the weights as they were")
+}
+
+func TestParseRejects(t *testing.T) {
+	linear := node("LinearRegressor", "ai.onnx.ml", []string{"input"}, []string{"variable"},
+		floatsAttr32("coefficients", 1))
+
+	_, err := Parse(model("travel", [][]byte{linear}), nil)
+	assert.ErrorContains(t, err, `unknown feature "travel"`)
+	_, err = Parse(model("colour=red", [][]byte{linear}), nil)
+	assert.ErrorContains(t, err, `unknown categorical feature "colour"`)
+
+	_, err = Parse(model("year", [][]byte{
+		node("TopK", "", []string{"input"}, []string{"variable"}),
+	}), nil)
+	assert.ErrorContains(t, err, "unsupported operator TopK")
+	_, err = Parse(model("year", [][]byte{
+		node("Relu", "com.microsoft", []string{"input"}, []string{"variable"}),
+	}), nil)
+	assert.ErrorContains(t, err, "unsupported operator domain com.microsoft")
+
+	_, err = Parse([]byte("not a model"), nil)
+	assert.Error(t, err)
+}
+
+func TestFeatures(t *testing.T) {
+	l := listing.Listing{Year: "2021", NormalizedSize: "L", FrontTravel: "170mm", Manufacturer: "Santa Cruz",
+		Details: listing.ListingDetails{SellerType: listing.Private, PhotoCount: 6}}
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	for name, want := range map[string]float64{
+		"front_travel":            170,
+		"photos":                  6,
+		"manufacturer=santa cruz": 1,
+		"seller_type=private":     1,
+		"manufacturer=Yeti":       0,
+		"negotiable":              0,
+		"size=L":                  1,
+	} {
+		f, err := parseFeature(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, f(l, now), name)
+	}
+
+	size, err := parseFeature("size")
+	require.NoError(t, err)
+	assert.Greater(t, size(l, now), 1.0)
+	views, err := parseFeature("views")
+	require.NoError(t, err)
+	assert.True(t, math.IsNaN(views(l, now)), "an unknown count is missing, not zero")
+	age, err := parseFeature("age")
+	require.NoError(t, err)
+	assert.Equal(t, 3.0, age(l, now), "ages are taken at the time given")
+}
@@ -0,0 +1,385 @@
+package pricemodel
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The parts of an ONNX model inference needs, decoded straight from the
+// protobuf wire format of onnx.proto so no generated code is needed. Field
+// numbers are those of onnx.proto.
+
+type onnxModel struct {
+	graph    onnxGraph
+	metadata map[string]string
+}
+
+type onnxGraph struct {
+	nodes        []onnxNode
+	initializers map[string]tensor
+	inputs       []string
+	outputs      []string
+}
+
+type onnxNode struct {
+	name, opType, domain string
+	inputs, outputs      []string
+	attrs                map[string]attribute
+}
+
+type attribute struct {
+	f       float64
+	i       int64
+	s       string
+	t       *tensor
+	floats  []float64
+	ints    []int64
+	strings []string
+}
+
+// ONNX tensor element types
+const (
+	onnxFloat  = 1
+	onnxInt32  = 6
+	onnxInt64  = 7
+	onnxDouble = 11
+)
+
+func decodeModel(b []byte) (onnxModel, error) {
+	m := onnxModel{metadata: map[string]string{}}
+	err := eachField(b, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+		switch num {
+		case 7: // graph
+			g, err := decodeGraph(v)
+			if err != nil {
+				return err
+			}
+			m.graph = g
+		case 14: // metadata_props
+			var key, value string
+			err := eachField(v, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) error {
+				switch num {
+				case 1:
+					key = string(v)
+				case 2:
+					value = string(v)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			m.metadata[key] = value
+		}
+		return nil
+	})
+	if err != nil {
+		return onnxModel{}, err
+	}
+	if len(m.graph.nodes) == 0 {
+		return onnxModel{}, fmt.Errorf("model has no graph")
+	}
+	return m, nil
+}
+
+func decodeGraph(b []byte) (onnxGraph, error) {
+	g := onnxGraph{initializers: map[string]tensor{}}
+	err := eachField(b, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) error {
+		switch num {
+		case 1: // node
+			n, err := decodeNode(v)
+			if err != nil {
+				return err
+			}
+			g.nodes = append(g.nodes, n)
+		case 5: // initializer
+			name, t, err := decodeTensor(v)
+			if err != nil {
+				return err
+			}
+			g.initializers[name] = t
+		case 11, 12: // input, output
+			name, err := valueInfoName(v)
+			if err != nil {
+				return err
+			}
+			if num == 11 {
+				g.inputs = append(g.inputs, name)
+			} else {
+				g.outputs = append(g.outputs, name)
+			}
+		}
+		return nil
+	})
+	return g, err
+}
+
+func valueInfoName(b []byte) (string, error) {
+	var name string
+	err := eachField(b, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) error {
+		if num == 1 {
+			name = string(v)
+		}
+		return nil
+	})
+	return name, err
+}
+
+func decodeNode(b []byte) (onnxNode, error) {
+	n := onnxNode{attrs: map[string]attribute{}}
+	err := eachField(b, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) error {
+		switch num {
+		case 1:
+			n.inputs = append(n.inputs, string(v))
+		case 2:
+			n.outputs = append(n.outputs, string(v))
+		case 3:
+			n.name = string(v)
+		case 4:
+			n.opType = string(v)
+		case 5:
+			name, a, err := decodeAttribute(v)
+			if err != nil {
+				return err
+			}
+			n.attrs[name] = a
+		case 7:
+			n.domain = string(v)
+		}
+		return nil
+	})
+	return n, err
+}
+
+func decodeAttribute(b []byte) (string, attribute, error) {
+	var name string
+	var a attribute
+	err := eachField(b, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error {
+		switch num {
+		case 1:
+			name = string(v)
+		case 2:
+			a.f = float64(math.Float32frombits(uint32(x)))
+		case 3:
+			a.i = int64(x)
+		case 4:
+			a.s = string(v)
+		case 5:
+			_, t, err := decodeTensor(v)
+			if err != nil {
+				return err
+			}
+			a.t = &t
+		case 7:
+			floats, err := float32s(typ, v, x)
+			if err != nil {
+				return err
+			}
+			a.floats = append(a.floats, floats...)
+		case 8:
+			ints, err := varints(typ, v, x)
+			if err != nil {
+				return err
+			}
+			a.ints = append(a.ints, ints...)
+		case 9:
+			a.strings = append(a.strings, string(v))
+		}
+		return nil
+	})
+	return name, a, err
+}
+
+func decodeTensor(b []byte) (string, tensor, error) {
+	var (
+		name     string
+		dims     []int64
+		dataType int64
+		raw      []byte
+		data     []float64
+	)
+	err := eachField(b, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error {
+		switch num {
+		case 1:
+			ints, err := varints(typ, v, x)
+			if err != nil {
+				return err
+			}
+			dims = append(dims, ints...)
+		case 2:
+			dataType = int64(x)
+		case 4, 5: // float_data, int32_data
+			var values []float64
+			var err error
+			if num == 4 {
+				values, err = float32s(typ, v, x)
+			} else {
+				var ints []int64
+				ints, err = varints(typ, v, x)
+				for _, i := range ints {
+					values = append(values, float64(int32(i)))
+				}
+			}
+			if err != nil {
+				return err
+			}
+			data = append(data, values...)
+		case 7: // int64_data
+			ints, err := varints(typ, v, x)
+			if err != nil {
+				return err
+			}
+			for _, i := range ints {
+				data = append(data, float64(i))
+			}
+		case 8:
+			name = string(v)
+		case 9:
+			raw = v
+		case 10: // double_data
+			doubles, err := float64s(typ, v, x)
+			if err != nil {
+				return err
+			}
+			data = append(data, doubles...)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", tensor{}, err
+	}
+
+	if raw != nil {
+		if data, err = decodeRaw(raw, dataType); err != nil {
+			return "", tensor{}, fmt.Errorf("tensor %s: %w", name, err)
+		}
+	}
+	shape := make([]int, len(dims))
+	for i, d := range dims {
+		shape[i] = int(d)
+	}
+	t := tensor{shape: shape, data: data}
+	if t.size() != len(data) {
+		return "", tensor{}, fmt.Errorf("tensor %s has %d values for shape %v", name, len(data), shape)
+	}
+	return name, t, nil
+}
+
+func decodeRaw(raw []byte, dataType int64) ([]float64, error) {
+	var width int
+	switch dataType {
+	case onnxFloat, onnxInt32:
+		width = 4
+	case onnxInt64, onnxDouble:
+		width = 8
+	default:
+		return nil, fmt.Errorf("unsupported tensor element type %d", dataType)
+	}
+	if len(raw)%width != 0 {
+		return nil, fmt.Errorf("raw data is not a whole number of values")
+	}
+
+	data := make([]float64, len(raw)/width)
+	for i := range data {
+		chunk := raw[i*width:]
+		switch dataType {
+		case onnxFloat:
+			data[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(chunk)))
+		case onnxInt32:
+			data[i] = float64(int32(binary.LittleEndian.Uint32(chunk)))
+		case onnxInt64:
+			data[i] = float64(int64(binary.LittleEndian.Uint64(chunk)))
+		case onnxDouble:
+			data[i] = math.Float64frombits(binary.LittleEndian.Uint64(chunk))
+		}
+	}
+	return data, nil
+}
+
+// eachField calls visit with every field of a message: bytes fields with
+// their contents, varint and fixed-width fields with their value in x
+func eachField(b []byte, visit func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("malformed model: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		var v []byte
+		var x uint64
+		switch typ {
+		case protowire.VarintType:
+			x, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed32Type:
+			var x32 uint32
+			x32, n = protowire.ConsumeFixed32(b)
+			x = uint64(x32)
+		case protowire.Fixed64Type:
+			x, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return fmt.Errorf("malformed model: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		if err := visit(num, typ, v, x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// float32s reads a repeated float field, packed or not
+func float32s(typ protowire.Type, v []byte, x uint64) ([]float64, error) {
+	if typ == protowire.Fixed32Type {
+		return []float64{float64(math.Float32frombits(uint32(x)))}, nil
+	}
+	if len(v)%4 != 0 {
+		return nil, fmt.Errorf("malformed model: packed floats are not a whole number of values")
+	}
+	values := make([]float64, len(v)/4)
+	for i := range values {
+		values[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(v[i*4:])))
+	}
+	return values, nil
+}
+
+// float64s reads a repeated double field, packed or not
+func float64s(typ protowire.Type, v []byte, x uint64) ([]float64, error) {
+	if typ == protowire.Fixed64Type {
+		return []float64{math.Float64frombits(x)}, nil
+	}
+	if len(v)%8 != 0 {
+		return nil, fmt.Errorf("malformed model: packed doubles are not a whole number of values")
+	}
+	values := make([]float64, len(v)/8)
+	for i := range values {
+		values[i] = math.Float64frombits(binary.LittleEndian.Uint64(v[i*8:]))
+	}
+	return values, nil
+}
+
+// varints reads a repeated integer field, packed or not
+func varints(typ protowire.Type, v []byte, x uint64) ([]int64, error) {
+	if typ == protowire.VarintType {
+		return []int64{int64(x)}, nil
+	}
+	var values []int64
+	for len(v) > 0 {
+		value, n := protowire.ConsumeVarint(v)
+		if n < 0 {
+			return nil, fmt.Errorf("malformed model: %w", protowire.ParseError(n))
+		}
+		values = append(values, int64(value))
+		v = v[n:]
+	}
+	return values, nil
+}
@@ -0,0 +1,546 @@
+package pricemodel
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// tensor is a dense array of values in row-major order. Every element type is
+// held as float64, which loses nothing a price model needs.
+type tensor struct {
+	shape []int
+	data  []float64
+}
+
+func (t tensor) size() int {
+	n := 1
+	for _, d := range t.shape {
+		n *= d
+	}
+	return n
+}
+
+// matrix views t as rows and columns: a vector is one row, and higher ranks
+// fold every dimension but the last into rows
+func (t tensor) matrix() (rows, cols int) {
+	switch len(t.shape) {
+	case 0:
+		return 1, 1
+	case 1:
+		return 1, t.shape[0]
+	}
+	cols = t.shape[len(t.shape)-1]
+	return t.size() / cols, cols
+}
+
+// op evaluates a node on its inputs, returning its first output
+type op func(n onnxNode, in []tensor) (tensor, error)
+
+// ops are the operators a price model may use: the arithmetic and
+// activations of small neural networks and linear models, and the scalers,
+// linear and tree ensemble regressors of the ai.onnx.ml domain that
+// scikit-learn, XGBoost and LightGBM models convert to
+var ops = map[string]op{
+	"Identity": passThrough,
+	"Cast":     passThrough,
+	"Reshape":  reshape,
+	"Flatten":  flatten,
+	"Concat":   concat,
+	"Add":      elementwise(func(a, b float64) float64 { return a + b }),
+	"Sub":      elementwise(func(a, b float64) float64 { return a - b }),
+	"Mul":      elementwise(func(a, b float64) float64 { return a * b }),
+	"Div":      elementwise(func(a, b float64) float64 { return a / b }),
+	"Relu":     unary(func(x float64) float64 { return math.Max(x, 0) }),
+	"Sigmoid":  unary(func(x float64) float64 { return 1 / (1 + math.Exp(-x)) }),
+	"Tanh":     unary(math.Tanh),
+	"Exp":      unary(math.Exp),
+	"MatMul":   matMul,
+	"Gemm":     gemm,
+
+	"Scaler":                scaler,
+	"LinearRegressor":       linearRegressor,
+	"TreeEnsembleRegressor": treeEnsembleRegressor,
+}
+
+func passThrough(_ onnxNode, in []tensor) (tensor, error) {
+	return in[0], nil
+}
+
+func unary(f func(float64) float64) op {
+	return func(_ onnxNode, in []tensor) (tensor, error) {
+		out := tensor{shape: in[0].shape, data: make([]float64, len(in[0].data))}
+		for i, x := range in[0].data {
+			out.data[i] = f(x)
+		}
+		return out, nil
+	}
+}
+
+// elementwise applies f to two tensors broadcast against each other the way
+// numpy does
+func elementwise(f func(a, b float64) float64) op {
+	return func(_ onnxNode, in []tensor) (tensor, error) {
+		if len(in) != 2 {
+			return tensor{}, fmt.Errorf("expected 2 inputs, got %d", len(in))
+		}
+		a, b := in[0], in[1]
+		rank := len(a.shape)
+		if len(b.shape) > rank {
+			rank = len(b.shape)
+		}
+		aShape, bShape := padShape(a.shape, rank), padShape(b.shape, rank)
+		shape := make([]int, rank)
+		for i := range shape {
+			switch {
+			case aShape[i] == bShape[i], bShape[i] == 1:
+				shape[i] = aShape[i]
+			case aShape[i] == 1:
+				shape[i] = bShape[i]
+			default:
+				return tensor{}, fmt.Errorf("cannot broadcast shapes %v and %v", a.shape, b.shape)
+			}
+		}
+
+		out := tensor{shape: shape}
+		out.data = make([]float64, out.size())
+		index := make([]int, rank)
+		for i := range out.data {
+			out.data[i] = f(a.data[broadcastOffset(index, aShape)], b.data[broadcastOffset(index, bShape)])
+			for d := rank - 1; d >= 0; d-- {
+				if index[d]++; index[d] < shape[d] {
+					break
+				}
+				index[d] = 0
+			}
+		}
+		return out, nil
+	}
+}
+
+func padShape(shape []int, rank int) []int {
+	padded := make([]int, rank-len(shape), rank)
+	for i := range padded {
+		padded[i] = 1
+	}
+	return append(padded, shape...)
+}
+
+// broadcastOffset is the position in a tensor of shape of the element at
+// index of the broadcast result
+func broadcastOffset(index, shape []int) int {
+	offset := 0
+	for d, n := range shape {
+		i := index[d]
+		if n == 1 {
+			i = 0
+		}
+		offset = offset*n + i
+	}
+	return offset
+}
+
+func reshape(_ onnxNode, in []tensor) (tensor, error) {
+	if len(in) != 2 {
+		return tensor{}, fmt.Errorf("expected 2 inputs, got %d", len(in))
+	}
+	shape := make([]int, len(in[1].data))
+	unknown := -1
+	known := 1
+	for i, d := range in[1].data {
+		switch {
+		case d == -1:
+			unknown = i
+			continue
+		case d == 0 && i < len(in[0].shape):
+			shape[i] = in[0].shape[i]
+		default:
+			shape[i] = int(d)
+		}
+		known *= shape[i]
+	}
+	if unknown >= 0 {
+		if known == 0 {
+			return tensor{}, fmt.Errorf("cannot infer dimension of reshape to %v", in[1].data)
+		}
+		shape[unknown] = len(in[0].data) / known
+	}
+	out := tensor{shape: shape, data: in[0].data}
+	if out.size() != len(out.data) {
+		return tensor{}, fmt.Errorf("cannot reshape %v to %v", in[0].shape, shape)
+	}
+	return out, nil
+}
+
+func flatten(n onnxNode, in []tensor) (tensor, error) {
+	axis := 1
+	if a, ok := n.attrs["axis"]; ok {
+		axis = int(a.i)
+	}
+	if axis < 0 {
+		axis += len(in[0].shape)
+	}
+	rows := 1
+	for _, d := range in[0].shape[:axis] {
+		rows *= d
+	}
+	return tensor{shape: []int{rows, len(in[0].data) / rows}, data: in[0].data}, nil
+}
+
+// concat joins matrices along their last axis, which is how feature columns
+// are put together
+func concat(n onnxNode, in []tensor) (tensor, error) {
+	axis := n.attrs["axis"].i
+	rows, _ := in[0].matrix()
+	if len(in[0].shape) > 2 || (axis != 1 && axis != -1 && !(axis == 0 && len(in[0].shape) == 1)) {
+		return tensor{}, fmt.Errorf("only joining columns of matrices is supported")
+	}
+
+	cols := 0
+	for _, t := range in {
+		r, c := t.matrix()
+		if r != rows {
+			return tensor{}, fmt.Errorf("cannot join %d rows to %d", r, rows)
+		}
+		cols += c
+	}
+	out := tensor{shape: []int{rows, cols}, data: make([]float64, 0, rows*cols)}
+	if len(in[0].shape) == 1 {
+		out.shape = []int{cols}
+	}
+	for r := 0; r < rows; r++ {
+		for _, t := range in {
+			_, c := t.matrix()
+			out.data = append(out.data, t.data[r*c:(r+1)*c]...)
+		}
+	}
+	return out, nil
+}
+
+func matMul(_ onnxNode, in []tensor) (tensor, error) {
+	if len(in) != 2 {
+		return tensor{}, fmt.Errorf("expected 2 inputs, got %d", len(in))
+	}
+	vector := len(in[1].shape) == 1
+	b := in[1]
+	if vector {
+		b = tensor{shape: []int{b.shape[0], 1}, data: b.data}
+	}
+	out, err := multiply(in[0], b, false, false)
+	if err != nil {
+		return tensor{}, err
+	}
+	if vector {
+		out.shape = out.shape[:1]
+	}
+	return out, nil
+}
+
+func gemm(n onnxNode, in []tensor) (tensor, error) {
+	if len(in) < 2 {
+		return tensor{}, fmt.Errorf("expected at least 2 inputs, got %d", len(in))
+	}
+	alpha, beta := 1.0, 1.0
+	if a, ok := n.attrs["alpha"]; ok {
+		alpha = a.f
+	}
+	if b, ok := n.attrs["beta"]; ok {
+		beta = b.f
+	}
+	out, err := multiply(in[0], in[1], n.attrs["transA"].i != 0, n.attrs["transB"].i != 0)
+	if err != nil {
+		return tensor{}, err
+	}
+	for i := range out.data {
+		out.data[i] *= alpha
+	}
+	if len(in) < 3 {
+		return out, nil
+	}
+
+	c := tensor{shape: in[2].shape, data: make([]float64, len(in[2].data))}
+	for i, v := range in[2].data {
+		c.data[i] = v * beta
+	}
+	return elementwise(func(a, b float64) float64 { return a + b })(n, []tensor{out, c})
+}
+
+// multiply returns the matrix product of a and b, either transposed first
+func multiply(a, b tensor, transA, transB bool) (tensor, error) {
+	if len(a.shape) != 2 || len(b.shape) != 2 {
+		return tensor{}, fmt.Errorf("expected matrices, got shapes %v and %v", a.shape, b.shape)
+	}
+	m, k := a.shape[0], a.shape[1]
+	if transA {
+		m, k = k, m
+	}
+	kb, cols := b.shape[0], b.shape[1]
+	if transB {
+		kb, cols = cols, kb
+	}
+	if k != kb {
+		return tensor{}, fmt.Errorf("cannot multiply shapes %v and %v", a.shape, b.shape)
+	}
+
+	at := func(i, j int) float64 {
+		if transA {
+			return a.data[j*a.shape[1]+i]
+		}
+		return a.data[i*a.shape[1]+j]
+	}
+	bt := func(i, j int) float64 {
+		if transB {
+			return b.data[j*b.shape[1]+i]
+		}
+		return b.data[i*b.shape[1]+j]
+	}
+
+	out := tensor{shape: []int{m, cols}, data: make([]float64, m*cols)}
+	for i := 0; i < m; i++ {
+		for j := 0; j < cols; j++ {
+			var sum float64
+			for x := 0; x < k; x++ {
+				sum += at(i, x) * bt(x, j)
+			}
+			out.data[i*cols+j] = sum
+		}
+	}
+	return out, nil
+}
+
+// scaler computes (x - offset) * scale per column, or with one offset and
+// scale for every column
+func scaler(n onnxNode, in []tensor) (tensor, error) {
+	offset, scale := n.attrs["offset"].floats, n.attrs["scale"].floats
+	rows, cols := in[0].matrix()
+	pick := func(values []float64, col int, fallback float64) float64 {
+		switch len(values) {
+		case 0:
+			return fallback
+		case 1:
+			return values[0]
+		}
+		return values[col]
+	}
+	if (len(offset) > 1 && len(offset) != cols) || (len(scale) > 1 && len(scale) != cols) {
+		return tensor{}, fmt.Errorf("scaler has %d offsets and %d scales for %d columns", len(offset), len(scale), cols)
+	}
+
+	out := tensor{shape: in[0].shape, data: make([]float64, len(in[0].data))}
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			i := r*cols + c
+			out.data[i] = (in[0].data[i] - pick(offset, c, 0)) * pick(scale, c, 1)
+		}
+	}
+	return out, nil
+}
+
+func linearRegressor(n onnxNode, in []tensor) (tensor, error) {
+	if t := n.attrs["post_transform"].s; t != "" && t != "NONE" {
+		return tensor{}, fmt.Errorf("unsupported post_transform %s", t)
+	}
+	targets := 1
+	if t, ok := n.attrs["targets"]; ok {
+		targets = int(t.i)
+	}
+	coefficients, intercepts := n.attrs["coefficients"].floats, n.attrs["intercepts"].floats
+	rows, cols := in[0].matrix()
+	if len(coefficients) != targets*cols {
+		return tensor{}, fmt.Errorf("%d coefficients for %d features and %d targets", len(coefficients), cols, targets)
+	}
+
+	out := tensor{shape: []int{rows, targets}, data: make([]float64, rows*targets)}
+	for r := 0; r < rows; r++ {
+		for t := 0; t < targets; t++ {
+			var sum float64
+			if t < len(intercepts) {
+				sum = intercepts[t]
+			}
+			for c := 0; c < cols; c++ {
+				sum += coefficients[t*cols+c] * in[0].data[r*cols+c]
+			}
+			out.data[r*targets+t] = sum
+		}
+	}
+	return out, nil
+}
+
+// floatsAttr reads an attribute given as floats, or as a double tensor in
+// its name_as_tensor form
+func floatsAttr(n onnxNode, name string) []float64 {
+	if a, ok := n.attrs[name]; ok {
+		return a.floats
+	}
+	if a, ok := n.attrs[name+"_as_tensor"]; ok && a.t != nil {
+		return a.t.data
+	}
+	return nil
+}
+
+type treeNode struct {
+	mode                string
+	feature             int
+	value               float64
+	trueNode, falseNode int
+	missingTracksTrue   bool
+	weights             []treeWeight
+}
+
+type treeWeight struct {
+	target int
+	weight float64
+}
+
+func treeEnsembleRegressor(n onnxNode, in []tensor) (tensor, error) {
+	if t := n.attrs["post_transform"].s; t != "" && t != "NONE" {
+		return tensor{}, fmt.Errorf("unsupported post_transform %s", t)
+	}
+	targets := 1
+	if t, ok := n.attrs["n_targets"]; ok {
+		targets = int(t.i)
+	}
+
+	treeIDs, nodeIDs := n.attrs["nodes_treeids"].ints, n.attrs["nodes_nodeids"].ints
+	features, modes := n.attrs["nodes_featureids"].ints, n.attrs["nodes_modes"].strings
+	trueIDs, falseIDs := n.attrs["nodes_truenodeids"].ints, n.attrs["nodes_falsenodeids"].ints
+	missing := n.attrs["nodes_missing_value_tracks_true"].ints
+	values := floatsAttr(n, "nodes_values")
+	for _, l := range [][]int64{nodeIDs, features, trueIDs, falseIDs} {
+		if len(l) != len(treeIDs) {
+			return tensor{}, fmt.Errorf("tree node attributes differ in length")
+		}
+	}
+	if len(modes) != len(treeIDs) || len(values) != len(treeIDs) {
+		return tensor{}, fmt.Errorf("tree node attributes differ in length")
+	}
+
+	type key struct{ tree, node int64 }
+	index := map[key]int{}
+	nodes := make([]treeNode, len(treeIDs))
+	var roots []int
+	seenTree := map[int64]bool{}
+	for i := range treeIDs {
+		index[key{treeIDs[i], nodeIDs[i]}] = i
+		nodes[i] = treeNode{mode: modes[i], feature: int(features[i]), value: values[i]}
+		if i < len(missing) {
+			nodes[i].missingTracksTrue = missing[i] != 0
+		}
+		if !seenTree[treeIDs[i]] {
+			seenTree[treeIDs[i]] = true
+			roots = append(roots, i)
+		}
+	}
+	for i := range nodes {
+		if nodes[i].mode == "LEAF" {
+			continue
+		}
+		t, ok1 := index[key{treeIDs[i], trueIDs[i]}]
+		f, ok2 := index[key{treeIDs[i], falseIDs[i]}]
+		if !ok1 || !ok2 {
+			return tensor{}, fmt.Errorf("tree %d node %d branches to a missing node", treeIDs[i], nodeIDs[i])
+		}
+		nodes[i].trueNode, nodes[i].falseNode = t, f
+	}
+
+	targetTrees, targetNodes := n.attrs["target_treeids"].ints, n.attrs["target_nodeids"].ints
+	targetIDs, weights := n.attrs["target_ids"].ints, floatsAttr(n, "target_weights")
+	if len(targetNodes) != len(targetTrees) || len(targetIDs) != len(targetTrees) || len(weights) != len(targetTrees) {
+		return tensor{}, fmt.Errorf("tree target attributes differ in length")
+	}
+	for i := range targetTrees {
+		leaf, ok := index[key{targetTrees[i], targetNodes[i]}]
+		if !ok {
+			return tensor{}, fmt.Errorf("tree %d has no leaf %d", targetTrees[i], targetNodes[i])
+		}
+		if targetIDs[i] < 0 || int(targetIDs[i]) >= targets {
+			return tensor{}, fmt.Errorf("tree %d weights target %d of %d", targetTrees[i], targetIDs[i], targets)
+		}
+		nodes[leaf].weights = append(nodes[leaf].weights, treeWeight{target: int(targetIDs[i]), weight: weights[i]})
+	}
+
+	aggregate := strings.ToUpper(n.attrs["aggregate_function"].s)
+	if aggregate == "" {
+		aggregate = "SUM"
+	}
+	base := floatsAttr(n, "base_values")
+
+	rows, cols := in[0].matrix()
+	out := tensor{shape: []int{rows, targets}, data: make([]float64, rows*targets)}
+	for r := 0; r < rows; r++ {
+		x := in[0].data[r*cols : (r+1)*cols]
+		sums := make([]float64, targets)
+		for t := range sums {
+			switch aggregate {
+			case "MIN":
+				sums[t] = math.Inf(1)
+			case "MAX":
+				sums[t] = math.Inf(-1)
+			}
+		}
+		for _, root := range roots {
+			leaf, err := walkTree(nodes, root, x)
+			if err != nil {
+				return tensor{}, err
+			}
+			for _, w := range leaf.weights {
+				switch aggregate {
+				case "MIN":
+					sums[w.target] = math.Min(sums[w.target], w.weight)
+				case "MAX":
+					sums[w.target] = math.Max(sums[w.target], w.weight)
+				default:
+					sums[w.target] += w.weight
+				}
+			}
+		}
+		for t, sum := range sums {
+			if aggregate == "AVERAGE" {
+				sum /= float64(len(roots))
+			}
+			if t < len(base) {
+				sum += base[t]
+			}
+			out.data[r*targets+t] = sum
+		}
+	}
+	return out, nil
+}
+
+func walkTree(nodes []treeNode, i int, x []float64) (treeNode, error) {
+	for steps := 0; steps <= len(nodes); steps++ {
+		node := nodes[i]
+		if node.mode == "LEAF" {
+			return node, nil
+		}
+		if node.feature >= len(x) {
+			return treeNode{}, fmt.Errorf("tree splits on feature %d of %d", node.feature, len(x))
+		}
+		v := x[node.feature]
+		var branch bool
+		switch {
+		case math.IsNaN(v):
+			branch = node.missingTracksTrue
+		case node.mode == "BRANCH_LEQ":
+			branch = v <= node.value
+		case node.mode == "BRANCH_LT":
+			branch = v < node.value
+		case node.mode == "BRANCH_GTE":
+			branch = v >= node.value
+		case node.mode == "BRANCH_GT":
+			branch = v > node.value
+		case node.mode == "BRANCH_EQ":
+			branch = v == node.value
+		case node.mode == "BRANCH_NEQ":
+			branch = v != node.value
+		default:
+			return treeNode{}, fmt.Errorf("unknown tree node mode %s", node.mode)
+		}
+		if branch {
+			i = node.trueNode
+		} else {
+			i = node.falseNode
+		}
+	}
+	return treeNode{}, fmt.Errorf("tree has a cycle")
+}
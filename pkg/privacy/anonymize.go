@@ -0,0 +1,98 @@
+package privacy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"pinkbike-scraper/pkg/listing"
+)
+
+// Mode is how an Anonymizer treats seller-identifying fields
+type Mode string
+
+const (
+	// Hash replaces the seller's username and phone number with salted
+	// hashes, so listings by the same seller can still be grouped
+	Hash Mode = "hash"
+	// Drop clears them
+	Drop Mode = "drop"
+)
+
+// ParseMode parses "hash" or "drop"
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(strings.TrimSpace(s))); m {
+	case Hash, Drop:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown anonymization mode %q (available: %s, %s)", s, Hash, Drop)
+}
+
+var (
+	// phonePattern matches North American phone numbers however they are
+	// punctuated, such as 604-555-0123, (604) 555 0123 or +1.604.555.0123
+	phonePattern = regexp.MustCompile(`(?:\+?1[\s.-]?)?\(?\b\d{3}\)?[\s.-]?\d{3}[\s.-]?\d{4}\b`)
+	emailPattern = regexp.MustCompile(`[\w.+-]+@[\w-]+(?:\.[\w-]+)+`)
+)
+
+// Anonymizer removes data identifying sellers from listings before they are
+// shared
+type Anonymizer struct {
+	Mode Mode
+	// Salt is mixed into hashes so they cannot be reversed by hashing
+	// known usernames. Keep it secret, and the same across exports that
+	// should group sellers consistently.
+	Salt string
+}
+
+// Listing returns l without seller-identifying data: the seller's username
+// is hashed or dropped, their profile link dropped, and phone numbers and
+// email addresses are removed from the phone field, title, description and
+// restrictions. The hash is computed before the title is scrubbed, so the
+// listing keeps its identity.
+func (a Anonymizer) Listing(l listing.Listing) listing.Listing {
+	if l.Hash == "" {
+		l.Hash = l.ComputeHash()
+	}
+
+	l.Title = Scrub(l.Title)
+	l.Details.Description = Scrub(l.Details.Description)
+	l.Details.Restrictions = Scrub(l.Details.Restrictions)
+	l.Details.SellerURL = ""
+	if a.Mode == Hash {
+		l.Details.Seller = a.hash("seller", l.Details.Seller)
+		l.Details.Phone = a.hash("phone", l.Details.Phone)
+	} else {
+		l.Details.Seller = ""
+		l.Details.Phone = ""
+	}
+	return l
+}
+
+// Listings anonymizes every listing, leaving listings untouched
+func (a Anonymizer) Listings(listings []listing.Listing) []listing.Listing {
+	anonymized := make([]listing.Listing, len(listings))
+	for i, l := range listings {
+		anonymized[i] = a.Listing(l)
+	}
+	return anonymized
+}
+
+// hash returns a short salted hash of value prefixed with kind, or "" for an
+// empty value
+func (a Anonymizer) hash(kind, value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(a.Salt + "|" + kind + "|" + value))
+	return kind + "-" + hex.EncodeToString(sum[:])[:12]
+}
+
+// Scrub replaces phone numbers and email addresses in text
+func Scrub(text string) string {
+	text = emailPattern.ReplaceAllString(text, "[email removed]")
+	return phonePattern.ReplaceAllString(text, "[phone removed]")
+}
@@ -0,0 +1,58 @@
+package privacy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pinkbike-scraper/pkg/listing"
+)
+
+func TestScrub(t *testing.T) {
+	assert.Equal(t, "Call [phone removed] or [phone removed], no trades",
+		Scrub("Call 604-555-0123 or (604) 555 0123, no trades"))
+	assert.Equal(t, "Text [phone removed]", Scrub("Text +1.604.555.0123"))
+	assert.Equal(t, "Email [email removed] for photos", Scrub("Email rider.one+bikes@example.co.uk for photos"))
+	assert.Equal(t, "Retailed for $8,000, 2023 model, 150mm", Scrub("Retailed for $8,000, 2023 model, 150mm"))
+}
+
+func TestAnonymizer(t *testing.T) {
+	l := listing.Listing{Title: "2022 Evil Offering call 604 555 0123", Price: "4200"}.
+		WithDetails(listing.ListingDetails{
+			Description: "Great bike, email me at rider@example.com",
+			Seller:      "MountainAdventureEquipment",
+			SellerURL:   "https://www.pinkbike.com/u/MountainAdventureEquipment/",
+			Phone:       "604-555-0123",
+		})
+	hash := l.ComputeHash()
+
+	hashed := Anonymizer{Mode: Hash, Salt: "secret"}.Listing(l)
+	assert.Equal(t, hash, hashed.Hash, "the listing keeps its identity")
+	assert.Equal(t, "2022 Evil Offering call [phone removed]", hashed.Title)
+	assert.Equal(t, "Great bike, email me at [email removed]", hashed.Details.Description)
+	assert.Empty(t, hashed.Details.SellerURL)
+	assert.Regexp(t, `^seller-[0-9a-f]{12}$`, hashed.Details.Seller)
+	assert.Regexp(t, `^phone-[0-9a-f]{12}$`, hashed.Details.Phone)
+	assert.Equal(t, "MountainAdventureEquipment", l.Details.Seller, "the original is left untouched")
+
+	again := Anonymizer{Mode: Hash, Salt: "secret"}.Listing(l)
+	assert.Equal(t, hashed.Details.Seller, again.Details.Seller, "the same salt groups a seller's listings")
+	other := Anonymizer{Mode: Hash, Salt: "other"}.Listing(l)
+	assert.NotEqual(t, hashed.Details.Seller, other.Details.Seller)
+
+	dropped := Anonymizer{Mode: Drop}.Listings([]listing.Listing{l})
+	require.Len(t, dropped, 1)
+	assert.Empty(t, dropped[0].Details.Seller)
+	assert.Empty(t, dropped[0].Details.Phone)
+	assert.Equal(t, "Great bike, email me at [email removed]", dropped[0].Details.Description)
+}
+
+func TestParseMode(t *testing.T) {
+	mode, err := ParseMode(" DROP ")
+	require.NoError(t, err)
+	assert.Equal(t, Drop, mode)
+
+	_, err = ParseMode("mask")
+	assert.Error(t, err)
+}
@@ -0,0 +1,117 @@
+// Package privacy protects data before it is published: aggregates, so prices
+// of individual sellers cannot be worked out from small cohorts, and listings,
+// whose seller-identifying fields are hashed or dropped
+package privacy
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// medianStep is the resolution, in USD, of noisy medians
+const medianStep = 50
+
+// Options controls how a cohort's listing count and median price are
+// protected. The zero value publishes exact values.
+type Options struct {
+	// MinCount suppresses cohorts with fewer listings. With noise enabled the
+	// noisy count is compared so suppression does not leak the exact count.
+	MinCount int
+	// Epsilon is the differential privacy budget spent on each cohort, split
+	// evenly between its count and its median. Cohorts of one summary do not
+	// share listings, so a summary spends Epsilon in all; summaries of the
+	// same listings each spend theirs, see Split. Zero disables noise;
+	// smaller values add more noise.
+	Epsilon float64
+	// MaxPrice bounds the prices a noisy median can report
+	MaxPrice float64
+
+	rng *rand.Rand
+}
+
+// Enabled reports whether any protection is applied
+func (o Options) Enabled() bool {
+	return o.MinCount > 0 || o.Epsilon > 0
+}
+
+// Split returns the options for one of n summaries of the same listings, each
+// spending an nth of the budget so together they spend Epsilon
+func (o *Options) Split(n int) Options {
+	split := *o
+	if n > 1 {
+		split.Epsilon = o.Epsilon / float64(n)
+	}
+	return split
+}
+
+func (o *Options) random() *rand.Rand {
+	if o.rng == nil {
+		var seed [8]byte
+		crand.Read(seed[:])
+		o.rng = rand.New(rand.NewSource(int64(binary.LittleEndian.Uint64(seed[:]))))
+	}
+	return o.rng
+}
+
+// Count returns the count to publish for a cohort of n listings and whether
+// the cohort may be published at all
+func (o *Options) Count(n int) (int, bool) {
+	count := n
+	if o.Epsilon > 0 {
+		// a listing changes the count by at most one
+		count = int(math.Round(float64(n) + o.laplace(1/(o.Epsilon/2))))
+		if count < 0 {
+			count = 0
+		}
+	}
+	return count, count >= o.MinCount && count > 0
+}
+
+// Median returns the median price to publish. With noise enabled it is
+// chosen with the exponential mechanism over medianStep wide buckets up to
+// MaxPrice, so it stays within the range of real prices.
+func (o *Options) Median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	if o.Epsilon <= 0 || o.MaxPrice <= 0 {
+		mid := len(sorted) / 2
+		if len(sorted)%2 == 0 {
+			return (sorted[mid-1] + sorted[mid]) / 2
+		}
+		return sorted[mid]
+	}
+
+	// utility of a candidate is minus how unbalanced the split around it is,
+	// which one listing changes by at most one
+	eps := o.Epsilon / 2
+	buckets := int(o.MaxPrice / medianStep)
+	weights := make([]float64, buckets+1)
+	total := 0.0
+	for i := range weights {
+		candidate := float64(i * medianStep)
+		below := sort.SearchFloat64s(sorted, candidate)
+		above := len(sorted) - below
+		utility := -math.Abs(float64(below - above))
+		weights[i] = math.Exp(eps * utility / 2)
+		total += weights[i]
+	}
+
+	pick := o.random().Float64() * total
+	for i, w := range weights {
+		pick -= w
+		if pick <= 0 {
+			return float64(i * medianStep)
+		}
+	}
+	return float64(buckets * medianStep)
+}
+
+// laplace samples zero-centred Laplace noise with the given scale
+func (o *Options) laplace(scale float64) float64 {
+	u := o.random().Float64() - 0.5
+	return -scale * math.Copysign(1, u) * math.Log(1-2*math.Abs(u))
+}
@@ -0,0 +1,56 @@
+package privacy
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExactWhenDisabled(t *testing.T) {
+	var o Options
+	assert.False(t, o.Enabled())
+
+	count, ok := o.Count(3)
+	assert.True(t, ok)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, 2500.0, o.Median([]float64{3000, 2000, 2500}))
+}
+
+func TestMinCountSuppression(t *testing.T) {
+	o := Options{MinCount: 5}
+
+	_, ok := o.Count(4)
+	assert.False(t, ok)
+	count, ok := o.Count(5)
+	assert.True(t, ok)
+	assert.Equal(t, 5, count)
+}
+
+func TestNoiseStaysNearTrueValues(t *testing.T) {
+	o := Options{Epsilon: 2, MaxPrice: 10000, rng: rand.New(rand.NewSource(1))}
+
+	prices := make([]float64, 0, 100)
+	for i := 0; i < 100; i++ {
+		prices = append(prices, 3000+float64(i*10))
+	}
+
+	for i := 0; i < 50; i++ {
+		count, _ := o.Count(100)
+		assert.InDelta(t, 100, count, 15)
+
+		m := o.Median(prices)
+		assert.InDelta(t, 3500, m, 300)
+		assert.Zero(t, int(m)%medianStep)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	o := Options{MinCount: 3, Epsilon: 2, MaxPrice: 10000}
+
+	half := o.Split(2)
+	assert.Equal(t, 1.0, half.Epsilon)
+	assert.Equal(t, 3, half.MinCount)
+	assert.Equal(t, 2.0, o.Epsilon, "the options split are left alone")
+	assert.Equal(t, o, o.Split(1))
+}
@@ -0,0 +1,192 @@
+// Package progress draws progress bars with a rate and an estimated time
+// left for the long steps of a run, such as paging through listings
+package progress
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"pinkbike-scraper/pkg/clock"
+)
+
+const (
+	// barWidth is how many characters the bar itself spans
+	barWidth = 24
+	// redrawEvery bounds how often a terminal bar is redrawn
+	redrawEvery = 100 * time.Millisecond
+	// logEvery is how long a bar written to a log goes without a line
+	// while it does not cross a tenth of its total
+	logEvery = 30 * time.Second
+)
+
+// Output is where bars are drawn. A nil Output draws nothing, for quiet runs.
+type Output struct {
+	w io.Writer
+	// interactive redraws each bar in place on a terminal; otherwise a line
+	// is written each tenth of the total, for logs
+	interactive bool
+	clock       clock.Clock
+}
+
+// NewOutput draws bars on w, redrawn in place when w is a terminal
+func NewOutput(w io.Writer, interactive bool, c clock.Clock) *Output {
+	return &Output{w: w, interactive: interactive, clock: clock.Or(c)}
+}
+
+// Printf writes a status line between bars, unless o is nil
+func (o *Output) Printf(format string, args ...interface{}) {
+	if o == nil {
+		return
+	}
+	fmt.Fprintf(o.w, format, args...)
+}
+
+// Start draws a new bar counting items of unit, such as "listings", with
+// total of them to go. A total of zero is unknown, drawn as a count and a rate.
+func (o *Output) Start(label, unit string, total int) *Bar {
+	if o == nil {
+		return nil
+	}
+	b := &Bar{out: o, label: label, unit: unit, total: total, start: o.clock.Now()}
+	b.mu.Lock()
+	b.draw(false)
+	b.mu.Unlock()
+	return b
+}
+
+// Bar is the progress of one step. Its methods may be called from several
+// goroutines, and do nothing on a nil Bar.
+type Bar struct {
+	out         *Output
+	label, unit string
+
+	mu          sync.Mutex
+	total, done int
+	start       time.Time
+	// drawn is when the bar was last drawn and tenth the tenths of the total
+	// done by then
+	drawn    time.Time
+	tenth    int
+	finished bool
+}
+
+// SetTotal changes how many items the step has, once it is known
+func (b *Bar) SetTotal(total int) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.total = total
+	b.draw(false)
+}
+
+// Add counts n more items done
+func (b *Bar) Add(n int) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.done += n
+	b.draw(false)
+}
+
+// Done draws the bar a last time with how long the step took
+func (b *Bar) Done() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.finished {
+		return
+	}
+	b.finished = true
+	b.draw(true)
+}
+
+// draw writes the bar, unless it was drawn too recently. final forces it and
+// ends the line.
+func (b *Bar) draw(final bool) {
+	now := b.out.clock.Now()
+	elapsed := now.Sub(b.start)
+	if !final {
+		if b.out.interactive {
+			if !b.drawn.IsZero() && now.Sub(b.drawn) < redrawEvery {
+				return
+			}
+		} else {
+			tenth := 0
+			if b.total > 0 {
+				tenth = b.done * 10 / b.total
+			}
+			if !b.drawn.IsZero() && tenth == b.tenth && now.Sub(b.drawn) < logEvery {
+				return
+			}
+			b.tenth = tenth
+		}
+	}
+	b.drawn = now
+
+	line := b.line(elapsed, final)
+	if b.out.interactive {
+		// the escape clears what is left of a longer line drawn before
+		fmt.Fprintf(b.out.w, "\r%s\x1b[K", line)
+		if final {
+			fmt.Fprintln(b.out.w)
+		}
+		return
+	}
+	fmt.Fprintln(b.out.w, line)
+}
+
+// line is the bar's text, such as
+// "Details [=======>        ] 42/120 35% 3.1 listings/s ETA 25s"
+func (b *Bar) line(elapsed time.Duration, final bool) string {
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(b.done) / elapsed.Seconds()
+	}
+	parts := []string{b.label}
+	if b.total > 0 {
+		parts = append(parts, bar(b.done, b.total), fmt.Sprintf("%d/%d %3.0f%%", b.done, b.total, 100*float64(b.done)/float64(b.total)))
+	} else {
+		parts = append(parts, fmt.Sprintf("%d", b.done))
+	}
+	if rate > 0 {
+		parts = append(parts, fmt.Sprintf("%.1f %s/s", rate, b.unit))
+	}
+	switch {
+	case final:
+		parts = append(parts, "in "+round(elapsed).String())
+	case b.total > b.done && rate > 0:
+		left := time.Duration(float64(b.total-b.done) / rate * float64(time.Second))
+		parts = append(parts, "ETA "+round(left).String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// bar fills barWidth characters in proportion to done
+func bar(done, total int) string {
+	filled := barWidth * done / total
+	if filled > barWidth {
+		filled = barWidth
+	}
+	s := strings.Repeat("=", filled)
+	if filled < barWidth {
+		s += ">" + strings.Repeat(" ", barWidth-filled-1)
+	}
+	return "[" + s + "]"
+}
+
+// round shortens a duration to whole seconds, or tenths under ten seconds
+func round(d time.Duration) time.Duration {
+	if d < 10*time.Second {
+		return d.Round(100 * time.Millisecond)
+	}
+	return d.Round(time.Second)
+}
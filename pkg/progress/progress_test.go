@@ -0,0 +1,70 @@
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testClock is moved forward by the test
+type testClock struct{ now time.Time }
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func TestBarLogsEachTenth(t *testing.T) {
+	var out bytes.Buffer
+	c := &testClock{now: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)}
+	b := NewOutput(&out, false, c).Start("Details", "listings", 20)
+	for i := 0; i < 20; i++ {
+		c.now = c.now.Add(500 * time.Millisecond)
+		b.Add(1)
+	}
+	b.Done()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 12, "a line to start, one per tenth and one when done")
+	assert.Equal(t, "Details [>                       ] 0/20   0%", lines[0])
+	assert.Equal(t, "Details [==>                     ] 2/20  10% 2.0 listings/s ETA 9s", lines[1])
+	assert.Equal(t, "Details [========================] 20/20 100% 2.0 listings/s in 10s", lines[11])
+}
+
+func TestBarRedrawsInPlace(t *testing.T) {
+	var out bytes.Buffer
+	c := &testClock{now: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)}
+	b := NewOutput(&out, true, c).Start("Pages", "pages", 0)
+	c.now = c.now.Add(2 * time.Second)
+	b.Add(3)
+	b.Done()
+	assert.Equal(t, "\rPages 0\x1b[K\rPages 3 1.5 pages/s\x1b[K\rPages 3 1.5 pages/s in 2s\x1b[K\n", out.String())
+}
+
+func TestNilOutputDrawsNothing(t *testing.T) {
+	var o *Output
+	b := o.Start("Pages", "pages", 5)
+	b.Add(1)
+	b.SetTotal(4)
+	b.Done()
+	o.Printf("not printed")
+}
+
+func TestBarIsSafeForWorkers(t *testing.T) {
+	var out bytes.Buffer
+	b := NewOutput(&out, false, nil).Start("Details", "listings", 100)
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				b.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	b.Done()
+	assert.Contains(t, out.String(), "100/100 100%")
+}
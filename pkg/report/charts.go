@@ -0,0 +1,153 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	htmltemplate "html/template"
+	"math"
+	"strings"
+	"time"
+)
+
+// Chart sizes in SVG user units. The SVG scales to the page width.
+const (
+	chartWidth   = 720
+	chartHeight  = 240
+	marginLeft   = 64
+	marginRight  = 16
+	marginTop    = 16
+	marginBottom = 32
+	gridLines    = 4
+)
+
+// chartCSS styles the classes charts are drawn with
+const chartCSS = `.grid { stroke: #e5e5e5; } .label { font-size: 11px; fill: #666; }
+.line { fill: none; stroke: #2e86c1; stroke-width: 2; } .dot { fill: #2e86c1; } .bar { fill: #aab7b8; }
+`
+
+// sample is one x, y pair of a chart
+type sample struct {
+	at    time.Time
+	value float64
+}
+
+// plotArea maps samples onto the drawable part of a chart
+type plotArea struct {
+	from, to time.Time
+	min, max float64
+	// layout formats the times of samples, such as "2006" for model years
+	layout string
+}
+
+func newPlotArea(samples []sample, layout string, fromZero bool) plotArea {
+	a := plotArea{from: samples[0].at, to: samples[len(samples)-1].at, min: math.Inf(1), max: math.Inf(-1), layout: layout}
+	for _, s := range samples {
+		a.min = math.Min(a.min, s.value)
+		a.max = math.Max(a.max, s.value)
+	}
+	if fromZero {
+		a.min = 0
+	}
+	// leave headroom, and give flat series a visible range
+	pad := (a.max - a.min) * 0.1
+	if pad == 0 {
+		pad = math.Max(math.Abs(a.max)*0.1, 1)
+	}
+	if !fromZero {
+		a.min -= pad
+	}
+	a.max += pad
+	return a
+}
+
+func (a plotArea) x(t time.Time) float64 {
+	width := float64(chartWidth - marginLeft - marginRight)
+	span := a.to.Sub(a.from)
+	if span <= 0 {
+		return marginLeft + width/2
+	}
+	return marginLeft + width*float64(t.Sub(a.from))/float64(span)
+}
+
+func (a plotArea) y(v float64) float64 {
+	height := float64(chartHeight - marginTop - marginBottom)
+	return marginTop + height*(1-(v-a.min)/(a.max-a.min))
+}
+
+// axes draws horizontal grid lines labelled with format, and the dates of
+// the first and last samples under the x axis
+func (a plotArea) axes(b *strings.Builder, samples []sample, format func(float64) string) {
+	for i := 0; i <= gridLines; i++ {
+		v := a.min + (a.max-a.min)*float64(i)/gridLines
+		y := a.y(v)
+		fmt.Fprintf(b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" class="grid"/>`, marginLeft, y, chartWidth-marginRight, y)
+		fmt.Fprintf(b, `<text x="%d" y="%.1f" class="label" text-anchor="end">%s</text>`, marginLeft-6, y+4, html.EscapeString(format(v)))
+	}
+	bottom := chartHeight - marginBottom + 18
+	first, last := samples[0].at, samples[len(samples)-1].at
+	fmt.Fprintf(b, `<text x="%d" y="%d" class="label">%s</text>`, marginLeft, bottom, first.Format(a.layout))
+	if last.After(first) {
+		fmt.Fprintf(b, `<text x="%d" y="%d" class="label" text-anchor="end">%s</text>`, chartWidth-marginRight, bottom, last.Format(a.layout))
+	}
+}
+
+// lineChart draws samples as a line with a dot on each sample
+func lineChart(title string, samples []sample, layout string, format func(float64) string) htmltemplate.HTML {
+	if len(samples) == 0 {
+		return ""
+	}
+	a := newPlotArea(samples, layout, false)
+
+	var b strings.Builder
+	openChart(&b, title)
+	a.axes(&b, samples, format)
+	points := make([]string, len(samples))
+	for i, s := range samples {
+		points[i] = fmt.Sprintf("%.1f,%.1f", a.x(s.at), a.y(s.value))
+	}
+	fmt.Fprintf(&b, `<polyline points="%s" class="line"/>`, strings.Join(points, " "))
+	for _, s := range samples {
+		fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" class="dot"><title>%s: %s</title></circle>`,
+			a.x(s.at), a.y(s.value), s.at.Format(layout), html.EscapeString(format(s.value)))
+	}
+	b.WriteString("</svg>")
+	return htmltemplate.HTML(b.String())
+}
+
+// barChart draws samples as bars rising from zero
+func barChart(title string, samples []sample, layout string, format func(float64) string) htmltemplate.HTML {
+	if len(samples) == 0 {
+		return ""
+	}
+	a := newPlotArea(samples, layout, true)
+	barWidth := math.Max(float64(chartWidth-marginLeft-marginRight)/float64(len(samples))*0.7, 1)
+	// widen the time range by half a bar each side so the end bars fit
+	if n := len(samples); n > 1 {
+		half := a.to.Sub(a.from) / time.Duration(2*(n-1))
+		a.from, a.to = a.from.Add(-half), a.to.Add(half)
+	}
+
+	var b strings.Builder
+	openChart(&b, title)
+	a.axes(&b, samples, format)
+	for _, s := range samples {
+		top := a.y(s.value)
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" class="bar"><title>%s: %s</title></rect>`,
+			a.x(s.at)-barWidth/2, top, barWidth, a.y(0)-top, s.at.Format(layout), html.EscapeString(format(s.value)))
+	}
+	b.WriteString("</svg>")
+	return htmltemplate.HTML(b.String())
+}
+
+func openChart(b *strings.Builder, title string) {
+	fmt.Fprintf(b, `<svg viewBox="0 0 %d %d" role="img" aria-label="%s" xmlns="http://www.w3.org/2000/svg">`,
+		chartWidth, chartHeight, html.EscapeString(title))
+}
+
+func dollars(v float64) string {
+	return fmt.Sprintf("$%.0f", v)
+}
+
+func count(v float64) string {
+	return fmt.Sprintf("%.0f", v)
+}
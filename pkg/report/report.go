@@ -0,0 +1,125 @@
+// Package report renders price charts as static HTML that can be opened or
+// hosted without the database or a server: a report of the price indexes and
+// a price guide site with a page per manufacturer and model
+package report
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"time"
+
+	"pinkbike-scraper/pkg/priceindex"
+)
+
+// Report is a page with a price trend and a listing volume chart for each of
+// its series, each a price index's weekly points oldest first
+type Report struct {
+	Title       string
+	GeneratedAt time.Time
+	Series      [][]priceindex.Point
+}
+
+// section is one series as the template shows it
+type section struct {
+	Name   string
+	Latest priceindex.Point
+	// Recent is the change over about the last four weeks and Total since
+	// the series began, as fractions; HasRecent is false for shorter series
+	Recent, Total float64
+	HasRecent     bool
+	Price, Volume htmltemplate.HTML
+}
+
+func newSection(points []priceindex.Point) section {
+	latest := points[len(points)-1]
+	s := section{Name: latest.Name, Latest: latest}
+	if first := points[0]; first.Median > 0 {
+		s.Total = latest.Median/first.Median - 1
+	}
+	// weeks without enough listings have no point, so compare with the last
+	// point at least four but under eight weeks old
+	monthAgo := latest.Week.AddDate(0, 0, -28)
+	for _, p := range points {
+		if !p.Week.After(monthAgo) && p.Week.After(monthAgo.AddDate(0, 0, -28)) && p.Median > 0 {
+			s.Recent, s.HasRecent = latest.Median/p.Median-1, true
+		}
+	}
+
+	prices := make([]sample, len(points))
+	volume := make([]sample, len(points))
+	for i, p := range points {
+		prices[i] = sample{at: p.Week, value: p.Median}
+		volume[i] = sample{at: p.Week, value: float64(p.Listings)}
+	}
+	s.Price = lineChart(s.Name+" median price", prices, "2006-01-02", dollars)
+	s.Volume = barChart(s.Name+" listings on sale", volume, "2006-01-02", count)
+	return s
+}
+
+// Write renders the report as HTML. Series without points are left out.
+func Write(w io.Writer, r Report) error {
+	data := struct {
+		Title       string
+		GeneratedAt time.Time
+		Sections    []section
+	}{Title: r.Title, GeneratedAt: r.GeneratedAt}
+	for _, points := range r.Series {
+		if len(points) > 0 {
+			data.Sections = append(data.Sections, newSection(points))
+		}
+	}
+
+	if err := page.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to render report: %w", err)
+	}
+	return nil
+}
+
+var page = htmltemplate.Must(htmltemplate.New("report").Funcs(htmltemplate.FuncMap{
+	"change":   func(f float64) string { return fmt.Sprintf("%+.1f%%", f*100) },
+	"money":    dollars,
+	"chartCSS": func() htmltemplate.CSS { return chartCSS },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem auto; max-width: 760px; padding: 0 1rem; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2rem; }
+th, td { padding: 0.3rem 0.5rem; border-bottom: 1px solid #ddd; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+.up { color: #b03a2e; } .down { color: #1e8449; }
+section { margin-bottom: 2.5rem; }
+svg { width: 100%; height: auto; }
+{{chartCSS}}footer { color: #888; font-size: 0.85rem; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{if .Sections}}
+<table>
+<tr><th>Index</th><th>Week</th><th>Median</th><th>Listings</th><th>4 weeks</th><th>Since start</th></tr>
+{{range .Sections}}<tr>
+<td><a href="#{{.Name}}">{{.Name}}</a></td>
+<td>{{.Latest.Week.Format "2006-01-02"}}</td>
+<td>{{money .Latest.Median}}</td>
+<td>{{.Latest.Listings}}</td>
+<td>{{if .HasRecent}}<span class="{{if gt .Recent 0.0}}up{{else if lt .Recent 0.0}}down{{end}}">{{change .Recent}}</span>{{else}}-{{end}}</td>
+<td><span class="{{if gt .Total 0.0}}up{{else if lt .Total 0.0}}down{{end}}">{{change .Total}}</span></td>
+</tr>
+{{end}}</table>
+{{range .Sections}}<section id="{{.Name}}">
+<h2>{{.Name}}</h2>
+<h3>Median asking price</h3>
+{{.Price}}
+<h3>Listings on sale</h3>
+{{.Volume}}
+</section>
+{{end}}{{else}}<p>No price index data yet.</p>
+{{end}}<footer>Generated {{.GeneratedAt.Format "2006-01-02 15:04"}} from weekly median asking prices of Pinkbike listings.</footer>
+</body>
+</html>
+`))
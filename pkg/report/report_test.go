@@ -0,0 +1,80 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pinkbike-scraper/pkg/priceindex"
+)
+
+func weeks(name string, medians ...float64) []priceindex.Point {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := make([]priceindex.Point, len(medians))
+	for i, m := range medians {
+		points[i] = priceindex.Point{Name: name, Week: start.AddDate(0, 0, 7*i), Median: m, Listings: 10 + i}
+	}
+	return points
+}
+
+func TestWriteChartsEachSeries(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, Report{
+		Title:       "Prices",
+		GeneratedAt: time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC),
+		Series: [][]priceindex.Point{
+			weeks(priceindex.Overall, 4000, 4100, 4200, 4300, 4400),
+			weeks("Santa Cruz Megatower", 5000, 4500),
+		},
+	})
+	require.NoError(t, err)
+	out := buf.String()
+
+	assert.Equal(t, 4, strings.Count(out, "<svg"), "a price and a volume chart per series")
+	assert.Equal(t, 7, strings.Count(out, `class="bar"`))
+	assert.Contains(t, out, `<polyline points="`)
+	assert.Contains(t, out, "<td>$4400</td>")
+	assert.Contains(t, out, "&#43;10.0%", "change since the first week of the overall index")
+	assert.Contains(t, out, "-10.0%", "change since the first week of the model index")
+	assert.Contains(t, out, "Generated 2024-03-01 08:00")
+}
+
+func TestWriteRecentChangeNeedsFourWeeks(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, Report{Series: [][]priceindex.Point{weeks("Short", 100, 110)}}))
+	assert.Contains(t, buf.String(), "<td>-</td>")
+}
+
+func TestWriteEscapesNames(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, Report{Series: [][]priceindex.Point{weeks("<script>alert(1)</script>", 100)}}))
+	assert.NotContains(t, buf.String(), "<script>")
+	assert.Contains(t, buf.String(), "&lt;script&gt;")
+}
+
+func TestWriteWithoutData(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, Report{Title: "Prices", Series: [][]priceindex.Point{nil}}))
+	assert.Contains(t, buf.String(), "No price index data yet")
+	assert.NotContains(t, buf.String(), "<svg")
+}
+
+func TestSingleSampleChartsStayInBounds(t *testing.T) {
+	chart := string(lineChart("one", []sample{{at: time.Now(), value: 0}}, "2006-01-02", count))
+	assert.NotContains(t, chart, "NaN")
+	assert.NotContains(t, chart, "Inf")
+}
+
+func TestWriteRecentChangeSkipsMissingWeeks(t *testing.T) {
+	points := weeks("Gappy", 100, 200, 300, 400, 500, 600, 700, 800, 900, 1000)
+	points = append(points[:5], points[6:]...)
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, Report{Series: [][]priceindex.Point{points}}))
+	// the week four weeks before the last is missing, so the one before it is used
+	assert.Contains(t, buf.String(), "&#43;100.0%")
+}
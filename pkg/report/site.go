@@ -0,0 +1,357 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/privacy"
+)
+
+// minYearListings is how many listings of a model year its depreciation curve
+// needs before the year's median is plotted
+const minYearListings = 3
+
+// SiteOptions control what the price guide site publishes
+type SiteOptions struct {
+	Title string
+	// MinListings is how many listings a model needs for a page of its own
+	MinListings int
+	// Recent is how many of a model's latest listings its page shows
+	Recent int
+	// Privacy protects the published counts and medians. Pages leave out
+	// individual listings when it is enabled, as their prices would give the
+	// protected medians away.
+	Privacy privacy.Options
+}
+
+// DefaultSiteOptions gives models with 5 listings a page showing their 20
+// latest listings, and publishes exact counts and medians
+func DefaultSiteOptions() SiteOptions {
+	return SiteOptions{Title: "Used bike price guide", MinListings: 5, Recent: 20}
+}
+
+type guideManufacturer struct {
+	Name, Slug string
+	Listings   int
+	Median     float64
+	Models     []*guideModel
+}
+
+type guideModel struct {
+	Name, Slug string
+	Listings   int
+	Median     float64
+	// Active and ActiveMedian cover the listings still on sale; ActiveMedian
+	// is zero when too few are to publish it
+	Active       int
+	ActiveMedian float64
+	Years        []guideYear
+	Depreciation htmltemplate.HTML
+	Recent       []listing.Listing
+}
+
+// guideYear is the median asking price of a model's listings of one year
+type guideYear struct {
+	Year     string
+	Listings int
+	Median   float64
+}
+
+// sitePage is what every page template is executed with. Root leads from the
+// page back to the top of the site.
+type sitePage struct {
+	Root, Title   string
+	GeneratedAt   time.Time
+	Manufacturers []*guideManufacturer
+	Manufacturer  *guideManufacturer
+	Model         *guideModel
+}
+
+// WriteSite writes a static price guide to dir: an index of manufacturers, a
+// page per manufacturer listing its models, and a page per model with its
+// median price, a depreciation curve of median price by model year and its
+// latest listings. Listings needing review or without a price are left out.
+// Files already in dir are overwritten but never removed. It returns the
+// number of pages written.
+func WriteSite(dir string, listings []listing.Listing, now time.Time, opts SiteOptions) (int, error) {
+	manufacturers := guide(listings, opts)
+	home := sitePage{Title: opts.Title, GeneratedAt: now, Manufacturers: manufacturers}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create site directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "style.css"), []byte(siteCSS+chartCSS), 0644); err != nil {
+		return 0, fmt.Errorf("failed to write stylesheet: %w", err)
+	}
+	if err := writePage(filepath.Join(dir, "index.html"), "home", home); err != nil {
+		return 0, err
+	}
+	pages := 1
+
+	for _, m := range manufacturers {
+		if err := os.MkdirAll(filepath.Join(dir, m.Slug), 0755); err != nil {
+			return pages, fmt.Errorf("failed to create site directory: %w", err)
+		}
+		page := sitePage{Root: "../", Title: m.Name, GeneratedAt: now, Manufacturer: m}
+		if err := writePage(filepath.Join(dir, m.Slug, "index.html"), "manufacturer", page); err != nil {
+			return pages, err
+		}
+		pages++
+
+		for _, model := range m.Models {
+			page.Title, page.Model = m.Name+" "+model.Name, model
+			if err := writePage(filepath.Join(dir, m.Slug, model.Slug+".html"), "model", page); err != nil {
+				return pages, err
+			}
+			pages++
+		}
+	}
+	return pages, nil
+}
+
+func writePage(path, name string, page sitePage) error {
+	var buf bytes.Buffer
+	if err := siteTemplates.ExecuteTemplate(&buf, name, page); err != nil {
+		return fmt.Errorf("failed to render %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// guide groups listings by manufacturer and model, ignoring case, and
+// summarizes each group. Manufacturers are ordered by name and their models by
+// how many listings they have.
+func guide(listings []listing.Listing, opts SiteOptions) []*guideManufacturer {
+	type priced struct {
+		listing.Listing
+		price float64
+	}
+	byManufacturer := map[string][]priced{}
+	for _, l := range listings {
+		price, err := strconv.ParseFloat(l.Price, 64)
+		if err != nil || price <= 0 || l.Manufacturer == "" || len(l.NeedsReview) > 0 {
+			continue
+		}
+		key := strings.ToLower(l.Manufacturer)
+		byManufacturer[key] = append(byManufacturer[key], priced{l, price})
+	}
+
+	p := opts.Privacy
+	var manufacturers []*guideManufacturer
+	for _, group := range byManufacturer {
+		count, ok := p.Count(len(group))
+		m := &guideManufacturer{Name: group[0].Manufacturer, Slug: slug(group[0].Manufacturer), Listings: count}
+		if !ok || m.Slug == "" {
+			continue
+		}
+
+		var prices []float64
+		byModel := map[string][]priced{}
+		for _, l := range group {
+			prices = append(prices, l.price)
+			if l.Model != "" {
+				byModel[strings.ToLower(l.Model)] = append(byModel[strings.ToLower(l.Model)], l)
+			}
+		}
+		m.Median = p.Median(prices)
+
+		for _, models := range byModel {
+			if len(models) < opts.MinListings {
+				continue
+			}
+			count, ok := p.Count(len(models))
+			model := &guideModel{Name: models[0].Model, Slug: slug(models[0].Model), Listings: count}
+			if !ok || model.Slug == "" {
+				continue
+			}
+
+			var all, active []float64
+			byYear := map[string][]float64{}
+			var recent []listing.Listing
+			for _, l := range models {
+				all = append(all, l.price)
+				if l.Active {
+					active = append(active, l.price)
+				}
+				if l.Year != "" {
+					byYear[l.Year] = append(byYear[l.Year], l.price)
+				}
+				recent = append(recent, l.Listing)
+			}
+			model.Median = p.Median(all)
+			if n, ok := p.Count(len(active)); ok {
+				model.Active, model.ActiveMedian = n, p.Median(active)
+			}
+			model.Years = modelYears(byYear, &p)
+			model.Depreciation = depreciationChart(m.Name+" "+model.Name, model.Years)
+
+			if !opts.Privacy.Enabled() {
+				sort.SliceStable(recent, func(i, j int) bool { return recent[i].FirstSeen.After(recent[j].FirstSeen) })
+				if len(recent) > opts.Recent {
+					recent = recent[:opts.Recent]
+				}
+				model.Recent = recent
+			}
+			m.Models = append(m.Models, model)
+		}
+
+		sort.Slice(m.Models, func(i, j int) bool {
+			if m.Models[i].Listings != m.Models[j].Listings {
+				return m.Models[i].Listings > m.Models[j].Listings
+			}
+			return m.Models[i].Name < m.Models[j].Name
+		})
+		uniqueSlugs(m.Models)
+		manufacturers = append(manufacturers, m)
+	}
+
+	sort.Slice(manufacturers, func(i, j int) bool {
+		return strings.ToLower(manufacturers[i].Name) < strings.ToLower(manufacturers[j].Name)
+	})
+	return manufacturers
+}
+
+// modelYears returns the median price of each model year with enough
+// listings, oldest first
+func modelYears(byYear map[string][]float64, p *privacy.Options) []guideYear {
+	var years []guideYear
+	for year, prices := range byYear {
+		if len(prices) < minYearListings {
+			continue
+		}
+		if n, ok := p.Count(len(prices)); ok {
+			years = append(years, guideYear{Year: year, Listings: n, Median: p.Median(prices)})
+		}
+	}
+	sort.Slice(years, func(i, j int) bool { return years[i].Year < years[j].Year })
+	return years
+}
+
+// depreciationChart plots the median price of each model year, or nothing
+// when fewer than two years have one
+func depreciationChart(name string, years []guideYear) htmltemplate.HTML {
+	var samples []sample
+	for _, y := range years {
+		year, err := strconv.Atoi(y.Year)
+		if err != nil {
+			continue
+		}
+		samples = append(samples, sample{at: time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC), value: y.Median})
+	}
+	if len(samples) < 2 {
+		return ""
+	}
+	return lineChart(name+" median price by model year", samples, "2006", dollars)
+}
+
+// slug turns a name into a file name of lower case letters, digits and dashes
+func slug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// uniqueSlugs numbers models whose names slug the same, such as "Process 153"
+// and "Process-153", so their pages do not overwrite each other or the
+// manufacturer's index page
+func uniqueSlugs(models []*guideModel) {
+	seen := map[string]int{"index": 1}
+	for _, m := range models {
+		seen[m.Slug]++
+		if n := seen[m.Slug]; n > 1 {
+			m.Slug = fmt.Sprintf("%s-%d", m.Slug, n)
+		}
+	}
+}
+
+const siteCSS = `body { font-family: system-ui, sans-serif; margin: 2rem auto; max-width: 860px; padding: 0 1rem; color: #222; }
+nav { margin-bottom: 1rem; font-size: 0.9rem; }
+a { color: #1f618d; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2rem; }
+th, td { padding: 0.3rem 0.5rem; border-bottom: 1px solid #ddd; text-align: right; }
+th:first-child, td:first-child, td.text { text-align: left; }
+.gone { color: #888; }
+svg { width: 100%; height: auto; }
+footer { color: #888; font-size: 0.85rem; }
+`
+
+var siteTemplates = htmltemplate.Must(htmltemplate.New("site").Funcs(htmltemplate.FuncMap{
+	"money": dollars,
+	"price": func(s string) string {
+		if price, err := strconv.ParseFloat(s, 64); err == nil {
+			return dollars(price)
+		}
+		return s
+	},
+}).Parse(`{{define "header"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="{{.Root}}style.css">
+</head>
+<body>
+{{end}}
+
+{{define "footer"}}<footer>Generated {{.GeneratedAt.Format "2006-01-02"}} from asking prices of Pinkbike buy/sell listings, in USD. Asking prices are not sale prices.</footer>
+</body>
+</html>
+{{end}}
+
+{{define "home"}}{{template "header" .}}<h1>{{.Title}}</h1>
+{{if .Manufacturers}}<table>
+<tr><th>Manufacturer</th><th>Models</th><th>Listings</th><th>Median price</th></tr>
+{{range .Manufacturers}}<tr><td><a href="{{.Slug}}/index.html">{{.Name}}</a></td><td>{{len .Models}}</td><td>{{.Listings}}</td><td>{{money .Median}}</td></tr>
+{{end}}</table>
+{{else}}<p>No listings to publish yet.</p>
+{{end}}{{template "footer" .}}{{end}}
+
+{{define "manufacturer"}}{{template "header" .}}<nav><a href="{{.Root}}index.html">All manufacturers</a></nav>
+<h1>{{.Manufacturer.Name}}</h1>
+<p>{{.Manufacturer.Listings}} listings with a median asking price of {{money .Manufacturer.Median}}.</p>
+{{if .Manufacturer.Models}}<table>
+<tr><th>Model</th><th>Listings</th><th>Median price</th><th>On sale</th><th>Median on sale</th></tr>
+{{range .Manufacturer.Models}}<tr><td><a href="{{.Slug}}.html">{{.Name}}</a></td><td>{{.Listings}}</td><td>{{money .Median}}</td><td>{{.Active}}</td><td>{{if .ActiveMedian}}{{money .ActiveMedian}}{{else}}-{{end}}</td></tr>
+{{end}}</table>
+{{else}}<p>No model has enough listings for a page yet.</p>
+{{end}}{{template "footer" .}}{{end}}
+
+{{define "model"}}{{template "header" .}}<nav><a href="{{.Root}}index.html">All manufacturers</a> / <a href="index.html">{{.Manufacturer.Name}}</a></nav>
+<h1>{{.Title}}</h1>
+{{with .Model}}<p>{{.Listings}} listings with a median asking price of {{money .Median}}{{if .ActiveMedian}}, {{.Active}} on sale now at a median of {{money .ActiveMedian}}{{end}}.</p>
+<h2>Price by model year</h2>
+{{.Depreciation}}
+{{if .Years}}<table>
+<tr><th>Year</th><th>Listings</th><th>Median price</th></tr>
+{{range .Years}}<tr><td>{{.Year}}</td><td>{{.Listings}}</td><td>{{money .Median}}</td></tr>
+{{end}}</table>
+{{else}}<p>Not enough listings give their model year yet.</p>
+{{end}}{{if .Recent}}<h2>Latest listings</h2>
+<table>
+<tr><th>Listed</th><th>Title</th><th>Year</th><th>Size</th><th>Condition</th><th>Price</th></tr>
+{{range .Recent}}<tr{{if not .Active}} class="gone"{{end}}><td>{{.FirstSeen.Format "2006-01-02"}}</td><td class="text"><a href="{{.URL}}">{{.Title}}</a>{{if not .Active}} (gone){{end}}</td><td>{{.Year}}</td><td>{{if .NormalizedSize}}{{.NormalizedSize}}{{else}}{{.FrameSize}}{{end}}</td><td class="text">{{.Condition}}</td><td>{{price .Price}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}{{template "footer" .}}{{end}}
+`))
@@ -0,0 +1,92 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/privacy"
+)
+
+func siteListings() []listing.Listing {
+	var listings []listing.Listing
+	seen := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	add := func(model, year string, price int, active bool) {
+		listings = append(listings, listing.Listing{
+			Title: year + " Santa Cruz " + model, Manufacturer: "Santa Cruz", Model: model, Year: year,
+			Price: strconv.Itoa(price), URL: "https://www.pinkbike.com/buysell/" + strconv.Itoa(len(listings)) + "/",
+			Active: active, FirstSeen: seen.AddDate(0, 0, len(listings)),
+		})
+	}
+	for i := 0; i < 3; i++ {
+		add("Megatower", "2020", 3000+100*i, true)
+		add("Megatower", "2022", 4500+100*i, i > 0)
+	}
+	add("Nomad", "2021", 2500, true)
+	listings = append(listings, listing.Listing{Title: "Wanted: Megatower", Manufacturer: "Santa Cruz", Model: "Megatower", Price: "1", NeedsReview: listing.Reasons{"price"}})
+	return listings
+}
+
+func TestWriteSite(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultSiteOptions()
+	opts.Recent = 5
+	pages, err := WriteSite(dir, siteListings(), time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), opts)
+	require.NoError(t, err)
+	assert.Equal(t, 3, pages, "the front page, Santa Cruz and the Megatower; the Nomad has too few listings")
+
+	home, err := os.ReadFile(filepath.Join(dir, "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(home), `<a href="santa-cruz/index.html">Santa Cruz</a>`)
+	assert.FileExists(t, filepath.Join(dir, "style.css"))
+
+	manufacturer, err := os.ReadFile(filepath.Join(dir, "santa-cruz", "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(manufacturer), `<a href="megatower.html">Megatower</a>`)
+	assert.Contains(t, string(manufacturer), "7 listings")
+	assert.NotContains(t, string(manufacturer), "Nomad")
+
+	model, err := os.ReadFile(filepath.Join(dir, "santa-cruz", "megatower.html"))
+	require.NoError(t, err)
+	page := string(model)
+	assert.Contains(t, page, `href="../style.css"`)
+	assert.Contains(t, page, "6 listings with a median asking price of $3850, 5 on sale now")
+	assert.Contains(t, page, "<tr><td>2020</td><td>3</td><td>$3100</td></tr>")
+	assert.Contains(t, page, "<tr><td>2022</td><td>3</td><td>$4600</td></tr>")
+	assert.Contains(t, page, "<svg", "two model years draw a depreciation curve")
+	assert.Contains(t, page, "2024-05-06", "the latest listing is shown")
+	assert.NotContains(t, page, "2024-05-01", "only the 5 latest listings are shown")
+	assert.Contains(t, page, "(gone)", "listings no longer on sale are marked")
+	assert.NotContains(t, page, "Wanted")
+}
+
+func TestWriteSiteWithPrivacyLeavesOutListings(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultSiteOptions()
+	opts.Privacy = privacy.Options{MinCount: 4}
+	_, err := WriteSite(dir, siteListings(), time.Now(), opts)
+	require.NoError(t, err)
+
+	model, err := os.ReadFile(filepath.Join(dir, "santa-cruz", "megatower.html"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(model), "Latest listings")
+	assert.NotContains(t, string(model), "<tr><td>2020</td>", "model years under the minimum count are suppressed")
+}
+
+func TestSlug(t *testing.T) {
+	assert.Equal(t, "santa-cruz", slug("Santa Cruz"))
+	assert.Equal(t, "sb5-5", slug(" SB5.5 "))
+	assert.Equal(t, "", slug("***"))
+
+	models := []*guideModel{{Slug: "process-153"}, {Slug: "process-153"}, {Slug: "index"}}
+	uniqueSlugs(models)
+	assert.Equal(t, "process-153", models[0].Slug)
+	assert.Equal(t, "process-153-2", models[1].Slug)
+	assert.Equal(t, "index-2", models[2].Slug)
+}
@@ -0,0 +1,128 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// ErrBlocked matches errors caused by Pinkbike's bot protection, so a run can
+// tell a block apart from a broken page
+var ErrBlocked = errors.New("blocked by bot protection")
+
+// BlockedError is returned when navigation lands on a Cloudflare challenge,
+// a CAPTCHA or a rate limit instead of the page. Scraping stops there, since
+// everything read from the interstitial would be garbage.
+type BlockedError struct {
+	URL    string
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("blocked by bot protection at %s: %s", e.URL, e.Reason)
+}
+
+// Is makes errors.Is(err, ErrBlocked) match
+func (e *BlockedError) Is(target error) bool {
+	return target == ErrBlocked
+}
+
+// challengeWait is how long an interstitial gets to clear by itself, as
+// Cloudflare's JavaScript challenges do when the browser passes them
+const challengeWait = 15 * time.Second
+
+// challengeTitles are page titles of interstitials, lower case
+var challengeTitles = []string{
+	"just a moment", "attention required", "access denied", "checking your browser",
+	"please verify you are a human", "are you a robot", "security check",
+}
+
+// interstitialMarkup only appears on interstitials, never on a page that
+// merely embeds a CAPTCHA widget
+var interstitialMarkup = []string{
+	"cdn-cgi/challenge-platform", "cf_chl_opt", "cf-challenge-running", "captcha-delivery.com", "px-captcha",
+}
+
+// captchaMarkup is CAPTCHA or challenge markup that marks a block when the
+// page was also refused
+var captchaMarkup = []string{
+	"challenges.cloudflare.com", "g-recaptcha", "recaptcha/api", "h-captcha", "hcaptcha.com", "turnstile",
+}
+
+// detectBlock returns why a response looks like an interstitial instead of the
+// requested page, or "" when it looks like the page. headers are lower case.
+func detectBlock(status int, headers map[string]string, title, html string) string {
+	if status == 429 {
+		return "rate limited (HTTP 429)"
+	}
+	if headers["cf-mitigated"] == "challenge" {
+		return fmt.Sprintf("Cloudflare challenge (HTTP %d)", status)
+	}
+
+	title = strings.ToLower(strings.TrimSpace(title))
+	for _, t := range challengeTitles {
+		if strings.HasPrefix(title, t) {
+			return fmt.Sprintf("interstitial page %q (HTTP %d)", title, status)
+		}
+	}
+
+	html = strings.ToLower(html)
+	for _, m := range interstitialMarkup {
+		if strings.Contains(html, m) {
+			return fmt.Sprintf("challenge page with %s (HTTP %d)", m, status)
+		}
+	}
+	if status == 403 || status == 503 {
+		for _, m := range captchaMarkup {
+			if strings.Contains(html, m) {
+				return fmt.Sprintf("CAPTCHA with %s (HTTP %d)", m, status)
+			}
+		}
+	}
+	return ""
+}
+
+// visit navigates page to url, returning a *BlockedError when it lands on an
+// interstitial that does not clear within challengeWait. A rate limit is not
+// waited out; the run cools down instead.
+func visit(page playwright.Page, url string) (playwright.Response, error) {
+	resp, err := page.Goto(url)
+	if err != nil {
+		return nil, fmt.Errorf("could not goto: %v", err)
+	}
+	if resp == nil {
+		return nil, nil
+	}
+
+	reason := pageBlock(page, resp.Status(), resp.Headers())
+	if reason == "" {
+		return resp, nil
+	}
+	if resp.Status() != 429 {
+		for deadline := time.Now().Add(challengeWait); time.Now().Before(deadline); {
+			time.Sleep(time.Second)
+			// a passed challenge reloads the page, which answers with 200
+			if pageBlock(page, 200, nil) == "" {
+				return resp, nil
+			}
+		}
+	}
+	return nil, &BlockedError{URL: url, Reason: reason}
+}
+
+// pageBlock reads the title and markup of page for detectBlock. A page that
+// cannot be read, such as one navigating away, is not reported as blocked.
+func pageBlock(page playwright.Page, status int, headers map[string]string) string {
+	title, err := page.Title()
+	if err != nil {
+		return detectBlock(status, headers, "", "")
+	}
+	html, err := page.Content()
+	if err != nil {
+		return detectBlock(status, headers, title, "")
+	}
+	return detectBlock(status, headers, title, html)
+}
@@ -0,0 +1,40 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectBlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		headers map[string]string
+		title   string
+		html    string
+		blocked bool
+	}{
+		{"listing page", 200, nil, "2022 Evil Offering - Pinkbike", listingsPageHTML, false},
+		{"detail page", 200, nil, "2022 Evil Offering - Pinkbike", detailsPageHTML, false},
+		{"rate limit", 429, nil, "", "Too Many Requests", true},
+		{"cloudflare header", 403, map[string]string{"cf-mitigated": "challenge"}, "", "", true},
+		{"cloudflare title", 503, nil, "Just a moment...", "", true},
+		{"challenge platform", 200, nil, "Pinkbike", `<script src="/cdn-cgi/challenge-platform/h/g/orchestrate/chl_page/v1"></script>`, true},
+		{"refused captcha", 403, nil, "Pinkbike", `<div class="g-recaptcha" data-sitekey="x"></div>`, true},
+		{"embedded captcha", 200, nil, "Log In - Pinkbike", `<div class="g-recaptcha" data-sitekey="x"></div>`, false},
+		{"not found", 404, nil, "Page Not Found - Pinkbike", "<p>This listing has been removed</p>", false},
+	}
+	for _, tt := range tests {
+		reason := detectBlock(tt.status, tt.headers, tt.title, tt.html)
+		assert.Equal(t, tt.blocked, reason != "", "%s: %q", tt.name, reason)
+	}
+}
+
+func TestBlockedError(t *testing.T) {
+	err := fmt.Errorf("could not create scraper: %w", &BlockedError{URL: "https://www.pinkbike.com/buysell/list/", Reason: "rate limited (HTTP 429)"})
+	assert.True(t, errors.Is(err, ErrBlocked))
+	assert.False(t, errors.Is(errors.New("could not goto: timeout"), ErrBlocked))
+}
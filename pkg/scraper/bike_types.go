@@ -0,0 +1,148 @@
+package scraper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"pinkbike-scraper/pkg/listing"
+)
+
+// biketype enum
+type BikeType string
+
+var (
+	Enduro BikeType = "enduro"
+	Trail  BikeType = "trail"
+	XC     BikeType = "xc"
+	DH     BikeType = "dh"
+	// Gravel and DirtJump are outside the mountain bike categories and are
+	// validated with their own profiles
+	Gravel   BikeType = "gravel"
+	DirtJump BikeType = "dirtjump"
+	// EBike listings are all marked electric, whatever their title says
+	EBike BikeType = "ebike"
+)
+
+// TravelRange is an inclusive suspension travel range in millimetres
+type TravelRange struct {
+	Min, Max int
+}
+
+// Contains reports whether travel falls inside the range
+func (r TravelRange) Contains(travel int) bool {
+	return travel >= r.Min && travel <= r.Max
+}
+
+// BikeTypeInfo describes how a bike type maps onto Pinkbike and the exporters
+type BikeTypeInfo struct {
+	Type        BikeType
+	DisplayName string
+	// CategoryID is the Pinkbike buysell category for this type
+	CategoryID  int
+	FrontTravel TravelRange
+	RearTravel  TravelRange
+	// SheetName is the Google Sheets tab listings of this type are written to
+	SheetName string
+	// Validation decides which missing fields flag a listing for review
+	Validation listing.ValidationProfile
+	// Electric categories only list e-bikes
+	Electric bool
+}
+
+// bikeTypes is the single place bike types are defined; adding a category only
+// requires a new entry here
+var bikeTypes = map[BikeType]BikeTypeInfo{
+	Enduro: {
+		Type:        Enduro,
+		DisplayName: "Enduro",
+		CategoryID:  2,
+		FrontTravel: TravelRange{Min: 150, Max: 190},
+		RearTravel:  TravelRange{Min: 150, Max: 180},
+		SheetName:   "Enduro",
+		Validation:  listing.DefaultProfile,
+	},
+	Trail: {
+		Type:        Trail,
+		DisplayName: "Trail",
+		CategoryID:  102,
+		FrontTravel: TravelRange{Min: 120, Max: 160},
+		RearTravel:  TravelRange{Min: 110, Max: 150},
+		SheetName:   "Trail",
+		Validation:  listing.DefaultProfile,
+	},
+	XC: {
+		Type:        XC,
+		DisplayName: "Cross Country",
+		CategoryID:  75,
+		FrontTravel: TravelRange{Min: 80, Max: 130},
+		RearTravel:  TravelRange{Min: 0, Max: 125},
+		SheetName:   "XC",
+		Validation:  listing.DefaultProfile,
+	},
+	DH: {
+		Type:        DH,
+		DisplayName: "Downhill",
+		CategoryID:  1,
+		FrontTravel: TravelRange{Min: 180, Max: 210},
+		RearTravel:  TravelRange{Min: 180, Max: 230},
+		SheetName:   "DH",
+		Validation:  listing.DefaultProfile,
+	},
+	// the gravel and dirt jump category IDs have not been confirmed against
+	// the buysell category menu yet, check them there if these return other bikes
+	Gravel: {
+		Type:        Gravel,
+		DisplayName: "Gravel",
+		CategoryID:  77,
+		FrontTravel: TravelRange{Min: 0, Max: 60},
+		RearTravel:  TravelRange{Min: 0, Max: 0},
+		SheetName:   "Gravel",
+		Validation:  listing.GravelProfile,
+	},
+	DirtJump: {
+		Type:        DirtJump,
+		DisplayName: "Dirt Jump",
+		CategoryID:  3,
+		FrontTravel: TravelRange{Min: 80, Max: 140},
+		RearTravel:  TravelRange{Min: 0, Max: 130},
+		SheetName:   "Dirt Jump",
+		Validation:  listing.DirtJumpProfile,
+	},
+	// the e-bike category ID has not been confirmed against the buysell
+	// category menu either
+	EBike: {
+		Type:        EBike,
+		DisplayName: "E-Bike",
+		CategoryID:  104,
+		FrontTravel: TravelRange{Min: 120, Max: 190},
+		RearTravel:  TravelRange{Min: 0, Max: 180},
+		SheetName:   "E-Bike",
+		Validation:  listing.DefaultProfile,
+		Electric:    true,
+	},
+}
+
+// LookupBikeType returns the registry entry for a bike type name
+func LookupBikeType(name string) (BikeTypeInfo, error) {
+	info, ok := bikeTypes[BikeType(strings.ToLower(strings.TrimSpace(name)))]
+	if !ok {
+		return BikeTypeInfo{}, fmt.Errorf("invalid bike type: %s (valid types: %s)", name, strings.Join(BikeTypeNames(), ", "))
+	}
+	return info, nil
+}
+
+// BikeTypeNames returns the registered bike type names in sorted order
+func BikeTypeNames() []string {
+	names := make([]string, 0, len(bikeTypes))
+	for t := range bikeTypes {
+		names = append(names, string(t))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ListingsURL returns the first page of listings for this bike type
+func (b BikeTypeInfo) ListingsURL(urlBase string) string {
+	return fmt.Sprintf("%s/?category=%d", urlBase, b.CategoryID)
+}
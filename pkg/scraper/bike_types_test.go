@@ -0,0 +1,22 @@
+package scraper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupBikeType(t *testing.T) {
+	info, err := LookupBikeType(" Trail ")
+	require.NoError(t, err)
+	assert.Equal(t, Trail, info.Type)
+	assert.Equal(t, "https://www.pinkbike.com/buysell/list//?category=102", info.ListingsURL("https://www.pinkbike.com/buysell/list/"))
+
+	_, err = LookupBikeType("road")
+	assert.Error(t, err)
+}
+
+func TestBikeTypeNames(t *testing.T) {
+	assert.Equal(t, []string{"dh", "dirtjump", "ebike", "enduro", "gravel", "trail", "xc"}, BikeTypeNames())
+}
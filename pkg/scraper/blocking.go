@@ -0,0 +1,136 @@
+package scraper
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// resourceTypes are the Playwright request types that can be blocked
+var resourceTypes = map[string]bool{
+	"document": true, "stylesheet": true, "image": true, "media": true, "font": true, "script": true,
+	"texttrack": true, "xhr": true, "fetch": true, "eventsource": true, "websocket": true,
+	"manifest": true, "other": true,
+}
+
+// defaultBlockedTypes are never needed to read listings. Stylesheets are kept
+// since element text can depend on what they hide.
+var defaultBlockedTypes = []string{"image", "media", "font"}
+
+// defaultBlockedDomains serve analytics and ads on Pinkbike's pages
+var defaultBlockedDomains = []string{
+	"google-analytics.com", "googletagmanager.com", "googlesyndication.com", "googleadservices.com",
+	"doubleclick.net", "adservice.google.com", "amazon-adsystem.com", "facebook.net",
+	"scorecardresearch.com", "quantserve.com", "adnxs.com", "criteo.com", "criteo.net",
+	"taboola.com", "outbrain.com", "hotjar.com", "moatads.com", "pubmatic.com",
+	"rubiconproject.com", "casalemedia.com", "openx.net", "chartbeat.com", "nr-data.net",
+}
+
+// ResourceBlocking selects requests the browser aborts instead of loading.
+// Listing pages pull in far more than the listing table, so blocking images,
+// fonts and trackers speeds up scrapes and saves bandwidth.
+type ResourceBlocking struct {
+	// Types are Playwright resource types such as "image" or "font"
+	Types []string
+	// Domains are blocked along with their subdomains
+	Domains []string
+
+	blocked atomic.Int64
+}
+
+// DefaultResourceBlocking blocks images, media, fonts and known analytics and
+// ad domains
+func DefaultResourceBlocking() *ResourceBlocking {
+	return &ResourceBlocking{
+		Types:   append([]string(nil), defaultBlockedTypes...),
+		Domains: append([]string(nil), defaultBlockedDomains...),
+	}
+}
+
+// ParseResourceBlocking reads a -block flag value: "none" to load everything,
+// or a comma separated list of resource types, domains (anything with a dot)
+// and "default" for the built-in set, as in "default,stylesheet,example.com"
+func ParseResourceBlocking(spec string) (*ResourceBlocking, error) {
+	b := &ResourceBlocking{}
+	spec = strings.TrimSpace(strings.ToLower(spec))
+	if spec == "" || spec == "none" || spec == "off" {
+		return b, nil
+	}
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		switch {
+		case item == "":
+		case item == "default":
+			b.Types = append(b.Types, defaultBlockedTypes...)
+			b.Domains = append(b.Domains, defaultBlockedDomains...)
+		case strings.Contains(item, "."):
+			b.Domains = append(b.Domains, strings.TrimPrefix(item, "*."))
+		case resourceTypes[item]:
+			b.Types = append(b.Types, item)
+		default:
+			names := make([]string, 0, len(resourceTypes))
+			for t := range resourceTypes {
+				names = append(names, t)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown resource type %q to block (valid types: %s, or a domain)", item, strings.Join(names, ", "))
+		}
+	}
+	return b, nil
+}
+
+// Blocks reports whether a request of resourceType for rawURL is aborted
+func (b *ResourceBlocking) Blocks(resourceType, rawURL string) bool {
+	if b == nil {
+		return false
+	}
+	for _, t := range b.Types {
+		if t == resourceType {
+			return true
+		}
+	}
+	if len(b.Domains) == 0 {
+		return false
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, d := range b.Domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// Blocked returns how many requests have been aborted
+func (b *ResourceBlocking) Blocked() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.blocked.Load()
+}
+
+// install routes every request of the context through the blocking rules
+func (b *ResourceBlocking) install(ctx playwright.BrowserContext) error {
+	if b == nil || len(b.Types) == 0 && len(b.Domains) == 0 {
+		return nil
+	}
+	return ctx.Route("**/*", func(route playwright.Route) {
+		req := route.Request()
+		if b.Blocks(req.ResourceType(), req.URL()) {
+			b.blocked.Add(1)
+			// errors here mean the page went away while the request was routed
+			_ = route.Abort("blockedbyclient")
+			return
+		}
+		_ = route.Continue()
+	})
+}
@@ -0,0 +1,53 @@
+package scraper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseResourceBlocking(t *testing.T) {
+	none, err := ParseResourceBlocking("none")
+	require.NoError(t, err)
+	assert.False(t, none.Blocks("image", "https://ep1.pinkbike.org/p4pb1234/bike.jpg"))
+
+	def, err := ParseResourceBlocking("default")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultResourceBlocking().Types, def.Types)
+	assert.Equal(t, DefaultResourceBlocking().Domains, def.Domains)
+
+	custom, err := ParseResourceBlocking(" Stylesheet, *.example.com ")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"stylesheet"}, custom.Types)
+	assert.Equal(t, []string{"example.com"}, custom.Domains)
+
+	_, err = ParseResourceBlocking("default,photos")
+	assert.Error(t, err)
+}
+
+func TestResourceBlockingBlocks(t *testing.T) {
+	b := DefaultResourceBlocking()
+
+	tests := []struct {
+		resourceType string
+		url          string
+		blocked      bool
+	}{
+		{"image", "https://ep1.pinkbike.org/p4pb1234/bike.jpg", true},
+		{"font", "https://www.pinkbike.com/fonts/site.woff2", true},
+		{"document", "https://www.pinkbike.com/buysell/3960926/", false},
+		{"script", "https://www.pinkbike.com/js/buysell.js", false},
+		{"script", "https://www.google-analytics.com/analytics.js", true},
+		{"script", "https://securepubads.g.doubleclick.net/tag/js/gpt.js", true},
+		{"script", "https://notdoubleclick.net/tag.js", false},
+		{"xhr", "not a url", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.blocked, b.Blocks(tt.resourceType, tt.url), "%s %s", tt.resourceType, tt.url)
+	}
+
+	var disabled *ResourceBlocking
+	assert.False(t, disabled.Blocks("image", "https://ep1.pinkbike.org/p4pb1234/bike.jpg"))
+	assert.Zero(t, disabled.Blocked())
+}
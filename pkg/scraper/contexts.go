@@ -0,0 +1,182 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// contextPool hands out browser contexts to concurrent workers. Each worker
+// holds its own context while it runs, so cookies and local storage are never
+// shared between pages loading at the same time. With a user data directory
+// each context's cookies and local storage are saved there on close and
+// restored on the next run, so Pinkbike sees a returning visitor instead of a
+// new one that gets its interstitials again.
+type contextPool struct {
+	dir string
+	// seed is the state contexts without saved state of their own start
+	// from, such as a signed in session
+	seed       string
+	newContext func(statePath string) (playwright.BrowserContext, error)
+
+	// beforeClose, when set, runs on each used context before it is closed
+	beforeClose func(slot int, context playwright.BrowserContext) error
+
+	// free holds the slots not acquired by a worker
+	free chan int
+
+	mu       sync.Mutex
+	contexts []playwright.BrowserContext
+}
+
+// pooledContext is a context acquired from a contextPool
+type pooledContext struct {
+	playwright.BrowserContext
+	slot int
+}
+
+// newContextPool creates a pool of up to size contexts in browser, each
+// passed to setup before its first use. dir may be empty to start every run
+// with fresh contexts.
+func newContextPool(browser playwright.Browser, size int, dir, seed string, setup func(playwright.BrowserContext) error) (*contextPool, error) {
+	return newContextPoolWith(size, dir, seed, func(statePath string) (playwright.BrowserContext, error) {
+		var options playwright.BrowserNewContextOptions
+		if statePath != "" {
+			options.StorageStatePath = playwright.String(statePath)
+		}
+		context, err := browser.NewContext(options)
+		if err != nil {
+			return nil, err
+		}
+		if err := setup(context); err != nil {
+			context.Close()
+			return nil, err
+		}
+		return context, nil
+	})
+}
+
+func newContextPoolWith(size int, dir, seed string, newContext func(statePath string) (playwright.BrowserContext, error)) (*contextPool, error) {
+	if size < 1 {
+		size = 1
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("could not create user data dir: %v", err)
+		}
+	}
+
+	p := &contextPool{
+		dir:        dir,
+		seed:       seed,
+		newContext: newContext,
+		free:       make(chan int, size),
+		contexts:   make([]playwright.BrowserContext, size),
+	}
+	for slot := 0; slot < size; slot++ {
+		p.free <- slot
+	}
+	return p, nil
+}
+
+// size returns how many contexts the pool holds at most
+func (p *contextPool) size() int {
+	return cap(p.free)
+}
+
+// acquire waits for a free slot and returns its context, creating it from
+// the saved state on first use
+func (p *contextPool) acquire() (*pooledContext, error) {
+	slot := <-p.free
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.contexts[slot] == nil {
+		context, err := p.newContext(p.existingStatePath(slot))
+		if err != nil {
+			p.free <- slot
+			return nil, fmt.Errorf("could not create browser context: %w", err)
+		}
+		p.contexts[slot] = context
+	}
+	return &pooledContext{BrowserContext: p.contexts[slot], slot: slot}, nil
+}
+
+// release returns a context to the pool. Its pages are left open.
+func (p *contextPool) release(c *pooledContext) {
+	p.free <- c.slot
+}
+
+// close saves the state of every context that was used and closes them. Every
+// context is closed even when saving one fails; the first error is returned.
+func (p *contextPool) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var firstErr error
+	for slot, context := range p.contexts {
+		if context == nil {
+			continue
+		}
+		if p.beforeClose != nil {
+			if err := p.beforeClose(slot, context); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		if p.dir != "" {
+			if err := p.saveState(slot, context); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		if err := context.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("could not close browser context: %v", err)
+		}
+		p.contexts[slot] = nil
+	}
+	return firstErr
+}
+
+// statePath is where a slot's cookies and local storage are kept
+func (p *contextPool) statePath(slot int) string {
+	return filepath.Join(p.dir, fmt.Sprintf("context-%d.json", slot))
+}
+
+// existingStatePath returns the slot's saved state, falling back to the seed,
+// or "" when there is none to restore
+func (p *contextPool) existingStatePath(slot int) string {
+	if p.dir == "" {
+		return p.seed
+	}
+	path := p.statePath(slot)
+	if _, err := os.Stat(path); err != nil {
+		return p.seed
+	}
+	return path
+}
+
+// saveState writes a context's state next to its final path and renames it
+// into place, so an interrupted save keeps the previous state readable.
+// The state holds session cookies, so only the owner can read it.
+func (p *contextPool) saveState(slot int, context playwright.BrowserContext) error {
+	state, err := context.StorageState()
+	if err != nil {
+		return fmt.Errorf("could not read browser state: %v", err)
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("could not encode browser state: %v", err)
+	}
+
+	path := p.statePath(slot)
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("could not save browser state: %v", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("could not save browser state: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package scraper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/playwright-community/playwright-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeContext stands in for a browser context, returning a fixed state
+type fakeContext struct {
+	playwright.BrowserContext
+	cookie string
+	closed bool
+}
+
+func (c *fakeContext) StorageState(path ...string) (*playwright.StorageState, error) {
+	return &playwright.StorageState{Cookies: []playwright.Cookie{{Name: "session", Value: c.cookie, Domain: ".pinkbike.com", Path: "/"}}}, nil
+}
+
+func (c *fakeContext) Close(options ...playwright.BrowserContextCloseOptions) error {
+	c.closed = true
+	return nil
+}
+
+func TestContextPool(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "userdata")
+	var created []*fakeContext
+	var statePaths []string
+	newPool := func() *contextPool {
+		pool, err := newContextPoolWith(2, dir, "", func(statePath string) (playwright.BrowserContext, error) {
+			c := &fakeContext{cookie: string(rune('a' + len(created)))}
+			created = append(created, c)
+			statePaths = append(statePaths, statePath)
+			return c, nil
+		})
+		require.NoError(t, err)
+		return pool
+	}
+
+	pool := newPool()
+	assert.Equal(t, 2, pool.size())
+
+	first, err := pool.acquire()
+	require.NoError(t, err)
+	second, err := pool.acquire()
+	require.NoError(t, err)
+	assert.NotSame(t, first.BrowserContext, second.BrowserContext, "concurrent workers get contexts of their own")
+
+	pool.release(first)
+	again, err := pool.acquire()
+	require.NoError(t, err)
+	assert.Same(t, first.BrowserContext, again.BrowserContext, "a released context is reused")
+	assert.Len(t, created, 2)
+	assert.Equal(t, []string{"", ""}, statePaths, "the first run has no state to restore")
+
+	require.NoError(t, pool.close())
+	assert.True(t, created[0].closed)
+	assert.True(t, created[1].closed)
+
+	info, err := os.Stat(filepath.Join(dir, "context-0.json"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// the next run restores each slot's saved state
+	pool = newPool()
+	_, err = pool.acquire()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "context-0.json"), statePaths[2])
+}
+
+func TestContextPoolWithoutUserDataDir(t *testing.T) {
+	pool, err := newContextPoolWith(0, "", "", func(statePath string) (playwright.BrowserContext, error) {
+		assert.Empty(t, statePath)
+		return &fakeContext{}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, pool.size())
+
+	c, err := pool.acquire()
+	require.NoError(t, err)
+	pool.release(c)
+	require.NoError(t, pool.close())
+	assert.True(t, c.BrowserContext.(*fakeContext).closed)
+}
+
+func TestContextPoolSeed(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "context-1.json"), []byte(`{"cookies":[]}`), 0600))
+
+	var statePaths []string
+	pool, err := newContextPoolWith(2, dir, "session.json", func(statePath string) (playwright.BrowserContext, error) {
+		statePaths = append(statePaths, statePath)
+		return &fakeContext{}, nil
+	})
+	require.NoError(t, err)
+
+	_, err = pool.acquire()
+	require.NoError(t, err)
+	_, err = pool.acquire()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"session.json", filepath.Join(dir, "context-1.json")}, statePaths, "saved state wins over the seed")
+}
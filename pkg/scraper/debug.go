@@ -0,0 +1,176 @@
+package scraper
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// debugCapture saves what a page looked like when a selector failed on it, a
+// screenshot next to its DOM, and records a Playwright trace of every browser
+// context, so parser regressions can be diagnosed after the run. A nil
+// debugCapture saves nothing.
+type debugCapture struct {
+	dir string
+
+	mu sync.Mutex
+	// saved holds the names already captured, so a page with several failing
+	// fields is only saved once
+	saved map[string]bool
+}
+
+// newDebugCapture saves captures and traces to dir, returning nil when dir is empty
+func newDebugCapture(dir string) (*debugCapture, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create debug directory: %v", err)
+	}
+	return &debugCapture{dir: dir, saved: map[string]bool{}}, nil
+}
+
+// capturePage saves a full page screenshot and the page's HTML
+func (d *debugCapture) capturePage(page playwright.Page, pageName, listingURL string) {
+	if d == nil {
+		return
+	}
+	d.save(captureName(pageName, listingURL), func() ([]byte, error) {
+		return page.Screenshot(playwright.PageScreenshotOptions{FullPage: playwright.Bool(true)})
+	}, page.Content)
+}
+
+// captureEntry saves a screenshot and the HTML of one listing row of a
+// listings page
+func (d *debugCapture) captureEntry(entry playwright.Locator, pageName, listingURL string) {
+	if d == nil {
+		return
+	}
+	d.save(captureName(pageName, listingURL), func() ([]byte, error) {
+		return entry.Screenshot(playwright.LocatorScreenshotOptions{Timeout: playwright.Float(5000)})
+	}, func() (string, error) {
+		html, err := entry.Evaluate("e => e.outerHTML", nil)
+		if err != nil {
+			return "", err
+		}
+		s, _ := html.(string)
+		return s, nil
+	})
+}
+
+// save writes name.png and name.html. A capture that fails is reported and
+// skipped, it never fails the scrape.
+func (d *debugCapture) save(name string, screenshot func() ([]byte, error), html func() (string, error)) {
+	d.mu.Lock()
+	if d.saved[name] {
+		d.mu.Unlock()
+		return
+	}
+	d.saved[name] = true
+	d.mu.Unlock()
+
+	if err := d.write(name, screenshot, html); err != nil {
+		fmt.Printf("Could not save debug capture %s: %v\n", name, err)
+	}
+}
+
+func (d *debugCapture) write(name string, screenshot func() ([]byte, error), html func() (string, error)) error {
+	// the DOM is read first, it survives pages the screenshot times out on
+	content, err := html()
+	if err != nil {
+		return fmt.Errorf("could not read page content: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(d.dir, name+".html"), []byte(content), 0644); err != nil {
+		return err
+	}
+
+	image, err := screenshot()
+	if err != nil {
+		return fmt.Errorf("could not take screenshot: %v", err)
+	}
+	return os.WriteFile(filepath.Join(d.dir, name+".png"), image, 0644)
+}
+
+// startTrace records screenshots and DOM snapshots of every action in context
+func (d *debugCapture) startTrace(context playwright.BrowserContext) error {
+	if d == nil {
+		return nil
+	}
+	err := context.Tracing().Start(playwright.TracingStartOptions{
+		Screenshots: playwright.Bool(true),
+		Snapshots:   playwright.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("could not start trace: %v", err)
+	}
+	return nil
+}
+
+// stopTrace saves the trace of a pool slot's context as trace-<slot>.zip,
+// which opens with "playwright show-trace"
+func (d *debugCapture) stopTrace(slot int, context playwright.BrowserContext) error {
+	if d == nil {
+		return nil
+	}
+	if err := context.Tracing().Stop(d.tracePath(slot)); err != nil {
+		return fmt.Errorf("could not save trace: %v", err)
+	}
+	return nil
+}
+
+func (d *debugCapture) tracePath(slot int) string {
+	return filepath.Join(d.dir, fmt.Sprintf("trace-%d.zip", slot))
+}
+
+// ForgetDebugCaptures deletes the captures saved to dir for the listing at
+// listingURL, its details page and its rows of listings pages, and returns
+// the files deleted, or those that would be on a dry run. Traces record whole
+// browser contexts and are kept.
+func ForgetDebugCaptures(dir, listingURL string, dryRun bool) ([]string, error) {
+	ref := listingURL
+	if u, err := url.Parse(listingURL); err == nil && u.Path != "" {
+		ref = u.Path
+	}
+	suffix := "_" + slug(ref)
+	return removeFiles(dir, func(name string) bool {
+		ext := filepath.Ext(name)
+		return (ext == ".html" || ext == ".png") && strings.HasSuffix(strings.TrimSuffix(name, ext), suffix)
+	}, dryRun)
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// captureName names a capture after the page it was taken on and the listing
+// whose selector failed, such as page-2_buysell_3958041 for a row of the
+// second listings page. Names are kept short enough for any file system.
+func captureName(pageName, listingURL string) string {
+	parts := []string{slug(pageName)}
+	if listingURL != "" {
+		ref := listingURL
+		if u, err := url.Parse(listingURL); err == nil && u.Path != "" {
+			ref = u.Path
+		}
+		parts = append(parts, slug(ref))
+	}
+
+	name := strings.Join(parts, "_")
+	if len(name) > 120 {
+		name = name[:120]
+	}
+	return name
+}
+
+func slug(s string) string {
+	s = unsafeNameChars.ReplaceAllString(s, "_")
+	s = strings.Trim(s, "_.")
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
@@ -0,0 +1,76 @@
+package scraper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureName(t *testing.T) {
+	assert.Equal(t, "page-2_buysell_3958041", captureName("page-2", "https://www.pinkbike.com/buysell/3958041/"))
+	assert.Equal(t, "details_buysell_3958041", captureName("details", "/buysell/3958041/"))
+	assert.Equal(t, "page-1_row-3", captureName("page-1", "row-3"))
+	assert.Equal(t, "page-1", captureName("page-1", ""))
+	assert.Equal(t, "unknown_unknown", captureName("", "?"))
+	assert.Len(t, captureName("page-1", "/buysell/"+strings.Repeat("a", 300)), 120)
+}
+
+func TestDebugCapture(t *testing.T) {
+	d, err := newDebugCapture("")
+	require.NoError(t, err)
+	assert.Nil(t, d, "no directory disables captures")
+	d.capturePage(nil, "page-1", "")
+	assert.NoError(t, d.startTrace(nil))
+
+	dir := filepath.Join(t.TempDir(), "debug")
+	d, err = newDebugCapture(dir)
+	require.NoError(t, err)
+
+	shots := 0
+	screenshot := func() ([]byte, error) {
+		shots++
+		return []byte("png"), nil
+	}
+	html := func() (string, error) { return "<html></html>", nil }
+
+	d.save("page-1_buysell_1", screenshot, html)
+	d.save("page-1_buysell_1", screenshot, html)
+	assert.Equal(t, 1, shots, "a page is captured once however many fields fail on it")
+
+	image, err := os.ReadFile(filepath.Join(dir, "page-1_buysell_1.png"))
+	require.NoError(t, err)
+	assert.Equal(t, "png", string(image))
+	content, err := os.ReadFile(filepath.Join(dir, "page-1_buysell_1.html"))
+	require.NoError(t, err)
+	assert.Equal(t, "<html></html>", string(content))
+
+	// the DOM is kept when the screenshot fails
+	d.save("details_buysell_2", func() ([]byte, error) { return nil, errors.New("timeout") }, html)
+	assert.FileExists(t, filepath.Join(dir, "details_buysell_2.html"))
+	assert.NoFileExists(t, filepath.Join(dir, "details_buysell_2.png"))
+
+	assert.Equal(t, filepath.Join(dir, "trace-1.zip"), d.tracePath(1))
+}
+
+func TestForgetDebugCaptures(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"details_buysell_3958041.html", "details_buysell_3958041.png", "page-2_buysell_3958041.png",
+		"details_buysell_39580410.html", "trace-1.zip"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+
+	removed, err := ForgetDebugCaptures(dir, "https://www.pinkbike.com/buysell/3958041/", false)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		filepath.Join(dir, "details_buysell_3958041.html"),
+		filepath.Join(dir, "details_buysell_3958041.png"),
+		filepath.Join(dir, "page-2_buysell_3958041.png"),
+	}, removed)
+	assert.FileExists(t, filepath.Join(dir, "details_buysell_39580410.html"))
+	assert.FileExists(t, filepath.Join(dir, "trace-1.zip"))
+}
@@ -0,0 +1,72 @@
+package scraper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DetailField is a field scraped from a listing's detail page
+type DetailField string
+
+const (
+	SellerTypeField   DetailField = "sellerType"
+	PostDateField     DetailField = "postDate"
+	DescriptionField  DetailField = "description"
+	RestrictionsField DetailField = "restrictions"
+	PhotosField       DetailField = "photos"
+	ViewsField        DetailField = "views"
+	SellerField       DetailField = "seller"
+	// PhoneField is only scraped when signed in
+	PhoneField DetailField = "phone"
+)
+
+var allDetailFields = []DetailField{SellerTypeField, PostDateField, DescriptionField, RestrictionsField, PhotosField, ViewsField, SellerField, PhoneField}
+
+// DetailFields selects which detail page fields are scraped. A nil set scrapes every field.
+type DetailFields map[DetailField]bool
+
+// Has reports whether the field should be scraped
+func (f DetailFields) Has(field DetailField) bool {
+	return f == nil || f[field]
+}
+
+// ParseDetailFields parses a comma-separated list of detail fields. An empty
+// string or "all" selects every field.
+func ParseDetailFields(s string) (DetailFields, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "all" {
+		return nil, nil
+	}
+
+	fields := DetailFields{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		found := false
+		for _, field := range allDetailFields {
+			if strings.EqualFold(name, string(field)) {
+				fields[field] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown detail field %q (available: %s)", name, strings.Join(DetailFieldNames(), ", "))
+		}
+	}
+	return fields, nil
+}
+
+// DetailFieldNames returns the names accepted by ParseDetailFields
+func DetailFieldNames() []string {
+	names := make([]string, 0, len(allDetailFields))
+	for _, field := range allDetailFields {
+		names = append(names, string(field))
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,29 @@
+package scraper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDetailFields(t *testing.T) {
+	all, err := ParseDetailFields("all")
+	require.NoError(t, err)
+	assert.True(t, all.Has(DescriptionField))
+
+	fields, err := ParseDetailFields("sellerType, postdate")
+	require.NoError(t, err)
+	assert.True(t, fields.Has(SellerTypeField))
+	assert.True(t, fields.Has(PostDateField))
+	assert.False(t, fields.Has(DescriptionField))
+	assert.False(t, fields.Has(RestrictionsField))
+
+	fields, err = ParseDetailFields("photos,views")
+	require.NoError(t, err)
+	assert.True(t, fields.Has(PhotosField))
+	assert.True(t, fields.Has(ViewsField))
+
+	_, err = ParseDetailFields("sellerType,mileage")
+	assert.Error(t, err)
+}
@@ -0,0 +1,203 @@
+package scraper
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/geo"
+	"pinkbike-scraper/pkg/listing"
+)
+
+// RowError describes a single CSV row that could not be imported
+type RowError struct {
+	Row int
+	Err error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+// legacyColumns is the fixed column order of files written before exports had headers
+var legacyColumns = []string{"title", "year", "price", "currency", "condition", "framesize", "wheelsize", "fronttravel", "reartravel", "framematerial"}
+
+// columnAliases maps normalized header names to the listing field they hold
+var columnAliases = map[string]string{
+	"title":            "title",
+	"year":             "year",
+	"manufacturer":     "manufacturer",
+	"model":            "model",
+	"price":            "price",
+	"usdprice":         "price",
+	"currency":         "currency",
+	"originalcurrency": "currency",
+	"condition":        "condition",
+	"framesize":        "framesize",
+	"wheelsize":        "wheelsize",
+	"framematerial":    "framematerial",
+	"material":         "framematerial",
+	"fronttravel":      "fronttravel",
+	"reartravel":       "reartravel",
+	"url":              "url",
+	"category":         "category",
+	"biketype":         "category",
+	"listedprice":      "listedprice",
+	"pricecurrency":    "pricecurrency",
+	"location":         "location",
+	"latitude":         "latitude",
+	"longitude":        "longitude",
+}
+
+// ReadListingsFromFile reads listings from the configured file path. Rows that
+// cannot be imported are skipped and reported alongside the listings.
+func (s *Scraper) ReadListingsFromFile() ([]listing.Listing, []RowError, error) {
+	return ReadListingsFile(s.opts.FilePath, s.opts.Clock)
+}
+
+// ReadListingsFile reads listings from a CSV file, such as one written by the
+// csv export, with or without a header row. They are revalidated at clk.
+func ReadListingsFile(path string, clk clock.Clock) ([]listing.Listing, []RowError, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not open file: %v", err)
+	}
+	defer file.Close()
+
+	return readListingsCSV(file, clk)
+}
+
+func readListingsCSV(r io.Reader, clk clock.Clock) ([]listing.Listing, []RowError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read file: %v", err)
+	}
+
+	if len(records) == 0 {
+		return nil, nil, nil
+	}
+
+	columns, hasHeader, err := detectColumns(records[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	firstRow := 0
+	if hasHeader {
+		firstRow = 1
+	}
+
+	var rowErrors []RowError
+	listings := make([]listing.Listing, 0, len(records))
+	for i, record := range records[firstRow:] {
+		l, err := parseRecord(record, columns)
+		if err != nil {
+			rowErrors = append(rowErrors, RowError{Row: i + firstRow + 1, Err: err})
+			continue
+		}
+
+		listings = append(listings, l.Revalidate(clk))
+	}
+
+	return listings, rowErrors, nil
+}
+
+// detectColumns maps column indexes to listing fields using the header row when
+// present, falling back to the legacy fixed column order
+func detectColumns(firstRow []string) (map[string]int, bool, error) {
+	columns := map[string]int{}
+	for i, name := range firstRow {
+		if field, ok := columnAliases[normalizeHeader(name)]; ok {
+			if _, dup := columns[field]; dup {
+				return nil, false, fmt.Errorf("duplicate column for %s in header", field)
+			}
+			columns[field] = i
+		}
+	}
+
+	if _, ok := columns["title"]; ok {
+		if _, ok := columns["price"]; !ok {
+			return nil, false, fmt.Errorf("header is missing a price column")
+		}
+		return columns, true, nil
+	}
+
+	if len(firstRow) < len(legacyColumns) {
+		return nil, false, fmt.Errorf("no header found and row has %d columns, expected %d", len(firstRow), len(legacyColumns))
+	}
+
+	columns = map[string]int{}
+	for i, field := range legacyColumns {
+		columns[field] = i
+	}
+	return columns, false, nil
+}
+
+func normalizeHeader(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.TrimPrefix(name, "\ufeff")
+	return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(name)
+}
+
+func parseRecord(record []string, columns map[string]int) (listing.Listing, error) {
+	field := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	for name, i := range columns {
+		if i >= len(record) {
+			return listing.Listing{}, fmt.Errorf("missing %s column, row has %d columns", name, len(record))
+		}
+	}
+
+	l := listing.Listing{
+		Title:         field("title"),
+		Year:          field("year"),
+		Manufacturer:  field("manufacturer"),
+		Model:         field("model"),
+		Price:         strings.NewReplacer("$", "", ",", "").Replace(field("price")),
+		Currency:      field("currency"),
+		Condition:     field("condition"),
+		FrameSize:     field("framesize"),
+		WheelSize:     field("wheelsize"),
+		FrameMaterial: field("framematerial"),
+		Category:      field("category"),
+		FrontTravel:   field("fronttravel"),
+		RearTravel:    field("reartravel"),
+		URL:           field("url"),
+		ListedPrice:   field("listedprice"),
+		Location:      field("location"),
+	}
+	if p, ok := geo.ParsePoint(field("latitude") + "," + field("longitude")); ok {
+		l.Coordinates = p
+	}
+
+	if l.Title == "" {
+		return listing.Listing{}, fmt.Errorf("empty title")
+	}
+
+	// prices are stored in USD, so one exported in a reporting currency
+	// cannot be read back as it is
+	if priceCurrency := strings.ToUpper(field("pricecurrency")); priceCurrency != "" && priceCurrency != "USD" && l.Price != "" {
+		return listing.Listing{}, fmt.Errorf("price is in %s, not USD", priceCurrency)
+	}
+
+	if l.Price != "" {
+		if _, err := strconv.ParseFloat(l.Price, 64); err != nil {
+			return listing.Listing{}, fmt.Errorf("invalid price %q", l.Price)
+		}
+	}
+
+	return l, nil
+}
@@ -0,0 +1,74 @@
+package scraper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadListingsCSVWithHeader(t *testing.T) {
+	input := `Title,Year,Manufacturer,Model,USD Price,Original Currency,Condition,Frame Size,Wheel Size,Front Travel,Rear Travel,Material,Reason for Review,URL,Category,Extra
+2021 Evil Wreckoning,2021,Evil,Wreckoning,3900,USD,Excellent - Lightly Ridden,XL,29,170 mm,170 mm,Carbon Fiber,,https://www.pinkbike.com/buysell/3891015/,enduro,ignored
+,2020,Kona,Process 153,2200,USD,Good,L,29,160 mm,153 mm,Carbon Fiber,,,,
+2020 Kona Process 153,2020,Kona,Process 153,cheap,USD,Good,L,29,160 mm,153 mm,Carbon Fiber,,,,
+`
+
+	listings, rowErrors, err := readListingsCSV(strings.NewReader(input), nil)
+	require.NoError(t, err)
+
+	require.Len(t, listings, 1)
+	l := listings[0]
+	assert.Equal(t, "2021 Evil Wreckoning", l.Title)
+	assert.Equal(t, "3900", l.Price)
+	assert.Equal(t, "USD", l.Currency)
+	assert.Equal(t, "Carbon Fiber", l.FrameMaterial)
+	assert.Equal(t, "https://www.pinkbike.com/buysell/3891015/", l.URL)
+	assert.Equal(t, "enduro", l.Category)
+	assert.Empty(t, l.NeedsReview)
+	assert.Equal(t, l.ComputeHash(), l.Hash)
+
+	require.Len(t, rowErrors, 2)
+	assert.Equal(t, 3, rowErrors[0].Row)
+	assert.Equal(t, 4, rowErrors[1].Row)
+}
+
+func TestReadListingsCSVLegacyOrder(t *testing.T) {
+	input := `2018 Commencal Meta AM 4.2,2018,2550,CAD,"Good - Used, Mechanically Sound",M,27.5 / 650B,170 mm,160 mm,Aluminum
+2018 Commencal Meta AM 4.2,2018,2550,CAD,Good
+`
+
+	listings, rowErrors, err := readListingsCSV(strings.NewReader(input), nil)
+	require.NoError(t, err)
+
+	require.Len(t, listings, 1)
+	assert.Equal(t, "Commencal", listings[0].Manufacturer)
+	assert.Equal(t, "Meta AM", listings[0].Model)
+	assert.Equal(t, "Aluminum", listings[0].FrameMaterial)
+
+	require.Len(t, rowErrors, 1)
+	assert.Equal(t, 2, rowErrors[0].Row)
+}
+
+func TestReadListingsCSVMissingPriceColumn(t *testing.T) {
+	_, _, err := readListingsCSV(strings.NewReader("Title,Year\n2021 Evil Wreckoning,2021\n"), nil)
+	assert.Error(t, err)
+}
+
+func TestReadListingsCSVListedPrice(t *testing.T) {
+	input := `Title,Price,Currency,Listed Price,Price Currency
+2018 Commencal Meta AM 4.2,1862,CAD,2550,USD
+2022 Canyon Spectral CF 8,3212,EUR,3500,EUR
+`
+
+	listings, rowErrors, err := readListingsCSV(strings.NewReader(input), nil)
+	require.NoError(t, err)
+
+	require.Len(t, listings, 1)
+	assert.Equal(t, "1862", listings[0].Price)
+	assert.Equal(t, "2550", listings[0].ListedPrice)
+
+	require.Len(t, rowErrors, 1)
+	assert.Contains(t, rowErrors[0].Error(), "price is in EUR")
+}
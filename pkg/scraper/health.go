@@ -0,0 +1,58 @@
+package scraper
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// reachableBodyLimit bounds how much of the listings page CheckReachable reads
+// looking for a challenge
+const reachableBodyLimit = 1 << 20
+
+// CheckBrowser starts the browser the way a scrape does, installing it first
+// unless BrowserPath is set, and signing in when a login is configured
+func (s *Scraper) CheckBrowser() error {
+	return s.start()
+}
+
+// CheckReachable requests the listings page of the bike type over plain HTTP,
+// through the proxy when one is set, without starting a browser. It returns a
+// *BlockedError when the answer is a challenge rather than the page, which a
+// browser may still pass, and an error for any other failed request.
+func CheckReachable(opts ScrapeOptions) error {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.Proxy != "" {
+		proxy, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return fmt.Errorf("invalid proxy %q: %v", opts.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	client := &http.Client{Transport: transport, Timeout: opts.Timeout}
+
+	listingsURL := opts.BikeType.ListingsURL(opts.BaseURL)
+	resp, err := client.Get(listingsURL)
+	if err != nil {
+		return fmt.Errorf("could not reach %s: %v", listingsURL, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, reachableBodyLimit))
+	if err != nil {
+		return fmt.Errorf("could not read %s: %v", listingsURL, err)
+	}
+
+	headers := map[string]string{}
+	for name := range resp.Header {
+		headers[strings.ToLower(name)] = resp.Header.Get(name)
+	}
+	if reason := detectBlock(resp.StatusCode, headers, "", string(body)); reason != "" {
+		return &BlockedError{URL: listingsURL, Reason: reason}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered with HTTP %d", listingsURL, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package scraper
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckReachable(t *testing.T) {
+	var status int
+	var body string
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	opts := DefaultScrapeOptions()
+	opts.BaseURL = server.URL
+
+	status, body = 200, listingsPageHTML
+	require.NoError(t, CheckReachable(opts))
+	assert.Equal(t, "category=2", query)
+
+	status, body = 503, `<script src="/cdn-cgi/challenge-platform/h/g/orchestrate/chl_page/v1"></script>`
+	err := CheckReachable(opts)
+	assert.True(t, errors.Is(err, ErrBlocked), "%v", err)
+
+	status, body = 500, "Internal Server Error"
+	err = CheckReachable(opts)
+	require.Error(t, err)
+	assert.False(t, errors.Is(err, ErrBlocked))
+
+	opts.BaseURL = "http://127.0.0.1:1"
+	assert.Error(t, CheckReachable(opts))
+}
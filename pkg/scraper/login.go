@@ -0,0 +1,97 @@
+package scraper
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// DefaultLoginURL is Pinkbike's sign in page
+const DefaultLoginURL = "https://www.pinkbike.com/user/login/"
+
+// Login signs the scraper in to a Pinkbike account, which shows listing
+// fields such as the seller's phone number that visitors cannot see. Only
+// sign in to an account allowed to collect them. A nil Login scrapes as a
+// visitor.
+type Login struct {
+	Username string
+	Password string
+	// SessionPath is a Playwright storage state file saved from a signed in
+	// browser. Contexts without saved state of their own start from it, so
+	// no password is needed while its session lasts.
+	SessionPath string
+	// URL is the sign in page, DefaultLoginURL when empty
+	URL string
+}
+
+// NewLogin returns a Login for username, reading the password from
+// $PINKBIKE_PASSWORD so it stays out of the command line. Either a username
+// and password or a session file is needed.
+func NewLogin(username, sessionPath string) (*Login, error) {
+	l := &Login{Username: username, Password: os.Getenv("PINKBIKE_PASSWORD"), SessionPath: sessionPath}
+	if sessionPath != "" {
+		if _, err := os.Stat(sessionPath); err != nil {
+			return nil, fmt.Errorf("could not read login session: %v", err)
+		}
+	}
+	if sessionPath == "" && (l.Username == "" || l.Password == "") {
+		return nil, fmt.Errorf("signing in needs a username and $PINKBIKE_PASSWORD, or a session file")
+	}
+	return l, nil
+}
+
+func (l *Login) url() string {
+	if l.URL == "" {
+		return DefaultLoginURL
+	}
+	return l.URL
+}
+
+// signIn makes sure context is signed in, filling in the sign in form when
+// its cookies hold no session
+func (l *Login) signIn(context playwright.BrowserContext, sel Selectors) error {
+	if l == nil {
+		return nil
+	}
+
+	page, err := context.NewPage()
+	if err != nil {
+		return fmt.Errorf("could not create page: %v", err)
+	}
+	defer page.Close()
+
+	if _, err := visit(page, l.url()); err != nil {
+		return err
+	}
+	if signedIn(page, sel, 1000) {
+		return nil
+	}
+	if l.Username == "" || l.Password == "" {
+		return fmt.Errorf("the login session has expired and no username and password are set to sign in again")
+	}
+
+	if err := page.Locator(sel.LoginUsername).Fill(l.Username); err != nil {
+		return fmt.Errorf("could not fill in username: %v", err)
+	}
+	if err := page.Locator(sel.LoginPassword).Fill(l.Password); err != nil {
+		return fmt.Errorf("could not fill in password: %v", err)
+	}
+	if err := page.Locator(sel.LoginSubmit).Click(); err != nil {
+		return fmt.Errorf("could not submit sign in form: %v", err)
+	}
+	if !signedIn(page, sel, 15000) {
+		return fmt.Errorf("could not sign in as %s: check the username and password", l.Username)
+	}
+	return nil
+}
+
+// signedIn reports whether page shows a signed in account, waiting up to
+// timeout milliseconds for it to
+func signedIn(page playwright.Page, sel Selectors, timeout float64) bool {
+	err := page.Locator(sel.SignedIn).First().WaitFor(playwright.LocatorWaitForOptions{
+		State:   playwright.WaitForSelectorStateAttached,
+		Timeout: playwright.Float(timeout),
+	})
+	return err == nil
+}
@@ -0,0 +1,32 @@
+package scraper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLogin(t *testing.T) {
+	t.Setenv("PINKBIKE_PASSWORD", "")
+	_, err := NewLogin("rider", "")
+	assert.Error(t, err, "a username needs a password")
+
+	_, err = NewLogin("", filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+
+	session := filepath.Join(t.TempDir(), "session.json")
+	require.NoError(t, os.WriteFile(session, []byte(`{"cookies":[]}`), 0600))
+	l, err := NewLogin("", session)
+	require.NoError(t, err)
+	assert.Equal(t, session, l.SessionPath)
+	assert.Equal(t, DefaultLoginURL, l.url())
+
+	t.Setenv("PINKBIKE_PASSWORD", "secret")
+	l, err = NewLogin("rider", "")
+	require.NoError(t, err)
+	assert.Equal(t, "rider", l.Username)
+	assert.Equal(t, "secret", l.Password)
+}
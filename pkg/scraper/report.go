@@ -0,0 +1,98 @@
+package scraper
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ListingLevel is the field of errors that affect a whole listing rather than one field
+const ListingLevel = "listing"
+
+// ScrapeError is a problem scraping one field of a listing, or the whole
+// listing when Field is ListingLevel
+type ScrapeError struct {
+	URL   string
+	Field string
+	Err   error
+}
+
+func (e ScrapeError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.URL, e.Field, e.Err)
+}
+
+func (e ScrapeError) Unwrap() error {
+	return e.Err
+}
+
+// ScrapeReport collects the errors hit while scraping so data quality issues
+// can be reviewed after a run instead of scrolling past in the log
+type ScrapeReport struct {
+	Errors []ScrapeError
+}
+
+// Add records an error for a field of the listing at url
+func (r *ScrapeReport) Add(url, field string, err error) {
+	r.Errors = append(r.Errors, ScrapeError{URL: url, Field: field, Err: err})
+}
+
+// Merge appends the errors of another report
+func (r *ScrapeReport) Merge(other ScrapeReport) {
+	r.Errors = append(r.Errors, other.Errors...)
+}
+
+// ByField counts errors per field
+func (r ScrapeReport) ByField() map[string]int {
+	counts := map[string]int{}
+	for _, e := range r.Errors {
+		counts[e.Field]++
+	}
+	return counts
+}
+
+// Summary is a one-line count of errors per field, most frequent first
+func (r ScrapeReport) Summary() string {
+	if len(r.Errors) == 0 {
+		return "no scrape errors"
+	}
+
+	counts := r.ByField()
+	fields := make([]string, 0, len(counts))
+	for field := range counts {
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		if counts[fields[i]] != counts[fields[j]] {
+			return counts[fields[i]] > counts[fields[j]]
+		}
+		return fields[i] < fields[j]
+	})
+
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = fmt.Sprintf("%s %d", field, counts[field])
+	}
+	return fmt.Sprintf("%d scrape errors (%s)", len(r.Errors), strings.Join(parts, ", "))
+}
+
+// WriteCSV saves the errors as url, field, error rows
+func (r ScrapeReport) WriteCSV(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create scrape report: %v", err)
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	w.Write([]string{"url", "field", "error"})
+	for _, e := range r.Errors {
+		w.Write([]string{e.URL, e.Field, e.Err.Error()})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("could not write scrape report: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package scraper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrapeReport(t *testing.T) {
+	var report ScrapeReport
+	assert.Equal(t, "no scrape errors", report.Summary())
+
+	timeout := errors.New("timeout")
+	report.Add("/buysell/1/", "price", timeout)
+	report.Add("/buysell/2/", "price", timeout)
+
+	var details ScrapeReport
+	details.Add("/buysell/2/", string(DescriptionField), errors.New("not found"))
+	details.Add("/buysell/3/", ListingLevel, errors.New("status 404"))
+	report.Merge(details)
+
+	assert.Equal(t, map[string]int{"price": 2, "description": 1, "listing": 1}, report.ByField())
+	assert.Equal(t, "4 scrape errors (price 2, description 1, listing 1)", report.Summary())
+	assert.ErrorIs(t, report.Errors[0], timeout)
+
+	path := filepath.Join(t.TempDir(), "report.csv")
+	require.NoError(t, report.WriteCSV(path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "url,field,error\n/buysell/1/,price,timeout\n/buysell/2/,price,timeout\n/buysell/2/,description,not found\n/buysell/3/,listing,status 404\n", string(data))
+}
@@ -1,90 +1,166 @@
 package scraper
 
 import (
-	"encoding/csv"
+	"errors"
 	"fmt"
-	"log"
-	"os"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/playwright-community/playwright-go"
 
-	"pinkbike-scraper/pkg/exporter"
 	"pinkbike-scraper/pkg/listing"
 )
 
-var (
-	Enduro BikeType = "enduro"
-	Trail  BikeType = "trail"
-	XC     BikeType = "xc"
-	DH     BikeType = "dh"
-)
-
-// biketype enum
-type BikeType string
-
 // Scraper holds configuration for scraping operations
 type Scraper struct {
-	filePath   string
-	headless   bool
-	pw         *playwright.Playwright
-	browser    playwright.Browser
-	baseUrl    string
-	dbExporter exporter.DBExporter
-	page       playwright.Page
+	opts ScrapeOptions
+
+	pw      *playwright.Playwright
+	browser playwright.Browser
+	// contexts are handed to the workers fetching listing details
+	contexts *contextPool
+	// context holds the listings page
+	context playwright.BrowserContext
+	page    playwright.Page
+	// listingsOpen is set once page has loaded the first listings page
+	listingsOpen bool
+	// pages is how many listing pages the last PerformWebScraping visited
+	pages int
+	// debug saves failing pages and traces, nil unless DebugDir is set
+	debug *debugCapture
+}
+
+// NewScraper creates and returns a new Scraper instance. The browser is only
+// started when the first page is scraped, so creating a Scraper needs no
+// browser or network.
+func NewScraper(opts ScrapeOptions) (*Scraper, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	return &Scraper{opts: opts}, nil
 }
 
-// NewScraper creates and returns a new Scraper instance
-func NewScraper(filePath string, headless bool, baseUrl string, bikeType BikeType, dbExporter exporter.DBExporter) (*Scraper, error) {
-	err := playwright.Install()
+// start launches the browser and opens the listings page in a context from
+// the pool, unless it is already running
+func (s *Scraper) start() error {
+	if s.page != nil {
+		return nil
+	}
+
+	launch, err := s.opts.launchOptions()
 	if err != nil {
-		return nil, fmt.Errorf("could not install playwright: %v", err)
+		return err
+	}
+	if s.debug, err = newDebugCapture(s.opts.DebugDir); err != nil {
+		return err
+	}
+	runOptions := &playwright.RunOptions{Verbose: true, Browsers: []string{s.opts.Engine}}
+	if s.opts.BrowserPath != "" {
+		// a preinstalled browser only needs the driver, which is installed with it
+		runOptions.SkipInstallBrowsers = true
+	} else if s.opts.Snapshots != nil && s.opts.Snapshots.Replay {
+		// replays never download, Playwright and its browser must already be installed
+		runOptions.SkipInstallBrowsers = true
+	} else if err := playwright.Install(runOptions); err != nil {
+		return fmt.Errorf("could not install playwright: %v", err)
 	}
 
-	pw, err := playwright.Run()
+	pw, err := playwright.Run(runOptions)
 	if err != nil {
-		return nil, fmt.Errorf("could not start playwright: %v", err)
+		return fmt.Errorf("could not start playwright: %v", err)
 	}
 
-	browser, err := pw.Chromium.Launch(playwright.BrowserTypeLaunchOptions{
-		Headless: playwright.Bool(headless),
-	})
+	browser, err := s.opts.browserType(pw).Launch(launch)
 	if err != nil {
 		pw.Stop()
-		return nil, fmt.Errorf("could not launch browser: %v", err)
+		return fmt.Errorf("could not launch %s: %v", s.opts.Engine, err)
+	}
+	s.pw, s.browser = pw, browser
+
+	var session string
+	if s.opts.Login != nil {
+		session = s.opts.Login.SessionPath
+	}
+	s.contexts, err = newContextPool(browser, s.opts.Workers, s.opts.UserDataDir, session, func(context playwright.BrowserContext) error {
+		if s.opts.Timeout > 0 {
+			context.SetDefaultNavigationTimeout(float64(s.opts.Timeout.Milliseconds()))
+		}
+		if err := s.opts.Blocking.install(context); err != nil {
+			return fmt.Errorf("could not set up resource blocking: %v", err)
+		}
+		if err := s.opts.Snapshots.install(context); err != nil {
+			return fmt.Errorf("could not set up snapshot replay: %v", err)
+		}
+		// tracing starts before signing in so a failed sign in is recorded too
+		if err := s.debug.startTrace(context); err != nil {
+			return err
+		}
+		if err := s.opts.Login.signIn(context, s.opts.Selectors); err != nil {
+			return fmt.Errorf("could not sign in: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if s.debug != nil {
+		s.contexts.beforeClose = s.debug.stopTrace
+	}
+	context, err := s.contexts.acquire()
+	if err != nil {
+		return err
 	}
+	// the listings page stays open in its context, idle while the details
+	// workers use the pool
+	defer s.contexts.release(context)
 
-	page, err := browser.NewPage()
+	page, err := context.NewPage()
 	if err != nil {
-		return nil, fmt.Errorf("could not create page: %v", err)
+		return fmt.Errorf("could not create page: %v", err)
 	}
+	s.context, s.page = context, page
+	return nil
+}
 
-	url := getListingsUrl(baseUrl, bikeType)
+// openListings starts the browser and loads the first listings page of the
+// bike type, once
+func (s *Scraper) openListings() error {
+	if err := s.start(); err != nil {
+		return err
+	}
+	if s.listingsOpen || s.opts.BaseURL == "" {
+		return nil
+	}
 
-	resp, err := page.Goto(url)
+	listingsURL := s.opts.BikeType.ListingsURL(s.opts.BaseURL)
+	resp, err := visit(s.page, listingsURL)
 	if err != nil {
-		return nil, fmt.Errorf("could not goto: %v", err)
+		return err
 	}
 
 	if resp.Status() != 200 {
-		return nil, fmt.Errorf("could not get 200 status: %v", resp.Status())
-	}
-
-	return &Scraper{
-		filePath:   filePath,
-		headless:   headless,
-		pw:         pw,
-		browser:    browser,
-		baseUrl:    baseUrl,
-		page:       page,
-		dbExporter: dbExporter,
-	}, nil
+		return fmt.Errorf("could not get 200 status: %v", resp.Status())
+	}
+	if err := s.opts.Snapshots.save(s.page, listingsURL); err != nil {
+		return err
+	}
+	s.listingsOpen = true
+	return nil
 }
 
 // Close cleanly shuts down the scraper
 func (s *Scraper) Close() error {
+	if s.browser == nil {
+		return nil
+	}
+	if s.contexts != nil {
+		if err := s.contexts.close(); err != nil {
+			return err
+		}
+	}
 	if err := s.browser.Close(); err != nil {
 		return fmt.Errorf("could not close browser: %v", err)
 	}
@@ -94,187 +170,444 @@ func (s *Scraper) Close() error {
 	return nil
 }
 
-// ReadListingsFromFile reads listings from the configured file path
-func (s *Scraper) ReadListingsFromFile() ([]listing.Listing, error) {
-	file, err := os.Open(s.filePath)
+// PerformWebScraping performs the web scraping operation. Fields that could not
+// be read are left empty and recorded in the returned report.
+func (s *Scraper) PerformWebScraping(numPages int) ([]listing.RawListing, ScrapeReport, error) {
+	var report ScrapeReport
+	if err := s.openListings(); err != nil {
+		return nil, report, err
+	}
+	bar := s.opts.Progress.Start("Pages", "pages", numPages)
+	defer bar.Done()
+
+	listings, nextPageURL, err := scrapePage(s.page, s.opts.Selectors, &report, s.debug, "page-1")
 	if err != nil {
-		return nil, fmt.Errorf("could not open file: %v", err)
+		return nil, report, fmt.Errorf("could not scrape page: %v", err)
 	}
-	defer file.Close()
+	bar.Add(1)
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+	knownStreak, stop, err := s.updateKnownStreak(listings, 0)
 	if err != nil {
-		return nil, fmt.Errorf("could not read file: %v", err)
+		return nil, report, err
 	}
 
-	listings := make([]listing.Listing, 0, len(records))
-	for _, record := range records {
-		l := listing.Listing{
-			Title:         record[0],
-			Year:          record[1],
-			Price:         record[2],
-			Currency:      record[3],
-			Condition:     record[4],
-			FrameSize:     record[5],
-			WheelSize:     record[6],
-			FrontTravel:   record[7],
-			RearTravel:    record[8],
-			FrameMaterial: record[9],
+	var newListings []listing.RawListing
+	pages := 1
+	for !stop && nextPageURL != "" && pages < numPages {
+		pages++
+
+		time.Sleep(s.opts.Delay)
+		pageURL := s.opts.BaseURL + nextPageURL
+		if _, err = visit(s.page, pageURL); err != nil {
+			return nil, report, err
+		}
+		if err := s.opts.Snapshots.save(s.page, pageURL); err != nil {
+			return nil, report, err
+		}
+
+		newListings, nextPageURL, err = scrapePage(s.page, s.opts.Selectors, &report, s.debug, fmt.Sprintf("page-%d", pages))
+		if err != nil {
+			return nil, report, fmt.Errorf("could not scrape page: %v", err)
+		}
+
+		listings = append(listings, newListings...)
+		bar.Add(1)
+
+		knownStreak, stop, err = s.updateKnownStreak(newListings, knownStreak)
+		if err != nil {
+			return nil, report, err
 		}
+	}
 
-		listings = append(listings, l)
+	if stop {
+		bar.Done()
+		s.opts.Progress.Printf("Stopped after %d consecutive known listings\n", knownStreak)
 	}
 
-	return listings, nil
+	s.pages = pages
+	return listings, report, nil
 }
 
-// PerformWebScraping performs the web scraping operation
-func (s *Scraper) PerformWebScraping(numPages int) ([]listing.RawListing, error) {
-	fmt.Println("Scraping page: 1")
+// Pages returns how many listing pages the last PerformWebScraping visited
+func (s *Scraper) Pages() int {
+	return s.pages
+}
 
-	listings, nextPageURL, err := scrapePage(s.page)
-	if err != nil {
-		return nil, fmt.Errorf("could not scrape page: %v", err)
+// updateKnownStreak continues counting consecutive listings that already exist in
+// the database and reports whether the incremental threshold has been reached
+func (s *Scraper) updateKnownStreak(listings []listing.RawListing, streak int) (int, bool, error) {
+	if s.opts.StopAfterKnown <= 0 {
+		return 0, false, nil
 	}
 
-	var newListings []listing.RawListing
-	pages := 1
-	for nextPageURL != "" && pages < numPages {
-		pages++
-		fmt.Println("Scraping page: ", pages)
+	return countKnownStreak(listings, streak, s.opts.StopAfterKnown, s.opts.DB.FingerprintExists)
+}
 
-		if _, err = s.page.Goto(s.baseUrl + nextPageURL); err != nil {
-			return nil, fmt.Errorf("could not goto: %v", err)
+// countKnownStreak counts listings already stored, recognized by their
+// fingerprint so one whose title was edited since is still known
+func countKnownStreak(listings []listing.RawListing, streak, threshold int, exists func(fingerprint string) (bool, error)) (int, bool, error) {
+	for _, l := range listings {
+		// the fingerprint only depends on fields that PostProcess does not convert
+		known, err := exists(l.PostProcess(1.0, nil).Fingerprint())
+		if err != nil {
+			return streak, false, fmt.Errorf("could not check if listing exists: %v", err)
 		}
 
-		newListings, nextPageURL, err = scrapePage(s.page)
-		if err != nil {
-			return nil, fmt.Errorf("could not scrape page: %v", err)
+		if !known {
+			streak = 0
+			continue
 		}
 
-		listings = append(listings, newListings...)
+		streak++
+		if streak >= threshold {
+			return streak, true, nil
+		}
+	}
+
+	return streak, false, nil
+}
+
+// FetchListingDetails scrapes the detail page of listings that do not have
+// details stored yet, with one worker per browser context in the pool. A
+// listing whose page cannot be loaded keeps empty details and the failure is
+// recorded in the returned report.
+func (s *Scraper) FetchListingDetails(listings []listing.Listing) ([]listing.Listing, ScrapeReport, error) {
+	if err := s.start(); err != nil {
+		return nil, ScrapeReport{}, err
+	}
+	listingsWithDetails := append([]listing.Listing(nil), listings...)
+
+	workers := s.contexts.size()
+	reports := make([]ScrapeReport, workers)
+	errs := make([]error, workers)
+	jobs := make(chan int)
+
+	bar := s.opts.Progress.Start("Details", "listings", len(listingsWithDetails))
+	defer bar.Done()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			errs[w] = s.fetchDetails(jobs, listingsWithDetails, &reports[w])
+		}(w)
+	}
+	for i := range listingsWithDetails {
+		jobs <- i
+		bar.Add(1)
 	}
+	close(jobs)
+	wg.Wait()
 
-	return listings, nil
+	var report ScrapeReport
+	for w := range reports {
+		report.Merge(reports[w])
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, report, err
+		}
+	}
+	return listingsWithDetails, report, nil
 }
 
-func (s *Scraper) FetchListingDetails(listings []listing.Listing) ([]listing.Listing, error) {
-	page, err := s.browser.NewPage()
+// fetchDetails fetches the details of the listings whose indexes arrive on
+// jobs in a context of its own, updating them in place. After an error it
+// keeps receiving jobs without fetching them so the other workers finish.
+func (s *Scraper) fetchDetails(jobs <-chan int, listings []listing.Listing, report *ScrapeReport) error {
+	fail := func(err error) error {
+		for range jobs {
+		}
+		return err
+	}
+
+	context, err := s.contexts.acquire()
 	if err != nil {
-		return nil, fmt.Errorf("could not create page: %v", err)
+		return fail(err)
 	}
+	defer s.contexts.release(context)
 
-	listingsWithDetails := []listing.Listing{}
+	page, err := context.NewPage()
+	if err != nil {
+		return fail(fmt.Errorf("could not create page: %v", err))
+	}
+	defer page.Close()
 
-	for _, l := range listings {
-		// if listing exists in db, and has details, skip
-		exists, err := s.dbExporter.ListingExistsWithDetails(l.Hash)
+	first := true
+	for i := range jobs {
+		l := listings[i]
+
+		// if listing exists in db, and has details, skip the details scrape
+		// unless it is a favorite, whose details are refreshed every run
+		fingerprint := l.Fingerprint()
+		exists, err := s.opts.DB.ListingExistsWithDetails(fingerprint)
 		if err != nil {
-			return nil, fmt.Errorf("could not check if listing exists: %v", err)
+			return fail(fmt.Errorf("could not check if listing exists: %v", err))
 		}
 
 		if exists {
-			continue
+			favorite, err := s.opts.DB.IsFavorite(fingerprint)
+			if err != nil {
+				return fail(fmt.Errorf("could not check if listing is a favorite: %v", err))
+			}
+			if !favorite {
+				continue
+			}
 		}
 
 		// if listing exists in db, and does not have details, perform details scrape
-		resp, err := page.Goto(l.URL)
+		if !first {
+			time.Sleep(s.opts.Delay)
+		}
+		first = false
+		resp, err := visit(page, l.URL)
+		if errors.Is(err, ErrBlocked) {
+			return fail(err)
+		}
 		if err != nil {
-			return nil, fmt.Errorf("could not goto: %v", err)
+			report.Add(l.URL, ListingLevel, err)
+			continue
 		}
 
 		if resp.Status() != 200 {
-			return nil, fmt.Errorf("could not get 200 status: %v", resp.Status())
+			report.Add(l.URL, ListingLevel, fmt.Errorf("could not get 200 status: %v", resp.Status()))
+			continue
 		}
 
-		_, err = s.detailsScrape(page)
+		if err := s.opts.Snapshots.save(page, l.URL); err != nil {
+			report.Add(l.URL, ListingLevel, err)
+		}
+		listings[i] = l.WithDetails(*s.detailsScrape(page, l.URL, report))
+	}
+	return nil
+}
+
+// detailsScrape reads the enabled detail fields from a listing page. Fields
+// that cannot be read are left empty and recorded in report against url.
+func (s *Scraper) detailsScrape(page playwright.Page, url string, report *ScrapeReport) *listing.ListingDetails {
+	details := listing.ListingDetails{}
+	failures := len(report.Errors)
+	defer func() {
+		if len(report.Errors) > failures {
+			s.debug.capturePage(page, "details", url)
+		}
+	}()
+
+	if s.opts.DetailFields.Has(SellerTypeField) {
+		sellerType, err := page.Locator(s.opts.Selectors.detailLabel("Seller Type")).TextContent(playwright.LocatorTextContentOptions{Timeout: playwright.Float(1000)})
 		if err != nil {
-			return nil, fmt.Errorf("could not scrape details: %v", err)
+			report.Add(url, string(SellerTypeField), fmt.Errorf("could not get seller type: %v", err))
+		} else {
+			details.SellerType = listing.ParseSellerType(listing.ParseItemDetail(sellerType, "Seller Type:"))
 		}
+	}
 
+	if s.opts.DetailFields.Has(PostDateField) {
+		postDate, err := originalPostDate(page, s.opts.Selectors)
+		if err != nil {
+			report.Add(url, string(PostDateField), err)
+		} else {
+			details.OriginalPostDate = postDate
+		}
 	}
 
-	return listingsWithDetails, nil
-}
+	if s.opts.DetailFields.Has(DescriptionField) {
+		description, err := page.Locator(s.opts.Selectors.Description).TextContent(playwright.LocatorTextContentOptions{Timeout: playwright.Float(1000)})
+		if err != nil {
+			report.Add(url, string(DescriptionField), fmt.Errorf("could not get description: %v", err))
+		} else {
+			details.Description = description
+		}
+	}
 
-func (s *Scraper) detailsScrape(page playwright.Page) (*listing.ListingDetails, error) {
-	details := listing.ListingDetails{}
+	if s.opts.DetailFields.Has(RestrictionsField) {
+		restrictions, err := page.Locator(s.opts.Selectors.Restrictions).TextContent(playwright.LocatorTextContentOptions{
+			Timeout: playwright.Float(1000),
+		})
+		if err != nil {
+			report.Add(url, string(RestrictionsField), fmt.Errorf("could not get restrictions: %v", err))
+		} else {
+			restrictions = strings.Split(restrictions, "Phone Number:")[0]
+			details.Restrictions = listing.ParseItemDetail(restrictions, "Restrictions:")
+		}
+	}
 
-	sellerType, err := page.Locator(`xpath=//div[contains(@class, "buysell-details-column")]//b[contains(text(), "Seller Type")]/parent::*`).TextContent(playwright.LocatorTextContentOptions{Timeout: playwright.Float(1000)})
-	if err != nil {
-		return nil, fmt.Errorf("\tcould not get seller type: %v", err)
+	if s.opts.DetailFields.Has(PhotosField) {
+		photos, err := photoCount(page, s.opts.Selectors)
+		if err != nil {
+			report.Add(url, string(PhotosField), err)
+		} else {
+			details.PhotoCount = photos
+		}
+	}
+
+	if s.opts.DetailFields.Has(ViewsField) {
+		views, err := viewCount(page, s.opts.Selectors)
+		if err != nil {
+			report.Add(url, string(ViewsField), err)
+		} else {
+			details.ViewCount = views
+		}
+	}
+
+	if s.opts.DetailFields.Has(SellerField) {
+		name, profile, err := seller(page, s.opts.Selectors)
+		if err != nil {
+			report.Add(url, string(SellerField), err)
+		} else {
+			details.Seller, details.SellerURL = name, profile
+		}
+	}
+
+	if s.opts.Login != nil && s.opts.DetailFields.Has(PhoneField) {
+		phone, err := phoneNumber(page, s.opts.Selectors)
+		if err != nil {
+			report.Add(url, string(PhoneField), err)
+		} else {
+			details.Phone = phone
+		}
 	}
 
-	originalPostDate, err := page.Locator(`xpath=//div[contains(@class, "buysell-details-column")]//b[contains(text(), "Original Post Date")]//parent::div`).TextContent(playwright.LocatorTextContentOptions{Timeout: playwright.Float(1000)})
+	return &details
+}
+
+// phoneNumber reveals and reads the seller's phone number. Listings without
+// one return an empty number.
+func phoneNumber(page playwright.Page, sel Selectors) (string, error) {
+	reveal := page.Locator(sel.PhoneReveal)
+	n, err := reveal.Count()
 	if err != nil {
-		return nil, fmt.Errorf("\tcould not get original post date: %v", err)
+		return "", fmt.Errorf("could not find phone number: %v", err)
+	}
+	if n == 0 {
+		return "", nil
 	}
 
-	dateRegex := regexp.MustCompile(`Original Post Date:\s*((?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec)-\d{2}-\d{4})`)
-	matches := dateRegex.FindStringSubmatch(originalPostDate)
-	if len(matches) < 2 {
-		return nil, fmt.Errorf("\tcould not find date in string: %s", originalPostDate)
+	if err := reveal.Click(playwright.LocatorClickOptions{Timeout: playwright.Float(1000)}); err != nil {
+		return "", fmt.Errorf("could not reveal phone number: %v", err)
+	}
+	if err := reveal.WaitFor(playwright.LocatorWaitForOptions{
+		State:   playwright.WaitForSelectorStateDetached,
+		Timeout: playwright.Float(5000),
+	}); err != nil {
+		return "", fmt.Errorf("phone number was not revealed: %v", err)
 	}
 
-	postDate, err := time.Parse("Jan-02-2006", matches[1])
+	phone, err := page.Locator(sel.Phone).TextContent(playwright.LocatorTextContentOptions{Timeout: playwright.Float(1000)})
 	if err != nil {
-		return nil, fmt.Errorf("\tcould not parse original post date: %v", err)
+		return "", fmt.Errorf("could not get phone number: %v", err)
 	}
+	return strings.TrimSpace(phone), nil
+}
 
-	description, err := page.Locator(`xpath=//div[contains(@class, 'buysell-container description')]`).TextContent(playwright.LocatorTextContentOptions{Timeout: playwright.Float(1000)})
+// photoCount counts the listing's photos: one per thumbnail, or the main
+// photo alone when there are no thumbnails
+func photoCount(page playwright.Page, sel Selectors) (int, error) {
+	n, err := page.Locator(sel.Photos).Count()
+	if err != nil {
+		return 0, fmt.Errorf("could not count photos: %v", err)
+	}
+	if n > 0 {
+		return n, nil
+	}
+	n, err = page.Locator(sel.MainPhoto).Count()
 	if err != nil {
-		return nil, fmt.Errorf("\tcould not get description: %v", err)
+		return 0, fmt.Errorf("could not count photos: %v", err)
+	}
+	if n > 0 {
+		return 1, nil
 	}
+	return 0, nil
+}
 
-	restrictions, err := page.Locator(`.buysell-container-right.buysell-restrictions .buysell-container`).TextContent(playwright.LocatorTextContentOptions{
-		Timeout: playwright.Float(1000),
-	})
+// seller reads the seller's username and profile link
+func seller(page playwright.Page, sel Selectors) (string, string, error) {
+	link := page.Locator(sel.Seller).First()
+	name, err := link.TextContent(playwright.LocatorTextContentOptions{Timeout: playwright.Float(1000)})
 	if err != nil {
-		return nil, fmt.Errorf("\tcould not get restrictions: %v", err)
+		return "", "", fmt.Errorf("could not get seller: %v", err)
 	}
+	profile, err := link.GetAttribute("href", playwright.LocatorGetAttributeOptions{Timeout: playwright.Float(1000)})
+	if err != nil {
+		return "", "", fmt.Errorf("could not get seller profile: %v", err)
+	}
+	return strings.TrimSpace(name), profile, nil
+}
 
-	restrictions = strings.Split(restrictions, "Phone Number:")[0]
+func viewCount(page playwright.Page, sel Selectors) (int, error) {
+	text, err := page.Locator(sel.detailLabel("View Count")).TextContent(playwright.LocatorTextContentOptions{Timeout: playwright.Float(1000)})
+	if err != nil {
+		return 0, fmt.Errorf("could not get view count: %v", err)
+	}
+	return parseViewCount(text)
+}
 
-	details.SellerType = listing.ParseSellerType(parseItemDetail(sellerType, "Seller Type:"))
-	details.OriginalPostDate = postDate
-	details.Description = description
-	details.Restrictions = parseItemDetail(restrictions, "Restrictions:")
+var viewCountRegex = regexp.MustCompile(`View Count:\s*([\d,]+)`)
 
-	return &details, nil
+// parseViewCount reads the view count from the detail column holding it,
+// such as "View Count: 1,248"
+func parseViewCount(text string) (int, error) {
+	matches := viewCountRegex.FindStringSubmatch(text)
+	if len(matches) < 2 {
+		return 0, fmt.Errorf("could not find view count in string: %s", text)
+	}
+	views, err := strconv.Atoi(strings.ReplaceAll(matches[1], ",", ""))
+	if err != nil {
+		return 0, fmt.Errorf("could not parse view count: %v", err)
+	}
+	return views, nil
 }
 
-func getListingsUrl(urlBase string, bikeType BikeType) string {
-	switch bikeType {
-	case Enduro:
-		return urlBase + "/?category=2"
-	case Trail:
-		return urlBase + "/?category=102"
-	case XC:
-		return urlBase + "/?category=75"
-	case DH:
-		return urlBase + "/?category=1"
-	default:
-		log.Fatalf("invalid bike type: %s", bikeType)
-		return ""
+func originalPostDate(page playwright.Page, sel Selectors) (time.Time, error) {
+	text, err := page.Locator(sel.detailLabel("Original Post Date")).TextContent(playwright.LocatorTextContentOptions{Timeout: playwright.Float(1000)})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not get original post date: %v", err)
 	}
-}
 
-// todo implement an auto-dedupe function that will compare each parsed listing from the page and will not add it to the list if it already exists
+	dateRegex := regexp.MustCompile(`Original Post Date:\s*((?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec)-\d{2}-\d{4})`)
+	matches := dateRegex.FindStringSubmatch(text)
+	if len(matches) < 2 {
+		return time.Time{}, fmt.Errorf("could not find date in string: %s", text)
+	}
 
-func scrapePage(page playwright.Page) ([]listing.RawListing, string, error) {
-	entries, err := page.Locator("tr.bsitem-table").All()
+	postDate, err := time.Parse("Jan-02-2006", matches[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not parse original post date: %v", err)
+	}
+	return postDate, nil
+}
+
+// scrapePage reads every listing row of a listings page. Rows with fields that
+// cannot be read are saved to debug under pageName, along with the whole page.
+func scrapePage(page playwright.Page, sel Selectors, report *ScrapeReport, debug *debugCapture, pageName string) ([]listing.RawListing, string, error) {
+	entries, err := page.Locator(sel.Entry).All()
 	if err != nil {
+		debug.capturePage(page, pageName, "")
 		return nil, "", fmt.Errorf("could not get entries: %v", err)
 	}
 
 	var sanitizedListings []listing.RawListing
-	for _, entry := range entries {
-		sanitizedListings = append(sanitizedListings, getListing(entry))
+	failed := false
+	for i, entry := range entries {
+		failures := len(report.Errors)
+		l := getListing(entry, sel, report)
+		if len(report.Errors) > failures {
+			ref := l.URL
+			if ref == "" {
+				ref = fmt.Sprintf("row-%d", i+1)
+			}
+			debug.captureEntry(entry, pageName, ref)
+			failed = true
+		}
+		sanitizedListings = append(sanitizedListings, l)
+	}
+	if failed {
+		debug.capturePage(page, pageName, "")
 	}
 
 	// Find the "Next Page" link
-	nextPageLink := page.Locator(`xpath=//a[text()='Next']`)
+	nextPageLink := page.Locator(sel.NextPage)
 
 	// Get the URL of the "Next Page" link
 	nextPageURL, err := nextPageLink.GetAttribute("href")
@@ -286,57 +619,48 @@ func scrapePage(page playwright.Page) ([]listing.RawListing, string, error) {
 	return sanitizedListings, nextPageURL, nil
 }
 
-func getListing(entry playwright.Locator) listing.RawListing {
-	titleElement := entry.Locator("div.bsitem-title > a")
-	title, err := titleElement.TextContent()
+// getListing reads a listing row. Fields that cannot be read are left empty and
+// recorded in report against the listing URL.
+func getListing(entry playwright.Locator, sel Selectors, report *ScrapeReport) listing.RawListing {
+	titleElement := entry.Locator(sel.Title)
+	url, err := titleElement.GetAttribute("href")
 	if err != nil {
-		fmt.Println("\tcould not get title")
+		report.Add("", "url", fmt.Errorf("could not get url: %v", err))
 	}
-	title = strings.ReplaceAll(title, "\n", "")
+	link := url
 
-	link, err := titleElement.GetAttribute("href")
-	if err != nil {
-		fmt.Println("\tcould not get title")
-	}
-
-	url, err := entry.Locator("div.bsitem-title > a").GetAttribute("href")
-	if err != nil {
-		fmt.Println("\tcould not get url")
-	}
-
-	condition, err := entry.Locator(`xpath=./descendant::div[b[contains(text(), "Condition")]]`).InnerText(playwright.LocatorInnerTextOptions{Timeout: playwright.Float(1000)})
-	if err != nil {
-		fmt.Println("\tcould not get condition")
-	}
-
-	frameSize, err := entry.Locator(`xpath=./descendant::div[b[contains(text(), "Frame Size")]]`).TextContent(playwright.LocatorTextContentOptions{Timeout: playwright.Float(1000)})
+	title, err := titleElement.TextContent()
 	if err != nil {
-		fmt.Println("\tcould not get frame size")
+		report.Add(url, "title", fmt.Errorf("could not get title: %v", err))
 	}
+	title = strings.ReplaceAll(title, "\n", "")
 
-	wheelSize, err := entry.Locator(`xpath=./descendant::div[b[contains(text(), "Wheel Size")]]`).TextContent(playwright.LocatorTextContentOptions{Timeout: playwright.Float(1000)})
-	if err != nil {
-		fmt.Println("\tcould not get wheel size")
+	detail := func(field, label string) string {
+		text, err := entry.Locator(sel.label(label)).TextContent(playwright.LocatorTextContentOptions{Timeout: playwright.Float(1000)})
+		if err != nil {
+			report.Add(url, field, fmt.Errorf("could not get %s: %v", strings.ToLower(label), err))
+		}
+		return text
 	}
 
-	frontTravel, err := entry.Locator(`xpath=./descendant::div[b[contains(text(), "Front Travel")]]`).TextContent(playwright.LocatorTextContentOptions{Timeout: playwright.Float(1000)})
+	condition, err := entry.Locator(sel.label("Condition")).InnerText(playwright.LocatorInnerTextOptions{Timeout: playwright.Float(1000)})
 	if err != nil {
-		fmt.Println("\tcould not get front travel")
+		report.Add(url, "condition", fmt.Errorf("could not get condition: %v", err))
 	}
 
-	rearTravel, err := entry.Locator(`xpath=./descendant::div[b[contains(text(), "Rear Travel")]]`).TextContent(playwright.LocatorTextContentOptions{Timeout: playwright.Float(1000)})
-	if err != nil {
-		fmt.Println("\tcould not get rear travel")
-	}
+	frameSize := detail("frameSize", "Frame Size")
+	wheelSize := detail("wheelSize", "Wheel Size")
+	frontTravel := detail("frontTravel", "Front Travel")
+	rearTravel := detail("rearTravel", "Rear Travel")
+	material := detail("frameMaterial", "Material")
 
-	material, err := entry.Locator(`xpath=./descendant::div[b[contains(text(), "Material")]]`).TextContent(playwright.LocatorTextContentOptions{Timeout: playwright.Float(1000)})
+	price, err := entry.Locator(sel.Price).TextContent(playwright.LocatorTextContentOptions{Timeout: playwright.Float(1000)})
 	if err != nil {
-		fmt.Println("\tcould not get material")
+		report.Add(url, "price", fmt.Errorf("could not get price: %v", err))
 	}
-
-	price, err := entry.Locator("td.bsitem-price > b").TextContent(playwright.LocatorTextContentOptions{Timeout: playwright.Float(1000)})
+	location, err := entry.Locator(sel.Location).TextContent(playwright.LocatorTextContentOptions{Timeout: playwright.Float(1000)})
 	if err != nil {
-		fmt.Println("\tcould not get price")
+		report.Add(url, "location", fmt.Errorf("could not get location: %v", err))
 	}
 
 	l := listing.RawListing{
@@ -350,36 +674,8 @@ func getListing(entry playwright.Locator) listing.RawListing {
 		FrameMaterial: material,
 		URL:           url,
 		DetailsLink:   link,
+		Location:      location,
 	}
 
-	return sanitize(l)
-}
-
-// Sanitize will remove spaces and labels from the listing
-func sanitize(l listing.RawListing) listing.RawListing {
-	newL := listing.RawListing{}
-
-	newL.Title = strings.TrimSpace(l.Title)
-	newL.Price = strings.TrimSpace(l.Price)
-	newL.Condition = parseItemDetail(l.Condition, "Condition :")
-	newL.FrameSize = parseItemDetail(l.FrameSize, "Frame Size :")
-	newL.WheelSize = parseItemDetail(l.WheelSize, "Wheel Size :")
-	newL.FrontTravel = parseItemDetail(l.FrontTravel, "Front Travel :")
-	newL.RearTravel = parseItemDetail(l.RearTravel, "Rear Travel :")
-	newL.FrameMaterial = parseItemDetail(l.FrameMaterial, "Material :")
-	newL.URL = strings.TrimSpace(l.URL)
-
-	return newL
-}
-
-func parseItemDetail(detail, label string) string {
-	split := strings.Split(detail, label)
-	if len(split) < 2 {
-		return ""
-	}
-
-	s := strings.TrimSpace(split[1])
-	s = strings.ReplaceAll(s, `"`, "")
-
-	return s
+	return l.Sanitize()
 }
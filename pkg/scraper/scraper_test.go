@@ -3,6 +3,7 @@ package scraper
 import (
 	_ "embed"
 	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/parser"
 	"strings"
 	"testing"
 	"time"
@@ -48,17 +49,22 @@ func TestDetailsScrapeWithHTML(t *testing.T) {
 	require.NoError(t, err)
 
 	// Create a scraper instance
-	s := &Scraper{}
+	s := &Scraper{opts: ScrapeOptions{Selectors: DefaultSelectors()}}
 
 	// Test the detailsScrape function
-	details, err := s.detailsScrape(page)
-	require.NoError(t, err)
+	var report ScrapeReport
+	details := s.detailsScrape(page, "", &report)
+	require.Empty(t, report.Errors)
 
 	// Assert the expected values
 	assert.Equal(t, "business", string(details.SellerType))
 	expectedDate, _ := time.Parse("2006-01-02", "2024-09-05")
 	assert.Equal(t, expectedDate, details.OriginalPostDate)
 	assert.Equal(t, "Firm, No Trades, Local pickup only", details.Restrictions)
+	assert.Equal(t, 12, details.PhotoCount)
+	assert.Equal(t, 1248, details.ViewCount)
+	assert.Equal(t, "MountainAdventureEquipment", details.Seller)
+	assert.Equal(t, "https://www.pinkbike.com/u/MountainAdventureEquipment/", details.SellerURL)
 
 	expectedDesc := strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(expectedDetailedDescription, "\n", ""), "\t", ""), " ", "")
 
@@ -66,6 +72,19 @@ func TestDetailsScrapeWithHTML(t *testing.T) {
 	assert.Equal(t, expectedDesc, actualDesc)
 }
 
+func TestParseViewCount(t *testing.T) {
+	views, err := parseViewCount("Original Post Date: Sep-05-2024 9:50:18 View Count: 1,248 Watch Count: 12")
+	require.NoError(t, err)
+	assert.Equal(t, 1248, views)
+
+	views, err = parseViewCount("View Count:\n  87")
+	require.NoError(t, err)
+	assert.Equal(t, 87, views)
+
+	_, err = parseViewCount("Watch Count: 12")
+	assert.Error(t, err)
+}
+
 func TestPerformWebScraping(t *testing.T) {
 	page := setupPlaywright(t)
 
@@ -75,33 +94,39 @@ func TestPerformWebScraping(t *testing.T) {
 
 	s := &Scraper{
 		page: page,
+		opts: ScrapeOptions{Selectors: DefaultSelectors()},
 	}
 
-	listings, err := s.PerformWebScraping(1)
+	listings, report, err := s.PerformWebScraping(1)
 	require.NoError(t, err)
+	assert.Empty(t, report.Errors)
 
 	require.Equal(t, 20, len(listings))
 
 	refinedListings := []listing.Listing{}
 	for _, l := range listings {
-		list := l.PostProcess(1.0)
+		list := l.PostProcess(1.0, nil)
+		list.Metadata = nil
 		refinedListings = append(refinedListings, list)
 	}
 
 	assert.Equal(t, refinedListings[17], listing.Listing{
-		Title:         "2022                                                                NEW Scott Contessa Spark 920, size S, 29.52lbs",
-		Year:          "2022",
-		Manufacturer:  "Scott",
-		Model:         "Spark",
-		Price:         "3300",
-		Currency:      "USD",
-		Condition:     "New - Unridden/With Tags",
-		FrameSize:     "S",
-		WheelSize:     "29",
-		FrameMaterial: "Carbon Fiber",
-		FrontTravel:   "130 mm",
-		RearTravel:    "120 mm",
-		URL:           "https://www.pinkbike.com/buysell/3960926/",
+		Title:          "2022 NEW Scott Contessa Spark 920, size S, 29.52lbs",
+		Year:           "2022",
+		Manufacturer:   "Scott",
+		Model:          "Spark",
+		Price:          "3300",
+		Currency:       "USD",
+		Condition:      "New - Unridden/With Tags",
+		FrameSize:      "S",
+		NormalizedSize: "S",
+		ConditionGrade: parser.ConditionNew,
+		WheelSize:      "29",
+		FrameMaterial:  "Carbon Fiber",
+		FrontTravel:    "130 mm",
+		RearTravel:     "120 mm",
+		URL:            "https://www.pinkbike.com/buysell/3960926/",
+		ListingID:      3960926,
 	})
 }
 
@@ -131,3 +156,38 @@ Seatpost: OC Mountain Control MC21, 31.6mm, Dropper
 Saddle: Ergon SM Enduro
 
 24OOM1`
+
+func TestCountKnownStreak(t *testing.T) {
+	known := listing.RawListing{Title: "2021 Evil Wreckoning", Condition: "Excellent - Lightly Ridden", URL: "https://www.pinkbike.com/buysell/3861316/"}
+	unknown := listing.RawListing{Title: "2020 Kona Process 153", Condition: "Good - Used, Mechanically Sound"}
+	knownFingerprint := known.PostProcess(1.0, nil).Fingerprint()
+	edited := known
+	edited.Title = "2021 Evil Wreckoning, price drop"
+
+	exists := func(fingerprint string) (bool, error) {
+		return fingerprint == knownFingerprint, nil
+	}
+
+	tests := []struct {
+		name       string
+		listings   []listing.RawListing
+		streak     int
+		wantStreak int
+		wantStop   bool
+	}{
+		{"No known listings", []listing.RawListing{unknown, unknown}, 0, 0, false},
+		{"Unknown listing resets streak", []listing.RawListing{known, unknown, known}, 0, 1, false},
+		{"Threshold reached", []listing.RawListing{known, known, known, unknown}, 0, 3, true},
+		{"Streak carried across pages", []listing.RawListing{known}, 2, 3, true},
+		{"Edited title is still known", []listing.RawListing{edited, known}, 0, 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streak, stop, err := countKnownStreak(tt.listings, tt.streak, 3, exists)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStreak, streak)
+			assert.Equal(t, tt.wantStop, stop)
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// SelectorsVersion is the version of the built-in selectors. Bump it whenever
+// they change to follow a Pinkbike layout change, so a failed self-check names
+// the selector set that no longer matches.
+const SelectorsVersion = 1
+
+// Selectors locate listing data in Pinkbike's markup. Each is a Playwright
+// selector; XPath ones start with "xpath=". Label selectors contain %s where
+// the label text, such as "Frame Size", is substituted.
+type Selectors struct {
+	Version int `json:"version"`
+
+	// Entry matches each listing row on a listings page
+	Entry string `json:"entry"`
+	// Title, Label and Price are relative to an entry
+	Title string `json:"title"`
+	Label string `json:"label"`
+	Price string `json:"price"`
+	// Location is the seller's city, region and country under the flag
+	Location string `json:"location"`
+	// NextPage is the link to the next listings page
+	NextPage string `json:"nextPage"`
+
+	// DetailLabel, Description and Restrictions are on a listing's detail page
+	DetailLabel  string `json:"detailLabel"`
+	Description  string `json:"description"`
+	Restrictions string `json:"restrictions"`
+	// Photos matches each photo thumbnail. Listings with a single photo have
+	// no thumbnails, only MainPhoto.
+	Photos    string `json:"photos"`
+	MainPhoto string `json:"mainPhoto"`
+	// Seller links to the seller's profile, with their username as its text
+	Seller string `json:"seller"`
+	// Phone holds the seller's phone number once PhoneReveal is clicked,
+	// which only works when signed in
+	Phone       string `json:"phone"`
+	PhoneReveal string `json:"phoneReveal"`
+
+	// SignedIn matches an element only shown to a signed in account. The
+	// login selectors are the fields of the sign in form.
+	SignedIn      string `json:"signedIn"`
+	LoginUsername string `json:"loginUsername"`
+	LoginPassword string `json:"loginPassword"`
+	LoginSubmit   string `json:"loginSubmit"`
+}
+
+// DefaultSelectors returns the selectors for the current Pinkbike layout
+func DefaultSelectors() Selectors {
+	return Selectors{
+		Version:       SelectorsVersion,
+		Entry:         "tr.bsitem-table",
+		Title:         "div.bsitem-title > a",
+		Label:         `xpath=./descendant::div[b[contains(text(), "%s")]]`,
+		Price:         "td.bsitem-price > b",
+		Location:      `xpath=./descendant::table[contains(@class, "bsitem-details")]//td[img[contains(@class, "flag")]]`,
+		NextPage:      `xpath=//a[text()='Next']`,
+		DetailLabel:   `xpath=//div[contains(@class, "buysell-details-column")]//b[contains(text(), "%s")]/parent::*`,
+		Description:   `xpath=//div[contains(@class, 'buysell-container description')]`,
+		Restrictions:  `.buysell-container-right.buysell-restrictions .buysell-container`,
+		Photos:        `.buysell-images-container .buysell-thumbnailimage`,
+		MainPhoto:     `#buysell-image`,
+		Seller:        `.buysell-profileinfo a[rel="author"]`,
+		Phone:         `#phoneAd`,
+		PhoneReveal:   `#phoneAd a.phoneAd`,
+		SignedIn:      `#login a[href*="x_logout"]`,
+		LoginUsername: `form input[name="username"]`,
+		LoginPassword: `form input[type="password"]`,
+		LoginSubmit:   `form [type="submit"]`,
+	}
+}
+
+// LoadSelectors reads selectors from a JSON file. Selectors the file leaves
+// out keep their built-in values, so a fix for one layout change only needs
+// to list the selectors that changed.
+func LoadSelectors(path string) (Selectors, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Selectors{}, fmt.Errorf("could not read selectors: %v", err)
+	}
+
+	s := DefaultSelectors()
+	s.Version = 0
+	if err := json.Unmarshal(data, &s); err != nil {
+		return Selectors{}, fmt.Errorf("could not parse selectors %s: %v", path, err)
+	}
+	if s.Version == 0 {
+		return Selectors{}, fmt.Errorf("selectors %s have no version", path)
+	}
+	if err := s.validate(); err != nil {
+		return Selectors{}, fmt.Errorf("invalid selectors %s: %v", path, err)
+	}
+	return s, nil
+}
+
+func (s Selectors) validate() error {
+	for _, label := range []struct{ name, selector string }{{"label", s.Label}, {"detailLabel", s.DetailLabel}} {
+		if strings.Count(label.selector, "%s") != 1 {
+			return fmt.Errorf("%s selector must contain %%s once for the label text", label.name)
+		}
+	}
+	for name, selector := range map[string]string{
+		"entry": s.Entry, "title": s.Title, "price": s.Price, "location": s.Location, "nextPage": s.NextPage,
+		"description": s.Description, "restrictions": s.Restrictions,
+		"photos": s.Photos, "mainPhoto": s.MainPhoto, "seller": s.Seller,
+		"phone": s.Phone, "phoneReveal": s.PhoneReveal, "signedIn": s.SignedIn,
+		"loginUsername": s.LoginUsername, "loginPassword": s.LoginPassword, "loginSubmit": s.LoginSubmit,
+	} {
+		if strings.TrimSpace(selector) == "" {
+			return fmt.Errorf("%s selector is empty", name)
+		}
+	}
+	return nil
+}
+
+func (s Selectors) label(text string) string {
+	return fmt.Sprintf(s.Label, text)
+}
+
+func (s Selectors) detailLabel(text string) string {
+	return fmt.Sprintf(s.DetailLabel, text)
+}
+
+// selectorCheck is one selector the self-check expects to match
+type selectorCheck struct {
+	name     string
+	selector string
+	// optional checks are reported but do not fail the self-check, for
+	// elements not every listing has
+	optional bool
+	count    func() (int, error)
+}
+
+// SelectorResult is the outcome of checking one selector against a live page
+type SelectorResult struct {
+	Name     string
+	Selector string
+	Matches  int
+	Optional bool
+	Err      error
+}
+
+func (r SelectorResult) ok() bool {
+	return r.Err == nil && r.Matches > 0
+}
+
+// LayoutError reports that the selectors no longer match Pinkbike's pages,
+// listing every selector checked so the broken ones can be fixed in a
+// selectors file
+type LayoutError struct {
+	Version int
+	URL     string
+	Results []SelectorResult
+}
+
+func (e *LayoutError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Pinkbike layout no longer matches selectors version %d (checked %s):\n", e.Version, e.URL)
+	for _, r := range e.Results {
+		status := "ok"
+		switch {
+		case r.Err != nil:
+			status = fmt.Sprintf("error: %v", r.Err)
+		case r.Matches == 0 && r.Optional:
+			status = "no match (optional)"
+		case r.Matches == 0:
+			status = "NO MATCH"
+		}
+		fmt.Fprintf(&b, "  %-13s %-20s %s\n", r.Name, status, r.Selector)
+	}
+	b.WriteString("Override the broken selectors with -selectors and a JSON file of the new ones")
+	return b.String()
+}
+
+// runChecks runs every check, returning a LayoutError when a required
+// selector matched nothing
+func runChecks(version int, url string, checks []selectorCheck) error {
+	layoutErr := &LayoutError{Version: version, URL: url}
+	failed := false
+	for _, c := range checks {
+		n, err := c.count()
+		r := SelectorResult{Name: c.name, Selector: c.selector, Matches: n, Optional: c.optional, Err: err}
+		layoutErr.Results = append(layoutErr.Results, r)
+		if !r.ok() && !c.optional {
+			failed = true
+		}
+	}
+	if failed {
+		return layoutErr
+	}
+	return nil
+}
+
+// SelfCheck validates the selectors against the first listings page and the
+// detail page of its first listing. It returns a *LayoutError describing
+// every selector when the layout has changed.
+func (s *Scraper) SelfCheck() error {
+	if err := s.openListings(); err != nil {
+		return err
+	}
+	sel := s.opts.Selectors
+	entries := s.page.Locator(sel.Entry)
+	first := entries.First()
+	count := func(l playwright.Locator) func() (int, error) {
+		return l.Count
+	}
+
+	checks := []selectorCheck{
+		{name: "entry", selector: sel.Entry, count: count(entries)},
+		{name: "title", selector: sel.Title, count: count(first.Locator(sel.Title))},
+		{name: "price", selector: sel.Price, count: count(first.Locator(sel.Price))},
+		{name: "location", selector: sel.Location, optional: true, count: count(first.Locator(sel.Location))},
+		{name: "nextPage", selector: sel.NextPage, optional: true, count: count(s.page.Locator(sel.NextPage))},
+	}
+	for _, label := range []string{"Condition", "Frame Size", "Wheel Size", "Material"} {
+		checks = append(checks, selectorCheck{name: "label", selector: sel.label(label), count: count(first.Locator(sel.label(label)))})
+	}
+	if err := runChecks(sel.Version, s.page.URL(), checks); err != nil {
+		return err
+	}
+
+	url, err := first.Locator(sel.Title).GetAttribute("href")
+	if err != nil {
+		return fmt.Errorf("could not get first listing url: %v", err)
+	}
+	page, err := s.context.NewPage()
+	if err != nil {
+		return fmt.Errorf("could not create page: %v", err)
+	}
+	defer page.Close()
+	if _, err := visit(page, url); err != nil {
+		return err
+	}
+
+	checks = []selectorCheck{
+		{name: "detailLabel", selector: sel.detailLabel("Seller Type"), count: count(page.Locator(sel.detailLabel("Seller Type")))},
+		{name: "detailLabel", selector: sel.detailLabel("Original Post Date"), count: count(page.Locator(sel.detailLabel("Original Post Date")))},
+		{name: "description", selector: sel.Description, count: count(page.Locator(sel.Description))},
+		{name: "restrictions", selector: sel.Restrictions, optional: true, count: count(page.Locator(sel.Restrictions))},
+		{name: "mainPhoto", selector: sel.MainPhoto, count: count(page.Locator(sel.MainPhoto))},
+		{name: "seller", selector: sel.Seller, count: count(page.Locator(sel.Seller))},
+		{name: "detailLabel", selector: sel.detailLabel("View Count"), optional: true, count: count(page.Locator(sel.detailLabel("View Count")))},
+	}
+	if s.opts.Login != nil {
+		checks = append(checks,
+			selectorCheck{name: "signedIn", selector: sel.SignedIn, count: count(page.Locator(sel.SignedIn))},
+			selectorCheck{name: "phone", selector: sel.Phone, optional: true, count: count(page.Locator(sel.Phone))},
+		)
+	}
+	return runChecks(sel.Version, url, checks)
+}
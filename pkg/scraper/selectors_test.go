@@ -0,0 +1,52 @@
+package scraper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSelectors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "selectors.json")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"version": 2, "price": "div.price"}`), 0644))
+	s, err := LoadSelectors(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.Version)
+	assert.Equal(t, "div.price", s.Price)
+	assert.Equal(t, DefaultSelectors().Entry, s.Entry, "selectors left out keep their defaults")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"price": "div.price"}`), 0644))
+	_, err = LoadSelectors(path)
+	assert.ErrorContains(t, err, "no version")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"version": 2, "label": "div.label"}`), 0644))
+	_, err = LoadSelectors(path)
+	assert.ErrorContains(t, err, "label selector must contain %s")
+}
+
+func TestRunChecks(t *testing.T) {
+	matches := func(n int) func() (int, error) {
+		return func() (int, error) { return n, nil }
+	}
+
+	ok := []selectorCheck{
+		{name: "entry", selector: "tr.bsitem-table", count: matches(20)},
+		{name: "nextPage", selector: "a.next", optional: true, count: matches(0)},
+	}
+	assert.NoError(t, runChecks(1, "https://www.pinkbike.com/buysell/list/", ok))
+
+	broken := append(ok, selectorCheck{name: "price", selector: "td.bsitem-price > b", count: matches(0)})
+	err := runChecks(1, "https://www.pinkbike.com/buysell/list/", broken)
+
+	var layoutErr *LayoutError
+	require.True(t, errors.As(err, &layoutErr))
+	assert.Len(t, layoutErr.Results, 3)
+	assert.Contains(t, err.Error(), "selectors version 1")
+	assert.Regexp(t, `price\s+NO MATCH\s+td.bsitem-price > b`, err.Error())
+	assert.Contains(t, err.Error(), "no match (optional)")
+}
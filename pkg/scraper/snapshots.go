@@ -0,0 +1,140 @@
+package scraper
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// Snapshots keeps the HTML of every listings and detail page a scrape
+// visits, so the scrape can be replayed without the network. A nil Snapshots
+// neither saves nor replays pages.
+type Snapshots struct {
+	Dir string
+	// Replay serves pages from Dir instead of saving them and aborts every
+	// other request, so the browser never reaches the network. Pages without
+	// a snapshot answer 404.
+	Replay bool
+
+	missing atomic.Int64
+}
+
+// Missing returns how many pages a replay had no snapshot of
+func (s *Snapshots) Missing() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.missing.Load()
+}
+
+// Path returns the file the page at rawURL is saved to. Names start with the
+// page's path for people browsing the directory and end with a hash of the
+// whole URL so pages differing only in their query are kept apart.
+func (s *Snapshots) Path(rawURL string) string {
+	sum := sha1.Sum([]byte(rawURL))
+	return filepath.Join(s.Dir, snapshotName(rawURL)+"-"+hex.EncodeToString(sum[:4])+".html")
+}
+
+// snapshotName is the readable part of the names of the snapshots of rawURL
+func snapshotName(rawURL string) string {
+	name := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		name = u.Host + u.Path
+	}
+	name = strings.Trim(unsafeNameChars.ReplaceAllString(name, "_"), "_")
+	if len(name) > 80 {
+		name = name[:80]
+	}
+	return name
+}
+
+// Forget deletes the snapshots of the page at rawURL, whatever query it was
+// visited with, and returns the files deleted, or those that would be on a
+// dry run. Listings pages showing the listing among others are kept.
+func (s *Snapshots) Forget(rawURL string, dryRun bool) ([]string, error) {
+	name := snapshotName(rawURL)
+	return removeFiles(s.Dir, func(file string) bool {
+		// the name is followed by 8 hex digits of the URL's hash
+		return strings.HasPrefix(file, name+"-") && strings.HasSuffix(file, ".html") && len(file) == len(name)+len("-01234567.html")
+	}, dryRun)
+}
+
+// removeFiles deletes the files in dir whose names match and returns their
+// paths. A directory that does not exist holds nothing to delete.
+func removeFiles(dir string, match func(name string) bool, dryRun bool) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %v", dir, err)
+	}
+	var removed []string
+	for _, e := range entries {
+		if e.IsDir() || !match(e.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if !dryRun {
+			if err := os.Remove(path); err != nil {
+				return removed, fmt.Errorf("could not delete %s: %v", path, err)
+			}
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// save writes the HTML page holds after loading rawURL, unless replaying
+func (s *Snapshots) save(page playwright.Page, rawURL string) error {
+	if s == nil || s.Replay {
+		return nil
+	}
+	content, err := page.Content()
+	if err != nil {
+		return fmt.Errorf("could not read page content: %v", err)
+	}
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return fmt.Errorf("could not create snapshot directory: %v", err)
+	}
+	if err := os.WriteFile(s.Path(rawURL), []byte(content), 0644); err != nil {
+		return fmt.Errorf("could not save snapshot: %v", err)
+	}
+	return nil
+}
+
+// install routes every request of the context to the snapshots when
+// replaying. It must be installed after resource blocking, as the routes
+// installed last are asked first.
+func (s *Snapshots) install(ctx playwright.BrowserContext) error {
+	if s == nil || !s.Replay {
+		return nil
+	}
+	return ctx.Route("**/*", func(route playwright.Route) {
+		req := route.Request()
+		// errors here mean the page went away while the request was routed
+		if req.ResourceType() != "document" {
+			_ = route.Abort("internetdisconnected")
+			return
+		}
+		content, err := os.ReadFile(s.Path(req.URL()))
+		if errors.Is(err, os.ErrNotExist) {
+			s.missing.Add(1)
+			_ = route.Fulfill(playwright.RouteFulfillOptions{Status: playwright.Int(404), Body: "no snapshot of " + req.URL()})
+			return
+		}
+		if err != nil {
+			_ = route.Abort("failed")
+			return
+		}
+		_ = route.Fulfill(playwright.RouteFulfillOptions{ContentType: playwright.String("text/html; charset=utf-8"), Body: content})
+	})
+}
@@ -0,0 +1,139 @@
+package scraper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/playwright-community/playwright-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/listing"
+)
+
+func TestSnapshotPath(t *testing.T) {
+	s := &Snapshots{Dir: "snapshots"}
+
+	page1 := s.Path("https://www.pinkbike.com/buysell/list/?category=2")
+	assert.Equal(t, "snapshots", filepath.Dir(page1))
+	assert.True(t, strings.HasPrefix(filepath.Base(page1), "www.pinkbike.com_buysell_list-"), page1)
+	assert.Equal(t, page1, s.Path("https://www.pinkbike.com/buysell/list/?category=2"))
+	assert.NotEqual(t, page1, s.Path("https://www.pinkbike.com/buysell/list/?category=2&page=2"))
+
+	details := s.Path("https://www.pinkbike.com/buysell/3958041/")
+	assert.True(t, strings.HasPrefix(filepath.Base(details), "www.pinkbike.com_buysell_3958041-"), details)
+	assert.Less(t, len(filepath.Base(s.Path("https://www.pinkbike.com/"+strings.Repeat("a", 300)))), 100)
+}
+
+func TestNilSnapshots(t *testing.T) {
+	var s *Snapshots
+	assert.Zero(t, s.Missing())
+	assert.NoError(t, s.save(nil, "https://www.pinkbike.com/buysell/3958041/"))
+	assert.NoError(t, s.install(nil))
+}
+
+func TestForgetSnapshots(t *testing.T) {
+	s := &Snapshots{Dir: t.TempDir()}
+	listing := s.Path("https://www.pinkbike.com/buysell/3958041/")
+	tracked := s.Path("https://www.pinkbike.com/buysell/3958041/?ref=list")
+	other := s.Path("https://www.pinkbike.com/buysell/39580410/")
+	for _, path := range []string{listing, tracked, other} {
+		require.NoError(t, os.WriteFile(path, []byte("<html></html>"), 0644))
+	}
+
+	removed, err := s.Forget("https://www.pinkbike.com/buysell/3958041/", true)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{listing, tracked}, removed)
+	assert.FileExists(t, listing, "a dry run deletes nothing")
+
+	_, err = s.Forget("https://www.pinkbike.com/buysell/3958041/", false)
+	require.NoError(t, err)
+	assert.NoFileExists(t, listing)
+	assert.NoFileExists(t, tracked)
+	assert.FileExists(t, other)
+
+	removed, err = (&Snapshots{Dir: filepath.Join(s.Dir, "missing")}).Forget("https://www.pinkbike.com/buysell/3958041/", false)
+	assert.NoError(t, err)
+	assert.Empty(t, removed)
+}
+
+// TestReplaySnapshots scrapes the trail listings page and the detail page of
+// one of its listings from testdata/snapshots. Replays never download, so the
+// test is skipped where Playwright is not installed.
+func TestReplaySnapshots(t *testing.T) {
+	snapshots := &Snapshots{Dir: filepath.Join("testdata", "snapshots"), Replay: true}
+	trail, err := LookupBikeType(string(Trail))
+	require.NoError(t, err)
+	require.FileExists(t, snapshots.Path(trail.ListingsURL(DefaultBaseURL)))
+	require.FileExists(t, snapshots.Path("https://www.pinkbike.com/buysell/3918904/"))
+
+	driver, err := playwright.NewDriver(&playwright.RunOptions{})
+	require.NoError(t, err)
+	if _, err := os.Stat(driver.DriverBinaryLocation); err != nil {
+		t.Skip("Playwright is not installed")
+	}
+
+	db, err := exporter.NewDBExporter(filepath.Join(t.TempDir(), "listings.db"), nil, exporter.DefaultDBOptions())
+	require.NoError(t, err)
+	defer db.Close()
+
+	opts := DefaultScrapeOptions()
+	opts.BikeType = trail
+	opts.Headless = true
+	opts.DB = db
+	opts.Snapshots = snapshots
+	s, err := NewScraper(opts)
+	require.NoError(t, err)
+	defer s.Close()
+	require.NoError(t, s.start())
+
+	var mu sync.Mutex
+	var failed []playwright.Request
+	s.context.OnRequestFailed(func(req playwright.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		failed = append(failed, req)
+	})
+
+	raw, report, err := s.PerformWebScraping(1)
+	require.NoError(t, err)
+	assert.Empty(t, report.Errors)
+	require.Len(t, raw, 20)
+	assert.Zero(t, snapshots.Missing())
+
+	var listings []listing.Listing
+	for _, r := range raw {
+		l := r.PostProcess(1, nil)
+		// the Orbea has a snapshot of its detail page, the Scott has none
+		if l.ListingID == 3918904 || l.ListingID == 3960926 {
+			listings = append(listings, l)
+		}
+	}
+	require.Len(t, listings, 2)
+
+	detailed, report, err := s.FetchListingDetails(listings)
+	require.NoError(t, err)
+	for _, l := range detailed {
+		if l.ListingID == 3918904 {
+			assert.Equal(t, "MountainAdventureEquipment", l.Details.Seller)
+		} else {
+			assert.Empty(t, l.Details.Seller)
+		}
+	}
+	assert.Equal(t, int64(1), snapshots.Missing())
+	require.Len(t, report.Errors, 1)
+	assert.Equal(t, "https://www.pinkbike.com/buysell/3960926/", report.Errors[0].URL)
+	assert.ErrorContains(t, report.Errors[0].Err, "404")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.NotEmpty(t, failed, "the snapshots load images and scripts")
+	for _, req := range failed {
+		assert.NotEqual(t, "document", req.ResourceType(), req.URL())
+		assert.ErrorContains(t, req.Failure(), "ERR_INTERNET_DISCONNECTED", req.URL())
+	}
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"pinkbike-scraper/pkg/events"
+	"pinkbike-scraper/pkg/listing"
+)
+
+// heartbeat is how often an idle event stream sends a comment, so proxies do
+// not close it
+const heartbeat = 30 * time.Second
+
+// eventJSON is how a listing event is sent to stream clients
+type eventJSON struct {
+	Kind     events.Kind `json:"kind"`
+	Time     time.Time   `json:"time"`
+	OldPrice string      `json:"oldPrice,omitempty"`
+	Listing  listingJSON `json:"listing"`
+}
+
+type listingJSON struct {
+	Hash         string `json:"hash"`
+	Title        string `json:"title"`
+	Year         string `json:"year,omitempty"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Model        string `json:"model,omitempty"`
+	Price        string `json:"price"`
+	Currency     string `json:"currency,omitempty"`
+	URL          string `json:"url"`
+	Category     string `json:"category,omitempty"`
+	Size         string `json:"size,omitempty"`
+	Active       bool   `json:"active"`
+}
+
+func toJSON(e events.Event) eventJSON {
+	return eventJSON{
+		Kind:     e.Kind,
+		Time:     e.Time.UTC(),
+		OldPrice: e.OldPrice,
+		Listing:  toListingJSON(e.Listing),
+	}
+}
+
+func toListingJSON(l listing.Listing) listingJSON {
+	return listingJSON{
+		Hash:         l.Hash,
+		Title:        l.Title,
+		Year:         l.Year,
+		Manufacturer: l.Manufacturer,
+		Model:        l.Model,
+		Price:        l.Price,
+		Currency:     l.Currency,
+		URL:          l.URL,
+		Category:     l.Category,
+		Size:         l.NormalizedSize,
+		Active:       l.Active,
+	}
+}
+
+// broadcast hands an event to every connected stream. A stream too slow to
+// keep up misses events rather than holding up the exporter.
+func (s *Server) broadcast(e events.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.clients {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+	return nil
+}
+
+// eventFilter is the optional ?kind=, ?manufacturer= and ?model= query of an
+// event stream
+type eventFilter struct {
+	kinds               map[events.Kind]bool
+	manufacturer, model string
+}
+
+func parseEventFilter(r *http.Request) eventFilter {
+	q := r.URL.Query()
+	f := eventFilter{manufacturer: q.Get("manufacturer"), model: q.Get("model")}
+	for _, kind := range strings.Split(q.Get("kind"), ",") {
+		if kind = strings.TrimSpace(kind); kind != "" {
+			if f.kinds == nil {
+				f.kinds = map[events.Kind]bool{}
+			}
+			f.kinds[events.Kind(kind)] = true
+		}
+	}
+	return f
+}
+
+func (f eventFilter) matches(e events.Event) bool {
+	if f.kinds != nil && !f.kinds[e.Kind] {
+		return false
+	}
+	return matchesFold(f.manufacturer, e.Listing.Manufacturer) && matchesFold(f.model, e.Listing.Model)
+}
+
+func matchesFold(want, got string) bool {
+	return want == "" || strings.EqualFold(want, got)
+}
+
+// streamEvents sends listing events as server-sent events until the client
+// goes or the server is closed
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	filter := parseEventFilter(r)
+
+	ch := make(chan events.Event, 64)
+	s.mu.Lock()
+	s.clients[ch] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, ch)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case e := <-ch:
+			if !filter.matches(e) {
+				continue
+			}
+			data, err := json.Marshal(toJSON(e))
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Kind, data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,175 @@
+// Package server serves data from the listings database as a JSON API, for
+// dashboards and charts that should not read SQLite themselves
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"pinkbike-scraper/pkg/events"
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/priceindex"
+)
+
+// Store is the part of the listings database the API reads, and writes
+// favorites to
+type Store interface {
+	LatestIndexes() ([]priceindex.Point, error)
+	IndexSeries(name string) ([]priceindex.Point, error)
+	FavoriteListings() ([]listing.Listing, error)
+	// SetFavorite returns exporter.ErrListingNotFound for unknown listings
+	SetFavorite(hash string, favorite bool) error
+}
+
+// Server routes API requests to the store
+type Server struct {
+	store Store
+	mux   *http.ServeMux
+
+	mu      sync.Mutex
+	clients map[chan events.Event]struct{}
+	done    chan struct{}
+	close   sync.Once
+}
+
+// New returns a server with these routes:
+//
+//	GET /api/indexes                latest point of every price index
+//	GET /api/indexes/{name}         weekly points of one index, such as "all"
+//	GET /api/favorites              the listings marked as favorites
+//	PUT /api/favorites/{hash}       marks a listing as a favorite
+//	DELETE /api/favorites/{hash}    unmarks it
+//	GET /events                     server-sent stream of the listing events
+//	                                on bus, filtered by the optional kind,
+//	                                manufacturer and model query parameters
+func New(store Store, bus *events.Bus) *Server {
+	s := &Server{
+		store:   store,
+		mux:     http.NewServeMux(),
+		clients: map[chan events.Event]struct{}{},
+		done:    make(chan struct{}),
+	}
+	s.mux.HandleFunc("/api/indexes", s.indexes)
+	s.mux.HandleFunc("/api/indexes/", s.indexSeries)
+	s.mux.HandleFunc("/api/favorites", s.favorites)
+	s.mux.HandleFunc("/api/favorites/", s.setFavorite)
+	s.mux.HandleFunc("/events", s.streamEvents)
+	if bus != nil {
+		bus.Subscribe("event stream", s.broadcast)
+	}
+	return s
+}
+
+// Close ends the open event streams, which would otherwise hold up a graceful
+// shutdown until their clients go
+func (s *Server) Close() {
+	s.close.Do(func() { close(s.done) })
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// favorites are the only thing clients can change
+	if r.Method != http.MethodGet && r.Method != http.MethodHead && !strings.HasPrefix(r.URL.Path, "/api/favorites/") {
+		writeError(w, http.StatusMethodNotAllowed, "only GET is supported")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) indexes(w http.ResponseWriter, r *http.Request) {
+	points, err := s.store.LatestIndexes()
+	if err != nil {
+		log.Printf("could not load indexes: %v", err)
+		writeError(w, http.StatusInternalServerError, "could not load indexes")
+		return
+	}
+	writeJSON(w, http.StatusOK, nonNil(points))
+}
+
+func (s *Server) indexSeries(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/indexes/")
+	if name == "" {
+		s.indexes(w, r)
+		return
+	}
+
+	points, err := s.store.IndexSeries(name)
+	if err != nil {
+		log.Printf("could not load index %s: %v", name, err)
+		writeError(w, http.StatusInternalServerError, "could not load index")
+		return
+	}
+	if len(points) == 0 {
+		writeError(w, http.StatusNotFound, "no index named "+name)
+		return
+	}
+	writeJSON(w, http.StatusOK, points)
+}
+
+func (s *Server) favorites(w http.ResponseWriter, r *http.Request) {
+	favorites, err := s.store.FavoriteListings()
+	if err != nil {
+		log.Printf("could not load favorites: %v", err)
+		writeError(w, http.StatusInternalServerError, "could not load favorites")
+		return
+	}
+	listings := []listingJSON{}
+	for _, l := range favorites {
+		listings = append(listings, toListingJSON(l))
+	}
+	writeJSON(w, http.StatusOK, listings)
+}
+
+func (s *Server) setFavorite(w http.ResponseWriter, r *http.Request) {
+	hash := strings.TrimPrefix(r.URL.Path, "/api/favorites/")
+	if hash == "" {
+		s.favorites(w, r)
+		return
+	}
+
+	var favorite bool
+	switch r.Method {
+	case http.MethodPut:
+		favorite = true
+	case http.MethodDelete:
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "only PUT and DELETE are supported")
+		return
+	}
+
+	err := s.store.SetFavorite(hash, favorite)
+	if errors.Is(err, exporter.ErrListingNotFound) {
+		writeError(w, http.StatusNotFound, "no listing with hash "+hash)
+		return
+	}
+	if err != nil {
+		log.Printf("could not update favorite %s: %v", hash, err)
+		writeError(w, http.StatusInternalServerError, "could not update favorite")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// nonNil makes an empty result encode as [] rather than null
+func nonNil(points []priceindex.Point) []priceindex.Point {
+	if points == nil {
+		return []priceindex.Point{}
+	}
+	return points
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("could not write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
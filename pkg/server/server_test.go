@@ -0,0 +1,196 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pinkbike-scraper/pkg/events"
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/priceindex"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStore map[string][]priceindex.Point
+
+func (s fakeStore) LatestIndexes() ([]priceindex.Point, error) {
+	var latest []priceindex.Point
+	for _, points := range s {
+		latest = append(latest, points[len(points)-1])
+	}
+	return latest, nil
+}
+
+func (s fakeStore) IndexSeries(name string) ([]priceindex.Point, error) {
+	if name == "broken" {
+		return nil, fmt.Errorf("database is locked")
+	}
+	return s[strings.ToLower(name)], nil
+}
+
+func (s fakeStore) FavoriteListings() ([]listing.Listing, error) {
+	return nil, nil
+}
+
+func (s fakeStore) SetFavorite(hash string, favorite bool) error {
+	return exporter.ErrListingNotFound
+}
+
+// favoriteStore keeps favorites among a fixed set of listings
+type favoriteStore struct {
+	fakeStore
+	listings  []listing.Listing
+	favorites map[string]bool
+}
+
+func (s *favoriteStore) FavoriteListings() ([]listing.Listing, error) {
+	var favorites []listing.Listing
+	for _, l := range s.listings {
+		if s.favorites[l.Hash] {
+			favorites = append(favorites, l)
+		}
+	}
+	return favorites, nil
+}
+
+func (s *favoriteStore) SetFavorite(hash string, favorite bool) error {
+	for _, l := range s.listings {
+		if l.Hash == hash {
+			s.favorites[hash] = favorite
+			return nil
+		}
+	}
+	return exporter.ErrListingNotFound
+}
+
+func get(t *testing.T, s *Server, method, path string) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec.Code, rec.Body.String()
+}
+
+func TestIndexRoutes(t *testing.T) {
+	week := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+	s := New(fakeStore{"all": {
+		{Name: "all", Week: week, Median: 4000, Listings: 10, Value: 100},
+		{Name: "all", Week: week.AddDate(0, 0, 7), Median: 3800, Listings: 12, Value: 95},
+	}}, nil)
+
+	code, body := get(t, s, http.MethodGet, "/api/indexes")
+	require.Equal(t, http.StatusOK, code)
+	var latest []priceindex.Point
+	require.NoError(t, json.Unmarshal([]byte(body), &latest))
+	require.Len(t, latest, 1)
+	assert.Equal(t, 3800.0, latest[0].Median)
+
+	code, body = get(t, s, http.MethodGet, "/api/indexes/ALL")
+	require.Equal(t, http.StatusOK, code)
+	var series []priceindex.Point
+	require.NoError(t, json.Unmarshal([]byte(body), &series))
+	assert.Len(t, series, 2)
+
+	code, body = get(t, s, http.MethodGet, "/api/indexes/Santa%20Cruz%20Nomad")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Contains(t, body, "no index named Santa Cruz Nomad")
+
+	code, _ = get(t, s, http.MethodGet, "/api/indexes/broken")
+	assert.Equal(t, http.StatusInternalServerError, code)
+	code, _ = get(t, s, http.MethodPost, "/api/indexes")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+
+	code, body = get(t, New(fakeStore{}, nil), http.MethodGet, "/api/indexes")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "[]\n", body)
+}
+
+func TestFavoriteRoutes(t *testing.T) {
+	store := &favoriteStore{
+		listings: []listing.Listing{
+			{Hash: "abc", Title: "2021 Santa Cruz Nomad", Price: "4200", Active: true},
+			{Hash: "def", Title: "2019 Specialized Enduro", Price: "2800"},
+		},
+		favorites: map[string]bool{},
+	}
+	s := New(store, nil)
+
+	code, body := get(t, s, http.MethodGet, "/api/favorites")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "[]\n", body)
+
+	code, _ = get(t, s, http.MethodPut, "/api/favorites/abc")
+	assert.Equal(t, http.StatusNoContent, code)
+	code, body = get(t, s, http.MethodPut, "/api/favorites/missing")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Contains(t, body, "no listing with hash missing")
+
+	code, body = get(t, s, http.MethodGet, "/api/favorites")
+	require.Equal(t, http.StatusOK, code)
+	var favorites []struct {
+		Hash   string `json:"hash"`
+		Title  string `json:"title"`
+		Active bool   `json:"active"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &favorites))
+	require.Len(t, favorites, 1)
+	assert.Equal(t, "2021 Santa Cruz Nomad", favorites[0].Title)
+	assert.True(t, favorites[0].Active)
+
+	code, _ = get(t, s, http.MethodDelete, "/api/favorites/abc")
+	assert.Equal(t, http.StatusNoContent, code)
+	assert.False(t, store.favorites["abc"])
+
+	code, _ = get(t, s, http.MethodPost, "/api/favorites/abc")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+	code, _ = get(t, s, http.MethodPut, "/api/indexes/all")
+	assert.Equal(t, http.StatusMethodNotAllowed, code, "only favorites can be changed")
+}
+
+func TestEventStream(t *testing.T) {
+	bus := events.NewBus()
+	s := New(fakeStore{}, bus)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	defer s.Close()
+
+	resp, err := http.Get(ts.URL + "/events?kind=price_changed&manufacturer=santa%20cruz")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// the stream is subscribed once its headers are sent
+	at := time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC)
+	bus.Publish(events.Event{Kind: events.ListingDiscovered, Listing: listing.Listing{Hash: "new", Manufacturer: "Santa Cruz"}})
+	bus.Publish(events.Event{Kind: events.PriceChanged, Listing: listing.Listing{Hash: "yeti", Manufacturer: "Yeti"}})
+	bus.Publish(events.Event{Kind: events.PriceChanged, Time: at, OldPrice: "4000",
+		Listing: listing.Listing{Hash: "abc", Manufacturer: "Santa Cruz", Price: "3800"}})
+
+	lines := bufio.NewScanner(resp.Body)
+	require.True(t, lines.Scan())
+	assert.Equal(t, "event: price_changed", lines.Text())
+	require.True(t, lines.Scan())
+	data := strings.TrimPrefix(lines.Text(), "data: ")
+	var e struct {
+		Kind     string    `json:"kind"`
+		Time     time.Time `json:"time"`
+		OldPrice string    `json:"oldPrice"`
+		Listing  struct {
+			Hash  string `json:"hash"`
+			Price string `json:"price"`
+		} `json:"listing"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(data), &e))
+	assert.Equal(t, "abc", e.Listing.Hash)
+	assert.Equal(t, "3800", e.Listing.Price)
+	assert.Equal(t, "4000", e.OldPrice)
+	assert.True(t, e.Time.Equal(at))
+}
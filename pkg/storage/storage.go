@@ -0,0 +1,123 @@
+// Package storage uploads run outputs such as CSV files and HTML reports, and
+// database exports such as Parquet files, to S3 or Google Cloud Storage, so
+// scheduled runs on a server leave nothing to collect from its disk
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	gcs "google.golang.org/api/storage/v1"
+)
+
+// objectStore writes objects to one bucket
+type objectStore interface {
+	put(ctx context.Context, key string, body io.ReadSeeker, contentType string) error
+}
+
+// Uploader copies local files under a prefix of a bucket
+type Uploader struct {
+	store  objectStore
+	scheme string
+	bucket string
+	prefix string
+}
+
+// Open returns an uploader for a destination such as s3://bucket/prefix or
+// gs://bucket/prefix. Credentials are read from the environment: the AWS
+// default chain (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_REGION or
+// AWS_PROFILE) for S3, and Application Default Credentials, such as
+// GOOGLE_APPLICATION_CREDENTIALS, for Cloud Storage.
+func Open(ctx context.Context, destination string) (*Uploader, error) {
+	u, err := url.Parse(destination)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid upload destination %q, expected s3://bucket/prefix or gs://bucket/prefix", destination)
+	}
+	up := &Uploader{scheme: u.Scheme, bucket: u.Host, prefix: strings.Trim(u.Path, "/")}
+
+	switch u.Scheme {
+	case "s3":
+		cfg, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not load AWS configuration: %w", err)
+		}
+		up.store = s3Store{client: s3.NewFromConfig(cfg), bucket: up.bucket}
+	case "gs":
+		svc, err := gcs.NewService(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not create Cloud Storage client: %w", err)
+		}
+		up.store = gcsStore{svc: svc, bucket: up.bucket}
+	default:
+		return nil, fmt.Errorf("unsupported upload destination %q, expected s3:// or gs://", destination)
+	}
+	return up, nil
+}
+
+// UploadFile uploads the file at local, keeping its relative path under the
+// prefix, and returns the URL it was uploaded to
+func (u *Uploader) UploadFile(ctx context.Context, local string) (string, error) {
+	f, err := os.Open(local)
+	if err != nil {
+		return "", fmt.Errorf("could not open %s: %w", local, err)
+	}
+	defer f.Close()
+
+	key := path.Join(u.prefix, filepath.ToSlash(filepath.Clean(local)))
+	if err := u.store.put(ctx, key, f, contentType(local)); err != nil {
+		return "", fmt.Errorf("could not upload %s: %w", local, err)
+	}
+	return fmt.Sprintf("%s://%s/%s", u.scheme, u.bucket, key), nil
+}
+
+func contentType(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext == ".csv" {
+		return "text/csv; charset=utf-8"
+	}
+	if ext == ".parquet" {
+		return "application/vnd.apache.parquet"
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+type s3Store struct {
+	client *s3.Client
+	bucket string
+}
+
+func (s s3Store) put(ctx context.Context, key string, body io.ReadSeeker, contentType string) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+	})
+	return err
+}
+
+type gcsStore struct {
+	svc    *gcs.Service
+	bucket string
+}
+
+func (s gcsStore) put(ctx context.Context, key string, body io.ReadSeeker, contentType string) error {
+	_, err := s.svc.Objects.Insert(s.bucket, &gcs.Object{Name: key, ContentType: contentType}).
+		Media(body).
+		Context(ctx).
+		Do()
+	return err
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryStore map[string]string
+
+func (m memoryStore) put(_ context.Context, key string, body io.ReadSeeker, contentType string) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	m[key] = contentType + "|" + string(data)
+	return nil
+}
+
+func TestUploadFileKeepsRelativePath(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	require.NoError(t, os.MkdirAll("runs", 0755))
+	require.NoError(t, os.WriteFile(filepath.Join("runs", "enduroListings2024-05-06.csv"), []byte("Title\n"), 0644))
+
+	store := memoryStore{}
+	up := &Uploader{store: store, scheme: "s3", bucket: "bikes", prefix: "pinkbike/daily"}
+	dest, err := up.UploadFile(context.Background(), "runs/enduroListings2024-05-06.csv")
+	require.NoError(t, err)
+	assert.Equal(t, "s3://bikes/pinkbike/daily/runs/enduroListings2024-05-06.csv", dest)
+	assert.Equal(t, "text/csv; charset=utf-8|Title\n", store["pinkbike/daily/runs/enduroListings2024-05-06.csv"])
+
+	_, err = up.UploadFile(context.Background(), "runs/missing.csv")
+	assert.Error(t, err)
+}
+
+func TestContentType(t *testing.T) {
+	assert.Equal(t, "text/csv; charset=utf-8", contentType("enduroListings.csv"))
+	assert.Equal(t, "application/vnd.apache.parquet", contentType("exports/listings.PARQUET"))
+	assert.Equal(t, "text/html; charset=utf-8", contentType("report.html"))
+}
+
+func TestOpenRejectsUnknownDestinations(t *testing.T) {
+	for _, dest := range []string{"bikes/daily", "ftp://bikes/daily", "s3://"} {
+		_, err := Open(context.Background(), dest)
+		assert.Error(t, err, dest)
+	}
+}
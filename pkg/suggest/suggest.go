@@ -0,0 +1,164 @@
+// Package suggest proposes model database entries from listing titles the
+// parser could not find a model in
+package suggest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxExamples is how many example titles are kept per suggestion
+const maxExamples = 3
+
+// Suggestion is a candidate model for a manufacturer seen in unmatched titles
+type Suggestion struct {
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+	Count        int      `json:"count"`
+	Examples     []string `json:"examples"`
+}
+
+// Report is the review file written for a batch of suggestions
+type Report struct {
+	GeneratedAt time.Time    `json:"generated_at"`
+	Since       time.Time    `json:"since"`
+	Suggestions []Suggestion `json:"suggestions"`
+}
+
+var (
+	yearPattern  = regexp.MustCompile(`^(19|20)\d{2}$`)
+	numberLike   = regexp.MustCompile(`^[\d.,/"'x-]+(mm|in|cm)?$`)
+	bracketsExpr = regexp.MustCompile(`[(\[{][^)\]}]*[)\]}]`)
+	noiseWords   = map[string]bool{
+		"xs": true, "s": true, "m": true, "l": true, "xl": true, "xxl": true, "sm": true, "md": true, "lg": true,
+		"small": true, "medium": true, "large": true, "size": true, "frame": true, "frameset": true, "bike": true,
+		"mtb": true, "new": true, "used": true, "carbon": true, "alloy": true, "aluminum": true, "aluminium": true,
+		"steel": true, "titanium": true, "with": true, "and": true, "for": true, "the": true, "w": true, "mm": true,
+		"-": true, "&": true, "+": true, "|": true,
+	}
+)
+
+// candidate returns the first word after the manufacturer that could be a model name
+func candidate(manufacturer, title string) string {
+	title = bracketsExpr.ReplaceAllString(title, " ")
+	// the parser matches manufacturers anywhere in a title ("Ari" in "Marin"),
+	// only skip past the name when it is a whole word
+	name := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(manufacturer) + `\b`)
+	if loc := name.FindStringIndex(title); loc != nil {
+		title = title[loc[1]:]
+	}
+
+	for _, word := range strings.Fields(title) {
+		word = strings.Trim(word, `,.:;!?*"'`)
+		lower := strings.ToLower(word)
+		if len(word) < 2 || noiseWords[lower] || yearPattern.MatchString(word) || numberLike.MatchString(lower) {
+			continue
+		}
+		return word
+	}
+	return ""
+}
+
+// Models groups unmatched titles by manufacturer and the word most likely to
+// be their model, returning groups seen at least minCount times, most common first
+func Models(titlesByManufacturer map[string][]string, minCount int) []Suggestion {
+	var suggestions []Suggestion
+	for manufacturer, titles := range titlesByManufacturer {
+		clusters := map[string]*Suggestion{}
+		spellings := map[string]map[string]int{}
+
+		for _, title := range titles {
+			word := candidate(manufacturer, title)
+			if word == "" {
+				continue
+			}
+			key := strings.ToLower(word)
+
+			c, ok := clusters[key]
+			if !ok {
+				c = &Suggestion{Manufacturer: manufacturer}
+				clusters[key] = c
+				spellings[key] = map[string]int{}
+			}
+			c.Count++
+			spellings[key][word]++
+			if len(c.Examples) < maxExamples {
+				c.Examples = append(c.Examples, title)
+			}
+		}
+
+		for key, c := range clusters {
+			if c.Count < minCount {
+				continue
+			}
+			c.Model = mostCommon(spellings[key])
+			suggestions = append(suggestions, *c)
+		}
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Count != suggestions[j].Count {
+			return suggestions[i].Count > suggestions[j].Count
+		}
+		if suggestions[i].Manufacturer != suggestions[j].Manufacturer {
+			return suggestions[i].Manufacturer < suggestions[j].Manufacturer
+		}
+		return suggestions[i].Model < suggestions[j].Model
+	})
+	return suggestions
+}
+
+func mostCommon(counts map[string]int) string {
+	best, bestCount := "", 0
+	for word, count := range counts {
+		if count > bestCount || (count == bestCount && word < best) {
+			best, bestCount = word, count
+		}
+	}
+	return best
+}
+
+// Write saves the report as JSON in dir, named after the day it was generated
+func (r Report) Write(dir string) (string, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("could not encode model suggestions: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("could not create suggestions directory: %w", err)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("model_suggestions_%s.json", r.GeneratedAt.Format("2006-01-02")))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("could not write model suggestions: %w", err)
+	}
+	return path, nil
+}
+
+// LastGenerated returns when the newest report in dir was written, or the zero
+// time if there is none
+func LastGenerated(dir string) (time.Time, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "model_suggestions_*.json"))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var last time.Time
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+	}
+	return last, nil
+}
@@ -0,0 +1,56 @@
+package suggest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModels(t *testing.T) {
+	titles := map[string][]string{
+		"Giant": {
+			"2024 Giant Trance Elite E (Large)",
+			"2023 Giant Trance X 29 Carbon",
+			"2022 giant TRANCE 2 M",
+			"2023 Giant Trance X Advanced",
+			"Giant 2021 Stance 29",
+			"2019 Giant (XL) frame",
+		},
+		"Ari": {
+			"2022 Marin Team Marin 1",
+		},
+		"Orbea": {
+			"2025 Orbea Wild M-Team",
+			"2024 Orbea Wild H20",
+		},
+	}
+
+	assert.Equal(t, "Marin", candidate("Ari", "2022 Marin Team Marin 1"))
+
+	suggestions := Models(titles, 2)
+	require.Len(t, suggestions, 2)
+
+	assert.Equal(t, "Giant", suggestions[0].Manufacturer)
+	assert.Equal(t, "Trance", suggestions[0].Model)
+	assert.Equal(t, 4, suggestions[0].Count)
+	assert.Len(t, suggestions[0].Examples, maxExamples)
+
+	assert.Equal(t, Suggestion{Manufacturer: "Orbea", Model: "Wild", Count: 2, Examples: titles["Orbea"]}, suggestions[1])
+}
+
+func TestReportWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	last, err := LastGenerated(dir)
+	require.NoError(t, err)
+	assert.True(t, last.IsZero())
+
+	_, err = Report{GeneratedAt: time.Now()}.Write(dir)
+	require.NoError(t, err)
+
+	last, err = LastGenerated(dir)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), last, time.Minute)
+}
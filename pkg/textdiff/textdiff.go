@@ -0,0 +1,163 @@
+// Package textdiff shows how a text changed, word by word, the way git's
+// word diff does
+package textdiff
+
+import "strings"
+
+// maxCells bounds the words compared at once, old times new, beyond which a
+// changed text is shown as replaced whole
+const maxCells = 1 << 22
+
+// Words diffs old and new word by word, marking removed words [-like this-]
+// and added ones {+like this+}. Unchanged runs keep context words on each side
+// of a change, the rest shortened to "...". Whitespace differences alone are
+// not changes; identical texts give an empty diff.
+func Words(old, new string, context int) string {
+	a, b := strings.Fields(old), strings.Fields(new)
+	ops := diff(a, b)
+
+	changed := false
+	for _, o := range ops {
+		if o.kind != equal {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	var out []string
+	for i, o := range ops {
+		switch o.kind {
+		case removed:
+			out = append(out, "[-"+strings.Join(o.words, " ")+"-]")
+		case added:
+			out = append(out, "{+"+strings.Join(o.words, " ")+"+}")
+		case equal:
+			out = append(out, shorten(o.words, context, i > 0, i < len(ops)-1)...)
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+// shorten keeps context words of an unchanged run next to the changes before
+// and after it
+func shorten(words []string, context int, before, after bool) []string {
+	keep := 0
+	if before {
+		keep += context
+	}
+	if after {
+		keep += context
+	}
+	if len(words) <= keep+1 {
+		return words
+	}
+
+	var out []string
+	if before {
+		out = append(out, words[:context]...)
+	}
+	out = append(out, "...")
+	if after {
+		out = append(out, words[len(words)-context:]...)
+	}
+	return out
+}
+
+type opKind int
+
+const (
+	equal opKind = iota
+	removed
+	added
+)
+
+// op is a run of words that are equal in both texts, or only in one of them
+type op struct {
+	kind  opKind
+	words []string
+}
+
+// diff returns the runs turning a into b, through their longest common
+// subsequence of words
+func diff(a, b []string) []op {
+	// common prefixes and suffixes, the bulk of a lightly edited text, are
+	// left out of the quadratic comparison
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []op
+	push := func(kind opKind, word string) {
+		if n := len(ops); n > 0 && ops[n-1].kind == kind {
+			ops[n-1].words = append(ops[n-1].words, word)
+			return
+		}
+		ops = append(ops, op{kind: kind, words: []string{word}})
+	}
+	for _, w := range a[:prefix] {
+		push(equal, w)
+	}
+
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(ma)*len(mb) > maxCells {
+		for _, w := range ma {
+			push(removed, w)
+		}
+		for _, w := range mb {
+			push(added, w)
+		}
+	} else {
+		// lcs[i][j] is the length of the longest common subsequence of
+		// ma[i:] and mb[j:]
+		lcs := make([][]int, len(ma)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(mb)+1)
+		}
+		for i := len(ma) - 1; i >= 0; i-- {
+			for j := len(mb) - 1; j >= 0; j-- {
+				if ma[i] == mb[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else if lcs[i+1][j] >= lcs[i][j+1] {
+					lcs[i][j] = lcs[i+1][j]
+				} else {
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < len(ma) && j < len(mb) {
+			switch {
+			case ma[i] == mb[j]:
+				push(equal, ma[i])
+				i++
+				j++
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				push(removed, ma[i])
+				i++
+			default:
+				push(added, mb[j])
+				j++
+			}
+		}
+		for ; i < len(ma); i++ {
+			push(removed, ma[i])
+		}
+		for ; j < len(mb); j++ {
+			push(added, mb[j])
+		}
+	}
+
+	for _, w := range a[len(a)-suffix:] {
+		push(equal, w)
+	}
+	return ops
+}
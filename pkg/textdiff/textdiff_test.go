@@ -0,0 +1,35 @@
+package textdiff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name, old, new string
+		want           string
+	}{
+		{"unchanged", "Fresh fork service", "Fresh  fork\nservice", ""},
+		{"replaced", "Price is firm", "Price is negotiable", "Price is [-firm-] {+negotiable+}"},
+		{"added", "Coil shock", "Coil shock, new chain", "Coil [-shock-] {+shock, new chain+}"},
+		{"removed", "Comes with spare derailleur hanger and pedals", "Comes with pedals", "Comes with [-spare derailleur hanger and-] pedals"},
+		{"from empty", "", "Call after 5", "{+Call after 5+}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Words(tt.old, tt.new, 3))
+		})
+	}
+}
+
+func TestWordsShortensUnchangedRuns(t *testing.T) {
+	old := "one two three four five six seven eight nine ten"
+	new := "one two three four five SIX seven eight nine ten"
+	assert.Equal(t, "... four five [-six-] {+SIX+} seven eight ...", Words(old, new, 2))
+
+	long := strings.Repeat("word ", 3000)
+	assert.Equal(t, "... word word [-word-] {+edit+}", Words(long, strings.Repeat("word ", 2999)+"edit", 2))
+}
@@ -0,0 +1,294 @@
+// Package tui is a terminal browser over the stored listings
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/listing"
+)
+
+// Store is the part of the listings database the browser reads and writes
+// as the user moves through it
+type Store interface {
+	PriceHistory(hash string) ([]exporter.PriceRange, error)
+	SetFavorite(hash string, favorite bool) error
+}
+
+// Order is how the browser sorts listings
+type Order int
+
+const (
+	Newest Order = iota
+	Cheapest
+	Priciest
+	NewestYear
+)
+
+var orderNames = [...]string{"newest", "cheapest", "priciest", "model year"}
+
+func (o Order) String() string {
+	return orderNames[o]
+}
+
+// Browser is the state of the listings browser: which listings are shown,
+// which is selected and whether its details are open. Keys are fed to
+// HandleKey and the screen is drawn from View, so it can be driven without
+// a terminal.
+type Browser struct {
+	store     Store
+	listings  []listing.Listing
+	favorites map[string]bool
+	// open shows a URL in the user's browser
+	open func(url string) error
+
+	shown         []listing.Listing
+	filter        string
+	filtering     bool
+	activeOnly    bool
+	favoritesOnly bool
+	order         Order
+	cursor, top   int
+
+	detail  bool
+	history []exporter.PriceRange
+	status  string
+	done    bool
+}
+
+// NewBrowser browses listings, marking those whose hash is in favorites
+func NewBrowser(store Store, listings []listing.Listing, favorites map[string]bool, open func(url string) error) *Browser {
+	if favorites == nil {
+		favorites = make(map[string]bool)
+	}
+	b := &Browser{store: store, listings: listings, favorites: favorites, open: open}
+	b.refresh()
+	return b
+}
+
+// Done reports whether the user quit
+func (b *Browser) Done() bool {
+	return b.done
+}
+
+// SetActiveOnly hides listings that are no longer for sale, or shows them again
+func (b *Browser) SetActiveOnly(active bool) {
+	b.activeOnly = active
+	b.refresh()
+}
+
+// Shown returns the listings passing the filters, in the current order
+func (b *Browser) Shown() []listing.Listing {
+	return b.shown
+}
+
+// Selected returns the listing under the cursor, false when none is shown
+func (b *Browser) Selected() (listing.Listing, bool) {
+	if len(b.shown) == 0 {
+		return listing.Listing{}, false
+	}
+	return b.shown[b.cursor], true
+}
+
+// refresh reapplies the filters and order, keeping the selected listing
+// under the cursor when it is still shown
+func (b *Browser) refresh() {
+	selected, hadSelection := b.Selected()
+
+	words := strings.Fields(strings.ToLower(b.filter))
+	b.shown = b.shown[:0]
+	for _, l := range b.listings {
+		if b.activeOnly && !l.Active || b.favoritesOnly && !b.favorites[l.Hash] {
+			continue
+		}
+		if matches(l, words) {
+			b.shown = append(b.shown, l)
+		}
+	}
+
+	sort.SliceStable(b.shown, func(i, j int) bool {
+		a, c := b.shown[i], b.shown[j]
+		switch b.order {
+		case Cheapest, Priciest:
+			pa, aok := price(a)
+			pc, cok := price(c)
+			if aok != cok {
+				// unpriced listings go last either way
+				return aok
+			}
+			if b.order == Cheapest {
+				return pa < pc
+			}
+			return pa > pc
+		case NewestYear:
+			return a.Year > c.Year
+		default:
+			return a.FirstSeen.After(c.FirstSeen)
+		}
+	})
+
+	b.cursor = 0
+	if hadSelection {
+		for i, l := range b.shown {
+			if l.Hash == selected.Hash {
+				b.cursor = i
+				break
+			}
+		}
+	}
+}
+
+// matches reports whether every filter word appears in the listing's title,
+// manufacturer, model, category or size
+func matches(l listing.Listing, words []string) bool {
+	text := strings.ToLower(strings.Join([]string{l.Title, l.Year, l.Manufacturer, l.Model, l.Category, l.NormalizedSize}, " "))
+	for _, w := range words {
+		if !strings.Contains(text, w) {
+			return false
+		}
+	}
+	return true
+}
+
+func price(l listing.Listing) (float64, bool) {
+	p, err := strconv.ParseFloat(l.Price, 64)
+	return p, err == nil && p > 0
+}
+
+// HandleKey applies a key press. It returns the errors of the store and of
+// opening a listing's URL.
+func (b *Browser) HandleKey(k Key) error {
+	b.status = ""
+	if b.filtering {
+		b.editFilter(k)
+		return nil
+	}
+
+	switch k {
+	case "q", CtrlC:
+		b.done = true
+	case Escape:
+		if b.detail {
+			b.detail = false
+		} else if b.filter != "" {
+			b.filter = ""
+			b.refresh()
+		}
+	case Up, "k":
+		return b.move(-1)
+	case Down, "j":
+		return b.move(1)
+	case PageUp:
+		return b.move(-pageSize)
+	case PageDown:
+		return b.move(pageSize)
+	case Home, "g":
+		return b.move(-len(b.shown))
+	case End, "G":
+		return b.move(len(b.shown))
+	case "/":
+		b.filtering, b.detail = true, false
+	case "s":
+		b.order = (b.order + 1) % Order(len(orderNames))
+		b.refresh()
+	case "a":
+		b.activeOnly = !b.activeOnly
+		b.refresh()
+	case "F":
+		b.favoritesOnly = !b.favoritesOnly
+		b.refresh()
+	case Enter:
+		return b.showDetail()
+	case "f":
+		return b.toggleFavorite()
+	case "o":
+		l, ok := b.Selected()
+		if !ok || l.URL == "" {
+			return nil
+		}
+		if err := b.open(l.URL); err != nil {
+			return fmt.Errorf("could not open %s: %w", l.URL, err)
+		}
+		b.status = "Opened " + l.URL
+	}
+	return nil
+}
+
+// editFilter types into the filter, which applies as it is typed
+func (b *Browser) editFilter(k Key) {
+	switch k {
+	case Enter, Escape:
+		b.filtering = false
+		return
+	case Backspace:
+		if r := []rune(b.filter); len(r) > 0 {
+			b.filter = string(r[:len(r)-1])
+		}
+	case CtrlC:
+		b.done = true
+		return
+	default:
+		if !k.Printable() {
+			return
+		}
+		b.filter += string(k)
+	}
+	b.refresh()
+}
+
+// pageSize is how many listings page up and down move by
+const pageSize = 10
+
+// move moves the cursor by n listings, showing the details of the listing it
+// lands on if details are open
+func (b *Browser) move(n int) error {
+	b.cursor += n
+	if b.cursor >= len(b.shown) {
+		b.cursor = len(b.shown) - 1
+	}
+	if b.cursor < 0 {
+		b.cursor = 0
+	}
+	if b.detail {
+		return b.showDetail()
+	}
+	return nil
+}
+
+func (b *Browser) showDetail() error {
+	l, ok := b.Selected()
+	if !ok {
+		return nil
+	}
+	history, err := b.store.PriceHistory(l.Hash)
+	if err != nil {
+		return err
+	}
+	b.detail, b.history = true, history
+	return nil
+}
+
+func (b *Browser) toggleFavorite() error {
+	l, ok := b.Selected()
+	if !ok {
+		return nil
+	}
+	favorite := !b.favorites[l.Hash]
+	if err := b.store.SetFavorite(l.Hash, favorite); err != nil {
+		return err
+	}
+	if favorite {
+		b.favorites[l.Hash] = true
+		b.status = "Added to favorites"
+	} else {
+		delete(b.favorites, l.Hash)
+		b.status = "Removed from favorites"
+		if b.favoritesOnly {
+			b.refresh()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package tui
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/listing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStore struct {
+	history   map[string][]exporter.PriceRange
+	favorites map[string]bool
+	err       error
+}
+
+func (s *fakeStore) PriceHistory(hash string) ([]exporter.PriceRange, error) {
+	return s.history[hash], s.err
+}
+
+func (s *fakeStore) SetFavorite(hash string, favorite bool) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.favorites[hash] = favorite
+	return nil
+}
+
+func testListings() []listing.Listing {
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	return []listing.Listing{
+		{Hash: "nomad", Title: "2021 Santa Cruz Nomad", Year: "2021", Manufacturer: "Santa Cruz", Model: "Nomad",
+			Price: "4200", Active: true, FirstSeen: day, LastSeen: day.AddDate(0, 0, 9)},
+		{Hash: "enduro", Title: "2019 Specialized Enduro", Year: "2019", Manufacturer: "Specialized", Model: "Enduro",
+			Price: "2800", FirstSeen: day.AddDate(0, 0, 2)},
+		{Hash: "megatower", Title: "2022 Santa Cruz Megatower", Year: "2022", Manufacturer: "Santa Cruz", Model: "Megatower",
+			Price: "5100", Active: true, FirstSeen: day.AddDate(0, 0, 1)},
+	}
+}
+
+func hashes(listings []listing.Listing) []string {
+	var out []string
+	for _, l := range listings {
+		out = append(out, l.Hash)
+	}
+	return out
+}
+
+func TestBrowserFiltersAndSorts(t *testing.T) {
+	b := NewBrowser(&fakeStore{}, testListings(), nil, nil)
+	assert.Equal(t, []string{"enduro", "megatower", "nomad"}, hashes(b.Shown()), "newest first")
+
+	for _, k := range []Key{"s", "s"} {
+		require.NoError(t, b.HandleKey(k))
+	}
+	assert.Equal(t, []string{"megatower", "nomad", "enduro"}, hashes(b.Shown()), "priciest first")
+
+	require.NoError(t, b.HandleKey("a"))
+	assert.Equal(t, []string{"megatower", "nomad"}, hashes(b.Shown()))
+
+	for _, k := range []Key{"/", "s", "a", "n", "t", "a", Backspace, "a", " ", "n", "o", Enter} {
+		require.NoError(t, b.HandleKey(k))
+	}
+	assert.Equal(t, []string{"nomad"}, hashes(b.Shown()))
+	assert.False(t, b.Done(), "q while filtering would have quit")
+
+	require.NoError(t, b.HandleKey(Escape))
+	require.NoError(t, b.HandleKey("q"))
+	assert.True(t, b.Done())
+}
+
+func TestBrowserKeepsSelection(t *testing.T) {
+	b := NewBrowser(&fakeStore{}, testListings(), nil, nil)
+	require.NoError(t, b.HandleKey(Down))
+	selected, ok := b.Selected()
+	require.True(t, ok)
+	assert.Equal(t, "megatower", selected.Hash)
+
+	require.NoError(t, b.HandleKey("s"))
+	selected, _ = b.Selected()
+	assert.Equal(t, "megatower", selected.Hash, "sorting keeps the cursor on the same listing")
+
+	require.NoError(t, b.HandleKey(End))
+	require.NoError(t, b.HandleKey(Down))
+	selected, _ = b.Selected()
+	assert.Equal(t, "megatower", selected.Hash, "the most expensive is last when cheapest first")
+}
+
+func TestBrowserFavorites(t *testing.T) {
+	store := &fakeStore{favorites: map[string]bool{}}
+	b := NewBrowser(store, testListings(), map[string]bool{"nomad": true}, nil)
+
+	require.NoError(t, b.HandleKey("f"))
+	assert.True(t, store.favorites["enduro"])
+	require.NoError(t, b.HandleKey("F"))
+	assert.Equal(t, []string{"enduro", "nomad"}, hashes(b.Shown()))
+
+	require.NoError(t, b.HandleKey("f"))
+	assert.False(t, store.favorites["enduro"])
+	assert.Equal(t, []string{"nomad"}, hashes(b.Shown()), "unmarked favorites leave the favorites view")
+
+	store.err = errors.New("database is locked")
+	assert.Error(t, b.HandleKey("f"))
+	assert.Equal(t, []string{"nomad"}, hashes(b.Shown()))
+}
+
+func TestBrowserOpensURL(t *testing.T) {
+	listings := testListings()
+	listings[1].URL = "https://www.pinkbike.com/buysell/3861316/"
+	var opened []string
+	b := NewBrowser(&fakeStore{}, listings, nil, func(url string) error {
+		opened = append(opened, url)
+		return nil
+	})
+
+	require.NoError(t, b.HandleKey("o"))
+	assert.Equal(t, []string{listings[1].URL}, opened)
+	require.NoError(t, b.HandleKey(Down))
+	require.NoError(t, b.HandleKey("o"))
+	assert.Len(t, opened, 1, "listings without a URL open nothing")
+}
+
+func TestBrowserView(t *testing.T) {
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	store := &fakeStore{history: map[string][]exporter.PriceRange{
+		"nomad": {
+			{Price: "4800", From: day, To: day.AddDate(0, 0, 3)},
+			{Price: "4500", From: day.AddDate(0, 0, 4), To: day.AddDate(0, 0, 6)},
+			{Price: "4200", From: day.AddDate(0, 0, 9), To: day.AddDate(0, 0, 9)},
+		},
+	}}
+	b := NewBrowser(store, testListings(), map[string]bool{"nomad": true}, nil)
+
+	screen := b.View(60, 10)
+	require.Len(t, screen, 10)
+	assert.Contains(t, screen[0], "3 of 3 listings  sorted by newest")
+	assert.Contains(t, screen[1], reverse, "the selected listing is highlighted")
+	assert.Contains(t, screen[3], "★    $4200")
+	assert.Contains(t, screen[3], "2021 Santa Cruz Nomad")
+
+	require.NoError(t, b.HandleKey(End))
+	require.NoError(t, b.HandleKey(Enter))
+	screen = b.View(60, 20)
+	text := strings.Join(screen, "\n")
+	assert.Contains(t, text, "Bike          2021 Santa Cruz Nomad")
+	assert.Contains(t, text, "Price history █▄▁")
+	assert.Contains(t, text, "$4200 to $4800, 2024-05-01 to 2024-05-10")
+
+	require.NoError(t, b.HandleKey(Up))
+	assert.Contains(t, strings.Join(b.View(60, 20), "\n"), "Price history  none recorded", "moving loads the next listing's history")
+}
+
+func TestSparkline(t *testing.T) {
+	assert.Equal(t, "▁▄█", Sparkline([]float64{1000, 1500, 2000}, 0))
+	assert.Equal(t, "██", Sparkline([]float64{1000, 1000}, 0), "a flat price is a full bar")
+	assert.Equal(t, "▁█", Sparkline([]float64{3000, 1000, 2000}, 2), "only the latest prices fit")
+	assert.Empty(t, Sparkline(nil, 10))
+}
+
+func TestReadKey(t *testing.T) {
+	r := NewKeyReader(strings.NewReader("a\x1b[A\x1b[6~\r\x7fé\x1bq\x03\x1b"))
+	var keys []Key
+	for {
+		k, err := r.ReadKey()
+		if err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+		keys = append(keys, k)
+	}
+	assert.Equal(t, []Key{"a", Up, PageDown, Enter, Backspace, "é", Escape, "q", CtrlC, Escape}, keys)
+	assert.True(t, Key("é").Printable())
+	assert.False(t, Up.Printable())
+}
+
+func TestReadKeySplitEscapeSequence(t *testing.T) {
+	in, out := io.Pipe()
+	r := NewKeyReader(in)
+	r.EscapeTimeout = time.Second
+	go func() {
+		// a slow link delivers the sequence in pieces
+		for _, piece := range []string{"\x1b", "[", "5", "~", "x"} {
+			out.Write([]byte(piece))
+			time.Sleep(10 * time.Millisecond)
+		}
+		out.Close()
+	}()
+
+	k, err := r.ReadKey()
+	require.NoError(t, err)
+	assert.Equal(t, PageUp, k)
+	k, err = r.ReadKey()
+	require.NoError(t, err)
+	assert.Equal(t, Key("x"), k)
+	_, err = r.ReadKey()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReadKeyLoneEscape(t *testing.T) {
+	in, out := io.Pipe()
+	r := NewKeyReader(in)
+	r.EscapeTimeout = 10 * time.Millisecond
+	go func() {
+		out.Write([]byte("\x1b"))
+		time.Sleep(200 * time.Millisecond)
+		out.Write([]byte("[A"))
+		out.Close()
+	}()
+
+	k, err := r.ReadKey()
+	require.NoError(t, err)
+	assert.Equal(t, Escape, k, "nothing followed the escape in time")
+	var keys []Key
+	for {
+		k, err := r.ReadKey()
+		if err != nil {
+			break
+		}
+		keys = append(keys, k)
+	}
+	assert.Equal(t, []Key{"[", "A"}, keys)
+}
@@ -0,0 +1,162 @@
+package tui
+
+import (
+	"io"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Key is a key press: the character typed, or one of the named keys below
+type Key string
+
+const (
+	Up        Key = "up"
+	Down      Key = "down"
+	Left      Key = "left"
+	Right     Key = "right"
+	PageUp    Key = "pgup"
+	PageDown  Key = "pgdown"
+	Home      Key = "home"
+	End       Key = "end"
+	Enter     Key = "enter"
+	Escape    Key = "esc"
+	Backspace Key = "backspace"
+	CtrlC     Key = "ctrl+c"
+	Unknown   Key = ""
+)
+
+// Printable reports whether the key is a single character that can be typed
+// into the filter
+func (k Key) Printable() bool {
+	r, size := utf8.DecodeRuneInString(string(k))
+	return size == len(k) && r != utf8.RuneError && unicode.IsPrint(r)
+}
+
+// escapeSequences are the keys terminals send as ESC [ ..., by what follows
+// the bracket
+var escapeSequences = map[string]Key{
+	"A": Up, "B": Down, "C": Right, "D": Left,
+	"H": Home, "F": End, "1~": Home, "4~": End, "7~": Home, "8~": End,
+	"5~": PageUp, "6~": PageDown,
+}
+
+// DefaultEscapeTimeout is how long a KeyReader waits for the rest of an
+// escape sequence. Terminals send a sequence at once, but over SSH or a slow
+// link it can arrive in pieces; an ESC followed by nothing for this long is
+// the escape key.
+const DefaultEscapeTimeout = 50 * time.Millisecond
+
+// KeyReader reads key presses from a terminal in raw mode. Bubble Tea, which
+// reads keys the same way, is not a dependency of this module, so the browser
+// reads its own.
+type KeyReader struct {
+	// EscapeTimeout is how long to wait for each byte of an escape sequence
+	EscapeTimeout time.Duration
+
+	bytes chan byte
+	// err is why the input ended, set before bytes is closed
+	err error
+	// unread are bytes read ahead that belong to the next key
+	unread []byte
+}
+
+// NewKeyReader reads keys from r. The bytes are read by a goroutine of its
+// own, which keeps reading until r fails or ends.
+func NewKeyReader(r io.Reader) *KeyReader {
+	k := &KeyReader{EscapeTimeout: DefaultEscapeTimeout, bytes: make(chan byte, 64)}
+	go k.read(r)
+	return k
+}
+
+func (k *KeyReader) read(r io.Reader) {
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		for _, b := range buf[:n] {
+			k.bytes <- b
+		}
+		if err != nil {
+			k.err = err
+			close(k.bytes)
+			return
+		}
+	}
+}
+
+// next returns the next byte, waiting at most timeout for it when timeout is
+// positive. ok is false when none arrived in time or the input ended.
+func (k *KeyReader) next(timeout time.Duration) (b byte, ok bool) {
+	if len(k.unread) > 0 {
+		b, k.unread = k.unread[0], k.unread[1:]
+		return b, true
+	}
+	if timeout <= 0 {
+		b, ok = <-k.bytes
+		return b, ok
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case b, ok = <-k.bytes:
+		return b, ok
+	case <-timer.C:
+		return 0, false
+	}
+}
+
+// ReadKey reads one key press. It returns the error the input ended with,
+// io.EOF when it was closed, once every key before it was read.
+func (k *KeyReader) ReadKey() (Key, error) {
+	c, ok := k.next(0)
+	if !ok {
+		return Unknown, k.err
+	}
+
+	switch c {
+	case '\r', '\n':
+		return Enter, nil
+	case 0x7f, 0x08:
+		return Backspace, nil
+	case 0x03:
+		return CtrlC, nil
+	case 0x1b:
+		next, ok := k.next(k.EscapeTimeout)
+		if !ok {
+			return Escape, nil
+		}
+		if next != '[' && next != 'O' {
+			// escape pressed before another key
+			k.unread = append(k.unread, next)
+			return Escape, nil
+		}
+		var seq []byte
+		for {
+			b, ok := k.next(k.EscapeTimeout)
+			if !ok {
+				return Unknown, nil
+			}
+			seq = append(seq, b)
+			// sequences end with a letter or a tilde
+			if b >= 0x40 && b <= 0x7e {
+				break
+			}
+		}
+		if key, ok := escapeSequences[string(seq)]; ok {
+			return key, nil
+		}
+		return Unknown, nil
+	}
+
+	// the bytes of a multi-byte character arrive together
+	encoded := []byte{c}
+	for !utf8.FullRune(encoded) {
+		b, ok := k.next(0)
+		if !ok {
+			break
+		}
+		encoded = append(encoded, b)
+	}
+	r, _ := utf8.DecodeRune(encoded)
+	return Key(string(r)), nil
+}
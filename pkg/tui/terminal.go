@@ -0,0 +1,77 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const (
+	altScreen  = "\x1b[?1049h\x1b[?25l"
+	mainScreen = "\x1b[?25h\x1b[?1049l"
+	clear      = "\x1b[H\x1b[2J"
+)
+
+// Run shows the browser on the terminal in, drawing to out, until the user
+// quits. The terminal is restored when it returns.
+func Run(b *Browser, in *os.File, out io.Writer) error {
+	fd := int(in.Fd())
+	if !term.IsTerminal(fd) {
+		return fmt.Errorf("the listings browser needs an interactive terminal")
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("could not switch the terminal to raw mode: %w", err)
+	}
+	defer term.Restore(fd, state)
+
+	fmt.Fprint(out, altScreen)
+	defer fmt.Fprint(out, mainScreen)
+
+	keys := NewKeyReader(in)
+	for !b.Done() {
+		width, height, err := term.GetSize(fd)
+		if err != nil || width == 0 || height == 0 {
+			width, height = 80, 24
+		}
+		// raw mode leaves line endings to us
+		fmt.Fprint(out, clear+strings.Join(b.View(width, height), "\r\n"))
+
+		k, err := keys.ReadKey()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("could not read key: %w", err)
+		}
+		if err := b.HandleKey(k); err != nil {
+			b.status = err.Error()
+		}
+	}
+	return nil
+}
+
+// OpenURL opens url in the user's web browser
+func OpenURL(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	// the browser's own output would draw over the screen
+	cmd.Stdout, cmd.Stderr = nil, nil
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
@@ -0,0 +1,264 @@
+package tui
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"pinkbike-scraper/pkg/listing"
+)
+
+const (
+	reverse = "\x1b[7m"
+	bold    = "\x1b[1m"
+	dim     = "\x1b[2m"
+	reset   = "\x1b[0m"
+)
+
+const help = "↑/↓ move  enter details  / filter  s sort  a active  f favorite  F favorites  o open  q quit"
+
+// View draws the browser on a screen of width by height characters, one
+// string per line
+func (b *Browser) View(width, height int) []string {
+	if width < 20 || height < 5 {
+		return []string{"Terminal too small"}
+	}
+
+	header := fmt.Sprintf("%d of %d listings  sorted by %s", len(b.shown), len(b.listings), b.order)
+	if b.activeOnly {
+		header += "  active only"
+	}
+	if b.favoritesOnly {
+		header += "  favorites only"
+	}
+	if b.filter != "" || b.filtering {
+		header += "  filter: " + b.filter
+		if b.filtering {
+			header += "_"
+		}
+	}
+	lines := []string{bold + fit(header, width) + reset}
+
+	body := height - 2
+	if b.detail {
+		l, _ := b.Selected()
+		lines = append(lines, b.detailLines(l, width, body)...)
+	} else {
+		lines = append(lines, b.listLines(width, body)...)
+	}
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+
+	footer := help
+	if b.filtering {
+		footer = "type to filter  enter done  esc done"
+	}
+	if b.status != "" {
+		footer = b.status
+	}
+	return append(lines, dim+fit(footer, width)+reset)
+}
+
+// listLines draws a screen of listings around the cursor
+func (b *Browser) listLines(width, height int) []string {
+	if len(b.shown) == 0 {
+		return []string{"No listings match"}
+	}
+
+	// scroll just far enough to keep the cursor on screen
+	if b.cursor < b.top {
+		b.top = b.cursor
+	}
+	if b.cursor >= b.top+height {
+		b.top = b.cursor - height + 1
+	}
+	if b.top > len(b.shown)-1 {
+		b.top = 0
+	}
+
+	var lines []string
+	for i := b.top; i < len(b.shown) && i < b.top+height; i++ {
+		line := fit(b.row(b.shown[i]), width)
+		if i == b.cursor {
+			line = reverse + line + reset
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// row is a listing's line in the list: whether it is a favorite, its price,
+// size and status, then as much of its title as fits
+func (b *Browser) row(l listing.Listing) string {
+	marker := " "
+	if b.favorites[l.Hash] {
+		marker = "★"
+	}
+	status := "active"
+	if !l.Active {
+		status = "sold"
+	}
+	p := "-"
+	if v, ok := price(l); ok {
+		p = "$" + strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	size := l.NormalizedSize
+	if size == "" {
+		size = l.FrameSize
+	}
+	return fmt.Sprintf("%s %8s %-4s %-6s %s", marker, p, fit(size, 4), status, l.Title)
+}
+
+// detailLines draws the specs of a listing, its price history and the start
+// of its description
+func (b *Browser) detailLines(l listing.Listing, width, height int) []string {
+	lines := []string{bold + fit(l.Title, width) + reset, ""}
+	field := func(label, value string) {
+		if strings.TrimSpace(value) != "" {
+			lines = append(lines, fit(fmt.Sprintf("%-14s%s", label, value), width))
+		}
+	}
+
+	if b.favorites[l.Hash] {
+		field("Favorite", "★")
+	}
+	field("Bike", strings.Join(nonEmpty(l.Year, l.Manufacturer, l.Model, l.Generation), " "))
+	if !l.InferredYears.IsZero() {
+		field("Model year", l.InferredYears.String()+" (inferred)")
+	}
+	if !l.BuildTier.IsZero() {
+		field("Build", fmt.Sprintf("%s (%s)", l.BuildTier.Kit, l.BuildTier.Rank))
+	}
+	p := l.Price + " USD"
+	if l.ListedPrice != "" && l.Currency != "" && l.Currency != "USD" {
+		p += fmt.Sprintf(" (listed %s %s)", l.ListedPrice, l.Currency)
+	}
+	if l.Negotiable {
+		p += ", negotiable"
+	}
+	field("Price", p)
+	if l.PredictedPrice > 0 {
+		field("Model price", fmt.Sprintf("$%.0f", l.PredictedPrice))
+	}
+	field("Condition", l.Condition)
+	field("Frame", strings.Join(nonEmpty(l.FrameSize, l.FrameMaterial), ", "))
+	field("Wheels", l.WheelSize)
+	field("Travel", strings.Join(nonEmpty(l.FrontTravel, l.RearTravel), " / "))
+	field("Category", l.Category)
+	field("Seller", string(l.Details.SellerType))
+	status := "active"
+	if !l.Active {
+		status = "sold or removed"
+	}
+	if !l.FirstSeen.IsZero() {
+		status += fmt.Sprintf(", seen %s to %s", l.FirstSeen.Format("2006-01-02"), l.LastSeen.Format("2006-01-02"))
+	}
+	field("Status", status)
+	field("Needs review", l.NeedsReview.String())
+	field("URL", l.URL)
+
+	lines = append(lines, "")
+	lines = append(lines, b.historyLines(width)...)
+
+	if d := strings.Join(strings.Fields(l.Details.Description), " "); d != "" {
+		lines = append(lines, "")
+		lines = append(lines, wrap(d, width)...)
+	}
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	return lines
+}
+
+// historyLines draws the price history as a sparkline with its range
+func (b *Browser) historyLines(width int) []string {
+	var prices []float64
+	for _, r := range b.history {
+		if p, err := strconv.ParseFloat(r.Price, 64); err == nil {
+			prices = append(prices, p)
+		}
+	}
+	if len(prices) == 0 {
+		return []string{"Price history  none recorded"}
+	}
+
+	low, high := prices[0], prices[0]
+	for _, p := range prices {
+		low, high = math.Min(low, p), math.Max(high, p)
+	}
+	first, last := b.history[0].From, b.history[len(b.history)-1].To
+	summary := fmt.Sprintf("$%.0f to $%.0f, %s to %s", low, high, first.Format("2006-01-02"), last.Format("2006-01-02"))
+	return []string{
+		fit("Price history "+Sparkline(prices, width-14), width),
+		fit("              "+summary, width),
+	}
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws prices as a line of bars scaled between the lowest and
+// highest, keeping the most recent width prices
+func Sparkline(prices []float64, width int) string {
+	if width > 0 && len(prices) > width {
+		prices = prices[len(prices)-width:]
+	}
+	if len(prices) == 0 {
+		return ""
+	}
+
+	low, high := prices[0], prices[0]
+	for _, p := range prices {
+		low, high = math.Min(low, p), math.Max(high, p)
+	}
+	var sb strings.Builder
+	for _, p := range prices {
+		i := len(sparks) - 1
+		if high > low {
+			i = int((p - low) / (high - low) * float64(len(sparks)-1))
+		}
+		sb.WriteRune(sparks[i])
+	}
+	return sb.String()
+}
+
+// fit cuts s to width characters, marking the cut with an ellipsis
+func fit(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:width-1]) + "…"
+}
+
+// wrap breaks text into lines of at most width characters
+func wrap(text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += fit(word, width)
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func nonEmpty(values ...string) []string {
+	var out []string
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,131 @@
+package wayback
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"pinkbike-scraper/pkg/listing"
+)
+
+// ParseListingsPage extracts listings from a buysell list page using the same
+// elements the browser scraper reads
+func ParseListingsPage(page []byte) ([]listing.RawListing, error) {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse page: %w", err)
+	}
+
+	var listings []listing.RawListing
+	walk(doc, func(n *html.Node) bool {
+		if n.Data == "tr" && hasClass(n, "bsitem-table") {
+			listings = append(listings, parseEntry(n))
+			return false
+		}
+		return true
+	})
+	return listings, nil
+}
+
+func parseEntry(entry *html.Node) listing.RawListing {
+	var l listing.RawListing
+	details := map[string]*string{
+		"Condition":    &l.Condition,
+		"Frame Size":   &l.FrameSize,
+		"Wheel Size":   &l.WheelSize,
+		"Front Travel": &l.FrontTravel,
+		"Rear Travel":  &l.RearTravel,
+		"Material":     &l.FrameMaterial,
+	}
+
+	walk(entry, func(n *html.Node) bool {
+		switch {
+		case n.Data == "div" && hasClass(n, "bsitem-title"):
+			if a := firstChild(n, "a"); a != nil {
+				l.Title = strings.ReplaceAll(textContent(a), "\n", "")
+				l.URL = unarchiveURL(attr(a, "href"))
+				l.DetailsLink = l.URL
+			}
+			// the item details are nested inside the title block
+		case n.Data == "td" && hasClass(n, "bsitem-price"):
+			if b := firstChild(n, "b"); b != nil {
+				l.Price = textContent(b)
+			}
+			return false
+		case n.Data == "div":
+			if b := firstChild(n, "b"); b != nil {
+				label := textContent(b)
+				for name, field := range details {
+					if strings.Contains(label, name) && *field == "" {
+						*field = textContent(n)
+					}
+				}
+			}
+		}
+		return true
+	})
+
+	return l.Sanitize()
+}
+
+// unarchiveURL turns a link rewritten by the archive back into the original URL
+func unarchiveURL(href string) string {
+	if i := strings.Index(href, "/http"); strings.Contains(href, "/web/") && i >= 0 {
+		return href[i+1:]
+	}
+	return href
+}
+
+// walk visits nodes depth first, descending into a node's children while visit returns true
+func walk(n *html.Node, visit func(*html.Node) bool) {
+	if n.Type == html.ElementNode && !visit(n) {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, visit)
+	}
+}
+
+func firstChild(n *html.Node, tag string) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == tag {
+			return c
+		}
+	}
+	return nil
+}
+
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(attr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+	return b.String()
+}
@@ -0,0 +1,108 @@
+// Package wayback reads archived Pinkbike buysell pages from the Internet
+// Archive's Wayback Machine so market data from before scraping started can be
+// backfilled
+package wayback
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	defaultBaseURL = "https://web.archive.org"
+	// timestampFormat is the layout of Wayback Machine capture timestamps
+	timestampFormat = "20060102150405"
+)
+
+// Snapshot is a single archived capture of a page
+type Snapshot struct {
+	Timestamp   time.Time
+	OriginalURL string
+}
+
+// Client queries the Wayback Machine CDX index and fetches archived pages
+type Client struct {
+	baseURL string
+	http    *http.Client
+	// Delay is waited before every request to stay within the archive's rate limits
+	Delay time.Duration
+}
+
+func NewClient() *Client {
+	return &Client{
+		baseURL: defaultBaseURL,
+		http:    &http.Client{Timeout: time.Minute},
+		Delay:   time.Second,
+	}
+}
+
+// Snapshots lists captures of buysell listing pages for a category between
+// from and to, keeping at most one capture per day
+func (c *Client) Snapshots(categoryID int, from, to time.Time) ([]Snapshot, error) {
+	params := url.Values{}
+	params.Set("url", "pinkbike.com/buysell/list/")
+	params.Set("matchType", "prefix")
+	params.Set("output", "json")
+	params.Set("fl", "timestamp,original")
+	params.Set("from", from.Format("20060102"))
+	params.Set("to", to.Format("20060102"))
+	params.Add("filter", "statuscode:200")
+	params.Add("filter", fmt.Sprintf(`original:.*[?&]category=%d(&.*)?$`, categoryID))
+	params.Set("collapse", "timestamp:8")
+
+	body, err := c.get(c.baseURL + "/cdx/search/cdx?" + params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("could not list snapshots: %w", err)
+	}
+
+	var rows [][]string
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &rows); err != nil {
+			return nil, fmt.Errorf("could not parse snapshot index: %w", err)
+		}
+	}
+
+	var snapshots []Snapshot
+	for i, row := range rows {
+		// the first row holds the field names
+		if i == 0 || len(row) < 2 {
+			continue
+		}
+		ts, err := time.Parse(timestampFormat, row[0])
+		if err != nil {
+			return nil, fmt.Errorf("could not parse snapshot timestamp %q: %w", row[0], err)
+		}
+		snapshots = append(snapshots, Snapshot{Timestamp: ts, OriginalURL: row[1]})
+	}
+	return snapshots, nil
+}
+
+// Fetch returns the page as it was captured, without the archive's toolbar and link rewriting
+func (c *Client) Fetch(s Snapshot) ([]byte, error) {
+	body, err := c.get(fmt.Sprintf("%s/web/%sid_/%s", c.baseURL, s.Timestamp.Format(timestampFormat), s.OriginalURL))
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch snapshot %s: %w", s.Timestamp.Format(timestampFormat), err)
+	}
+	return body, nil
+}
+
+func (c *Client) get(u string) ([]byte, error) {
+	if c.Delay > 0 {
+		time.Sleep(c.Delay)
+	}
+
+	resp, err := c.http.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
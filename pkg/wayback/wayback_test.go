@@ -0,0 +1,80 @@
+package wayback
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/parser"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseListingsPage(t *testing.T) {
+	// the page the browser scraper is tested against
+	page, err := os.ReadFile("../scraper/testdata/listingsPage.html")
+	require.NoError(t, err)
+
+	listings, err := ParseListingsPage(page)
+	require.NoError(t, err)
+	require.Len(t, listings, 20)
+
+	got := listings[17].PostProcess(1.0, nil)
+	got.Metadata = nil
+	assert.Equal(t, listing.Listing{
+		Title:          "2022 NEW Scott Contessa Spark 920, size S, 29.52lbs",
+		Year:           "2022",
+		Manufacturer:   "Scott",
+		Model:          "Spark",
+		Price:          "3300",
+		ListedPrice:    "3300",
+		Currency:       "USD",
+		Condition:      "New - Unridden/With Tags",
+		FrameSize:      "S",
+		NormalizedSize: "S",
+		ConditionGrade: parser.ConditionNew,
+		Kind:           parser.KindBike,
+		WheelSize:      "29",
+		Wheels:         parser.Wheels{Front: 29, Rear: 29},
+		FrameMaterial:  "Carbon Fiber",
+		FrontTravel:    "130 mm",
+		RearTravel:     "120 mm",
+		URL:            "https://www.pinkbike.com/buysell/3960926/",
+		ListingID:      3960926,
+	}, got)
+}
+
+func TestUnarchiveURL(t *testing.T) {
+	assert.Equal(t, "https://www.pinkbike.com/buysell/2512345/", unarchiveURL("/web/20190301000000/https://www.pinkbike.com/buysell/2512345/"))
+	assert.Equal(t, "https://www.pinkbike.com/buysell/2512345/", unarchiveURL("https://www.pinkbike.com/buysell/2512345/"))
+}
+
+func TestSnapshotsAndFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cdx/search/cdx":
+			assert.Equal(t, "20190101", r.URL.Query().Get("from"))
+			assert.Contains(t, r.URL.Query()["filter"], `original:.*[?&]category=2(&.*)?$`)
+			fmt.Fprint(w, `[["timestamp","original"],["20190315120000","https://www.pinkbike.com/buysell/list/?category=2"]]`)
+		case "/web/20190315120000id_/https://www.pinkbike.com/buysell/list/":
+			fmt.Fprint(w, "<html></html>")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := &Client{baseURL: server.URL, http: server.Client()}
+	snapshots, err := c.Snapshots(2, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	assert.Equal(t, time.Date(2019, 3, 15, 12, 0, 0, 0, time.UTC), snapshots[0].Timestamp)
+
+	_, err = c.Fetch(Snapshot{Timestamp: snapshots[0].Timestamp, OriginalURL: "https://www.pinkbike.com/buysell/list/"})
+	require.NoError(t, err)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/privacy"
+	"pinkbike-scraper/pkg/report"
+)
+
+func runPublish(args []string) error {
+	defaults := report.DefaultSiteOptions()
+	fs := flag.NewFlagSet("publish", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database to build the price guide from")
+	out := fs.String("out", "site", "The directory the site is written to, such as a GitHub Pages docs/ folder")
+	title := fs.String("title", defaults.Title, "Title of the site's front page")
+	category := fs.String("category", "", "Only listings scraped under this bike type (e.g. enduro)")
+	minListings := fs.Int("minListings", defaults.MinListings, "Listings a model needs for a page of its own")
+	recent := fs.Int("recent", defaults.Recent, "Number of latest listings shown on each model page")
+	publishMinCount := fs.Int("publishMinCount", 0, "Leave models and model years with fewer listings out of the site")
+	publishEpsilon := fs.Float64("publishEpsilon", 0, "Differential privacy budget per model; smaller adds more noise (0 publishes exact values). Model pages then leave out individual listings.")
+	fixedTime := fs.String("fixedTime", "", "Publish as if it were this time (YYYY-MM-DD or RFC 3339) so the output is reproducible")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	clk, err := parseClock(*fixedTime)
+	if err != nil {
+		return err
+	}
+
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, exporter.DefaultDBOptions())
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	listings, err := dbExp.StoredListings(*category)
+	if err != nil {
+		return err
+	}
+	pages, err := report.WriteSite(*out, listings, clk.Now(), report.SiteOptions{
+		Title:       *title,
+		MinListings: *minListings,
+		Recent:      *recent,
+		Privacy:     privacy.Options{MinCount: *publishMinCount, Epsilon: *publishEpsilon, MaxPrice: 20000},
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d pages to %s\n", pages, *out)
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/parser"
+	"pinkbike-scraper/pkg/scraper"
+)
+
+func runReparse(args []string) error {
+	fs := flag.NewFlagSet("reparse", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database to reparse")
+	category := fs.String("category", "", "Only reparse listings scraped under this bike type (e.g. enduro)")
+	bikeType := fs.String("bikeType", "enduro", "The bike type to validate listings stored without a category against")
+	sizeSchemesPath := fs.String("sizeSchemes", "", "JSON file mapping each manufacturer's size labels to canonical sizes, added to the built-in schemes")
+	validationRulesPath := fs.String("validationRules", "", "JSON file of validation rules added to the built-in rules, as for the scraper")
+	dryRun := fs.Bool("dryRun", false, "Print what would change without writing it")
+	skipBadRows := fs.Bool("skipBadRows", false, "Leave out stored listings that cannot be read instead of failing")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	fallback, err := scraper.LookupBikeType(*bikeType)
+	if err != nil {
+		return err
+	}
+
+	sizes := parser.NewSizes()
+	if *sizeSchemesPath != "" {
+		if sizes, err = parser.LoadSizeSchemes(*sizeSchemesPath); err != nil {
+			return fmt.Errorf("could not load size schemes: %v", err)
+		}
+	}
+
+	var rules []listing.Rule
+	if *validationRulesPath != "" {
+		if rules, err = listing.LoadRules(*validationRulesPath); err != nil {
+			return err
+		}
+	}
+
+	dbOptions := exporter.DefaultDBOptions()
+	dbOptions.SkipBadRows = *skipBadRows
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, dbOptions)
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	aliases, err := dbExp.Aliases()
+	if err != nil {
+		return fmt.Errorf("could not load aliases: %v", err)
+	}
+
+	stored, err := dbExp.StoredListings(*category)
+	if err != nil {
+		return err
+	}
+	reportSkippedRows(dbExp)
+
+	changed, failed := 0, 0
+	for _, l := range stored {
+		bikeTypeInfo, err := scraper.LookupBikeType(l.Category)
+		if err != nil {
+			bikeTypeInfo = fallback
+		}
+		validation := bikeTypeInfo.Validation
+		if rules != nil {
+			validation = validation.WithRules(rules)
+		}
+		reparsed := l.Reparse(clock.System).ApplyAliases(aliases).ApplySizes(sizes).Validate(validation)
+		reparsed.IsElectric = reparsed.IsElectric || bikeTypeInfo.Electric
+		reparsed.Hash = reparsed.ComputeHash()
+
+		changes := exporter.ReparseChanges(l, reparsed)
+		if !*dryRun && len(changes) > 0 {
+			if changes, err = dbExp.SaveReparse(l, reparsed); err != nil {
+				fmt.Printf("%s: %v\n", l.Title, err)
+				failed++
+				continue
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+
+		changed++
+		fields := make([]string, len(changes))
+		for i, c := range changes {
+			fields[i] = fmt.Sprintf("%s %q -> %q", c.Field, c.Old, c.New)
+		}
+		fmt.Printf("%s\n\t%s\n", l.Title, strings.Join(fields, "\n\t"))
+	}
+
+	verb := "updated"
+	if *dryRun {
+		verb = "would be updated"
+	}
+	fmt.Printf("%d of %d listings %s", changed, len(stored), verb)
+	if failed > 0 {
+		fmt.Printf(", %d could not be saved", failed)
+	}
+	fmt.Println()
+	return nil
+}
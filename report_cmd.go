@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/priceindex"
+	"pinkbike-scraper/pkg/report"
+)
+
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database price indexes are stored in")
+	out := fs.String("out", "report.html", "The HTML file the report is written to")
+	indexes := fs.String("index", "", "Comma-separated indexes to chart, \"all\" or models such as \"Santa Cruz Megatower\" (default charts every index)")
+	refresh := fs.Bool("refresh", false, "Recompute the indexes from the stored listings first")
+	fixedTime := fs.String("fixedTime", "", "Report as if it were this time (YYYY-MM-DD or RFC 3339) so the output is reproducible")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	clk, err := parseClock(*fixedTime)
+	if err != nil {
+		return err
+	}
+
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, exporter.DefaultDBOptions())
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	if *refresh {
+		if _, err := dbExp.RefreshIndexes(priceindex.DefaultOptions()); err != nil {
+			return err
+		}
+	}
+
+	if err := writeReport(dbExp, splitList(*indexes), *out, clk.Now()); err != nil {
+		return err
+	}
+	fmt.Printf("Report written to %s\n", *out)
+	return nil
+}
+
+// writeReport charts the named price indexes, or every index when names is
+// empty, into an HTML report at path
+func writeReport(dbExp *exporter.DBExporter, names []string, path string, now time.Time) error {
+	if len(names) == 0 {
+		latest, err := dbExp.LatestIndexes()
+		if err != nil {
+			return err
+		}
+		for _, p := range latest {
+			names = append(names, p.Name)
+		}
+	}
+
+	r := report.Report{Title: "Pinkbike price report", GeneratedAt: now}
+	for _, name := range names {
+		points, err := dbExp.IndexSeries(name)
+		if err != nil {
+			return err
+		}
+		if len(points) == 0 {
+			return fmt.Errorf("no index named %q, see the analytics command for the indexes", name)
+		}
+		r.Series = append(r.Series, points)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("could not create report directory: %v", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create report: %v", err)
+	}
+	defer f.Close()
+	if err := report.Write(f, r); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/listing"
+)
+
+func runReview(args []string) error {
+	fs := flag.NewFlagSet("review", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database to review")
+	limit := fs.Int("limit", 0, "Maximum number of listings to review (0 reviews all)")
+	skipBadRows := fs.Bool("skipBadRows", false, "Leave out stored listings that cannot be read instead of failing")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	dbOptions := exporter.DefaultDBOptions()
+	dbOptions.SkipBadRows = *skipBadRows
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, dbOptions)
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	listings, err := dbExp.ListingsNeedingReview(*limit)
+	if err != nil {
+		return err
+	}
+	reportSkippedRows(dbExp)
+	if len(listings) == 0 {
+		fmt.Println("No listings need review")
+		return nil
+	}
+
+	return reviewListings(dbExp, listings, os.Stdin, os.Stdout)
+}
+
+// reviewListings walks the user through each flagged listing, saving
+// corrections and the aliases that explain them
+func reviewListings(dbExp *exporter.DBExporter, listings []listing.Listing, in io.Reader, out io.Writer) error {
+	reader := bufio.NewReader(in)
+	prompt := func(format string, a ...interface{}) (string, error) {
+		fmt.Fprintf(out, format, a...)
+		line, err := reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
+		}
+		return strings.TrimSpace(line), nil
+	}
+
+	corrected := 0
+	for i, l := range listings {
+		fmt.Fprintf(out, "\n[%d/%d] %s\n", i+1, len(listings), l.URL)
+		fmt.Fprintf(out, "  Needs review:  %s\n", l.NeedsReview)
+		fmt.Fprintf(out, "  Raw title:     %s\n", l.Title)
+		fmt.Fprintf(out, "  Raw fields:    %s %s | %s | frame %s | wheels %s | travel %s / %s | %s\n",
+			l.Price, l.Currency, l.Condition, l.FrameSize, l.WheelSize, l.FrontTravel, l.RearTravel, l.FrameMaterial)
+		fmt.Fprintf(out, "  Parsed:        year %q, manufacturer %q, model %q\n", l.Year, l.Manufacturer, l.Model)
+		if !l.InferredYears.IsZero() {
+			fmt.Fprintf(out, "  Inferred:      model year %s from the components and model\n", l.InferredYears)
+		}
+
+		action, err := prompt("(e)dit, (s)kip, (q)uit [e]: ")
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(action) {
+		case "s":
+			continue
+		case "q":
+			fmt.Fprintf(out, "Corrected %d listings\n", corrected)
+			return nil
+		}
+
+		c := exporter.Correction{Year: l.Year, Manufacturer: l.Manufacturer, Model: l.Model}
+		for _, field := range []struct {
+			label string
+			value *string
+		}{
+			{"Year", &c.Year},
+			{"Manufacturer", &c.Manufacturer},
+			{"Model", &c.Model},
+		} {
+			answer, err := prompt("  %s [%s]: ", field.label, *field.value)
+			if err != nil {
+				return err
+			}
+			if answer != "" {
+				*field.value = answer
+			}
+		}
+
+		if c == (exporter.Correction{Year: l.Year, Manufacturer: l.Manufacturer, Model: l.Model}) {
+			fmt.Fprintln(out, "  No changes")
+			continue
+		}
+
+		accept, err := prompt("  Save correction? [Y/n]: ")
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(accept, "n") {
+			continue
+		}
+
+		saved, err := dbExp.SaveCorrection(l, c)
+		if err != nil {
+			return err
+		}
+		corrected++
+		if len(saved.NeedsReview) > 0 {
+			fmt.Fprintf(out, "  Saved, still needs review: %s\n", saved.NeedsReview)
+		}
+
+		// teach the parser how this title named the bike so future listings resolve on their own
+		if c.Manufacturer != l.Manufacturer {
+			name, err := prompt("  Text in the title that means %s (blank to skip): ", c.Manufacturer)
+			if err != nil {
+				return err
+			}
+			if name != "" {
+				if err := dbExp.AddManufacturerAlias(name, c.Manufacturer); err != nil {
+					return err
+				}
+			}
+		}
+		if c.Model != l.Model {
+			name, err := prompt("  Text in the title that means %s %s (blank to skip): ", c.Manufacturer, c.Model)
+			if err != nil {
+				return err
+			}
+			if name != "" {
+				if err := dbExp.AddModelAlias(c.Manufacturer, name, c.Model); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	fmt.Fprintf(out, "Corrected %d listings\n", corrected)
+	return nil
+}
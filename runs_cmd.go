@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"pinkbike-scraper/pkg/exporter"
+)
+
+func runRuns(args []string) error {
+	fs := flag.NewFlagSet("runs", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database runs are recorded in")
+	limit := fs.Int("limit", 20, "Number of recent runs to show (0 shows all)")
+	showErrors := fs.Bool("errors", false, "Print the errors of each run")
+	summary := fs.Bool("summary", false, "Print the full summary of each run: failures by field, export outcomes and thresholds exceeded")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, exporter.DefaultDBOptions())
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	runs, err := dbExp.RecentRuns(*limit)
+	if err != nil {
+		return err
+	}
+
+	if *summary {
+		for i, r := range runs {
+			if i > 0 {
+				fmt.Println()
+			}
+			if err := r.WriteSummary(os.Stdout); err != nil {
+				return err
+			}
+			if *showErrors {
+				for _, e := range r.Errors {
+					fmt.Printf("    %s\n", e)
+				}
+			}
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTARTED\tDURATION\tTYPE\tMODE\tPAGES\tLISTINGS\tNEW\tUPDATED\tDROPS\tSOLD\tERRORS\tBLOCKED\tFAILED")
+	for _, r := range runs {
+		duration := "incomplete"
+		if !r.FinishedAt.IsZero() {
+			duration = r.FinishedAt.Sub(r.StartedAt).Round(time.Second).String()
+		}
+		blocked, failed := "", ""
+		if r.Blocked {
+			blocked = "yes"
+		}
+		if len(r.Exceeded) > 0 {
+			failed = "yes"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%s\n", r.ID, r.StartedAt.Local().Format("2006-01-02 15:04"),
+			duration, r.BikeType, r.InputMode, r.Pages, r.Listings, r.New, r.Updated, r.PriceDrops, r.Sold, len(r.Errors), blocked, failed)
+		if *showErrors {
+			for _, e := range r.Errors {
+				fmt.Fprintf(w, "\t%s\n", e)
+			}
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/geo"
+	"pinkbike-scraper/pkg/listing"
+)
+
+func runSearch(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database to search")
+	limit := fs.Int("limit", 20, "Maximum number of results (0 for no limit)")
+	category := fs.String("category", "", "Only search listings scraped under this bike type (e.g. enduro)")
+	skipBadRows := fs.Bool("skipBadRows", false, "Leave out stored listings that cannot be read instead of failing")
+	nearFlags := addNearFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: pinkbike-scraper search [flags] <query>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	query := strings.Join(fs.Args(), " ")
+	if strings.TrimSpace(query) == "" {
+		fs.Usage()
+		return fmt.Errorf("search needs a query")
+	}
+
+	dbOptions := exporter.DefaultDBOptions()
+	dbOptions.SkipBadRows = *skipBadRows
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, dbOptions)
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	near, err := nearFlags.locate(dbExp)
+	if err != nil {
+		return err
+	}
+	// the distance is only known after the query, so a -near search takes
+	// every match and applies -limit itself
+	queryLimit := *limit
+	if !near.IsZero() {
+		queryLimit = 0
+	}
+	results, err := dbExp.SearchListings(query, *category, queryLimit)
+	if err != nil {
+		return err
+	}
+	reportSkippedRows(dbExp)
+	if !near.IsZero() {
+		results = keepListings(results, func(l listing.Listing) bool {
+			km, ok := l.DistanceKM(near)
+			return ok && km <= *nearFlags.maxKM
+		})
+		if *limit > 0 && len(results) > *limit {
+			results = results[:*limit]
+		}
+	}
+
+	for _, l := range results {
+		status := "active"
+		if !l.Active {
+			status = "inactive"
+		}
+		if km, ok := l.DistanceKM(near); ok {
+			status += ", " + geo.FormatKM(km) + "km away"
+		}
+		fmt.Printf("%s %s (%s) - %s %s [%s]\n\t%s\n", l.Year, l.Title, l.Manufacturer, l.Price, l.Currency, status, l.URL)
+	}
+	fmt.Printf("%d listings found\n", len(results))
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"pinkbike-scraper/pkg/exporter"
+)
+
+func runSellers(args []string) error {
+	fs := flag.NewFlagSet("sellers", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database to read sellers from")
+	minActive := fs.Int("min", 2, "Only show sellers with at least this many active listings")
+	threshold := fs.Int("businessThreshold", exporter.DefaultBusinessThreshold, "Flag private sellers with at least this many active listings as likely businesses (0 disables)")
+	flagged := fs.Bool("flagged", false, "Only show sellers flagged as likely businesses")
+	history := fs.String("history", "", "Print the daily listing counts of this seller instead")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, exporter.DefaultDBOptions())
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if *history != "" {
+		counts, err := dbExp.SellerHistory(*history)
+		if err != nil {
+			return err
+		}
+		if len(counts) == 0 {
+			return fmt.Errorf("no listing counts recorded for seller %q", *history)
+		}
+		fmt.Fprintln(w, "DATE\tACTIVE\tTOTAL")
+		for _, c := range counts {
+			fmt.Fprintf(w, "%s\t%d\t%d\n", c.Date.Format("2006-01-02"), c.ActiveListings, c.TotalListings)
+		}
+		return w.Flush()
+	}
+
+	sellers, err := dbExp.Sellers(*minActive)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "SELLER\tTYPE\tACTIVE\tTOTAL\tFIRST SEEN\tFLAG\tPROFILE")
+	for _, s := range sellers {
+		note := ""
+		if s.LikelyBusiness(*threshold) {
+			note = "likely business"
+		} else if *flagged {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\t%s\n", s.Username, s.SellerType, s.ActiveListings, s.TotalListings,
+			s.FirstSeen.Local().Format("2006-01-02"), note, s.ProfileURL)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"pinkbike-scraper/pkg/api"
+	"pinkbike-scraper/pkg/events"
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/server"
+)
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database to serve")
+	listen := fs.String("listen", "localhost:8080", "Address the API listens on")
+	grpcListen := fs.String("grpcListen", "", "Also serve the listings over gRPC on this address, such as localhost:9090")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, exporter.DefaultDBOptions())
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// scrapes export from their own process, so relay the events they record
+	bus := events.NewBus()
+	go func() {
+		if err := dbExp.RelayEvents(ctx, bus, 5*time.Second); err != nil {
+			log.Printf("could not relay listing events: %v", err)
+		}
+	}()
+
+	handler := server.New(dbExp, bus)
+	srv := &http.Server{
+		Addr:              *listen,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	srv.RegisterOnShutdown(handler.Close)
+
+	var grpcSrv *grpc.Server
+	if *grpcListen != "" {
+		lis, err := net.Listen("tcp", *grpcListen)
+		if err != nil {
+			return fmt.Errorf("could not listen for gRPC: %v", err)
+		}
+		grpcSrv = grpc.NewServer()
+		api.RegisterListingsServer(grpcSrv, api.NewServer(dbExp))
+		go func() {
+			if err := grpcSrv.Serve(lis); err != nil {
+				log.Printf("could not serve gRPC: %v", err)
+			}
+		}()
+		fmt.Printf("Serving gRPC on %s\n", *grpcListen)
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if grpcSrv != nil {
+			// streams only end when their clients go, so stop them at the deadline
+			stopped := make(chan struct{})
+			go func() {
+				grpcSrv.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-shutdown.Done():
+				grpcSrv.Stop()
+			}
+		}
+		srv.Shutdown(shutdown)
+	}()
+
+	fmt.Printf("Serving %s on http://%s, press Ctrl+C to stop\n", *dbPath, *listen)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("could not serve: %v", err)
+	}
+	return nil
+}
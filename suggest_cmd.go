@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/suggest"
+)
+
+const suggestionsDir = "suggestions"
+
+func runSuggestModels(args []string) error {
+	fs := flag.NewFlagSet("suggest-models", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database to read unmatched titles from")
+	days := fs.Int("days", 7, "Only use listings seen in this many days")
+	minCount := fs.Int("minCount", 3, "Minimum listings sharing a model name before it is suggested")
+	dir := fs.String("dir", suggestionsDir, "Directory the review file is written to")
+	fixedTime := fs.String("fixedTime", "", "Suggest as if it were this time (YYYY-MM-DD or RFC 3339) so the output is reproducible")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	clk, err := parseClock(*fixedTime)
+	if err != nil {
+		return err
+	}
+
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, exporter.DefaultDBOptions())
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	path, count, err := writeModelSuggestions(dbExp, *dir, clk.Now(), time.Duration(*days)*24*time.Hour, *minCount)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d model suggestions to %s\n", count, path)
+	return nil
+}
+
+// writeModelSuggestions clusters the titles without a model seen in the
+// window before now and writes the suggested model database entries to a
+// review file in dir
+func writeModelSuggestions(dbExp *exporter.DBExporter, dir string, now time.Time, window time.Duration, minCount int) (string, int, error) {
+	since := now.Add(-window)
+
+	titles, err := dbExp.UnmatchedModelTitles(since)
+	if err != nil {
+		return "", 0, err
+	}
+
+	report := suggest.Report{
+		GeneratedAt: now,
+		Since:       since,
+		Suggestions: suggest.Models(titles, minCount),
+	}
+	path, err := report.Write(dir)
+	if err != nil {
+		return "", 0, err
+	}
+	return path, len(report.Suggestions), nil
+}
+
+// suggestModelsIfDue writes model suggestions when the last review file is older than every
+func suggestModelsIfDue(dbExp *exporter.DBExporter, every time.Duration, clk clock.Clock) {
+	last, err := suggest.LastGenerated(suggestionsDir)
+	if err != nil {
+		log.Printf("could not check model suggestions: %v", err)
+		return
+	}
+	now := clk.Now()
+	if now.Sub(last) < every {
+		return
+	}
+
+	path, count, err := writeModelSuggestions(dbExp, suggestionsDir, now, every, 3)
+	if err != nil {
+		log.Printf("could not write model suggestions: %v", err)
+		return
+	}
+	status("Wrote %d model suggestions to %s\n", count, path)
+}
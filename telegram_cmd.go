@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"pinkbike-scraper/pkg/cache"
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/notify"
+)
+
+func runTelegramBot(args []string) error {
+	fs := flag.NewFlagSet("telegram-bot", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database saved searches and prices are read from")
+	token := fs.String("token", os.Getenv("TELEGRAM_BOT_TOKEN"), "Telegram bot token (defaults to $TELEGRAM_BOT_TOKEN)")
+	geocoderName := fs.String("geocoder", "nominatim", geocoderUsage)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *token == "" {
+		return fmt.Errorf("telegram-bot needs -token or TELEGRAM_BOT_TOKEN")
+	}
+
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, exporter.DefaultDBOptions())
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	geocoder, err := openGeocoder(*geocoderName, &cache.Cache{Store: dbExp})
+	if err != nil {
+		return fmt.Errorf("could not open geocoder: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Println("Telegram bot running, press Ctrl+C to stop")
+	if err := notify.NewBot(notify.NewTelegram(*token), dbExp, geocoder).Run(ctx); err != context.Canceled {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"pinkbike-scraper/pkg/cache"
+	"pinkbike-scraper/pkg/clock"
+	"pinkbike-scraper/pkg/currency"
+	"pinkbike-scraper/pkg/exporter"
+	"pinkbike-scraper/pkg/listing"
+	"pinkbike-scraper/pkg/scraper"
+	"pinkbike-scraper/pkg/wayback"
+)
+
+func runWayback(args []string) error {
+	fs := flag.NewFlagSet("wayback", flag.ExitOnError)
+	dbPath := fs.String("db", "listings.db", "The listings database to backfill")
+	bikeType := fs.String("bikeType", "enduro", "The type of bike to import archived listings for")
+	from := fs.String("from", "", "First day to import captures from (YYYY-MM-DD)")
+	to := fs.String("to", time.Now().Format("2006-01-02"), "Last day to import captures from (YYYY-MM-DD)")
+	maxSnapshots := fs.Int("maxSnapshots", 0, "Maximum number of archived pages to import (0 imports all)")
+	delay := fs.Duration("delay", time.Second, "Pause between requests to the Wayback Machine")
+	fixedExchangeRate := fs.Float64("fixedExchangeRate", 0, "Convert CAD prices at this CAD to USD rate instead of fetching the current one (0 fetches)")
+	historicalRates := fs.Bool("historicalRates", true, "Convert archived CAD prices at the rate on the day each page was archived instead of today's rate")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	bikeTypeInfo, err := scraper.LookupBikeType(*bikeType)
+	if err != nil {
+		return err
+	}
+
+	fromDate, err := time.Parse("2006-01-02", *from)
+	if err != nil {
+		return fmt.Errorf("invalid -from date %q: %v", *from, err)
+	}
+	toDate, err := time.Parse("2006-01-02", *to)
+	if err != nil {
+		return fmt.Errorf("invalid -to date %q: %v", *to, err)
+	}
+
+	dbExp, err := exporter.NewDBExporter(*dbPath, nil, exporter.DefaultDBOptions())
+	if err != nil {
+		return fmt.Errorf("could not open database: %v", err)
+	}
+	defer dbExp.Close()
+
+	// rates on the days of the captures are cached, so reruns do not fetch them again
+	rates := currency.Cached(currency.ExchangeRateAPI{}, &cache.Cache{Store: dbExp}, 12*time.Hour)
+	if *fixedExchangeRate > 0 {
+		rates = currency.FixedRate{Value: *fixedExchangeRate}
+	}
+	rate, err := rates.CADtoUSD()
+	if err != nil {
+		return fmt.Errorf("could not get exchange rate: %v", err)
+	}
+	converter := currency.NewConverter(rates)
+
+	aliases, err := dbExp.Aliases()
+	if err != nil {
+		return err
+	}
+
+	client := wayback.NewClient()
+	client.Delay = *delay
+
+	snapshots, err := client.Snapshots(bikeTypeInfo.CategoryID, fromDate, toDate)
+	if err != nil {
+		return err
+	}
+	if *maxSnapshots > 0 && len(snapshots) > *maxSnapshots {
+		snapshots = snapshots[:*maxSnapshots]
+	}
+	fmt.Printf("Found %d archived pages\n", len(snapshots))
+
+	total := 0
+	for _, snapshot := range snapshots {
+		page, err := client.Fetch(snapshot)
+		if err != nil {
+			log.Printf("skipping snapshot: %v", err)
+			continue
+		}
+
+		rawListings, err := wayback.ParseListingsPage(page)
+		if err != nil {
+			log.Printf("skipping snapshot %s: %v", snapshot.Timestamp.Format("2006-01-02"), err)
+			continue
+		}
+
+		cadToUSD := rate.Value
+		if *historicalRates {
+			if archived, err := converter.RateOn("CAD", currency.USD, snapshot.Timestamp); err != nil {
+				log.Printf("converting snapshot %s at today's rate: %v", snapshot.Timestamp.Format("2006-01-02"), err)
+			} else {
+				cadToUSD = archived.Value
+			}
+		}
+
+		var listings []listing.Listing
+		for _, l := range rawListings {
+			refined := l.PostProcess(cadToUSD, clock.System).ApplyAliases(aliases).Validate(bikeTypeInfo.Validation)
+			refined.Category = string(bikeTypeInfo.Type)
+			listings = append(listings, refined)
+		}
+
+		imported, err := dbExp.ImportHistorical(listings, snapshot.Timestamp)
+		if err != nil {
+			return err
+		}
+		total += imported
+		fmt.Printf("%s: imported %d listings\n", snapshot.Timestamp.Format("2006-01-02"), imported)
+	}
+
+	fmt.Printf("Imported %d archived listings\n", total)
+	return nil
+}